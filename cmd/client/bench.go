@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aichatplayers/internal/api"
+	"aichatplayers/internal/logging"
+)
+
+type benchOptions struct {
+	BaseURL     string
+	Endpoint    string
+	Token       string
+	Concurrency int
+	Duration    time.Duration
+	// MaxRPS caps the request rate across all workers; zero is unlimited.
+	MaxRPS float64
+}
+
+type BenchResult struct {
+	Endpoint    string         `json:"endpoint"`
+	Concurrency int            `json:"concurrency"`
+	MaxRPS      float64        `json:"max_rps,omitempty"`
+	DurationS   float64        `json:"duration_s"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	ErrorRate   float64        `json:"error_rate"`
+	RPS         float64        `json:"rps"`
+	LatencyMS   BenchLatency   `json:"latency_ms"`
+	StatusCodes map[string]int `json:"status_codes"`
+}
+
+// BenchLatency covers every request, failed ones included.
+type BenchLatency struct {
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+type benchSample struct {
+	latency time.Duration
+	status  string
+	ok      bool
+}
+
+// runBench sends generated plan requests from opts.Concurrency workers until
+// opts.Duration is over. Any non-2xx answer or transport error is an error.
+func runBench(client *http.Client, opts benchOptions) BenchResult {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Duration)
+	defer cancel()
+
+	var ticks <-chan time.Time
+	if opts.MaxRPS > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.MaxRPS))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var (
+		mu      sync.Mutex
+		samples []benchSample
+		seq     atomic.Int64
+		wg      sync.WaitGroup
+	)
+	started := time.Now()
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for {
+				if ticks != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticks:
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				sample := benchRequest(ctx, client, opts, benchPayload(rng, opts.Endpoint, seq.Add(1)))
+				if sample.status == "canceled" {
+					return
+				}
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	return summarizeBench(opts, samples, time.Since(started))
+}
+
+func benchRequest(ctx context.Context, client *http.Client, opts benchOptions, payload any) benchSample {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return benchSample{status: "marshal_error"}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.BaseURL+opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return benchSample{status: "request_error"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return benchSample{status: "canceled"}
+		}
+		return benchSample{latency: time.Since(start), status: "transport_error"}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return benchSample{
+		latency: time.Since(start),
+		status:  strconv.Itoa(resp.StatusCode),
+		ok:      resp.StatusCode >= 200 && resp.StatusCode < 300,
+	}
+}
+
+func summarizeBench(opts benchOptions, samples []benchSample, elapsed time.Duration) BenchResult {
+	result := BenchResult{
+		Endpoint:    opts.Endpoint,
+		Concurrency: opts.Concurrency,
+		MaxRPS:      opts.MaxRPS,
+		DurationS:   elapsed.Seconds(),
+		Requests:    len(samples),
+		StatusCodes: map[string]int{},
+	}
+	latencies := make([]time.Duration, 0, len(samples))
+	var total time.Duration
+	for _, sample := range samples {
+		result.StatusCodes[sample.status]++
+		if !sample.ok {
+			result.Errors++
+		}
+		latencies = append(latencies, sample.latency)
+		total += sample.latency
+	}
+	if len(samples) == 0 {
+		return result
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.ErrorRate = float64(result.Errors) / float64(len(samples))
+	if elapsed > 0 {
+		result.RPS = float64(len(samples)) / elapsed.Seconds()
+	}
+	result.LatencyMS = BenchLatency{
+		P50:  durationMS(percentile(latencies, 50)),
+		P95:  durationMS(percentile(latencies, 95)),
+		P99:  durationMS(percentile(latencies, 99)),
+		Max:  durationMS(latencies[len(latencies)-1]),
+		Mean: durationMS(total / time.Duration(len(latencies))),
+	}
+	return result
+}
+
+// percentile picks the nearest-rank value of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func reportBench(result BenchResult, outPath string) error {
+	fmt.Printf("endpoint: %s concurrency: %d duration: %.1fs\n", result.Endpoint, result.Concurrency, result.DurationS)
+	fmt.Printf("requests: %d errors: %d (%.2f%%) throughput: %.1f req/s\n", result.Requests, result.Errors, result.ErrorRate*100, result.RPS)
+	fmt.Printf("latency ms: p50 %.1f p95 %.1f p99 %.1f max %.1f mean %.1f\n", result.LatencyMS.P50, result.LatencyMS.P95, result.LatencyMS.P99, result.LatencyMS.Max, result.LatencyMS.Mean)
+	codes := make([]string, 0, len(result.StatusCodes))
+	for code := range result.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("status %s: %d\n", code, result.StatusCodes[code])
+	}
+	logging.Infof("bench_done endpoint=%s requests=%d errors=%d rps=%.1f p50_ms=%.1f p95_ms=%.1f p99_ms=%.1f", result.Endpoint, result.Requests, result.Errors, result.RPS, result.LatencyMS.P50, result.LatencyMS.P95, result.LatencyMS.P99)
+	if outPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	return nil
+}
+
+var (
+	benchBotNames = []string{"Kuba", "Maja", "Olek", "Zosia", "Bartek", "Ola", "Szymon", "Lena"}
+	benchPlayers  = []string{"RealPlayer123", "xXSniperXx", "Creeper_Hunter", "BuilderAnia", "Notch2", "mateo_pl"}
+	benchMessages = []string{
+		"siema ktos idzie na pvp?",
+		"hej wszystkim",
+		"ktos wie jak zrobic portal do netheru?",
+		"gdzie jest spawn?",
+		"ale lag dzisiaj",
+		"kto chce isc na dungeon?",
+		"dobra, juz wiem",
+		"ma ktos diamenty na wymiane?",
+		"gg",
+		"jaki jest najlepszy enchant na miecz?",
+	}
+	benchModes = []string{"LOBBY", "SURVIVAL", "SKYBLOCK", "BEDWARS"}
+)
+
+// benchPayload builds a valid, randomized request for endpoint. Server IDs
+// rotate so per-server rate limits and cooldowns do not dominate results.
+func benchPayload(rng *rand.Rand, endpoint string, seq int64) any {
+	now := time.Now().UnixMilli()
+	bots := make([]api.BotProfile, 1+rng.Intn(4))
+	for i, name := range rng.Perm(len(benchBotNames))[:len(bots)] {
+		bots[i] = api.BotProfile{
+			BotID: fmt.Sprintf("bench_bot_%02d", name),
+			Name:  benchBotNames[name],
+			Persona: api.Persona{
+				Language: "pl",
+				Tone:     "casual",
+			},
+		}
+	}
+	chat := make([]api.ChatMessage, 1+rng.Intn(5))
+	for i := range chat {
+		chat[i] = api.ChatMessage{
+			TimestampMS: now - int64(len(chat)-i)*1500,
+			Sender:      benchPlayers[rng.Intn(len(benchPlayers))],
+			SenderType:  "PLAYER",
+			Message:     benchMessages[rng.Intn(len(benchMessages))],
+		}
+	}
+	req := api.PlanRequest{
+		RequestID: fmt.Sprintf("bench-%d", seq),
+		Server: api.ServerContext{
+			ServerID:      fmt.Sprintf("bench-%d", rng.Intn(50)),
+			Mode:          benchModes[rng.Intn(len(benchModes))],
+			OnlinePlayers: 10 + rng.Intn(190),
+		},
+		Tick:   seq,
+		TimeMS: now,
+		Bots:   bots,
+		Chat:   chat,
+		Settings: api.PlanSettings{
+			MaxActions:          1 + rng.Intn(3),
+			MinDelayMS:          800,
+			MaxDelayMS:          4500,
+			GlobalSilenceChance: 0.2,
+			ReplyChance:         0.7,
+		},
+	}
+	if endpoint == "/v1/engagement" {
+		return api.EngagementRequest{
+			RequestID:    req.RequestID,
+			Server:       req.Server,
+			Tick:         req.Tick,
+			TimeMS:       req.TimeMS,
+			Bots:         req.Bots,
+			Chat:         req.Chat,
+			Settings:     req.Settings,
+			TargetPlayer: chat[len(chat)-1].Sender,
+		}
+	}
+	return req
+}
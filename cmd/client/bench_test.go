@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/api"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 50, want: 50 * time.Millisecond},
+		{p: 95, want: 95 * time.Millisecond},
+		{p: 99, want: 99 * time.Millisecond},
+		{p: 100, want: 100 * time.Millisecond},
+		{p: 0, want: time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]time.Duration{7 * time.Millisecond}, 99); got != 7*time.Millisecond {
+		t.Errorf("percentile of one sample = %v", got)
+	}
+}
+
+func TestSummarizeBench(t *testing.T) {
+	samples := []benchSample{
+		{latency: 10 * time.Millisecond, status: "200", ok: true},
+		{latency: 20 * time.Millisecond, status: "200", ok: true},
+		{latency: 30 * time.Millisecond, status: "429"},
+		{latency: 40 * time.Millisecond, status: "transport_error"},
+	}
+	result := summarizeBench(benchOptions{Endpoint: "/v1/plan", Concurrency: 2}, samples, 2*time.Second)
+	if result.Requests != 4 || result.Errors != 2 || result.ErrorRate != 0.5 || result.RPS != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.LatencyMS.P50 != 20 || result.LatencyMS.P99 != 40 || result.LatencyMS.Mean != 25 {
+		t.Fatalf("latency = %+v", result.LatencyMS)
+	}
+	if result.StatusCodes["200"] != 2 || result.StatusCodes["429"] != 1 {
+		t.Fatalf("status codes = %v", result.StatusCodes)
+	}
+}
+
+func TestRunBenchRespectsMaxRPS(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req api.PlanRequest
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &req); err != nil || req.RequestID == "" || len(req.Bots) == 0 || len(req.Chat) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"actions":[]}`)
+	}))
+	defer server.Close()
+
+	result := runBench(server.Client(), benchOptions{
+		BaseURL:     server.URL,
+		Endpoint:    "/v1/plan",
+		Concurrency: 4,
+		Duration:    500 * time.Millisecond,
+		MaxRPS:      20,
+	})
+	if result.Errors != 0 || result.StatusCodes["200"] != result.Requests {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Requests < 5 || result.Requests > 11 {
+		t.Fatalf("requests = %d in 500ms at 20 rps", result.Requests)
+	}
+}
+
+func TestBenchPayloadEngagement(t *testing.T) {
+	payload := benchPayload(rand.New(rand.NewSource(1)), "/v1/engagement", 7)
+	engagement, ok := payload.(api.EngagementRequest)
+	if !ok || engagement.TargetPlayer == "" || engagement.RequestID != "bench-7" || !strings.HasPrefix(engagement.Server.ServerID, "bench-") {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"aichatplayers/internal/api"
+)
+
+const interactiveHelp = `Type a line to send it as a PLAYER message. Commands:
+  /sys <message>        send a SYSTEM message
+  /bot <name> <message> add a BOT line to the history
+  /bots <file.json>     load bot profiles (an array or {"bots": [...]})
+  /reset                clear the chat history
+  /help                 show this help
+  /quit                 exit`
+
+// chatSession is the state of the interactive simulator: the bots, the
+// rolling chat history and where plans are requested.
+type chatSession struct {
+	client       *http.Client
+	baseURL      string
+	token        string
+	player       string
+	serverID     string
+	historyLimit int
+	settings     api.PlanSettings
+	bots         []api.BotProfile
+	history      []api.ChatMessage
+	seq          int
+}
+
+// runInteractive reads lines from in until EOF or /quit. Every chat line is
+// followed by a plan request whose actions are printed to out and appended
+// to the history as BOT messages.
+func runInteractive(in io.Reader, out io.Writer, session *chatSession) error {
+	fmt.Fprintln(out, interactiveHelp)
+	fmt.Fprintf(out, "bots: %s\n", botNames(session.bots))
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		command, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch command {
+		case "/quit", "/exit":
+			return nil
+		case "/help":
+			fmt.Fprintln(out, interactiveHelp)
+			continue
+		case "/reset":
+			session.history = nil
+			fmt.Fprintln(out, "history cleared")
+			continue
+		case "/bots":
+			bots, err := loadBotProfiles(rest)
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			session.bots = bots
+			fmt.Fprintf(out, "bots: %s\n", botNames(bots))
+			continue
+		case "/sys":
+			session.add(api.ChatMessage{Sender: "Server", SenderType: "SYSTEM", Message: rest})
+		case "/bot":
+			name, message, _ := strings.Cut(rest, " ")
+			if name == "" || strings.TrimSpace(message) == "" {
+				fmt.Fprintln(out, "usage: /bot <name> <message>")
+				continue
+			}
+			session.add(api.ChatMessage{Sender: name, SenderType: "BOT", Message: strings.TrimSpace(message)})
+		default:
+			if strings.HasPrefix(command, "/") {
+				fmt.Fprintf(out, "unknown command %s, see /help\n", command)
+				continue
+			}
+			session.add(api.ChatMessage{Sender: session.player, SenderType: "PLAYER", Message: line})
+		}
+		if err := session.plan(out); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// add appends message stamped with the current time and drops the oldest
+// messages beyond historyLimit.
+func (s *chatSession) add(message api.ChatMessage) {
+	if message.TimestampMS == 0 {
+		message.TimestampMS = time.Now().UnixMilli()
+	}
+	s.history = append(s.history, message)
+	if s.historyLimit > 0 && len(s.history) > s.historyLimit {
+		s.history = append([]api.ChatMessage(nil), s.history[len(s.history)-s.historyLimit:]...)
+	}
+}
+
+func (s *chatSession) plan(out io.Writer) error {
+	s.seq++
+	now := time.Now().UnixMilli()
+	req := api.PlanRequest{
+		RequestID: fmt.Sprintf("interactive-%d", s.seq),
+		Server:    api.ServerContext{ServerID: s.serverID, Mode: "LOBBY", OnlinePlayers: 1 + len(s.bots)},
+		Tick:      int64(s.seq),
+		TimeMS:    now,
+		Bots:      s.bots,
+		Chat:      s.history,
+		Settings:  s.settings,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, s.baseURL+"/v1/plan", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var plan api.PlanResponse
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	if len(plan.Actions) == 0 {
+		fmt.Fprintf(out, "  (no reply, strategy=%s)\n", plan.Debug.ChosenStrategy)
+		return nil
+	}
+	for _, action := range plan.Actions {
+		name := s.botName(action.BotID)
+		fmt.Fprintf(out, "  [+%dms] %s: %s  (reason=%s source=%s)\n", action.SendAfterMS, name, action.Message, action.Reason, action.Source)
+		s.add(api.ChatMessage{TimestampMS: now + action.SendAfterMS, Sender: name, SenderType: "BOT", Message: action.Message})
+	}
+	fmt.Fprintf(out, "  strategy=%s\n", plan.Debug.ChosenStrategy)
+	return nil
+}
+
+func (s *chatSession) botName(botID string) string {
+	for _, bot := range s.bots {
+		if bot.BotID == botID && bot.Name != "" {
+			return bot.Name
+		}
+	}
+	return botID
+}
+
+func botNames(bots []api.BotProfile) string {
+	names := make([]string, 0, len(bots))
+	for _, bot := range bots {
+		names = append(names, bot.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// loadBotProfiles reads a JSON array of bot profiles or an object with a
+// bots field, such as a /v1/bots/register request.
+func loadBotProfiles(path string) ([]api.BotProfile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("usage: /bots <file.json>")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bots []api.BotProfile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &bots)
+	} else {
+		var wrapper struct {
+			Bots []api.BotProfile `json:"bots"`
+		}
+		err = json.Unmarshal(trimmed, &wrapper)
+		bots = wrapper.Bots
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(bots) == 0 {
+		return nil, fmt.Errorf("%s has no bots", path)
+	}
+	return bots, nil
+}
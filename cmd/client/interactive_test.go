@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/api"
+)
+
+func TestRunInteractive(t *testing.T) {
+	var requests []api.PlanRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.PlanRequest
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+		_, _ = io.WriteString(w, `{"actions":[{"bot_id":"bot_02","send_after_ms":1200,"message":"elo","reason":"greeting","source":"heuristic"}],"debug":{"chosen_strategy":"heuristics"}}`)
+	}))
+	defer server.Close()
+
+	botsFile := filepath.Join(t.TempDir(), "bots.json")
+	if err := os.WriteFile(botsFile, []byte(`[{"bot_id":"bot_02","name":"Maja","online":true}]`), 0o600); err != nil {
+		t.Fatalf("write bots: %v", err)
+	}
+	session := &chatSession{client: server.Client(), baseURL: server.URL, player: "Steve", serverID: "test", historyLimit: 4}
+	input := strings.Join([]string{
+		"/bots " + botsFile,
+		"siema",
+		"/sys Event start!",
+		"/bot Olek hej",
+		"/unknown",
+		"/reset",
+		"ktos tu jest?",
+		"/quit",
+		"never sent",
+	}, "\n")
+	var out strings.Builder
+	if err := runInteractive(strings.NewReader(input), &out, session); err != nil {
+		t.Fatalf("runInteractive() error: %v", err)
+	}
+
+	if len(requests) != 4 {
+		t.Fatalf("sent %d plan requests, want 4", len(requests))
+	}
+	senders := func(chat []api.ChatMessage) string {
+		var parts []string
+		for _, message := range chat {
+			parts = append(parts, message.SenderType+":"+message.Sender+":"+message.Message)
+		}
+		return strings.Join(parts, "|")
+	}
+	if got := senders(requests[1].Chat); got != "PLAYER:Steve:siema|BOT:Maja:elo|SYSTEM:Server:Event start!" {
+		t.Fatalf("second request chat = %s", got)
+	}
+	if got := senders(requests[2].Chat); got != "BOT:Maja:elo|SYSTEM:Server:Event start!|BOT:Maja:elo|BOT:Olek:hej" {
+		t.Fatalf("history not capped at 4: %s", got)
+	}
+	if got := senders(requests[3].Chat); got != "PLAYER:Steve:ktos tu jest?" {
+		t.Fatalf("chat after /reset = %s", got)
+	}
+	if len(requests[0].Bots) != 1 || requests[0].Bots[0].Name != "Maja" {
+		t.Fatalf("bots = %+v", requests[0].Bots)
+	}
+	for _, want := range []string{"[+1200ms] Maja: elo  (reason=greeting source=heuristic)", "unknown command /unknown", "history cleared"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestLoadBotProfiles(t *testing.T) {
+	bots, err := loadBotProfiles("../../DOCS/examples/register.json")
+	if err != nil || len(bots) == 0 || bots[0].Name == "" {
+		t.Fatalf("loadBotProfiles(register.json) = %+v, %v", bots, err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"bots":[]}`), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := loadBotProfiles(empty); err == nil || !strings.Contains(err.Error(), "no bots") {
+		t.Fatalf("loadBotProfiles(empty) error = %v", err)
+	}
+}
@@ -7,24 +7,124 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"aichatplayers/internal/api"
 	"aichatplayers/internal/config"
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/version"
 )
 
 func main() {
 	url := flag.String("url", "http://127.0.0.1:8090", "base url of aichatplayers")
+	envFile := flag.String("env-file", "", "comma-separated .env files loaded in order, later ones win (overrides ENV_FILE)")
+	showConfig := flag.Bool("show-config", false, "print the effective configuration (secrets masked) and exit")
+	showVersion := flag.Bool("version", false, "print the build version and exit")
+	scenarioPath := flag.String("scenario", "", "JSON file with one plan request or an array of them to replay instead of the sample")
+	interval := flag.Duration("interval", 0, "pause between scenario requests")
+	bench := flag.Bool("bench", false, "load test the service with generated plan requests and report latency percentiles")
+	concurrency := flag.Int("concurrency", 4, "concurrent requests in -bench mode")
+	duration := flag.Duration("duration", 30*time.Second, "how long -bench runs")
+	endpoint := flag.String("endpoint", "/v1/plan", "endpoint -bench targets: /v1/plan, /v1/plan/async or /v1/engagement")
+	maxRPS := flag.Float64("max-rps", 0, "cap on requests per second across all -bench workers (0 = unlimited)")
+	outPath := flag.String("out", "", "write the -bench result as JSON to this file")
+	interactive := flag.Bool("interactive", false, "type chat lines and see what the bots would answer")
+	player := flag.String("player", "Player", "sender name of lines typed in -interactive mode")
+	historyLimit := flag.Int("history", 20, "chat messages kept in -interactive mode")
+	botsPath := flag.String("bots", "", "JSON file with bot profiles for -interactive mode (default: the sample bots)")
 	flag.Parse()
+	build := version.Get()
+	if *showVersion {
+		fmt.Println(build)
+		return
+	}
+	logging.Infof("client_starting version=%s commit=%s build_date=%s go=%s", build.Version, build.Commit, build.BuildDate, build.GoVersion)
+	if *envFile != "" {
+		_ = os.Setenv("ENV_FILE", *envFile)
+	}
 
 	logging.SetLevelFromEnv("LOG_LEVEL")
 	cfg, err := config.Load()
 	if err != nil {
 		logging.Fatalf("failed to load config: %v", err)
 	}
+	if *showConfig {
+		data, err := json.MarshalIndent(api.ConfigResponse{Config: config.Effective(cfg), Sources: cfg.Sources}, "", "  ")
+		if err != nil {
+			logging.Fatalf("marshal config: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
 	logging.Infof("elastic_config_loaded url=%s index=%s api_key_set=%t verify_cert=%t", cfg.Elastic.URL, cfg.Elastic.Index, cfg.Elastic.APIKey != "", cfg.Elastic.VerifyCert)
 
+	var token string
+	if len(cfg.API.Tokens) > 0 {
+		token = cfg.API.Tokens[0]
+	}
+	if *bench {
+		switch *endpoint {
+		case "/v1/plan", "/v1/plan/async", "/v1/engagement":
+		default:
+			logging.Fatalf("unsupported -endpoint %q (expected /v1/plan, /v1/plan/async or /v1/engagement)", *endpoint)
+		}
+		if *concurrency < 1 || *duration <= 0 || *maxRPS < 0 {
+			logging.Fatalf("-concurrency must be >= 1, -duration > 0 and -max-rps >= 0")
+		}
+		transport := &http.Transport{MaxIdleConnsPerHost: *concurrency}
+		result := runBench(&http.Client{Timeout: 30 * time.Second, Transport: transport}, benchOptions{
+			BaseURL:     *url,
+			Endpoint:    *endpoint,
+			Token:       token,
+			Concurrency: *concurrency,
+			Duration:    *duration,
+			MaxRPS:      *maxRPS,
+		})
+		if err := reportBench(result, *outPath); err != nil {
+			logging.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if *interactive {
+		sample := sampleRequest()
+		session := &chatSession{
+			client:       &http.Client{Timeout: 30 * time.Second},
+			baseURL:      *url,
+			token:        token,
+			player:       *player,
+			serverID:     "interactive",
+			historyLimit: *historyLimit,
+			settings:     sample.Settings,
+			bots:         sample.Bots,
+		}
+		session.settings.GlobalSilenceChance = 0
+		session.settings.ReplyChance = 1
+		if *botsPath != "" {
+			bots, err := loadBotProfiles(*botsPath)
+			if err != nil {
+				logging.Fatalf("%v", err)
+			}
+			session.bots = bots
+		}
+		if err := runInteractive(os.Stdin, os.Stdout, session); err != nil {
+			logging.Fatalf("read stdin: %v", err)
+		}
+		return
+	}
+
+	if *scenarioPath != "" {
+		steps, err := loadScenario(*scenarioPath)
+		if err != nil {
+			logging.Fatalf("%v", err)
+		}
+		if failed := runScenario(&http.Client{Timeout: 30 * time.Second}, *url, token, steps, *interval); failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	payload := sampleRequest()
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -61,7 +161,6 @@ func sampleRequest() api.PlanRequest {
 			{
 				BotID:      "bot_01",
 				Name:       "Kuba",
-				Online:     true,
 				CooldownMS: 0,
 				Persona: api.Persona{
 					Language:       "pl",
@@ -74,7 +173,6 @@ func sampleRequest() api.PlanRequest {
 			{
 				BotID:      "bot_02",
 				Name:       "Maja",
-				Online:     true,
 				CooldownMS: 2000,
 				Persona: api.Persona{
 					Language:       "pl",
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"aichatplayers/internal/api"
+	"aichatplayers/internal/logging"
+)
+
+// scenarioStep is one plan request of a scenario file. The expect_* keys are
+// stripped before the request is sent.
+type scenarioStep struct {
+	Body      []byte
+	RequestID string
+	Expect    scenarioExpect
+}
+
+type scenarioExpect struct {
+	MinActions *int   `json:"expect_min_actions"`
+	MaxActions *int   `json:"expect_max_actions"`
+	Strategy   string `json:"expect_strategy"`
+}
+
+// loadScenario reads a file holding one plan request or an array of them.
+func loadScenario(path string) ([]scenarioStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario %s: %w", path, err)
+	}
+	var entries []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &entries)
+	} else {
+		entries = []json.RawMessage{trimmed}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("scenario %s has no requests", path)
+	}
+
+	steps := make([]scenarioStep, 0, len(entries))
+	for i, entry := range entries {
+		step, err := parseScenarioStep(entry)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s request %d: %w", path, i, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseScenarioStep(entry json.RawMessage) (scenarioStep, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry, &fields); err != nil {
+		return scenarioStep{}, err
+	}
+	var step scenarioStep
+	if err := json.Unmarshal(entry, &step.Expect); err != nil {
+		return scenarioStep{}, err
+	}
+	for key := range fields {
+		if strings.HasPrefix(key, "expect_") {
+			delete(fields, key)
+		}
+	}
+	if raw, ok := fields["request_id"]; ok {
+		_ = json.Unmarshal(raw, &step.RequestID)
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return scenarioStep{}, err
+	}
+	step.Body = body
+	return step, nil
+}
+
+// check returns the expectations resp does not meet.
+func (e scenarioExpect) check(resp api.PlanResponse) []string {
+	var failures []string
+	if e.MinActions != nil && len(resp.Actions) < *e.MinActions {
+		failures = append(failures, fmt.Sprintf("expected at least %d actions, got %d", *e.MinActions, len(resp.Actions)))
+	}
+	if e.MaxActions != nil && len(resp.Actions) > *e.MaxActions {
+		failures = append(failures, fmt.Sprintf("expected at most %d actions, got %d", *e.MaxActions, len(resp.Actions)))
+	}
+	if e.Strategy != "" && resp.Debug.ChosenStrategy != e.Strategy {
+		failures = append(failures, fmt.Sprintf("expected strategy %q, got %q", e.Strategy, resp.Debug.ChosenStrategy))
+	}
+	return failures
+}
+
+// runScenario posts each step to /v1/plan in order and prints the
+// responses; it reports how many steps failed or broke an expectation.
+func runScenario(client *http.Client, baseURL, token string, steps []scenarioStep, interval time.Duration) int {
+	failed := 0
+	for i, step := range steps {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		if failures := runScenarioStep(client, baseURL, token, i, step); len(failures) > 0 {
+			failed++
+			for _, failure := range failures {
+				logging.Errorf("scenario_step_failed index=%d request_id=%s reason=%q", i, step.RequestID, failure)
+			}
+		}
+	}
+	logging.Infof("scenario_done steps=%d failed=%d", len(steps), failed)
+	return failed
+}
+
+func runScenarioStep(client *http.Client, baseURL, token string, index int, step scenarioStep) []string {
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/plan", bytes.NewReader(step.Body))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return []string{fmt.Sprintf("request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return []string{fmt.Sprintf("read response: %v", err)}
+	}
+
+	fmt.Printf("=== step %d request_id=%s status: %s\n", index, step.RequestID, resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println(string(data))
+		return []string{fmt.Sprintf("status %d", resp.StatusCode)}
+	}
+	var plan api.PlanResponse
+	if err := json.Unmarshal(data, &plan); err != nil {
+		fmt.Println(string(data))
+		return []string{fmt.Sprintf("decode response: %v", err)}
+	}
+	pretty, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprintf("format response: %v", err)}
+	}
+	fmt.Println(string(pretty))
+	logging.Infof("scenario_step index=%d request_id=%s actions=%d strategy=%s", index, step.RequestID, len(plan.Actions), plan.Debug.ChosenStrategy)
+	return step.Expect.check(plan)
+}
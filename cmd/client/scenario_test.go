@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantSteps int
+		wantErr   string
+	}{
+		{name: "single request", content: `{"request_id":"a","expect_min_actions":1}`, wantSteps: 1},
+		{name: "array", content: ` [{"request_id":"a"},{"request_id":"b","expect_strategy":"silence"}]`, wantSteps: 2},
+		{name: "empty array", content: `[]`, wantErr: "no requests"},
+		{name: "not an object", content: `[1]`, wantErr: "request 0"},
+		{name: "invalid json", content: `{"request_id":`, wantErr: "scenario"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scenario.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("write scenario: %v", err)
+			}
+			steps, err := loadScenario(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadScenario() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(steps) != tt.wantSteps {
+				t.Fatalf("loadScenario() = %d steps, %v, want %d", len(steps), err, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestExampleScenariosLoad(t *testing.T) {
+	paths, err := filepath.Glob("../../DOCS/examples/scenarios/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no example scenarios: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := loadScenario(path); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(string(body), `"broken"`) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"request_id":"r","actions":[{"bot_id":"b","message":"hej"}],"debug":{"chosen_strategy":"heuristics"}}`)
+	}))
+	defer server.Close()
+
+	steps := []scenarioStep{}
+	for _, entry := range []string{
+		`{"request_id":"ok","expect_min_actions":1,"expect_strategy":"heuristics"}`,
+		`{"request_id":"too_few","expect_min_actions":2}`,
+		`{"request_id":"broken"}`,
+	} {
+		step, err := parseScenarioStep(json.RawMessage(entry))
+		if err != nil {
+			t.Fatalf("parseScenarioStep(%s) error: %v", entry, err)
+		}
+		steps = append(steps, step)
+	}
+
+	if failed := runScenario(server.Client(), server.URL, "secret", steps, 0); failed != 2 {
+		t.Fatalf("runScenario() failed = %d, want 2", failed)
+	}
+	if len(bodies) != 3 || strings.Contains(bodies[0], "expect_") || !strings.Contains(bodies[0], `"request_id":"ok"`) {
+		t.Fatalf("request bodies = %q, want expect_* keys stripped", bodies)
+	}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/logging"
+)
+
+const (
+	AsyncStatusPending = "pending"
+	AsyncStatusDone    = "done"
+
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+
+	asyncQueuePerWorker  = 16
+	callbackAttempts     = 3
+	callbackTimeout      = 5 * time.Second
+	callbackBaseBackoff  = 500 * time.Millisecond
+	defaultAsyncTTL      = 5 * time.Minute
+	planIDHeader         = "X-Plan-Id"
+	maxPlanIDGenerations = 8
+)
+
+var (
+	errAsyncQueueFull = errors.New("async plan queue is full")
+	errAsyncClosed    = errors.New("async planner is shutting down")
+)
+
+type PlanFunc func(ctx context.Context, req PlanRequest) PlanResponse
+
+// AsyncPlanner runs plans on a worker pool, delivers them to the request's
+// callback URL and keeps finished results for polling until they expire.
+type AsyncPlanner struct {
+	plan        PlanFunc
+	planTimeout time.Duration
+	ttl         time.Duration
+	client      *http.Client
+	backoff     time.Duration
+	now         func() time.Time
+
+	queue  chan asyncJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	results map[string]*asyncResult
+}
+
+type asyncJob struct {
+	planID string
+	req    AsyncPlanRequest
+	trace  logging.Trace
+}
+
+type asyncResult struct {
+	status AsyncPlanStatus
+	// expiresAt is zero while the plan is still running.
+	expiresAt time.Time
+}
+
+func NewAsyncPlanner(plan PlanFunc, workers int, ttl, planTimeout time.Duration) *AsyncPlanner {
+	if workers < 1 {
+		workers = 1
+	}
+	if ttl <= 0 {
+		ttl = defaultAsyncTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &AsyncPlanner{
+		plan:        plan,
+		planTimeout: planTimeout,
+		ttl:         ttl,
+		client:      &http.Client{Timeout: callbackTimeout},
+		backoff:     callbackBaseBackoff,
+		now:         time.Now,
+		queue:       make(chan asyncJob, workers*asyncQueuePerWorker),
+		ctx:         ctx,
+		cancel:      cancel,
+		results:     make(map[string]*asyncResult),
+	}
+	for i := 0; i < workers; i++ {
+		a.wg.Add(1)
+		go a.worker()
+	}
+	return a
+}
+
+// Submit queues the request and returns its plan ID without waiting for the
+// plan itself.
+func (a *AsyncPlanner) Submit(ctx context.Context, req AsyncPlanRequest) (AsyncPlanStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return AsyncPlanStatus{}, errAsyncClosed
+	}
+	a.pruneLocked(a.now())
+	planID, err := a.newPlanIDLocked()
+	if err != nil {
+		return AsyncPlanStatus{}, err
+	}
+	status := AsyncPlanStatus{PlanID: planID, Status: AsyncStatusPending}
+	if req.CallbackURL != "" {
+		status.CallbackStatus = CallbackPending
+	}
+	select {
+	case a.queue <- asyncJob{planID: planID, req: req, trace: logging.TraceFromContext(ctx)}:
+	default:
+		return AsyncPlanStatus{}, errAsyncQueueFull
+	}
+	a.results[planID] = &asyncResult{status: status}
+	return status, nil
+}
+
+func (a *AsyncPlanner) Result(planID string) (AsyncPlanStatus, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pruneLocked(a.now())
+	result, ok := a.results[planID]
+	if !ok {
+		return AsyncPlanStatus{}, false
+	}
+	return result.status, true
+}
+
+// Close stops accepting plans and waits for queued ones; when ctx expires the
+// remaining plans and callbacks are cancelled.
+func (a *AsyncPlanner) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		a.cancel()
+		return nil
+	case <-ctx.Done():
+		a.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// newPlanIDLocked never hands out an ID that is still stored, so a plan ID
+// always identifies exactly one request while its result can be polled.
+func (a *AsyncPlanner) newPlanIDLocked() (string, error) {
+	for i := 0; i < maxPlanIDGenerations; i++ {
+		planID := generateRequestID()
+		if _, exists := a.results[planID]; !exists {
+			return planID, nil
+		}
+	}
+	return "", errors.New("could not generate a unique plan id")
+}
+
+func (a *AsyncPlanner) pruneLocked(now time.Time) {
+	for planID, result := range a.results {
+		if !result.expiresAt.IsZero() && now.After(result.expiresAt) {
+			delete(a.results, planID)
+		}
+	}
+}
+
+func (a *AsyncPlanner) worker() {
+	defer a.wg.Done()
+	for job := range a.queue {
+		a.run(job)
+	}
+}
+
+func (a *AsyncPlanner) run(job asyncJob) {
+	ctx := logging.WithTrace(a.ctx, job.trace)
+	planCtx := ctx
+	if a.planTimeout > 0 {
+		var cancel context.CancelFunc
+		planCtx, cancel = context.WithTimeout(ctx, a.planTimeout)
+		defer cancel()
+	}
+	response := a.plan(planCtx, job.req.PlanRequest).ForSchemaVersion(job.req.SchemaVersion)
+	a.update(job.planID, func(status *AsyncPlanStatus) {
+		status.Status = AsyncStatusDone
+		status.Response = &response
+	})
+	logging.Ctx(ctx).Infof("request_id=%s transaction_id=%s plan_async_done plan_id=%s actions=%d", job.req.RequestID, job.req.RequestID, job.planID, len(response.Actions))
+	if job.req.CallbackURL == "" {
+		return
+	}
+	callbackStatus := CallbackDelivered
+	if err := a.deliver(ctx, job, response); err != nil {
+		callbackStatus = CallbackFailed
+		logging.Ctx(ctx).Warnf("request_id=%s transaction_id=%s plan_async_callback_failed plan_id=%s error=%v", job.req.RequestID, job.req.RequestID, job.planID, err)
+	}
+	a.update(job.planID, func(status *AsyncPlanStatus) {
+		status.CallbackStatus = callbackStatus
+	})
+}
+
+func (a *AsyncPlanner) update(planID string, fn func(status *AsyncPlanStatus)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result, ok := a.results[planID]
+	if !ok {
+		return
+	}
+	fn(&result.status)
+	result.expiresAt = a.now().Add(a.ttl)
+}
+
+func (a *AsyncPlanner) deliver(ctx context.Context, job asyncJob, response PlanResponse) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	backoff := a.backoff
+	var lastErr error
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if lastErr = a.post(ctx, job, body); lastErr == nil {
+			return nil
+		}
+		logging.Ctx(ctx).Infof("request_id=%s transaction_id=%s plan_async_callback_retry plan_id=%s attempt=%d error=%v", job.req.RequestID, job.req.RequestID, job.planID, attempt, lastErr)
+		if attempt == callbackAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	return lastErr
+}
+
+func (a *AsyncPlanner) post(ctx context.Context, job asyncJob, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.req.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(planIDHeader, job.planID)
+	req.Header.Set(requestIDHeader, job.req.RequestID)
+	if traceparent := job.trace.Traceparent(); traceparent != "" {
+		req.Header.Set(logging.TraceparentHeader, traceparent)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("callback status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func echoPlan(ctx context.Context, req PlanRequest) PlanResponse {
+	return PlanResponse{RequestID: req.RequestID}
+}
+
+func newTestAsyncPlanner(t *testing.T, plan PlanFunc, workers int, ttl time.Duration) *AsyncPlanner {
+	t.Helper()
+	async := NewAsyncPlanner(plan, workers, ttl, time.Second)
+	async.backoff = time.Millisecond
+	t.Cleanup(func() { async.Close(context.Background()) })
+	return async
+}
+
+func waitForResult(t *testing.T, async *AsyncPlanner, planID string, done func(AsyncPlanStatus) bool) AsyncPlanStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := async.Result(planID); ok && done(status) {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	status, _ := async.Result(planID)
+	t.Fatalf("plan %s did not finish, last status %+v", planID, status)
+	return status
+}
+
+func TestAsyncPlannerDeliversCallbackWithRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		wantCallback string
+		wantAttempts int32
+	}{
+		{name: "first attempt", wantCallback: CallbackDelivered, wantAttempts: 1},
+		{name: "after retries", failures: 2, wantCallback: CallbackDelivered, wantAttempts: 3},
+		{name: "gives up", failures: callbackAttempts, wantCallback: CallbackFailed, wantAttempts: callbackAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			var mu sync.Mutex
+			var received PlanResponse
+			var planIDHeaderValue string
+			callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				planIDHeaderValue = r.Header.Get(planIDHeader)
+				_ = json.NewDecoder(r.Body).Decode(&received)
+			}))
+			defer callback.Close()
+
+			async := newTestAsyncPlanner(t, echoPlan, 1, time.Minute)
+			accepted, err := async.Submit(context.Background(), AsyncPlanRequest{PlanRequest: PlanRequest{RequestID: "req-async"}, CallbackURL: callback.URL})
+			if err != nil {
+				t.Fatalf("Submit() error: %v", err)
+			}
+			if accepted.Status != AsyncStatusPending || accepted.CallbackStatus != CallbackPending {
+				t.Fatalf("unexpected accepted status %+v", accepted)
+			}
+
+			status := waitForResult(t, async, accepted.PlanID, func(s AsyncPlanStatus) bool { return s.CallbackStatus != CallbackPending })
+			if status.Status != AsyncStatusDone || status.Response == nil || status.Response.RequestID != "req-async" {
+				t.Fatalf("unexpected result %+v", status)
+			}
+			if status.CallbackStatus != tt.wantCallback || attempts.Load() != tt.wantAttempts {
+				t.Fatalf("callback = %s after %d attempts, want %s after %d", status.CallbackStatus, attempts.Load(), tt.wantCallback, tt.wantAttempts)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantCallback == CallbackDelivered && (received.RequestID != "req-async" || planIDHeaderValue != accepted.PlanID) {
+				t.Fatalf("callback got response %+v with plan id %q", received, planIDHeaderValue)
+			}
+		})
+	}
+}
+
+func TestAsyncPlannerIssuesUniquePlanIDs(t *testing.T) {
+	release := make(chan struct{})
+	blocked := func(ctx context.Context, req PlanRequest) PlanResponse {
+		<-release
+		return PlanResponse{}
+	}
+	async := newTestAsyncPlanner(t, blocked, 4, time.Minute)
+	defer close(release)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 4*asyncQueuePerWorker; i++ {
+		status, err := async.Submit(context.Background(), AsyncPlanRequest{})
+		if err != nil {
+			t.Fatalf("Submit() #%d error: %v", i+1, err)
+		}
+		if _, dup := seen[status.PlanID]; dup {
+			t.Fatalf("duplicate plan id %s", status.PlanID)
+		}
+		seen[status.PlanID] = struct{}{}
+	}
+}
+
+func TestAsyncPlannerRejectsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	blocked := func(ctx context.Context, req PlanRequest) PlanResponse {
+		<-release
+		return PlanResponse{}
+	}
+	async := newTestAsyncPlanner(t, blocked, 1, time.Minute)
+	defer close(release)
+
+	var err error
+	for i := 0; i < asyncQueuePerWorker+2 && err == nil; i++ {
+		_, err = async.Submit(context.Background(), AsyncPlanRequest{})
+	}
+	if err != errAsyncQueueFull {
+		t.Fatalf("expected queue full error, got %v", err)
+	}
+}
+
+func TestAsyncPlannerExpiresResults(t *testing.T) {
+	now := time.Unix(1712345000, 0)
+	var mu sync.Mutex
+	async := newTestAsyncPlanner(t, echoPlan, 1, time.Minute)
+	async.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	accepted, err := async.Submit(context.Background(), AsyncPlanRequest{PlanRequest: PlanRequest{RequestID: "req-ttl"}})
+	if err != nil {
+		t.Fatalf("Submit() error: %v", err)
+	}
+	waitForResult(t, async, accepted.PlanID, func(s AsyncPlanStatus) bool { return s.Status == AsyncStatusDone })
+
+	mu.Lock()
+	now = now.Add(time.Minute + time.Second)
+	mu.Unlock()
+	if status, ok := async.Result(accepted.PlanID); ok {
+		t.Fatalf("expected result to expire, got %+v", status)
+	}
+}
+
+func TestAsyncPlannerRejectsAfterClose(t *testing.T) {
+	async := NewAsyncPlanner(echoPlan, 1, time.Minute, time.Second)
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if _, err := async.Submit(context.Background(), AsyncPlanRequest{}); err != errAsyncClosed {
+		t.Fatalf("expected closed error, got %v", err)
+	}
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatalf("second Close() error: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"aichatplayers/internal/logging"
+)
+
+const (
+	apiKeyHeader    = "X-Api-Key"
+	protectedPrefix = "/v1/"
+)
+
+// RequireAPIToken rejects /v1/ requests without a configured token in
+// "Authorization: Bearer" or X-Api-Key. With no tokens it is a passthrough.
+func RequireAPIToken(tokens []string, next http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return next
+	}
+	digests := make([][sha256.Size]byte, 0, len(tokens))
+	for _, token := range tokens {
+		digests = append(digests, sha256.Sum256([]byte(token)))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, protectedPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := requestToken(r)
+		if token == "" || !matchToken(digests, token) {
+			reason := "invalid_token"
+			if token == "" {
+				reason = "missing_token"
+			}
+			reqID := RequestIDFromContext(r.Context())
+			logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s auth_rejected path=%s reason=%s remote_addr=%s", reqID, reqID, r.URL.Path, reason, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireClientCert rejects /v1/ requests that did not present a verified
+// TLS client certificate, leaving probes and /metrics reachable. With
+// required false it is a passthrough.
+func RequireClientCert(required bool, next http.Handler) http.Handler {
+	if !required {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, protectedPrefix) || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reqID := RequestIDFromContext(r.Context())
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s auth_rejected path=%s reason=missing_client_cert remote_addr=%s", reqID, reqID, r.URL.Path, r.RemoteAddr)
+		respondError(w, http.StatusForbidden, "client_certificate_required")
+	})
+}
+
+func requestToken(r *http.Request) string {
+	if value := strings.TrimSpace(r.Header.Get("Authorization")); value != "" {
+		scheme, token, found := strings.Cut(value, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(apiKeyHeader))
+}
+
+// matchToken compares SHA-256 digests so the comparison time depends neither
+// on the token length nor on which configured token matched.
+func matchToken(digests [][sha256.Size]byte, token string) bool {
+	candidate := sha256.Sum256([]byte(token))
+	matched := 0
+	for i := range digests {
+		matched |= subtle.ConstantTimeCompare(digests[i][:], candidate[:])
+	}
+	return matched == 1
+}
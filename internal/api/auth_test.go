@@ -0,0 +1,92 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireAPIToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	tests := []struct {
+		name       string
+		tokens     []string
+		path       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "no tokens configured", path: "/v1/plan", wantStatus: http.StatusOK},
+		{name: "healthz stays open", tokens: []string{"secret"}, path: "/healthz", wantStatus: http.StatusOK},
+		{name: "missing token", tokens: []string{"secret"}, path: "/v1/plan", wantStatus: http.StatusUnauthorized},
+		{name: "bearer token", tokens: []string{"secret"}, path: "/v1/plan", headers: map[string]string{"Authorization": "Bearer secret"}, wantStatus: http.StatusOK},
+		{name: "lowercase scheme", tokens: []string{"secret"}, path: "/v1/plan", headers: map[string]string{"Authorization": "bearer secret"}, wantStatus: http.StatusOK},
+		{name: "api key header", tokens: []string{"secret"}, path: "/v1/bots/register", headers: map[string]string{"X-Api-Key": "secret"}, wantStatus: http.StatusOK},
+		{name: "second configured token", tokens: []string{"first", "second"}, path: "/v1/plan", headers: map[string]string{"X-Api-Key": "second"}, wantStatus: http.StatusOK},
+		{name: "wrong token", tokens: []string{"secret"}, path: "/v1/plan", headers: map[string]string{"Authorization": "Bearer secrets"}, wantStatus: http.StatusUnauthorized},
+		{name: "basic scheme", tokens: []string{"secret"}, path: "/v1/plan", headers: map[string]string{"Authorization": "Basic secret"}, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			RequireAPIToken(tt.tokens, ok).ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && strings.TrimSpace(recorder.Body.String()) != `{"error":"unauthorized"}` {
+				t.Fatalf("unexpected body %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestRedactHeadersHidesTokens(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	headers.Set("X-Api-Key", "secret")
+	headers.Set("Content-Type", "application/json")
+
+	logged := fmt.Sprintf("%v", redactHeaders(headers))
+	if strings.Contains(logged, "secret") || !strings.Contains(logged, "application/json") {
+		t.Fatalf("unexpected redacted headers %s", logged)
+	}
+	if headers.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("original headers were modified")
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	tests := []struct {
+		name       string
+		required   bool
+		path       string
+		tls        *tls.ConnectionState
+		wantStatus int
+	}{
+		{name: "not required", path: "/v1/plan", wantStatus: http.StatusOK},
+		{name: "readyz stays open", required: true, path: "/readyz", tls: &tls.ConnectionState{}, wantStatus: http.StatusOK},
+		{name: "plain http", required: true, path: "/v1/plan", wantStatus: http.StatusForbidden},
+		{name: "no client certificate", required: true, path: "/v1/plan", tls: &tls.ConnectionState{}, wantStatus: http.StatusForbidden},
+		{name: "verified client certificate", required: true, path: "/v1/plan", tls: verified, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			req.TLS = tt.tls
+			recorder := httptest.NewRecorder()
+			RequireClientCert(tt.required, ok).ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
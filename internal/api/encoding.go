@@ -0,0 +1,132 @@
+package api
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+
+	"aichatplayers/internal/logging"
+)
+
+// DecompressBody unwraps request bodies sent with Content-Encoding: gzip.
+// It runs before LimitBodySize, so the body limit applies to the
+// decompressed stream and a gzip bomb is cut off at the limit.
+func DecompressBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		transactionID := RequestIDFromContext(r.Context())
+		if encoding != "gzip" {
+			logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s unsupported_content_encoding path=%s content_encoding=%q", transactionID, transactionID, r.URL.Path, encoding)
+			respondError(w, http.StatusUnsupportedMediaType, "unsupported_content_encoding")
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid_gzip_body path=%s error=%v", transactionID, transactionID, r.URL.Path, err)
+			respondError(w, http.StatusBadRequest, "invalid_body")
+			return
+		}
+		defer reader.Close()
+		r.Body = reader
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireJSON answers 415 unsupported_media_type when a request body is
+// declared as anything but JSON. Bodies without a Content-Type are still
+// accepted, as older plugins do not send one.
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" || r.Body == nil || r.Body == http.NoBody || isJSONMediaType(contentType) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		transactionID := RequestIDFromContext(r.Context())
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s unsupported_media_type path=%s content_type=%q", transactionID, transactionID, r.URL.Path, contentType)
+		respondError(w, http.StatusUnsupportedMediaType, "unsupported_media_type")
+	})
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// CompressResponse gzips responses for clients that send
+// Accept-Encoding: gzip.
+func CompressResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writer := &gzipResponseWriter{ResponseWriter: w}
+		defer writer.close()
+		next.ServeHTTP(writer, r)
+	})
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipResponseWriter decides at WriteHeader whether the response gets a
+// body; responses that cannot have one are passed through unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	header := g.ResponseWriter.Header()
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz == nil {
+		return g.ResponseWriter.Write(data)
+	}
+	return g.gz.Write(data)
+}
+
+func (g *gzipResponseWriter) close() {
+	if g.gz == nil {
+		return
+	}
+	if err := g.gz.Close(); err != nil {
+		logging.Warnf("gzip_response_close_failed error=%v", err)
+	}
+}
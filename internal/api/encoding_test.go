@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRequestBodyNegotiation(t *testing.T) {
+	const limit = 64 << 10
+	body := []byte(`{"server":{"server_id":"s"}}`)
+	bomb := gzipBytes(t, bytes.Repeat([]byte("a"), 10<<20))
+	if len(bomb) >= limit {
+		t.Fatalf("bomb is %d bytes compressed, want it under the %d byte limit", len(bomb), limit)
+	}
+
+	tests := []struct {
+		name            string
+		body            []byte
+		contentType     string
+		contentEncoding string
+		wantStatus      int
+		wantError       string
+	}{
+		{name: "json", body: body, contentType: "application/json", wantStatus: http.StatusOK},
+		{name: "json with charset", body: body, contentType: "application/json; charset=utf-8", wantStatus: http.StatusOK},
+		{name: "json suffix type", body: body, contentType: "application/vnd.aichat+json", wantStatus: http.StatusOK},
+		{name: "no content type", body: body, wantStatus: http.StatusOK},
+		{name: "text plain", body: body, contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType, wantError: "unsupported_media_type"},
+		{name: "form", body: body, contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType, wantError: "unsupported_media_type"},
+		{name: "malformed content type", body: body, contentType: "application/json; =", wantStatus: http.StatusUnsupportedMediaType, wantError: "unsupported_media_type"},
+		{name: "gzip", body: gzipBytes(t, body), contentType: "application/json", contentEncoding: "gzip", wantStatus: http.StatusOK},
+		{name: "gzip bomb", body: bomb, contentType: "application/json", contentEncoding: "gzip", wantStatus: http.StatusRequestEntityTooLarge, wantError: "payload_too_large"},
+		{name: "corrupt gzip", body: []byte("not gzip"), contentType: "application/json", contentEncoding: "gzip", wantStatus: http.StatusBadRequest, wantError: "invalid_body"},
+		{name: "truncated gzip", body: gzipBytes(t, body)[:20], contentType: "application/json", contentEncoding: "gzip", wantStatus: http.StatusBadRequest, wantError: "invalid_body"},
+		{name: "unsupported encoding", body: body, contentType: "application/json", contentEncoding: "br", wantStatus: http.StatusUnsupportedMediaType, wantError: "unsupported_content_encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []byte
+			handler := DecompressBody(LimitBodySize(limit, RequireJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = io.ReadAll(r.Body)
+				respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+			}))))
+			req := httptest.NewRequest("POST", "/v1/plan", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				if !bytes.Equal(seen, body) {
+					t.Fatalf("handler saw %q, want %q", seen, body)
+				}
+				return
+			}
+			var response map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response["error"] != tt.wantError {
+				t.Fatalf("body = %s, want error %s (err %v)", recorder.Body.String(), tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	payload := map[string]string{"message": strings.Repeat("siema ", 100)}
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		status         int
+		wantGzip       bool
+	}{
+		{name: "gzip accepted", acceptEncoding: "gzip", status: http.StatusOK, wantGzip: true},
+		{name: "gzip among others", acceptEncoding: "br;q=1.0, gzip;q=0.8", status: http.StatusOK, wantGzip: true},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0", status: http.StatusOK},
+		{name: "not accepted", status: http.StatusOK},
+		{name: "error responses too", acceptEncoding: "gzip", status: http.StatusBadRequest, wantGzip: true},
+		{name: "no content", acceptEncoding: "gzip", status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusNoContent {
+					w.WriteHeader(tt.status)
+					return
+				}
+				respondJSON(w, tt.status, payload)
+			}))
+			req := httptest.NewRequest("GET", "/healthz", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if vary := recorder.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Fatalf("Vary = %q, want Accept-Encoding", vary)
+			}
+			gzipped := recorder.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %t", recorder.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if tt.status == http.StatusNoContent {
+				if recorder.Body.Len() != 0 {
+					t.Fatalf("204 response has a body: %q", recorder.Body.String())
+				}
+				return
+			}
+			body := recorder.Body.Bytes()
+			if gzipped {
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("gunzip: %v", err)
+				}
+				if recorder.Body.Len() >= len(body) {
+					t.Fatalf("compressed body is %d bytes, plain %d", recorder.Body.Len(), len(body))
+				}
+			}
+			var decoded map[string]string
+			if err := json.Unmarshal(body, &decoded); err != nil || decoded["message"] != payload["message"] {
+				t.Fatalf("body = %q (err %v)", body, err)
+			}
+		})
+	}
+}
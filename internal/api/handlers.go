@@ -1,30 +1,177 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/models"
 	"aichatplayers/internal/planner"
+	"aichatplayers/internal/schema"
+	"aichatplayers/internal/version"
 )
 
+const defaultBatchMaxSize = 10
+
 type Handler struct {
-	Planner *planner.Planner
+	Planner             *planner.Planner
+	Elastic             ElasticStatusProvider
+	PlanLimiter         *RateLimiter
+	Async               *AsyncPlanner
+	BatchMaxSize        int
+	StrictValidation    bool
+	ReadinessRequireLLM bool
+	Reloader            ConfigReloader
+	ConfigDumper        ConfigDumper
+	// Redaction applies to the plan and engagement payloads logged at debug
+	// level, like it does in the request logging middleware.
+	Redaction LogRedaction
+	// StartedAt is reported as uptime_s on /healthz when set.
+	StartedAt time.Time
+
+	ready    atomic.Bool
+	draining atomic.Bool
+}
+
+// MarkReady opens the handler for traffic once its dependencies are set;
+// until then planning endpoints answer 503 not_ready.
+func (h *Handler) MarkReady() {
+	h.ready.Store(true)
+}
+
+// checkReady answers 503 not_ready before MarkReady or when the planner is
+// missing, instead of panicking on a nil dependency.
+func (h *Handler) checkReady(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case !h.ready.Load():
+		respondNotReady(w, r, "not_marked_ready")
+	case h.Planner == nil:
+		respondNotReady(w, r, "planner_missing")
+	default:
+		return true
+	}
+	return false
+}
+
+func (h *Handler) checkAsyncReady(w http.ResponseWriter, r *http.Request) bool {
+	if !h.checkReady(w, r) {
+		return false
+	}
+	if h.Async == nil {
+		respondNotReady(w, r, "async_missing")
+		return false
+	}
+	return true
+}
+
+func respondNotReady(w http.ResponseWriter, r *http.Request, reason string) {
+	transactionID := RequestIDFromContext(r.Context())
+	logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s handler_not_ready path=%s reason=%s", transactionID, transactionID, r.URL.Path, reason)
+	respondError(w, http.StatusServiceUnavailable, "not_ready")
+}
+
+// StartDraining turns /readyz unready so load balancers stop routing new
+// plans while in-flight ones finish.
+func (h *Handler) StartDraining() {
+	h.draining.Store(true)
+}
+
+type ElasticStatusProvider interface {
+	Status() logging.ElasticStatus
+}
+
+// ConfigReloader re-reads the configuration and applies what it can live.
+type ConfigReloader interface {
+	Reload() (ConfigReloadResponse, error)
+}
+
+// ConfigDumper reports the configuration the service runs with.
+type ConfigDumper interface {
+	DumpConfig() ConfigResponse
 }
 
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 	transactionID := RequestIDFromContext(r.Context())
-	logging.Infof("request_id=%s transaction_id=%s healthz", transactionID, transactionID)
-	respondJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s healthz", transactionID, transactionID)
+	build := version.Get()
+	response := HealthResponse{Status: "ok", Version: build.Version, Commit: build.Commit, BuildDate: build.BuildDate}
+	if h.Planner != nil {
+		response.LLMState = h.Planner.LLMState()
+	}
+	if !h.StartedAt.IsZero() {
+		response.UptimeS = int64(time.Since(h.StartedAt).Seconds())
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.ready.Load() || h.Planner == nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s readyz_not_ready reason=dependencies_missing", transactionID, transactionID)
+		respondJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Status: "not_ready"})
+		return
+	}
+	response := ReadinessResponse{
+		Status: "ready",
+		Components: ReadinessComponents{
+			LLM:     h.Planner.LLMStatus(),
+			Elastic: h.elasticReadiness(),
+			Planner: h.Planner.RegistryStatus(),
+		},
+	}
+	status := http.StatusOK
+	if h.draining.Load() {
+		response.Status = "draining"
+		status = http.StatusServiceUnavailable
+	} else if h.ReadinessRequireLLM && !response.Components.LLM.Available {
+		response.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s readyz_not_ready llm_state=%s", transactionID, transactionID, response.Components.LLM.State)
+	}
+	respondJSON(w, status, response)
+}
+
+func (h *Handler) elasticReadiness() ElasticReadiness {
+	if h.Elastic == nil {
+		return ElasticReadiness{}
+	}
+	status := h.Elastic.Status()
+	readiness := ElasticReadiness{
+		Enabled:    true,
+		QueueDepth: status.QueueDepth,
+		LastError:  status.LastError,
+		Dropped:    status.Dropped,
+		Spilled:    status.Spilled,
+		Replayed:   status.Replayed,
+	}
+	if !status.LastErrorAt.IsZero() {
+		readiness.LastErrorMS = status.LastErrorAt.UnixMilli()
+	}
+	return readiness
 }
 
 func (h *Handler) Plan(w http.ResponseWriter, r *http.Request) {
 	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	if !h.validateStrict(w, r, "plan") {
+		return
+	}
 	var req PlanRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&req); err != nil {
-		logging.Warnf("request_id=%s transaction_id=%s invalid plan request: %v", transactionID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid plan request: %v", transactionID, transactionID, err)
 		respondError(w, http.StatusBadRequest, "invalid_json")
 		return
 	}
@@ -37,29 +184,251 @@ func (h *Handler) Plan(w http.ResponseWriter, r *http.Request) {
 	if transactionID == "" {
 		transactionID = req.RequestID
 	}
+	if !h.admitSchemaVersion(w, r, &req.SchemaVersion, req.RequestID, transactionID) {
+		return
+	}
+	if dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run")); err == nil && dryRun {
+		req.DryRun = true
+	}
+
+	if !h.admitPlan(w, r, req, req.Validate(), transactionID) {
+		return
+	}
 
 	if payload, err := json.Marshal(req); err == nil {
-		logging.Debugf("request_id=%s transaction_id=%s plan_request=%s", req.RequestID, transactionID, string(payload))
+		logging.Ctx(r.Context()).Debugf("request_id=%s transaction_id=%s plan_request=%s", req.RequestID, transactionID, h.Redaction.body(payload))
 	} else {
-		logging.Warnf("request_id=%s transaction_id=%s failed to marshal plan request: %v", req.RequestID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s failed to marshal plan request: %v", req.RequestID, transactionID, err)
 	}
 
-	response := h.Planner.Plan(req)
+	response := h.Planner.Plan(r.Context(), req).ForSchemaVersion(req.SchemaVersion)
 	if payload, err := json.Marshal(response); err == nil {
-		logging.Debugf("request_id=%s transaction_id=%s plan_response=%s", req.RequestID, transactionID, string(payload))
+		logging.Ctx(r.Context()).Debugf("request_id=%s transaction_id=%s plan_response=%s", req.RequestID, transactionID, h.Redaction.body(payload))
 	} else {
-		logging.Warnf("request_id=%s transaction_id=%s failed to marshal plan response: %v", req.RequestID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s failed to marshal plan response: %v", req.RequestID, transactionID, err)
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) PlanAsync(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkAsyncReady(w, r) {
+		return
+	}
+	if !h.validateStrict(w, r, "plan_async") {
+		return
+	}
+	var req AsyncPlanRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid async plan request: %v", transactionID, transactionID, err)
+		respondError(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	if req.RequestID == "" {
+		req.RequestID = transactionID
+	}
+	if transactionID == "" {
+		transactionID = req.RequestID
+	}
+	if !h.admitSchemaVersion(w, r, &req.SchemaVersion, req.RequestID, transactionID) {
+		return
+	}
+	if !h.admitPlan(w, r, req.PlanRequest, req.Validate(), transactionID) {
+		return
+	}
+
+	status, err := h.Async.Submit(r.Context(), req)
+	if err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_async_rejected error=%v", req.RequestID, transactionID, err)
+		respondError(w, http.StatusServiceUnavailable, "async_unavailable")
+		return
+	}
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s plan_async_accepted plan_id=%s callback=%t", req.RequestID, transactionID, status.PlanID, req.CallbackURL != "")
+	respondJSON(w, http.StatusAccepted, status)
+}
+
+func (h *Handler) PlanResult(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkAsyncReady(w, r) {
+		return
+	}
+	planID := strings.TrimPrefix(r.URL.Path, "/v1/plan/")
+	status, ok := h.Async.Result(planID)
+	if !ok {
+		logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s plan_async_unknown plan_id=%s", transactionID, transactionID, planID)
+		respondError(w, http.StatusNotFound, "unknown_plan")
+		return
+	}
+	respondJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) PlanBatch(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	var batch BatchPlanRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&batch); err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid batch plan request: %v", transactionID, transactionID, err)
+		respondError(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	maxSize := h.BatchMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultBatchMaxSize
+	}
+	if len(batch.Requests) > maxSize {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_batch_too_large size=%d max=%d", transactionID, transactionID, len(batch.Requests), maxSize)
+		respondError(w, http.StatusRequestEntityTooLarge, "batch_too_large")
+		return
+	}
+
+	// Entries are planned concurrently; LLM calls still go through the one
+	// shared generator and its concurrency limit.
+	responses := make([]BatchPlanEntry, len(batch.Requests))
+	var wg sync.WaitGroup
+	for i, raw := range batch.Requests {
+		wg.Add(1)
+		go func(i int, raw json.RawMessage) {
+			defer wg.Done()
+			responses[i] = h.planBatchEntry(r, raw, fmt.Sprintf("%s-%d", transactionID, i))
+		}(i, raw)
+	}
+	wg.Wait()
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s plan_batch_done size=%d", transactionID, transactionID, len(responses))
+	respondJSON(w, http.StatusOK, BatchPlanResponse{Responses: responses})
+}
+
+func (h *Handler) planBatchEntry(r *http.Request, raw json.RawMessage, entryID string) BatchPlanEntry {
+	if h.StrictValidation && json.Valid(raw) {
+		s, _ := schema.Lookup("plan")
+		if violations := schema.Validate(s, raw); len(violations) > 0 {
+			logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s strict_validation_failed schema=plan violations=%d first_path=%s first_rule=%s", entryID, entryID, len(violations), violations[0].Path, violations[0].Rule)
+			return BatchPlanEntry{Error: "validation_failed", Details: violations}
+		}
+	}
+	var req PlanRequest
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid plan request: %v", entryID, entryID, err)
+		return BatchPlanEntry{Error: "invalid_json"}
+	}
+	if req.RequestID == "" {
+		req.RequestID = entryID
+	}
+	if !models.SchemaVersionSupported(req.SchemaVersion) {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s unsupported_schema_version schema_version=%d max_schema_version=%d", req.RequestID, entryID, req.SchemaVersion, models.MaxSchemaVersion)
+		return BatchPlanEntry{Error: "unsupported_schema_version", MaxSchemaVersion: models.MaxSchemaVersion}
+	}
+	req.SchemaVersion = models.EffectiveSchemaVersion(req.SchemaVersion)
+	metrics.SchemaVersions.Inc(strconv.Itoa(req.SchemaVersion))
+	if allowed, retryAfter := h.PlanLimiter.Allow(rateLimitKey(req.Server.ServerID, r.RemoteAddr)); !allowed {
+		metrics.PlanRateLimited.Inc()
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_rate_limited server_id=%s remote_addr=%s retry_after_ms=%d rate_limited_total=%d", req.RequestID, entryID, req.Server.ServerID, r.RemoteAddr, retryAfter.Milliseconds(), metrics.PlanRateLimited.Value())
+		return BatchPlanEntry{Error: "rate_limited", RetryAfterMS: max(retryAfter.Milliseconds(), 1)}
+	}
+	if violations := req.Validate(); len(violations) > 0 {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_validation_failed violations=%d first_path=%s first_rule=%s", req.RequestID, entryID, len(violations), violations[0].Path, violations[0].Rule)
+		return BatchPlanEntry{Error: "validation_failed", Details: violations}
+	}
+	response := h.Planner.Plan(r.Context(), req).ForSchemaVersion(req.SchemaVersion)
+	return BatchPlanEntry{PlanResponse: &response}
+}
+
+func (h *Handler) ReloadTopics(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	counts, err := h.Planner.ReloadTopics()
+	if err != nil {
+		logging.Ctx(r.Context()).Errorf("request_id=%s transaction_id=%s topics_reload_failed error=%v", transactionID, transactionID, err)
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "topics_reload_failed", "message": err.Error()})
+		return
+	}
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s topics_reloaded topics=%d", transactionID, transactionID, len(counts))
+	respondJSON(w, http.StatusOK, TopicsReloadResponse{Status: "reloaded", Topics: counts})
+}
+
+func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if h.Reloader == nil {
+		respondJSON(w, http.StatusNotImplemented, map[string]string{"error": "config_reload_unavailable"})
+		return
+	}
+	response, err := h.Reloader.Reload()
+	if err != nil {
+		logging.Ctx(r.Context()).Errorf("request_id=%s transaction_id=%s config_reload_failed error=%v", transactionID, transactionID, err)
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "config_reload_failed", "message": err.Error()})
+		return
 	}
 	respondJSON(w, http.StatusOK, response)
 }
 
+func (h *Handler) DumpConfig(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if h.ConfigDumper == nil {
+		respondJSON(w, http.StatusNotImplemented, map[string]string{"error": "config_dump_unavailable"})
+		return
+	}
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s config_dumped remote_addr=%s", transactionID, transactionID, r.RemoteAddr)
+	respondJSON(w, http.StatusOK, h.ConfigDumper.DumpConfig())
+}
+
+// admitSchemaVersion answers 400 for a schema_version newer than this build
+// speaks. Otherwise it fills in the default version and counts it.
+func (h *Handler) admitSchemaVersion(w http.ResponseWriter, r *http.Request, schemaVersion *int, requestID, transactionID string) bool {
+	if !models.SchemaVersionSupported(*schemaVersion) {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s unsupported_schema_version schema_version=%d max_schema_version=%d", requestID, transactionID, *schemaVersion, models.MaxSchemaVersion)
+		respondJSON(w, http.StatusBadRequest, UnsupportedSchemaVersionResponse{Error: "unsupported_schema_version", SchemaVersion: *schemaVersion, MaxSchemaVersion: models.MaxSchemaVersion})
+		return false
+	}
+	*schemaVersion = models.EffectiveSchemaVersion(*schemaVersion)
+	metrics.SchemaVersions.Inc(strconv.Itoa(*schemaVersion))
+	return true
+}
+
+// admitPlan applies the per-server rate limit and the request validation
+// shared by the synchronous and asynchronous plan endpoints.
+func (h *Handler) admitPlan(w http.ResponseWriter, r *http.Request, req PlanRequest, violations []ValidationViolation, transactionID string) bool {
+	if allowed, retryAfter := h.PlanLimiter.Allow(rateLimitKey(req.Server.ServerID, r.RemoteAddr)); !allowed {
+		metrics.PlanRateLimited.Inc()
+		retryAfterMS := retryAfter.Milliseconds()
+		if retryAfterMS < 1 {
+			retryAfterMS = 1
+		}
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_rate_limited server_id=%s remote_addr=%s retry_after_ms=%d rate_limited_total=%d", req.RequestID, transactionID, req.Server.ServerID, r.RemoteAddr, retryAfterMS, metrics.PlanRateLimited.Value())
+		w.Header().Set("Retry-After", strconv.FormatInt((retryAfterMS+999)/1000, 10))
+		respondJSON(w, http.StatusTooManyRequests, RateLimitedResponse{Error: "rate_limited", RetryAfterMS: retryAfterMS})
+		return false
+	}
+
+	if len(violations) > 0 {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s plan_validation_failed violations=%d first_path=%s first_rule=%s", req.RequestID, transactionID, len(violations), violations[0].Path, violations[0].Rule)
+		respondJSON(w, http.StatusBadRequest, ValidationFailedResponse{Error: "validation_failed", Details: violations})
+		return false
+	}
+	return true
+}
+
 func (h *Handler) Engagement(w http.ResponseWriter, r *http.Request) {
 	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	if !h.validateStrict(w, r, "engagement") {
+		return
+	}
 	var req EngagementRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&req); err != nil {
-		logging.Warnf("request_id=%s transaction_id=%s invalid engagement request: %v", transactionID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid engagement request: %v", transactionID, transactionID, err)
 		respondError(w, http.StatusBadRequest, "invalid_json")
 		return
 	}
@@ -72,46 +441,133 @@ func (h *Handler) Engagement(w http.ResponseWriter, r *http.Request) {
 	if transactionID == "" {
 		transactionID = req.RequestID
 	}
+	if !h.admitSchemaVersion(w, r, &req.SchemaVersion, req.RequestID, transactionID) {
+		return
+	}
 
 	if payload, err := json.Marshal(req); err == nil {
-		logging.Debugf("request_id=%s transaction_id=%s engagement_request=%s", req.RequestID, transactionID, string(payload))
+		logging.Ctx(r.Context()).Debugf("request_id=%s transaction_id=%s engagement_request=%s", req.RequestID, transactionID, h.Redaction.body(payload))
 	} else {
-		logging.Warnf("request_id=%s transaction_id=%s failed to marshal engagement request: %v", req.RequestID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s failed to marshal engagement request: %v", req.RequestID, transactionID, err)
 	}
 
-	response := h.Planner.Plan(PlanRequest{
-		RequestID: req.RequestID,
-		Server:    req.Server,
-		Tick:      req.Tick,
-		TimeMS:    req.TimeMS,
-		Bots:      req.Bots,
-		Chat:      req.Chat,
-		Settings:  req.Settings,
-	})
+	response := h.Planner.Engage(r.Context(), req).ForSchemaVersion(req.SchemaVersion)
 	if payload, err := json.Marshal(response); err == nil {
-		logging.Debugf("request_id=%s transaction_id=%s engagement_response=%s", req.RequestID, transactionID, string(payload))
+		logging.Ctx(r.Context()).Debugf("request_id=%s transaction_id=%s engagement_response=%s", req.RequestID, transactionID, h.Redaction.body(payload))
 	} else {
-		logging.Warnf("request_id=%s transaction_id=%s failed to marshal engagement response: %v", req.RequestID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s failed to marshal engagement response: %v", req.RequestID, transactionID, err)
 	}
 	respondJSON(w, http.StatusOK, response)
 }
 
 func (h *Handler) RegisterBots(w http.ResponseWriter, r *http.Request) {
 	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	if !h.validateStrict(w, r, "register") {
+		return
+	}
 	var req BotRegisterRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&req); err != nil {
-		logging.Warnf("request_id=%s transaction_id=%s invalid register request: %v", transactionID, transactionID, err)
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid register request: %v", transactionID, transactionID, err)
 		respondError(w, http.StatusBadRequest, "invalid_json")
 		return
 	}
+	if violations := req.Validate(); len(violations) > 0 {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s register_validation_failed violations=%d first_path=%s first_rule=%s", transactionID, transactionID, len(violations), violations[0].Path, violations[0].Rule)
+		respondJSON(w, http.StatusBadRequest, ValidationFailedResponse{Error: "validation_failed", Details: violations})
+		return
+	}
 
 	count := h.Planner.RegisterBots(req.ServerID, req.Bots)
-	logging.Infof("request_id=%s transaction_id=%s register_bots server_id=%s bots=%d registered=%d", transactionID, transactionID, req.ServerID, len(req.Bots), count)
+	if req.DefaultSettings != nil {
+		h.Planner.RegisterDefaultSettings(req.ServerID, *req.DefaultSettings)
+	}
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s register_bots server_id=%s bots=%d registered=%d default_settings=%t", transactionID, transactionID, req.ServerID, len(req.Bots), count, req.DefaultSettings != nil)
 	respondJSON(w, http.StatusOK, BotRegisterResponse{Registered: count})
 }
 
+func (h *Handler) CheckActions(w http.ResponseWriter, r *http.Request) {
+	transactionID := RequestIDFromContext(r.Context())
+	if !h.checkReady(w, r) {
+		return
+	}
+	var req ActionCheckRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid action check request: %v", transactionID, transactionID, err)
+		respondError(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+
+	response := h.Planner.CheckActions(req)
+	logging.Ctx(r.Context()).Infof("request_id=%s transaction_id=%s check_actions tokens=%d chat_messages=%d", transactionID, transactionID, len(req.Tokens), len(req.Chat))
+	respondJSON(w, http.StatusOK, response)
+}
+
+// Meta describes what plan responses may contain: the action reasons, the
+// active topics and the supported schema versions.
+func (h *Handler) Meta(w http.ResponseWriter, r *http.Request) {
+	if !h.checkReady(w, r) {
+		return
+	}
+	respondJSON(w, http.StatusOK, MetaResponse{
+		SchemaVersion:    models.SchemaVersion1,
+		MaxSchemaVersion: models.MaxSchemaVersion,
+		Reasons:          models.Reasons,
+		ReasonPrefixes:   []string{models.ReasonDryRunPrefix},
+		ReasonSuffixes:   []string{models.ReasonWhisperSuffix},
+		Topics:           h.Planner.Topics(),
+	})
+}
+
+func (h *Handler) Schemas(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/schemas/")
+	document, ok := schema.Export(name)
+	if !ok {
+		respondError(w, http.StatusNotFound, "unknown_schema")
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(document); err != nil {
+		logging.Warnf("failed to encode schema %s: %v", name, err)
+	}
+}
+
+// validateStrict checks the raw body against the endpoint schema when strict
+// validation is enabled and restores it for the regular decoder.
+func (h *Handler) validateStrict(w http.ResponseWriter, r *http.Request, name string) bool {
+	if !h.StrictValidation {
+		return true
+	}
+	transactionID := RequestIDFromContext(r.Context())
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s invalid %s request: %v", transactionID, transactionID, name, err)
+		respondError(w, http.StatusBadRequest, "invalid_json")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !json.Valid(body) {
+		// Malformed JSON keeps the regular 400 invalid_json response.
+		return true
+	}
+
+	s, _ := schema.Lookup(name)
+	violations := schema.Validate(s, body)
+	if len(violations) == 0 {
+		return true
+	}
+	logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s strict_validation_failed schema=%s violations=%d first_path=%s first_rule=%s", transactionID, transactionID, name, len(violations), violations[0].Path, violations[0].Rule)
+	respondJSON(w, http.StatusUnprocessableEntity, ValidationErrorResponse{Error: "validation_failed", Violations: violations})
+	return false
+}
+
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/planner"
+)
+
+type stubLLM struct {
+	message string
+	err     error
+}
+
+func (stubLLM) Enabled() bool { return true }
+
+func (s stubLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	return s.message, s.err
+}
+
+func (stubLLM) Close() error { return nil }
+
+const greetingPlan = `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,"bots":[{"bot_id":"bot_01","name":"Kuba"}],"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"siema"}],"settings":{"reply_chance":1,"global_silence_chance":0}}`
+
+func serveHandler(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestHandlerNotReady(t *testing.T) {
+	ready := &Handler{Planner: planner.NewPlanner(nil, planner.Config{})}
+	ready.MarkReady()
+	tests := []struct {
+		name       string
+		handler    *Handler
+		wantStatus int
+	}{
+		{name: "no dependencies", handler: &Handler{}, wantStatus: http.StatusServiceUnavailable},
+		{name: "not marked ready", handler: &Handler{Planner: planner.NewPlanner(nil, planner.Config{})}, wantStatus: http.StatusServiceUnavailable},
+		{name: "marked ready without planner", handler: func() *Handler { h := &Handler{}; h.MarkReady(); return h }(), wantStatus: http.StatusServiceUnavailable},
+		{name: "ready", handler: ready, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, route := range []struct {
+				path    string
+				handler http.HandlerFunc
+			}{
+				{path: "/v1/plan", handler: tt.handler.Plan},
+				{path: "/v1/engagement", handler: tt.handler.Engagement},
+				{path: "/v1/plan/batch", handler: tt.handler.PlanBatch},
+			} {
+				body := greetingPlan
+				switch route.path {
+				case "/v1/engagement":
+					body = strings.TrimSuffix(greetingPlan, "}") + `,"target_player":"Steve"}`
+				case "/v1/plan/batch":
+					body = `{"requests":[` + greetingPlan + `]}`
+				}
+				recorder := serveHandler(route.handler, "POST", route.path, body)
+				if recorder.Code != tt.wantStatus {
+					t.Fatalf("%s status = %d, want %d (body %s)", route.path, recorder.Code, tt.wantStatus, recorder.Body.String())
+				}
+				if tt.wantStatus == http.StatusServiceUnavailable && strings.TrimSpace(recorder.Body.String()) != `{"error":"not_ready"}` {
+					t.Fatalf("%s body = %s", route.path, recorder.Body.String())
+				}
+			}
+
+			readyz := serveHandler(tt.handler.Readyz, "GET", "/readyz", "")
+			if readyz.Code != tt.wantStatus {
+				t.Fatalf("readyz status = %d, want %d (body %s)", readyz.Code, tt.wantStatus, readyz.Body.String())
+			}
+			if healthz := serveHandler(tt.handler.Healthz, "GET", "/healthz", ""); healthz.Code != http.StatusOK {
+				t.Fatalf("healthz status = %d, want 200", healthz.Code)
+			}
+		})
+	}
+
+	if recorder := serveHandler(ready.PlanAsync, "POST", "/v1/plan/async", greetingPlan); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("async without an async planner: status = %d, want 503", recorder.Code)
+	}
+}
+
+func TestPlanReportsDegraded(t *testing.T) {
+	tests := []struct {
+		name           string
+		generator      planner.LLMGenerator
+		llmUnavailable bool
+		wantDegraded   bool
+	}{
+		{name: "llm answers", generator: stubLLM{message: "siema Steve"}},
+		{name: "llm disabled on purpose"},
+		{name: "llm fails", generator: stubLLM{err: errors.New("connection refused")}, wantDegraded: true},
+		{name: "llm client failed to start", llmUnavailable: true, wantDegraded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := planner.NewPlanner(tt.generator, planner.Config{})
+			if tt.llmUnavailable {
+				p.MarkLLMUnavailable()
+			}
+			h := &Handler{Planner: p}
+			h.MarkReady()
+
+			recorder := serveHandler(h.Plan, "POST", "/v1/plan", greetingPlan)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+			}
+			var response PlanResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(response.Actions) == 0 {
+				t.Fatalf("expected a reply, got %s", recorder.Body.String())
+			}
+			if response.Debug.Degraded != tt.wantDegraded {
+				t.Fatalf("degraded = %t, want %t (body %s)", response.Debug.Degraded, tt.wantDegraded, recorder.Body.String())
+			}
+			if !tt.wantDegraded && strings.Contains(recorder.Body.String(), `"degraded"`) {
+				t.Fatalf("healthy plan carries degraded: %s", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetaListsReasonsAndTopics(t *testing.T) {
+	h := &Handler{Planner: planner.NewPlanner(nil, planner.Config{})}
+	h.MarkReady()
+	recorder := serveHandler(h.Meta, "GET", "/v1/meta", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	var response MetaResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(response.Reasons) != len(models.Reasons) || response.Reasons[0].Reason != models.ReasonGreeting || response.Reasons[0].Description == "" {
+		t.Fatalf("unexpected reasons: %+v", response.Reasons)
+	}
+	if response.MaxSchemaVersion != models.MaxSchemaVersion || response.Topics[0] != "toxic" {
+		t.Fatalf("unexpected meta: %s", recorder.Body.String())
+	}
+}
+
+func TestRegisterBotsStoresDefaultSettings(t *testing.T) {
+	h := &Handler{Planner: planner.NewPlanner(nil, planner.Config{})}
+	h.MarkReady()
+
+	recorder := serveHandler(h.RegisterBots, "POST", "/v1/bots/register", `{"server_id":"srv-1","bots":[{"bot_id":"bot_01"}],"default_settings":{"reply_chance":2}}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "/default_settings/reply_chance") {
+		t.Fatalf("status = %d (body %s), want a validation failure", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = serveHandler(h.RegisterBots, "POST", "/v1/bots/register", `{"server_id":"srv-1","bots":[{"bot_id":"bot_01"}],"default_settings":{"max_actions":1,"reply_chance":1}}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	plan := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,"bots":[{"bot_id":"bot_01"},{"bot_id":"bot_02"}],"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"jak zrobic portal?"}]}`
+	recorder = serveHandler(h.Plan, "POST", "/v1/plan", plan)
+	var response PlanResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(response.Actions) != 1 {
+		t.Fatalf("expected the registered max_actions to apply, got %s", recorder.Body.String())
+	}
+}
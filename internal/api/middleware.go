@@ -3,29 +3,42 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
 )
 
 type ctxKey string
 
-const requestIDKey ctxKey = "request_id"
+const (
+	requestIDKey    ctxKey = "request_id"
+	requestIDHeader        = "X-Request-Id"
+)
 
 func RequestIDFromContext(ctx context.Context) string {
 	value, _ := ctx.Value(requestIDKey).(string)
 	return value
 }
 
+// WithRequestID tags the request with an ID and a W3C trace span; the trace
+// continues an incoming traceparent header when it is valid.
 func WithRequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := r.Header.Get("X-Request-Id")
+		reqID := r.Header.Get(requestIDHeader)
 		if reqID == "" {
 			reqID = generateRequestID()
 		}
+		w.Header().Set(requestIDHeader, reqID)
 		ctx := context.WithValue(r.Context(), requestIDKey, reqID)
+		ctx = logging.WithTrace(ctx, logging.StartTrace(r.Header.Get(logging.TraceparentHeader)))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -36,7 +49,7 @@ func RequestLogging(next http.Handler) http.Handler {
 		start := time.Now()
 		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(recorder, r)
-		logging.Infof(
+		logging.Ctx(r.Context()).Infof(
 			"ts=%s request_id=%s transaction_id=%s method=%s path=%s status=%d bytes=%d duration_ms=%d remote_addr=%s user_agent=%q",
 			start.Format(time.RFC3339),
 			reqID,
@@ -52,19 +65,15 @@ func RequestLogging(next http.Handler) http.Handler {
 	})
 }
 
-func RequestDebugLogging(next http.Handler) http.Handler {
+func RequestDebugLogging(redaction LogRedaction, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !logging.Enabled(logging.LevelDebug) {
 			next.ServeHTTP(w, r)
 			return
 		}
 		reqID := RequestIDFromContext(r.Context())
-		var bodyBytes []byte
-		if r.Body != nil {
-			bodyBytes, _ = io.ReadAll(r.Body)
-			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		}
-		logging.Debugf(
+		bodyBytes := readBody(r)
+		logging.Ctx(r.Context()).Debugf(
 			"request_id=%s transaction_id=%s incoming_request method=%s path=%s query=%s content_length=%d content_type=%s headers=%v body=%s",
 			reqID,
 			reqID,
@@ -73,29 +82,26 @@ func RequestDebugLogging(next http.Handler) http.Handler {
 			r.URL.RawQuery,
 			r.ContentLength,
 			r.Header.Get("Content-Type"),
-			r.Header,
-			string(bodyBytes),
+			redaction.headers(r.Header),
+			redaction.body(bodyBytes),
 		)
 		next.ServeHTTP(w, r)
 	})
 }
 
-func RequestErrorLogging(next http.Handler) http.Handler {
+func RequestErrorLogging(redaction LogRedaction, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqID := RequestIDFromContext(r.Context())
-		var bodyBytes []byte
-		if r.Body != nil {
-			bodyBytes, _ = io.ReadAll(r.Body)
-			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		}
+		bodyBytes := readBody(r)
 		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(recorder, r)
 		if recorder.status < http.StatusBadRequest {
 			return
 		}
-		logFn := logging.Infof
+		logger := logging.Ctx(r.Context())
+		logFn := logger.Infof
 		if recorder.status >= http.StatusInternalServerError {
-			logFn = logging.Errorf
+			logFn = logger.Errorf
 		}
 		logFn(
 			"request_id=%s transaction_id=%s error_request method=%s path=%s query=%s status=%d bytes=%d content_length=%d content_type=%s headers=%v body=%s remote_addr=%s user_agent=%q",
@@ -108,37 +114,123 @@ func RequestErrorLogging(next http.Handler) http.Handler {
 			recorder.bytes,
 			r.ContentLength,
 			r.Header.Get("Content-Type"),
-			r.Header,
-			string(bodyBytes),
+			redaction.headers(r.Header),
+			redaction.body(bodyBytes),
 			r.RemoteAddr,
 			r.UserAgent(),
 		)
 	})
 }
 
+// RequestTimeout bounds the request context so a stuck LLM call cannot hold
+// the handler until the server's write timeout cuts the response.
+func RequestTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Recover turns a handler panic into a 500 JSON response so the client gets
+// an answer instead of a dropped connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			reqID := RequestIDFromContext(r.Context())
+			logging.Ctx(r.Context()).Exceptionf("request_id=%s transaction_id=%s handler_panic method=%s path=%s panic=%q stack=%q", reqID, reqID, r.Method, r.URL.Path, fmt.Sprint(recovered), string(debug.Stack()))
+			if recorder.wroteHeader {
+				return
+			}
+			respondJSON(recorder, http.StatusInternalServerError, InternalErrorResponse{Error: "internal_error", RequestID: reqID})
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// LimitBodySize reads the body up front and answers 413 payload_too_large
+// when it exceeds limit bytes, instead of letting handlers fail on a
+// truncated body.
 func LimitBodySize(limit int64, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			transactionID := RequestIDFromContext(r.Context())
+			logging.Ctx(r.Context()).Warnf("request_id=%s transaction_id=%s payload_too_large path=%s limit_bytes=%d content_length=%d", transactionID, transactionID, r.URL.Path, limit, r.ContentLength)
+			respondJSON(w, http.StatusRequestEntityTooLarge, PayloadTooLargeResponse{Error: "payload_too_large", LimitBytes: limit})
+			return
+		}
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid_body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
 		next.ServeHTTP(w, r)
 	})
 }
 
+func InstrumentRoute(path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+		metrics.ObserveHTTPRequest(path, recorder.status, time.Since(start))
+	}
+}
+
+// generateRequestID returns a random UUIDv4.
 func generateRequestID() string {
-	return time.Now().Format("20060102T150405.000000000")
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		logging.Warnf("request_id_generation_failed error=%v", err)
+		return time.Now().Format("20060102T150405.000000000")
+	}
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	var out [36]byte
+	hex.Encode(out[0:8], buf[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], buf[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], buf[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], buf[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], buf[10:])
+	return string(out[:])
 }
 
 type responseRecorder struct {
 	http.ResponseWriter
-	status int
-	bytes  int
+	status      int
+	bytes       int
+	wroteHeader bool
 }
 
 func (r *responseRecorder) WriteHeader(status int) {
 	r.status = status
+	r.wroteHeader = true
 	r.ResponseWriter.WriteHeader(status)
 }
 
 func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
 	size, err := r.ResponseWriter.Write(data)
 	r.bytes += size
 	return size, err
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/logging"
+)
+
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestGenerateRequestIDIsUniqueUUID(t *testing.T) {
+	seen := make(map[string]struct{}, 10000)
+	for i := 0; i < 10000; i++ {
+		id := generateRequestID()
+		if !uuidV4Pattern.MatchString(id) {
+			t.Fatalf("id %q is not a UUIDv4", id)
+		}
+		if _, dup := seen[id]; dup {
+			t.Fatalf("duplicate id %q after %d ids", id, i)
+		}
+		seen[id] = struct{}{}
+	}
+}
+
+func TestWithRequestIDHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{name: "provided", incoming: "plugin-req-42 / tick=7"},
+		{name: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/healthz", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-Id", tt.incoming)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			echoed := recorder.Header().Get("X-Request-Id")
+			if echoed != seen {
+				t.Fatalf("response header %q does not match context id %q", echoed, seen)
+			}
+			if tt.incoming != "" && seen != tt.incoming {
+				t.Fatalf("provided id changed: got %q, want %q", seen, tt.incoming)
+			}
+			if tt.incoming == "" && !uuidV4Pattern.MatchString(seen) {
+				t.Fatalf("generated id %q is not a UUIDv4", seen)
+			}
+		})
+	}
+}
+
+func TestWithRequestIDStartsTrace(t *testing.T) {
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name        string
+		traceparent string
+		wantTraceID string
+		wantParent  string
+	}{
+		{name: "continues incoming trace", traceparent: incoming, wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736", wantParent: "00f067aa0ba902b7"},
+		{name: "starts trace when absent"},
+		{name: "starts trace when invalid", traceparent: "00-zz-00f067aa0ba902b7-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace logging.Trace
+			handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = logging.TraceFromContext(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/healthz", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`).MatchString(trace.Traceparent()) {
+				t.Fatalf("traceparent %q is not W3C formatted", trace.Traceparent())
+			}
+			if tt.wantTraceID != "" && trace.TraceID != tt.wantTraceID {
+				t.Fatalf("trace id = %s, want %s", trace.TraceID, tt.wantTraceID)
+			}
+			if trace.ParentID != tt.wantParent || trace.SpanID == tt.wantParent {
+				t.Fatalf("trace = %+v, want parent %q and a new span", trace, tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestRecoverReturnsInternalError(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "panic before write",
+			handler:    func(w http.ResponseWriter, r *http.Request) { var m map[string]int; m["x"]++ },
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"error":"internal_error","request_id":"req-panic"}`,
+		},
+		{
+			name: "panic after headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("late failure")
+			},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("POST", "/v1/plan", nil)
+			req.Header.Set(requestIDHeader, "req-panic")
+			recorder := httptest.NewRecorder()
+			WithRequestID(Recover(tt.handler)).ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(recorder.Body.String()); got != tt.wantBody {
+				t.Fatalf("body = %s, want %s", got, tt.wantBody)
+			}
+			logged := logs.String()
+			if !strings.Contains(logged, "[EXCEPTION] request_id=req-panic") || !strings.Contains(logged, "handler_panic") || !strings.Contains(logged, "goroutine") {
+				t.Fatalf("panic not logged with request id and stack: %s", logged)
+			}
+		})
+	}
+}
+
+func TestLimitBodySize(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within limit", body: `{"a":1}`, wantStatus: http.StatusOK},
+		{name: "at limit", body: strings.Repeat("x", 16), wantStatus: http.StatusOK},
+		{name: "over limit", body: strings.Repeat("x", 17), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "over limit without content length", body: strings.Repeat("x", 64), chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := LimitBodySize(16, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				seen = string(body)
+			}))
+			req := httptest.NewRequest("POST", "/v1/plan", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if seen != tt.body {
+					t.Fatalf("handler saw %q, want %q", seen, tt.body)
+				}
+				return
+			}
+			var response PayloadTooLargeResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Error != "payload_too_large" || response.LimitBytes != 16 {
+				t.Fatalf("body = %s (err %v)", recorder.Body.String(), err)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package api
 
 import "aichatplayers/internal/models"
 
+// The request and response types are defined once in internal/models and
+// aliased here, so handlers, the planner and clients share one wire format.
+
 type ServerContext = models.ServerContext
 
 type Persona = models.Persona
@@ -12,18 +15,80 @@ type ChatMessage = models.ChatMessage
 
 type PlanSettings = models.PlanSettings
 
+type LLMSettings = models.LLMSettings
+
+type LLMOverrides = models.LLMOverrides
+
 type PlanRequest = models.PlanRequest
 
 type EngagementRequest = models.EngagementRequest
 
 type PlannedAction = models.PlannedAction
 
+type RequiredBotFailure = models.RequiredBotFailure
+
 type PlanDebug = models.PlanDebug
 
+type LLMBackendUse = models.LLMBackendUse
+
 type PlanResponse = models.PlanResponse
 
+type ActionCheckRequest = models.ActionCheckRequest
+
+type ActionCheckResult = models.ActionCheckResult
+
+type ActionCheckResponse = models.ActionCheckResponse
+
 type HealthResponse = models.HealthResponse
 
+type ReadinessResponse = models.ReadinessResponse
+
+type ReadinessComponents = models.ReadinessComponents
+
+type LLMReadiness = models.LLMReadiness
+
+type LLMServerReadiness = models.LLMServerReadiness
+
+type PlannerReadiness = models.PlannerReadiness
+
+type ElasticReadiness = models.ElasticReadiness
+
 type BotRegisterRequest = models.BotRegisterRequest
 
 type BotRegisterResponse = models.BotRegisterResponse
+
+type ValidationViolation = models.ValidationViolation
+
+type ValidationErrorResponse = models.ValidationErrorResponse
+
+type ValidationFailedResponse = models.ValidationFailedResponse
+
+type RateLimitedResponse = models.RateLimitedResponse
+
+type UnsupportedSchemaVersionResponse = models.UnsupportedSchemaVersionResponse
+
+type InternalErrorResponse = models.InternalErrorResponse
+
+type AsyncPlanRequest = models.AsyncPlanRequest
+
+type AsyncPlanStatus = models.AsyncPlanStatus
+
+type BatchPlanRequest = models.BatchPlanRequest
+
+type TopicsReloadResponse = models.TopicsReloadResponse
+
+type PayloadTooLargeResponse = models.PayloadTooLargeResponse
+
+type ConfigReloadResponse = models.ConfigReloadResponse
+
+type ConfigResponse = models.ConfigResponse
+
+type BatchPlanEntry = models.BatchPlanEntry
+
+type BatchPlanResponse = models.BatchPlanResponse
+
+type Reason = models.Reason
+
+type ReasonInfo = models.ReasonInfo
+
+type MetaResponse = models.MetaResponse
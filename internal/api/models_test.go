@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+// The API types must stay aliases of internal/models: a separate struct
+// with the same name no longer converts implicitly and breaks the build.
+var (
+	_ *models.ServerContext       = (*ServerContext)(nil)
+	_ *models.Persona             = (*Persona)(nil)
+	_ *models.BotProfile          = (*BotProfile)(nil)
+	_ *models.ChatMessage         = (*ChatMessage)(nil)
+	_ *models.PlanSettings        = (*PlanSettings)(nil)
+	_ *models.LLMSettings         = (*LLMSettings)(nil)
+	_ *models.LLMOverrides        = (*LLMOverrides)(nil)
+	_ *models.PlanRequest         = (*PlanRequest)(nil)
+	_ *models.EngagementRequest   = (*EngagementRequest)(nil)
+	_ *models.AsyncPlanRequest    = (*AsyncPlanRequest)(nil)
+	_ *models.BatchPlanRequest    = (*BatchPlanRequest)(nil)
+	_ *models.BotRegisterRequest  = (*BotRegisterRequest)(nil)
+	_ *models.ActionCheckRequest  = (*ActionCheckRequest)(nil)
+	_ *models.PlannedAction       = (*PlannedAction)(nil)
+	_ *models.PlanDebug           = (*PlanDebug)(nil)
+	_ *models.PlanResponse        = (*PlanResponse)(nil)
+	_ *models.BatchPlanResponse   = (*BatchPlanResponse)(nil)
+	_ *models.ActionCheckResponse = (*ActionCheckResponse)(nil)
+	_ *models.HealthResponse      = (*HealthResponse)(nil)
+	_ *models.ReadinessResponse   = (*ReadinessResponse)(nil)
+)
+
+func TestExampleRequestsKeepWireFormat(t *testing.T) {
+	tests := []struct {
+		file   string
+		target any
+	}{
+		{file: "plan.json", target: &PlanRequest{}},
+		{file: "plan_async.json", target: &AsyncPlanRequest{}},
+		{file: "engagement.json", target: &EngagementRequest{}},
+		{file: "register.json", target: &BotRegisterRequest{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile("../../DOCS/examples/" + tt.file)
+			if err != nil {
+				t.Fatalf("read example: %v", err)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(tt.target); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			encoded, err := json.Marshal(tt.target)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			var original, roundTrip any
+			if err := json.Unmarshal(data, &original); err != nil {
+				t.Fatalf("decode example: %v", err)
+			}
+			if err := json.Unmarshal(encoded, &roundTrip); err != nil {
+				t.Fatalf("decode round trip: %v", err)
+			}
+			if err := jsonSubset("", original, roundTrip); err != nil {
+				t.Fatalf("round trip changed the wire format: %v\n%s", err, encoded)
+			}
+		})
+	}
+}
+
+func TestPlanSettingsKebabCaseThroughAPI(t *testing.T) {
+	var req PlanRequest
+	if err := json.Unmarshal([]byte(`{"settings":{"max_actions":2,"topic-cooldown-ms":5000,"llm":{"top-p":0.5}}}`), &req); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if req.Settings.MaxActions != 2 || req.Settings.TopicCooldownMS == nil || *req.Settings.TopicCooldownMS != 5000 {
+		t.Fatalf("settings = %+v", req.Settings)
+	}
+	if req.Settings.LLM == nil || req.Settings.LLM.TopP == nil || *req.Settings.LLM.TopP != 0.5 {
+		t.Fatalf("llm settings = %+v", req.Settings.LLM)
+	}
+}
+
+// jsonSubset reports the first value of want that got lacks or changes.
+func jsonSubset(path string, want, got any) error {
+	switch want := want.(type) {
+	case map[string]any:
+		gotMap, ok := got.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: got %T, want an object", path, got)
+		}
+		for key, value := range want {
+			if err := jsonSubset(path+"/"+key, value, gotMap[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		gotList, ok := got.([]any)
+		if !ok || len(gotList) != len(want) {
+			return fmt.Errorf("%s: got %v, want %d items", path, got, len(want))
+		}
+		for i := range want {
+			if err := jsonSubset(fmt.Sprintf("%s/%d", path, i), want[i], gotList[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return fmt.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+	return nil
+}
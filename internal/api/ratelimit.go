@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterMaxIdleBuckets bounds how many buckets are kept before full
+// (idle) ones are dropped; a dropped bucket is recreated full anyway.
+const rateLimiterMaxIdleBuckets = 1024
+
+// RateLimiter is a token bucket per key (server ID or client IP).
+type RateLimiter struct {
+	ratePerSecond float64
+	burst         float64
+	now           func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns nil when perMinute <= 0, which disables limiting.
+// A burst below 1 falls back to a single request.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		ratePerSecond: float64(perMinute) / 60,
+		burst:         float64(burst),
+		now:           time.Now,
+		buckets:       make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the key's bucket. When the bucket is empty it
+// reports how long the caller has to wait for the next token.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimiterMaxIdleBuckets {
+			l.pruneIdle(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+	}
+	l.refill(bucket, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	missing := 1 - bucket.tokens
+	retryAfter := time.Duration(missing / l.ratePerSecond * float64(time.Second))
+	if retryAfter < time.Millisecond {
+		retryAfter = time.Millisecond
+	}
+	return false, retryAfter
+}
+
+func (l *RateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	bucket.tokens += elapsed * l.ratePerSecond
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.updated = now
+}
+
+func (l *RateLimiter) pruneIdle(now time.Time) {
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func rateLimitKey(serverID, remoteAddr string) string {
+	if serverID = strings.TrimSpace(serverID); serverID != "" {
+		return "server:" + serverID
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	now := time.Unix(1712345000, 0)
+	limiter := NewRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("server:a"); !allowed {
+			t.Fatalf("burst request #%d rejected", i+1)
+		}
+	}
+	allowed, retryAfter := limiter.Allow("server:a")
+	if allowed || retryAfter != time.Second {
+		t.Fatalf("expected rejection with 1s retry, got allowed=%t retry=%s", allowed, retryAfter)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if allowed, retryAfter := limiter.Allow("server:a"); allowed || retryAfter != 500*time.Millisecond {
+		t.Fatalf("expected rejection with 500ms retry, got allowed=%t retry=%s", allowed, retryAfter)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if allowed, _ := limiter.Allow("server:a"); !allowed {
+		t.Fatal("expected a refilled token after one second")
+	}
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("server:a"); !allowed {
+			t.Fatalf("refill should cap at burst, request #%d rejected", i+1)
+		}
+	}
+	if allowed, _ := limiter.Allow("server:a"); allowed {
+		t.Fatal("refill should not exceed burst")
+	}
+}
+
+func TestRateLimiterBucketsAreIndependent(t *testing.T) {
+	now := time.Unix(1712345000, 0)
+	limiter := NewRateLimiter(60, 1)
+	limiter.now = func() time.Time { return now }
+
+	if allowed, _ := limiter.Allow("server:a"); !allowed {
+		t.Fatal("first request for server a rejected")
+	}
+	if allowed, _ := limiter.Allow("server:a"); allowed {
+		t.Fatal("second request for server a should be limited")
+	}
+	if allowed, _ := limiter.Allow("server:b"); !allowed {
+		t.Fatal("server b should have its own bucket")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 5)
+	for i := 0; i < 100; i++ {
+		if allowed, _ := limiter.Allow("server:a"); !allowed {
+			t.Fatalf("disabled limiter rejected request #%d", i+1)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	tests := []struct {
+		serverID   string
+		remoteAddr string
+		want       string
+	}{
+		{serverID: "srv-1", remoteAddr: "10.0.0.1:5555", want: "server:srv-1"},
+		{serverID: " ", remoteAddr: "10.0.0.1:5555", want: "ip:10.0.0.1"},
+		{remoteAddr: "[::1]:5555", want: "ip:::1"},
+		{remoteAddr: "unix", want: "ip:unix"},
+	}
+	for _, tt := range tests {
+		if got := rateLimitKey(tt.serverID, tt.remoteAddr); got != tt.want {
+			t.Fatalf("rateLimitKey(%q, %q) = %q, want %q", tt.serverID, tt.remoteAddr, got, tt.want)
+		}
+	}
+}
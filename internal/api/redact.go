@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const redactedHeaderVal = "[REDACTED]"
+
+var sensitiveHeaders = []string{"Authorization", apiKeyHeader, "Cookie"}
+
+// LogRedaction decides what request logging may write. Credentials are
+// always hidden; with Chat set, chat text in JSON bodies is replaced by a
+// hash so identical messages still correlate.
+type LogRedaction struct {
+	Chat bool
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, redactedHeaderVal)
+		}
+	}
+	return redacted
+}
+
+// readBody returns the request body and puts it back for the next handler.
+func readBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	return body
+}
+
+func (lr LogRedaction) headers(headers http.Header) http.Header {
+	return redactHeaders(headers)
+}
+
+// body returns the body as it may be logged. A body that is not valid JSON
+// cannot be redacted field by field, so in chat mode only its size is kept.
+func (lr LogRedaction) body(body []byte) string {
+	if !lr.Chat || len(body) == 0 {
+		return string(body)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Sprintf("[REDACTED bytes=%d]", len(body))
+	}
+	redacted, err := json.Marshal(redactMessages(value))
+	if err != nil {
+		return fmt.Sprintf("[REDACTED bytes=%d]", len(body))
+	}
+	return string(redacted)
+}
+
+func redactMessages(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, field := range typed {
+			if text, ok := field.(string); ok && key == "message" {
+				typed[key] = hashChat(text)
+				continue
+			}
+			typed[key] = redactMessages(field)
+		}
+	case []any:
+		for i, item := range typed {
+			typed[i] = redactMessages(item)
+		}
+	}
+	return value
+}
+
+func hashChat(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/planner"
+)
+
+func TestRequestLoggingRedactsSecrets(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	logging.SetLevel(logging.LevelDebug)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		logging.SetLevel(logging.LevelInfo)
+	})
+
+	const body = `{"server_id":"srv-1","chat":[{"sender":"Steve","message":"my address is 12 Elm St"}],"nested":{"message":"hi"},"tick":3}`
+	tests := []struct {
+		name       string
+		middleware func(LogRedaction, http.Handler) http.Handler
+		redaction  LogRedaction
+		wantLogged []string
+		hidden     []string
+	}{
+		{
+			name:       "debug headers only",
+			middleware: RequestDebugLogging,
+			wantLogged: []string{"incoming_request", "my address is 12 Elm St", redactedHeaderVal},
+			hidden:     []string{"bearer-secret", "key-secret", "session=cookie-secret"},
+		},
+		{
+			name:       "debug chat",
+			middleware: RequestDebugLogging,
+			redaction:  LogRedaction{Chat: true},
+			wantLogged: []string{"incoming_request", `"sender":"Steve"`, `"tick":3`, hashChat("my address is 12 Elm St"), hashChat("hi")},
+			hidden:     []string{"bearer-secret", "key-secret", "cookie-secret", "12 Elm St"},
+		},
+		{
+			name:       "error chat",
+			middleware: RequestErrorLogging,
+			redaction:  LogRedaction{Chat: true},
+			wantLogged: []string{"error_request", "status=400", hashChat("my address is 12 Elm St")},
+			hidden:     []string{"bearer-secret", "key-secret", "cookie-secret", "12 Elm St"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			var seen string
+			handler := tt.middleware(tt.redaction, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				seen = string(data)
+				w.WriteHeader(http.StatusBadRequest)
+			}))
+			req := httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer bearer-secret")
+			req.Header.Set("X-Api-Key", "key-secret")
+			req.Header.Set("Cookie", "session=cookie-secret")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if seen != body {
+				t.Fatalf("handler saw %q, want the original body", seen)
+			}
+			logged := logs.String()
+			for _, want := range tt.wantLogged {
+				if !strings.Contains(logged, want) {
+					t.Fatalf("log is missing %q: %s", want, logged)
+				}
+			}
+			for _, secret := range tt.hidden {
+				if strings.Contains(logged, secret) {
+					t.Fatalf("log leaks %q: %s", secret, logged)
+				}
+			}
+		})
+	}
+}
+
+func TestLogRedactionBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction LogRedaction
+		body      string
+		want      string
+	}{
+		{name: "off", body: `{"message":"hello"}`, want: `{"message":"hello"}`},
+		{name: "non-string message kept", redaction: LogRedaction{Chat: true}, body: `{"message":{"text":"x"},"n":1.50}`, want: `{"message":{"text":"x"},"n":1.50}`},
+		{name: "invalid json", redaction: LogRedaction{Chat: true}, body: `{"message":"hel`, want: "[REDACTED bytes=15]"},
+		{name: "empty", redaction: LogRedaction{Chat: true}, body: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.redaction.body([]byte(tt.body)); got != tt.want {
+				t.Fatalf("body() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanAndEngagementLogsRedactChat(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	logging.SetLevel(logging.LevelDebug)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		logging.SetLevel(logging.LevelInfo)
+	})
+
+	const chat = "mieszkam przy Elm St 12"
+	const reply = "wpadne do ciebie na Elm"
+	h := &Handler{Planner: planner.NewPlanner(stubLLM{message: reply}, planner.Config{}), Redaction: LogRedaction{Chat: true}}
+	h.MarkReady()
+	plan := strings.Replace(greetingPlan, `"message":"siema"`, `"message":"`+chat+`"`, 1)
+	for _, route := range []struct {
+		path    string
+		handler http.HandlerFunc
+		body    string
+		logged  []string
+	}{
+		{path: "/v1/plan", handler: h.Plan, body: plan, logged: []string{"plan_request=", "plan_response="}},
+		{path: "/v1/engagement", handler: h.Engagement, body: strings.TrimSuffix(plan, "}") + `,"target_player":"Steve"}`, logged: []string{"engagement_request=", "engagement_response="}},
+	} {
+		logs.Reset()
+		recorder := serveHandler(route.handler, "POST", route.path, route.body)
+		var response PlanResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || recorder.Code != http.StatusOK || len(response.Actions) != 1 {
+			t.Fatalf("%s status = %d (body %s), want one action", route.path, recorder.Code, recorder.Body.String())
+		}
+		logged := logs.String()
+		for _, want := range append(route.logged, hashChat(chat), hashChat(response.Actions[0].Message)) {
+			if !strings.Contains(logged, want) {
+				t.Fatalf("%s log is missing %q: %s", route.path, want, logged)
+			}
+		}
+		for _, secret := range []string{chat, response.Actions[0].Message} {
+			if strings.Contains(logged, secret) {
+				t.Fatalf("%s log leaks %q: %s", route.path, secret, logged)
+			}
+		}
+	}
+}
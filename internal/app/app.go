@@ -0,0 +1,272 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/api"
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/planner"
+)
+
+// defaultBodyLimitBytes applies when Config.HTTP leaves the limit unset.
+const defaultBodyLimitBytes = 1 << 20
+
+type App struct {
+	Config  config.Config
+	Planner *planner.Planner
+	Handler http.Handler
+	// TLSConfig is nil unless HTTP_TLS_CERT_FILE is set.
+	TLSConfig *tls.Config
+
+	api      *api.Handler
+	llm      planner.LLMGenerator
+	reloadMu sync.Mutex
+	drainers closerRegistry
+	closers  closerRegistry
+}
+
+type Deps struct {
+	InitLogging    func(cfg config.ElasticConfig) (logFile io.Closer, elasticLogger io.Closer, err error)
+	StartLLMServer func(cfg config.LLMConfig) (io.Closer, error)
+	NewLLM         func(cfg config.LLMConfig) (planner.LLMGenerator, error)
+}
+
+func DefaultDeps() Deps {
+	return Deps{
+		InitLogging: initLogging,
+		StartLLMServer: func(cfg config.LLMConfig) (io.Closer, error) {
+			proc, err := llm.EnsureServerReady(cfg)
+			if proc == nil {
+				return nil, err
+			}
+			return proc, err
+		},
+		NewLLM: func(cfg config.LLMConfig) (planner.LLMGenerator, error) {
+			client, err := llm.NewClient(cfg)
+			if err != nil {
+				return client, err
+			}
+			generator, err := llm.WithFaultInjection(client, cfg)
+			if err != nil {
+				return generator, err
+			}
+			return llm.WithConcurrencyLimit(llm.WithCircuitBreaker(generator, cfg), cfg), nil
+		},
+	}
+}
+
+func New(cfg config.Config, deps Deps) (*App, error) {
+	if _, err := llm.LoadPromptTemplate(cfg.LLM.PromptTemplatePath); err != nil {
+		return nil, fmt.Errorf("LLM_PROMPT_TEMPLATE_PATH %s: %w", cfg.LLM.PromptTemplatePath, err)
+	}
+	tlsConfig, err := serverTLSConfig(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	a := &App{Config: cfg, TLSConfig: tlsConfig}
+	a.drainers.timeout = cfg.API.ShutdownDrain
+	var elasticStatus api.ElasticStatusProvider
+
+	if deps.InitLogging != nil {
+		logFile, elasticLogger, err := deps.InitLogging(cfg.Elastic)
+		if err != nil {
+			return nil, err
+		}
+		if logFile != nil {
+			a.OnClose("log_file", ioCloser(logFile))
+		}
+		if elasticLogger != nil {
+			a.OnClose("elastic_logger", ioCloser(elasticLogger))
+			elasticStatus, _ = elasticLogger.(api.ElasticStatusProvider)
+		}
+	}
+	logging.Infof("elastic_config_loaded url=%s index=%s api_key_set=%t verify_cert=%t", cfg.Elastic.URL, cfg.Elastic.Index, cfg.Elastic.APIKey != "", cfg.Elastic.VerifyCert)
+
+	if deps.StartLLMServer != nil {
+		serverProcess, err := deps.StartLLMServer(cfg.LLM)
+		if err != nil {
+			logging.Errorf("llm_server_start_failed error=%v fallback=heuristics", err)
+		}
+		if serverProcess != nil {
+			a.OnClose("llm_server", ioCloser(serverProcess))
+		}
+	}
+
+	var generator planner.LLMGenerator
+	llmInitFailed := false
+	if deps.NewLLM != nil {
+		var err error
+		generator, err = deps.NewLLM(cfg.LLM)
+		if err != nil {
+			llmInitFailed = true
+			logging.Errorf("llm_init_failed error=%v fallback=heuristics", err)
+		}
+	}
+	if generator != nil {
+		a.OnClose("llm_client", ioCloser(generator))
+		if generator.Enabled() {
+			logging.Infof("llm_enabled model_path=%s ctx=%d threads=%d timeout=%s soft_timeout=%s", cfg.LLM.ModelPath, cfg.LLM.CtxSize, cfg.LLM.NumThreads, cfg.LLM.Timeout, cfg.LLM.SoftTimeout)
+		}
+	}
+
+	a.llm = generator
+	a.Planner = planner.NewPlanner(generator, plannerConfig(cfg))
+	if llmInitFailed {
+		a.Planner.MarkLLMUnavailable()
+	}
+	a.OnClose("planner_state", a.Planner.Close)
+
+	asyncPlanner := api.NewAsyncPlanner(a.Planner.Plan, cfg.API.AsyncWorkers, cfg.API.AsyncResultTTL, cfg.API.RequestTimeout)
+	a.OnDrain("async_planner", asyncPlanner.Close)
+	a.api = &api.Handler{
+		Planner:             a.Planner,
+		Elastic:             elasticStatus,
+		PlanLimiter:         api.NewRateLimiter(cfg.API.PlanRateLimitPerMinute, cfg.API.PlanRateBurst),
+		Async:               asyncPlanner,
+		BatchMaxSize:        cfg.API.PlanBatchMax,
+		StrictValidation:    cfg.API.StrictValidation,
+		ReadinessRequireLLM: cfg.API.ReadinessRequireLLM,
+		Reloader:            a,
+		ConfigDumper:        a,
+		Redaction:           api.LogRedaction{Chat: cfg.API.RedactChat},
+		StartedAt:           time.Now(),
+	}
+	a.api.MarkReady()
+	a.Handler = newHandler(cfg, a.api)
+	return a, nil
+}
+
+func plannerConfig(cfg config.Config) planner.Config {
+	return planner.Config{
+		LLMTimeout:             cfg.LLM.SoftTimeout,
+		ChatHistoryLimit:       cfg.LLM.ChatHistoryLimit,
+		LLMTemperature:         cfg.LLM.Temperature,
+		LLMTopP:                cfg.LLM.TopP,
+		LLMMaxTokens:           cfg.LLM.MaxTokens,
+		PressureQueueDepth:     cfg.LLM.PressureQueueDepth,
+		PressureP95Latency:     cfg.LLM.PressureP95,
+		EngagementCooldown:     cfg.Planner.EngagementCooldown,
+		RecentMessageLimit:     cfg.Planner.RecentMessageLimit,
+		ChatMessageMaxChars:    cfg.Planner.ChatMessageMaxChars,
+		ChatClockSkew:          cfg.Planner.ChatClockSkew,
+		PlanDeadline:           cfg.Planner.PlanDeadline,
+		MaxMessageChars:        cfg.LLM.MaxResponseChars,
+		StatePath:              cfg.Planner.StatePath,
+		StateInterval:          cfg.Planner.StateInterval,
+		TopicKeywordsPath:      cfg.Planner.TopicKeywordsPath,
+		QuietHours:             cfg.Planner.QuietHours,
+		ProfanityBlocklistPath: cfg.Planner.ProfanityBlocklistPath,
+	}
+}
+
+// Reload re-reads the environment and .env, applies the hot-reloadable
+// settings to the LLM client and the planner and reports the rest as
+// pending a restart. A config that fails to load leaves everything as is.
+func (a *App) Reload() (api.ConfigReloadResponse, error) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	loaded, err := config.Load()
+	if err != nil {
+		logging.Errorf("config_reload_failed error=%v", err)
+		return api.ConfigReloadResponse{}, err
+	}
+	applied, pendingRestart := config.Diff(a.Config, loaded)
+	a.Config = config.ApplyReloadable(a.Config, loaded)
+	if reconfigurer, ok := a.llm.(llm.Reconfigurer); ok {
+		reconfigurer.Reconfigure(a.Config.LLM)
+	}
+	a.Planner.Reconfigure(plannerConfig(a.Config))
+	logging.Infof("config_reloaded applied=%s pending_restart=%s", strings.Join(applied, ","), strings.Join(pendingRestart, ","))
+	if applied == nil {
+		applied = []string{}
+	}
+	if pendingRestart == nil {
+		pendingRestart = []string{}
+	}
+	return api.ConfigReloadResponse{Status: "reloaded", Applied: applied, PendingRestart: pendingRestart}, nil
+}
+
+// DumpConfig returns the running config, including reloaded settings, with
+// secrets masked.
+func (a *App) DumpConfig() api.ConfigResponse {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	return api.ConfigResponse{Config: config.Effective(a.Config), Sources: a.Config.Sources}
+}
+
+func (a *App) OnClose(name string, fn func(ctx context.Context) error) {
+	a.closers.add(name, fn)
+}
+
+// OnDrain registers fn to finish in-flight work on shutdown. Drainers run
+// before any OnClose hook, each bounded by SHUTDOWN_DRAIN_MS.
+func (a *App) OnDrain(name string, fn func(ctx context.Context) error) {
+	a.drainers.add(name, fn)
+}
+
+// Close turns /readyz unready, drains in-flight plans and only then closes
+// the LLM client, the llama-server and the loggers.
+func (a *App) Close(ctx context.Context) error {
+	if a.api != nil {
+		a.api.StartDraining()
+	}
+	return errors.Join(a.drainers.closeAll(ctx), a.closers.closeAll(ctx))
+}
+
+func newHandler(cfg config.Config, h *api.Handler) http.Handler {
+	mux := http.NewServeMux()
+	handle(mux, "/healthz", "GET", h.Healthz)
+	handle(mux, "/readyz", "GET", h.Readyz)
+	handle(mux, "/metrics", "GET", metrics.Handler)
+	handle(mux, "/v1/plan", "POST", h.Plan)
+	handle(mux, "/v1/plan/async", "POST", h.PlanAsync)
+	handle(mux, "/v1/plan/batch", "POST", h.PlanBatch)
+	handle(mux, "/v1/plan/", "GET", h.PlanResult)
+	handle(mux, "/v1/engagement", "POST", h.Engagement)
+	handle(mux, "/v1/bots/register", "POST", h.RegisterBots)
+	handle(mux, "/v1/actions/check", "POST", h.CheckActions)
+	handle(mux, "/v1/schemas/", "GET", h.Schemas)
+	handle(mux, "/v1/meta", "GET", h.Meta)
+	handle(mux, "/v1/admin/topics/reload", "POST", h.ReloadTopics)
+	handle(mux, "/v1/admin/reload", "POST", h.ReloadConfig)
+	handle(mux, "/v1/admin/config", "GET", h.DumpConfig)
+
+	bodyLimit := cfg.HTTP.BodyLimit
+	if bodyLimit <= 0 {
+		bodyLimit = defaultBodyLimitBytes
+	}
+	redaction := api.LogRedaction{Chat: cfg.API.RedactChat}
+	return api.WithRequestID(api.Recover(api.CompressResponse(api.RequestLogging(api.DecompressBody(api.LimitBodySize(bodyLimit, api.RequestErrorLogging(redaction, api.RequireClientCert(cfg.HTTP.TLSClientCAFile != "", api.RequireAPIToken(cfg.API.Tokens, api.RequireJSON(api.RequestDebugLogging(redaction, api.RequestTimeout(cfg.API.RequestTimeout, mux))))))))))))
+}
+
+func handle(mux *http.ServeMux, path, method string, next http.HandlerFunc) {
+	mux.HandleFunc(path, api.InstrumentRoute(path, methodGuard(method, next)))
+}
+
+func methodGuard(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func ioCloser(c io.Closer) func(ctx context.Context) error {
+	return func(context.Context) error {
+		return c.Close()
+	}
+}
@@ -0,0 +1,805 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/planner"
+	"aichatplayers/internal/version"
+)
+
+type closeRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *closeRecorder) closer(name string) *fakeCloser {
+	return &fakeCloser{name: name, recorder: r}
+}
+
+func (r *closeRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = append(r.order, name)
+}
+
+type fakeCloser struct {
+	name     string
+	recorder *closeRecorder
+}
+
+func (c *fakeCloser) Close() error {
+	c.recorder.record(c.name)
+	return nil
+}
+
+type fakeLLM struct {
+	*fakeCloser
+}
+
+func (fakeLLM) Enabled() bool { return false }
+
+func (fakeLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	return "", nil
+}
+
+func TestAppCloseIsOrderedAndIdempotent(t *testing.T) {
+	before := runtime.NumGoroutine()
+	recorder := &closeRecorder{}
+	application, err := New(config.Config{}, Deps{
+		InitLogging: func(config.ElasticConfig) (io.Closer, io.Closer, error) {
+			return recorder.closer("log_file"), recorder.closer("elastic_logger"), nil
+		},
+		StartLLMServer: func(config.LLMConfig) (io.Closer, error) {
+			return recorder.closer("llm_server"), nil
+		},
+		NewLLM: func(config.LLMConfig) (planner.LLMGenerator, error) {
+			return fakeLLM{recorder.closer("llm_client")}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	application.OnClose("http_server", func(context.Context) error {
+		recorder.record("http_server")
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := application.Close(context.Background()); err != nil {
+			t.Fatalf("Close() #%d error: %v", i+1, err)
+		}
+	}
+
+	want := []string{"http_server", "llm_client", "llm_server", "elastic_logger", "log_file"}
+	if len(recorder.order) != len(want) {
+		t.Fatalf("close order = %v, want %v", recorder.order, want)
+	}
+	for i := range want {
+		if recorder.order[i] != want[i] {
+			t.Fatalf("close order = %v, want %v", recorder.order, want)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("goroutines leaked: before=%d after=%d", before, after)
+	}
+}
+
+func TestAppCloseTimesOutSlowCloser(t *testing.T) {
+	application := &App{}
+	application.closers.timeout = 20 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	application.OnClose("slow", func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	if err := application.Close(context.Background()); err == nil {
+		t.Fatal("expected timeout error for slow closer")
+	}
+}
+
+type slowLLM struct {
+	*fakeCloser
+	started chan struct{}
+}
+
+func (slowLLM) Enabled() bool { return true }
+
+func (l slowLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	close(l.started)
+	select {
+	case <-time.After(200 * time.Millisecond):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	l.recorder.record("generated")
+	return "siema, kto gra?", nil
+}
+
+func TestAppCloseDrainsInFlightPlan(t *testing.T) {
+	recorder := &closeRecorder{}
+	generator := slowLLM{fakeCloser: recorder.closer("llm_client"), started: make(chan struct{})}
+	application, err := New(config.Config{API: config.APIConfig{ShutdownDrain: 5 * time.Second}}, Deps{
+		NewLLM: func(config.LLMConfig) (planner.LLMGenerator, error) { return generator, nil },
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &http.Server{Handler: application.Handler}
+	go func() { _ = server.Serve(listener) }()
+	application.OnDrain("http_server", server.Shutdown)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		body := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,"bots":[{"bot_id":"bot-1","online":true}],"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"ktoś gra?"}],"settings":{"reply_chance":1,"max_actions":1}}`
+		resp, err := http.Post("http://"+listener.Addr().String()+"/v1/plan", "application/json", strings.NewReader(body))
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		done <- result{status: resp.StatusCode, body: string(data)}
+	}()
+
+	select {
+	case <-generator.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("plan never reached the LLM")
+	}
+	if err := application.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	got := <-done
+	if got.err != nil || got.status != http.StatusOK || !strings.Contains(got.body, "siema, kto gra?") {
+		t.Fatalf("in-flight plan: status %d body %s err %v", got.status, got.body, got.err)
+	}
+	want := []string{"generated", "llm_client"}
+	if len(recorder.order) != len(want) || recorder.order[0] != want[0] || recorder.order[1] != want[1] {
+		t.Fatalf("order = %v, want %v", recorder.order, want)
+	}
+
+	ready := httptest.NewRecorder()
+	application.Handler.ServeHTTP(ready, httptest.NewRequest("GET", "/readyz", nil))
+	if ready.Code != http.StatusServiceUnavailable || !strings.Contains(ready.Body.String(), `"status":"draining"`) {
+		t.Fatalf("readyz after Close: status %d body %s", ready.Code, ready.Body.String())
+	}
+}
+
+func TestPlanAppliesPerBotLLMOverrides(t *testing.T) {
+	var mu sync.Mutex
+	temperatures := map[string]float64{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Prompt      string  `json:"prompt"`
+			Temperature float64 `json:"temperature"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		for _, name := range []string{"Chaos", "Helper"} {
+			if strings.Contains(payload.Prompt, name) {
+				mu.Lock()
+				temperatures[name] = payload.Temperature
+				mu.Unlock()
+			}
+		}
+		_, _ = w.Write([]byte(`{"content":"spoko, zaraz pomoge"}`))
+	}))
+	defer backend.Close()
+
+	application, err := New(config.Config{}, Deps{
+		NewLLM: func(config.LLMConfig) (planner.LLMGenerator, error) {
+			return llm.NewClient(config.LLMConfig{ServerURL: backend.URL, Temperature: 0.6, Timeout: time.Second})
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	body := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,` +
+		`"bots":[{"bot_id":"chaos","name":"Chaos","online":true,"llm_overrides":{"temperature":1.4}},{"bot_id":"helper","name":"Helper","online":true,"llm_overrides":{"temperature":0.2}}],` +
+		`"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"jak zrobic portal do netheru?"}],` +
+		`"required_bot_ids":["chaos","helper"],"settings":{"reply_chance":1,"max_actions":2}}`
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if temperatures["Chaos"] != 1.4 || temperatures["Helper"] != 0.2 {
+		t.Fatalf("temperatures = %v, want Chaos 1.4 and Helper 0.2 (body %s)", temperatures, recorder.Body.String())
+	}
+}
+
+func TestPlanPropagatesTraceToLLMServer(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	traceparents := make(chan string, 4)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{"content":"spoko, zaraz pomoge"}`))
+	}))
+	defer backend.Close()
+
+	application, err := New(config.Config{}, Deps{
+		NewLLM: func(config.LLMConfig) (planner.LLMGenerator, error) {
+			return llm.NewClient(config.LLMConfig{ServerURL: backend.URL, Timeout: time.Second})
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	body := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,` +
+		`"bots":[{"bot_id":"helper","name":"Helper","online":true}],` +
+		`"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"jak zrobic portal do netheru?"}],` +
+		`"required_bot_ids":["helper"],"settings":{"reply_chance":1,"max_actions":1}}`
+	req := httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+
+	select {
+	case got := <-traceparents:
+		parts := strings.Split(got, "-")
+		if len(parts) != 4 || parts[1] != "4bf92f3577b34da6a3ce929d0e0e4736" || parts[2] == "00f067aa0ba902b7" {
+			t.Fatalf("outbound traceparent = %q, want the incoming trace with this service's span", got)
+		}
+		if !strings.Contains(logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id="+parts[2]) {
+			t.Fatalf("request logs lack the trace: %s", logs.String())
+		}
+	default:
+		t.Fatal("LLM server was not called")
+	}
+}
+
+func TestStrictValidationAndSchemaRoutes(t *testing.T) {
+	application, err := New(config.Config{API: config.APIConfig{StrictValidation: true}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "health reports llm state", method: "GET", path: "/healthz", wantStatus: http.StatusOK, wantBody: `"llm_state":"disabled"`},
+		{name: "schema export", method: "GET", path: "/v1/schemas/register", wantStatus: http.StatusOK, wantBody: `"$id":"/v1/schemas/register"`},
+		{name: "unknown schema", method: "GET", path: "/v1/schemas/nope", wantStatus: http.StatusNotFound},
+		{name: "valid register", method: "POST", path: "/v1/bots/register", body: `{"server_id":"s","bots":[{"bot_id":"b"}]}`, wantStatus: http.StatusOK},
+		{name: "invalid register", method: "POST", path: "/v1/bots/register", body: `{"server_id":"s","bots":[{"bot_id":""}]}`, wantStatus: http.StatusUnprocessableEntity, wantBody: `"path":"/bots/0/bot_id"`},
+		{name: "malformed json", method: "POST", path: "/v1/plan", body: `{"server":`, wantStatus: http.StatusBadRequest},
+		{name: "reload topics", method: "POST", path: "/v1/admin/topics/reload", wantStatus: http.StatusOK, wantBody: `"status":"reloaded"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			application.Handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Fatalf("body %s does not contain %s", recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPlanValidationErrors(t *testing.T) {
+	application, err := New(config.Config{}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid", body: `{"server":{"server_id":"s"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}]}`, wantStatus: http.StatusOK},
+		{name: "missing time", body: `{"server":{"server_id":"s"},"bots":[{"bot_id":"b"}]}`, wantStatus: http.StatusBadRequest, wantBody: `{"error":"validation_failed","details":[{"path":"/time_ms"`},
+		{name: "missing bot id", body: `{"server":{"server_id":"s"},"time_ms":1,"bots":[{"name":"Kuba"}]}`, wantStatus: http.StatusBadRequest, wantBody: `"path":"/bots/0/bot_id"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan", strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Fatalf("body %s does not contain %s", recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+type readyLLM struct{}
+
+func (readyLLM) Enabled() bool { return true }
+
+func (readyLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	return "siema", nil
+}
+
+func (readyLLM) Close() error { return nil }
+
+type fakeElastic struct{}
+
+func (fakeElastic) Close() error { return nil }
+
+func (fakeElastic) Status() logging.ElasticStatus {
+	return logging.ElasticStatus{QueueDepth: 7, LastError: "connection refused", LastErrorAt: time.UnixMilli(1712345000000), Spilled: 3, Replayed: 2}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		requireLLM bool
+		llm        planner.LLMGenerator
+		wantStatus int
+		wantBody   []string
+	}{
+		{name: "heuristics only", llm: fakeLLM{}, wantStatus: http.StatusOK, wantBody: []string{`"status":"ready"`, `"llm":{"enabled":false,"available":false,"state":"disabled"}`}},
+		{name: "llm required but disabled", requireLLM: true, llm: fakeLLM{}, wantStatus: http.StatusServiceUnavailable, wantBody: []string{`"status":"not_ready"`}},
+		{name: "llm required and enabled", requireLLM: true, llm: readyLLM{}, wantStatus: http.StatusOK, wantBody: []string{`"available":true,"state":"enabled"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Deps{
+				InitLogging: func(config.ElasticConfig) (io.Closer, io.Closer, error) { return nil, fakeElastic{}, nil },
+				NewLLM:      func(config.LLMConfig) (planner.LLMGenerator, error) { return tt.llm, nil },
+			}
+			application, err := New(config.Config{API: config.APIConfig{ReadinessRequireLLM: tt.requireLLM}}, deps)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+			application.Planner.RegisterBots("srv-1", []models.BotProfile{{BotID: "bot-1"}, {BotID: "bot-2"}})
+
+			recorder := httptest.NewRecorder()
+			application.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			want := append(tt.wantBody,
+				`"elastic":{"enabled":true,"queue_depth":7,"last_error":"connection refused","last_error_ms":1712345000000,"spilled":3,"replayed":2}`,
+				`"planner":{"registered_servers":1,"registered_bots":2}`,
+			)
+			for _, fragment := range want {
+				if !strings.Contains(recorder.Body.String(), fragment) {
+					t.Fatalf("body %s does not contain %s", recorder.Body.String(), fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanRateLimit(t *testing.T) {
+	application, err := New(config.Config{API: config.APIConfig{PlanRateLimitPerMinute: 60, PlanRateBurst: 1}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	plan := func(serverID string) *httptest.ResponseRecorder {
+		body := `{"server":{"server_id":"` + serverID + `"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}]}`
+		recorder := httptest.NewRecorder()
+		application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body)))
+		return recorder
+	}
+
+	if recorder := plan("srv-1"); recorder.Code != http.StatusOK {
+		t.Fatalf("first plan status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	recorder := plan("srv-1")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("second plan status = %d, want 429 (body %s)", recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), `"error":"rate_limited","retry_after_ms":`) || recorder.Header().Get("Retry-After") == "" {
+		t.Fatalf("unexpected rate limit response %s headers %v", recorder.Body.String(), recorder.Header())
+	}
+	if recorder := plan("srv-2"); recorder.Code != http.StatusOK {
+		t.Fatalf("other server status = %d, want 200", recorder.Code)
+	}
+}
+
+func TestAsyncPlanRoundTrip(t *testing.T) {
+	application, err := New(config.Config{}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		application.Handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	invalid := serve("POST", "/v1/plan/async", `{"server":{"server_id":"s"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"callback_url":"ftp://plugin"}`)
+	if invalid.Code != http.StatusBadRequest || !strings.Contains(invalid.Body.String(), `"path":"/callback_url"`) {
+		t.Fatalf("invalid callback: status %d body %s", invalid.Code, invalid.Body.String())
+	}
+
+	accepted := serve("POST", "/v1/plan/async", `{"request_id":"req-async","server":{"server_id":"s"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}]}`)
+	if accepted.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 (body %s)", accepted.Code, accepted.Body.String())
+	}
+	var status models.AsyncPlanStatus
+	if err := json.Unmarshal(accepted.Body.Bytes(), &status); err != nil || status.PlanID == "" || status.Status != "pending" {
+		t.Fatalf("unexpected accepted body %s (err %v)", accepted.Body.String(), err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		polled := serve("GET", "/v1/plan/"+status.PlanID, "")
+		if polled.Code != http.StatusOK {
+			t.Fatalf("poll status = %d (body %s)", polled.Code, polled.Body.String())
+		}
+		if strings.Contains(polled.Body.String(), `"status":"done"`) {
+			if !strings.Contains(polled.Body.String(), `"request_id":"req-async"`) {
+				t.Fatalf("unexpected result %s", polled.Body.String())
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plan not done in time, last body %s", polled.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if unknown := serve("GET", "/v1/plan/nope", ""); unknown.Code != http.StatusNotFound {
+		t.Fatalf("unknown plan status = %d, want 404", unknown.Code)
+	}
+}
+
+func TestPlanBatch(t *testing.T) {
+	application, err := New(config.Config{API: config.APIConfig{PlanBatchMax: 3}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   []string
+	}{
+		{
+			name: "mixed entries keep order",
+			body: `{"requests":[` +
+				`{"request_id":"req-a","server":{"server_id":"a"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}]},` +
+				`{"request_id":"req-b","server":{"server_id":"b"},"bots":[{"bot_id":"b"}]},` +
+				`{"request_id":"req-c","server":{"server_id":"c"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"unknown":1}` +
+				`]}`,
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				`{"responses":[{"request_id":"req-a",`,
+				`{"error":"validation_failed","details":[{"path":"/time_ms"`,
+				`{"error":"invalid_json"}]}`,
+			},
+		},
+		{name: "empty batch", body: `{"requests":[]}`, wantStatus: http.StatusOK, wantBody: []string{`{"responses":[]}`}},
+		{name: "too large", body: `{"requests":[{},{},{},{}]}`, wantStatus: http.StatusRequestEntityTooLarge, wantBody: []string{`"error":"batch_too_large"`}},
+		{name: "malformed envelope", body: `{"requests":`, wantStatus: http.StatusBadRequest, wantBody: []string{`"error":"invalid_json"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan/batch", strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			for _, fragment := range tt.wantBody {
+				if !strings.Contains(recorder.Body.String(), fragment) {
+					t.Fatalf("body %s does not contain %s", recorder.Body.String(), fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanDryRunQuery(t *testing.T) {
+	application, err := New(config.Config{}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	body := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"siema"}],"settings":{"reply_chance":1}}`
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan?dry_run=1", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	for _, fragment := range []string{`"message":"[dry-run greeting reply]"`, `"reason":"dry_run_greeting"`, `"dry_run":true`} {
+		if !strings.Contains(recorder.Body.String(), fragment) {
+			t.Fatalf("body %s does not contain %s", recorder.Body.String(), fragment)
+		}
+	}
+}
+
+func TestNewRejectsInvalidPromptTemplate(t *testing.T) {
+	path := t.TempDir() + "/prompt.tmpl"
+	if err := os.WriteFile(path, []byte(`{{define "user"}}{{.Task}{{end}}`), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	_, err := New(config.Config{LLM: config.LLMConfig{PromptTemplatePath: path}}, Deps{})
+	if err == nil || !strings.Contains(err.Error(), "LLM_PROMPT_TEMPLATE_PATH") {
+		t.Fatalf("expected prompt template error, got %v", err)
+	}
+}
+
+func TestReloadConfigRoute(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	application, err := New(cfg, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	t.Setenv("LLM_TEMPERATURE", "1.3")
+	t.Setenv("LLM_MODEL_PATH", "/models/other.gguf")
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/admin/reload", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	var response models.ConfigReloadResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if strings.Join(response.Applied, ",") != "LLM.Temperature" || strings.Join(response.PendingRestart, ",") != "LLM.ModelPath" {
+		t.Fatalf("response = %+v", response)
+	}
+	if application.Config.LLM.Temperature != 1.3 || application.Config.LLM.ModelPath != cfg.LLM.ModelPath {
+		t.Fatalf("running config = %+v", application.Config.LLM)
+	}
+
+	t.Setenv("LLM_TEMPERATURE", "hot")
+	recorder = httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/admin/reload", nil))
+	if recorder.Code != http.StatusUnprocessableEntity || application.Config.LLM.Temperature != 1.3 {
+		t.Fatalf("invalid reload: status = %d temperature = %v", recorder.Code, application.Config.LLM.Temperature)
+	}
+}
+
+func TestPlanRejectsOversizedBody(t *testing.T) {
+	application, err := New(config.Config{HTTP: config.HTTPConfig{BodyLimit: 256}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	body := `{"server":{"server_id":"srv-1"},"chat":[{"sender":"Steve","message":"` + strings.Repeat("a", 512) + `"}]}`
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body)))
+	if recorder.Code != http.StatusRequestEntityTooLarge || !strings.Contains(recorder.Body.String(), `"error":"payload_too_large"`) {
+		t.Fatalf("status = %d body = %s, want 413 payload_too_large", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestDumpConfigRoute(t *testing.T) {
+	t.Setenv("API_TOKENS", "admin-token-1234")
+	t.Setenv("LLM_SERVER_API_KEY", "sk-secret-abcd")
+	t.Setenv("LLM_TEMPERATURE", "0.8")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	application, err := New(cfg, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/admin/config", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d, want 401", recorder.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer admin-token-1234")
+	recorder = httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	body := recorder.Body.String()
+	if strings.Contains(body, "sk-secret-abcd") || strings.Contains(body, "admin-token-1234") {
+		t.Fatalf("secret leaked in %s", body)
+	}
+	var response models.ConfigResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.Config["LLM"]["ServerAPIKey"] != "****abcd" || response.Config["LLM"]["Temperature"] != 0.8 {
+		t.Fatalf("LLM = %+v", response.Config["LLM"])
+	}
+	if response.Sources["LLM.Temperature"] != config.SourceEnv || response.Sources["LLM.TopP"] != config.SourceDefault {
+		t.Fatalf("sources = %+v", response.Sources)
+	}
+}
+
+func TestHealthzReportsBuildInfo(t *testing.T) {
+	defer func(v, commit string) { version.Version, version.Commit = v, commit }(version.Version, version.Commit)
+	version.Version, version.Commit = "v1.4.0", "0def415"
+
+	application, err := New(config.Config{}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+	application.api.StartedAt = time.Now().Add(-90 * time.Second)
+
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %s)", recorder.Code, recorder.Body.String())
+	}
+	var response models.HealthResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.Status != "ok" || response.Version != "v1.4.0" || response.Commit != "0def415" || response.BuildDate == "" {
+		t.Fatalf("response = %+v", response)
+	}
+	if response.UptimeS < 90 || response.UptimeS > 95 {
+		t.Fatalf("uptime_s = %d, want about 90", response.UptimeS)
+	}
+}
+
+func TestPlanSchemaVersions(t *testing.T) {
+	const request = `{"server":{"server_id":"%s"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"chat":[{"ts_ms":1712344999000,"sender":"Steve","sender_type":"PLAYER","message":"jak zrobic portal?"}],"settings":{"reply_chance":1,"allow_whispers":true}%s}`
+	tests := []struct {
+		name       string
+		version    string
+		wantStatus int
+		wantBody   []string
+		denyBody   []string
+	}{
+		{name: "omitted is v1", wantStatus: http.StatusOK, wantBody: []string{`"reason":"helpful_hint_whisper"`, `"target_player":"Steve"`}, denyBody: []string{`"schema_version"`}},
+		{name: "v1", version: `,"schema_version":1`, wantStatus: http.StatusOK, wantBody: []string{`"reason":"helpful_hint_whisper"`}, denyBody: []string{`"schema_version"`}},
+		{name: "v2", version: `,"schema_version":2`, wantStatus: http.StatusOK, wantBody: []string{`"schema_version":2`, `"reason":"helpful_hint"`, `"visibility":"WHISPER"`, `"target_player":"Steve"`}},
+		{name: "future version", version: `,"schema_version":3`, wantStatus: http.StatusBadRequest, wantBody: []string{`{"error":"unsupported_schema_version","schema_version":3,"max_schema_version":2}`}},
+	}
+	for _, strict := range []bool{false, true} {
+		application, err := New(config.Config{API: config.APIConfig{StrictValidation: strict}}, Deps{})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		for i, tt := range tests {
+			t.Run(fmt.Sprintf("%s strict=%t", tt.name, strict), func(t *testing.T) {
+				body := fmt.Sprintf(request, fmt.Sprintf("srv-%t-%d", strict, i), tt.version)
+				recorder := httptest.NewRecorder()
+				application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body)))
+				if recorder.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+				}
+				for _, fragment := range tt.wantBody {
+					if !strings.Contains(recorder.Body.String(), fragment) {
+						t.Fatalf("body %s does not contain %s", recorder.Body.String(), fragment)
+					}
+				}
+				for _, fragment := range tt.denyBody {
+					if strings.Contains(recorder.Body.String(), fragment) {
+						t.Fatalf("body %s contains %s", recorder.Body.String(), fragment)
+					}
+				}
+			})
+		}
+	}
+
+	application, err := New(config.Config{}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	batch := `{"requests":[{"server":{"server_id":"a"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"schema_version":2},{"server":{"server_id":"b"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}],"schema_version":9}]}`
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/v1/plan/batch", strings.NewReader(batch)))
+	for _, fragment := range []string{`{"responses":[{"schema_version":2,`, `{"error":"unsupported_schema_version","max_schema_version":2}`} {
+		if !strings.Contains(recorder.Body.String(), fragment) {
+			t.Fatalf("batch body %s does not contain %s", recorder.Body.String(), fragment)
+		}
+	}
+}
+
+func TestPlanContentNegotiation(t *testing.T) {
+	application, err := New(config.Config{HTTP: config.HTTPConfig{BodyLimit: 4096}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	body := `{"server":{"server_id":"srv-1"},"time_ms":1712345000000,"bots":[{"bot_id":"b"}]}`
+
+	plain := httptest.NewRequest("POST", "/v1/plan", strings.NewReader(body))
+	plain.Header.Set("Content-Type", "text/plain")
+	recorder := httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, plain)
+	if recorder.Code != http.StatusUnsupportedMediaType || !strings.Contains(recorder.Body.String(), `"error":"unsupported_media_type"`) {
+		t.Fatalf("text/plain: status %d body %s", recorder.Code, recorder.Body.String())
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(body))
+	writer.Close()
+	gzipped := httptest.NewRequest("POST", "/v1/plan", &compressed)
+	gzipped.Header.Set("Content-Type", "application/json")
+	gzipped.Header.Set("Content-Encoding", "gzip")
+	gzipped.Header.Set("Accept-Encoding", "gzip")
+	recorder = httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, gzipped)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip: status %d headers %v", recorder.Code, recorder.Header())
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	var response models.PlanResponse
+	if err := json.NewDecoder(reader).Decode(&response); err != nil || response.Debug.ChosenStrategy == "" {
+		t.Fatalf("decode gzipped response: %+v (err %v)", response, err)
+	}
+
+	compressed.Reset()
+	writer = gzip.NewWriter(&compressed)
+	writer.Write(bytes.Repeat([]byte(" "), 1<<20))
+	writer.Close()
+	bomb := httptest.NewRequest("POST", "/v1/plan", &compressed)
+	bomb.Header.Set("Content-Encoding", "gzip")
+	recorder = httptest.NewRecorder()
+	application.Handler.ServeHTTP(recorder, bomb)
+	if recorder.Code != http.StatusRequestEntityTooLarge || !strings.Contains(recorder.Body.String(), `"limit_bytes":4096`) {
+		t.Fatalf("gzip bomb: status %d body %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/logging"
+)
+
+const defaultCloserTimeout = 5 * time.Second
+
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+	once sync.Once
+	err  error
+}
+
+type closerRegistry struct {
+	mu      sync.Mutex
+	closers []*closer
+	timeout time.Duration
+}
+
+func (r *closerRegistry) add(name string, fn func(ctx context.Context) error) {
+	if fn == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closers = append(r.closers, &closer{name: name, fn: fn})
+}
+
+func (r *closerRegistry) closeAll(ctx context.Context) error {
+	r.mu.Lock()
+	closers := make([]*closer, len(r.closers))
+	copy(closers, r.closers)
+	timeout := r.timeout
+	r.mu.Unlock()
+	if timeout <= 0 {
+		timeout = defaultCloserTimeout
+	}
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].close(ctx, timeout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *closer) close(ctx context.Context, timeout time.Duration) error {
+	c.once.Do(func() {
+		closeCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- c.fn(closeCtx)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				c.err = fmt.Errorf("close %s: %w", c.name, err)
+			}
+		case <-closeCtx.Done():
+			c.err = fmt.Errorf("close %s: %w", c.name, closeCtx.Err())
+		}
+		if c.err != nil {
+			logging.Errorf("app_close_failed name=%s duration_ms=%d error=%v", c.name, time.Since(start).Milliseconds(), c.err)
+			return
+		}
+		logging.Infof("app_closed name=%s duration_ms=%d", c.name, time.Since(start).Milliseconds())
+	})
+	return c.err
+}
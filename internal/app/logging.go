@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+func initLogging(elasticCfg config.ElasticConfig) (io.Closer, io.Closer, error) {
+	logDir := strings.TrimSpace(os.Getenv("LOG_DIR"))
+	if logDir == "" {
+		logDir = "logs"
+	}
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create logs dir: %w", err)
+	}
+	logTimestamp := time.Now().Unix()
+	logPath := filepath.Join(logDir, fmt.Sprintf("logs_%d", logTimestamp))
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open log file: %w", err)
+	}
+	stdoutLevel := logging.LevelInfo
+	if level, ok := logging.ParseLevel(os.Getenv("LOG_LEVEL")); ok {
+		stdoutLevel = level
+	}
+	fileLevel := stdoutLevel
+	if raw := strings.TrimSpace(os.Getenv("LOG_FILE_LEVEL")); raw != "" {
+		if level, ok := logging.ParseLevel(raw); ok {
+			fileLevel = level
+		}
+	}
+	minLevel := stdoutLevel
+	if fileLevel < minLevel {
+		minLevel = fileLevel
+	}
+	elasticLevel := stdoutLevel
+	if raw := strings.TrimSpace(os.Getenv("ELASTIC_LOG_LEVEL")); raw != "" {
+		if level, ok := logging.ParseLevel(raw); ok {
+			elasticLevel = level
+		}
+	}
+	if elasticLevel < minLevel {
+		minLevel = elasticLevel
+	}
+	logging.SetLevel(minLevel)
+	var elasticLogger *logging.ElasticLogger
+	spillFile := elasticCfg.SpillFile
+	if spillFile == "" {
+		spillFile = filepath.Join(logDir, "elastic-spill.ndjson")
+	}
+	if elasticCfg.URL != "" && elasticCfg.Index != "" {
+		elasticLogger, err = logging.NewElasticLogger(elasticCfg.URL, elasticCfg.Index, elasticCfg.APIKey, elasticCfg.VerifyCert, logging.ElasticBulk{
+			Enabled:       elasticCfg.Bulk,
+			MaxEntries:    elasticCfg.BulkSize,
+			FlushInterval: elasticCfg.BulkFlushInterval,
+			DataStream:    elasticCfg.DataStream,
+		}, logging.ElasticRetry{
+			MaxRetries: elasticCfg.RetryMax,
+			Backoff:    elasticCfg.RetryBackoff,
+			SpillFile:  spillFile,
+		})
+		if err != nil {
+			_ = logFile.Close()
+			return nil, nil, fmt.Errorf("init elastic logger: %w", err)
+		}
+	}
+	outputs := []io.Writer{logging.NewSplitWriter(os.Stdout, stdoutLevel, logFile, fileLevel)}
+	if elasticLogger != nil {
+		outputs = append(outputs, logging.NewElasticWriter(elasticLogger, elasticLevel))
+	}
+	log.SetOutput(io.MultiWriter(outputs...))
+	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC)
+	logging.Infof("elastic_logging_config url=%s index=%s api_key_set=%t verify_cert=%t", elasticCfg.URL, elasticCfg.Index, elasticCfg.APIKey != "", elasticCfg.VerifyCert)
+	if elasticCfg.URL == "" || elasticCfg.Index == "" {
+		logging.Warnf("elastic_logging_disabled missing_url=%t missing_index=%t", elasticCfg.URL == "", elasticCfg.Index == "")
+	}
+	logging.Infof("logging initialized path=%s stdout_level=%s file_level=%s", logPath, stdoutLevel, fileLevel)
+	if elasticLogger != nil {
+		logging.Infof("elastic_logging_enabled url=%s index=%s verify_cert=%t", elasticCfg.URL, elasticCfg.Index, elasticCfg.VerifyCert)
+	}
+	if elasticLogger == nil {
+		return logFile, nil, nil
+	}
+	return logFile, elasticLogger, nil
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"aichatplayers/internal/config"
+)
+
+// serverTLSConfig loads the server certificate and the optional client CA;
+// it returns nil when TLS is off. Client certificates are verified when
+// presented and required on /v1/ by api.RequireClientCert, so probes can
+// reach /healthz and /readyz without one.
+func serverTLSConfig(cfg config.HTTPConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP_TLS_CERT_FILE %s / HTTP_TLS_KEY_FILE %s: %w", cfg.TLSCertFile, cfg.TLSKeyFile, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP_TLS_CLIENT_CA_FILE %s: %w", cfg.TLSClientCAFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("HTTP_TLS_CLIENT_CA_FILE %s: no PEM certificates found", cfg.TLSClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA
+// when parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, 0)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCert(t, "aichat", ca, x509.ExtKeyUsageServerAuth).write(t, dir, "server")
+	plugin := newTestCert(t, "minecraft-plugin", ca, x509.ExtKeyUsageClientAuth)
+	otherCA := newTestCert(t, "other-ca", nil, 0)
+	stranger := newTestCert(t, "stranger", otherCA, x509.ExtKeyUsageClientAuth)
+
+	application, err := New(config.Config{HTTP: config.HTTPConfig{
+		TLSCertFile:     serverCertFile,
+		TLSKeyFile:      serverKeyFile,
+		TLSClientCAFile: caFile,
+	}}, Deps{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer application.Close(context.Background())
+	server := httptest.NewUnstartedServer(application.Handler)
+	server.TLS = application.TLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(path string, clientCert *testCert) (int, error) {
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			tlsConfig.Certificates = []tls.Certificate{clientCert.tlsCertificate()}
+		}
+		client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		cert       *testCert
+		wantStatus int
+	}{
+		{name: "plugin certificate", path: "/v1/schemas/plan", cert: plugin, wantStatus: http.StatusOK},
+		{name: "healthz without certificate", path: "/healthz", wantStatus: http.StatusOK},
+		{name: "api without certificate", path: "/v1/schemas/plan", wantStatus: http.StatusForbidden},
+		{name: "certificate from another CA", path: "/v1/schemas/plan", cert: stranger, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := get(tt.path, tt.cert)
+			if err != nil || status != tt.wantStatus {
+				t.Fatalf("status = %d err = %v, want %d", status, err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNewRejectsBadTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, 0)
+	caFile, caKeyFile := ca.write(t, dir, "ca")
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	tests := []struct {
+		name string
+		cfg  config.HTTPConfig
+		want string
+	}{
+		{name: "missing cert", cfg: config.HTTPConfig{TLSCertFile: filepath.Join(dir, "nope.crt"), TLSKeyFile: caKeyFile}, want: "HTTP_TLS_CERT_FILE"},
+		{name: "missing client ca", cfg: config.HTTPConfig{TLSCertFile: caFile, TLSKeyFile: caKeyFile, TLSClientCAFile: filepath.Join(dir, "nope.crt")}, want: "HTTP_TLS_CLIENT_CA_FILE"},
+		{name: "client ca without pem", cfg: config.HTTPConfig{TLSCertFile: caFile, TLSKeyFile: caKeyFile, TLSClientCAFile: notPEM}, want: "no PEM certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.Config{HTTP: tt.cfg}, Deps{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("New() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,19 @@ package config
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+
+	"aichatplayers/internal/logging"
 )
 
 const (
@@ -20,13 +27,108 @@ const (
 	defaultLLMMaxResponseChars     = 80
 	defaultLLMMaxResponseWords     = 0
 	defaultLLMServerStartupTimeout = 60 * time.Second
+	defaultLLMMaxConcurrentCLI     = 1
+	defaultLLMMaxConcurrentServer  = 4
+	defaultLLMPressureQueueDepth   = 2
+	defaultLLMMaxRetries           = 1
+	defaultLLMBreakerFailures      = 3
+	defaultLLMBreakerCooldown      = 30 * time.Second
+	defaultLLMBackendRetry         = 30 * time.Second
+	defaultLLMServerMaxRestarts    = 3
+	defaultLLMCacheTTL             = 10 * time.Second
+	defaultLLMHealthPollInterval   = 5 * time.Second
+	defaultEngagementCooldown      = 10 * time.Minute
+	defaultRecentMessageLimit      = 5
+	defaultChatMessageMaxChars     = 256
+	defaultChatClockSkew           = 2 * time.Second
+	defaultPlannerStateInterval    = 30 * time.Second
+	defaultPlanRateLimitPerMinute  = 120
+	defaultPlanRateBurst           = 20
+	defaultRequestTimeoutMargin    = 500 * time.Millisecond
+	defaultAsyncWorkers            = 4
+	defaultAsyncResultTTL          = 5 * time.Minute
+	defaultPlanBatchMax            = 10
+	defaultShutdownDrain           = 10 * time.Second
+	defaultHTTPListenAddr          = ":8090"
+	defaultHTTPBodyLimitBytes      = 1 << 20
+	defaultHTTPReadTimeout         = 5 * time.Second
+	defaultHTTPWriteTimeout        = 10 * time.Second
+	defaultHTTPIdleTimeout         = 30 * time.Second
+	defaultElasticBulkSize         = 100
+	defaultElasticBulkFlush        = time.Second
+	defaultElasticRetryMax         = 3
+	defaultElasticRetryBackoff     = 500 * time.Millisecond
 	defaultLLMChatHistoryLimit     = 6
 	defaultLLMPromptSystem         = "You are a Minecraft player chat bot roleplaying as a normal player.\nYou have NO memory and NO access to anything except the provided CHAT LOG and BOT/SERVER info.\nDo NOT invent facts, backstory, previous events, or personal memories.\nDo NOT mention being an AI, a model, or system instructions."
 )
 
+const LanguagePlaceholder = "{language}"
+
+const (
+	ServerAPILlamaCpp          = "llamacpp"
+	ServerAPIOpenAIChat        = "openai-chat"
+	ServerAPIOpenAICompletions = "openai-completions"
+)
+
+// GrammarBuiltin selects the built-in GBNF grammar (one chat line or
+// __SILENCE__) for llama.cpp backends.
+const GrammarBuiltin = "builtin"
+
 type Config struct {
 	LLM     LLMConfig
 	Elastic ElasticConfig
+	API     APIConfig
+	Planner PlannerConfig
+	HTTP    HTTPConfig
+	// Sources tells, per Section.Field, whether Load took the value from
+	// the environment, a file or the default.
+	Sources map[string]string
+}
+
+// HTTPConfig configures the HTTP server; zero timeouts disable them.
+type HTTPConfig struct {
+	ListenAddr   string
+	BodyLimit    int64
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// TLSCertFile and TLSKeyFile switch the server to HTTPS. With
+	// TLSClientCAFile, /v1/ requests need a client certificate it signed.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+}
+
+type APIConfig struct {
+	StrictValidation       bool
+	ReadinessRequireLLM    bool
+	Tokens                 []string
+	PlanRateLimitPerMinute int
+	PlanRateBurst          int
+	RequestTimeout         time.Duration
+	AsyncWorkers           int
+	AsyncResultTTL         time.Duration
+	PlanBatchMax           int
+	ShutdownDrain          time.Duration
+	RedactChat             bool
+}
+
+type PlannerConfig struct {
+	EngagementCooldown     time.Duration
+	RecentMessageLimit     int
+	StatePath              string
+	StateInterval          time.Duration
+	TopicKeywordsPath      string
+	ProfanityBlocklistPath string
+	ChatMessageMaxChars    int
+	// ChatClockSkew is how far chat timestamps may lag time_ms before
+	// settings.max_message_age_ms counts the chat as stale.
+	ChatClockSkew time.Duration
+	// PlanDeadline caps how long a plan may generate replies when the
+	// request sets no settings.plan_deadline_ms; 0 means no cap.
+	PlanDeadline time.Duration
+	// QuietHours is nil when BOT_QUIET_HOURS is not set.
+	QuietHours *QuietHours
 }
 
 type ElasticConfig struct {
@@ -34,32 +136,101 @@ type ElasticConfig struct {
 	Index      string
 	APIKey     string
 	VerifyCert bool
+	// Bulk sends log lines through _bulk in batches of BulkSize, flushed at
+	// least every BulkFlushInterval; false posts one document per line.
+	Bulk              bool
+	BulkSize          int
+	BulkFlushInterval time.Duration
+	// DataStream writes with create actions, as data streams require.
+	DataStream bool
+	// Failed sends are retried RetryMax times with doubling backoff, then
+	// spilled to SpillFile (LOG_DIR/elastic-spill.ndjson when empty).
+	RetryMax     int
+	RetryBackoff time.Duration
+	SpillFile    string
+}
+
+// LLMBackend is one LLM server. Empty API, APIKey and Model fall back to
+// LLM_SERVER_API, LLM_SERVER_API_KEY and LLM_SERVER_MODEL.
+type LLMBackend struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	API    string `json:"api"`
+	APIKey string `json:"api_key"`
+	Model  string `json:"model"`
 }
 
 type LLMConfig struct {
-	ModelPath            string
-	ModelsDir            string
-	ServerURL            string
-	ServerCommand        string
-	Command              string
-	MaxRAMMB             int
-	MaxTokens            int
-	MaxResponseChars     int
-	MaxResponseWords     int
+	ModelPath          string
+	ModelsDir          string
+	ServerURL          string
+	ServerCommand      string
+	ServerAPI          string
+	ServerModel        string
+	ServerAPIKey       string
+	ServerAuthHeader   string
+	FaultInjection     string
+	MaxConcurrent      int
+	MaxRetries         int
+	BreakerFailures    int
+	BreakerCooldown    time.Duration
+	PressureQueueDepth int
+	PressureP95        time.Duration
+	// Backends, when set, are tried in order instead of ServerURL alone;
+	// a failed backend is skipped for BackendRetry.
+	Backends     []LLMBackend
+	BackendRetry time.Duration
+	// CacheTTL is how long a response is reused for an identical prompt;
+	// zero or CacheDisabled turns the cache off.
+	CacheTTL      time.Duration
+	CacheDisabled bool
+	// HealthPollInterval is how often /health and /slots of the LLM server
+	// are polled; zero disables the poller.
+	HealthPollInterval time.Duration
+	Command            string
+	MaxRAMMB           int
+	MaxTokens          int
+	MaxResponseChars   int
+	MaxResponseWords   int
+	// StopSequences end generation early; llama.cpp otherwise keeps writing
+	// lines that are thrown away.
+	StopSequences []string
+	// Grammar is empty or GrammarBuiltin; GrammarPath, a GBNF file, wins
+	// over it.
+	Grammar              string
+	GrammarPath          string
 	NumThreads           int
 	CtxSize              int
 	Timeout              time.Duration
 	SoftTimeout          time.Duration
 	ServerStartupTimeout time.Duration
-	Temperature          float64
-	TopP                 float64
-	ChatHistoryLimit     int
-	PromptSystem         string
-	PromptResponseRules  string
+	// GPULayers, Parallel and BatchSize map to --n-gpu-layers, --parallel
+	// and --batch-size; zero leaves the llama.cpp default. ExtraArgs are
+	// appended verbatim after them.
+	GPULayers int
+	Parallel  int
+	BatchSize int
+	ExtraArgs []string
+	// ServerModelCheck asks an already running server which model it serves
+	// and restarts it when that is not ModelPath.
+	ServerModelCheck bool
+	// StateDir holds the managed llama-server state file.
+	StateDir string
+	// ServerMaxRestarts caps how often a crashed managed llama-server is
+	// relaunched within ten minutes.
+	ServerMaxRestarts   int
+	Temperature         float64
+	TopP                float64
+	ChatHistoryLimit    int
+	PromptSystem        string
+	PromptResponseRules string
+	// PromptTemplatePath overrides the embedded prompt template.
+	PromptTemplatePath string
 }
 
 func Load() (Config, error) {
-	if err := loadDotEnv(".env"); err != nil {
+	paths, explicit := envFilePaths()
+	if err := loadEnvFiles(paths, explicit); err != nil {
 		return Config{}, err
 	}
 
@@ -71,26 +242,79 @@ func Load() (Config, error) {
 			ModelsDir:            strings.TrimSpace(os.Getenv("LLM_MODELS_DIR")),
 			ServerURL:            strings.TrimSpace(os.Getenv("LLM_SERVER_URL")),
 			ServerCommand:        strings.TrimSpace(os.Getenv("LLM_SERVER_COMMAND")),
+			ServerAPI:            ServerAPILlamaCpp,
+			ServerModel:          strings.TrimSpace(os.Getenv("LLM_SERVER_MODEL")),
+			ServerAPIKey:         strings.TrimSpace(os.Getenv("LLM_SERVER_API_KEY")),
+			GrammarPath:          strings.TrimSpace(os.Getenv("LLM_GRAMMAR_PATH")),
+			PromptTemplatePath:   strings.TrimSpace(os.Getenv("LLM_PROMPT_TEMPLATE_PATH")),
+			StateDir:             firstNonEmptyEnv("LLM_STATE_DIR", "LOG_DIR"),
+			ServerAuthHeader:     strings.TrimSpace(os.Getenv("LLM_SERVER_AUTH_HEADER")),
+			FaultInjection:       strings.TrimSpace(os.Getenv("LLM_FAULT_INJECTION")),
 			Command:              strings.TrimSpace(os.Getenv("LLM_COMMAND")),
 			MaxRAMMB:             defaultLLMMaxRAMMB,
 			MaxTokens:            defaultLLMMaxTokens,
 			MaxResponseChars:     defaultLLMMaxResponseChars,
 			MaxResponseWords:     defaultLLMMaxResponseWords,
+			StopSequences:        DefaultStopSequences(),
 			NumThreads:           0,
 			CtxSize:              defaultLLMCtxSize,
 			Timeout:              time.Duration(defaultLLMTimeoutMS) * time.Millisecond,
 			ServerStartupTimeout: defaultLLMServerStartupTimeout,
+			ServerMaxRestarts:    defaultLLMServerMaxRestarts,
+			ServerModelCheck:     true,
+			PressureQueueDepth:   defaultLLMPressureQueueDepth,
+			MaxRetries:           defaultLLMMaxRetries,
+			BreakerFailures:      defaultLLMBreakerFailures,
+			BreakerCooldown:      defaultLLMBreakerCooldown,
+			BackendRetry:         defaultLLMBackendRetry,
+			CacheTTL:             defaultLLMCacheTTL,
+			HealthPollInterval:   defaultLLMHealthPollInterval,
 			Temperature:          defaultLLMTemperature,
 			TopP:                 defaultLLMTopP,
 			ChatHistoryLimit:     defaultLLMChatHistoryLimit,
 			PromptSystem:         defaultLLMPromptSystem,
 			PromptResponseRules:  DefaultPromptResponseRules(defaultLLMMaxResponseChars, defaultLLMMaxResponseWords),
 		},
+		API: APIConfig{
+			PlanRateLimitPerMinute: defaultPlanRateLimitPerMinute,
+			PlanRateBurst:          defaultPlanRateBurst,
+			AsyncWorkers:           defaultAsyncWorkers,
+			AsyncResultTTL:         defaultAsyncResultTTL,
+			PlanBatchMax:           defaultPlanBatchMax,
+			ShutdownDrain:          defaultShutdownDrain,
+		},
+		Planner: PlannerConfig{
+			EngagementCooldown:     defaultEngagementCooldown,
+			RecentMessageLimit:     defaultRecentMessageLimit,
+			ChatMessageMaxChars:    defaultChatMessageMaxChars,
+			ChatClockSkew:          defaultChatClockSkew,
+			StatePath:              strings.TrimSpace(os.Getenv("PLANNER_STATE_PATH")),
+			TopicKeywordsPath:      strings.TrimSpace(os.Getenv("TOPIC_KEYWORDS_PATH")),
+			ProfanityBlocklistPath: strings.TrimSpace(os.Getenv("PROFANITY_BLOCKLIST_PATH")),
+			StateInterval:          defaultPlannerStateInterval,
+		},
+		HTTP: HTTPConfig{
+			ListenAddr:   defaultHTTPListenAddr,
+			BodyLimit:    defaultHTTPBodyLimitBytes,
+			ReadTimeout:  defaultHTTPReadTimeout,
+			WriteTimeout: defaultHTTPWriteTimeout,
+			IdleTimeout:  defaultHTTPIdleTimeout,
+
+			TLSCertFile:     strings.TrimSpace(os.Getenv("HTTP_TLS_CERT_FILE")),
+			TLSKeyFile:      strings.TrimSpace(os.Getenv("HTTP_TLS_KEY_FILE")),
+			TLSClientCAFile: strings.TrimSpace(os.Getenv("HTTP_TLS_CLIENT_CA_FILE")),
+		},
 		Elastic: ElasticConfig{
-			URL:        strings.TrimSpace(os.Getenv("ELASTIC_URL")),
-			Index:      strings.TrimSpace(os.Getenv("ELASTIC_INDEX")),
-			APIKey:     strings.TrimSpace(os.Getenv("ELASTIC_API_KEY")),
-			VerifyCert: true,
+			URL:               strings.TrimSpace(os.Getenv("ELASTIC_URL")),
+			Index:             strings.TrimSpace(os.Getenv("ELASTIC_INDEX")),
+			APIKey:            strings.TrimSpace(os.Getenv("ELASTIC_API_KEY")),
+			VerifyCert:        true,
+			Bulk:              true,
+			BulkSize:          defaultElasticBulkSize,
+			BulkFlushInterval: defaultElasticBulkFlush,
+			RetryMax:          defaultElasticRetryMax,
+			RetryBackoff:      defaultElasticRetryBackoff,
+			SpillFile:         strings.TrimSpace(os.Getenv("ELASTIC_SPILL_FILE")),
 		},
 	}
 
@@ -112,6 +336,10 @@ func Load() (Config, error) {
 		cfg.LLM.MaxResponseChars = value
 	}
 
+	if value, ok := os.LookupEnv("LLM_STOP_SEQUENCES"); ok {
+		cfg.LLM.StopSequences = parseStopSequences(value)
+	}
+
 	if value, ok, err := readEnvInt("LLM_MAX_RESPONSE_WORDS"); err != nil {
 		return Config{}, err
 	} else if ok {
@@ -152,6 +380,112 @@ func Load() (Config, error) {
 		cfg.LLM.ServerStartupTimeout = time.Duration(value) * time.Millisecond
 	}
 
+	if value, ok, err := readEnvInt("LLM_GPU_LAYERS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.GPULayers = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_PARALLEL"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.Parallel = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_BATCH_SIZE"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.BatchSize = value
+	}
+
+	if args, err := splitArgs(os.Getenv("LLM_SERVER_EXTRA_ARGS")); err != nil {
+		return Config{}, fmt.Errorf("invalid LLM_SERVER_EXTRA_ARGS: %w", err)
+	} else {
+		cfg.LLM.ExtraArgs = args
+	}
+
+	if value, ok, err := readEnvBool("LLM_SERVER_MODEL_CHECK"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.ServerModelCheck = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_SERVER_MAX_RESTARTS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.ServerMaxRestarts = value
+	}
+
+	backends, err := parseBackends(os.Getenv("LLM_BACKENDS"), readEnvList("LLM_SERVER_URLS"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.LLM.Backends = backends
+
+	if value, ok, err := readEnvInt("LLM_BACKEND_RETRY_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.BackendRetry = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("LLM_MAX_CONCURRENT"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.MaxConcurrent = value
+	} else if cfg.LLM.ServerURL != "" || len(cfg.LLM.Backends) > 0 {
+		cfg.LLM.MaxConcurrent = defaultLLMMaxConcurrentServer
+	} else {
+		cfg.LLM.MaxConcurrent = defaultLLMMaxConcurrentCLI
+	}
+
+	if value, ok, err := readEnvInt("LLM_MAX_RETRIES"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.MaxRetries = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_BREAKER_FAILURES"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.BreakerFailures = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_BREAKER_COOLDOWN_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.BreakerCooldown = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("LLM_CACHE_TTL_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.CacheTTL = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvBool("LLM_CACHE_DISABLED"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.CacheDisabled = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_HEALTH_POLL_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.HealthPollInterval = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("LLM_PRESSURE_QUEUE_DEPTH"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.PressureQueueDepth = value
+	}
+
+	if value, ok, err := readEnvInt("LLM_PRESSURE_P95_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.LLM.PressureP95 = time.Duration(value) * time.Millisecond
+	}
+
 	if value, ok, err := readEnvFloat("LLM_TEMPERATURE"); err != nil {
 		return Config{}, err
 	} else if ok {
@@ -176,6 +510,193 @@ func Load() (Config, error) {
 		cfg.Elastic.VerifyCert = value
 	}
 
+	if value, ok, err := readEnvBool("ELASTIC_BULK"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.Bulk = value
+	}
+
+	if value, ok, err := readEnvInt("ELASTIC_BULK_SIZE"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.BulkSize = value
+	}
+
+	if value, ok, err := readEnvInt("ELASTIC_BULK_FLUSH_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.BulkFlushInterval = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvBool("ELASTIC_DATA_STREAM"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.DataStream = value
+	}
+
+	if value, ok, err := readEnvInt("ELASTIC_RETRY_MAX"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.RetryMax = value
+	}
+
+	if value, ok, err := readEnvInt("ELASTIC_RETRY_BACKOFF_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Elastic.RetryBackoff = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("ENGAGEMENT_COOLDOWN_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.EngagementCooldown = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("RECENT_MESSAGE_LIMIT"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.RecentMessageLimit = value
+	}
+
+	if value, ok, err := readEnvInt("CHAT_MESSAGE_MAX_CHARS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.ChatMessageMaxChars = value
+	}
+
+	if value, ok, err := readEnvInt("CHAT_CLOCK_SKEW_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.ChatClockSkew = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("PLAN_DEADLINE_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.PlanDeadline = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("PLANNER_STATE_INTERVAL_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.Planner.StateInterval = time.Duration(value) * time.Millisecond
+	}
+
+	if window := strings.TrimSpace(os.Getenv("BOT_QUIET_HOURS")); window != "" {
+		quietHours, err := ParseQuietHours(window, os.Getenv("BOT_QUIET_TZ"))
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Planner.QuietHours = quietHours
+	}
+
+	if value, ok, err := readEnvInt("PLAN_RATE_LIMIT_PER_MINUTE"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.PlanRateLimitPerMinute = value
+	}
+
+	if value, ok, err := readEnvInt("PLAN_RATE_BURST"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.PlanRateBurst = value
+	}
+
+	requestTimeoutSet := false
+	if value, ok, err := readEnvInt("REQUEST_TIMEOUT_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.RequestTimeout = time.Duration(value) * time.Millisecond
+		requestTimeoutSet = true
+	}
+
+	if value, ok, err := readEnvInt("ASYNC_PLAN_WORKERS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.AsyncWorkers = value
+	}
+
+	if value, ok, err := readEnvInt("SHUTDOWN_DRAIN_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.ShutdownDrain = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("ASYNC_PLAN_RESULT_TTL_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.AsyncResultTTL = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("PLAN_BATCH_MAX"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.PlanBatchMax = value
+	}
+
+	if value, ok, err := readEnvBool("STRICT_VALIDATION"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.StrictValidation = value
+	}
+
+	if value, ok, err := readEnvBool("READINESS_REQUIRE_LLM"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.ReadinessRequireLLM = value
+	}
+	cfg.API.Tokens = readEnvList("API_TOKEN", "API_TOKENS")
+
+	if value, ok, err := readEnvBool("LOG_REDACT_CHAT"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.API.RedactChat = value
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("HTTP_LISTEN_ADDR")); raw != "" {
+		cfg.HTTP.ListenAddr = raw
+	}
+
+	if value, ok, err := readEnvInt("HTTP_BODY_LIMIT_BYTES"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.HTTP.BodyLimit = int64(value)
+	}
+
+	if value, ok, err := readEnvInt("HTTP_READ_TIMEOUT_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.HTTP.ReadTimeout = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("HTTP_WRITE_TIMEOUT_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.HTTP.WriteTimeout = time.Duration(value) * time.Millisecond
+	}
+
+	if value, ok, err := readEnvInt("HTTP_IDLE_TIMEOUT_MS"); err != nil {
+		return Config{}, err
+	} else if ok {
+		cfg.HTTP.IdleTimeout = time.Duration(value) * time.Millisecond
+	}
+
+	if raw := strings.ToLower(strings.TrimSpace(os.Getenv("LLM_SERVER_API"))); raw != "" {
+		switch raw {
+		case ServerAPILlamaCpp, ServerAPIOpenAIChat, ServerAPIOpenAICompletions:
+			cfg.LLM.ServerAPI = raw
+		default:
+			return Config{}, fmt.Errorf("invalid LLM_SERVER_API: %q (expected %s, %s or %s)", raw, ServerAPILlamaCpp, ServerAPIOpenAIChat, ServerAPIOpenAICompletions)
+		}
+	}
+
+	if raw := strings.ToLower(strings.TrimSpace(os.Getenv("LLM_GRAMMAR"))); raw != "" {
+		if raw != GrammarBuiltin {
+			return Config{}, fmt.Errorf("invalid LLM_GRAMMAR: %q (expected %s)", raw, GrammarBuiltin)
+		}
+		cfg.LLM.Grammar = raw
+	}
+
 	if raw := strings.TrimSpace(os.Getenv("LLM_PROMPT_SYSTEM")); raw != "" {
 		cfg.LLM.PromptSystem = raw
 	}
@@ -212,6 +733,57 @@ func Load() (Config, error) {
 	if cfg.LLM.ChatHistoryLimit < 0 {
 		return Config{}, errors.New("LLM_CHAT_HISTORY_LIMIT must be >= 0")
 	}
+	if cfg.LLM.MaxConcurrent < 1 {
+		return Config{}, errors.New("LLM_MAX_CONCURRENT must be >= 1")
+	}
+	if cfg.Planner.EngagementCooldown < 0 {
+		return Config{}, errors.New("ENGAGEMENT_COOLDOWN_MS must be >= 0")
+	}
+	if cfg.Planner.RecentMessageLimit < 1 {
+		return Config{}, errors.New("RECENT_MESSAGE_LIMIT must be >= 1")
+	}
+	if cfg.Planner.ChatMessageMaxChars < 1 {
+		return Config{}, errors.New("CHAT_MESSAGE_MAX_CHARS must be >= 1")
+	}
+	if cfg.Planner.ChatClockSkew < 0 {
+		return Config{}, errors.New("CHAT_CLOCK_SKEW_MS must be >= 0")
+	}
+	if cfg.Planner.PlanDeadline < 0 {
+		return Config{}, errors.New("PLAN_DEADLINE_MS must be >= 0")
+	}
+	if cfg.Planner.StateInterval < time.Millisecond {
+		return Config{}, errors.New("PLANNER_STATE_INTERVAL_MS must be >= 1")
+	}
+	if cfg.API.PlanRateLimitPerMinute < 0 {
+		return Config{}, errors.New("PLAN_RATE_LIMIT_PER_MINUTE must be >= 0")
+	}
+	if cfg.API.PlanRateBurst < 1 {
+		return Config{}, errors.New("PLAN_RATE_BURST must be >= 1")
+	}
+	if cfg.LLM.MaxRetries < 0 {
+		return Config{}, errors.New("LLM_MAX_RETRIES must be >= 0")
+	}
+	if cfg.LLM.BreakerFailures < 0 {
+		return Config{}, errors.New("LLM_BREAKER_FAILURES must be >= 0")
+	}
+	if cfg.LLM.BreakerCooldown < 0 {
+		return Config{}, errors.New("LLM_BREAKER_COOLDOWN_MS must be >= 0")
+	}
+	if cfg.LLM.BackendRetry < 0 {
+		return Config{}, errors.New("LLM_BACKEND_RETRY_MS must be >= 0")
+	}
+	if cfg.LLM.CacheTTL < 0 {
+		return Config{}, errors.New("LLM_CACHE_TTL_MS must be >= 0")
+	}
+	if cfg.LLM.HealthPollInterval < 0 {
+		return Config{}, errors.New("LLM_HEALTH_POLL_MS must be >= 0")
+	}
+	if cfg.LLM.PressureQueueDepth < 0 {
+		return Config{}, errors.New("LLM_PRESSURE_QUEUE_DEPTH must be >= 0")
+	}
+	if cfg.LLM.PressureP95 < 0 {
+		return Config{}, errors.New("LLM_PRESSURE_P95_MS must be >= 0")
+	}
 	if cfg.LLM.Timeout < 0 {
 		return Config{}, errors.New("LLM_TIMEOUT_MS must be >= 0")
 	}
@@ -221,12 +793,154 @@ func Load() (Config, error) {
 	if cfg.LLM.ServerStartupTimeout < 0 {
 		return Config{}, errors.New("LLM_SERVER_STARTUP_TIMEOUT_MS must be >= 0")
 	}
+	if cfg.LLM.GPULayers < 0 {
+		return Config{}, errors.New("LLM_GPU_LAYERS must be >= 0")
+	}
+	if cfg.LLM.Parallel < 0 {
+		return Config{}, errors.New("LLM_PARALLEL must be >= 0")
+	}
+	if cfg.LLM.BatchSize < 0 {
+		return Config{}, errors.New("LLM_BATCH_SIZE must be >= 0")
+	}
+	if cfg.LLM.ServerMaxRestarts < 0 {
+		return Config{}, errors.New("LLM_SERVER_MAX_RESTARTS must be >= 0")
+	}
+	if cfg.API.AsyncWorkers < 1 {
+		return Config{}, errors.New("ASYNC_PLAN_WORKERS must be >= 1")
+	}
+	if cfg.API.AsyncResultTTL < time.Millisecond {
+		return Config{}, errors.New("ASYNC_PLAN_RESULT_TTL_MS must be >= 1")
+	}
+	if err := validateElasticURL(cfg.Elastic.URL); err != nil {
+		return Config{}, err
+	}
+	if cfg.Elastic.BulkSize < 1 {
+		return Config{}, errors.New("ELASTIC_BULK_SIZE must be >= 1")
+	}
+	if cfg.Elastic.BulkFlushInterval < time.Millisecond {
+		return Config{}, errors.New("ELASTIC_BULK_FLUSH_MS must be >= 1")
+	}
+	if cfg.Elastic.RetryMax < 0 {
+		return Config{}, errors.New("ELASTIC_RETRY_MAX must be >= 0")
+	}
+	if cfg.Elastic.RetryBackoff < time.Millisecond {
+		return Config{}, errors.New("ELASTIC_RETRY_BACKOFF_MS must be >= 1")
+	}
+	if cfg.API.ShutdownDrain < time.Millisecond {
+		return Config{}, errors.New("SHUTDOWN_DRAIN_MS must be >= 1")
+	}
+	if cfg.API.PlanBatchMax < 1 {
+		return Config{}, errors.New("PLAN_BATCH_MAX must be >= 1")
+	}
+	if _, _, err := net.SplitHostPort(cfg.HTTP.ListenAddr); err != nil {
+		return Config{}, fmt.Errorf("invalid HTTP_LISTEN_ADDR: %q (expected host:port or :port)", cfg.HTTP.ListenAddr)
+	}
+	if (cfg.HTTP.TLSCertFile == "") != (cfg.HTTP.TLSKeyFile == "") {
+		return Config{}, errors.New("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	if cfg.HTTP.TLSClientCAFile != "" && cfg.HTTP.TLSCertFile == "" {
+		return Config{}, errors.New("HTTP_TLS_CLIENT_CA_FILE requires HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE")
+	}
+	if cfg.HTTP.BodyLimit < 1 {
+		return Config{}, errors.New("HTTP_BODY_LIMIT_BYTES must be >= 1")
+	}
+	if cfg.HTTP.ReadTimeout < 0 {
+		return Config{}, errors.New("HTTP_READ_TIMEOUT_MS must be >= 0")
+	}
+	if cfg.HTTP.WriteTimeout < 0 {
+		return Config{}, errors.New("HTTP_WRITE_TIMEOUT_MS must be >= 0")
+	}
+	if cfg.HTTP.IdleTimeout < 0 {
+		return Config{}, errors.New("HTTP_IDLE_TIMEOUT_MS must be >= 0")
+	}
+	if cfg.API.RequestTimeout < 0 {
+		return Config{}, errors.New("REQUEST_TIMEOUT_MS must be >= 0")
+	}
 	if cfg.LLM.Timeout > 0 && cfg.LLM.SoftTimeout > cfg.LLM.Timeout {
 		cfg.LLM.SoftTimeout = cfg.LLM.Timeout
 	}
+	if !requestTimeoutSet && cfg.LLM.SoftTimeout > 0 {
+		cfg.API.RequestTimeout = cfg.LLM.SoftTimeout + defaultRequestTimeoutMargin
+	}
+	fileEnvMu.Lock()
+	cfg.Sources = fieldSources()
+	fileEnvMu.Unlock()
 	return cfg, nil
 }
 
+// validateElasticURL accepts an empty URL (Elastic logging off) or an
+// absolute http(s) URL.
+func validateElasticURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid ELASTIC_URL: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid ELASTIC_URL: %q (expected http://host:port or https://host:port)", raw)
+	}
+	return nil
+}
+
+func firstNonEmptyEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseBackends reads LLM_BACKENDS, a JSON array of LLMBackend, or else the
+// comma-separated LLM_SERVER_URLS.
+func parseBackends(raw string, urls []string) ([]LLMBackend, error) {
+	var backends []LLMBackend
+	if raw = strings.TrimSpace(raw); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &backends); err != nil {
+			return nil, fmt.Errorf("invalid LLM_BACKENDS: %w", err)
+		}
+	} else {
+		for _, url := range urls {
+			backends = append(backends, LLMBackend{URL: url})
+		}
+	}
+	names := make(map[string]bool, len(backends))
+	for i := range backends {
+		backend := &backends[i]
+		backend.Name = strings.TrimSpace(backend.Name)
+		backend.URL = strings.TrimSpace(backend.URL)
+		backend.API = strings.ToLower(strings.TrimSpace(backend.API))
+		if backend.URL == "" {
+			return nil, fmt.Errorf("invalid LLM_BACKENDS: backend %d has no url", i)
+		}
+		switch backend.API {
+		case "", ServerAPILlamaCpp, ServerAPIOpenAIChat, ServerAPIOpenAICompletions:
+		default:
+			return nil, fmt.Errorf("invalid LLM_BACKENDS: backend %d api %q (expected %s, %s or %s)", i, backend.API, ServerAPILlamaCpp, ServerAPIOpenAIChat, ServerAPIOpenAICompletions)
+		}
+		if backend.Name == "" {
+			backend.Name = backendName(backend.URL)
+		}
+		if names[backend.Name] {
+			return nil, fmt.Errorf("invalid LLM_BACKENDS: duplicate backend name %q", backend.Name)
+		}
+		names[backend.Name] = true
+	}
+	return backends, nil
+}
+
+// backendName is the host:port of url, or url itself when it has no scheme.
+func backendName(url string) string {
+	name := url
+	if _, rest, ok := strings.Cut(name, "://"); ok {
+		name = rest
+	}
+	name, _, _ = strings.Cut(name, "/")
+	return name
+}
+
 func readEnvInt(key string) (int, bool, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -246,7 +960,7 @@ func readEnvBool(key string) (bool, bool, error) {
 	}
 	value, err := strconv.ParseBool(raw)
 	if err != nil {
-		return false, false, fmt.Errorf("invalid %s: %w", key, err)
+		return false, false, fmt.Errorf("invalid %s: %q (expected true or false)", key, raw)
 	}
 	return value, true, nil
 }
@@ -263,13 +977,179 @@ func readEnvFloat(key string) (float64, bool, error) {
 	return value, true, nil
 }
 
-func loadDotEnv(path string) error {
+func readEnvList(keys ...string) []string {
+	var values []string
+	for _, key := range keys {
+		for _, value := range strings.Split(os.Getenv(key), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// DefaultStopSequences stops at the end of the first line or when the model
+// starts writing a new prompt section.
+func DefaultStopSequences() []string {
+	return []string{"\n", "==="}
+}
+
+// parseStopSequences splits a comma-separated list; \n and \t escapes are
+// expanded and an empty value disables stop sequences.
+func parseStopSequences(raw string) []string {
+	escapes := strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+	var stops []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = escapes.Replace(strings.Trim(value, " ")); value != "" {
+			stops = append(stops, value)
+		}
+	}
+	return stops
+}
+
+// splitArgs splits raw the way a shell would: whitespace separates
+// arguments, quotes group them and a backslash outside single quotes
+// escapes the next character.
+func splitArgs(raw string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range raw {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// fileEnvKeys remembers the variables set from .env and the config file, so
+// that Load on reload picks up edits while real environment variables still
+// win.
+var (
+	fileEnvMu   sync.Mutex
+	fileEnvKeys = map[string]bool{}
+)
+
+// defaultEnvFile is loaded, when it exists, unless ENV_FILE names others.
+const defaultEnvFile = ".env"
+
+// envFilePaths returns the comma-separated ENV_FILE list, or .env; explicit
+// reports whether the files were named and so must exist.
+func envFilePaths() (paths []string, explicit bool) {
+	for _, path := range strings.Split(os.Getenv("ENV_FILE"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return []string{defaultEnvFile}, false
+	}
+	return paths, true
+}
+
+// loadEnvFiles sets variables from the dotenv files, later files overriding
+// earlier ones, and then from the config file (CONFIG_FILE or config.yaml).
+// None of them overrides the real environment and dotenv files win over the
+// config file.
+func loadEnvFiles(dotEnvPaths []string, explicit bool) error {
+	fileEnvMu.Lock()
+	defer fileEnvMu.Unlock()
+	seen := map[string]bool{}
+	for _, path := range dotEnvPaths {
+		loaded, err := loadDotEnv(path, seen)
+		if err != nil {
+			return err
+		}
+		switch {
+		case loaded:
+			logging.Infof("env_file_loaded path=%s", path)
+		case explicit:
+			return fmt.Errorf("ENV_FILE %s: file not found", path)
+		default:
+			logging.Infof("env_file_absent path=%s", path)
+		}
+	}
+	if path := configFilePath(); path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			if seen[key] {
+				continue
+			}
+			if err := setFileEnv(key, value, seen); err != nil {
+				return fmt.Errorf("set %s from %s: %w", key, path, err)
+			}
+		}
+	}
+	forgetFileEnvKeys(seen)
+	return nil
+}
+
+// setFileEnv sets key unless it comes from the real environment.
+func setFileEnv(key, value string, seen map[string]bool) error {
+	if _, exists := os.LookupEnv(key); exists && !fileEnvKeys[key] {
+		return nil
+	}
+	if err := os.Setenv(key, value); err != nil {
+		return err
+	}
+	fileEnvKeys[key] = true
+	seen[key] = true
+	return nil
+}
+
+// loadDotEnv reads KEY=value lines, optionally prefixed with export; it
+// reports false when the file does not exist.
+func loadDotEnv(path string, seen map[string]bool) (bool, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil
+			return false, nil
 		}
-		return fmt.Errorf("open %s: %w", path, err)
+		return false, fmt.Errorf("open %s: %w", path, err)
 	}
 	defer file.Close()
 
@@ -279,37 +1159,64 @@ func loadDotEnv(path string) error {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
 		key, value, ok := strings.Cut(line, "=")
 		if !ok {
 			continue
 		}
 		key = strings.TrimSpace(key)
-		value = strings.TrimSpace(value)
 		if key == "" {
 			continue
 		}
-		if len(value) >= 2 {
-			if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
-				value = value[1 : len(value)-1]
-			}
-		}
+		value = dotEnvValue(value)
 		if strings.Contains(value, `\n`) {
 			value = strings.ReplaceAll(value, `\n`, "\n")
 		}
-		if _, exists := os.LookupEnv(key); !exists {
-			if err := os.Setenv(key, value); err != nil {
-				return fmt.Errorf("set %s from %s: %w", key, path, err)
-			}
+		if err := setFileEnv(key, value, seen); err != nil {
+			return true, fmt.Errorf("set %s from %s: %w", key, path, err)
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("scan %s: %w", path, err)
+		return true, fmt.Errorf("scan %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// dotEnvValue unquotes a value or drops a " # comment" after an unquoted
+// one; # inside a word (a URL fragment, a colour) is kept.
+func dotEnvValue(raw string) string {
+	value := strings.TrimSpace(raw)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			rest := strings.TrimSpace(value[end+2:])
+			if rest == "" || strings.HasPrefix(rest, "#") {
+				return value[1 : end+1]
+			}
+		}
+	}
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+			return strings.TrimSpace(raw[:i])
+		}
+	}
+	return value
+}
+
+// forgetFileEnvKeys unsets variables an earlier load took from a file that
+// no longer defines them.
+func forgetFileEnvKeys(seen map[string]bool) {
+	for key := range fileEnvKeys {
+		if !seen[key] {
+			_ = os.Unsetenv(key)
+			delete(fileEnvKeys, key)
+		}
 	}
-	return nil
 }
 
 func DefaultPromptResponseRules(maxChars, maxWords int) string {
-	base := "- Output exactly ONE single-line chat message in Polish OR output exactly \"__SILENCE__\".\n- Reply ONLY to the LAST message from a PLAYER, and ONLY if it clearly needs a response (question, greeting, direct mention, or conversational prompt).\n- If the last message is from a BOT, or does not need a response, output \"__SILENCE__\"."
+	base := "- Output exactly ONE single-line chat message in " + LanguagePlaceholder + " OR output exactly \"__SILENCE__\".\n- Reply ONLY to the LAST message from a PLAYER, and ONLY if it clearly needs a response (question, greeting, direct mention, or conversational prompt).\n- If the last message is from a BOT, or does not need a response, output \"__SILENCE__\"."
 	if maxChars > 0 {
 		base += fmt.Sprintf("\n- Keep it short: max %d characters, casual Minecraft chat tone.", maxChars)
 	} else {
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -10,6 +14,8 @@ func TestLoadLLMConfigOverrides(t *testing.T) {
 	t.Setenv("LLM_MODELS_DIR", "/tmp/models")
 	t.Setenv("LLM_SERVER_URL", "http://127.0.0.1:8080")
 	t.Setenv("LLM_SERVER_COMMAND", "/usr/local/bin/llama-server")
+	t.Setenv("LLM_SERVER_API", "openai-chat")
+	t.Setenv("LLM_SERVER_MODEL", "qwen2.5-1.5b-instruct")
 	t.Setenv("LLM_COMMAND", "/usr/local/bin/llama-cli")
 	t.Setenv("LLM_MAX_RAM_MB", "1536")
 	t.Setenv("LLM_MAX_TOKENS", "192")
@@ -18,6 +24,19 @@ func TestLoadLLMConfigOverrides(t *testing.T) {
 	t.Setenv("LLM_TIMEOUT_MS", "3500")
 	t.Setenv("LLM_SOFT_TIMEOUT_MS", "3000")
 	t.Setenv("LLM_SERVER_STARTUP_TIMEOUT_MS", "45000")
+	t.Setenv("LLM_SERVER_MAX_RESTARTS", "7")
+	t.Setenv("LLM_SERVER_MODEL_CHECK", "false")
+	t.Setenv("LLM_MAX_CONCURRENT", "2")
+	t.Setenv("LLM_MAX_RETRIES", "3")
+	t.Setenv("LLM_BREAKER_FAILURES", "5")
+	t.Setenv("LLM_BREAKER_COOLDOWN_MS", "15000")
+	t.Setenv("LLM_CACHE_TTL_MS", "2500")
+	t.Setenv("LLM_CACHE_DISABLED", "true")
+	t.Setenv("LLM_HEALTH_POLL_MS", "1500")
+	t.Setenv("ENGAGEMENT_COOLDOWN_MS", "120000")
+	t.Setenv("RECENT_MESSAGE_LIMIT", "8")
+	t.Setenv("LLM_PRESSURE_QUEUE_DEPTH", "3")
+	t.Setenv("LLM_PRESSURE_P95_MS", "1500")
 	t.Setenv("LLM_TEMPERATURE", "0.25")
 	t.Setenv("LLM_TOP_P", "0.8")
 	t.Setenv("LLM_CHAT_HISTORY_LIMIT", "2")
@@ -41,6 +60,12 @@ func TestLoadLLMConfigOverrides(t *testing.T) {
 	if cfg.LLM.ServerCommand != "/usr/local/bin/llama-server" {
 		t.Fatalf("ServerCommand = %q", cfg.LLM.ServerCommand)
 	}
+	if cfg.LLM.ServerAPI != "openai-chat" {
+		t.Fatalf("ServerAPI = %q", cfg.LLM.ServerAPI)
+	}
+	if cfg.LLM.ServerModel != "qwen2.5-1.5b-instruct" {
+		t.Fatalf("ServerModel = %q", cfg.LLM.ServerModel)
+	}
 	if cfg.LLM.Command != "/usr/local/bin/llama-cli" {
 		t.Fatalf("Command = %q", cfg.LLM.Command)
 	}
@@ -65,6 +90,42 @@ func TestLoadLLMConfigOverrides(t *testing.T) {
 	if cfg.LLM.ServerStartupTimeout != 45*time.Second {
 		t.Fatalf("ServerStartupTimeout = %v", cfg.LLM.ServerStartupTimeout)
 	}
+	if cfg.LLM.ServerMaxRestarts != 7 {
+		t.Fatalf("ServerMaxRestarts = %d", cfg.LLM.ServerMaxRestarts)
+	}
+	if cfg.LLM.ServerModelCheck {
+		t.Fatal("ServerModelCheck should be disabled")
+	}
+	if cfg.LLM.MaxConcurrent != 2 {
+		t.Fatalf("MaxConcurrent = %d", cfg.LLM.MaxConcurrent)
+	}
+	if cfg.Planner.EngagementCooldown != 2*time.Minute {
+		t.Fatalf("EngagementCooldown = %v", cfg.Planner.EngagementCooldown)
+	}
+	if cfg.Planner.RecentMessageLimit != 8 {
+		t.Fatalf("RecentMessageLimit = %d", cfg.Planner.RecentMessageLimit)
+	}
+	if cfg.LLM.MaxRetries != 3 {
+		t.Fatalf("MaxRetries = %d", cfg.LLM.MaxRetries)
+	}
+	if cfg.LLM.BreakerFailures != 5 {
+		t.Fatalf("BreakerFailures = %d", cfg.LLM.BreakerFailures)
+	}
+	if cfg.LLM.BreakerCooldown != 15*time.Second {
+		t.Fatalf("BreakerCooldown = %v", cfg.LLM.BreakerCooldown)
+	}
+	if cfg.LLM.CacheTTL != 2500*time.Millisecond || !cfg.LLM.CacheDisabled {
+		t.Fatalf("CacheTTL = %v, CacheDisabled = %v", cfg.LLM.CacheTTL, cfg.LLM.CacheDisabled)
+	}
+	if cfg.LLM.HealthPollInterval != 1500*time.Millisecond {
+		t.Fatalf("HealthPollInterval = %v", cfg.LLM.HealthPollInterval)
+	}
+	if cfg.LLM.PressureQueueDepth != 3 {
+		t.Fatalf("PressureQueueDepth = %d", cfg.LLM.PressureQueueDepth)
+	}
+	if cfg.LLM.PressureP95 != 1500*time.Millisecond {
+		t.Fatalf("PressureP95 = %v", cfg.LLM.PressureP95)
+	}
 	if cfg.LLM.Temperature != 0.25 {
 		t.Fatalf("Temperature = %v", cfg.LLM.Temperature)
 	}
@@ -81,3 +142,514 @@ func TestLoadLLMConfigOverrides(t *testing.T) {
 		t.Fatalf("PromptResponseRules = %q", cfg.LLM.PromptResponseRules)
 	}
 }
+
+func TestLoadStopSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		set  bool
+		raw  string
+		want []string
+	}{
+		{name: "default", want: []string{"\n", "==="}},
+		{name: "escapes", set: true, raw: `\n, ###,</s>`, want: []string{"\n", "###", "</s>"}},
+		{name: "disabled", set: true, raw: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv("LLM_STOP_SEQUENCES", tt.raw)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.LLM.StopSequences, tt.want) {
+				t.Fatalf("StopSequences = %q, want %q", cfg.LLM.StopSequences, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadServerTuning(t *testing.T) {
+	t.Setenv("LLM_GPU_LAYERS", "99")
+	t.Setenv("LLM_PARALLEL", "4")
+	t.Setenv("LLM_BATCH_SIZE", "512")
+	t.Setenv("LLM_SERVER_EXTRA_ARGS", `--flash-attn --alias "chat bot" --chat-template '{{ .x }}' --path C:\\models`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.LLM.GPULayers != 99 || cfg.LLM.Parallel != 4 || cfg.LLM.BatchSize != 512 {
+		t.Fatalf("GPULayers = %d, Parallel = %d, BatchSize = %d", cfg.LLM.GPULayers, cfg.LLM.Parallel, cfg.LLM.BatchSize)
+	}
+	want := []string{"--flash-attn", "--alias", "chat bot", "--chat-template", "{{ .x }}", "--path", `C:\models`}
+	if !reflect.DeepEqual(cfg.LLM.ExtraArgs, want) {
+		t.Fatalf("ExtraArgs = %q, want %q", cfg.LLM.ExtraArgs, want)
+	}
+}
+
+func TestLoadStateDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		stateDir string
+		logDir   string
+		want     string
+	}{
+		{name: "unset", want: ""},
+		{name: "log dir", logDir: "/var/log/aichat", want: "/var/log/aichat"},
+		{name: "explicit", stateDir: "/run/aichat", logDir: "/var/log/aichat", want: "/run/aichat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LLM_STATE_DIR", tt.stateDir)
+			t.Setenv("LOG_DIR", tt.logDir)
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.LLM.StateDir != tt.want {
+				t.Fatalf("StateDir = %q, want %q", cfg.LLM.StateDir, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBackends(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends string
+		urls     string
+		want     []LLMBackend
+		wantErr  string
+	}{
+		{name: "unset"},
+		{
+			name: "server urls",
+			urls: "http://10.0.0.5:8080/v1, http://127.0.0.1:8080",
+			want: []LLMBackend{{Name: "10.0.0.5:8080", URL: "http://10.0.0.5:8080/v1"}, {Name: "127.0.0.1:8080", URL: "http://127.0.0.1:8080"}},
+		},
+		{
+			name:     "json wins over urls",
+			backends: `[{"name":"gpu","url":"https://gpu.example/v1","api":"OpenAI-Chat","api_key":"k"},{"url":"http://127.0.0.1:8080"}]`,
+			urls:     "http://ignored:1",
+			want:     []LLMBackend{{Name: "gpu", URL: "https://gpu.example/v1", API: ServerAPIOpenAIChat, APIKey: "k"}, {Name: "127.0.0.1:8080", URL: "http://127.0.0.1:8080"}},
+		},
+		{name: "malformed json", backends: `[{"url":`, wantErr: "invalid LLM_BACKENDS"},
+		{name: "missing url", backends: `[{"name":"gpu"}]`, wantErr: "backend 0 has no url"},
+		{name: "unknown api", backends: `[{"url":"http://a","api":"grpc"}]`, wantErr: `api "grpc"`},
+		{name: "duplicate name", urls: "http://a:1,https://a:1", wantErr: `duplicate backend name "a:1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LLM_BACKENDS", tt.backends)
+			t.Setenv("LLM_SERVER_URLS", tt.urls)
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.LLM.Backends, tt.want) {
+				t.Fatalf("Backends = %+v, want %+v", cfg.LLM.Backends, tt.want)
+			}
+			if len(tt.want) > 0 && cfg.LLM.MaxConcurrent != defaultLLMMaxConcurrentServer {
+				t.Fatalf("MaxConcurrent = %d, want server default", cfg.LLM.MaxConcurrent)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr string
+	}{
+		{raw: "", want: nil},
+		{raw: "  -ngl   99 ", want: []string{"-ngl", "99"}},
+		{raw: `--alias "a b" --x ''`, want: []string{"--alias", "a b", "--x", ""}},
+		{raw: `it\'s "say \"hi\""`, want: []string{"it's", `say "hi"`}},
+		{raw: `--alias "open`, wantErr: "unterminated"},
+		{raw: `--path x\`, wantErr: "trailing backslash"},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("splitArgs(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("splitArgs(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsUnterminatedExtraArgs(t *testing.T) {
+	t.Setenv("LLM_SERVER_EXTRA_ARGS", `--alias "oops`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LLM_SERVER_EXTRA_ARGS") {
+		t.Fatalf("expected LLM_SERVER_EXTRA_ARGS error, got %v", err)
+	}
+}
+
+func TestLoadGrammar(t *testing.T) {
+	t.Setenv("LLM_GRAMMAR", "Builtin")
+	t.Setenv("LLM_GRAMMAR_PATH", "/etc/chat.gbnf")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.LLM.Grammar != GrammarBuiltin || cfg.LLM.GrammarPath != "/etc/chat.gbnf" {
+		t.Fatalf("Grammar = %q, GrammarPath = %q", cfg.LLM.Grammar, cfg.LLM.GrammarPath)
+	}
+	t.Setenv("LLM_GRAMMAR", "json")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for unknown LLM_GRAMMAR")
+	}
+}
+
+func TestLoadRejectsUnknownServerAPI(t *testing.T) {
+	t.Setenv("LLM_SERVER_API", "grpc")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for unknown LLM_SERVER_API")
+	}
+}
+
+func TestLoadMaxConcurrentDefaultsByBackend(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverURL string
+		want      int
+	}{
+		{name: "cli", want: 1},
+		{name: "server", serverURL: "http://127.0.0.1:8080", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LLM_SERVER_URL", tt.serverURL)
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.LLM.MaxConcurrent != tt.want {
+				t.Fatalf("MaxConcurrent = %d, want %d", cfg.LLM.MaxConcurrent, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidMaxConcurrent(t *testing.T) {
+	t.Setenv("LLM_MAX_CONCURRENT", "0")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for LLM_MAX_CONCURRENT=0")
+	}
+}
+
+func TestLoadAPITokens(t *testing.T) {
+	t.Setenv("API_TOKEN", " primary ")
+	t.Setenv("API_TOKENS", "second, ,third")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := strings.Join(cfg.API.Tokens, "|"); got != "primary|second|third" {
+		t.Fatalf("Tokens = %q", got)
+	}
+}
+
+func TestLoadRedactChat(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.RedactChat {
+		t.Fatal("RedactChat should default to false")
+	}
+	t.Setenv("LOG_REDACT_CHAT", "true")
+	if cfg, err = Load(); err != nil || !cfg.API.RedactChat {
+		t.Fatalf("RedactChat = %t err=%v, want true", cfg.API.RedactChat, err)
+	}
+}
+
+func TestLoadPlanRateLimit(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.PlanRateLimitPerMinute != defaultPlanRateLimitPerMinute || cfg.API.PlanRateBurst != defaultPlanRateBurst {
+		t.Fatalf("unexpected defaults %+v", cfg.API)
+	}
+
+	t.Setenv("PLAN_RATE_LIMIT_PER_MINUTE", "30")
+	t.Setenv("PLAN_RATE_BURST", "3")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.PlanRateLimitPerMinute != 30 || cfg.API.PlanRateBurst != 3 {
+		t.Fatalf("unexpected overrides %+v", cfg.API)
+	}
+
+	t.Setenv("PLAN_RATE_BURST", "0")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for PLAN_RATE_BURST=0")
+	}
+}
+
+func TestLoadRequestTimeout(t *testing.T) {
+	t.Setenv("LLM_TIMEOUT_MS", "4000")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.RequestTimeout != 3500*time.Millisecond {
+		t.Fatalf("RequestTimeout = %v, want soft timeout + margin", cfg.API.RequestTimeout)
+	}
+
+	t.Setenv("REQUEST_TIMEOUT_MS", "8000")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.RequestTimeout != 8*time.Second {
+		t.Fatalf("RequestTimeout = %v, want 8s", cfg.API.RequestTimeout)
+	}
+
+	t.Setenv("REQUEST_TIMEOUT_MS", "-1")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for negative REQUEST_TIMEOUT_MS")
+	}
+}
+
+func TestLoadShutdownDrain(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.ShutdownDrain != 10*time.Second {
+		t.Fatalf("ShutdownDrain = %v, want 10s default", cfg.API.ShutdownDrain)
+	}
+
+	t.Setenv("SHUTDOWN_DRAIN_MS", "2500")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.API.ShutdownDrain != 2500*time.Millisecond {
+		t.Fatalf("ShutdownDrain = %v, want 2.5s", cfg.API.ShutdownDrain)
+	}
+
+	t.Setenv("SHUTDOWN_DRAIN_MS", "0")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for SHUTDOWN_DRAIN_MS=0")
+	}
+}
+
+func TestLoadHTTPConfig(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want := HTTPConfig{ListenAddr: ":8090", BodyLimit: 1 << 20, ReadTimeout: 5 * time.Second, WriteTimeout: 10 * time.Second, IdleTimeout: 30 * time.Second}
+	if cfg.HTTP != want {
+		t.Fatalf("defaults = %+v, want %+v", cfg.HTTP, want)
+	}
+
+	t.Setenv("HTTP_LISTEN_ADDR", "127.0.0.1:9000")
+	t.Setenv("HTTP_BODY_LIMIT_BYTES", "4194304")
+	t.Setenv("HTTP_READ_TIMEOUT_MS", "15000")
+	t.Setenv("HTTP_WRITE_TIMEOUT_MS", "0")
+	t.Setenv("HTTP_IDLE_TIMEOUT_MS", "60000")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want = HTTPConfig{ListenAddr: "127.0.0.1:9000", BodyLimit: 4 << 20, ReadTimeout: 15 * time.Second, IdleTimeout: time.Minute}
+	if cfg.HTTP != want {
+		t.Fatalf("overrides = %+v, want %+v", cfg.HTTP, want)
+	}
+
+	tests := map[string]string{
+		"HTTP_LISTEN_ADDR":        "localhost",
+		"HTTP_BODY_LIMIT_BYTES":   "0",
+		"HTTP_READ_TIMEOUT_MS":    "-1",
+		"HTTP_WRITE_TIMEOUT_MS":   "-1",
+		"HTTP_IDLE_TIMEOUT_MS":    "-1",
+		"HTTP_TLS_CERT_FILE":      "/etc/aichat/tls.crt",
+		"HTTP_TLS_CLIENT_CA_FILE": "/etc/aichat/ca.crt",
+	}
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Fatalf("Load() error = %v, want one naming %s", err, key)
+			}
+		})
+	}
+}
+
+func TestLoadElasticConfigOverrides(t *testing.T) {
+	t.Setenv("ELASTIC_URL", " https://elastic.example:9200 ")
+	t.Setenv("ELASTIC_INDEX", "minecraft-ai-%{+yyyy.MM.dd}")
+	t.Setenv("ELASTIC_API_KEY", "c2VjcmV0")
+	t.Setenv("ELASTIC_VERIFY_CERT", "false")
+	t.Setenv("ELASTIC_BULK_SIZE", "40")
+	t.Setenv("ELASTIC_BULK_FLUSH_MS", "2000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if cfg.Elastic.URL != "https://elastic.example:9200" {
+		t.Fatalf("URL = %q", cfg.Elastic.URL)
+	}
+	if cfg.Elastic.Index != "minecraft-ai-%{+yyyy.MM.dd}" {
+		t.Fatalf("Index = %q", cfg.Elastic.Index)
+	}
+	if cfg.Elastic.APIKey != "c2VjcmV0" {
+		t.Fatalf("APIKey = %q", cfg.Elastic.APIKey)
+	}
+	if cfg.Elastic.VerifyCert {
+		t.Fatal("VerifyCert = true, want false")
+	}
+	if cfg.Elastic.BulkSize != 40 {
+		t.Fatalf("BulkSize = %d", cfg.Elastic.BulkSize)
+	}
+	if cfg.Elastic.BulkFlushInterval != 2*time.Second {
+		t.Fatalf("BulkFlushInterval = %v", cfg.Elastic.BulkFlushInterval)
+	}
+}
+
+func TestLoadElasticConfigErrors(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{key: "ELASTIC_URL", value: "elastic:9200", want: "invalid ELASTIC_URL"},
+		{key: "ELASTIC_URL", value: "ftp://elastic:9200", want: "invalid ELASTIC_URL"},
+		{key: "ELASTIC_URL", value: "http://", want: "invalid ELASTIC_URL"},
+		{key: "ELASTIC_URL", value: "http://elastic:port", want: "invalid ELASTIC_URL"},
+		{key: "ELASTIC_VERIFY_CERT", value: "yes", want: `invalid ELASTIC_VERIFY_CERT: "yes" (expected true or false)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadElasticBulk(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !cfg.Elastic.Bulk || cfg.Elastic.BulkSize != 100 || cfg.Elastic.BulkFlushInterval != time.Second {
+		t.Fatalf("defaults = %t %d %v, want true 100 1s", cfg.Elastic.Bulk, cfg.Elastic.BulkSize, cfg.Elastic.BulkFlushInterval)
+	}
+
+	t.Setenv("ELASTIC_BULK", "false")
+	t.Setenv("ELASTIC_BULK_SIZE", "25")
+	t.Setenv("ELASTIC_BULK_FLUSH_MS", "250")
+	t.Setenv("ELASTIC_DATA_STREAM", "true")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Elastic.Bulk || cfg.Elastic.BulkSize != 25 || cfg.Elastic.BulkFlushInterval != 250*time.Millisecond || !cfg.Elastic.DataStream {
+		t.Fatalf("overrides = %t %d %v %t", cfg.Elastic.Bulk, cfg.Elastic.BulkSize, cfg.Elastic.BulkFlushInterval, cfg.Elastic.DataStream)
+	}
+
+	for _, key := range []string{"ELASTIC_BULK_SIZE", "ELASTIC_BULK_FLUSH_MS"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "0")
+			if _, err := Load(); err == nil {
+				t.Fatalf("expected error for %s=0", key)
+			}
+		})
+	}
+}
+
+func TestLoadElasticRetry(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Elastic.RetryMax != 3 || cfg.Elastic.RetryBackoff != 500*time.Millisecond || cfg.Elastic.SpillFile != "" {
+		t.Fatalf("defaults = %d %v %q", cfg.Elastic.RetryMax, cfg.Elastic.RetryBackoff, cfg.Elastic.SpillFile)
+	}
+
+	t.Setenv("ELASTIC_RETRY_MAX", "0")
+	t.Setenv("ELASTIC_RETRY_BACKOFF_MS", "50")
+	t.Setenv("ELASTIC_SPILL_FILE", " /var/spool/aichat/elastic.ndjson ")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Elastic.RetryMax != 0 || cfg.Elastic.RetryBackoff != 50*time.Millisecond || cfg.Elastic.SpillFile != "/var/spool/aichat/elastic.ndjson" {
+		t.Fatalf("overrides = %d %v %q", cfg.Elastic.RetryMax, cfg.Elastic.RetryBackoff, cfg.Elastic.SpillFile)
+	}
+
+	tests := map[string]string{"ELASTIC_RETRY_MAX": "-1", "ELASTIC_RETRY_BACKOFF_MS": "0"}
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := Load(); err == nil {
+				t.Fatalf("expected error for %s=%s", key, value)
+			}
+		})
+	}
+}
+
+func TestEnvFileLayering(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.env")
+	local := filepath.Join(dir, "local.env")
+	if err := os.WriteFile(base, []byte("LLM_TEMPERATURE=0.3\nLLM_TOP_P=0.5\nexport LLM_MAX_TOKENS=64 # tokens\n"), 0o600); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := os.WriteFile(local, []byte("LLM_TOP_P=0.7\n"), 0o600); err != nil {
+		t.Fatalf("write local: %v", err)
+	}
+	t.Setenv("ENV_FILE", base+", "+local)
+	t.Setenv("LLM_TEMPERATURE", "0.9")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.LLM.Temperature != 0.9 || cfg.LLM.TopP != 0.7 || cfg.LLM.MaxTokens != 64 {
+		t.Fatalf("temperature=%v top_p=%v max_tokens=%d, want 0.9 (env), 0.7 (local.env), 64 (base.env)", cfg.LLM.Temperature, cfg.LLM.TopP, cfg.LLM.MaxTokens)
+	}
+
+	t.Setenv("ENV_FILE", base+","+filepath.Join(dir, "missing.env"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "missing.env") {
+		t.Fatalf("Load() error = %v, want missing explicit file", err)
+	}
+}
+
+func TestDotEnvValue(t *testing.T) {
+	tests := map[string]string{
+		"plain":               "plain",
+		" spaced ":            "spaced",
+		"value # comment":     "value",
+		" # only a comment":   "",
+		"#fff":                "#fff",
+		"http://host/#anchor": "http://host/#anchor",
+		`"quoted # kept"`:     "quoted # kept",
+		`"quoted" # comment`:  "quoted",
+		`'single'`:            "single",
+		`"unterminated # c`:   `"unterminated`,
+		`"a" "b"`:             `"a" "b"`,
+	}
+	for raw, want := range tests {
+		if got := dotEnvValue(raw); got != want {
+			t.Errorf("dotEnvValue(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
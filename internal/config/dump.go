@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Sources of a setting, as recorded in Config.Sources.
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+)
+
+// fieldEnvKeys maps each Section.Field to the variables Load reads for it,
+// in the order they are consulted.
+var fieldEnvKeys = map[string][]string{
+	"LLM.ModelPath":            {"LLM_MODEL_PATH"},
+	"LLM.ModelsDir":            {"LLM_MODELS_DIR"},
+	"LLM.ServerURL":            {"LLM_SERVER_URL"},
+	"LLM.ServerCommand":        {"LLM_SERVER_COMMAND"},
+	"LLM.ServerAPI":            {"LLM_SERVER_API"},
+	"LLM.ServerModel":          {"LLM_SERVER_MODEL"},
+	"LLM.ServerAPIKey":         {"LLM_SERVER_API_KEY"},
+	"LLM.ServerAuthHeader":     {"LLM_SERVER_AUTH_HEADER"},
+	"LLM.FaultInjection":       {"LLM_FAULT_INJECTION"},
+	"LLM.MaxConcurrent":        {"LLM_MAX_CONCURRENT"},
+	"LLM.MaxRetries":           {"LLM_MAX_RETRIES"},
+	"LLM.BreakerFailures":      {"LLM_BREAKER_FAILURES"},
+	"LLM.BreakerCooldown":      {"LLM_BREAKER_COOLDOWN_MS"},
+	"LLM.PressureQueueDepth":   {"LLM_PRESSURE_QUEUE_DEPTH"},
+	"LLM.PressureP95":          {"LLM_PRESSURE_P95_MS"},
+	"LLM.Backends":             {"LLM_BACKENDS", "LLM_SERVER_URLS"},
+	"LLM.BackendRetry":         {"LLM_BACKEND_RETRY_MS"},
+	"LLM.CacheTTL":             {"LLM_CACHE_TTL_MS"},
+	"LLM.CacheDisabled":        {"LLM_CACHE_DISABLED"},
+	"LLM.HealthPollInterval":   {"LLM_HEALTH_POLL_MS"},
+	"LLM.Command":              {"LLM_COMMAND"},
+	"LLM.MaxRAMMB":             {"LLM_MAX_RAM_MB"},
+	"LLM.MaxTokens":            {"LLM_MAX_TOKENS"},
+	"LLM.MaxResponseChars":     {"LLM_MAX_RESPONSE_CHARS"},
+	"LLM.MaxResponseWords":     {"LLM_MAX_RESPONSE_WORDS"},
+	"LLM.StopSequences":        {"LLM_STOP_SEQUENCES"},
+	"LLM.Grammar":              {"LLM_GRAMMAR"},
+	"LLM.GrammarPath":          {"LLM_GRAMMAR_PATH"},
+	"LLM.NumThreads":           {"LLM_NUM_THREADS"},
+	"LLM.CtxSize":              {"LLM_CTX_SIZE"},
+	"LLM.Timeout":              {"LLM_TIMEOUT_MS"},
+	"LLM.SoftTimeout":          {"LLM_SOFT_TIMEOUT_MS"},
+	"LLM.ServerStartupTimeout": {"LLM_SERVER_STARTUP_TIMEOUT_MS"},
+	"LLM.GPULayers":            {"LLM_GPU_LAYERS"},
+	"LLM.Parallel":             {"LLM_PARALLEL"},
+	"LLM.BatchSize":            {"LLM_BATCH_SIZE"},
+	"LLM.ExtraArgs":            {"LLM_SERVER_EXTRA_ARGS"},
+	"LLM.ServerModelCheck":     {"LLM_SERVER_MODEL_CHECK"},
+	"LLM.StateDir":             {"LLM_STATE_DIR", "LOG_DIR"},
+	"LLM.ServerMaxRestarts":    {"LLM_SERVER_MAX_RESTARTS"},
+	"LLM.Temperature":          {"LLM_TEMPERATURE"},
+	"LLM.TopP":                 {"LLM_TOP_P"},
+	"LLM.ChatHistoryLimit":     {"LLM_CHAT_HISTORY_LIMIT"},
+	"LLM.PromptSystem":         {"LLM_PROMPT_SYSTEM"},
+	"LLM.PromptResponseRules":  {"LLM_PROMPT_RESPONSE_RULES"},
+	"LLM.PromptTemplatePath":   {"LLM_PROMPT_TEMPLATE_PATH"},
+
+	"Elastic.URL":               {"ELASTIC_URL"},
+	"Elastic.Index":             {"ELASTIC_INDEX"},
+	"Elastic.APIKey":            {"ELASTIC_API_KEY"},
+	"Elastic.VerifyCert":        {"ELASTIC_VERIFY_CERT"},
+	"Elastic.Bulk":              {"ELASTIC_BULK"},
+	"Elastic.BulkSize":          {"ELASTIC_BULK_SIZE"},
+	"Elastic.BulkFlushInterval": {"ELASTIC_BULK_FLUSH_MS"},
+	"Elastic.DataStream":        {"ELASTIC_DATA_STREAM"},
+	"Elastic.RetryMax":          {"ELASTIC_RETRY_MAX"},
+	"Elastic.RetryBackoff":      {"ELASTIC_RETRY_BACKOFF_MS"},
+	"Elastic.SpillFile":         {"ELASTIC_SPILL_FILE"},
+
+	"API.StrictValidation":       {"STRICT_VALIDATION"},
+	"API.ReadinessRequireLLM":    {"READINESS_REQUIRE_LLM"},
+	"API.Tokens":                 {"API_TOKEN", "API_TOKENS"},
+	"API.PlanRateLimitPerMinute": {"PLAN_RATE_LIMIT_PER_MINUTE"},
+	"API.PlanRateBurst":          {"PLAN_RATE_BURST"},
+	"API.RequestTimeout":         {"REQUEST_TIMEOUT_MS"},
+	"API.AsyncWorkers":           {"ASYNC_PLAN_WORKERS"},
+	"API.AsyncResultTTL":         {"ASYNC_PLAN_RESULT_TTL_MS"},
+	"API.PlanBatchMax":           {"PLAN_BATCH_MAX"},
+	"API.ShutdownDrain":          {"SHUTDOWN_DRAIN_MS"},
+	"API.RedactChat":             {"LOG_REDACT_CHAT"},
+
+	"Planner.EngagementCooldown":     {"ENGAGEMENT_COOLDOWN_MS"},
+	"Planner.RecentMessageLimit":     {"RECENT_MESSAGE_LIMIT"},
+	"Planner.StatePath":              {"PLANNER_STATE_PATH"},
+	"Planner.StateInterval":          {"PLANNER_STATE_INTERVAL_MS"},
+	"Planner.TopicKeywordsPath":      {"TOPIC_KEYWORDS_PATH"},
+	"Planner.ProfanityBlocklistPath": {"PROFANITY_BLOCKLIST_PATH"},
+	"Planner.ChatMessageMaxChars":    {"CHAT_MESSAGE_MAX_CHARS"},
+	"Planner.ChatClockSkew":          {"CHAT_CLOCK_SKEW_MS"},
+	"Planner.PlanDeadline":           {"PLAN_DEADLINE_MS"},
+	"Planner.QuietHours":             {"BOT_QUIET_HOURS", "BOT_QUIET_TZ"},
+
+	"HTTP.ListenAddr":      {"HTTP_LISTEN_ADDR"},
+	"HTTP.BodyLimit":       {"HTTP_BODY_LIMIT_BYTES"},
+	"HTTP.ReadTimeout":     {"HTTP_READ_TIMEOUT_MS"},
+	"HTTP.WriteTimeout":    {"HTTP_WRITE_TIMEOUT_MS"},
+	"HTTP.IdleTimeout":     {"HTTP_IDLE_TIMEOUT_MS"},
+	"HTTP.TLSCertFile":     {"HTTP_TLS_CERT_FILE"},
+	"HTTP.TLSKeyFile":      {"HTTP_TLS_KEY_FILE"},
+	"HTTP.TLSClientCAFile": {"HTTP_TLS_CLIENT_CA_FILE"},
+}
+
+// fieldSources reports for every field whether its first set variable came
+// from the real environment or a file; fields with none are defaults.
+// Derived fields, like LLM.PromptResponseRules from the response limits,
+// count as defaults. The caller holds fileEnvMu.
+func fieldSources() map[string]string {
+	sources := make(map[string]string, len(fieldEnvKeys))
+	for field, keys := range fieldEnvKeys {
+		sources[field] = SourceDefault
+		for _, key := range keys {
+			if strings.TrimSpace(os.Getenv(key)) == "" {
+				continue
+			}
+			if fileEnvKeys[key] {
+				sources[field] = SourceFile
+			} else {
+				sources[field] = SourceEnv
+			}
+			break
+		}
+	}
+	return sources
+}
+
+// Effective renders cfg by Section and Field for display, with durations
+// and quiet hours as text and API keys and tokens masked by MaskSecret.
+func Effective(cfg Config) map[string]map[string]any {
+	cfg.LLM.ServerAPIKey = MaskSecret(cfg.LLM.ServerAPIKey)
+	cfg.Elastic.APIKey = MaskSecret(cfg.Elastic.APIKey)
+	if cfg.LLM.Backends != nil {
+		backends := make([]LLMBackend, len(cfg.LLM.Backends))
+		for i, backend := range cfg.LLM.Backends {
+			backend.APIKey = MaskSecret(backend.APIKey)
+			backends[i] = backend
+		}
+		cfg.LLM.Backends = backends
+	}
+	if cfg.API.Tokens != nil {
+		tokens := make([]string, len(cfg.API.Tokens))
+		for i, token := range cfg.API.Tokens {
+			tokens[i] = MaskSecret(token)
+		}
+		cfg.API.Tokens = tokens
+	}
+
+	rendered := map[string]map[string]any{}
+	root := reflect.ValueOf(cfg)
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		fields := map[string]any{}
+		for j := 0; j < section.NumField(); j++ {
+			fields[section.Type().Field(j).Name] = displayValue(section.Field(j))
+		}
+		rendered[root.Type().Field(i).Name] = fields
+	}
+	return rendered
+}
+
+func displayValue(value reflect.Value) any {
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil
+	}
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return value.Interface()
+}
+
+// MaskSecret keeps the last 4 characters of secret; shorter secrets are
+// masked entirely.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFieldEnvKeysCoverConfig(t *testing.T) {
+	walkSections(Config{}, Config{}, func(name string, _, _ reflect.Value) {
+		if len(fieldEnvKeys[name]) == 0 {
+			t.Errorf("fieldEnvKeys has no variables for %s", name)
+		}
+	})
+}
+
+func TestLoadRecordsSources(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "llm:\n  top_p: 0.5\n  temperature: 0.1\n"))
+	t.Setenv("LLM_TEMPERATURE", "0.8")
+	t.Setenv("LOG_DIR", "/var/log/aichat")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want := map[string]string{
+		"LLM.Temperature": SourceEnv,
+		"LLM.TopP":        SourceFile,
+		"LLM.MaxTokens":   SourceDefault,
+		"LLM.StateDir":    SourceEnv,
+	}
+	for field, source := range want {
+		if cfg.Sources[field] != source {
+			t.Errorf("Sources[%s] = %q, want %q", field, cfg.Sources[field], source)
+		}
+	}
+}
+
+func TestEffectiveMasksSecrets(t *testing.T) {
+	cfg := Config{
+		LLM: LLMConfig{
+			ServerAPIKey: "sk-0123456789",
+			Backends:     []LLMBackend{{Name: "a", APIKey: "key"}},
+			Timeout:      1500 * time.Millisecond,
+		},
+		Elastic: ElasticConfig{APIKey: "elastic-key-wxyz"},
+		API:     APIConfig{Tokens: []string{"token-aaaa", "bbbb"}},
+	}
+	rendered := Effective(cfg)
+
+	if got := rendered["LLM"]["ServerAPIKey"]; got != "****6789" {
+		t.Errorf("LLM.ServerAPIKey = %v", got)
+	}
+	if got := rendered["LLM"]["Backends"].([]LLMBackend)[0].APIKey; got != "****" {
+		t.Errorf("backend api key = %q", got)
+	}
+	if got := rendered["Elastic"]["APIKey"]; got != "****wxyz" {
+		t.Errorf("Elastic.APIKey = %v", got)
+	}
+	if got := rendered["API"]["Tokens"]; !reflect.DeepEqual(got, []string{"****aaaa", "****"}) {
+		t.Errorf("API.Tokens = %v", got)
+	}
+	if got := rendered["LLM"]["Timeout"]; got != "1.5s" {
+		t.Errorf("LLM.Timeout = %v", got)
+	}
+	if got := rendered["Planner"]["QuietHours"]; got != nil {
+		t.Errorf("Planner.QuietHours = %v", got)
+	}
+	if _, ok := rendered["Sources"]; ok {
+		t.Error("Sources rendered as a section")
+	}
+	if cfg.LLM.ServerAPIKey != "sk-0123456789" || cfg.API.Tokens[0] != "token-aaaa" || cfg.LLM.Backends[0].APIKey != "key" {
+		t.Fatal("Effective modified its argument")
+	}
+}
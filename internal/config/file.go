@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aichatplayers/internal/logging"
+)
+
+// defaultConfigFile is read when CONFIG_FILE is not set and it exists.
+const defaultConfigFile = "config.yaml"
+
+// configFileKeys maps key paths of the YAML/JSON config file to the
+// environment variables Load reads, so a file value behaves exactly like
+// the variable. Nested sections (planner.quiet_hours) are dotted paths.
+var configFileKeys = map[string]string{
+	"llm.model_path":                   "LLM_MODEL_PATH",
+	"llm.models_dir":                   "LLM_MODELS_DIR",
+	"llm.server_url":                   "LLM_SERVER_URL",
+	"llm.server_urls":                  "LLM_SERVER_URLS",
+	"llm.server_command":               "LLM_SERVER_COMMAND",
+	"llm.server_api":                   "LLM_SERVER_API",
+	"llm.server_model":                 "LLM_SERVER_MODEL",
+	"llm.server_api_key":               "LLM_SERVER_API_KEY",
+	"llm.server_auth_header":           "LLM_SERVER_AUTH_HEADER",
+	"llm.server_extra_args":            "LLM_SERVER_EXTRA_ARGS",
+	"llm.server_model_check":           "LLM_SERVER_MODEL_CHECK",
+	"llm.server_max_restarts":          "LLM_SERVER_MAX_RESTARTS",
+	"llm.server_startup_timeout_ms":    "LLM_SERVER_STARTUP_TIMEOUT_MS",
+	"llm.backends":                     "LLM_BACKENDS",
+	"llm.backend_retry_ms":             "LLM_BACKEND_RETRY_MS",
+	"llm.fault_injection":              "LLM_FAULT_INJECTION",
+	"llm.command":                      "LLM_COMMAND",
+	"llm.state_dir":                    "LLM_STATE_DIR",
+	"llm.max_ram_mb":                   "LLM_MAX_RAM_MB",
+	"llm.max_tokens":                   "LLM_MAX_TOKENS",
+	"llm.max_response_chars":           "LLM_MAX_RESPONSE_CHARS",
+	"llm.max_response_words":           "LLM_MAX_RESPONSE_WORDS",
+	"llm.stop_sequences":               "LLM_STOP_SEQUENCES",
+	"llm.grammar":                      "LLM_GRAMMAR",
+	"llm.grammar_path":                 "LLM_GRAMMAR_PATH",
+	"llm.num_threads":                  "LLM_NUM_THREADS",
+	"llm.ctx_size":                     "LLM_CTX_SIZE",
+	"llm.timeout_ms":                   "LLM_TIMEOUT_MS",
+	"llm.soft_timeout_ms":              "LLM_SOFT_TIMEOUT_MS",
+	"llm.gpu_layers":                   "LLM_GPU_LAYERS",
+	"llm.parallel":                     "LLM_PARALLEL",
+	"llm.batch_size":                   "LLM_BATCH_SIZE",
+	"llm.max_concurrent":               "LLM_MAX_CONCURRENT",
+	"llm.max_retries":                  "LLM_MAX_RETRIES",
+	"llm.breaker_failures":             "LLM_BREAKER_FAILURES",
+	"llm.breaker_cooldown_ms":          "LLM_BREAKER_COOLDOWN_MS",
+	"llm.cache_ttl_ms":                 "LLM_CACHE_TTL_MS",
+	"llm.cache_disabled":               "LLM_CACHE_DISABLED",
+	"llm.health_poll_ms":               "LLM_HEALTH_POLL_MS",
+	"llm.pressure_queue_depth":         "LLM_PRESSURE_QUEUE_DEPTH",
+	"llm.pressure_p95_ms":              "LLM_PRESSURE_P95_MS",
+	"llm.temperature":                  "LLM_TEMPERATURE",
+	"llm.top_p":                        "LLM_TOP_P",
+	"llm.chat_history_limit":           "LLM_CHAT_HISTORY_LIMIT",
+	"llm.prompt_system":                "LLM_PROMPT_SYSTEM",
+	"llm.prompt_response_rules":        "LLM_PROMPT_RESPONSE_RULES",
+	"llm.prompt_template_path":         "LLM_PROMPT_TEMPLATE_PATH",
+	"elastic.url":                      "ELASTIC_URL",
+	"elastic.index":                    "ELASTIC_INDEX",
+	"elastic.api_key":                  "ELASTIC_API_KEY",
+	"elastic.verify_cert":              "ELASTIC_VERIFY_CERT",
+	"elastic.log_level":                "ELASTIC_LOG_LEVEL",
+	"elastic.bulk":                     "ELASTIC_BULK",
+	"elastic.bulk_size":                "ELASTIC_BULK_SIZE",
+	"elastic.bulk_flush_ms":            "ELASTIC_BULK_FLUSH_MS",
+	"elastic.data_stream":              "ELASTIC_DATA_STREAM",
+	"elastic.retry_max":                "ELASTIC_RETRY_MAX",
+	"elastic.retry_backoff_ms":         "ELASTIC_RETRY_BACKOFF_MS",
+	"elastic.spill_file":               "ELASTIC_SPILL_FILE",
+	"api.tokens":                       "API_TOKENS",
+	"api.strict_validation":            "STRICT_VALIDATION",
+	"api.readiness_require_llm":        "READINESS_REQUIRE_LLM",
+	"api.plan_rate_limit_per_minute":   "PLAN_RATE_LIMIT_PER_MINUTE",
+	"api.plan_rate_burst":              "PLAN_RATE_BURST",
+	"api.request_timeout_ms":           "REQUEST_TIMEOUT_MS",
+	"api.async_workers":                "ASYNC_PLAN_WORKERS",
+	"api.async_result_ttl_ms":          "ASYNC_PLAN_RESULT_TTL_MS",
+	"api.plan_batch_max":               "PLAN_BATCH_MAX",
+	"api.shutdown_drain_ms":            "SHUTDOWN_DRAIN_MS",
+	"planner.engagement_cooldown_ms":   "ENGAGEMENT_COOLDOWN_MS",
+	"planner.recent_message_limit":     "RECENT_MESSAGE_LIMIT",
+	"planner.chat_message_max_chars":   "CHAT_MESSAGE_MAX_CHARS",
+	"planner.chat_clock_skew_ms":       "CHAT_CLOCK_SKEW_MS",
+	"planner.plan_deadline_ms":         "PLAN_DEADLINE_MS",
+	"planner.state_path":               "PLANNER_STATE_PATH",
+	"planner.state_interval_ms":        "PLANNER_STATE_INTERVAL_MS",
+	"planner.topic_keywords_path":      "TOPIC_KEYWORDS_PATH",
+	"planner.profanity_blocklist_path": "PROFANITY_BLOCKLIST_PATH",
+	"planner.quiet_hours.window":       "BOT_QUIET_HOURS",
+	"planner.quiet_hours.tz":           "BOT_QUIET_TZ",
+	"http.listen_addr":                 "HTTP_LISTEN_ADDR",
+	"http.body_limit_bytes":            "HTTP_BODY_LIMIT_BYTES",
+	"http.read_timeout_ms":             "HTTP_READ_TIMEOUT_MS",
+	"http.write_timeout_ms":            "HTTP_WRITE_TIMEOUT_MS",
+	"http.idle_timeout_ms":             "HTTP_IDLE_TIMEOUT_MS",
+	"http.tls.cert_file":               "HTTP_TLS_CERT_FILE",
+	"http.tls.key_file":                "HTTP_TLS_KEY_FILE",
+	"http.tls.client_ca_file":          "HTTP_TLS_CLIENT_CA_FILE",
+	"log.dir":                          "LOG_DIR",
+	"log.level":                        "LOG_LEVEL",
+	"log.file_level":                   "LOG_FILE_LEVEL",
+	"log.redact_chat":                  "LOG_REDACT_CHAT",
+}
+
+// configFilePath is CONFIG_FILE, or config.yaml when that exists.
+func configFilePath() string {
+	if path := strings.TrimSpace(os.Getenv("CONFIG_FILE")); path != "" {
+		return path
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
+// readConfigFile parses a YAML or JSON config file into environment
+// variable values. Unknown keys are logged and skipped.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	var root map[string]any
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&root)
+	} else {
+		root, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	var unknown []string
+	if err := flattenConfigFile(root, "", values, &unknown); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		logging.Warnf("config_file_unknown_key file=%s key=%s", path, key)
+	}
+	return values, nil
+}
+
+func flattenConfigFile(section map[string]any, prefix string, values map[string]string, unknown *[]string) error {
+	for key, value := range section {
+		path := prefix + key
+		if env, ok := configFileKeys[path]; ok {
+			if value == nil {
+				continue
+			}
+			raw, err := configFileValue(env, value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			values[env] = raw
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok && isConfigFileSection(path) {
+			if err := flattenConfigFile(nested, path+".", values, unknown); err != nil {
+				return err
+			}
+			continue
+		}
+		*unknown = append(*unknown, path)
+	}
+	return nil
+}
+
+func isConfigFileSection(path string) bool {
+	for key := range configFileKeys {
+		if strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// configFileValue renders value in the format Load expects for env: lists
+// are comma separated, except LLM_BACKENDS (JSON) and LLM_SERVER_EXTRA_ARGS
+// (shell words).
+func configFileValue(env string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		if env == "LLM_BACKENDS" {
+			encoded, err := json.Marshal(v)
+			return string(encoded), err
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			text, err := configFileValue(env, item)
+			if err != nil {
+				return "", err
+			}
+			if _, nested := item.([]any); nested {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			items = append(items, text)
+		}
+		if env == "LLM_SERVER_EXTRA_ARGS" {
+			for i, item := range items {
+				items[i] = shellQuote(item)
+			}
+			return strings.Join(items, " "), nil
+		}
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("expected a value or a list, got a section")
+}
+
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
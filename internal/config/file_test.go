@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func forgetAllFileEnv() {
+	fileEnvMu.Lock()
+	defer fileEnvMu.Unlock()
+	forgetFileEnvKeys(map[string]bool{})
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `llm:
+  server_url: http://llm:8080
+  temperature: 0.4
+  stop_sequences: ["\n", "==="]
+  server_extra_args:
+    - --flash-attn
+    - --alias
+    - my model
+  backends:
+    - name: gpu
+      url: http://gpu:8080
+  prompt_system: |
+    Line one.
+    Line two.
+elastic:
+  url: http://elastic:9200
+  bulk_size: 50
+planner:
+  quiet_hours:
+    window: 23:00-06:00
+    tz: UTC
+`,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{"llm": {"server_url": "http://llm:8080", "temperature": 0.4, "stop_sequences": ["\n", "==="],
+  "server_extra_args": ["--flash-attn", "--alias", "my model"],
+  "backends": [{"name": "gpu", "url": "http://gpu:8080"}],
+  "prompt_system": "Line one.\nLine two.\n"},
+ "elastic": {"url": "http://elastic:9200", "bulk_size": 50},
+ "planner": {"quiet_hours": {"window": "23:00-06:00", "tz": "UTC"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.file, tt.content))
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.LLM.Temperature != 0.4 || cfg.Elastic.URL != "http://elastic:9200" || cfg.Elastic.BulkSize != 50 {
+				t.Fatalf("unexpected config %+v %+v", cfg.LLM, cfg.Elastic)
+			}
+			if cfg.LLM.PromptSystem != "Line one.\nLine two." {
+				t.Fatalf("PromptSystem = %q", cfg.LLM.PromptSystem)
+			}
+			if !reflect.DeepEqual(cfg.LLM.StopSequences, []string{"\n", "==="}) {
+				t.Fatalf("StopSequences = %q", cfg.LLM.StopSequences)
+			}
+			if !reflect.DeepEqual(cfg.LLM.ExtraArgs, []string{"--flash-attn", "--alias", "my model"}) {
+				t.Fatalf("ExtraArgs = %q", cfg.LLM.ExtraArgs)
+			}
+			if len(cfg.LLM.Backends) != 1 || cfg.LLM.Backends[0].Name != "gpu" || cfg.LLM.Backends[0].URL != "http://gpu:8080" {
+				t.Fatalf("Backends = %+v", cfg.LLM.Backends)
+			}
+			if cfg.Planner.QuietHours == nil || cfg.Planner.QuietHours.Start != 23*time.Hour {
+				t.Fatalf("QuietHours = %+v", cfg.Planner.QuietHours)
+			}
+		})
+	}
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "llm:\n  temperature: 0.1\n  top_p: 0.2\n  max_tokens: 30\n"))
+	dotEnv := writeConfigFile(t, ".env", "LLM_TOP_P=0.5\nLLM_MAX_TOKENS=60\n")
+	t.Setenv("LLM_MAX_TOKENS", "90")
+
+	if err := loadEnvFiles([]string{dotEnv}, true); err != nil {
+		t.Fatalf("loadEnvFiles() error: %v", err)
+	}
+	want := map[string]string{"LLM_TEMPERATURE": "0.1", "LLM_TOP_P": "0.5", "LLM_MAX_TOKENS": "90"}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Fatalf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	// A reload sees edits to the file and drops keys it no longer sets.
+	if err := os.WriteFile(os.Getenv("CONFIG_FILE"), []byte("llm:\n  top_p: 0.3\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config file: %v", err)
+	}
+	if err := os.WriteFile(dotEnv, nil, 0o600); err != nil {
+		t.Fatalf("rewrite .env: %v", err)
+	}
+	if err := loadEnvFiles([]string{dotEnv}, true); err != nil {
+		t.Fatalf("loadEnvFiles() error: %v", err)
+	}
+	if _, ok := os.LookupEnv("LLM_TEMPERATURE"); ok {
+		t.Fatal("LLM_TEMPERATURE should be unset after removal from the config file")
+	}
+	if got := os.Getenv("LLM_TOP_P"); got != "0.3" {
+		t.Fatalf("LLM_TOP_P = %q, want 0.3 from the config file", got)
+	}
+	if got := os.Getenv("LLM_MAX_TOKENS"); got != "90" {
+		t.Fatalf("LLM_MAX_TOKENS = %q, want the real environment value", got)
+	}
+}
+
+func TestConfigFileUnknownKeys(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "llm:\n  temprature: 0.9\n  top_p: 0.8\nplanner:\n  quiet_hours:\n    start: 22:00\nextra:\n  enabled: true\n"))
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.LLM.TopP != 0.8 {
+		t.Fatalf("TopP = %v, want 0.8", cfg.LLM.TopP)
+	}
+	for _, key := range []string{"key=extra", "key=llm.temprature", "key=planner.quiet_hours.start"} {
+		if !strings.Contains(logs.String(), "config_file_unknown_key") || !strings.Contains(logs.String(), key) {
+			t.Fatalf("expected warning for %s, got %s", key, logs.String())
+		}
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	t.Cleanup(forgetAllFileEnv)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{name: "missing file", file: "", want: "read config file"},
+		{name: "invalid yaml", file: "config.yaml", content: "llm:\n\ttemperature: 1\n", want: "tabs are not allowed"},
+		{name: "invalid json", file: "config.json", content: `{"llm":`, want: "parse config file"},
+		{name: "section for value", file: "config.yaml", content: "llm:\n  temperature:\n    value: 1\n", want: "llm.temperature"},
+		{name: "invalid value", file: "config.yaml", content: "llm:\n  temperature: hot\n", want: "LLM_TEMPERATURE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.yaml")
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file, tt.content)
+			}
+			t.Setenv("CONFIG_FILE", path)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
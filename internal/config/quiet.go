@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily window (start inclusive, end exclusive) in which the
+// bots stay silent. A window whose end is before its start crosses midnight.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseQuietHours parses a "HH:MM-HH:MM" window; tz is an IANA time zone name
+// and defaults to the local time zone.
+func ParseQuietHours(window, tz string) (*QuietHours, error) {
+	startRaw, endRaw, ok := strings.Cut(window, "-")
+	if !ok {
+		return nil, fmt.Errorf("BOT_QUIET_HOURS must look like HH:MM-HH:MM, got %q", window)
+	}
+	start, err := parseClock(startRaw)
+	if err != nil {
+		return nil, fmt.Errorf("BOT_QUIET_HOURS start: %w", err)
+	}
+	end, err := parseClock(endRaw)
+	if err != nil {
+		return nil, fmt.Errorf("BOT_QUIET_HOURS end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("BOT_QUIET_HOURS start and end must differ, got %q", window)
+	}
+	location := time.Local
+	if tz = strings.TrimSpace(tz); tz != "" {
+		location, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("BOT_QUIET_TZ: %w", err)
+		}
+	}
+	return &QuietHours{Start: start, End: end, Location: location}, nil
+}
+
+// Active reports whether now falls into the window; a nil window is never
+// active.
+func (q *QuietHours) Active(now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	local := now.In(q.Location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+func (q *QuietHours) String() string {
+	if q == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s %s", formatClock(q.Start), formatClock(q.End), q.Location)
+}
+
+func parseClock(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	hoursRaw, minutesRaw, ok := strings.Cut(raw, ":")
+	if !ok {
+		return 0, fmt.Errorf("expected HH:MM, got %q", raw)
+	}
+	hours, err := strconv.Atoi(hoursRaw)
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", raw)
+	}
+	minutes, err := strconv.Atoi(minutesRaw)
+	if err != nil || len(minutesRaw) != 2 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid minutes in %q", raw)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
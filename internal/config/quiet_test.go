@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	day := time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		window  string
+		active  []string
+		quiet   []string
+		wantErr string
+	}{
+		{window: "23:00-06:00", active: []string{"23:00", "23:59", "00:00", "05:59"}, quiet: []string{"06:00", "12:00", "22:59"}},
+		{window: " 01:30 - 03:00 ", active: []string{"01:30", "02:59"}, quiet: []string{"01:29", "03:00", "23:30"}},
+		{window: "23:00", wantErr: "HH:MM-HH:MM"},
+		{window: "2300-0600", wantErr: "expected HH:MM"},
+		{window: "24:00-06:00", wantErr: "invalid hour"},
+		{window: "23:00-06:7", wantErr: "invalid minutes"},
+		{window: "23:00-23:00", wantErr: "must differ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			quietHours, err := ParseQuietHours(tt.window, "UTC")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuietHours() error: %v", err)
+			}
+			check := func(clock string, want bool) {
+				offset, _ := parseClock(clock)
+				if got := quietHours.Active(day.Add(offset)); got != want {
+					t.Fatalf("Active(%s) = %t, want %t", clock, got, want)
+				}
+			}
+			for _, clock := range tt.active {
+				check(clock, true)
+			}
+			for _, clock := range tt.quiet {
+				check(clock, false)
+			}
+		})
+	}
+
+	if _, err := ParseQuietHours("23:00-06:00", "Mars/Olympus"); err == nil || !strings.Contains(err.Error(), "BOT_QUIET_TZ") {
+		t.Fatalf("expected time zone error, got %v", err)
+	}
+	var disabled *QuietHours
+	if disabled.Active(day) {
+		t.Fatal("nil quiet hours must never be active")
+	}
+}
+
+func TestLoadQuietHours(t *testing.T) {
+	t.Setenv("BOT_QUIET_HOURS", "23:00-06:00")
+	t.Setenv("BOT_QUIET_TZ", "UTC")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Planner.QuietHours == nil || cfg.Planner.QuietHours.String() != "23:00-06:00 UTC" {
+		t.Fatalf("QuietHours = %v", cfg.Planner.QuietHours)
+	}
+
+	t.Setenv("BOT_QUIET_HOURS", "late")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "BOT_QUIET_HOURS") {
+		t.Fatalf("expected BOT_QUIET_HOURS error, got %v", err)
+	}
+}
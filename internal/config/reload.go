@@ -0,0 +1,93 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// reloadableFields can change on a running service; every other field only
+// takes effect after a restart.
+var reloadableFields = map[string]bool{
+	"LLM.Temperature":             true,
+	"LLM.TopP":                    true,
+	"LLM.MaxTokens":               true,
+	"LLM.Timeout":                 true,
+	"LLM.SoftTimeout":             true,
+	"LLM.MaxRetries":              true,
+	"LLM.ChatHistoryLimit":        true,
+	"LLM.PromptSystem":            true,
+	"LLM.PromptResponseRules":     true,
+	"LLM.MaxResponseChars":        true,
+	"LLM.MaxResponseWords":        true,
+	"LLM.StopSequences":           true,
+	"LLM.PressureQueueDepth":      true,
+	"LLM.PressureP95":             true,
+	"Planner.EngagementCooldown":  true,
+	"Planner.RecentMessageLimit":  true,
+	"Planner.ChatMessageMaxChars": true,
+	"Planner.ChatClockSkew":       true,
+	"Planner.PlanDeadline":        true,
+	"Planner.QuietHours":          true,
+}
+
+// Diff lists the fields that differ between the running and the freshly
+// loaded config, split into those a reload applies and those that wait for
+// a restart. Names are Section.Field, e.g. LLM.Temperature.
+func Diff(running, loaded Config) (applied, pendingRestart []string) {
+	walkSections(running, loaded, func(name string, old, next reflect.Value) {
+		if reflect.DeepEqual(old.Interface(), next.Interface()) {
+			return
+		}
+		if reloadableFields[name] {
+			applied = append(applied, name)
+		} else {
+			pendingRestart = append(pendingRestart, name)
+		}
+	})
+	sort.Strings(applied)
+	sort.Strings(pendingRestart)
+	return applied, pendingRestart
+}
+
+// ApplyReloadable returns running with the reloadable fields of loaded and
+// their sources.
+func ApplyReloadable(running, loaded Config) Config {
+	sources := make(map[string]string, len(running.Sources))
+	for name, source := range running.Sources {
+		sources[name] = source
+	}
+	walkSections(&running, loaded, func(name string, old, next reflect.Value) {
+		if reloadableFields[name] {
+			old.Set(next)
+			if source, ok := loaded.Sources[name]; ok {
+				sources[name] = source
+			}
+		}
+	})
+	running.Sources = sources
+	return running
+}
+
+// ReloadLLM is ApplyReloadable for an LLM client that keeps its own,
+// already resolved copy of the LLM section.
+func ReloadLLM(running, loaded LLMConfig) LLMConfig {
+	merged := ApplyReloadable(Config{LLM: running}, Config{LLM: loaded})
+	return merged.LLM
+}
+
+// walkSections calls fn for each field of each section struct, skipping
+// Sources; running may be a pointer so fn can set its fields.
+func walkSections(running any, loaded Config, fn func(name string, old, next reflect.Value)) {
+	oldRoot := reflect.Indirect(reflect.ValueOf(running))
+	nextRoot := reflect.ValueOf(loaded)
+	for i := 0; i < oldRoot.NumField(); i++ {
+		section := oldRoot.Type().Field(i).Name
+		oldSection, nextSection := oldRoot.Field(i), nextRoot.Field(i)
+		if oldSection.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < oldSection.NumField(); j++ {
+			fn(section+"."+oldSection.Type().Field(j).Name, oldSection.Field(j), nextSection.Field(j))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSplitsReloadableFields(t *testing.T) {
+	running := Config{
+		LLM:     LLMConfig{ModelPath: "a.gguf", Temperature: 0.7, Timeout: time.Second},
+		Planner: PlannerConfig{RecentMessageLimit: 20},
+	}
+	tests := []struct {
+		name        string
+		edit        func(cfg *Config)
+		wantApplied []string
+		wantPending []string
+	}{
+		{name: "unchanged", edit: func(*Config) {}},
+		{
+			name:        "hot fields",
+			edit:        func(cfg *Config) { cfg.LLM.Temperature = 0.9; cfg.Planner.RecentMessageLimit = 10 },
+			wantApplied: []string{"LLM.Temperature", "Planner.RecentMessageLimit"},
+		},
+		{
+			name:        "restart fields",
+			edit:        func(cfg *Config) { cfg.LLM.ModelPath = "b.gguf"; cfg.LLM.ServerURL = "http://llm:8080" },
+			wantPending: []string{"LLM.ModelPath", "LLM.ServerURL"},
+		},
+		{
+			name:        "mixed",
+			edit:        func(cfg *Config) { cfg.LLM.Timeout = 2 * time.Second; cfg.API.PlanRateBurst = 5 },
+			wantApplied: []string{"LLM.Timeout"},
+			wantPending: []string{"API.PlanRateBurst"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := running
+			tt.edit(&loaded)
+			applied, pending := Diff(running, loaded)
+			if !reflect.DeepEqual(applied, tt.wantApplied) || !reflect.DeepEqual(pending, tt.wantPending) {
+				t.Fatalf("Diff() = %v, %v, want %v, %v", applied, pending, tt.wantApplied, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestApplyReloadableKeepsRestartFields(t *testing.T) {
+	running := Config{
+		LLM:     LLMConfig{ModelPath: "a.gguf", Temperature: 0.7, PromptSystem: "old"},
+		Sources: map[string]string{"LLM.ModelPath": SourceEnv, "LLM.Temperature": SourceDefault},
+	}
+	loaded := Config{
+		LLM:     LLMConfig{ModelPath: "b.gguf", Temperature: 0.9, PromptSystem: "new"},
+		Sources: map[string]string{"LLM.ModelPath": SourceFile, "LLM.Temperature": SourceFile},
+	}
+
+	merged := ApplyReloadable(running, loaded)
+	if merged.LLM.ModelPath != "a.gguf" || merged.LLM.Temperature != 0.9 || merged.LLM.PromptSystem != "new" {
+		t.Fatalf("merged = %+v", merged.LLM)
+	}
+	if merged.Sources["LLM.ModelPath"] != SourceEnv || merged.Sources["LLM.Temperature"] != SourceFile {
+		t.Fatalf("merged sources = %v", merged.Sources)
+	}
+	if running.LLM.Temperature != 0.7 || running.Sources["LLM.Temperature"] != SourceDefault {
+		t.Fatal("ApplyReloadable modified its argument")
+	}
+	if got := ReloadLLM(running.LLM, loaded.LLM); !reflect.DeepEqual(got, merged.LLM) {
+		t.Fatalf("ReloadLLM() = %+v, want %+v", got, merged.LLM)
+	}
+}
+
+func TestLoadDotEnvPicksUpEdits(t *testing.T) {
+	const key = "AICHAT_TEST_RELOAD_VALUE"
+	t.Cleanup(forgetAllFileEnv)
+	path := filepath.Join(t.TempDir(), ".env")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write .env: %v", err)
+		}
+		if err := loadEnvFiles([]string{path}, true); err != nil {
+			t.Fatalf("loadEnvFiles() error: %v", err)
+		}
+	}
+
+	write(key + "=first\n")
+	write(key + "=second\n")
+	if got := os.Getenv(key); got != "second" {
+		t.Fatalf("%s = %q after edit, want second", key, got)
+	}
+	write("# removed\n")
+	if _, ok := os.LookupEnv(key); ok {
+		t.Fatalf("%s still set after removal from .env", key)
+	}
+}
@@ -0,0 +1,380 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseYAML reads the YAML subset config files need: nested mappings, block
+// and flow sequences, plain and quoted scalars, and literal (|) or folded
+// (>) block scalars. Scalars stay strings; null, ~ and missing values are
+// nil. Anchors, tags, flow mappings and multiple documents are rejected.
+func parseYAML(data []byte) (map[string]any, error) {
+	text := strings.ReplaceAll(strings.TrimPrefix(string(data), "\ufeff"), "\r\n", "\n")
+	p := &yamlParser{lines: strings.Split(text, "\n")}
+	if p.nextContent() && strings.TrimSpace(p.lines[p.pos]) == "---" {
+		p.pos++
+	}
+	if !p.nextContent() {
+		return map[string]any{}, nil
+	}
+	indent, err := p.indent()
+	if err != nil {
+		return nil, err
+	}
+	if isYAMLSequenceItem(strings.TrimSpace(p.lines[p.pos])) {
+		return nil, p.errorf("top level must be a mapping")
+	}
+	root, err := p.parseMapping(indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.nextContent() {
+		return nil, p.errorf("unexpected %q", strings.TrimSpace(p.lines[p.pos]))
+	}
+	return root, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// nextContent skips blank and comment lines and reports whether a content
+// line is left.
+func (p *yamlParser) nextContent() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := strings.TrimSpace(p.lines[p.pos])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *yamlParser) indent() (int, error) {
+	line := p.lines[p.pos]
+	trimmed := strings.TrimLeft(line, " \t")
+	if strings.Contains(line[:len(line)-len(trimmed)], "\t") {
+		return 0, p.errorf("tabs are not allowed for indentation")
+	}
+	return len(line) - len(trimmed), nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	mapping := map[string]any{}
+	for p.nextContent() {
+		current, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if current < indent {
+			break
+		}
+		if current > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		text := strings.TrimSpace(p.lines[p.pos])
+		if isYAMLSequenceItem(text) {
+			break
+		}
+		key, rest, ok, err := splitYAMLKey(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected \"key: value\", got %q", text)
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		value, err := p.parseValue(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+	return mapping, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	sequence := []any{}
+	for p.nextContent() {
+		current, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if current < indent {
+			break
+		}
+		if current > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		text := strings.TrimSpace(p.lines[p.pos])
+		if !isYAMLSequenceItem(text) {
+			break
+		}
+		item := strings.TrimLeft(text[1:], " ")
+		if _, _, isKey, _ := splitYAMLKey(item); isKey {
+			// "- key: value" starts a mapping at the column of key.
+			itemIndent := current + len(text) - len(item)
+			p.lines[p.pos] = strings.Repeat(" ", itemIndent) + item
+			mapping, err := p.parseMapping(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, mapping)
+			continue
+		}
+		p.pos++
+		value, err := p.parseValue(item, indent)
+		if err != nil {
+			return nil, err
+		}
+		sequence = append(sequence, value)
+	}
+	return sequence, nil
+}
+
+// parseValue reads what follows "key:" or "- " on a line whose block is at
+// indent, continuing onto more indented lines when rest is empty.
+func (p *yamlParser) parseValue(rest string, indent int) (any, error) {
+	switch {
+	case rest == "" || strings.HasPrefix(rest, "#"):
+		if !p.nextContent() {
+			return nil, nil
+		}
+		current, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(p.lines[p.pos])
+		switch {
+		case current > indent && isYAMLSequenceItem(text):
+			return p.parseSequence(current)
+		case current > indent:
+			return p.parseMapping(current)
+		case current == indent && isYAMLSequenceItem(text):
+			return p.parseSequence(current)
+		}
+		return nil, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(rest, indent)
+	case rest[0] == '[':
+		value, err := parseYAMLFlowSequence(rest)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return value, nil
+	case rest[0] == '{' || rest[0] == '&' || rest[0] == '*' || rest[0] == '!':
+		return nil, p.errorf("unsupported YAML syntax %q", rest)
+	}
+	value, err := parseYAMLScalar(rest)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return value, nil
+}
+
+func (p *yamlParser) parseBlockScalar(header string, indent int) (any, error) {
+	header, _, _ = strings.Cut(header, " #")
+	header = strings.TrimSpace(header)
+	folded := header[0] == '>'
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		current := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent < 0 {
+			if current <= indent {
+				break
+			}
+			blockIndent = current
+		}
+		if current < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range body {
+			switch {
+			case line == "":
+				b.WriteByte('\n')
+			case i == 0 || body[i-1] == "":
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(body, "\n")
+	}
+	switch {
+	case len(body) == 0 || chomp == "-":
+	case chomp == "+":
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: rest". ok is false when text is a scalar rather
+// than a mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated quoted key in %q", text)
+		}
+		after := text[end+1:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		value, err := parseYAMLScalar(text[:end+1])
+		if err != nil {
+			return "", "", false, err
+		}
+		return value.(string), strings.TrimSpace(after[1:]), true, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false, nil
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// closingQuote returns the index of the quote that ends the quoted scalar
+// at the start of text, or -1.
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func parseYAMLScalar(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	if text[0] != '"' && text[0] != '\'' {
+		if value, _, found := strings.Cut(text, " #"); found {
+			text = strings.TrimSpace(value)
+		}
+		switch text {
+		case "~", "null", "Null", "NULL":
+			return nil, nil
+		}
+		return text, nil
+	}
+	end := closingQuote(text)
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated quoted string %s", text)
+	}
+	if after := strings.TrimSpace(text[end+1:]); after != "" && !strings.HasPrefix(after, "#") {
+		return nil, fmt.Errorf("unexpected %q after quoted string", after)
+	}
+	inner := text[1:end]
+	if text[0] == '\'' {
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] != '\\' {
+			b.WriteByte(inner[i])
+			continue
+		}
+		i++
+		switch inner[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '"', '\\', '/':
+			b.WriteByte(inner[i])
+		default:
+			return nil, fmt.Errorf("unsupported escape \\%c", inner[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// parseYAMLFlowSequence reads a one-line [a, "b", c] list of scalars.
+func parseYAMLFlowSequence(text string) ([]any, error) {
+	items := []any{}
+	start := 1
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			end := closingQuote(text[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in %s", text)
+			}
+			i += end
+		case '[', '{':
+			return nil, fmt.Errorf("nested flow collections are not supported: %s", text)
+		case ',', ']':
+			item := strings.TrimSpace(text[start:i])
+			if item != "" || text[i] == ',' {
+				value, err := parseYAMLScalar(item)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+			}
+			if text[i] == ']' {
+				if after := strings.TrimSpace(text[i+1:]); after != "" && !strings.HasPrefix(after, "#") {
+					return nil, fmt.Errorf("unexpected %q after %s", after, text[:i+1])
+				}
+				return items, nil
+			}
+			start = i + 1
+		}
+	}
+	return nil, fmt.Errorf("unterminated flow sequence %s", text)
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]any
+	}{
+		{name: "empty", input: "# only a comment\n", want: map[string]any{}},
+		{
+			name:  "nested mapping and comments",
+			input: "---\nllm:\n  # sampling\n  temperature: 0.7 # warm\n  url: http://host:8080/v1\n\nlog:\n  level: info\n",
+			want:  map[string]any{"llm": map[string]any{"temperature": "0.7", "url": "http://host:8080/v1"}, "log": map[string]any{"level": "info"}},
+		},
+		{
+			name:  "quoted scalars",
+			input: "a: \"x # not a comment\\n\"\nb: 'it''s'\n\"c d\": ''\ne: null\nf:\n",
+			want:  map[string]any{"a": "x # not a comment\n", "b": "it's", "c d": "", "e": nil, "f": nil},
+		},
+		{
+			name:  "sequences",
+			input: "flow: [a, \"b, c\", ]\nblock:\n  - one\n  - two\nsame_indent:\n- x\nitems:\n  - name: gpu\n    url: http://gpu\n  - name: cpu\nempty: []\n",
+			want: map[string]any{
+				"flow":        []any{"a", "b, c"},
+				"block":       []any{"one", "two"},
+				"same_indent": []any{"x"},
+				"items":       []any{map[string]any{"name": "gpu", "url": "http://gpu"}, map[string]any{"name": "cpu"}},
+				"empty":       []any{},
+			},
+		},
+		{
+			name:  "block scalars",
+			input: "literal: |\n  first\n    indented\n\n  last\n\nstrip: |-\n  no newline\nfolded: >\n  one\n  two\n\n  three\n\n\n  four\nnext: 1\n",
+			want: map[string]any{
+				"literal": "first\n  indented\n\nlast\n",
+				"strip":   "no newline",
+				"folded":  "one two\nthree\n\nfour\n",
+				"next":    "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseYAML() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseYAML() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "top level list", input: "- a\n", want: "top level must be a mapping"},
+		{name: "bad indentation", input: "a:\n    b: 1\n  c: 2\n", want: "line 3: unexpected indentation"},
+		{name: "duplicate key", input: "a: 1\na: 2\n", want: "duplicate key"},
+		{name: "not a mapping", input: "a: 1\njust text\n", want: "expected \"key: value\""},
+		{name: "flow mapping", input: "a: {b: 1}\n", want: "unsupported YAML syntax"},
+		{name: "anchor", input: "a: &x 1\n", want: "unsupported YAML syntax"},
+		{name: "unterminated quote", input: "a: \"open\n", want: "unterminated"},
+		{name: "unterminated flow", input: "a: [1, 2\n", want: "unterminated flow sequence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("parseYAML() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+
+	"aichatplayers/internal/config"
+)
+
+const defaultAuthHeader = "Authorization"
+
+type serverAuth struct {
+	header string
+	value  string
+}
+
+func newServerAuth(cfg config.LLMConfig) serverAuth {
+	key := strings.TrimSpace(cfg.ServerAPIKey)
+	if key == "" {
+		return serverAuth{}
+	}
+	header := strings.TrimSpace(cfg.ServerAuthHeader)
+	if header == "" {
+		header = defaultAuthHeader
+	}
+	value := key
+	if strings.EqualFold(header, defaultAuthHeader) {
+		value = "Bearer " + key
+	}
+	return serverAuth{header: header, value: value}
+}
+
+func (a serverAuth) apply(req *http.Request) {
+	if a.header == "" || req == nil {
+		return
+	}
+	req.Header.Set(a.header, a.value)
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+var ErrBreakerOpen = errors.New("llm circuit breaker open")
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// CircuitBreaker fails LLM calls instantly after repeated failures so plan
+// requests fall back to heuristics without waiting for the soft timeout. After
+// the cool-off window a single probe decides whether it closes again.
+type CircuitBreaker struct {
+	inner    Generator
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	consecutive int
+	openedAt    time.Time
+	open        bool
+	probing     bool
+}
+
+func WithCircuitBreaker(inner Generator, cfg config.LLMConfig) Generator {
+	if inner == nil || !inner.Enabled() || cfg.BreakerFailures <= 0 {
+		return inner
+	}
+	logging.Debugf("llm_breaker_enabled failures=%d cooldown_ms=%d", cfg.BreakerFailures, cfg.BreakerCooldown.Milliseconds())
+	return NewCircuitBreaker(inner, cfg)
+}
+
+func NewCircuitBreaker(inner Generator, cfg config.LLMConfig) *CircuitBreaker {
+	failures := cfg.BreakerFailures
+	if failures <= 0 {
+		failures = 1
+	}
+	return &CircuitBreaker{
+		inner:    inner,
+		failures: failures,
+		cooldown: cfg.BreakerCooldown,
+		now:      time.Now,
+	}
+}
+
+func (b *CircuitBreaker) Enabled() bool {
+	return b.inner.Enabled()
+}
+
+func (b *CircuitBreaker) Close() error {
+	return b.inner.Close()
+}
+
+func (b *CircuitBreaker) Reconfigure(cfg config.LLMConfig) {
+	if inner, ok := b.inner.(Reconfigurer); ok {
+		inner.Reconfigure(cfg)
+	}
+}
+
+func (b *CircuitBreaker) Generate(ctx context.Context, req Request) (string, error) {
+	probe, ok := b.allow()
+	if !ok {
+		return "", ErrBreakerOpen
+	}
+	message, err := b.inner.Generate(ctx, req)
+	b.record(probe, err)
+	return message, err
+}
+
+func (b *CircuitBreaker) allow() (probe bool, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return false, true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	logging.Infof("llm_breaker_half_open consecutive_failures=%d", b.consecutive)
+	return true, true
+}
+
+func (b *CircuitBreaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if err == nil {
+		if b.open {
+			logging.Infof("llm_breaker_closed consecutive_failures=%d", b.consecutive)
+		}
+		b.open = false
+		b.consecutive = 0
+		return
+	}
+	b.consecutive++
+	if probe || (!b.open && b.consecutive >= b.failures) {
+		b.open = true
+		b.openedAt = b.now()
+		logging.Warnf("llm_breaker_open consecutive_failures=%d cooldown_ms=%d error=%v", b.consecutive, b.cooldown.Milliseconds(), err)
+	}
+}
+
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.open:
+		return BreakerClosed
+	case b.probing || b.now().Sub(b.openedAt) >= b.cooldown:
+		return BreakerHalfOpen
+	default:
+		return BreakerOpen
+	}
+}
+
+func (b *CircuitBreaker) Stats() Stats {
+	var stats Stats
+	if provider, ok := b.inner.(StatsProvider); ok {
+		stats = provider.Stats()
+	}
+	stats.BreakerState = b.State()
+	stats.BreakerOpen = stats.BreakerState != BreakerClosed
+	return stats
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+)
+
+type scriptedGenerator struct {
+	errs  []error
+	calls int
+}
+
+func (g *scriptedGenerator) Enabled() bool { return true }
+
+func (g *scriptedGenerator) Close() error { return nil }
+
+func (g *scriptedGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	g.calls++
+	if len(g.errs) == 0 {
+		return "siema", nil
+	}
+	err := g.errs[0]
+	g.errs = g.errs[1:]
+	return "", err
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	boom := errors.New("connection refused")
+	inner := &scriptedGenerator{errs: []error{boom, boom, boom, boom}}
+	breaker := NewCircuitBreaker(inner, config.LLMConfig{BreakerFailures: 3, BreakerCooldown: time.Minute})
+	now := time.Unix(1712345000, 0)
+	breaker.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		wantErr   error
+		wantCalls int
+		wantState string
+	}{
+		{name: "first failure", wantErr: boom, wantCalls: 1, wantState: BreakerClosed},
+		{name: "second failure", wantErr: boom, wantCalls: 2, wantState: BreakerClosed},
+		{name: "third failure opens", wantErr: boom, wantCalls: 3, wantState: BreakerOpen},
+		{name: "open fails fast", advance: 30 * time.Second, wantErr: ErrBreakerOpen, wantCalls: 3, wantState: BreakerOpen},
+		{name: "failed probe reopens", advance: 31 * time.Second, wantErr: boom, wantCalls: 4, wantState: BreakerOpen},
+		{name: "still cooling off", advance: 10 * time.Second, wantErr: ErrBreakerOpen, wantCalls: 4, wantState: BreakerOpen},
+		{name: "successful probe closes", advance: time.Minute, wantCalls: 5, wantState: BreakerClosed},
+		{name: "closed passes through", wantCalls: 6, wantState: BreakerClosed},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		_, err := breaker.Generate(context.Background(), Request{})
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if inner.calls != step.wantCalls {
+			t.Fatalf("%s: inner calls = %d, want %d", step.name, inner.calls, step.wantCalls)
+		}
+		if state := breaker.State(); state != step.wantState {
+			t.Fatalf("%s: state = %s, want %s", step.name, state, step.wantState)
+		}
+	}
+}
+
+func TestCircuitBreakerAllowsSingleProbe(t *testing.T) {
+	inner := &slowGenerator{delay: 50 * time.Millisecond}
+	breaker := NewCircuitBreaker(inner, config.LLMConfig{BreakerFailures: 1, BreakerCooldown: time.Minute})
+	now := time.Unix(1712345000, 0)
+	breaker.now = func() time.Time { return now }
+	breaker.record(false, errors.New("timeout"))
+	now = now.Add(time.Minute)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := breaker.Generate(context.Background(), Request{})
+		done <- err
+	}()
+	for inner.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := breaker.Generate(context.Background(), Request{}); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("second call during probe error = %v, want ErrBreakerOpen", err)
+	}
+	if stats := breaker.Stats(); !stats.BreakerOpen || stats.BreakerState != BreakerHalfOpen {
+		t.Fatalf("stats during probe = %+v", stats)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if stats := breaker.Stats(); stats.BreakerOpen || stats.BreakerState != BreakerClosed {
+		t.Fatalf("stats after probe = %+v", stats)
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+)
+
+const responseCacheSize = 256
+
+// responseCache remembers recent responses by prompt, so a plan request the
+// plugin retries after a timeout does not pay for a second generation.
+type responseCache struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type cacheEntry struct {
+	key      [sha256.Size]byte
+	response string
+	expires  time.Time
+}
+
+// newResponseCache returns nil, a cache that never hits, when caching is
+// disabled.
+func newResponseCache(cfg config.LLMConfig) *responseCache {
+	if cfg.CacheDisabled || cfg.CacheTTL <= 0 {
+		return nil
+	}
+	return &responseCache{
+		ttl:     cfg.CacheTTL,
+		size:    responseCacheSize,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+func (c *responseCache) get(prompt, botID string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	key := sha256.Sum256([]byte(prompt))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		metrics.LLMCacheMisses.Inc()
+		return "", false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		metrics.LLMCacheMisses.Inc()
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	metrics.LLMCacheHits.Inc()
+	logging.Infof("llm_cache_hit bot_id=%s ttl_left_ms=%d", botID, entry.expires.Sub(c.now()).Milliseconds())
+	return entry.response, true
+}
+
+func (c *responseCache) put(prompt, response string) {
+	if c == nil {
+		return
+	}
+	key := sha256.Sum256([]byte(prompt))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.response = response
+		entry.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, response: response, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
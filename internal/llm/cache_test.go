@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/models"
+)
+
+func TestResponseCacheExpiresAndEvicts(t *testing.T) {
+	now := time.Unix(1712345000, 0)
+	cache := newResponseCache(config.LLMConfig{CacheTTL: 10 * time.Second})
+	cache.now = func() time.Time { return now }
+	cache.size = 2
+
+	cache.put("a", "siema")
+	if got, ok := cache.get("a", "bot-1"); !ok || got != "siema" {
+		t.Fatalf("get(a) = %q, %v", got, ok)
+	}
+	now = now.Add(10 * time.Second)
+	if _, ok := cache.get("a", "bot-1"); ok {
+		t.Fatal("expected entry to expire after the TTL")
+	}
+
+	cache.put("a", "1")
+	cache.put("b", "2")
+	cache.get("a", "bot-1")
+	cache.put("c", "3")
+	if _, ok := cache.get("b", "bot-1"); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	for _, prompt := range []string{"a", "c"} {
+		if _, ok := cache.get(prompt, "bot-1"); !ok {
+			t.Fatalf("expected %q to stay cached", prompt)
+		}
+	}
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	for _, cfg := range []config.LLMConfig{{CacheTTL: 0}, {CacheTTL: time.Second, CacheDisabled: true}} {
+		cache := newResponseCache(cfg)
+		cache.put("a", "siema")
+		if _, ok := cache.get("a", "bot-1"); ok {
+			t.Fatalf("expected no cache for %+v", cfg)
+		}
+	}
+}
+
+func TestResponseCacheConcurrentAccess(t *testing.T) {
+	cache := newResponseCache(config.LLMConfig{CacheTTL: time.Minute})
+	cache.size = 16
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				prompt := fmt.Sprintf("prompt-%d", (worker+i)%32)
+				if got, ok := cache.get(prompt, "bot-1"); ok && got != "reply-"+prompt {
+					t.Errorf("get(%s) = %q", prompt, got)
+					return
+				}
+				cache.put(prompt, "reply-"+prompt)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if cache.order.Len() > 16 || len(cache.entries) != cache.order.Len() {
+		t.Fatalf("cache grew past its bound: %d entries, %d in order", len(cache.entries), cache.order.Len())
+	}
+}
+
+func TestServerClientServesIdenticalPromptsFromCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"content":"siema, kto gra?"}`))
+	}))
+	defer server.Close()
+
+	client := newServerClient(config.LLMConfig{ServerURL: server.URL, MaxResponseChars: 120, CacheTTL: time.Minute})
+	req := Request{
+		Bot:        models.BotProfile{BotID: "bot-1", Name: "Kuba"},
+		RecentChat: []models.ChatMessage{{Sender: "Steve", SenderType: "PLAYER", Message: "hej"}},
+	}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+
+	hits := metrics.LLMCacheHits.Value()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := client.Generate(context.Background(), req); err != nil || got != "siema, kto gra?" {
+				t.Errorf("Generate() = %q, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("server called %d times, want 1", got)
+	}
+	if got := metrics.LLMCacheHits.Value() - hits; got != 10 {
+		t.Fatalf("cache hits = %d, want 10", got)
+	}
+
+	req.RecentChat = append(req.RecentChat, models.ChatMessage{Sender: "Alex", SenderType: "PLAYER", Message: "siema"})
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("changed prompt should miss the cache, server called %d times", got)
+	}
+}
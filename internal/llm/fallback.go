@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+// FallbackGenerator tries its backends in priority order. A backend that
+// fails is moved to the back of the line for the retry window, so calls do
+// not pay for an unreachable primary every time.
+type FallbackGenerator struct {
+	backends []*fallbackBackend
+	retry    time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+}
+
+type fallbackBackend struct {
+	name    string
+	gen     Generator
+	down    bool
+	retryAt time.Time
+}
+
+// newBackendsClient builds one ServerClient per configured backend. Only the
+// primary runs the health poller that feeds /readyz.
+func newBackendsClient(cfg config.LLMConfig, prompt *template.Template) *FallbackGenerator {
+	backends := make([]*fallbackBackend, 0, len(cfg.Backends))
+	for i, backend := range cfg.Backends {
+		client := newServerClient(backendConfig(cfg, backend, i == 0))
+		client.prompt = prompt
+		backends = append(backends, &fallbackBackend{name: backend.Name, gen: client})
+	}
+	return newFallbackGenerator(backends, cfg.BackendRetry)
+}
+
+func backendConfig(cfg config.LLMConfig, backend config.LLMBackend, primary bool) config.LLMConfig {
+	cfg.ServerURL = backend.URL
+	if backend.API != "" {
+		cfg.ServerAPI = backend.API
+	}
+	if backend.APIKey != "" {
+		cfg.ServerAPIKey = backend.APIKey
+	}
+	if backend.Model != "" {
+		cfg.ServerModel = backend.Model
+	}
+	if !primary {
+		cfg.HealthPollInterval = 0
+	}
+	cfg.Backends = nil
+	return cfg
+}
+
+func newFallbackGenerator(backends []*fallbackBackend, retry time.Duration) *FallbackGenerator {
+	return &FallbackGenerator{backends: backends, retry: retry, now: time.Now}
+}
+
+func (f *FallbackGenerator) Enabled() bool {
+	for _, backend := range f.backends {
+		if backend.gen.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FallbackGenerator) Close() error {
+	var errs []error
+	for _, backend := range f.backends {
+		errs = append(errs, backend.gen.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FallbackGenerator) Reconfigure(cfg config.LLMConfig) {
+	for _, backend := range f.backends {
+		if inner, ok := backend.gen.(Reconfigurer); ok {
+			inner.Reconfigure(cfg)
+		}
+	}
+}
+
+func (f *FallbackGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	var lastErr error
+	for _, backend := range f.order() {
+		message, err := backend.gen.Generate(ctx, req)
+		if err == nil {
+			f.markUp(backend)
+			recordBackend(ctx, req.Bot.BotID, backend.name)
+			if backend == f.backends[0] {
+				logging.Ctx(ctx).Debugf("llm_backend_used bot_id=%s backend=%s fallback=false", req.Bot.BotID, backend.name)
+			} else {
+				logging.Ctx(ctx).Infof("llm_backend_used bot_id=%s backend=%s fallback=true", req.Bot.BotID, backend.name)
+			}
+			return message, nil
+		}
+		// Bad replies and callers that gave up say nothing about the backend.
+		if errors.Is(err, errEmptyResponse) || errors.Is(err, errPromptLeak) || errors.Is(ctx.Err(), context.Canceled) {
+			return "", err
+		}
+		f.markDown(backend, err)
+		lastErr = fmt.Errorf("llm backend %s: %w", backend.name, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		return "", errors.New("llm disabled")
+	}
+	return "", lastErr
+}
+
+// order lists backends by priority, with those still in their retry window
+// last: they are only tried when everything else failed too.
+func (f *FallbackGenerator) order() []*fallbackBackend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	ready := make([]*fallbackBackend, 0, len(f.backends))
+	var waiting []*fallbackBackend
+	for _, backend := range f.backends {
+		if !backend.gen.Enabled() {
+			continue
+		}
+		if backend.down && now.Before(backend.retryAt) {
+			waiting = append(waiting, backend)
+			continue
+		}
+		ready = append(ready, backend)
+	}
+	return append(ready, waiting...)
+}
+
+func (f *FallbackGenerator) markDown(backend *fallbackBackend, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	backend.retryAt = f.now().Add(f.retry)
+	if !backend.down {
+		backend.down = true
+		logging.Warnf("llm_backend_down backend=%s retry_ms=%d error=%v", backend.name, f.retry.Milliseconds(), err)
+	}
+}
+
+func (f *FallbackGenerator) markUp(backend *fallbackBackend) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if backend.down {
+		backend.down = false
+		logging.Infof("llm_backend_up backend=%s", backend.name)
+	}
+}
+
+type backendTraceKey struct{}
+
+type backendTrace struct {
+	mu   sync.Mutex
+	uses []models.LLMBackendUse
+}
+
+// WithBackendTrace returns a context in which FallbackGenerator records the
+// backend behind each reply; BackendsUsed reads them back.
+func WithBackendTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backendTraceKey{}, &backendTrace{})
+}
+
+func BackendsUsed(ctx context.Context) []models.LLMBackendUse {
+	trace, ok := ctx.Value(backendTraceKey{}).(*backendTrace)
+	if !ok {
+		return nil
+	}
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	return append([]models.LLMBackendUse(nil), trace.uses...)
+}
+
+func recordBackend(ctx context.Context, botID, backend string) {
+	trace, ok := ctx.Value(backendTraceKey{}).(*backendTrace)
+	if !ok {
+		return
+	}
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.uses = append(trace.uses, models.LLMBackendUse{BotID: botID, Backend: backend})
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/models"
+)
+
+func TestFallbackGeneratorSkipsFailedBackend(t *testing.T) {
+	boom := errors.New("connection refused")
+	primary := &scriptedGenerator{errs: []error{boom, boom}}
+	local := &scriptedGenerator{}
+	fallback := newFallbackGenerator([]*fallbackBackend{{name: "gpu", gen: primary}, {name: "local", gen: local}}, time.Minute)
+	now := time.Unix(1712345000, 0)
+	fallback.now = func() time.Time { return now }
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		wantBackend  string
+		wantPrimary  int
+		wantFallback int
+	}{
+		{name: "primary fails over", wantBackend: "local", wantPrimary: 1, wantFallback: 1},
+		{name: "primary skipped in retry window", advance: 30 * time.Second, wantBackend: "local", wantPrimary: 1, wantFallback: 2},
+		{name: "primary retried and still down", advance: 31 * time.Second, wantBackend: "local", wantPrimary: 2, wantFallback: 3},
+		{name: "primary recovered", advance: time.Minute, wantBackend: "gpu", wantPrimary: 3, wantFallback: 3},
+		{name: "primary preferred again", wantBackend: "gpu", wantPrimary: 4, wantFallback: 3},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		ctx := WithBackendTrace(context.Background())
+		message, err := fallback.Generate(ctx, Request{Bot: models.BotProfile{BotID: "bot-1"}})
+		if err != nil || message != "siema" {
+			t.Fatalf("%s: Generate() = %q, %v", step.name, message, err)
+		}
+		if uses := BackendsUsed(ctx); len(uses) != 1 || uses[0] != (models.LLMBackendUse{BotID: "bot-1", Backend: step.wantBackend}) {
+			t.Fatalf("%s: backends = %+v, want %s", step.name, uses, step.wantBackend)
+		}
+		if primary.calls != step.wantPrimary || local.calls != step.wantFallback {
+			t.Fatalf("%s: calls = %d/%d, want %d/%d", step.name, primary.calls, local.calls, step.wantPrimary, step.wantFallback)
+		}
+	}
+}
+
+func TestFallbackGeneratorErrors(t *testing.T) {
+	boom := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		primary   error
+		wantErr   error
+		wantLocal int
+		wantDown  bool
+	}{
+		{name: "all backends fail", primary: boom, wantErr: boom, wantLocal: 1, wantDown: true},
+		{name: "empty reply is not a backend failure", primary: errEmptyResponse, wantErr: errEmptyResponse},
+		{name: "prompt leak is not a backend failure", primary: errPromptLeak, wantErr: errPromptLeak},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &scriptedGenerator{errs: []error{tt.primary}}
+			local := &scriptedGenerator{errs: []error{boom}}
+			fallback := newFallbackGenerator([]*fallbackBackend{{name: "gpu", gen: primary}, {name: "local", gen: local}}, time.Minute)
+
+			_, err := fallback.Generate(context.Background(), Request{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if local.calls != tt.wantLocal {
+				t.Fatalf("fallback calls = %d, want %d", local.calls, tt.wantLocal)
+			}
+			if fallback.backends[0].down != tt.wantDown {
+				t.Fatalf("primary down = %t, want %t", fallback.backends[0].down, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestNewClientWithBackends(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	var gotAuth string
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"siema, kto gra?"}}]}`))
+	}))
+	defer local.Close()
+
+	client, err := NewClient(config.LLMConfig{
+		Backends: []config.LLMBackend{
+			{Name: "gpu", URL: primary.URL},
+			{Name: "local", URL: local.URL, API: config.ServerAPIOpenAIChat, APIKey: "secret"},
+		},
+		BackendRetry: time.Minute,
+		Timeout:      time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	defer client.Close()
+
+	ctx := WithBackendTrace(context.Background())
+	message, err := client.Generate(ctx, Request{Bot: models.BotProfile{BotID: "bot-1", Name: "Kuba"}})
+	if err != nil || message != "siema, kto gra?" {
+		t.Fatalf("Generate() = %q, %v", message, err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Fatalf("fallback auth = %q, want per-backend key", gotAuth)
+	}
+	if uses := BackendsUsed(ctx); len(uses) != 1 || uses[0].Backend != "local" {
+		t.Fatalf("backends = %+v", uses)
+	}
+}
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+const (
+	FaultLatency   = "latency"
+	FaultReset     = "reset"
+	FaultMalformed = "malformed"
+	FaultPartial   = "partial"
+	FaultStuck     = "stuck"
+)
+
+type FaultScenario struct {
+	Name   string      `json:"name"`
+	Seed   int64       `json:"seed"`
+	Faults []FaultRule `json:"faults"`
+}
+
+type FaultRule struct {
+	Type        string  `json:"type"`
+	Probability float64 `json:"probability"`
+	DelayMS     int64   `json:"delay_ms"`
+}
+
+type FaultInjector struct {
+	inner    Generator
+	scenario FaultScenario
+	cfg      config.LLMConfig
+	mu       sync.Mutex
+	rng      *rand.Rand
+}
+
+func LoadFaultScenario(path string) (FaultScenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FaultScenario{}, fmt.Errorf("read fault scenario: %w", err)
+	}
+	var scenario FaultScenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return FaultScenario{}, fmt.Errorf("decode fault scenario %s: %w", path, err)
+	}
+	for _, rule := range scenario.Faults {
+		switch rule.Type {
+		case FaultLatency, FaultReset, FaultMalformed, FaultPartial, FaultStuck:
+		default:
+			return FaultScenario{}, fmt.Errorf("fault scenario %s: unknown fault type %q", path, rule.Type)
+		}
+		if rule.Probability < 0 || rule.Probability > 1 {
+			return FaultScenario{}, fmt.Errorf("fault scenario %s: probability for %s must be within [0,1]", path, rule.Type)
+		}
+	}
+	if scenario.Name == "" {
+		scenario.Name = path
+	}
+	return scenario, nil
+}
+
+func WithFaultInjection(inner Generator, cfg config.LLMConfig) (Generator, error) {
+	path := strings.TrimSpace(cfg.FaultInjection)
+	if path == "" {
+		return inner, nil
+	}
+	scenario, err := LoadFaultScenario(path)
+	if err != nil {
+		return inner, err
+	}
+	logging.Warnf("llm_fault_injection_enabled scenario=%s seed=%d faults=%d", scenario.Name, scenario.Seed, len(scenario.Faults))
+	return NewFaultInjector(inner, scenario, cfg), nil
+}
+
+func NewFaultInjector(inner Generator, scenario FaultScenario, cfg config.LLMConfig) *FaultInjector {
+	if inner == nil {
+		inner = Noop{}
+	}
+	return &FaultInjector{
+		inner:    inner,
+		scenario: scenario,
+		cfg:      cfg,
+		rng:      rand.New(rand.NewSource(scenario.Seed)),
+	}
+}
+
+func (f *FaultInjector) Enabled() bool {
+	return f.inner.Enabled()
+}
+
+func (f *FaultInjector) Close() error {
+	return f.inner.Close()
+}
+
+func (f *FaultInjector) Reconfigure(cfg config.LLMConfig) {
+	if inner, ok := f.inner.(Reconfigurer); ok {
+		inner.Reconfigure(cfg)
+	}
+}
+
+func (f *FaultInjector) Generate(ctx context.Context, req Request) (string, error) {
+	ctx, cancel := withTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+
+	faults, cut := f.draw()
+	for _, rule := range faults {
+		logging.Ctx(ctx).Debugf("llm_fault_injected scenario=%s type=%s bot_id=%s", f.scenario.Name, rule.Type, req.Bot.BotID)
+		switch rule.Type {
+		case FaultLatency:
+			if err := sleepContext(ctx, time.Duration(rule.DelayMS)*time.Millisecond); err != nil {
+				return "", fmt.Errorf("llm timeout after %s", timeoutLabel(f.cfg.Timeout))
+			}
+		case FaultReset:
+			return "", errors.New("llm server request failed: connection reset by peer")
+		case FaultMalformed:
+			// Run a truncated body through the real parser, as a broken server reply would.
+			if message := parseServerResponse("", req.Bot.Name, []byte(`{"content": "sie`), f.cfg); message != "" {
+				return message, nil
+			}
+			return "", errEmptyResponse
+		case FaultStuck:
+			<-ctx.Done()
+			return "", fmt.Errorf("llm timeout after %s", timeoutLabel(f.cfg.Timeout))
+		}
+	}
+
+	message, err := f.inner.Generate(ctx, req)
+	if err != nil {
+		return message, err
+	}
+	for _, rule := range faults {
+		if rule.Type == FaultPartial {
+			message = truncateRunes(message, cut(runeCount(message)))
+			message = normalizeLLMOutput(message, req.Bot.Name, f.cfg.MaxResponseChars, f.cfg.MaxResponseWords)
+		}
+	}
+	return message, nil
+}
+
+// draw picks the faults for one call. All randomness is taken up front under
+// the lock so concurrent callers still see a deterministic sequence per seed.
+func (f *FaultInjector) draw() ([]FaultRule, func(int) int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var faults []FaultRule
+	for _, rule := range f.scenario.Faults {
+		if f.rng.Float64() < rule.Probability {
+			faults = append(faults, rule)
+		}
+	}
+	fraction := f.rng.Float64()
+	return faults, func(length int) int {
+		if length <= 1 {
+			return length
+		}
+		return 1 + int(fraction*float64(length-1))
+	}
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+)
+
+// flightGroup lets concurrent generations for the same prompt share one
+// backend call. The shared call is detached from the caller that started
+// it and is only cancelled once every waiter has given up.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[[sha256.Size]byte]*flightCall
+}
+
+type flightCall struct {
+	done     chan struct{}
+	response string
+	err      error
+	waiters  int
+	cancel   context.CancelFunc
+}
+
+func (g *flightGroup) do(ctx context.Context, prompt string, generate func(context.Context) (string, error)) (string, bool, error) {
+	key := sha256.Sum256([]byte(prompt))
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[[sha256.Size]byte]*flightCall)
+	}
+	call, shared := g.calls[key]
+	if !shared {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			response, err := generate(callCtx)
+			g.mu.Lock()
+			g.forget(key, call)
+			call.response, call.err = response, err
+			g.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.response, shared, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			g.forget(key, call)
+			call.cancel()
+		}
+		g.mu.Unlock()
+		return "", shared, ctx.Err()
+	}
+}
+
+// forget removes call unless a newer call for the same prompt replaced it;
+// an abandoned call must not be joined by later callers.
+func (g *flightGroup) forget(key [sha256.Size]byte, call *flightCall) {
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/models"
+)
+
+func waitForWaiters(t *testing.T, g *flightGroup, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		g.mu.Lock()
+		waiters := 0
+		for _, call := range g.calls {
+			waiters += call.waiters
+		}
+		g.mu.Unlock()
+		if waiters == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters = %d, want %d", waiters, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServerClientSharesConcurrentGenerations(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"content":"siema, kto gra?"}`))
+	}))
+	defer server.Close()
+
+	client := newServerClient(config.LLMConfig{ServerURL: server.URL, MaxResponseChars: 120})
+	req := Request{
+		Bot:        models.BotProfile{BotID: "bot-1", Name: "Kuba"},
+		RecentChat: []models.ChatMessage{{Sender: "Steve", SenderType: "PLAYER", Message: "hej"}},
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response, err := client.Generate(context.Background(), req)
+			if err != nil {
+				t.Errorf("Generate() error: %v", err)
+			}
+			results[i] = response
+		}(i)
+	}
+	waitForWaiters(t, &client.flight, 2)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("backend called %d times, want 1", got)
+	}
+	for _, response := range results {
+		if response != "siema, kto gra?" {
+			t.Fatalf("unexpected responses %q", results)
+		}
+	}
+}
+
+func TestFlightGroupSurvivesFirstCallerCancel(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	generate := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "siema", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(firstCtx, "prompt", generate)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		response, shared, err := g.do(context.Background(), "prompt", generate)
+		if err != nil || !shared {
+			t.Errorf("second caller: shared=%v err=%v", shared, err)
+		}
+		second <- response
+	}()
+	waitForWaiters(t, &g, 2)
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller error = %v, want context.Canceled", err)
+	}
+	close(release)
+	if got := <-second; got != "siema" {
+		t.Fatalf("second caller got %q", got)
+	}
+}
+
+func TestFlightGroupCancelsAbandonedCall(t *testing.T) {
+	var g flightGroup
+	cancelled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.do(ctx, "prompt", func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return "", ctx.Err()
+		})
+	}()
+	waitForWaiters(t, &g, 1)
+	cancel()
+	<-done
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shared call kept running after its last caller left")
+	}
+	if response, shared, err := g.do(context.Background(), "prompt", func(context.Context) (string, error) { return "nowy", nil }); err != nil || shared || response != "nowy" {
+		t.Fatalf("new caller joined the abandoned call: %q shared=%v err=%v", response, shared, err)
+	}
+}
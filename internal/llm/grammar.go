@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+// builtinGrammar allows exactly one line of chat without quotes, capped at
+// maxChars runes, or the silence token.
+func builtinGrammar(maxChars int) string {
+	line := `[^\r\n"'_] [^\r\n"']*`
+	if maxChars > 1 {
+		line = fmt.Sprintf(`[^\r\n"'_] [^\r\n"']{0,%d}`, maxChars-1)
+	}
+	return "root ::= \"__SILENCE__\" | line\nline ::= " + line + "\n"
+}
+
+// serverGrammar returns the grammar text sent with server requests. An
+// unreadable LLM_GRAMMAR_PATH is logged and generation runs unconstrained.
+func serverGrammar(cfg config.LLMConfig) string {
+	if cfg.GrammarPath != "" {
+		data, err := os.ReadFile(cfg.GrammarPath)
+		if err != nil {
+			logging.Warnf("llm_grammar_load_failed path=%s error=%v", cfg.GrammarPath, err)
+			return ""
+		}
+		return string(data)
+	}
+	if cfg.Grammar == config.GrammarBuiltin {
+		return builtinGrammar(cfg.MaxResponseChars)
+	}
+	return ""
+}
+
+func grammarArgs(cfg config.LLMConfig) []string {
+	if cfg.GrammarPath != "" {
+		return []string{"--grammar-file", cfg.GrammarPath}
+	}
+	if cfg.Grammar == config.GrammarBuiltin {
+		return []string{"--grammar", builtinGrammar(cfg.MaxResponseChars)}
+	}
+	return nil
+}
+
+// grammarRejected reports whether a server failed a request because it does
+// not know the grammar parameter, as OpenAI-compatible servers other than
+// llama-server do.
+func grammarRejected(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	if status.code != http.StatusBadRequest && status.code != http.StatusUnprocessableEntity {
+		return false
+	}
+	return status.body == "" || strings.Contains(strings.ToLower(status.body), "grammar")
+}
+
+// dropGrammar stops sending the grammar; only the first caller logs.
+func (c *ServerClient) dropGrammar(err error) {
+	if c.grammarOff.CompareAndSwap(false, true) {
+		logging.Warnf("llm_grammar_rejected server_api=%s error=%v fallback=no_grammar", c.settings().ServerAPI, err)
+	}
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/models"
+)
+
+func TestBuiltinGrammar(t *testing.T) {
+	grammar := builtinGrammar(80)
+	for _, want := range []string{`root ::= "__SILENCE__" | line`, `{0,79}`, `\r\n`} {
+		if !strings.Contains(grammar, want) {
+			t.Fatalf("expected %q in grammar: %q", want, grammar)
+		}
+	}
+	if strings.Contains(builtinGrammar(0), "{") {
+		t.Fatalf("unlimited grammar should not cap the length: %q", builtinGrammar(0))
+	}
+}
+
+func TestCommandArgsGrammar(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.LLMConfig
+		want []string
+	}{
+		{name: "none", cfg: config.LLMConfig{}},
+		{name: "builtin", cfg: config.LLMConfig{Grammar: config.GrammarBuiltin, MaxResponseChars: 60}, want: []string{"--grammar", builtinGrammar(60)}},
+		{name: "file", cfg: config.LLMConfig{Grammar: config.GrammarBuiltin, GrammarPath: "/etc/chat.gbnf"}, want: []string{"--grammar-file", "/etc/chat.gbnf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := (&Client{cfg: tt.cfg}).commandArgs(Request{}, "prompt")
+			joined := strings.Join(args, "|")
+			if tt.want == nil {
+				if strings.Contains(joined, "--grammar") {
+					t.Fatalf("unexpected grammar args: %q", joined)
+				}
+				return
+			}
+			if !strings.Contains(joined, strings.Join(tt.want, "|")) {
+				t.Fatalf("expected %q in args: %q", tt.want, joined)
+			}
+		})
+	}
+}
+
+func TestServerPayloadGrammar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.gbnf")
+	if err := os.WriteFile(path, []byte("root ::= \"hej\"\n"), 0o600); err != nil {
+		t.Fatalf("write grammar: %v", err)
+	}
+	tests := []struct {
+		name string
+		cfg  config.LLMConfig
+		want string
+	}{
+		{name: "none", cfg: config.LLMConfig{}},
+		{name: "builtin", cfg: config.LLMConfig{Grammar: config.GrammarBuiltin, MaxResponseChars: 80}, want: builtinGrammar(80)},
+		{name: "file", cfg: config.LLMConfig{GrammarPath: path}, want: "root ::= \"hej\"\n"},
+		{name: "missing file", cfg: config.LLMConfig{GrammarPath: filepath.Join(t.TempDir(), "missing.gbnf")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := newServerClient(tt.cfg).requestPayload(Request{}, "", "prompt")
+			got, ok := payload["grammar"]
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("unexpected grammar in payload: %v", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("grammar = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerClientDropsRejectedGrammar(t *testing.T) {
+	var requests, withGrammar atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		_ = json.Unmarshal(body, &payload)
+		if _, ok := payload["grammar"]; ok {
+			withGrammar.Add(1)
+			http.Error(w, `{"error":"Unrecognized request argument supplied: grammar"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"siema"}}]}`))
+	}))
+	defer server.Close()
+
+	client := newServerClient(config.LLMConfig{
+		ServerURL:        server.URL,
+		ServerAPI:        config.ServerAPIOpenAIChat,
+		Grammar:          config.GrammarBuiltin,
+		MaxResponseChars: 80,
+	})
+	req := Request{Bot: models.BotProfile{Name: "Kuba"}, RecentChat: []models.ChatMessage{{Sender: "Steve", SenderType: "PLAYER", Message: "siema"}}}
+	for i := 0; i < 2; i++ {
+		message, err := client.Generate(context.Background(), req)
+		if err != nil || message != "siema" {
+			t.Fatalf("Generate() = %q, %v", message, err)
+		}
+	}
+	if requests.Load() != 3 || withGrammar.Load() != 1 {
+		t.Fatalf("expected one rejected grammar request then plain ones, got requests=%d with_grammar=%d", requests.Load(), withGrammar.Load())
+	}
+}
+
+func TestServerClientKeepsGrammarOnOtherErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "context too long", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := newServerClient(config.LLMConfig{ServerURL: server.URL, Grammar: config.GrammarBuiltin})
+	if _, err := client.Generate(context.Background(), Request{Bot: models.BotProfile{Name: "Kuba"}}); err == nil {
+		t.Fatal("expected error for a 400 response")
+	}
+	if requests.Load() != 1 || client.grammarOff.Load() {
+		t.Fatalf("unrelated 400 should not drop the grammar, requests=%d grammar_off=%t", requests.Load(), client.grammarOff.Load())
+	}
+}
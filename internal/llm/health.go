@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+const (
+	ServerHealthy  = "healthy"
+	ServerDegraded = "degraded"
+)
+
+// ServerStatus is the latest llama-server snapshot taken by the health
+// poller. State is empty when no poller is running.
+type ServerStatus struct {
+	State      string
+	Reason     string
+	SlotsTotal int
+	SlotsBusy  int
+	Latency    time.Duration
+	CheckedAt  time.Time
+}
+
+var serverStatus atomic.Pointer[ServerStatus]
+
+func Status() ServerStatus {
+	if status := serverStatus.Load(); status != nil {
+		return *status
+	}
+	return ServerStatus{}
+}
+
+// healthPoller polls /health and /slots in the background so a slow plan can
+// be told apart from an overloaded llama-server.
+type healthPoller struct {
+	baseURL  string
+	auth     serverAuth
+	client   *http.Client
+	interval time.Duration
+	state    string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func startHealthPoller(cfg config.LLMConfig, auth serverAuth) *healthPoller {
+	if cfg.HealthPollInterval <= 0 {
+		return nil
+	}
+	p := newHealthPoller(cfg.ServerURL, auth, cfg.HealthPollInterval)
+	go p.run()
+	return p
+}
+
+func newHealthPoller(serverURL string, auth serverAuth, interval time.Duration) *healthPoller {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &healthPoller{
+		baseURL:  strings.TrimSuffix(strings.TrimRight(strings.TrimSpace(serverURL), "/"), "/v1"),
+		auth:     auth,
+		client:   &http.Client{},
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *healthPoller) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.poll()
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *healthPoller) close() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		p.cancel()
+		<-p.done
+		serverStatus.Store(nil)
+	})
+}
+
+func (p *healthPoller) poll() {
+	ctx, cancel := context.WithTimeout(p.ctx, p.interval)
+	defer cancel()
+
+	started := time.Now()
+	status := ServerStatus{State: ServerHealthy, CheckedAt: started}
+	if _, err := p.get(ctx, "/health"); err != nil {
+		status.State = ServerDegraded
+		status.Reason = err.Error()
+	}
+	status.Latency = time.Since(started)
+	if total, busy, ok := p.slots(ctx); ok {
+		status.SlotsTotal, status.SlotsBusy = total, busy
+		if status.State == ServerHealthy && total > 0 && busy >= total {
+			status.State = ServerDegraded
+			status.Reason = "all_slots_busy"
+		}
+	}
+	if p.ctx.Err() != nil {
+		return
+	}
+	serverStatus.Store(&status)
+
+	if status.State == p.state {
+		return
+	}
+	previous := p.state
+	p.state = status.State
+	if status.State == ServerDegraded {
+		logging.Warnf("llm_server_degraded previous=%s reason=%q slots_busy=%d slots_total=%d latency_ms=%d", previous, status.Reason, status.SlotsBusy, status.SlotsTotal, status.Latency.Milliseconds())
+		return
+	}
+	logging.Infof("llm_server_healthy previous=%s slots_busy=%d slots_total=%d latency_ms=%d", previous, status.SlotsBusy, status.SlotsTotal, status.Latency.Milliseconds())
+}
+
+// slots counts busy slots; ok is false when the server does not expose
+// /slots (llama-server needs --slots on newer builds).
+func (p *healthPoller) slots(ctx context.Context) (total, busy int, ok bool) {
+	body, err := p.get(ctx, "/slots")
+	if err != nil {
+		return 0, 0, false
+	}
+	var slots []struct {
+		IsProcessing *bool `json:"is_processing"`
+		State        *int  `json:"state"`
+	}
+	if err := json.Unmarshal(body, &slots); err != nil {
+		return 0, 0, false
+	}
+	for _, slot := range slots {
+		if (slot.IsProcessing != nil && *slot.IsProcessing) || (slot.State != nil && *slot.State != 0) {
+			busy++
+		}
+	}
+	return len(slots), busy, true
+}
+
+func (p *healthPoller) get(ctx context.Context, path string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.auth.apply(request)
+	resp, err := p.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s read response: %w", path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s status=%d", path, resp.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+)
+
+type fakeLlamaServer struct {
+	health atomic.Int32
+	slots  atomic.Value
+	polls  atomic.Int32
+}
+
+func newFakeLlamaServer(t *testing.T) (*fakeLlamaServer, *httptest.Server) {
+	t.Helper()
+	fake := &fakeLlamaServer{}
+	fake.health.Store(http.StatusOK)
+	fake.slots.Store(`[{"id":0,"is_processing":false},{"id":1,"is_processing":false}]`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fake.polls.Add(1)
+			w.WriteHeader(int(fake.health.Load()))
+		case "/slots":
+			_, _ = w.Write([]byte(fake.slots.Load().(string)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { serverStatus.Store(nil) })
+	return fake, server
+}
+
+func TestHealthPollerSnapshots(t *testing.T) {
+	tests := []struct {
+		name       string
+		health     int
+		slots      string
+		wantState  string
+		wantReason string
+		wantTotal  int
+		wantBusy   int
+	}{
+		{name: "idle", health: http.StatusOK, slots: `[{"id":0,"is_processing":false},{"id":1,"is_processing":true}]`, wantState: ServerHealthy, wantTotal: 2, wantBusy: 1},
+		{name: "all slots busy", health: http.StatusOK, slots: `[{"id":0,"is_processing":true},{"id":1,"is_processing":true}]`, wantState: ServerDegraded, wantReason: "all_slots_busy", wantTotal: 2, wantBusy: 2},
+		{name: "legacy slot state", health: http.StatusOK, slots: `[{"id":0,"state":1},{"id":1,"state":0}]`, wantState: ServerHealthy, wantTotal: 2, wantBusy: 1},
+		{name: "slots endpoint disabled", health: http.StatusOK, slots: `{"error":"This server does not support slots endpoint."}`, wantState: ServerHealthy},
+		{name: "loading model", health: http.StatusServiceUnavailable, slots: `[]`, wantState: ServerDegraded, wantReason: "/health status=503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeLlamaServer(t)
+			fake.health.Store(int32(tt.health))
+			fake.slots.Store(tt.slots)
+
+			poller := newHealthPoller(server.URL+"/v1/", serverAuth{}, time.Second)
+			poller.poll()
+			status := Status()
+			if status.State != tt.wantState || status.Reason != tt.wantReason {
+				t.Fatalf("state = %q reason = %q, want %q %q", status.State, status.Reason, tt.wantState, tt.wantReason)
+			}
+			if status.SlotsTotal != tt.wantTotal || status.SlotsBusy != tt.wantBusy {
+				t.Fatalf("slots = %d/%d, want %d/%d", status.SlotsBusy, status.SlotsTotal, tt.wantBusy, tt.wantTotal)
+			}
+			if status.CheckedAt.IsZero() {
+				t.Fatal("expected checked time")
+			}
+		})
+	}
+}
+
+func TestHealthPollerTracksTransitions(t *testing.T) {
+	fake, server := newFakeLlamaServer(t)
+	poller := newHealthPoller(server.URL, serverAuth{}, time.Second)
+
+	steps := []struct {
+		slots string
+		want  string
+	}{
+		{slots: `[{"id":0,"is_processing":false}]`, want: ServerHealthy},
+		{slots: `[{"id":0,"is_processing":true}]`, want: ServerDegraded},
+		{slots: `[{"id":0,"is_processing":true}]`, want: ServerDegraded},
+		{slots: `[{"id":0,"is_processing":false}]`, want: ServerHealthy},
+	}
+	for i, step := range steps {
+		fake.slots.Store(step.slots)
+		poller.poll()
+		if poller.state != step.want || Status().State != step.want {
+			t.Fatalf("step %d: state = %q, status = %q, want %q", i, poller.state, Status().State, step.want)
+		}
+	}
+}
+
+func TestServerClientStopsHealthPollerOnClose(t *testing.T) {
+	fake, server := newFakeLlamaServer(t)
+	client := newServerClient(config.LLMConfig{ServerURL: server.URL, HealthPollInterval: 10 * time.Millisecond})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.polls.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("health poller did not run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if Status().State != ServerHealthy {
+		t.Fatalf("status = %+v", Status())
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("second Close() error: %v", err)
+	}
+	// A request cancelled by Close may still reach the handler; let it land.
+	time.Sleep(20 * time.Millisecond)
+	polls := fake.polls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := fake.polls.Load(); got != polls {
+		t.Fatalf("poller kept running after Close: %d polls, was %d", got, polls)
+	}
+	if Status().State != "" {
+		t.Fatalf("expected status to be cleared after Close, got %+v", Status())
+	}
+}
+
+func TestServerClientWithoutPollInterval(t *testing.T) {
+	client := newServerClient(config.LLMConfig{ServerURL: "http://127.0.0.1:1"})
+	if client.health != nil {
+		t.Fatal("expected no health poller without an interval")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+}
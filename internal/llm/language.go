@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"strings"
+
+	"aichatplayers/internal/config"
+)
+
+const defaultLanguageName = "Polish"
+
+var languageNames = map[string]string{
+	"pl": "Polish",
+	"en": "English",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+	"pt": "Portuguese",
+	"nl": "Dutch",
+	"cs": "Czech",
+	"sk": "Slovak",
+	"uk": "Ukrainian",
+	"ru": "Russian",
+}
+
+func languageName(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return defaultLanguageName
+	}
+	normalized := strings.ToLower(trimmed)
+	if name, ok := languageNames[normalized]; ok {
+		return name
+	}
+	if base, _, found := strings.Cut(strings.ReplaceAll(normalized, "_", "-"), "-"); found {
+		if name, ok := languageNames[base]; ok {
+			return name
+		}
+	}
+	return trimmed
+}
+
+func applyLanguage(text, language string) string {
+	return strings.ReplaceAll(text, config.LanguagePlaceholder, language)
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+)
+
+var ErrBusy = errors.New("llm busy")
+
+type ConcurrencyLimiter struct {
+	inner   Generator
+	cfg     config.LLMConfig
+	slots   chan struct{}
+	queued  atomic.Int32
+	latency latencyWindow
+}
+
+func WithConcurrencyLimit(inner Generator, cfg config.LLMConfig) Generator {
+	if inner == nil || !inner.Enabled() || cfg.MaxConcurrent <= 0 {
+		return inner
+	}
+	logging.Debugf("llm_concurrency_limit max_concurrent=%d", cfg.MaxConcurrent)
+	return NewConcurrencyLimiter(inner, cfg)
+}
+
+func NewConcurrencyLimiter(inner Generator, cfg config.LLMConfig) *ConcurrencyLimiter {
+	limit := cfg.MaxConcurrent
+	if limit <= 0 {
+		limit = 1
+	}
+	return &ConcurrencyLimiter{
+		inner: inner,
+		cfg:   cfg,
+		slots: make(chan struct{}, limit),
+	}
+}
+
+func (l *ConcurrencyLimiter) Enabled() bool {
+	return l.inner.Enabled()
+}
+
+func (l *ConcurrencyLimiter) Close() error {
+	return l.inner.Close()
+}
+
+func (l *ConcurrencyLimiter) Reconfigure(cfg config.LLMConfig) {
+	if inner, ok := l.inner.(Reconfigurer); ok {
+		inner.Reconfigure(cfg)
+	}
+}
+
+func (l *ConcurrencyLimiter) Generate(ctx context.Context, req Request) (string, error) {
+	// Callers without a deadline wait at most the soft timeout for a slot.
+	waitCtx, cancel := withTimeout(ctx, l.cfg.SoftTimeout)
+	defer cancel()
+
+	l.queued.Add(1)
+	select {
+	case l.slots <- struct{}{}:
+		l.queued.Add(-1)
+	case <-waitCtx.Done():
+		l.queued.Add(-1)
+		logging.Ctx(ctx).Debugf("llm_busy bot_id=%s max_concurrent=%d", req.Bot.BotID, cap(l.slots))
+		return "", ErrBusy
+	}
+	defer func() { <-l.slots }()
+
+	start := time.Now()
+	message, err := l.inner.Generate(ctx, req)
+	l.latency.observe(time.Since(start))
+	return message, err
+}
+
+func (l *ConcurrencyLimiter) Stats() Stats {
+	stats := Stats{
+		InFlight:   len(l.slots),
+		Queued:     int(l.queued.Load()),
+		P95Latency: l.latency.p95(),
+	}
+	if provider, ok := l.inner.(StatsProvider); ok {
+		inner := provider.Stats()
+		stats.BreakerOpen = inner.BreakerOpen
+		stats.BreakerState = inner.BreakerState
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+)
+
+type slowGenerator struct {
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (g *slowGenerator) Enabled() bool { return true }
+
+func (g *slowGenerator) Close() error { return nil }
+
+func (g *slowGenerator) Generate(ctx context.Context, req Request) (string, error) {
+	current := g.inFlight.Add(1)
+	defer g.inFlight.Add(-1)
+	for {
+		peak := g.peak.Load()
+		if current <= peak || g.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	if err := sleepContext(ctx, g.delay); err != nil {
+		return "", err
+	}
+	return "siema", nil
+}
+
+func TestConcurrencyLimiterBoundsInFlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		softTimeout time.Duration
+		wantBusy    bool
+	}{
+		{name: "waits for slot", limit: 2, softTimeout: time.Second},
+		{name: "single slot", limit: 1, softTimeout: time.Second},
+		{name: "busy before soft timeout", limit: 3, softTimeout: 30 * time.Millisecond, wantBusy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &slowGenerator{delay: 40 * time.Millisecond}
+			limiter := NewConcurrencyLimiter(backend, config.LLMConfig{MaxConcurrent: tt.limit, SoftTimeout: tt.softTimeout})
+
+			var wg sync.WaitGroup
+			var ok, busy atomic.Int32
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := limiter.Generate(context.Background(), Request{})
+					switch {
+					case err == nil:
+						ok.Add(1)
+					case errors.Is(err, ErrBusy):
+						busy.Add(1)
+					default:
+						t.Errorf("Generate() error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if peak := backend.peak.Load(); peak > int32(tt.limit) {
+				t.Fatalf("peak in-flight = %d, want <= %d", peak, tt.limit)
+			}
+			if tt.wantBusy && busy.Load() == 0 {
+				t.Fatal("expected some calls to fail with ErrBusy")
+			}
+			if !tt.wantBusy && ok.Load() != 10 {
+				t.Fatalf("successful calls = %d busy = %d, want 10 successes", ok.Load(), busy.Load())
+			}
+		})
+	}
+}
@@ -12,15 +12,23 @@ import (
 	"os/exec"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"aichatplayers/internal/config"
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
 	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
 )
 
 const defaultMaxTokens = 128
 
+var errEmptyResponse = errors.New("llm returned empty response")
+
 type Generator interface {
 	Enabled() bool
 	Generate(ctx context.Context, req Request) (string, error)
@@ -28,23 +36,126 @@ type Generator interface {
 }
 
 type Request struct {
-	Server     models.ServerContext
-	Bot        models.BotProfile
-	Topic      string
-	RecentChat []models.ChatMessage
+	Server       models.ServerContext
+	Bot          models.BotProfile
+	Topic        string
+	TopicHint    string
+	RecentChat   []models.ChatMessage
+	EngageTarget string
+	EngageHint   string
+	// SystemEvent is a server announcement the bot reacts to.
+	SystemEvent string
+	// BanterOpener asks for a line that starts a bot-to-bot exchange;
+	// BanterReplyTo names the bot whose line should be answered.
+	BanterOpener  bool
+	BanterReplyTo string
+	// ReplyTo names the player whose message raised the topic.
+	ReplyTo string
+	// Sampling is the request's settings.llm; the bot's overrides win.
+	Sampling *models.LLMSettings
+}
+
+// MaxTemperature is the highest temperature a request or bot may ask for.
+const MaxTemperature = 2.0
+
+// sampling returns cfg with the request's settings.llm and then the bot's
+// llm_overrides applied, clamping temperature to [0, MaxTemperature] and
+// top_p to [0, 1].
+func (r Request) sampling(cfg config.LLMConfig) config.LLMConfig {
+	if settings := r.Sampling; settings != nil {
+		if settings.Temperature != nil {
+			cfg.Temperature = min(max(*settings.Temperature, 0), MaxTemperature)
+		}
+		if settings.TopP != nil {
+			cfg.TopP = min(max(*settings.TopP, 0), 1)
+		}
+		if settings.MaxTokens > 0 {
+			cfg.MaxTokens = settings.MaxTokens
+		}
+	}
+	overrides := r.Bot.LLMOverrides
+	if overrides == nil {
+		return cfg
+	}
+	if overrides.Temperature != nil {
+		cfg.Temperature = min(max(*overrides.Temperature, 0), MaxTemperature)
+	}
+	if overrides.TopP != nil {
+		cfg.TopP = min(max(*overrides.TopP, 0), 1)
+	}
+	if overrides.MaxTokens > 0 {
+		cfg.MaxTokens = overrides.MaxTokens
+	}
+	if model := strings.TrimSpace(overrides.Model); model != "" {
+		cfg.ServerModel = model
+	}
+	return cfg
+}
+
+// cacheKey keeps replies generated with different overrides apart.
+func cacheKey(req Request, prompt string) string {
+	if req.Bot.LLMOverrides == nil && req.Sampling == nil {
+		return prompt
+	}
+	overrides, _ := json.Marshal([]any{req.Sampling, req.Bot.LLMOverrides})
+	return prompt + "\x00" + string(overrides)
 }
 
 type Client struct {
 	cfg     config.LLMConfig
+	live    atomic.Pointer[config.LLMConfig]
 	command string
 	enabled bool
+	prompt  *template.Template
+	cache   *responseCache
+	flight  flightGroup
 }
 
 type ServerClient struct {
 	cfg     config.LLMConfig
+	live    atomic.Pointer[config.LLMConfig]
 	url     string
+	auth    serverAuth
 	client  *http.Client
 	enabled bool
+	prompt  *template.Template
+	cache   *responseCache
+	flight  flightGroup
+	health  *healthPoller
+	grammar string
+	// grammarOff is set once the server rejected the grammar parameter.
+	grammarOff atomic.Bool
+}
+
+// Reconfigurer takes the reloadable fields of a freshly loaded config; the
+// next Generate call uses them.
+type Reconfigurer interface {
+	Reconfigure(cfg config.LLMConfig)
+}
+
+// settings is the config as of the last Reconfigure, or cfg before any.
+func (c *Client) settings() config.LLMConfig {
+	if live := c.live.Load(); live != nil {
+		return *live
+	}
+	return c.cfg
+}
+
+func (c *Client) Reconfigure(cfg config.LLMConfig) {
+	next := config.ReloadLLM(c.settings(), cfg)
+	c.live.Store(&next)
+}
+
+func (c *ServerClient) settings() config.LLMConfig {
+	if live := c.live.Load(); live != nil {
+		return *live
+	}
+	return c.cfg
+}
+
+func (c *ServerClient) Reconfigure(cfg config.LLMConfig) {
+	next := config.ReloadLLM(c.settings(), cfg)
+	c.live.Store(&next)
 }
 
 type Noop struct{}
@@ -58,11 +169,21 @@ func (Noop) Generate(ctx context.Context, req Request) (string, error) {
 func (Noop) Close() error { return nil }
 
 func NewClient(cfg config.LLMConfig) (Generator, error) {
-	logging.Debugf("llm_client_init server_url=%q model_path=%q command=%q server_command=%q", cfg.ServerURL, cfg.ModelPath, cfg.Command, cfg.ServerCommand)
+	logging.Debugf("llm_client_init server_url=%q server_api=%s api_key_set=%t model_path=%q command=%q server_command=%q", cfg.ServerURL, cfg.ServerAPI, cfg.ServerAPIKey != "", cfg.ModelPath, cfg.Command, cfg.ServerCommand)
 	_ = resolveModelPath(&cfg)
+	prompt, err := LoadPromptTemplate(cfg.PromptTemplatePath)
+	if err != nil {
+		return Noop{}, fmt.Errorf("LLM_PROMPT_TEMPLATE_PATH %s: %w", cfg.PromptTemplatePath, err)
+	}
+	if len(cfg.Backends) > 0 {
+		logging.Debugf("llm_client_mode backends=%d", len(cfg.Backends))
+		return newBackendsClient(cfg, prompt), nil
+	}
 	if strings.TrimSpace(cfg.ServerURL) != "" {
 		logging.Debugf("llm_client_mode server url configured")
-		return newServerClient(cfg), nil
+		client := newServerClient(cfg)
+		client.prompt = prompt
+		return client, nil
 	}
 	if cfg.ModelPath == "" {
 		logging.Debugf("llm_client_disabled reason=missing_model_path")
@@ -85,7 +206,7 @@ func NewClient(cfg config.LLMConfig) (Generator, error) {
 		debug.SetMemoryLimit(int64(cfg.MaxRAMMB) * 1024 * 1024)
 		logging.Debugf("llm_client_memory_limit_set max_ram_mb=%d", cfg.MaxRAMMB)
 	}
-	return &Client{cfg: cfg, command: command, enabled: true}, nil
+	return &Client{cfg: cfg, command: command, enabled: true, prompt: prompt, cache: newResponseCache(cfg)}, nil
 }
 
 func (c *Client) Enabled() bool {
@@ -103,38 +224,37 @@ func (c *Client) Generate(ctx context.Context, req Request) (string, error) {
 	if c == nil || !c.enabled {
 		return "", errors.New("llm disabled")
 	}
-	prompt := buildPrompt(req, c.cfg)
+	cfg := c.settings()
+	system, user, err := renderPromptParts(c.prompt, req, cfg)
+	if err != nil {
+		return "", err
+	}
+	prompt := system + user
 	if strings.TrimSpace(prompt) == "" {
 		return "", errors.New("llm prompt empty")
 	}
+	key := cacheKey(req, prompt)
+	if response, ok := c.cache.get(key, req.Bot.BotID); ok {
+		return response, nil
+	}
+	return generateShared(ctx, &c.flight, key, req.Bot.BotID, cfg.Timeout, func(ctx context.Context) (string, error) {
+		return c.generate(ctx, req, system, prompt)
+	})
+}
 
-	ctx, cancel := withTimeout(ctx, c.cfg.Timeout)
+func (c *Client) generate(ctx context.Context, req Request, system, prompt string) (string, error) {
+	cfg := c.settings()
+	ctx, cancel := withTimeout(ctx, cfg.Timeout)
 	defer cancel()
+	metrics.LLMAttempts.Inc()
 
-	maxTokens := c.cfg.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = defaultMaxTokens
-	}
-	args := []string{
-		"--model", c.cfg.ModelPath,
-		"--prompt", prompt,
-		"--n-predict", fmt.Sprint(maxTokens),
-		"--temp", fmt.Sprint(c.cfg.Temperature),
-		"--top-p", fmt.Sprint(c.cfg.TopP),
-	}
-	if c.cfg.CtxSize > 0 {
-		args = append(args, "--ctx-size", fmt.Sprint(c.cfg.CtxSize))
-	}
-	if c.cfg.NumThreads > 0 {
-		args = append(args, "--threads", fmt.Sprint(c.cfg.NumThreads))
-	}
-
-	cmd := exec.CommandContext(ctx, c.command, args...)
+	cmd := exec.CommandContext(ctx, c.command, c.commandArgs(req, prompt)...)
 	configureCommand(cmd)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		if ctx.Err() != nil {
-			return "", fmt.Errorf("llm timeout after %s", timeoutLabel(c.cfg.Timeout))
+			metrics.LLMTimeouts.Inc()
+			return "", fmt.Errorf("llm timeout after %s", timeoutLabel(cfg.Timeout))
 		}
 		trimmed := strings.TrimSpace(string(output))
 		if trimmed != "" {
@@ -143,13 +263,58 @@ func (c *Client) Generate(ctx context.Context, req Request) (string, error) {
 		return "", fmt.Errorf("llm command failed: %w", err)
 	}
 
-	response := sanitizeResponse(prompt, string(output), req.Bot.Name, c.cfg)
+	response := sanitizeResponse(prompt, string(output), req.Bot.Name, cfg)
 	if response == "" {
-		return "", errors.New("llm returned empty response")
+		return "", errEmptyResponse
+	}
+	if leaksPrompt(response, system) {
+		return "", errPromptLeak
 	}
+	c.cache.put(cacheKey(req, prompt), response)
+	metrics.LLMSuccesses.Inc()
 	return response, nil
 }
 
+func (c *Client) commandArgs(req Request, prompt string) []string {
+	cfg := c.settings()
+	sampling := req.sampling(cfg)
+	maxTokens := sampling.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	args := []string{
+		"--model", cfg.ModelPath,
+		"--prompt", prompt,
+		"--n-predict", fmt.Sprint(maxTokens),
+		"--temp", fmt.Sprint(sampling.Temperature),
+		"--top-p", fmt.Sprint(sampling.TopP),
+	}
+	if cfg.CtxSize > 0 {
+		args = append(args, "--ctx-size", fmt.Sprint(cfg.CtxSize))
+	}
+	if cfg.NumThreads > 0 {
+		args = append(args, "--threads", fmt.Sprint(cfg.NumThreads))
+	}
+	for _, stop := range cfg.StopSequences {
+		args = append(args, "--reverse-prompt", stop)
+	}
+	args = append(args, grammarArgs(cfg)...)
+	return append(args, tuningArgs(cfg)...)
+}
+
+// tuningArgs are the hardware flags shared by llama-cli and llama-server;
+// extra args come last so they can override anything before them.
+func tuningArgs(cfg config.LLMConfig) []string {
+	var args []string
+	if cfg.GPULayers > 0 {
+		args = append(args, "--n-gpu-layers", fmt.Sprint(cfg.GPULayers))
+	}
+	if cfg.BatchSize > 0 {
+		args = append(args, "--batch-size", fmt.Sprint(cfg.BatchSize))
+	}
+	return append(args, cfg.ExtraArgs...)
+}
+
 func (c *ServerClient) Enabled() bool {
 	if c == nil {
 		return false
@@ -158,6 +323,9 @@ func (c *ServerClient) Enabled() bool {
 }
 
 func (c *ServerClient) Close() error {
+	if c != nil {
+		c.health.close()
+	}
 	return nil
 }
 
@@ -165,75 +333,222 @@ func (c *ServerClient) Generate(ctx context.Context, req Request) (string, error
 	if c == nil || !c.enabled {
 		return "", errors.New("llm disabled")
 	}
-	prompt := buildPrompt(req, c.cfg)
+	cfg := c.settings()
+	system, user, err := renderPromptParts(c.prompt, req, cfg)
+	if err != nil {
+		return "", err
+	}
+	prompt := system + user
 	if strings.TrimSpace(prompt) == "" {
 		return "", errors.New("llm prompt empty")
 	}
+	key := cacheKey(req, prompt)
+	if response, ok := c.cache.get(key, req.Bot.BotID); ok {
+		return response, nil
+	}
+	return generateShared(ctx, &c.flight, key, req.Bot.BotID, cfg.Timeout, func(ctx context.Context) (string, error) {
+		return c.generate(ctx, req, system, prompt)
+	})
+}
 
-	ctx, cancel := withTimeout(ctx, c.cfg.Timeout)
+func (c *ServerClient) generate(ctx context.Context, req Request, system, prompt string) (string, error) {
+	cfg := c.settings()
+	ctx, cancel := withTimeout(ctx, cfg.Timeout)
 	defer cancel()
 
-	maxTokens := c.cfg.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = defaultMaxTokens
-	}
-	payload := map[string]any{
-		"prompt":      prompt,
-		"n_predict":   maxTokens,
-		"temperature": c.cfg.Temperature,
-		"top_p":       c.cfg.TopP,
-		"stream":      false,
-	}
-	if c.cfg.CtxSize > 0 {
-		payload["n_ctx"] = c.cfg.CtxSize
-	}
-
+	payload := c.requestPayload(req, system, strings.TrimPrefix(prompt, system))
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return "", fmt.Errorf("llm server request encode: %w", err)
 	}
 
-	endpoint := strings.TrimRight(c.url, "/") + "/completion"
+	endpoint := serverEndpoint(c.url, cfg.ServerAPI)
+	attempts := 0
+	for {
+		attempts++
+		responseBody, retryable, err := c.send(ctx, endpoint, body)
+		if _, sent := payload["grammar"]; sent && grammarRejected(err) {
+			c.dropGrammar(err)
+			delete(payload, "grammar")
+			if body, err = json.Marshal(payload); err != nil {
+				return "", fmt.Errorf("llm server request encode: %w", err)
+			}
+			responseBody, retryable, err = c.send(ctx, endpoint, body)
+		}
+		if err == nil {
+			response := parseServerResponse(prompt, req.Bot.Name, responseBody, cfg)
+			if response == "" {
+				return "", errEmptyResponse
+			}
+			if leaksPrompt(response, system) {
+				return "", errPromptLeak
+			}
+			c.cache.put(cacheKey(req, prompt), response)
+			metrics.LLMSuccesses.Inc()
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			metrics.LLMTimeouts.Inc()
+			return "", withAttempts(fmt.Errorf("llm timeout after %s", timeoutLabel(cfg.Timeout)), attempts)
+		}
+		if !retryable || attempts > cfg.MaxRetries {
+			return "", withAttempts(err, attempts)
+		}
+		backoff := retryBackoff(attempts)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return "", withAttempts(err, attempts)
+		}
+		logging.Ctx(ctx).Debugf("llm_server_retry bot_id=%s attempt=%d backoff_ms=%d error=%v", req.Bot.BotID, attempts, backoff.Milliseconds(), err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			metrics.LLMTimeouts.Inc()
+			return "", withAttempts(fmt.Errorf("llm timeout after %s", timeoutLabel(cfg.Timeout)), attempts)
+		}
+	}
+}
+
+// send performs one HTTP round trip and reports whether a failure is worth
+// retrying: network errors and 5xx responses are, 4xx responses are not.
+func (c *ServerClient) send(ctx context.Context, endpoint string, body []byte) ([]byte, bool, error) {
+	metrics.LLMAttempts.Inc()
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("llm server request: %w", err)
+		return nil, false, fmt.Errorf("llm server request: %w", err)
 	}
 	request.Header.Set("Content-Type", "application/json")
+	if traceparent := logging.TraceFromContext(ctx).Traceparent(); traceparent != "" {
+		request.Header.Set(logging.TraceparentHeader, traceparent)
+	}
+	c.auth.apply(request)
 
 	resp, err := c.client.Do(request)
 	if err != nil {
-		if ctx.Err() != nil {
-			return "", fmt.Errorf("llm timeout after %s", timeoutLabel(c.cfg.Timeout))
-		}
-		return "", fmt.Errorf("llm server request failed: %w", err)
+		return nil, true, fmt.Errorf("llm server request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("llm server read response: %w", err)
+		return nil, true, fmt.Errorf("llm server read response: %w", err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		trimmed := strings.TrimSpace(string(responseBody))
-		if trimmed != "" {
-			return "", fmt.Errorf("llm server response status=%d body=%s", resp.StatusCode, trimmed)
+		return nil, resp.StatusCode >= 500, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(responseBody))}
+	}
+	return responseBody, false, nil
+}
+
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body != "" {
+		return fmt.Sprintf("llm server response status=%d body=%s", e.code, e.body)
+	}
+	return fmt.Sprintf("llm server response status=%d", e.code)
+}
+
+// generateShared runs generate once per prompt across concurrent callers. A
+// caller that gives up gets a timeout error while the shared call keeps
+// running for the others.
+func generateShared(ctx context.Context, flight *flightGroup, prompt, botID string, timeout time.Duration, generate func(context.Context) (string, error)) (string, error) {
+	response, shared, err := flight.do(ctx, prompt, generate)
+	if shared {
+		logging.Ctx(ctx).Debugf("llm_generation_shared bot_id=%s", botID)
+	}
+	if err != nil && ctx.Err() != nil {
+		return "", fmt.Errorf("llm timeout after %s", timeoutLabel(timeout))
+	}
+	return response, err
+}
+
+func retryBackoff(attempt int) time.Duration {
+	return time.Duration(attempt) * 50 * time.Millisecond
+}
+
+func withAttempts(err error, attempts int) error {
+	if attempts <= 1 {
+		return err
+	}
+	return fmt.Errorf("%w attempts=%d", err, attempts)
+}
+
+func (c *ServerClient) requestPayload(req Request, system, user string) map[string]any {
+	prompt := system + user
+	cfg := c.settings()
+	sampling := req.sampling(cfg)
+	maxTokens := sampling.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	var payload map[string]any
+	switch cfg.ServerAPI {
+	case config.ServerAPIOpenAIChat:
+		payload = map[string]any{
+			"messages": []map[string]string{
+				{"role": "system", "content": strings.TrimSpace(system)},
+				{"role": "user", "content": user},
+			},
+			"max_tokens":  maxTokens,
+			"temperature": sampling.Temperature,
+			"top_p":       sampling.TopP,
+			"stream":      false,
+		}
+	case config.ServerAPIOpenAICompletions:
+		payload = map[string]any{
+			"prompt":      prompt,
+			"max_tokens":  maxTokens,
+			"temperature": sampling.Temperature,
+			"top_p":       sampling.TopP,
+			"stream":      false,
+		}
+	default:
+		payload = map[string]any{
+			"prompt":      prompt,
+			"n_predict":   maxTokens,
+			"temperature": sampling.Temperature,
+			"top_p":       sampling.TopP,
+			"stream":      false,
+		}
+		if cfg.CtxSize > 0 {
+			payload["n_ctx"] = cfg.CtxSize
 		}
-		return "", fmt.Errorf("llm server response status=%d", resp.StatusCode)
 	}
+	if model := strings.TrimSpace(sampling.ServerModel); model != "" {
+		payload["model"] = model
+	}
+	if len(cfg.StopSequences) > 0 {
+		payload["stop"] = cfg.StopSequences
+	}
+	if c.grammar != "" && !c.grammarOff.Load() {
+		payload["grammar"] = c.grammar
+	}
+	return payload
+}
 
-	response := parseServerResponse(prompt, req.Bot.Name, responseBody, c.cfg)
-	if response == "" {
-		return "", errors.New("llm returned empty response")
+func serverEndpoint(baseURL, api string) string {
+	base := strings.TrimRight(baseURL, "/")
+	switch api {
+	case config.ServerAPIOpenAIChat:
+		return strings.TrimSuffix(base, "/v1") + "/v1/chat/completions"
+	case config.ServerAPIOpenAICompletions:
+		return strings.TrimSuffix(base, "/v1") + "/v1/completions"
+	default:
+		return base + "/completion"
 	}
-	return response, nil
 }
 
 func newServerClient(cfg config.LLMConfig) *ServerClient {
+	auth := newServerAuth(cfg)
 	return &ServerClient{
 		cfg:     cfg,
 		url:     strings.TrimSpace(cfg.ServerURL),
+		auth:    auth,
 		client:  &http.Client{},
 		enabled: true,
+		cache:   newResponseCache(cfg),
+		health:  startHealthPoller(cfg, auth),
+		grammar: serverGrammar(cfg),
 	}
 }
 
@@ -253,14 +568,8 @@ func timeoutLabel(timeout time.Duration) time.Duration {
 }
 
 func parseServerResponse(prompt, botName string, payload []byte, cfg config.LLMConfig) string {
-	var completion struct {
+	var parsed struct {
 		Content string `json:"content"`
-	}
-	if err := json.Unmarshal(payload, &completion); err == nil && completion.Content != "" {
-		return sanitizeResponse(prompt, completion.Content, botName, cfg)
-	}
-
-	var openAI struct {
 		Choices []struct {
 			Text    string `json:"text"`
 			Message struct {
@@ -268,13 +577,25 @@ func parseServerResponse(prompt, botName string, payload []byte, cfg config.LLMC
 			} `json:"message"`
 		} `json:"choices"`
 	}
-	if err := json.Unmarshal(payload, &openAI); err == nil && len(openAI.Choices) > 0 {
-		choice := openAI.Choices[0]
-		if choice.Message.Content != "" {
-			return sanitizeResponse(prompt, choice.Message.Content, botName, cfg)
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return ""
+	}
+
+	candidates := []string{parsed.Content}
+	if len(parsed.Choices) > 0 {
+		choice := parsed.Choices[0]
+		switch cfg.ServerAPI {
+		case config.ServerAPIOpenAIChat:
+			candidates = []string{choice.Message.Content, choice.Text, parsed.Content}
+		case config.ServerAPIOpenAICompletions:
+			candidates = []string{choice.Text, choice.Message.Content, parsed.Content}
+		default:
+			candidates = append(candidates, choice.Message.Content, choice.Text)
 		}
-		if choice.Text != "" {
-			return sanitizeResponse(prompt, choice.Text, botName, cfg)
+	}
+	for _, candidate := range candidates {
+		if candidate != "" {
+			return sanitizeResponse(prompt, candidate, botName, cfg)
 		}
 	}
 	return ""
@@ -283,10 +604,29 @@ func parseServerResponse(prompt, botName string, payload []byte, cfg config.LLMC
 func sanitizeResponse(prompt, output, botName string, cfg config.LLMConfig) string {
 	response := strings.TrimSpace(output)
 	response = strings.TrimPrefix(response, prompt)
-	response = strings.TrimSpace(response)
+	response = trimStopSequences(strings.TrimSpace(response), cfg.StopSequences)
 	return normalizeLLMOutput(response, botName, cfg.MaxResponseChars, cfg.MaxResponseWords)
 }
 
+// trimStopSequences cuts the response at the first stop sequence (llama-cli
+// prints the reverse prompt it stopped on) and drops a trailing partial one.
+func trimStopSequences(response string, stops []string) string {
+	for _, stop := range stops {
+		if index := strings.Index(response, stop); index >= 0 {
+			response = response[:index]
+		}
+	}
+	for _, stop := range stops {
+		for n := len(stop) - 1; n > 0; n-- {
+			if strings.HasSuffix(response, stop[:n]) {
+				response = response[:len(response)-n]
+				break
+			}
+		}
+	}
+	return strings.TrimSpace(response)
+}
+
 func stripBotPrefix(message, botName string) string {
 	if botName == "" {
 		return message
@@ -306,18 +646,19 @@ func stripBotPrefix(message, botName string) string {
 }
 
 func normalizeLLMOutput(output, botName string, maxChars, maxWords int) string {
-	line := firstNonEmptyLine(output)
+	line := firstNonEmptyLine(escapedLineBreaks.Replace(output))
 	if line == "" {
 		return "__SILENCE__"
 	}
 	if strings.EqualFold(strings.TrimSpace(line), "__SILENCE__") {
 		return "__SILENCE__"
 	}
+	line = stripChatFormatting(line)
 	line = stripBotMarkers(line)
 	line = stripQuotes(line)
 	line = stripBotPrefix(line, botName)
 	line = strings.TrimSpace(line)
-	if line == "" {
+	if !hasLetterOrDigit(line) {
 		return "__SILENCE__"
 	}
 	if maxWords > 0 {
@@ -350,6 +691,119 @@ func firstNonEmptyLine(output string) string {
 	return ""
 }
 
+// escapedLineBreaks turns line breaks the model wrote out as escape sequences
+// into real ones, so only the first line is kept.
+var escapedLineBreaks = strings.NewReplacer(`\r\n`, "\n", `\n`, "\n", `\r`, "\n", `\t`, " ")
+
+// markdownEmphasis lists the markers stripEmphasis removes, longest first so
+// that ** is not read as two *.
+var markdownEmphasis = []string{"**", "__", "~~", "*", "`"}
+
+// stripChatFormatting removes markdown emphasis, Minecraft color and style
+// codes (§c, &l) and control characters, and collapses whitespace. An & code
+// right after a letter or digit is only stripped in a run of codes, so words
+// such as R&D and rock&roll stay intact.
+func stripChatFormatting(value string) string {
+	runes := []rune(stripEmphasis(value))
+	var sb strings.Builder
+	sb.Grow(len(value))
+	codeEnd := -1
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '&' && i > 0 && isWordRune(runes[i-1]) && codeEnd != i:
+			sb.WriteRune(r)
+		case (r == '§' || r == '&') && i+1 < len(runes) && util.IsFormattingCode(runes[i+1]):
+			i++
+			codeEnd = i + 1
+		case r == '§':
+		case unicode.IsControl(r):
+			sb.WriteRune(' ')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// stripEmphasis removes markdown markers only where a pair wraps text, so
+// names such as xX__Steve__Xx and expressions such as 2*3 stay intact.
+func stripEmphasis(value string) string {
+	for _, marker := range markdownEmphasis {
+		var sb strings.Builder
+		rest := value
+		for {
+			open := openingMarker(rest, marker)
+			if open < 0 {
+				break
+			}
+			inner := rest[open+len(marker):]
+			end := closingMarker(inner, marker)
+			if end < 0 {
+				break
+			}
+			sb.WriteString(rest[:open])
+			sb.WriteString(inner[:end])
+			rest = inner[end+len(marker):]
+		}
+		sb.WriteString(rest)
+		value = sb.String()
+	}
+	return value
+}
+
+// openingMarker finds marker at the start of a word: not preceded by a
+// letter or digit and followed by text.
+func openingMarker(value, marker string) int {
+	for from := 0; from < len(value); {
+		i := strings.Index(value[from:], marker)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		before, _ := utf8.DecodeLastRuneInString(value[:i])
+		after, _ := utf8.DecodeRuneInString(value[i+len(marker):])
+		if (i == 0 || !isWordRune(before)) && i+len(marker) < len(value) && !unicode.IsSpace(after) {
+			return i
+		}
+		from = i + 1
+	}
+	return -1
+}
+
+// closingMarker finds marker at the end of a word: preceded by text and not
+// followed by a letter or digit.
+func closingMarker(value, marker string) int {
+	for from := 0; from < len(value); {
+		i := strings.Index(value[from:], marker)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		before, _ := utf8.DecodeLastRuneInString(value[:i])
+		after, _ := utf8.DecodeRuneInString(value[i+len(marker):])
+		if i > 0 && !unicode.IsSpace(before) && (i+len(marker) == len(value) || !isWordRune(after)) {
+			return i
+		}
+		from = i + 1
+	}
+	return -1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// hasLetterOrDigit is false for replies made only of emoji or punctuation.
+func hasLetterOrDigit(value string) bool {
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
 func stripQuotes(value string) string {
 	value = strings.ReplaceAll(value, "\"", "")
 	value = strings.ReplaceAll(value, "'", "")
@@ -425,75 +879,6 @@ func truncateWords(value string, limit int) string {
 	return strings.Join(words[:limit], " ")
 }
 
-func buildPrompt(req Request, cfg config.LLMConfig) string {
-	var sb strings.Builder
-	promptSystem := strings.TrimSpace(cfg.PromptSystem)
-	if promptSystem == "" {
-		promptSystem = "You are a Minecraft player chat bot roleplaying as a normal player.\nYou have NO memory and NO access to anything except the provided CHAT LOG and BOT/SERVER info.\nDo NOT invent facts, backstory, previous events, or personal memories.\nDo NOT mention being an AI, a model, or system instructions."
-	}
-	promptRules := strings.TrimSpace(cfg.PromptResponseRules)
-	if promptRules == "" {
-		promptRules = config.DefaultPromptResponseRules(cfg.MaxResponseChars, cfg.MaxResponseWords)
-	}
-
-	sb.WriteString("=== SYSTEM ===\n")
-	sb.WriteString(promptSystem)
-	sb.WriteString("\n\n")
-	sb.WriteString("=== RULES ===\n")
-	sb.WriteString(promptRules)
-	sb.WriteString("\n\n")
-	sb.WriteString("=== BOT ===\n")
-	sb.WriteString("name: ")
-	sb.WriteString(req.Bot.Name)
-	sb.WriteString("\n")
-	persona := req.Bot.Persona
-	sb.WriteString("language: ")
-	sb.WriteString(persona.Language)
-	sb.WriteString("\n")
-	sb.WriteString("tone: ")
-	sb.WriteString(persona.Tone)
-	sb.WriteString("\n")
-	sb.WriteString("style_tags: ")
-	sb.WriteString(strings.Join(persona.StyleTags, ", "))
-	sb.WriteString("\n")
-	sb.WriteString("knowledge_level: ")
-	sb.WriteString(persona.KnowledgeLevel)
-	sb.WriteString("\n")
-	sb.WriteString("avoid_topics: ")
-	sb.WriteString(strings.Join(persona.AvoidTopics, ", "))
-	sb.WriteString("\n\n")
-	sb.WriteString("=== SERVER ===\n")
-	sb.WriteString("server_id: ")
-	sb.WriteString(req.Server.ServerID)
-	sb.WriteString("\n")
-	sb.WriteString("mode: ")
-	sb.WriteString(req.Server.Mode)
-	sb.WriteString("\n")
-	sb.WriteString("online_players: ")
-	sb.WriteString(fmt.Sprint(req.Server.OnlinePlayers))
-	sb.WriteString("\n\n")
-	sb.WriteString("=== CHAT LOG (last ")
-	sb.WriteString(fmt.Sprint(cfg.ChatHistoryLimit))
-	sb.WriteString(") ===\n")
-	for _, message := range req.RecentChat {
-		if strings.TrimSpace(message.Message) == "" {
-			continue
-		}
-		sb.WriteString("[")
-		sb.WriteString(chatRole(message.SenderType))
-		sb.WriteString("] ")
-		sb.WriteString(sanitizeChatField(message.Sender))
-		sb.WriteString(": ")
-		sb.WriteString(sanitizeChatField(message.Message))
-		sb.WriteString("\n")
-	}
-	sb.WriteString("\n=== TASK ===\n")
-	sb.WriteString("Write ONE short Polish chat message as the BOT that replies to the LAST [PLAYER] message if it needs a reply.\n")
-	sb.WriteString("If no reply is needed, output exactly \"__SILENCE__\".\n\n")
-	sb.WriteString("=== OUTPUT ===\n")
-	return sb.String()
-}
-
 func chatRole(senderType string) string {
 	switch strings.ToLower(strings.TrimSpace(senderType)) {
 	case "player":
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -62,10 +63,10 @@ func TestBuildPromptFormat(t *testing.T) {
 	if !strings.HasSuffix(prompt, "=== OUTPUT ===\n") {
 		t.Fatalf("expected prompt to end with output header, got: %q", prompt)
 	}
-	if !strings.Contains(prompt, "[PLAYER] Player123: Cześć wszystkim!") {
+	if !strings.Contains(prompt, `<msg role="PLAYER" sender="Player123">Cześć wszystkim!</msg>`) {
 		t.Fatalf("expected player chat line, got: %q", prompt)
 	}
-	if !strings.Contains(prompt, "[BOT] Kuba: Hej!") {
+	if !strings.Contains(prompt, `<msg role="BOT" sender="Kuba">Hej!</msg>`) {
 		t.Fatalf("expected bot chat line, got: %q", prompt)
 	}
 	if !strings.Contains(prompt, "__SILENCE__") {
@@ -116,6 +117,96 @@ func TestNormalizeLLMOutput(t *testing.T) {
 			bot:    "Kuba",
 			want:   "__SILENCE__",
 		},
+		{
+			name:   "markdown emphasis and backticks",
+			output: "**hej** co tam, wpisz `/spawn` i _leć_ ~~szybko~~ __teraz__",
+			bot:    "Kuba",
+			want:   "hej co tam, wpisz /spawn i _leć_ szybko teraz",
+		},
+		{
+			name:   "underscores inside a name",
+			output: "xX__Steve__Xx wbijaj na __pvp__",
+			bot:    "Kuba",
+			want:   "xX__Steve__Xx wbijaj na pvp",
+		},
+		{
+			name:   "unpaired underscores in names",
+			output: "Steve__ i __Ola graja razem",
+			bot:    "Kuba",
+			want:   "Steve__ i __Ola graja razem",
+		},
+		{
+			name:   "asterisk in an expression",
+			output: "to jest 2*3 czyli *szesc*",
+			bot:    "Kuba",
+			want:   "to jest 2*3 czyli szesc",
+		},
+		{
+			name:   "section sign color codes",
+			output: "§cuwaga §l§nevent§r za chwile §",
+			bot:    "Kuba",
+			want:   "uwaga event za chwile",
+		},
+		{
+			name:   "ampersand color codes",
+			output: "&aSiema &x&f&f&0&0&0&0wszystkim",
+			bot:    "Kuba",
+			want:   "Siema wszystkim",
+		},
+		{
+			name:   "ampersand inside words",
+			output: "R&D to Tom&Bob, rock&roll &z 3&4",
+			bot:    "Kuba",
+			want:   "R&D to Tom&Bob, rock&roll &z 3&4",
+		},
+		{
+			name:   "ampersand code after a code run",
+			output: "siema§a&lKuba tu",
+			bot:    "Kuba",
+			want:   "siemaKuba tu",
+		},
+		{
+			name:   "escaped newline literal",
+			output: `hej\nKuba: kolejna linia`,
+			bot:    "Kuba",
+			want:   "hej",
+		},
+		{
+			name:   "control characters and whitespace",
+			output: "siema\tco\x07   tam\r",
+			bot:    "Kuba",
+			want:   "siema co tam",
+		},
+		{
+			name:   "bot prefix behind color code",
+			output: "§eKuba: siema",
+			bot:    "Kuba",
+			want:   "siema",
+		},
+		{
+			name:   "only emoji",
+			output: "😂😂 🔥",
+			bot:    "Kuba",
+			want:   "__SILENCE__",
+		},
+		{
+			name:   "only punctuation",
+			output: "?!... :)",
+			bot:    "Kuba",
+			want:   "__SILENCE__",
+		},
+		{
+			name:   "markdown wrapped emoji",
+			output: "**🔥**",
+			bot:    "Kuba",
+			want:   "__SILENCE__",
+		},
+		{
+			name:   "truncate after stripping",
+			output: "§a" + strings.Repeat("b", 90),
+			bot:    "Kuba",
+			want:   strings.Repeat("b", 80),
+		},
 	}
 
 	for _, tt := range tests {
@@ -127,3 +218,189 @@ func TestNormalizeLLMOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestTrimStopSequences(t *testing.T) {
+	stops := []string{"\n", "==="}
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{name: "no stop", response: "siema", want: "siema"},
+		{name: "reverse prompt echoed", response: "siema\nKuba: dalej", want: "siema"},
+		{name: "section marker", response: "siema === TASK", want: "siema"},
+		{name: "trailing fragment", response: "siema ==", want: "siema"},
+		{name: "single trailing char", response: "siema=", want: "siema"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimStopSequences(tt.response, stops); got != tt.want {
+				t.Fatalf("trimStopSequences(%q) = %q, want %q", tt.response, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandArgsIncludeStopSequences(t *testing.T) {
+	client := &Client{cfg: config.LLMConfig{ModelPath: "model.gguf", StopSequences: []string{"\n", "==="}}}
+	args := strings.Join(client.commandArgs(Request{}, "prompt"), "|")
+	if !strings.Contains(args, "--reverse-prompt|\n|--reverse-prompt|===") {
+		t.Fatalf("expected reverse prompts in args: %q", args)
+	}
+	client.cfg.StopSequences = nil
+	if args := strings.Join(client.commandArgs(Request{}, "prompt"), "|"); strings.Contains(args, "--reverse-prompt") {
+		t.Fatalf("unexpected reverse prompt without stops: %q", args)
+	}
+}
+
+func TestCommandArgsIncludeTuningFlags(t *testing.T) {
+	client := &Client{cfg: config.LLMConfig{ModelPath: "model.gguf", GPULayers: 20, Parallel: 4, BatchSize: 256, ExtraArgs: []string{"--flash-attn", "--mlock"}}}
+	args := strings.Join(client.commandArgs(Request{}, "prompt"), "|")
+	if !strings.HasSuffix(args, "|--n-gpu-layers|20|--batch-size|256|--flash-attn|--mlock") {
+		t.Fatalf("expected tuning flags at the end of args: %q", args)
+	}
+	if strings.Contains(args, "--parallel") {
+		t.Fatalf("--parallel is a llama-server flag: %q", args)
+	}
+}
+
+func TestBuildPromptUsesPersonaLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{language: "en", want: "in English"},
+		{language: "de", want: "in German"},
+		{language: "pt-BR", want: "in Portuguese"},
+		{language: "", want: "in Polish"},
+	}
+	for _, tt := range tests {
+		req := Request{Bot: models.BotProfile{Name: "Kuba", Persona: models.Persona{Language: tt.language}}}
+		prompt := buildPrompt(req, config.LLMConfig{})
+		task := prompt[strings.Index(prompt, "=== TASK ==="):]
+		if !strings.Contains(task, tt.want) {
+			t.Fatalf("language %q: expected task to contain %q, got: %q", tt.language, tt.want, task)
+		}
+		if !strings.Contains(prompt, "single-line chat message "+tt.want) {
+			t.Fatalf("language %q: expected rules to contain %q, got: %q", tt.language, tt.want, prompt)
+		}
+		if strings.Contains(prompt, config.LanguagePlaceholder) {
+			t.Fatalf("language %q: placeholder left in prompt: %q", tt.language, prompt)
+		}
+	}
+}
+
+func TestBuildPromptEngagementTask(t *testing.T) {
+	req := Request{
+		Bot:          models.BotProfile{Name: "Kuba", Persona: models.Persona{Language: "pl"}},
+		EngageTarget: "Steve",
+		EngageHint:   "zapytaj co buduje",
+	}
+	prompt := buildPrompt(req, config.LLMConfig{})
+	task := prompt[strings.Index(prompt, "=== TASK ==="):]
+	for _, want := range []string{"starts a short friendly conversation with Steve", "Idea: zapytaj co buduje"} {
+		if !strings.Contains(task, want) {
+			t.Fatalf("expected task to contain %q, got: %q", want, task)
+		}
+	}
+	if strings.Contains(task, `replies to the LAST message with role="PLAYER"`) {
+		t.Fatalf("engagement task should not ask for a reply: %q", task)
+	}
+}
+
+func TestBuildPromptTopicHint(t *testing.T) {
+	req := Request{
+		Bot:       models.BotProfile{Name: "Kuba", Persona: models.Persona{Language: "pl"}},
+		Topic:     "parkour",
+		TopicHint: "Mention the parkour\nmap at spawn.",
+	}
+	prompt := buildPrompt(req, config.LLMConfig{})
+	task := prompt[strings.Index(prompt, "=== TASK ==="):]
+	if !strings.Contains(task, "Topic hint: Mention the parkour map at spawn.\n") {
+		t.Fatalf("expected topic hint in task, got: %q", task)
+	}
+	if prompt := buildPrompt(Request{Bot: req.Bot}, config.LLMConfig{}); strings.Contains(prompt, "Topic hint:") {
+		t.Fatalf("unexpected topic hint without a hint: %q", prompt)
+	}
+}
+
+func TestBuildPromptSystemEventTask(t *testing.T) {
+	req := Request{
+		Bot:         models.BotProfile{Name: "Kuba", Persona: models.Persona{Language: "pl"}},
+		Topic:       "event",
+		SystemEvent: "Event start za 5 minut!",
+	}
+	prompt := buildPrompt(req, config.LLMConfig{})
+	task := prompt[strings.Index(prompt, "=== TASK ==="):]
+	if !strings.Contains(task, "The server just announced: Event start za 5 minut!") || strings.Contains(task, `replies to the LAST message with role="PLAYER"`) {
+		t.Fatalf("unexpected system event task: %q", task)
+	}
+}
+
+func TestBuildPromptBanterTasks(t *testing.T) {
+	bot := models.BotProfile{Name: "Ola", Persona: models.Persona{Language: "pl"}}
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{name: "opener", req: Request{Bot: bot, BanterOpener: true}, want: "starts a conversation on a quiet server"},
+		{name: "reply", req: Request{Bot: bot, BanterReplyTo: "Kuba"}, want: "casually replies to the LAST message from Kuba"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := buildPrompt(tt.req, config.LLMConfig{})
+			task := prompt[strings.Index(prompt, "=== TASK ==="):]
+			if !strings.Contains(task, tt.want) || strings.Contains(task, `replies to the LAST message with role="PLAYER"`) {
+				t.Fatalf("unexpected banter task: %q", task)
+			}
+		})
+	}
+}
+
+func TestRequestSamplingOverrides(t *testing.T) {
+	cfg := config.LLMConfig{ModelPath: "model.gguf", Temperature: 0.6, TopP: 0.9, MaxTokens: 128, ServerModel: "qwen"}
+	float := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name      string
+		sampling  *models.LLMSettings
+		overrides *models.LLMOverrides
+		wantTemp  float64
+		wantTopP  float64
+		wantMax   int
+		wantModel string
+	}{
+		{name: "config defaults", wantTemp: 0.6, wantTopP: 0.9, wantMax: 128, wantModel: "qwen"},
+		{name: "chaotic", overrides: &models.LLMOverrides{Temperature: float(1.3), MaxTokens: 64, Model: "mistral"}, wantTemp: 1.3, wantTopP: 0.9, wantMax: 64, wantModel: "mistral"},
+		{name: "explicit zero", overrides: &models.LLMOverrides{Temperature: float(0), TopP: float(0.5)}, wantTemp: 0, wantTopP: 0.5, wantMax: 128, wantModel: "qwen"},
+		{name: "request settings", sampling: &models.LLMSettings{Temperature: float(0.3), MaxTokens: 32}, wantTemp: 0.3, wantTopP: 0.9, wantMax: 32, wantModel: "qwen"},
+		{name: "bot wins over request", sampling: &models.LLMSettings{Temperature: float(0.3), TopP: float(0.7)}, overrides: &models.LLMOverrides{Temperature: float(1.3)}, wantTemp: 1.3, wantTopP: 0.7, wantMax: 128, wantModel: "qwen"},
+		{name: "clamped", overrides: &models.LLMOverrides{Temperature: float(7), TopP: float(-1)}, wantTemp: MaxTemperature, wantTopP: 0, wantMax: 128, wantModel: "qwen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{Bot: models.BotProfile{LLMOverrides: tt.overrides}, Sampling: tt.sampling}
+			payload := newServerClient(cfg).requestPayload(req, "", "prompt")
+			if payload["temperature"] != tt.wantTemp || payload["top_p"] != tt.wantTopP || payload["n_predict"] != tt.wantMax || payload["model"] != tt.wantModel {
+				t.Fatalf("payload = %v", payload)
+			}
+			args := strings.Join((&Client{cfg: cfg}).commandArgs(req, "prompt"), "|")
+			want := "|--n-predict|" + fmt.Sprint(tt.wantMax) + "|--temp|" + fmt.Sprint(tt.wantTemp) + "|--top-p|" + fmt.Sprint(tt.wantTopP)
+			if !strings.Contains(args, want) {
+				t.Fatalf("args %q do not contain %q", args, want)
+			}
+		})
+	}
+}
+
+func TestCacheKeySeparatesOverrides(t *testing.T) {
+	hot := 1.2
+	plain := Request{}
+	chaotic := Request{Bot: models.BotProfile{LLMOverrides: &models.LLMOverrides{Temperature: &hot}}}
+	if cacheKey(plain, "prompt") != "prompt" {
+		t.Fatalf("cache key without overrides = %q", cacheKey(plain, "prompt"))
+	}
+	if cacheKey(chaotic, "prompt") == cacheKey(plain, "prompt") {
+		t.Fatal("expected overrides to change the cache key")
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// modelHashWindow bounds how much of the model file is hashed: only the first
+// and last window are read, so multi-GB GGUF files stay cheap to fingerprint.
+const modelHashWindow = 1 << 20
+
+type modelFingerprint struct {
+	Size        int64  `json:"size"`
+	ModTimeUnix int64  `json:"mod_time_unix_nano"`
+	PartialHash string `json:"partial_hash"`
+}
+
+func fingerprintModel(path string) (*modelFingerprint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%d:", stat.Size())
+	if _, err := io.CopyN(hasher, file, modelHashWindow); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("hash model head: %w", err)
+	}
+	if stat.Size() > 2*modelHashWindow {
+		if _, err := file.Seek(-modelHashWindow, io.SeekEnd); err != nil {
+			return nil, fmt.Errorf("seek model tail: %w", err)
+		}
+		if _, err := io.CopyN(hasher, file, modelHashWindow); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("hash model tail: %w", err)
+		}
+	} else if stat.Size() > modelHashWindow {
+		if _, err := io.Copy(hasher, file); err != nil {
+			return nil, fmt.Errorf("hash model tail: %w", err)
+		}
+	}
+	return &modelFingerprint{
+		Size:        stat.Size(),
+		ModTimeUnix: stat.ModTime().UnixNano(),
+		PartialHash: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+func (f *modelFingerprint) equal(other *modelFingerprint) bool {
+	return f.Size == other.Size && f.ModTimeUnix == other.ModTimeUnix && f.PartialHash == other.PartialHash
+}
@@ -0,0 +1,263 @@
+package llm
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+// PromptData is what prompt templates are executed against. Chat lines and
+// the task are already sanitized to single lines.
+type PromptData struct {
+	System    string
+	Rules     string
+	Language  string
+	Bot       models.BotProfile
+	Persona   models.Persona
+	Server    models.ServerContext
+	ChatLimit int
+	Chat      []PromptChatLine
+	Task      string
+}
+
+type PromptChatLine struct {
+	Role    string
+	Sender  string
+	Message string
+}
+
+//go:embed prompt.tmpl
+var defaultPromptText string
+
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+var defaultPrompt = template.Must(template.New("prompt").Funcs(promptFuncs).Parse(defaultPromptText))
+
+// LoadPromptTemplate returns the default prompt template with the "system"
+// and "user" templates (and any helpers) from path defined over it; an empty
+// path returns the default.
+func LoadPromptTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return defaultPrompt, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt template: %w", err)
+	}
+	file, err := template.New(path).Funcs(promptFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template: %w", err)
+	}
+	if file.Lookup("system") == nil && file.Lookup("user") == nil {
+		return nil, fmt.Errorf("prompt template %s must define a \"system\" or \"user\" template", path)
+	}
+	prompt := template.Must(defaultPrompt.Clone())
+	for _, tmpl := range file.Templates() {
+		if tmpl.Name() == path || tmpl.Tree == nil {
+			continue
+		}
+		if _, err := prompt.AddParseTree(tmpl.Name(), tmpl.Tree); err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", tmpl.Name(), err)
+		}
+	}
+	return prompt, nil
+}
+
+func buildPrompt(req Request, cfg config.LLMConfig) string {
+	system, user, _ := renderPromptParts(defaultPrompt, req, cfg)
+	return system + user
+}
+
+// renderPromptParts returns the system and user parts of the prompt; a
+// template that fails to execute is logged and the default one is used. The
+// error is set when the default template fails too.
+func renderPromptParts(prompt *template.Template, req Request, cfg config.LLMConfig) (string, string, error) {
+	data := promptData(req, cfg)
+	if prompt != nil && prompt != defaultPrompt {
+		system, user, err := fitPrompt(prompt, data, cfg, req.Bot.BotID)
+		if err == nil {
+			return system, user, nil
+		}
+		logging.Warnf("llm_prompt_template_failed bot_id=%s error=%v fallback=default", req.Bot.BotID, err)
+	}
+	system, user, err := fitPrompt(defaultPrompt, data, cfg, req.Bot.BotID)
+	if err != nil {
+		return "", "", fmt.Errorf("default prompt template: %w", err)
+	}
+	return system, user, nil
+}
+
+// promptTokenMargin leaves room for the chat template tokens the backend
+// wraps around the prompt.
+const promptTokenMargin = 64
+
+// promptTokenBudget is how many prompt tokens fit next to the reply in the
+// context window; 0 means the context size is unknown.
+func promptTokenBudget(cfg config.LLMConfig) int {
+	if cfg.CtxSize <= 0 {
+		return 0
+	}
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	if budget := cfg.CtxSize - maxTokens - promptTokenMargin; budget > 0 {
+		return budget
+	}
+	return 1
+}
+
+// estimateTokens approximates llama tokenizers at four characters a token.
+func estimateTokens(text string) int {
+	return (runeCount(text) + 3) / 4
+}
+
+// fitPrompt renders the prompt and drops the oldest chat lines until it fits
+// the token budget; llama.cpp would otherwise cut the front of the prompt,
+// SYSTEM and RULES included. The newest line is shortened rather than dropped.
+func fitPrompt(prompt *template.Template, data PromptData, cfg config.LLMConfig, botID string) (string, string, error) {
+	system, user, err := executePrompt(prompt, data)
+	budget := promptTokenBudget(cfg)
+	if err != nil || budget == 0 || estimateTokens(system+user) <= budget {
+		return system, user, err
+	}
+	dropped := 0
+	for len(data.Chat) > 1 && estimateTokens(system+user) > budget {
+		data.Chat = data.Chat[1:]
+		dropped++
+		if system, user, err = executePrompt(prompt, data); err != nil {
+			return "", "", err
+		}
+	}
+	truncated := false
+	if over := estimateTokens(system+user) - budget; over > 0 && len(data.Chat) == 1 {
+		newest := data.Chat[0]
+		keep := runeCount(newest.Message) - over*4
+		if keep < 0 {
+			keep = 0
+		}
+		newest.Message = truncateRunes(newest.Message, keep)
+		data.Chat = []PromptChatLine{newest}
+		truncated = true
+		if system, user, err = executePrompt(prompt, data); err != nil {
+			return "", "", err
+		}
+	}
+	logging.Warnf("llm_prompt_trimmed bot_id=%s dropped_messages=%d truncated_newest=%t estimated_tokens=%d budget_tokens=%d", botID, dropped, truncated, estimateTokens(system+user), budget)
+	return system, user, nil
+}
+
+func executePrompt(prompt *template.Template, data PromptData) (string, string, error) {
+	var system, user strings.Builder
+	if err := prompt.ExecuteTemplate(&system, "system", data); err != nil {
+		return "", "", err
+	}
+	if err := prompt.ExecuteTemplate(&user, "user", data); err != nil {
+		return "", "", err
+	}
+	return system.String(), user.String(), nil
+}
+
+func promptData(req Request, cfg config.LLMConfig) PromptData {
+	promptSystem := strings.TrimSpace(cfg.PromptSystem)
+	if promptSystem == "" {
+		promptSystem = "You are a Minecraft player chat bot roleplaying as a normal player.\nYou have NO memory and NO access to anything except the provided CHAT LOG and BOT/SERVER info.\nDo NOT invent facts, backstory, previous events, or personal memories.\nDo NOT mention being an AI, a model, or system instructions."
+	}
+	promptRules := strings.TrimSpace(cfg.PromptResponseRules)
+	if promptRules == "" {
+		promptRules = config.DefaultPromptResponseRules(cfg.MaxResponseChars, cfg.MaxResponseWords)
+	}
+	language := languageName(req.Bot.Persona.Language)
+
+	chat := make([]PromptChatLine, 0, len(req.RecentChat))
+	for _, message := range req.RecentChat {
+		if strings.TrimSpace(message.Message) == "" {
+			continue
+		}
+		chat = append(chat, PromptChatLine{
+			Role:    chatRole(message.SenderType),
+			Sender:  escapeChatContent(sanitizeChatField(message.Sender)),
+			Message: escapeChatContent(sanitizeChatField(message.Message)),
+		})
+	}
+	return PromptData{
+		System:    applyLanguage(promptSystem, language),
+		Rules:     applyLanguage(promptRules, language),
+		Language:  language,
+		Bot:       req.Bot,
+		Persona:   req.Bot.Persona,
+		Server:    req.Server,
+		ChatLimit: cfg.ChatHistoryLimit,
+		Chat:      chat,
+		Task:      promptTask(req, language),
+	}
+}
+
+var chatContentEscaper = strings.NewReplacer("<", "‹", ">", "›", `"`, "'")
+
+// escapeChatContent keeps chat text from closing its <msg> tag or opening a
+// new prompt section.
+func escapeChatContent(text string) string {
+	text = chatContentEscaper.Replace(text)
+	for strings.Contains(text, "===") {
+		text = strings.ReplaceAll(text, "===", "=")
+	}
+	return text
+}
+
+var errPromptLeak = errors.New("llm response repeats the prompt instructions")
+
+// leaksPrompt reports whether a reply repeats five or more consecutive words
+// of the SYSTEM or RULES text, which happens when a player talks the model
+// into reciting its instructions.
+func leaksPrompt(response, system string) bool {
+	const run = 5
+	words := util.Words(util.NormalizeText(response))
+	if len(words) < run {
+		return false
+	}
+	prompt := " " + strings.Join(util.Words(util.NormalizeText(system)), " ") + " "
+	for i := 0; i+run <= len(words); i++ {
+		if strings.Contains(prompt, " "+strings.Join(words[i:i+run], " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func promptTask(req Request, language string) string {
+	var lines []string
+	if target := sanitizeChatField(req.EngageTarget); target != "" {
+		lines = append(lines, "Write ONE short chat message in "+language+" as the BOT that starts a short friendly conversation with "+target+". Address "+target+" by name. Do not output \"__SILENCE__\".")
+		if hint := sanitizeChatField(req.EngageHint); hint != "" {
+			lines = append(lines, "Idea: "+hint)
+		}
+	} else if announcement := sanitizeChatField(req.SystemEvent); announcement != "" {
+		lines = append(lines,
+			"The server just announced: "+announcement,
+			"Write ONE short excited chat message in "+language+" as the BOT reacting to this announcement. Do not output \"__SILENCE__\".")
+	} else if partner := sanitizeChatField(req.BanterReplyTo); partner != "" {
+		lines = append(lines, "Write ONE short chat message in "+language+" as the BOT that casually replies to the LAST message from "+partner+". Do not output \"__SILENCE__\".")
+	} else if req.BanterOpener {
+		lines = append(lines, "Write ONE short casual chat message in "+language+" as the BOT that starts a conversation on a quiet server. Do not output \"__SILENCE__\".")
+	} else {
+		if player := sanitizeChatField(req.ReplyTo); player != "" {
+			lines = append(lines, "Write ONE short chat message in "+language+" as the BOT that replies to the LAST message from "+player+" if it needs a reply.")
+		} else {
+			lines = append(lines, "Write ONE short chat message in "+language+" as the BOT that replies to the LAST message with role=\"PLAYER\" if it needs a reply.")
+		}
+		if hint := sanitizeChatField(req.TopicHint); hint != "" {
+			lines = append(lines, "Topic hint: "+hint)
+		}
+		lines = append(lines, "If no reply is needed, output exactly \"__SILENCE__\".")
+	}
+	return strings.Join(lines, "\n")
+}
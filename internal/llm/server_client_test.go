@@ -0,0 +1,294 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/models"
+)
+
+func TestServerClientAPIFlavors(t *testing.T) {
+	tests := []struct {
+		name     string
+		api      string
+		path     string
+		response string
+		check    func(t *testing.T, payload map[string]any)
+	}{
+		{
+			name:     "llamacpp",
+			api:      config.ServerAPILlamaCpp,
+			path:     "/completion",
+			response: `{"content":"siema llama"}`,
+			check: func(t *testing.T, payload map[string]any) {
+				if _, ok := payload["n_predict"]; !ok {
+					t.Fatalf("expected n_predict in payload: %v", payload)
+				}
+				if _, ok := payload["prompt"].(string); !ok {
+					t.Fatalf("expected prompt in payload: %v", payload)
+				}
+			},
+		},
+		{
+			name:     "openai-completions",
+			api:      config.ServerAPIOpenAICompletions,
+			path:     "/v1/completions",
+			response: `{"choices":[{"text":"siema completions"}]}`,
+			check: func(t *testing.T, payload map[string]any) {
+				if payload["model"] != "test-model" || payload["max_tokens"] == nil {
+					t.Fatalf("expected model and max_tokens in payload: %v", payload)
+				}
+				if _, ok := payload["prompt"].(string); !ok {
+					t.Fatalf("expected prompt in payload: %v", payload)
+				}
+			},
+		},
+		{
+			name:     "openai-chat",
+			api:      config.ServerAPIOpenAIChat,
+			path:     "/v1/chat/completions",
+			response: `{"choices":[{"message":{"role":"assistant","content":"siema chat"}}]}`,
+			check: func(t *testing.T, payload map[string]any) {
+				if payload["model"] != "test-model" || payload["max_tokens"] == nil || payload["top_p"] == nil || payload["temperature"] == nil {
+					t.Fatalf("expected openai fields in payload: %v", payload)
+				}
+				messages, ok := payload["messages"].([]any)
+				if !ok || len(messages) != 2 {
+					t.Fatalf("expected system and user messages: %v", payload["messages"])
+				}
+				system := messages[0].(map[string]any)
+				user := messages[1].(map[string]any)
+				if system["role"] != "system" || !strings.Contains(system["content"].(string), "=== RULES ===") {
+					t.Fatalf("unexpected system message: %v", system)
+				}
+				if strings.Contains(system["content"].(string), "=== BOT ===") {
+					t.Fatalf("system message should not contain bot section: %v", system)
+				}
+				if user["role"] != "user" || !strings.Contains(user["content"].(string), "=== CHAT LOG") || !strings.Contains(user["content"].(string), "=== TASK ===") {
+					t.Fatalf("unexpected user message: %v", user)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("path = %s, want %s", r.URL.Path, tt.path)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Errorf("decode payload: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client := newServerClient(config.LLMConfig{
+				ServerURL:        server.URL,
+				ServerAPI:        tt.api,
+				ServerModel:      "test-model",
+				MaxResponseChars: 80,
+				StopSequences:    []string{"\n", "==="},
+			})
+			message, err := client.Generate(context.Background(), Request{
+				Bot: models.BotProfile{Name: "Kuba"},
+				RecentChat: []models.ChatMessage{
+					{Sender: "Player", SenderType: "PLAYER", Message: "siema"},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			if !strings.HasPrefix(message, "siema") {
+				t.Fatalf("unexpected message %q", message)
+			}
+			tt.check(t, payload)
+			if stops, ok := payload["stop"].([]any); !ok || len(stops) != 2 || stops[0] != "\n" || stops[1] != "===" {
+				t.Fatalf("expected stop sequences in payload: %v", payload["stop"])
+			}
+		})
+	}
+}
+
+func TestServerEndpointAvoidsDoubleV1(t *testing.T) {
+	got := serverEndpoint("http://localhost:1234/v1/", config.ServerAPIOpenAIChat)
+	if got != "http://localhost:1234/v1/chat/completions" {
+		t.Fatalf("serverEndpoint() = %q", got)
+	}
+}
+
+func TestServerClientSendsAuthHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantName  string
+		wantValue string
+	}{
+		{name: "bearer", wantName: "Authorization", wantValue: "Bearer secret-key"},
+		{name: "custom header", header: "X-API-Key", wantName: "X-API-Key", wantValue: "secret-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tt.wantName) != tt.wantValue {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"content":"siema"}`))
+			}))
+			defer server.Close()
+
+			cfg := config.LLMConfig{ServerURL: server.URL, ServerAPIKey: "secret-key", ServerAuthHeader: tt.header}
+			client := newServerClient(cfg)
+			if _, err := client.Generate(context.Background(), Request{Bot: models.BotProfile{Name: "Kuba"}}); err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			if err := checkServerReady(&http.Client{}, server.URL, newServerAuth(cfg)); err != nil {
+				t.Fatalf("checkServerReady() error: %v", err)
+			}
+
+			unauthenticated := newServerClient(config.LLMConfig{ServerURL: server.URL})
+			if _, err := unauthenticated.Generate(context.Background(), Request{Bot: models.BotProfile{Name: "Kuba"}}); err == nil {
+				t.Fatal("expected error without auth header")
+			}
+			if err := checkServerReady(&http.Client{}, server.URL, serverAuth{}); err == nil {
+				t.Fatal("expected ready check to fail without auth header")
+			}
+		})
+	}
+}
+
+func TestServerClientRetriesTransientFailures(t *testing.T) {
+	respondOK := func(w http.ResponseWriter) { _, _ = w.Write([]byte(`{"content":"siema"}`)) }
+	tests := []struct {
+		name       string
+		maxRetries int
+		timeout    time.Duration
+		handle     func(call int32, w http.ResponseWriter)
+		wantCalls  int32
+		wantErr    string
+	}{
+		{
+			name:       "503 then success",
+			maxRetries: 1,
+			handle: func(call int32, w http.ResponseWriter) {
+				if call == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				respondOK(w)
+			},
+			wantCalls: 2,
+		},
+		{
+			name:       "connection reset then success",
+			maxRetries: 1,
+			handle: func(call int32, w http.ResponseWriter) {
+				if call == 1 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					_ = conn.Close()
+					return
+				}
+				respondOK(w)
+			},
+			wantCalls: 2,
+		},
+		{
+			name:       "4xx is not retried",
+			maxRetries: 1,
+			handle: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			wantCalls: 1,
+			wantErr:   "status=400",
+		},
+		{
+			name:       "retries exhausted",
+			maxRetries: 1,
+			handle: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantCalls: 2,
+			wantErr:   "attempts=2",
+		},
+		{
+			name:       "deadline caps retries",
+			maxRetries: 10,
+			timeout:    120 * time.Millisecond,
+			handle: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr: "status=503",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handle(calls.Add(1), w)
+			}))
+			defer server.Close()
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = time.Second
+			}
+			client := newServerClient(config.LLMConfig{ServerURL: server.URL, MaxRetries: tt.maxRetries, Timeout: timeout})
+			start := time.Now()
+			message, err := client.Generate(context.Background(), Request{Bot: models.BotProfile{Name: "Kuba"}})
+			if elapsed := time.Since(start); elapsed > timeout+50*time.Millisecond {
+				t.Fatalf("Generate() took %s, deadline was %s", elapsed, timeout)
+			}
+			if tt.wantErr == "" {
+				if err != nil || message != "siema" {
+					t.Fatalf("Generate() = %q, %v", message, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Generate() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantCalls > 0 && calls.Load() != tt.wantCalls {
+				t.Fatalf("server calls = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestServerClientReconfigure(t *testing.T) {
+	payloads := make(chan map[string]any, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+		_, _ = w.Write([]byte(`{"content":"siema"}`))
+	}))
+	defer server.Close()
+
+	client := newServerClient(config.LLMConfig{ServerURL: server.URL, Temperature: 0.5, MaxTokens: 40})
+	req := Request{Bot: models.BotProfile{Name: "Kuba"}}
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if payload := <-payloads; payload["temperature"] != 0.5 {
+		t.Fatalf("temperature = %v, want 0.5", payload["temperature"])
+	}
+
+	client.Reconfigure(config.LLMConfig{ServerURL: "http://127.0.0.1:1", Temperature: 1.1, MaxTokens: 40})
+	if _, err := client.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate() after Reconfigure error: %v", err)
+	}
+	if payload := <-payloads; payload["temperature"] != 1.1 {
+		t.Fatalf("temperature = %v, want 1.1 after Reconfigure", payload["temperature"])
+	}
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"aichatplayers/internal/logging"
+)
+
+// servedModel asks a running llama-server which model it loaded, via /props
+// and then /v1/models. It returns "" when the server does not say.
+func servedModel(client *http.Client, serverURL string, auth serverAuth) string {
+	base := strings.TrimSuffix(strings.TrimRight(serverURL, "/"), "/v1")
+
+	var props struct {
+		ModelPath       string `json:"model_path"`
+		GenerationProps struct {
+			Model string `json:"model"`
+		} `json:"default_generation_settings"`
+	}
+	if getServerJSON(client, base+"/props", auth, &props) {
+		if props.ModelPath != "" {
+			return props.ModelPath
+		}
+		if props.GenerationProps.Model != "" {
+			return props.GenerationProps.Model
+		}
+	}
+
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if getServerJSON(client, base+"/v1/models", auth, &models) && len(models.Data) > 0 {
+		return models.Data[0].ID
+	}
+	return ""
+}
+
+func getServerJSON(client *http.Client, endpoint string, auth serverAuth, target any) bool {
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false
+	}
+	auth.apply(request)
+	resp, err := client.Do(request)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false
+	}
+	return json.Unmarshal(body, target) == nil
+}
+
+// servedModelMismatch reports whether the server at serverURL runs a model
+// other than modelPath. Servers that do not report a GGUF file name (no
+// endpoint, or an --alias) count as unknown, never as a mismatch.
+func servedModelMismatch(client *http.Client, serverURL, modelPath string, auth serverAuth) bool {
+	served := servedModel(client, serverURL, auth)
+	if !strings.HasSuffix(strings.ToLower(served), ".gguf") {
+		logging.Debugf("llm_server_model_unknown url=%s served_model=%q", serverURL, served)
+		return false
+	}
+	if modelFileName(served) == modelFileName(modelPath) {
+		return false
+	}
+	logging.Warnf("llm_server_model_mismatch url=%s served_model=%s configured_model=%s", serverURL, served, modelPath)
+	return true
+}
+
+// modelFileName strips the directory with either separator, since the server
+// may run on another OS or report a relative path.
+func modelFileName(path string) string {
+	return path[strings.LastIndexAny(path, `/\`)+1:]
+}
@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServedModelMismatch(t *testing.T) {
+	const configured = "/srv/models/qwen2.5-1.5b-instruct-q4_k_m.gguf"
+	tests := []struct {
+		name   string
+		routes map[string]string
+		want   bool
+	}{
+		{name: "props match", routes: map[string]string{"/props": `{"model_path":"/srv/models/qwen2.5-1.5b-instruct-q4_k_m.gguf"}`}},
+		{name: "props match other directory", routes: map[string]string{"/props": `{"model_path":"C:\\models\\qwen2.5-1.5b-instruct-q4_k_m.gguf"}`}},
+		{name: "props mismatch", routes: map[string]string{"/props": `{"model_path":"/home/op/llama-3-8b.Q4_0.gguf"}`}, want: true},
+		{name: "legacy props mismatch", routes: map[string]string{"/props": `{"default_generation_settings":{"model":"models/phi-2.gguf"}}`}, want: true},
+		{name: "models endpoint match", routes: map[string]string{"/v1/models": `{"data":[{"id":"qwen2.5-1.5b-instruct-q4_k_m.gguf"}]}`}},
+		{name: "models endpoint mismatch", routes: map[string]string{"/v1/models": `{"data":[{"id":"/models/mistral-7b.gguf"}]}`}, want: true},
+		{name: "alias is unknown", routes: map[string]string{"/v1/models": `{"data":[{"id":"chat-bot"}]}`}},
+		{name: "no endpoints", routes: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.routes[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			client := &http.Client{Timeout: time.Second}
+			if got := servedModelMismatch(client, server.URL+"/v1", configured, serverAuth{}); got != tt.want {
+				t.Fatalf("servedModelMismatch() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,10 +11,10 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"aichatplayers/internal/config"
@@ -22,21 +22,37 @@ import (
 )
 
 const defaultServerCommand = "llama-server"
-const serverStateFilename = "llm_server_state.json"
+const legacyServerStateFilename = "llm_server_state.json"
 
 var errServerStateMissing = errors.New("llm server state missing")
 
+// ServerProcess is a llama-server started by EnsureServerReady. It is
+// relaunched when it crashes until Close is called.
 type ServerProcess struct {
-	cmd    *exec.Cmd
-	exitCh chan error
-	url    string
+	url            string
+	state          serverState
+	stateFile      serverStateFile
+	auth           serverAuth
+	startupTimeout time.Duration
+	maxRestarts    int
+	restartWindow  time.Duration
+	backoffBase    time.Duration
+
+	mu        sync.Mutex
+	run       *serverRun
+	stopped   bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type serverState struct {
-	URL     string   `json:"url"`
-	Command string   `json:"command"`
-	Args    []string `json:"args"`
-	PID     int      `json:"pid"`
+	URL     string            `json:"url"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	PID     int               `json:"pid"`
+	Model   *modelFingerprint `json:"model,omitempty"`
 }
 
 func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
@@ -63,6 +79,8 @@ func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
 	if err != nil {
 		return nil, err
 	}
+	stateFile := newServerStateFile(cfg.StateDir, port)
+	stateFile.migrateLegacy(serverURL)
 
 	modelOk := true
 	if modelPath == "" {
@@ -77,13 +95,7 @@ func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
 	canStartServer := commandOk && modelOk
 	var args []string
 	if canStartServer {
-		args = []string{"--model", modelPath, "--host", host, "--port", port}
-		if cfg.CtxSize > 0 {
-			args = append(args, "--ctx-size", fmt.Sprint(cfg.CtxSize))
-		}
-		if cfg.NumThreads > 0 {
-			args = append(args, "--threads", fmt.Sprint(cfg.NumThreads))
-		}
+		args = serverArgs(cfg, modelPath, host, port)
 	}
 
 	desiredState := serverState{
@@ -91,22 +103,36 @@ func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
 		Command: command,
 		Args:    args,
 	}
+	if canStartServer {
+		fingerprint, err := fingerprintModel(modelPath)
+		if err != nil {
+			logging.Warnf("llm_server_model_fingerprint_failed path=%s error=%v", modelPath, err)
+		} else {
+			desiredState.Model = fingerprint
+		}
+	}
 
+	auth := newServerAuth(cfg)
 	client := &http.Client{Timeout: 750 * time.Millisecond}
-	if err := checkServerReady(client, serverURL); err == nil {
+	if err := checkServerReady(client, serverURL, auth); err == nil {
 		if !canStartServer {
 			logging.Infof("llm_server_detected url=%s status=ready", serverURL)
 			return nil, nil
 		}
-		restartNeeded, existingState, err := needsServerRestart(desiredState)
+		restartNeeded, reason, existingState, err := needsServerRestart(stateFile, desiredState)
 		if err != nil {
 			if errors.Is(err, errServerStateMissing) {
-				logging.Warnf("llm_server_state_missing url=%s path=%s", serverURL, serverStatePath())
+				logging.Warnf("llm_server_state_missing url=%s path=%s", serverURL, stateFile)
 				restartNeeded = true
+				reason = "state_missing"
 			} else {
 				logging.Warnf("llm_server_state_read_failed url=%s error=%v", serverURL, err)
 			}
 		}
+		if !restartNeeded && cfg.ServerModelCheck && servedModelMismatch(client, serverURL, modelPath, auth) {
+			restartNeeded = true
+			reason = "served_model_mismatch"
+		}
 		if !restartNeeded {
 			logging.Infof("llm_server_detected url=%s status=ready", serverURL)
 			if existingState != nil && existingState.PID > 0 {
@@ -117,8 +143,8 @@ func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
 			return nil, nil
 		}
 
-		logging.Infof("llm_server_restart_required url=%s", serverURL)
-		if err := restartRunningServer(serverURL, existingState); err != nil {
+		logging.Infof("llm_server_restart_required url=%s reason=%s", serverURL, reason)
+		if err := restartRunningServer(stateFile, serverURL, existingState, auth); err != nil {
 			return nil, err
 		}
 	} else {
@@ -131,93 +157,96 @@ func EnsureServerReady(cfg config.LLMConfig) (*ServerProcess, error) {
 			timeout = 60 * time.Second
 		}
 		logging.Warnf("llm_server_start_skipped url=%s reason=missing_command_or_model waiting_for_ready_timeout=%s", serverURL, timeout)
-		if err := waitForServerReady(serverURL, timeout, nil); err != nil {
+		if err := waitForServerReady(serverURL, timeout, nil, auth); err != nil {
 			return nil, err
 		}
 		logging.Infof("llm_server_ready url=%s", serverURL)
 		return nil, nil
 	}
 
-	cmd := exec.Command(command, args...)
-	configureCommand(cmd)
-	cmd.Stdout = log.Writer()
-	cmd.Stderr = log.Writer()
-
-	logging.Infof("llm_server_starting command=%s args=%s url=%s", command, strings.Join(args, " "), serverURL)
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("llm server start: %w", err)
-	}
-	if err := writeServerState(desiredState, cmd.Process.Pid); err != nil {
-		logging.Warnf("llm_server_state_write_failed url=%s error=%v", serverURL, err)
-	}
-
-	proc := &ServerProcess{
-		cmd:    cmd,
-		exitCh: make(chan error, 1),
-		url:    serverURL,
-	}
-	go func() {
-		proc.exitCh <- cmd.Wait()
-	}()
-
 	timeout := cfg.ServerStartupTimeout
 	if timeout <= 0 {
 		timeout = 60 * time.Second
 	}
+	proc := &ServerProcess{
+		url:            serverURL,
+		state:          desiredState,
+		stateFile:      stateFile,
+		auth:           auth,
+		startupTimeout: timeout,
+		maxRestarts:    cfg.ServerMaxRestarts,
+		restartWindow:  serverRestartWindow,
+		backoffBase:    serverRestartBackoffBase,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	run, err := proc.launch()
+	if err != nil {
+		return nil, err
+	}
+
 	logging.Debugf("llm_server_waiting url=%s timeout=%s", serverURL, timeout)
-	if err := waitForServerReady(serverURL, timeout, proc.exitCh); err != nil {
-		_ = proc.Close()
+	if err := waitForServerReady(serverURL, timeout, run, auth); err != nil {
+		_ = run.terminate(serverURL, stateFile)
 		return nil, err
 	}
 
 	logging.Infof("llm_server_ready url=%s", serverURL)
+	go proc.supervise()
 	return proc, nil
 }
 
-func (p *ServerProcess) Close() error {
-	if p == nil || p.cmd == nil || p.cmd.Process == nil {
-		return nil
+func serverArgs(cfg config.LLMConfig, modelPath, host, port string) []string {
+	args := []string{"--model", modelPath, "--host", host, "--port", port}
+	if cfg.CtxSize > 0 {
+		args = append(args, "--ctx-size", fmt.Sprint(cfg.CtxSize))
 	}
-
-	logging.Infof("llm_server_stopping url=%s pid=%d", p.url, p.cmd.Process.Pid)
-	if err := p.cmd.Process.Signal(interruptSignal()); err != nil {
-		return fmt.Errorf("llm server signal: %w", err)
+	if cfg.NumThreads > 0 {
+		args = append(args, "--threads", fmt.Sprint(cfg.NumThreads))
 	}
+	if cfg.Parallel > 0 {
+		args = append(args, "--parallel", fmt.Sprint(cfg.Parallel))
+	}
+	return append(args, tuningArgs(cfg)...)
+}
 
-	select {
-	case err := <-p.exitCh:
-		if err != nil {
-			return fmt.Errorf("llm server stop: %w", err)
-		}
-		_ = removeServerState()
-		return nil
-	case <-time.After(5 * time.Second):
-		if killErr := p.cmd.Process.Kill(); killErr != nil {
-			return fmt.Errorf("llm server kill: %w", killErr)
-		}
-		_ = removeServerState()
+// Close stops the supervisor before the process, so shutdown never respawns
+// the server.
+func (p *ServerProcess) Close() error {
+	if p == nil {
 		return nil
 	}
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		run := p.run
+		p.mu.Unlock()
+
+		close(p.stop)
+		p.closeErr = run.terminate(p.url, p.stateFile)
+		<-p.done
+	})
+	return p.closeErr
 }
 
-func waitForServerReady(serverURL string, timeout time.Duration, exitCh <-chan error) error {
+func waitForServerReady(serverURL string, timeout time.Duration, run *serverRun, auth serverAuth) error {
 	client := &http.Client{Timeout: 1 * time.Second}
 	deadline := time.Now().Add(timeout)
 	var lastErr error
 	for {
-		if err := checkServerReady(client, serverURL); err == nil {
+		if err := checkServerReady(client, serverURL, auth); err == nil {
 			return nil
 		} else {
 			lastErr = err
 		}
 
-		if exitCh != nil {
+		if run != nil {
 			select {
-			case err := <-exitCh:
-				if err == nil {
+			case <-run.exited:
+				if run.err == nil {
 					return errors.New("llm server exited before ready")
 				}
-				return fmt.Errorf("llm server exited: %w", err)
+				return fmt.Errorf("llm server exited: %w", run.err)
 			case <-time.After(300 * time.Millisecond):
 			}
 		} else {
@@ -233,10 +262,11 @@ func waitForServerReady(serverURL string, timeout time.Duration, exitCh <-chan e
 	}
 }
 
-func checkServerReady(client *http.Client, serverURL string) error {
+func checkServerReady(client *http.Client, serverURL string, auth serverAuth) error {
 	healthURL := strings.TrimRight(serverURL, "/") + "/health"
 	req, err := http.NewRequest(http.MethodGet, healthURL, nil)
 	if err == nil {
+		auth.apply(req)
 		resp, err := client.Do(req)
 		if err == nil {
 			_, _ = io.Copy(io.Discard, resp.Body)
@@ -247,6 +277,18 @@ func checkServerReady(client *http.Client, serverURL string) error {
 		}
 	}
 
+	modelsURL := strings.TrimSuffix(strings.TrimRight(serverURL, "/"), "/v1") + "/v1/models"
+	if req, err := http.NewRequest(http.MethodGet, modelsURL, nil); err == nil {
+		auth.apply(req)
+		if resp, err := client.Do(req); err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+				return nil
+			}
+		}
+	}
+
 	payload := map[string]any{
 		"prompt":    "ping",
 		"n_predict": 1,
@@ -262,6 +304,7 @@ func checkServerReady(client *http.Client, serverURL string) error {
 		return fmt.Errorf("llm server ready check: %w", err)
 	}
 	request.Header.Set("Content-Type", "application/json")
+	auth.apply(request)
 
 	resp, err := client.Do(request)
 	if err != nil {
@@ -275,29 +318,30 @@ func checkServerReady(client *http.Client, serverURL string) error {
 	return fmt.Errorf("llm server ready check status=%d", resp.StatusCode)
 }
 
-func needsServerRestart(desired serverState) (bool, *serverState, error) {
-	state, err := readServerState()
+func needsServerRestart(stateFile serverStateFile, desired serverState) (bool, string, *serverState, error) {
+	state, err := stateFile.read()
 	if err != nil || state == nil {
 		if err == nil && state == nil {
-			return false, nil, errServerStateMissing
+			return false, "", nil, errServerStateMissing
 		}
-		return false, nil, err
+		return false, "", nil, err
 	}
-	return !state.matches(desired), state, nil
+	reason := state.mismatch(desired)
+	return reason != "", reason, state, nil
 }
 
-func restartRunningServer(serverURL string, state *serverState) error {
+func restartRunningServer(stateFile serverStateFile, serverURL string, state *serverState, auth serverAuth) error {
 	if state == nil || state.PID == 0 {
 		logging.Warnf("llm_server_restart_missing_pid url=%s", serverURL)
-		return stopServerByURL(serverURL)
+		return stopServerByURL(stateFile, serverURL, auth)
 	}
-	if err := stopServerByPID(state.PID, serverURL); err != nil {
+	if err := stopServerByPID(stateFile, state.PID, serverURL, auth); err != nil {
 		return err
 	}
 	return nil
 }
 
-func stopServerByPID(pid int, serverURL string) error {
+func stopServerByPID(stateFile serverStateFile, pid int, serverURL string, auth serverAuth) error {
 	proc, err := os.FindProcess(pid)
 	if err != nil {
 		return fmt.Errorf("llm server find process: %w", err)
@@ -306,21 +350,21 @@ func stopServerByPID(pid int, serverURL string) error {
 	if err := proc.Signal(interruptSignal()); err != nil {
 		logging.Warnf("llm_server_signal_failed pid=%d error=%v", pid, err)
 	}
-	if err := waitForServerStop(serverURL, 5*time.Second); err == nil {
-		_ = removeServerState()
+	if err := waitForServerStop(serverURL, 5*time.Second, auth); err == nil {
+		_ = stateFile.remove()
 		return nil
 	}
 	if err := proc.Kill(); err != nil {
 		return fmt.Errorf("llm server kill: %w", err)
 	}
-	if err := waitForServerStop(serverURL, 5*time.Second); err != nil {
+	if err := waitForServerStop(serverURL, 5*time.Second, auth); err != nil {
 		return err
 	}
-	_ = removeServerState()
+	_ = stateFile.remove()
 	return nil
 }
 
-func stopServerByURL(serverURL string) error {
+func stopServerByURL(stateFile serverStateFile, serverURL string, auth serverAuth) error {
 	client := &http.Client{Timeout: 1 * time.Second}
 	endpoints := []string{"/shutdown", "/exit"}
 	methods := []string{http.MethodPost, http.MethodGet}
@@ -331,6 +375,7 @@ func stopServerByURL(serverURL string) error {
 			if err != nil {
 				continue
 			}
+			auth.apply(req)
 			resp, err := client.Do(req)
 			if err != nil {
 				continue
@@ -339,8 +384,8 @@ func stopServerByURL(serverURL string) error {
 			resp.Body.Close()
 			if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
 				logging.Infof("llm_server_shutdown_requested url=%s method=%s endpoint=%s", serverURL, method, endpoint)
-				if err := waitForServerStop(serverURL, 5*time.Second); err == nil {
-					_ = removeServerState()
+				if err := waitForServerStop(serverURL, 5*time.Second, auth); err == nil {
+					_ = stateFile.remove()
 					return nil
 				}
 			} else {
@@ -351,11 +396,11 @@ func stopServerByURL(serverURL string) error {
 	return fmt.Errorf("llm server stop request failed url=%s", serverURL)
 }
 
-func waitForServerStop(serverURL string, timeout time.Duration) error {
+func waitForServerStop(serverURL string, timeout time.Duration, auth serverAuth) error {
 	client := &http.Client{Timeout: 500 * time.Millisecond}
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		if err := checkServerReady(client, serverURL); err != nil {
+		if err := checkServerReady(client, serverURL, auth); err != nil {
 			return nil
 		}
 		time.Sleep(200 * time.Millisecond)
@@ -387,28 +432,40 @@ func hostPortForURL(serverURL string) (string, string, error) {
 	return host, port, nil
 }
 
-func (s serverState) matches(other serverState) bool {
-	if s.URL != other.URL || s.Command != other.Command {
-		return false
+func (s serverState) mismatch(other serverState) string {
+	if s.URL != other.URL {
+		return "url_changed"
+	}
+	if s.Command != other.Command {
+		return "command_changed"
 	}
 	if len(s.Args) != len(other.Args) {
-		return false
+		return "args_changed"
 	}
 	for i := range s.Args {
 		if s.Args[i] != other.Args[i] {
-			return false
+			return "args_changed"
 		}
 	}
-	return true
+	if s.Model != nil && other.Model != nil && !s.Model.equal(other.Model) {
+		return "model_changed"
+	}
+	return ""
 }
 
-func serverStatePath() string {
-	return filepath.Join("logs", serverStateFilename)
+// serverStateFile records the managed server's launch state. It is named
+// after the listen port, so instances sharing a state dir do not collide.
+type serverStateFile string
+
+func newServerStateFile(dir, port string) serverStateFile {
+	if dir == "" {
+		dir = "logs"
+	}
+	return serverStateFile(filepath.Join(dir, fmt.Sprintf("llm_server_state_%s.json", port)))
 }
 
-func readServerState() (*serverState, error) {
-	path := serverStatePath()
-	data, err := os.ReadFile(path)
+func (f serverStateFile) read() (*serverState, error) {
+	data, err := os.ReadFile(string(f))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -422,23 +479,46 @@ func readServerState() (*serverState, error) {
 	return &state, nil
 }
 
-func writeServerState(state serverState, pid int) error {
+func (f serverStateFile) write(state serverState, pid int) error {
 	state.PID = pid
 	data, err := json.Marshal(state)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(serverStatePath(), data, 0o644)
+	if err := os.MkdirAll(filepath.Dir(string(f)), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(string(f), data, 0o644)
 }
 
-func removeServerState() error {
-	err := os.Remove(serverStatePath())
+func (f serverStateFile) remove() error {
+	err := os.Remove(string(f))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
 
+// migrateLegacy moves the state file older versions kept at
+// logs/llm_server_state.json, if it describes serverURL and f is not there
+// yet.
+func (f serverStateFile) migrateLegacy(serverURL string) {
+	if _, err := os.Stat(string(f)); !os.IsNotExist(err) {
+		return
+	}
+	legacy := serverStateFile(filepath.Join("logs", legacyServerStateFilename))
+	state, err := legacy.read()
+	if err != nil || state == nil || state.URL != serverURL {
+		return
+	}
+	if err := f.write(*state, state.PID); err != nil {
+		logging.Warnf("llm_server_state_migrate_failed from=%s to=%s error=%v", legacy, f, err)
+		return
+	}
+	_ = legacy.remove()
+	logging.Infof("llm_server_state_migrated from=%s to=%s", legacy, f)
+}
+
 func attachServerLogs(pid int) {
 	if pid <= 0 {
 		logging.Warnf("llm_server_log_attach_skipped reason=invalid_pid pid=%d", pid)
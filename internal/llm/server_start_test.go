@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+)
+
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.MkdirAll("logs", 0o755); err != nil {
+		t.Fatalf("mkdir logs: %v", err)
+	}
+	return dir
+}
+
+func TestNeedsServerRestartDetectsModelSwap(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := newServerStateFile(dir, "8080")
+	modelPath := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(modelPath, []byte("weights-v1"), 0o644); err != nil {
+		t.Fatalf("write model: %v", err)
+	}
+
+	desiredState := func() serverState {
+		fingerprint, err := fingerprintModel(modelPath)
+		if err != nil {
+			t.Fatalf("fingerprintModel() error: %v", err)
+		}
+		return serverState{
+			URL:     "http://127.0.0.1:8080",
+			Command: "llama-server",
+			Args:    []string{"--model", modelPath},
+			Model:   fingerprint,
+		}
+	}
+
+	if err := stateFile.write(desiredState(), 1234); err != nil {
+		t.Fatalf("write() error: %v", err)
+	}
+	restart, reason, _, err := needsServerRestart(stateFile, desiredState())
+	if err != nil {
+		t.Fatalf("needsServerRestart() error: %v", err)
+	}
+	if restart || reason != "" {
+		t.Fatalf("expected no restart for unchanged model, got restart=%t reason=%s", restart, reason)
+	}
+
+	if err := os.WriteFile(modelPath, []byte("weights-v2"), 0o644); err != nil {
+		t.Fatalf("swap model: %v", err)
+	}
+	restart, reason, state, err := needsServerRestart(stateFile, desiredState())
+	if err != nil {
+		t.Fatalf("needsServerRestart() error: %v", err)
+	}
+	if !restart || reason != "model_changed" {
+		t.Fatalf("expected model_changed restart, got restart=%t reason=%s", restart, reason)
+	}
+	if state == nil || state.PID != 1234 {
+		t.Fatalf("expected existing state with pid, got %+v", state)
+	}
+}
+
+func TestNeedsServerRestartDetectsModelTouch(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := newServerStateFile(dir, "8080")
+	modelPath := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(modelPath, []byte("weights"), 0o644); err != nil {
+		t.Fatalf("write model: %v", err)
+	}
+	fingerprint, err := fingerprintModel(modelPath)
+	if err != nil {
+		t.Fatalf("fingerprintModel() error: %v", err)
+	}
+	if err := stateFile.write(serverState{URL: "u", Model: fingerprint}, 1); err != nil {
+		t.Fatalf("write() error: %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(modelPath, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	touched, err := fingerprintModel(modelPath)
+	if err != nil {
+		t.Fatalf("fingerprintModel() error: %v", err)
+	}
+	if restart, reason, _, _ := needsServerRestart(stateFile, serverState{URL: "u", Model: touched}); !restart || reason != "model_changed" {
+		t.Fatalf("expected model_changed restart, got restart=%t reason=%s", restart, reason)
+	}
+}
+
+func TestFingerprintModelReadsBoundedWindows(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.gguf")
+	data := make([]byte, 3*modelHashWindow)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write model: %v", err)
+	}
+	first, err := fingerprintModel(path)
+	if err != nil {
+		t.Fatalf("fingerprintModel() error: %v", err)
+	}
+	data[len(data)-1] = 1
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("rewrite model: %v", err)
+	}
+	second, err := fingerprintModel(path)
+	if err != nil {
+		t.Fatalf("fingerprintModel() error: %v", err)
+	}
+	if first.PartialHash == second.PartialHash {
+		t.Fatal("expected tail change to alter partial hash")
+	}
+}
+
+func TestServerArgsIncludeTuningFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.LLMConfig
+		want string
+	}{
+		{name: "defaults", cfg: config.LLMConfig{}, want: "--model|m.gguf|--host|127.0.0.1|--port|8080"},
+		{
+			name: "gpu host",
+			cfg:  config.LLMConfig{CtxSize: 4096, GPULayers: 99, Parallel: 4, BatchSize: 512, ExtraArgs: []string{"--flash-attn", "--alias", "chat bot"}},
+			want: "--model|m.gguf|--host|127.0.0.1|--port|8080|--ctx-size|4096|--parallel|4|--n-gpu-layers|99|--batch-size|512|--flash-attn|--alias|chat bot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(serverArgs(tt.cfg, "m.gguf", "127.0.0.1", "8080"), "|"); got != tt.want {
+				t.Fatalf("serverArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsServerRestartWhenTuningChanges(t *testing.T) {
+	stateFile := newServerStateFile(t.TempDir(), "8080")
+	cpu := config.LLMConfig{NumThreads: 8}
+	gpu := config.LLMConfig{NumThreads: 8, GPULayers: 35, ExtraArgs: []string{"--flash-attn"}}
+	state := func(cfg config.LLMConfig) serverState {
+		return serverState{URL: "http://127.0.0.1:8080", Command: "llama-server", Args: serverArgs(cfg, "m.gguf", "127.0.0.1", "8080")}
+	}
+
+	if err := stateFile.write(state(cpu), 1234); err != nil {
+		t.Fatalf("write() error: %v", err)
+	}
+	if restart, reason, _, err := needsServerRestart(stateFile, state(cpu)); err != nil || restart {
+		t.Fatalf("unchanged args: restart=%t reason=%s err=%v", restart, reason, err)
+	}
+	if restart, reason, _, err := needsServerRestart(stateFile, state(gpu)); err != nil || !restart || reason != "args_changed" {
+		t.Fatalf("expected args_changed restart, got restart=%t reason=%s err=%v", restart, reason, err)
+	}
+}
+
+func TestServerStateFilePerPort(t *testing.T) {
+	dir := t.TempDir()
+	first, second := newServerStateFile(dir, "8080"), newServerStateFile(dir, "8081")
+	if first == second {
+		t.Fatalf("instances on different ports share %s", first)
+	}
+	if err := first.write(serverState{URL: "http://127.0.0.1:8080"}, 11); err != nil {
+		t.Fatalf("write() error: %v", err)
+	}
+	if state, err := second.read(); err != nil || state != nil {
+		t.Fatalf("second instance read %+v, %v", state, err)
+	}
+	if got := newServerStateFile("", "8080"); got != serverStateFile(filepath.Join("logs", "llm_server_state_8080.json")) {
+		t.Fatalf("default state file = %s", got)
+	}
+}
+
+func TestServerStateFileMigratesLegacyPath(t *testing.T) {
+	const serverURL = "http://127.0.0.1:8080"
+	legacy := serverStateFile(filepath.Join("logs", legacyServerStateFilename))
+	tests := []struct {
+		name         string
+		legacyURL    string
+		existing     bool
+		wantPID      int
+		wantMigrated bool
+	}{
+		{name: "migrates matching state", legacyURL: serverURL, wantPID: 42, wantMigrated: true},
+		{name: "ignores another server", legacyURL: "http://127.0.0.1:9090"},
+		{name: "keeps existing new state", legacyURL: serverURL, existing: true, wantPID: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdirTemp(t)
+			stateFile := newServerStateFile(filepath.Join(t.TempDir(), "state"), "8080")
+			if err := legacy.write(serverState{URL: tt.legacyURL, Command: "llama-server"}, 42); err != nil {
+				t.Fatalf("write legacy: %v", err)
+			}
+			if tt.existing {
+				if err := stateFile.write(serverState{URL: serverURL}, 7); err != nil {
+					t.Fatalf("write state: %v", err)
+				}
+			}
+
+			stateFile.migrateLegacy(serverURL)
+
+			state, err := stateFile.read()
+			if err != nil {
+				t.Fatalf("read() error: %v", err)
+			}
+			if tt.wantPID == 0 && state != nil {
+				t.Fatalf("unexpected migrated state %+v", state)
+			}
+			if tt.wantPID != 0 && (state == nil || state.PID != tt.wantPID) {
+				t.Fatalf("state = %+v, want pid %d", state, tt.wantPID)
+			}
+			legacyState, _ := legacy.read()
+			if (legacyState == nil) != tt.wantMigrated {
+				t.Fatalf("legacy state after migration = %+v, migrated %t", legacyState, tt.wantMigrated)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"aichatplayers/internal/logging"
+)
+
+const (
+	serverRestartWindow      = 10 * time.Minute
+	serverRestartBackoffBase = time.Second
+	serverRestartBackoffMax  = 30 * time.Second
+)
+
+var errServerClosed = errors.New("llm server closed")
+
+// serverRun is one launch of the managed llama-server.
+type serverRun struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+func startServerRun(command string, args []string) (*serverRun, error) {
+	cmd := exec.Command(command, args...)
+	configureCommand(cmd)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("llm server start: %w", err)
+	}
+	run := &serverRun{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		run.err = cmd.Wait()
+		close(run.exited)
+	}()
+	return run, nil
+}
+
+func (r *serverRun) pid() int {
+	if r == nil || r.cmd.Process == nil {
+		return 0
+	}
+	return r.cmd.Process.Pid
+}
+
+func (r *serverRun) terminate(url string, stateFile serverStateFile) error {
+	if r == nil || r.cmd.Process == nil {
+		return nil
+	}
+	select {
+	case <-r.exited:
+		_ = stateFile.remove()
+		return nil
+	default:
+	}
+
+	logging.Infof("llm_server_stopping url=%s pid=%d", url, r.pid())
+	if err := r.cmd.Process.Signal(interruptSignal()); err != nil {
+		return fmt.Errorf("llm server signal: %w", err)
+	}
+
+	select {
+	case <-r.exited:
+		if r.err != nil {
+			return fmt.Errorf("llm server stop: %w", r.err)
+		}
+		_ = stateFile.remove()
+		return nil
+	case <-time.After(5 * time.Second):
+		if killErr := r.cmd.Process.Kill(); killErr != nil {
+			return fmt.Errorf("llm server kill: %w", killErr)
+		}
+		_ = stateFile.remove()
+		return nil
+	}
+}
+
+// launch starts a new run unless Close already began, so shutdown never
+// races a respawn.
+func (p *ServerProcess) launch() (*serverRun, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return nil, errServerClosed
+	}
+	logging.Infof("llm_server_starting command=%s args=%s url=%s", p.state.Command, strings.Join(p.state.Args, " "), p.url)
+	run, err := startServerRun(p.state.Command, p.state.Args)
+	if err != nil {
+		return nil, err
+	}
+	p.run = run
+	if err := p.stateFile.write(p.state, run.pid()); err != nil {
+		logging.Warnf("llm_server_state_write_failed url=%s error=%v", p.url, err)
+	}
+	return run, nil
+}
+
+func (p *ServerProcess) current() *serverRun {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.run
+}
+
+// supervise relaunches the server when it exits on its own, backing off
+// exponentially and giving up after maxRestarts within serverRestartWindow.
+func (p *ServerProcess) supervise() {
+	defer close(p.done)
+	var restarts []time.Time
+	for {
+		run := p.current()
+		select {
+		case <-p.stop:
+			return
+		case <-run.exited:
+		}
+		if p.stopping() {
+			return
+		}
+		logging.Warnf("llm_server_exited url=%s pid=%d error=%v", p.url, run.pid(), run.err)
+
+		for {
+			restarts = recentRestarts(restarts, time.Now(), p.restartWindow)
+			if len(restarts) >= p.maxRestarts {
+				logging.Errorf("llm_server_restart_gave_up url=%s restarts=%d window=%s", p.url, len(restarts), p.restartWindow)
+				_ = p.stateFile.remove()
+				return
+			}
+			backoff := restartBackoff(p.backoffBase, len(restarts))
+			logging.Warnf("llm_server_restarting url=%s attempt=%d backoff_ms=%d", p.url, len(restarts)+1, backoff.Milliseconds())
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(backoff):
+			}
+			restarts = append(restarts, time.Now())
+
+			run, err := p.launch()
+			if errors.Is(err, errServerClosed) {
+				return
+			}
+			if err == nil {
+				err = waitForServerReady(p.url, p.startupTimeout, run, p.auth)
+				if err == nil {
+					logging.Infof("llm_server_restarted url=%s pid=%d", p.url, run.pid())
+					break
+				}
+				_ = run.terminate(p.url, p.stateFile)
+			}
+			if p.stopping() {
+				return
+			}
+			logging.Warnf("llm_server_restart_failed url=%s error=%v", p.url, err)
+		}
+	}
+}
+
+func (p *ServerProcess) stopping() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func recentRestarts(restarts []time.Time, now time.Time, window time.Duration) []time.Time {
+	kept := restarts[:0]
+	for _, at := range restarts {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	return kept
+}
+
+func restartBackoff(base time.Duration, attempt int) time.Duration {
+	backoff := base
+	for i := 0; i < attempt && backoff < serverRestartBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > serverRestartBackoffMax {
+		return serverRestartBackoffMax
+	}
+	return backoff
+}
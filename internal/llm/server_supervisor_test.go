@@ -0,0 +1,145 @@
+//go:build !windows
+
+package llm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestServerProcess runs script under sh as a fake llama-server; the
+// httptest server answers its health checks.
+func startTestServerProcess(t *testing.T, script string, maxRestarts int) *ServerProcess {
+	t.Helper()
+	chdirTemp(t)
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(health.Close)
+
+	proc := &ServerProcess{
+		url:            health.URL,
+		state:          serverState{URL: health.URL, Command: "sh", Args: []string{"-c", "echo $$ >> launches; " + script}},
+		stateFile:      newServerStateFile("state", "8080"),
+		startupTimeout: time.Second,
+		maxRestarts:    maxRestarts,
+		restartWindow:  time.Minute,
+		backoffBase:    time.Millisecond,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	if _, err := proc.launch(); err != nil {
+		t.Fatalf("launch() error: %v", err)
+	}
+	go proc.supervise()
+	t.Cleanup(func() { _ = proc.Close() })
+	return proc
+}
+
+func launchedPIDs(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile("launches")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+func waitForLaunches(t *testing.T, want int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pids := launchedPIDs(t)
+		if len(pids) >= want {
+			return pids
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("launches = %d, want %d", len(pids), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+const idleServerScript = "trap 'exit 0' INT TERM; while :; do sleep 0.02; done"
+
+func TestServerProcessRestartsAfterCrash(t *testing.T) {
+	proc := startTestServerProcess(t, "if [ -f crashed ]; then "+idleServerScript+"; fi; touch crashed; exit 3", 3)
+
+	pids := waitForLaunches(t, 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, err := proc.stateFile.read()
+		if err == nil && state != nil && strconv.Itoa(state.PID) == pids[1] && proc.current().pid() == state.PID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state file not updated to relaunched pid %s: %+v err=%v", pids[1], state, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := proc.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := len(launchedPIDs(t)); got != 2 {
+		t.Fatalf("launches after Close = %d, want 2", got)
+	}
+	if state, _ := proc.stateFile.read(); state != nil {
+		t.Fatalf("expected state file to be removed, got %+v", state)
+	}
+}
+
+func TestServerProcessGivesUpAfterMaxRestarts(t *testing.T) {
+	proc := startTestServerProcess(t, "exit 1", 2)
+
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor kept restarting past the limit")
+	}
+	if got := len(launchedPIDs(t)); got != 3 {
+		t.Fatalf("launches = %d, want 1 start and 2 restarts", got)
+	}
+}
+
+func TestServerProcessCloseDoesNotRespawn(t *testing.T) {
+	proc := startTestServerProcess(t, idleServerScript, 3)
+	waitForLaunches(t, 1)
+
+	if err := proc.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if err := proc.Close(); err != nil {
+		t.Fatalf("second Close() error: %v", err)
+	}
+	select {
+	case <-proc.done:
+	default:
+		t.Fatal("supervisor still running after Close")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := len(launchedPIDs(t)); got != 1 {
+		t.Fatalf("launches = %d, want 1", got)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: 2 * time.Second},
+		{attempt: 3, want: 8 * time.Second},
+		{attempt: 10, want: serverRestartBackoffMax},
+	}
+	for _, tt := range tests {
+		if got := restartBackoff(serverRestartBackoffBase, tt.attempt); got != tt.want {
+			t.Fatalf("restartBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const latencyWindowSize = 64
+
+type Stats struct {
+	InFlight     int
+	Queued       int
+	P95Latency   time.Duration
+	BreakerOpen  bool
+	BreakerState string
+}
+
+type StatsProvider interface {
+	Stats() Stats
+}
+
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+func (w *latencyWindow) p95() time.Duration {
+	w.mu.Lock()
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := (len(sorted)*95+99)/100 - 1
+	return sorted[index]
+}
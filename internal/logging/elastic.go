@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
@@ -12,23 +13,71 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	elasticLogChannelSize = 512
 	elasticRequestTimeout = 5 * time.Second
+	elasticRetryMaxDelay  = 30 * time.Second
 )
 
 var elasticDiagLogger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.LUTC)
 
 type ElasticLogger struct {
-	client   *http.Client
-	endpoint string
-	apiKey   string
-	queue    chan logEntry
-	stop     chan struct{}
-	wg       sync.WaitGroup
+	client  *http.Client
+	baseURL string
+	index   indexPattern
+	now     func() time.Time
+	apiKey  string
+	bulk    ElasticBulk
+	retry   ElasticRetry
+	queue   chan logEntry
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	// offline and currentIndex belong to the run goroutine.
+	offline      bool
+	currentIndex string
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+
+	spillMu      sync.Mutex
+	spillPending bool
+	dropped      atomic.Int64
+	spilled      atomic.Int64
+	replayed     atomic.Int64
+}
+
+// ElasticBulk batches documents into _bulk requests of up to MaxEntries,
+// sent at least every FlushInterval. Disabled, every line is its own POST.
+// DataStream uses create actions, which data streams require.
+type ElasticBulk struct {
+	Enabled       bool
+	MaxEntries    int
+	FlushInterval time.Duration
+	DataStream    bool
+}
+
+// ElasticRetry retries a failed send MaxRetries times, doubling Backoff
+// each time. Entries that still fail, or that find the queue full, are
+// appended to SpillFile and replayed once Elasticsearch accepts a send
+// again. Without a SpillFile they are dropped.
+type ElasticRetry struct {
+	MaxRetries int
+	Backoff    time.Duration
+	SpillFile  string
+}
+
+type ElasticStatus struct {
+	QueueDepth  int
+	LastError   string
+	LastErrorAt time.Time
+	Dropped     int64
+	Spilled     int64
+	Replayed    int64
 }
 
 type logEntry struct {
@@ -38,13 +87,25 @@ type logEntry struct {
 	Fields    map[string]interface{} `json:"fields,omitempty"`
 }
 
-func NewElasticLogger(url, index, apiKey string, verifyCert bool) (*ElasticLogger, error) {
+func NewElasticLogger(url, index, apiKey string, verifyCert bool, bulk ElasticBulk, retry ElasticRetry) (*ElasticLogger, error) {
 	url = strings.TrimSpace(url)
 	index = strings.Trim(strings.TrimSpace(index), "/")
 	if url == "" || index == "" {
 		return nil, errors.New("elastic url and index must be set")
 	}
-	endpoint := strings.TrimRight(url, "/") + "/" + index + "/_doc"
+	pattern, err := parseIndexPattern(index)
+	if err != nil {
+		return nil, err
+	}
+	if bulk.MaxEntries <= 0 {
+		bulk.MaxEntries = 1
+	}
+	if bulk.FlushInterval <= 0 {
+		bulk.FlushInterval = time.Second
+	}
+	if retry.Backoff <= 0 {
+		retry.Backoff = 500 * time.Millisecond
+	}
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	if !verifyCert {
 		if transport.TLSClientConfig == nil {
@@ -57,20 +118,35 @@ func NewElasticLogger(url, index, apiKey string, verifyCert bool) (*ElasticLogge
 			Timeout:   elasticRequestTimeout,
 			Transport: transport,
 		},
-		endpoint: endpoint,
-		apiKey:   strings.TrimSpace(apiKey),
-		queue:    make(chan logEntry, elasticLogChannelSize),
-		stop:     make(chan struct{}),
+		baseURL: strings.TrimRight(url, "/"),
+		index:   pattern,
+		now:     time.Now,
+		apiKey:  strings.TrimSpace(apiKey),
+		bulk:    bulk,
+		retry:   retry,
+		queue:   make(chan logEntry, elasticLogChannelSize),
+		stop:    make(chan struct{}),
+	}
+	logElasticInfo("elastic_logger_initialized url=%s index=%s verify_cert=%t api_key_set=%t bulk=%t bulk_size=%d bulk_flush_ms=%d data_stream=%t", logger.baseURL, index, verifyCert, strings.TrimSpace(apiKey) != "", bulk.Enabled, bulk.MaxEntries, bulk.FlushInterval.Milliseconds(), bulk.DataStream)
+	if info, err := os.Stat(retry.SpillFile); retry.SpillFile != "" && err == nil && info.Size() > 0 {
+		logger.spillPending = true
+		logElasticInfo("elastic_spill_found path=%s bytes=%d", retry.SpillFile, info.Size())
 	}
-	logElasticInfo("elastic_logger_initialized endpoint=%s verify_cert=%t api_key_set=%t", endpoint, verifyCert, strings.TrimSpace(apiKey) != "")
 	logger.wg.Add(1)
-	go logger.run()
+	if bulk.Enabled {
+		go logger.runBulk()
+	} else {
+		go logger.run()
+	}
 	return logger, nil
 }
 
+// Close sends what is still queued and replays the spill file once; entries
+// Elasticsearch does not take stay in the spill file for the next start.
 func (l *ElasticLogger) Close() error {
 	close(l.stop)
 	l.wg.Wait()
+	logElasticInfo("elastic_logger_closed dropped=%d spilled=%d replayed=%d", l.dropped.Load(), l.spilled.Load(), l.replayed.Load())
 	return nil
 }
 
@@ -78,21 +154,43 @@ func (l *ElasticLogger) Enqueue(entry logEntry) {
 	select {
 	case l.queue <- entry:
 	default:
+		l.spill([]logEntry{entry}, "queue_full")
+	}
+}
+
+func (l *ElasticLogger) Status() ElasticStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ElasticStatus{
+		QueueDepth:  len(l.queue),
+		LastError:   l.lastError,
+		LastErrorAt: l.lastErrorAt,
+		Dropped:     l.dropped.Load(),
+		Spilled:     l.spilled.Load(),
+		Replayed:    l.replayed.Load(),
 	}
 }
 
+func (l *ElasticLogger) recordError(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastError = message
+	l.lastErrorAt = time.Now()
+}
+
 func (l *ElasticLogger) run() {
 	defer l.wg.Done()
 	for {
 		select {
 		case entry := <-l.queue:
-			l.send(entry)
+			l.deliver([]logEntry{entry})
 		case <-l.stop:
 			for {
 				select {
 				case entry := <-l.queue:
-					l.send(entry)
+					l.deliver([]logEntry{entry})
 				default:
+					l.replaySpill()
 					return
 				}
 			}
@@ -100,44 +198,347 @@ func (l *ElasticLogger) run() {
 	}
 }
 
-func (l *ElasticLogger) send(entry logEntry) {
-	payload := map[string]interface{}{
-		"@timestamp":  entry.Timestamp.UTC().Format(time.RFC3339Nano),
-		"level":       entry.Level,
-		"logmessage":  entry.Message,
-		"transaction": entry.Fields["transaction_id"],
+// runBulk collects entries and flushes them when the batch is full, when
+// the flush interval passes and, for whatever is left, on Close.
+func (l *ElasticLogger) runBulk() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.bulk.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]logEntry, 0, l.bulk.MaxEntries)
+	add := func(entry logEntry) {
+		batch = append(batch, entry)
+		if len(batch) >= l.bulk.MaxEntries {
+			l.deliver(batch)
+			batch = batch[:0]
+		}
 	}
-	for key, value := range entry.Fields {
-		payload[key] = value
+	for {
+		select {
+		case entry := <-l.queue:
+			add(entry)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.deliver(batch)
+				batch = batch[:0]
+			}
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.queue:
+					add(entry)
+				default:
+					if len(batch) > 0 {
+						l.deliver(batch)
+					}
+					l.replaySpill()
+					return
+				}
+			}
+		}
 	}
-	body, err := json.Marshal(payload)
-	if err != nil {
+}
+
+// deliver sends entries, retrying a failed request with exponential backoff.
+// Entries that still fail are spilled; once a send succeeds the spill file
+// is replayed. While stopping, an offline Elasticsearch is not retried so
+// Close does not wait out the backoff.
+func (l *ElasticLogger) deliver(entries []logEntry) {
+	if l.offline && l.stopping() {
+		l.spill(entries, "offline")
 		return
 	}
-	logElasticInfo("elastic_send_attempt endpoint=%s payload_bytes=%d", l.endpoint, len(body))
-	req, err := http.NewRequest(http.MethodPost, l.endpoint, bytes.NewReader(body))
+	for attempt := 0; ; attempt++ {
+		err := l.sendOnce(entries)
+		if err == nil {
+			l.offline = false
+			l.replaySpill()
+			return
+		}
+		if attempt >= l.retry.MaxRetries || l.stopping() {
+			l.offline = true
+			l.spill(entries, "send_failed")
+			return
+		}
+		delay := l.retryDelay(attempt)
+		logElasticInfo("elastic_send_retry attempt=%d backoff_ms=%d error=%v", attempt+1, delay.Milliseconds(), err)
+		select {
+		case <-time.After(delay):
+		case <-l.stop:
+		}
+	}
+}
+
+func (l *ElasticLogger) retryDelay(attempt int) time.Duration {
+	delay := l.retry.Backoff
+	for i := 0; i < attempt && delay < elasticRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, elasticRetryMaxDelay)
+}
+
+func (l *ElasticLogger) stopping() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *ElasticLogger) sendOnce(entries []logEntry) error {
+	if l.bulk.Enabled {
+		return l.sendBulk(entries)
+	}
+	for _, entry := range entries {
+		if err := l.send(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *ElasticLogger) send(entry logEntry) error {
+	body, err := entry.document()
 	if err != nil {
-		return
+		return nil
+	}
+	endpoint := l.endpoint("_doc")
+	logElasticInfo("elastic_send_attempt endpoint=%s payload_bytes=%d", endpoint, len(body))
+	resp, err := l.post(endpoint, "application/json", body)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return nil
+}
+
+// sendBulk posts entries as one newline-delimited _bulk body and logs the
+// items Elasticsearch rejected.
+func (l *ElasticLogger) sendBulk(entries []logEntry) error {
+	action := `{"index":{}}` + "\n"
+	if l.bulk.DataStream {
+		action = `{"create":{}}` + "\n"
+	}
+	var body bytes.Buffer
+	for _, entry := range entries {
+		document, err := entry.document()
+		if err != nil {
+			continue
+		}
+		body.WriteString(action)
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	endpoint := l.endpoint("_bulk")
+	logElasticInfo("elastic_bulk_attempt endpoint=%s documents=%d payload_bytes=%d", endpoint, len(entries), body.Len())
+	resp, err := l.post(endpoint, "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logElasticInfo("elastic_bulk_response_invalid error=%v", err)
+		return nil
+	}
+	if !result.Errors {
+		return nil
+	}
+	failed := 0
+	var lastError string
+	for i, item := range result.Items {
+		for _, outcome := range item {
+			if outcome.Status < http.StatusOK || outcome.Status >= http.StatusMultipleChoices {
+				failed++
+				lastError = fmt.Sprintf("bulk item status %d %s: %s", outcome.Status, outcome.Error.Type, outcome.Error.Reason)
+				logElasticInfo("elastic_bulk_item_failed item=%d status=%d type=%s reason=%q", i, outcome.Status, outcome.Error.Type, outcome.Error.Reason)
+			}
+		}
+	}
+	logElasticInfo("elastic_bulk_partial_failure documents=%d failed=%d", len(entries), failed)
+	if lastError != "" {
+		l.recordError(lastError)
+	}
+	return nil
+}
+
+// endpoint resolves the index pattern for the request being sent, so a
+// batch after midnight UTC lands in the next day's index.
+func (l *ElasticLogger) endpoint(api string) string {
+	index := l.index.resolve(l.now())
+	if index != l.currentIndex {
+		if l.currentIndex != "" {
+			logElasticInfo("elastic_index_rollover from=%s to=%s", l.currentIndex, index)
+		}
+		l.currentIndex = index
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return l.baseURL + "/" + index + "/" + api
+}
+
+// post sends body and reports non-2xx answers and transport errors; on
+// success the caller owns the response body.
+func (l *ElasticLogger) post(endpoint, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
 	if l.apiKey != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("ApiKey %s", l.apiKey))
 	}
 	resp, err := l.client.Do(req)
 	if err != nil {
-		logElasticInfo("elastic_send_failed endpoint=%s error=%v", l.endpoint, err)
-		return
+		logElasticInfo("elastic_send_failed endpoint=%s error=%v", endpoint, err)
+		l.recordError(err.Error())
+		return nil, err
 	}
 	logElasticInfo("elastic_send_response status=%s", resp.Status)
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		bodyPreview, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
 		logElasticInfo("elastic_send_non_2xx status=%s body=%q", resp.Status, strings.TrimSpace(string(bodyPreview)))
+		l.recordError("status " + resp.Status)
 		_, _ = io.Copy(io.Discard, resp.Body)
 		_ = resp.Body.Close()
+		return nil, fmt.Errorf("elastic status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// spill appends entries to the spill file, or drops them when there is none
+// or it cannot be written.
+func (l *ElasticLogger) spill(entries []logEntry, reason string) {
+	if err := l.writeSpill(entries); err != nil {
+		total := l.dropped.Add(int64(len(entries)))
+		logElasticInfo("elastic_entries_dropped reason=%s entries=%d dropped_total=%d error=%v", reason, len(entries), total, err)
 		return
 	}
-	_, _ = io.Copy(io.Discard, resp.Body)
-	_ = resp.Body.Close()
+	total := l.spilled.Add(int64(len(entries)))
+	logElasticInfo("elastic_entries_spilled reason=%s entries=%d spilled_total=%d path=%s", reason, len(entries), total, l.retry.SpillFile)
+}
+
+func (l *ElasticLogger) writeSpill(entries []logEntry) error {
+	if l.retry.SpillFile == "" {
+		return errors.New("no spill file")
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	l.spillMu.Lock()
+	defer l.spillMu.Unlock()
+	file, err := os.OpenFile(l.retry.SpillFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	l.spillPending = true
+	return nil
+}
+
+// replaySpill takes the spill file over and sends it in batches. Entries
+// from the first failed batch on are written back for the next attempt.
+func (l *ElasticLogger) replaySpill() {
+	entries, err := l.takeSpill()
+	if err != nil {
+		logElasticInfo("elastic_spill_read_failed path=%s error=%v", l.retry.SpillFile, err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	logElasticInfo("elastic_spill_replay_started entries=%d path=%s", len(entries), l.retry.SpillFile)
+	size := 1
+	if l.bulk.Enabled {
+		size = l.bulk.MaxEntries
+	}
+	sent := 0
+	for sent < len(entries) {
+		batch := entries[sent:min(sent+size, len(entries))]
+		if err := l.sendOnce(batch); err != nil {
+			l.offline = true
+			if err := l.writeSpill(entries[sent:]); err != nil {
+				l.dropped.Add(int64(len(entries) - sent))
+				logElasticInfo("elastic_entries_dropped reason=replay_failed entries=%d error=%v", len(entries)-sent, err)
+			}
+			break
+		}
+		sent += len(batch)
+	}
+	total := l.replayed.Add(int64(sent))
+	logElasticInfo("elastic_spill_replayed entries=%d remaining=%d replayed_total=%d", sent, len(entries)-sent, total)
+}
+
+func (l *ElasticLogger) takeSpill() ([]logEntry, error) {
+	l.spillMu.Lock()
+	defer l.spillMu.Unlock()
+	if !l.spillPending {
+		return nil, nil
+	}
+	file, err := os.Open(l.retry.SpillFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			l.spillPending = false
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var entries []logEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(l.retry.SpillFile); err != nil {
+		return nil, err
+	}
+	l.spillPending = false
+	return entries, nil
+}
+
+func (e logEntry) document() ([]byte, error) {
+	payload := map[string]interface{}{
+		"@timestamp":  e.Timestamp.UTC().Format(time.RFC3339Nano),
+		"level":       e.Level,
+		"logmessage":  e.Message,
+		"transaction": e.Fields["transaction_id"],
+	}
+	for key, value := range e.Fields {
+		payload[key] = value
+	}
+	return json.Marshal(payload)
 }
 
 func logElasticInfo(format string, args ...any) {
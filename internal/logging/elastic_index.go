@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// indexPattern is an Elasticsearch index name with Logstash-style date
+// placeholders, e.g. minecraft-ai-%{+yyyy.MM.dd}, expanded in UTC.
+type indexPattern struct {
+	parts []indexPart
+}
+
+// indexPart is either literal text or a date token such as yyyy or MM.
+type indexPart struct {
+	literal string
+	token   string
+}
+
+var indexDateTokens = []string{"yyyy", "yy", "MM", "dd", "HH"}
+
+func parseIndexPattern(pattern string) (indexPattern, error) {
+	var parsed indexPattern
+	rest := pattern
+	for {
+		start := strings.Index(rest, "%{+")
+		if start < 0 {
+			parsed.addLiteral(rest)
+			return parsed, nil
+		}
+		parsed.addLiteral(rest[:start])
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return indexPattern{}, fmt.Errorf("elastic index %q: unterminated %%{+", pattern)
+		}
+		format := rest[start+3 : start+end]
+		if format == "" {
+			return indexPattern{}, fmt.Errorf("elastic index %q: empty date format", pattern)
+		}
+		for format != "" {
+			token := matchDateToken(format)
+			switch {
+			case token != "":
+				parsed.parts = append(parsed.parts, indexPart{token: token})
+				format = format[len(token):]
+			case isASCIILetter(format[0]):
+				return indexPattern{}, fmt.Errorf("elastic index %q: unsupported date token in %q", pattern, format)
+			default:
+				parsed.addLiteral(format[:1])
+				format = format[1:]
+			}
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+func matchDateToken(format string) string {
+	for _, token := range indexDateTokens {
+		if strings.HasPrefix(format, token) {
+			return token
+		}
+	}
+	return ""
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *indexPattern) addLiteral(text string) {
+	if text != "" {
+		p.parts = append(p.parts, indexPart{literal: text})
+	}
+}
+
+func (p indexPattern) resolve(now time.Time) string {
+	now = now.UTC()
+	var b strings.Builder
+	for _, part := range p.parts {
+		switch part.token {
+		case "yyyy":
+			fmt.Fprintf(&b, "%04d", now.Year())
+		case "yy":
+			fmt.Fprintf(&b, "%02d", now.Year()%100)
+		case "MM":
+			fmt.Fprintf(&b, "%02d", int(now.Month()))
+		case "dd":
+			fmt.Fprintf(&b, "%02d", now.Day())
+		case "HH":
+			fmt.Fprintf(&b, "%02d", now.Hour())
+		default:
+			b.WriteString(part.literal)
+		}
+	}
+	return b.String()
+}
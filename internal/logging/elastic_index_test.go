@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndexPatternResolve(t *testing.T) {
+	at := time.Date(2024, time.April, 5, 23, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "minecraft-chat-logs", want: "minecraft-chat-logs"},
+		{pattern: "minecraft-ai-%{+yyyy.MM.dd}", want: "minecraft-ai-2024.04.05"},
+		{pattern: "logs-%{+yy-MM}-app", want: "logs-24-04-app"},
+		{pattern: "logs-%{+yyyy.MM.dd.HH}", want: "logs-2024.04.05.21"},
+		{pattern: "%{+yyyy}-%{+MM}", want: "2024-04"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			pattern, err := parseIndexPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("parseIndexPattern() error: %v", err)
+			}
+			if got := pattern.resolve(at); got != tt.want {
+				t.Fatalf("resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexPatternRejectsInvalid(t *testing.T) {
+	for _, pattern := range []string{"logs-%{+yyyy.MM.dd", "logs-%{+}", "logs-%{+YYYY.ww}"} {
+		if _, err := parseIndexPattern(pattern); err == nil {
+			t.Fatalf("parseIndexPattern(%q) expected error", pattern)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type elasticRequest struct {
+	path        string
+	contentType string
+	lines       []string
+	rejected    bool
+}
+
+type fakeElastic struct {
+	mu       sync.Mutex
+	requests []elasticRequest
+	response string
+	down     atomic.Bool
+}
+
+func newFakeElastic(t *testing.T, response string) (*fakeElastic, *httptest.Server) {
+	t.Helper()
+	fake := &fakeElastic{response: response}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		down := fake.down.Load()
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, elasticRequest{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), lines: lines, rejected: down})
+		fake.mu.Unlock()
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(fake.response))
+	}))
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeElastic) snapshot() []elasticRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]elasticRequest(nil), f.requests...)
+}
+
+func enqueueMessages(logger *ElasticLogger, messages ...string) {
+	for _, message := range messages {
+		logger.Enqueue(logEntry{Timestamp: time.Now(), Level: "INFO", Message: message})
+	}
+}
+
+// bulkMessages checks the action/source pairs of a _bulk body and returns
+// the logged messages in order.
+func bulkMessages(t *testing.T, req elasticRequest) []string {
+	t.Helper()
+	if req.path != "/logs/_bulk" || req.contentType != "application/x-ndjson" {
+		t.Fatalf("request = %s %s, want /logs/_bulk application/x-ndjson", req.path, req.contentType)
+	}
+	if len(req.lines)%2 != 0 {
+		t.Fatalf("bulk body has %d lines, want action/source pairs", len(req.lines))
+	}
+	var messages []string
+	for i := 0; i < len(req.lines); i += 2 {
+		if req.lines[i] != `{"index":{}}` {
+			t.Fatalf("action line = %q", req.lines[i])
+		}
+		var doc map[string]any
+		if err := json.Unmarshal([]byte(req.lines[i+1]), &doc); err != nil {
+			t.Fatalf("source line %q: %v", req.lines[i+1], err)
+		}
+		messages = append(messages, doc["logmessage"].(string))
+	}
+	return messages
+}
+
+func TestElasticLoggerBulk(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		flush     time.Duration
+		messages  []string
+		wait      int
+		wantBulks [][]string
+	}{
+		{name: "flushes full batches then remainder on close", size: 2, flush: time.Hour, messages: []string{"a", "b", "c"}, wait: 1, wantBulks: [][]string{{"a", "b"}, {"c"}}},
+		{name: "flushes on interval", size: 100, flush: 10 * time.Millisecond, messages: []string{"a"}, wait: 1, wantBulks: [][]string{{"a"}}},
+		{name: "flushes remainder on close", size: 100, flush: time.Hour, messages: []string{"a"}, wantBulks: [][]string{{"a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeElastic(t, `{"errors":false,"items":[]}`)
+			logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{Enabled: true, MaxEntries: tt.size, FlushInterval: tt.flush}, ElasticRetry{})
+			if err != nil {
+				t.Fatalf("NewElasticLogger() error: %v", err)
+			}
+			enqueueMessages(logger, tt.messages...)
+
+			deadline := time.Now().Add(2 * time.Second)
+			for len(fake.snapshot()) < tt.wait {
+				if time.Now().After(deadline) {
+					t.Fatalf("got %d bulk requests before close, want %d", len(fake.snapshot()), tt.wait)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+
+			requests := fake.snapshot()
+			if len(requests) != len(tt.wantBulks) {
+				t.Fatalf("got %d bulk requests, want %d", len(requests), len(tt.wantBulks))
+			}
+			for i, want := range tt.wantBulks {
+				if got := bulkMessages(t, requests[i]); strings.Join(got, ",") != strings.Join(want, ",") {
+					t.Fatalf("bulk %d messages = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestElasticLoggerBulkPartialFailure(t *testing.T) {
+	_, server := newFakeElastic(t, `{"errors":true,"items":[
+		{"index":{"status":201}},
+		{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [level]"}}}
+	]}`)
+	logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{Enabled: true, MaxEntries: 100, FlushInterval: time.Hour}, ElasticRetry{})
+	if err != nil {
+		t.Fatalf("NewElasticLogger() error: %v", err)
+	}
+	enqueueMessages(logger, "ok", "rejected")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	status := logger.Status()
+	if !strings.Contains(status.LastError, "mapper_parsing_exception") || status.LastErrorAt.IsZero() {
+		t.Fatalf("status = %+v, want the rejected item recorded", status)
+	}
+}
+
+func TestElasticLoggerSingleDocuments(t *testing.T) {
+	fake, server := newFakeElastic(t, `{"result":"created"}`)
+	logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{}, ElasticRetry{})
+	if err != nil {
+		t.Fatalf("NewElasticLogger() error: %v", err)
+	}
+	enqueueMessages(logger, "a", "b")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	requests := fake.snapshot()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want one per entry", len(requests))
+	}
+	for _, req := range requests {
+		if req.path != "/logs/_doc" || req.contentType != "application/json" || len(req.lines) != 1 {
+			t.Fatalf("request = %+v, want a single JSON document to /logs/_doc", req)
+		}
+	}
+	if status := logger.Status(); status.LastError != "" {
+		t.Fatalf("unexpected error: %+v", status)
+	}
+}
+
+// indexedMessages returns the messages of accepted single-document requests.
+func (f *fakeElastic) indexedMessages(t *testing.T) []string {
+	t.Helper()
+	var messages []string
+	for _, req := range f.snapshot() {
+		if req.rejected {
+			continue
+		}
+		var doc map[string]any
+		if err := json.Unmarshal([]byte(req.lines[0]), &doc); err != nil {
+			t.Fatalf("document %q: %v", req.lines[0], err)
+		}
+		messages = append(messages, doc["logmessage"].(string))
+	}
+	return messages
+}
+
+func waitForStatus(t *testing.T, logger *ElasticLogger, what string, done func(ElasticStatus) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done(logger.Status()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, logger.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElasticLoggerSpillsWhileDownAndReplays(t *testing.T) {
+	fake, server := newFakeElastic(t, `{"result":"created"}`)
+	fake.down.Store(true)
+	spillFile := filepath.Join(t.TempDir(), "spill.ndjson")
+	logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{}, ElasticRetry{MaxRetries: 2, Backoff: time.Millisecond, SpillFile: spillFile})
+	if err != nil {
+		t.Fatalf("NewElasticLogger() error: %v", err)
+	}
+
+	enqueueMessages(logger, "a", "b")
+	waitForStatus(t, logger, "spill", func(s ElasticStatus) bool { return s.Spilled == 2 })
+	if got := len(fake.snapshot()); got != 6 {
+		t.Fatalf("requests while down = %d, want 3 attempts per entry", got)
+	}
+	data, err := os.ReadFile(spillFile)
+	if err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("spill file = %q err=%v, want 2 entries", data, err)
+	}
+
+	fake.down.Store(false)
+	enqueueMessages(logger, "c")
+	waitForStatus(t, logger, "replay", func(s ElasticStatus) bool { return s.Replayed == 2 })
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	if got := strings.Join(fake.indexedMessages(t), ","); got != "c,a,b" {
+		t.Fatalf("indexed = %s, want c,a,b", got)
+	}
+	if _, err := os.Stat(spillFile); !os.IsNotExist(err) {
+		t.Fatalf("spill file still present after replay: %v", err)
+	}
+	if status := logger.Status(); status.Dropped != 0 {
+		t.Fatalf("dropped = %d, want 0", status.Dropped)
+	}
+}
+
+func TestElasticLoggerCloseReplaysSpillFile(t *testing.T) {
+	fake, server := newFakeElastic(t, `{"errors":false,"items":[]}`)
+	spillFile := filepath.Join(t.TempDir(), "spill.ndjson")
+	spill := `{"@timestamp":"2024-04-05T10:00:00Z","level":"INFO","logmessage":"old-1"}` + "\n" +
+		`{"@timestamp":"2024-04-05T10:00:01Z","level":"WARN","logmessage":"old-2"}` + "\n"
+	if err := os.WriteFile(spillFile, []byte(spill), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{Enabled: true, MaxEntries: 10, FlushInterval: time.Hour}, ElasticRetry{SpillFile: spillFile})
+	if err != nil {
+		t.Fatalf("NewElasticLogger() error: %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	requests := fake.snapshot()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want one bulk replay", len(requests))
+	}
+	if got := strings.Join(bulkMessages(t, requests[0]), ","); got != "old-1,old-2" {
+		t.Fatalf("replayed = %s", got)
+	}
+	if _, err := os.Stat(spillFile); !os.IsNotExist(err) {
+		t.Fatalf("spill file still present after Close: %v", err)
+	}
+}
+
+func TestElasticLoggerCloseKeepsSpillWhileDown(t *testing.T) {
+	fake, server := newFakeElastic(t, `{"result":"created"}`)
+	fake.down.Store(true)
+	spillFile := filepath.Join(t.TempDir(), "spill.ndjson")
+	logger, err := NewElasticLogger(server.URL, "logs", "", true, ElasticBulk{}, ElasticRetry{MaxRetries: 5, Backoff: time.Hour, SpillFile: spillFile})
+	if err != nil {
+		t.Fatalf("NewElasticLogger() error: %v", err)
+	}
+	enqueueMessages(logger, "a", "b")
+
+	closed := make(chan struct{})
+	go func() {
+		_ = logger.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close waited out the retry backoff")
+	}
+	if status := logger.Status(); status.Spilled != 2 || status.Dropped != 0 {
+		t.Fatalf("status = %+v, want both entries spilled", status)
+	}
+	data, err := os.ReadFile(spillFile)
+	if err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("spill file = %q err=%v, want 2 entries kept for the next start", data, err)
+	}
+}
+
+func TestElasticLoggerQueueFull(t *testing.T) {
+	tests := []struct {
+		name        string
+		spillFile   bool
+		wantSpilled int64
+		wantDropped int64
+	}{
+		{name: "spills to disk", spillFile: true, wantSpilled: 1},
+		{name: "drops without spill file", wantDropped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &ElasticLogger{queue: make(chan logEntry, 1)}
+			if tt.spillFile {
+				logger.retry.SpillFile = filepath.Join(t.TempDir(), "spill.ndjson")
+			}
+			enqueueMessages(logger, "queued", "overflow")
+
+			status := logger.Status()
+			if status.QueueDepth != 1 || status.Spilled != tt.wantSpilled || status.Dropped != tt.wantDropped {
+				t.Fatalf("status = %+v, want spilled=%d dropped=%d", status, tt.wantSpilled, tt.wantDropped)
+			}
+			if tt.spillFile {
+				entries, err := logger.takeSpill()
+				if err != nil || len(entries) != 1 || entries[0].Message != "overflow" {
+					t.Fatalf("spill = %+v err=%v, want the overflow entry", entries, err)
+				}
+			}
+		})
+	}
+}
+
+func TestElasticLoggerIndexRollover(t *testing.T) {
+	tests := []struct {
+		name       string
+		dataStream bool
+		wantAction string
+	}{
+		{name: "index", wantAction: `{"index":{}}`},
+		{name: "data stream", dataStream: true, wantAction: `{"create":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeElastic(t, `{"errors":false,"items":[]}`)
+			logger, err := NewElasticLogger(server.URL, "minecraft-ai-%{+yyyy.MM.dd}", "", true, ElasticBulk{Enabled: true, MaxEntries: 1, FlushInterval: time.Hour, DataStream: tt.dataStream}, ElasticRetry{})
+			if err != nil {
+				t.Fatalf("NewElasticLogger() error: %v", err)
+			}
+			// The run goroutine reads the clock only after receiving an entry.
+			var now atomic.Int64
+			now.Store(time.Date(2024, time.April, 5, 23, 59, 59, 0, time.UTC).UnixNano())
+			logger.now = func() time.Time { return time.Unix(0, now.Load()) }
+
+			enqueueMessages(logger, "before midnight")
+			waitForRequests(t, fake, 1)
+			now.Store(time.Date(2024, time.April, 6, 0, 0, 1, 0, time.UTC).UnixNano())
+			enqueueMessages(logger, "after midnight")
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+
+			requests := fake.snapshot()
+			wantPaths := []string{"/minecraft-ai-2024.04.05/_bulk", "/minecraft-ai-2024.04.06/_bulk"}
+			if len(requests) != len(wantPaths) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(wantPaths))
+			}
+			for i, req := range requests {
+				if req.path != wantPaths[i] || req.lines[0] != tt.wantAction {
+					t.Fatalf("request %d = %s %s, want %s %s", i, req.path, req.lines[0], wantPaths[i], tt.wantAction)
+				}
+			}
+		})
+	}
+}
+
+func waitForRequests(t *testing.T, fake *fakeElastic, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(fake.snapshot()) < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d requests, want %d", len(fake.snapshot()), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
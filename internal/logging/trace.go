@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentHeader carries W3C trace context between services.
+const TraceparentHeader = "traceparent"
+
+type traceKey struct{}
+
+// Trace identifies this service's span within a W3C trace. ParentID is the
+// caller's span and is empty when the trace started here.
+type Trace struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Flags    string
+}
+
+// ParseTraceparent reads a version 00 traceparent header. All-zero IDs and
+// malformed values are rejected, as the W3C spec requires.
+func ParseTraceparent(header string) (Trace, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return Trace{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return Trace{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(parts[0], 2) || !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return Trace{}, false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return Trace{}, false
+	}
+	return Trace{TraceID: traceID, ParentID: spanID, Flags: flags}, true
+}
+
+// StartTrace continues the trace in header with a new span, or starts a new
+// sampled trace when the header is missing or invalid.
+func StartTrace(header string) Trace {
+	trace, ok := ParseTraceparent(header)
+	if !ok {
+		trace = Trace{TraceID: randomHex(16), Flags: "01"}
+	}
+	trace.SpanID = randomHex(8)
+	return trace
+}
+
+// Traceparent renders the header that makes this span the parent of the
+// next hop.
+func (t Trace) Traceparent() string {
+	if t.TraceID == "" {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%s", t.TraceID, t.SpanID, t.Flags)
+}
+
+func WithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+func TraceFromContext(ctx context.Context) Trace {
+	trace, _ := ctx.Value(traceKey{}).(Trace)
+	return trace
+}
+
+// ContextLogger appends the trace and span IDs of a request to each line.
+type ContextLogger struct {
+	suffix string
+}
+
+// Ctx returns a logger for lines emitted on behalf of ctx's request.
+func Ctx(ctx context.Context) ContextLogger {
+	trace := TraceFromContext(ctx)
+	if trace.TraceID == "" {
+		return ContextLogger{}
+	}
+	return ContextLogger{suffix: " trace_id=" + trace.TraceID + " span_id=" + trace.SpanID}
+}
+
+func (l ContextLogger) Debugf(format string, args ...any) {
+	logf(LevelDebug, format+l.suffix, args...)
+}
+
+func (l ContextLogger) Infof(format string, args ...any) {
+	logf(LevelInfo, format+l.suffix, args...)
+}
+
+func (l ContextLogger) Warnf(format string, args ...any) {
+	logf(LevelWarning, format+l.suffix, args...)
+}
+
+func (l ContextLogger) Errorf(format string, args ...any) {
+	logf(LevelError, format+l.suffix, args...)
+}
+
+func (l ContextLogger) Exceptionf(format string, args ...any) {
+	logf(LevelException, format+l.suffix, args...)
+}
+
+func isLowerHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil || strings.Trim(hex.EncodeToString(buf), "0") == "" {
+		buf[size-1] = 1
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var traceparentPattern = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   Trace
+		ok     bool
+	}{
+		{name: "valid", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "01"}, ok: true},
+		{name: "not sampled", header: " 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", want: Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "00"}, ok: true},
+		{name: "future version with extra field", header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", want: Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "01"}, ok: true},
+		{name: "empty", header: ""},
+		{name: "uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "short trace id", header: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{name: "invalid version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "version 00 extra field", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTraceparent(tt.header)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("ParseTraceparent(%q) = %+v %t, want %+v %t", tt.header, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStartTrace(t *testing.T) {
+	continued := StartTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if continued.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || continued.ParentID != "00f067aa0ba902b7" || continued.Flags != "00" {
+		t.Fatalf("continued trace = %+v", continued)
+	}
+	if continued.SpanID == continued.ParentID || !traceparentPattern.MatchString(continued.Traceparent()) {
+		t.Fatalf("continued trace needs its own span: %+v", continued)
+	}
+
+	started := StartTrace("garbage")
+	if started.ParentID != "" || started.Flags != "01" || !traceparentPattern.MatchString(started.Traceparent()) {
+		t.Fatalf("started trace = %+v (%s)", started, started.Traceparent())
+	}
+	if other := StartTrace(""); other.TraceID == started.TraceID {
+		t.Fatalf("new traces share id %s", other.TraceID)
+	}
+	if (Trace{}).Traceparent() != "" {
+		t.Fatal("empty trace should render no header")
+	}
+}
+
+func TestContextLoggerAddsTraceFields(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	trace := Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: "01"}
+	Ctx(WithTrace(context.Background(), trace)).Infof("plan_done request_id=%s", "req-1")
+	Ctx(context.Background()).Infof("plan_done request_id=%s", "req-2")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines: %s", len(lines), logs.String())
+	}
+	if !strings.HasSuffix(lines[0], "[INFO] plan_done request_id=req-1 trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7") {
+		t.Fatalf("traced line = %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "[INFO] plan_done request_id=req-2") {
+		t.Fatalf("untraced line = %s", lines[1])
+	}
+}
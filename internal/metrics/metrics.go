@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	PlanRequests       = newCounter("aichat_plan_requests_total", "Plan requests handled by the planner.")
+	ActionsEmitted     = newCounter("aichat_actions_emitted_total", "Planned chat actions returned to clients.")
+	LLMAttempts        = newCounter("aichat_llm_attempts_total", "LLM generation attempts.")
+	LLMSuccesses       = newCounter("aichat_llm_successes_total", "LLM generations that returned a usable message.")
+	LLMTimeouts        = newCounter("aichat_llm_timeouts_total", "LLM generations that timed out.")
+	LLMCacheHits       = newCounter("aichat_llm_cache_hits_total", "LLM responses served from the prompt cache.")
+	LLMCacheMisses     = newCounter("aichat_llm_cache_misses_total", "LLM prompt cache lookups that required a generation.")
+	HeuristicFallbacks = newCounter("aichat_heuristic_fallbacks_total", "Messages generated by heuristics after an LLM attempt.")
+	SilenceDecisions   = newCounterVec("aichat_silence_decisions_total", "Plans that intentionally returned no actions.", "reason")
+	PlanRateLimited    = newCounter("aichat_plan_rate_limited_total", "Plan requests rejected by the per-server rate limiter.")
+	SchemaVersions     = newCounterVec("aichat_schema_versions_total", "Plan and engagement requests by wire format version.", "version")
+	HTTPRequests       = newCounterVec("aichat_http_requests_total", "HTTP requests by endpoint and status code.", "path", "status")
+	HTTPLatency        = newHistogramVec("aichat_http_request_duration_seconds", "HTTP request latency by endpoint.", defaultLatencyBuckets, "path")
+)
+
+var registry = []collector{
+	PlanRequests,
+	ActionsEmitted,
+	LLMAttempts,
+	LLMSuccesses,
+	LLMTimeouts,
+	LLMCacheHits,
+	LLMCacheMisses,
+	HeuristicFallbacks,
+	SilenceDecisions,
+	PlanRateLimited,
+	SchemaVersions,
+	HTTPRequests,
+	HTTPLatency,
+}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+func newCounter(name, help string) *Counter {
+	return &Counter{name: name, help: help}
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n int) {
+	if n <= 0 {
+		return
+	}
+	c.value.Add(int64(n))
+}
+
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*atomic.Int64
+}
+
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*atomic.Int64)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.counter(labelValues).Add(1)
+}
+
+func (c *CounterVec) Value(labelValues ...string) int64 {
+	return c.counter(labelValues).Load()
+}
+
+func (c *CounterVec) counter(labelValues []string) *atomic.Int64 {
+	key := formatLabels(c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		value = &atomic.Int64{}
+		c.values[key] = value
+	}
+	return value
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	keys := sortedKeys(c.values)
+	snapshot := make([]int64, len(keys))
+	for i, key := range keys {
+		snapshot[i] = c.values[key].Load()
+	}
+	c.mu.Unlock()
+	for i, key := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", c.name, key, snapshot[i])
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			entry.counts[i]++
+		}
+	}
+	entry.sum += value
+	entry.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, key, formatFloat(bound), entry.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, key, entry.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, key, formatFloat(entry.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, key, entry.count)
+	}
+}
+
+func ObserveHTTPRequest(path string, status int, duration time.Duration) {
+	HTTPRequests.Inc(path, strconv.Itoa(status))
+	HTTPLatency.Observe(duration.Seconds(), path)
+}
+
+func WritePrometheus(w io.Writer) {
+	for _, c := range registry {
+		c.write(w)
+	}
+}
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	WritePrometheus(w)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func formatLabels(names, values []string) string {
+	parts := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(value)))
+	}
+	return strings.Join(parts, ",")
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
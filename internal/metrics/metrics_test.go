@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheusFormat(t *testing.T) {
+	PlanRequests.Inc()
+	SilenceDecisions.Inc("toxic")
+	ObserveHTTPRequest("/v1/plan", 200, 30*time.Millisecond)
+
+	var buf bytes.Buffer
+	WritePrometheus(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE aichat_plan_requests_total counter\n",
+		"aichat_plan_requests_total 1\n",
+		"aichat_silence_decisions_total{reason=\"toxic\"} 1\n",
+		"aichat_http_requests_total{path=\"/v1/plan\",status=\"200\"} 1\n",
+		"# TYPE aichat_http_request_duration_seconds histogram\n",
+		"aichat_http_request_duration_seconds_bucket{path=\"/v1/plan\",le=\"0.025\"} 0\n",
+		"aichat_http_request_duration_seconds_bucket{path=\"/v1/plan\",le=\"0.05\"} 1\n",
+		"aichat_http_request_duration_seconds_bucket{path=\"/v1/plan\",le=\"+Inf\"} 1\n",
+		"aichat_http_request_duration_seconds_count{path=\"/v1/plan\"} 1\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Fatalf("expected %q in output:\n%s", line, output)
+		}
+	}
+}
+
+func TestFormatLabelsEscapesValues(t *testing.T) {
+	got := formatLabels([]string{"path"}, []string{"a\"b\\c\n"})
+	want := `path="a\"b\\c\n"`
+	if got != want {
+		t.Fatalf("formatLabels() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Request types accept kebab-case keys ("server-id") as well as the
+// canonical snake_case ones; responses are always snake_case. A snake_case
+// key wins over its kebab-case twin and unknown keys are rejected, also
+// when the outer decoder does not disallow them.
+
+// snakeCaseKeys decodes a JSON object and renames its kebab-case keys.
+// Only the object's own keys change, so map values such as
+// settings.topic_cooldowns keep theirs. A JSON null yields a nil map.
+func snakeCaseKeys(data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		if !strings.Contains(key, "-") {
+			continue
+		}
+		delete(fields, key)
+		snake := strings.ReplaceAll(key, "-", "_")
+		if _, ok := fields[snake]; !ok {
+			fields[snake] = value
+		}
+	}
+	return fields, nil
+}
+
+// decodeFields decodes fields into v, rejecting keys v has no field for.
+func decodeFields(fields map[string]json.RawMessage, v any) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// decodeDualCase decodes data into v, which must not implement
+// json.Unmarshaler itself; callers pass a pointer to a plain alias type.
+func decodeDualCase(data []byte, v any) error {
+	fields, err := snakeCaseKeys(data)
+	if err != nil || fields == nil {
+		return err
+	}
+	return decodeFields(fields, v)
+}
+
+func (c *ServerContext) UnmarshalJSON(data []byte) error {
+	type plain ServerContext
+	return decodeDualCase(data, (*plain)(c))
+}
+
+func (p *Persona) UnmarshalJSON(data []byte) error {
+	type plain Persona
+	return decodeDualCase(data, (*plain)(p))
+}
+
+func (b *BotProfile) UnmarshalJSON(data []byte) error {
+	type plain BotProfile
+	return decodeDualCase(data, (*plain)(b))
+}
+
+func (o *LLMOverrides) UnmarshalJSON(data []byte) error {
+	type plain LLMOverrides
+	return decodeDualCase(data, (*plain)(o))
+}
+
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	type plain ChatMessage
+	return decodeDualCase(data, (*plain)(m))
+}
+
+func (s *PlanSettings) UnmarshalJSON(data []byte) error {
+	type plain PlanSettings
+	return decodeDualCase(data, (*plain)(s))
+}
+
+func (s *LLMSettings) UnmarshalJSON(data []byte) error {
+	type plain LLMSettings
+	return decodeDualCase(data, (*plain)(s))
+}
+
+func (r *PlanRequest) UnmarshalJSON(data []byte) error {
+	type plain PlanRequest
+	return decodeDualCase(data, (*plain)(r))
+}
+
+// UnmarshalJSON takes callback_url itself: a plain alias would still
+// promote PlanRequest.UnmarshalJSON, which rejects it.
+func (r *AsyncPlanRequest) UnmarshalJSON(data []byte) error {
+	fields, err := snakeCaseKeys(data)
+	if err != nil || fields == nil {
+		return err
+	}
+	if raw, ok := fields["callback_url"]; ok {
+		if err := json.Unmarshal(raw, &r.CallbackURL); err != nil {
+			return err
+		}
+		delete(fields, "callback_url")
+	}
+	return decodeFields(fields, &r.PlanRequest)
+}
+
+func (r *EngagementRequest) UnmarshalJSON(data []byte) error {
+	type plain EngagementRequest
+	return decodeDualCase(data, (*plain)(r))
+}
+
+func (r *BotRegisterRequest) UnmarshalJSON(data []byte) error {
+	type plain BotRegisterRequest
+	return decodeDualCase(data, (*plain)(r))
+}
+
+func (r *ActionCheckRequest) UnmarshalJSON(data []byte) error {
+	type plain ActionCheckRequest
+	return decodeDualCase(data, (*plain)(r))
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type ServerContext struct {
 	ServerID      string `json:"server_id"`
 	Mode          string `json:"mode"`
@@ -7,19 +9,46 @@ type ServerContext struct {
 }
 
 type Persona struct {
-	Language       string   `json:"language"`
-	Tone           string   `json:"tone"`
-	StyleTags      []string `json:"style_tags"`
-	AvoidTopics    []string `json:"avoid_topics"`
+	Language    string   `json:"language"`
+	Tone        string   `json:"tone"`
+	StyleTags   []string `json:"style_tags"`
+	AvoidTopics []string `json:"avoid_topics"`
+	// Interests are topics the bot likes to join; see
+	// PlanSettings.UninterestedWeight.
+	Interests []string `json:"interests,omitempty"`
+	// Catchphrases are sometimes added to the bot's messages; see
+	// PlanSettings.CatchphraseChance.
+	Catchphrases []string `json:"catchphrases,omitempty"`
+	// Quirks change how every message of the bot is written:
+	// lowercase_only, no_punctuation and occasional_typos.
+	Quirks         []string `json:"quirks,omitempty"`
 	KnowledgeLevel string   `json:"knowledge_level"`
 }
 
 type BotProfile struct {
-	BotID      string  `json:"bot_id"`
-	Name       string  `json:"name"`
-	Online     bool    `json:"online"`
+	BotID string `json:"bot_id"`
+	Name  string `json:"name"`
+	// Online is nil when the request omits the flag; see IsOnline.
+	Online     *bool   `json:"online,omitempty"`
 	CooldownMS int64   `json:"cooldown_ms"`
 	Persona    Persona `json:"persona"`
+	// LLMOverrides replaces the configured sampling settings for this bot.
+	LLMOverrides *LLMOverrides `json:"llm_overrides,omitempty"`
+}
+
+// IsOnline treats a bot without an online flag as online; only an explicit
+// false takes it offline.
+func (b BotProfile) IsOnline() bool {
+	return b.Online == nil || *b.Online
+}
+
+// LLMOverrides are per-bot generation settings; unset fields keep the
+// LLM_* defaults. Model only applies to server backends.
+type LLMOverrides struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Model       string   `json:"model,omitempty"`
 }
 
 type ChatMessage struct {
@@ -29,22 +58,139 @@ type ChatMessage struct {
 	Message     string `json:"message"`
 }
 
+const (
+	SelectionRandom      = "random"
+	SelectionLeastRecent = "least_recent"
+	SelectionWeighted    = "weighted"
+)
+
+var SelectionStrategies = []string{SelectionRandom, SelectionLeastRecent, SelectionWeighted}
+
+const (
+	QuirkLowercaseOnly   = "lowercase_only"
+	QuirkNoPunctuation   = "no_punctuation"
+	QuirkOccasionalTypos = "occasional_typos"
+)
+
+var Quirks = []string{QuirkLowercaseOnly, QuirkNoPunctuation, QuirkOccasionalTypos}
+
 type PlanSettings struct {
 	MaxActions          int     `json:"max_actions"`
 	MinDelayMS          int64   `json:"min_delay_ms"`
 	MaxDelayMS          int64   `json:"max_delay_ms"`
 	GlobalSilenceChance float64 `json:"global_silence_chance"`
 	ReplyChance         float64 `json:"reply_chance"`
+	AllowWhispers       bool    `json:"allow_whispers,omitempty"`
+	BanterChance        float64 `json:"banter_chance,omitempty"`
+	// SelectionStrategy is one of SelectionStrategies; empty means random.
+	SelectionStrategy string `json:"selection_strategy,omitempty"`
+	// TopicCooldownMS is nil when the request does not override the default.
+	TopicCooldownMS *int64           `json:"topic_cooldown_ms,omitempty"`
+	TopicCooldowns  map[string]int64 `json:"topic_cooldowns,omitempty"`
+	// Quiet forces (true) or lifts (false) the configured quiet hours.
+	Quiet *bool `json:"quiet,omitempty"`
+	// MaxMessageAgeMS marks the chat as stale when its newest message is
+	// older than this (plus CHAT_CLOCK_SKEW_MS); 0 disables the check.
+	MaxMessageAgeMS int64 `json:"max_message_age_ms,omitempty"`
+	// PlanDeadlineMS caps how long the plan may spend generating replies;
+	// 0 uses PLAN_DEADLINE_MS.
+	PlanDeadlineMS int64 `json:"plan_deadline_ms,omitempty"`
+	// UninterestedWeight scales the selection chance of bots whose persona
+	// interests do not match the topic, when another bot's do; 0 uses 0.3.
+	UninterestedWeight float64 `json:"uninterested_weight,omitempty"`
+	// CatchphraseChance is how often a message gets one of the bot's
+	// persona catchphrases; nil uses 0.2 and 0 turns them off.
+	CatchphraseChance *float64 `json:"catchphrase_chance,omitempty"`
+	// MaxBotMessagesPerMinute caps the actions of each bot across plans in
+	// a rolling minute; 0 means no cap.
+	MaxBotMessagesPerMinute int `json:"max_bot_messages_per_minute,omitempty"`
+	// LLM overrides the configured generation settings for this request.
+	LLM *LLMSettings `json:"llm,omitempty"`
+}
+
+// LLMSettings tune generation for one request; unset fields keep the
+// LLM_* configuration.
+type LLMSettings struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        int      `json:"max_tokens,omitempty"`
+	ChatHistoryLimit int      `json:"chat_history_limit,omitempty"`
 }
 
 type PlanRequest struct {
-	RequestID string        `json:"request_id"`
-	Server    ServerContext `json:"server"`
-	Tick      int64         `json:"tick"`
-	TimeMS    int64         `json:"time_ms"`
-	Bots      []BotProfile  `json:"bots"`
-	Chat      []ChatMessage `json:"chat"`
-	Settings  PlanSettings  `json:"settings"`
+	RequestID              string        `json:"request_id"`
+	Server                 ServerContext `json:"server"`
+	Tick                   int64         `json:"tick"`
+	TimeMS                 int64         `json:"time_ms"`
+	Bots                   []BotProfile  `json:"bots"`
+	Chat                   []ChatMessage `json:"chat"`
+	Settings               PlanSettings  `json:"settings"`
+	RequiredBotIDs         []string      `json:"required_bot_ids,omitempty"`
+	RequiredBypassCooldown bool          `json:"required_bypass_cooldown,omitempty"`
+	// DryRun plans without calling the LLM or touching planner memory;
+	// messages are placeholders and reasons get a dry_run_ prefix.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Explain fills debug.decisions with the steps behind the plan.
+	Explain bool `json:"explain,omitempty"`
+	// SchemaVersion is the wire format the client speaks; omitted means 1.
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+// AsyncPlanRequest is a PlanRequest whose response is delivered to
+// CallbackURL (when set) and kept for polling.
+type AsyncPlanRequest struct {
+	PlanRequest
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+type TopicsReloadResponse struct {
+	Status string         `json:"status"`
+	Topics map[string]int `json:"topics"`
+}
+
+type PayloadTooLargeResponse struct {
+	Error      string `json:"error"`
+	LimitBytes int64  `json:"limit_bytes"`
+}
+
+// ConfigReloadResponse lists changed settings as Section.Field names.
+type ConfigReloadResponse struct {
+	Status         string   `json:"status"`
+	Applied        []string `json:"applied"`
+	PendingRestart []string `json:"pending_restart"`
+}
+
+// ConfigResponse is the effective configuration by Section and Field, with
+// secrets masked, and where each Section.Field value came from.
+type ConfigResponse struct {
+	Config  map[string]map[string]any `json:"config"`
+	Sources map[string]string         `json:"sources"`
+}
+
+type BatchPlanRequest struct {
+	Requests []json.RawMessage `json:"requests"`
+}
+
+// BatchPlanEntry is either a plan response or, for a sub-request that could
+// not be planned, an error with optional details.
+type BatchPlanEntry struct {
+	*PlanResponse
+	Error        string                `json:"error,omitempty"`
+	Details      []ValidationViolation `json:"details,omitempty"`
+	RetryAfterMS int64                 `json:"retry_after_ms,omitempty"`
+	// MaxSchemaVersion accompanies an unsupported_schema_version error.
+	MaxSchemaVersion int `json:"max_schema_version,omitempty"`
+}
+
+type BatchPlanResponse struct {
+	Responses []BatchPlanEntry `json:"responses"`
+}
+
+type AsyncPlanStatus struct {
+	PlanID         string        `json:"plan_id"`
+	Status         string        `json:"status"`
+	CallbackStatus string        `json:"callback_status,omitempty"`
+	Response       *PlanResponse `json:"response,omitempty"`
 }
 
 type EngagementRequest struct {
@@ -57,36 +203,220 @@ type EngagementRequest struct {
 	Settings      PlanSettings  `json:"settings"`
 	TargetPlayer  string        `json:"target_player"`
 	ExamplePrompt string        `json:"example_prompt"`
+	SchemaVersion int           `json:"schema_version,omitempty"`
 }
 
 type PlannedAction struct {
-	BotID       string `json:"bot_id"`
-	SendAfterMS int64  `json:"send_after_ms"`
-	Message     string `json:"message"`
-	Visibility  string `json:"visibility"`
-	Reason      string `json:"reason"`
+	BotID        string `json:"bot_id"`
+	SendAfterMS  int64  `json:"send_after_ms"`
+	Message      string `json:"message"`
+	Visibility   string `json:"visibility"`
+	Reason       Reason `json:"reason"`
+	TargetPlayer string `json:"target_player,omitempty"`
+	ActionToken  string `json:"action_token,omitempty"`
+	// Source is "llm" or "heuristic"; GenerationMS is the time spent
+	// generating the message.
+	Source       string `json:"source,omitempty"`
+	GenerationMS int64  `json:"generation_ms,omitempty"`
+}
+
+type RequiredBotFailure struct {
+	BotID  string `json:"bot_id"`
+	Reason string `json:"reason"`
 }
 
 type PlanDebug struct {
-	ChosenStrategy    string `json:"chosen_strategy"`
-	SuppressedReplies int    `json:"suppressed_replies"`
+	ChosenStrategy    string               `json:"chosen_strategy"`
+	SuppressedReplies int                  `json:"suppressed_replies"`
+	CooldownSkipped   int                  `json:"cooldown_skipped"`
+	RequiredFailures  []RequiredBotFailure `json:"required_failures,omitempty"`
+	Warnings          []string             `json:"warnings,omitempty"`
+	LLMRouting        string               `json:"llm_routing,omitempty"`
+	LLMAttempts       int                  `json:"llm_attempts"`
+	LLMFailures       int                  `json:"llm_failures"`
+	GenerationMS      int64                `json:"generation_ms"`
+	// TruncatedMessages counts chat messages cut to the length cap and
+	// generated messages cut after the persona style was applied.
+	TruncatedMessages int  `json:"truncated_messages"`
+	DryRun            bool `json:"dry_run,omitempty"`
+	// LLMBackends names the backend behind each LLM reply when several
+	// are configured.
+	LLMBackends []LLMBackendUse `json:"llm_backends,omitempty"`
+	// LLMSettings echoes the effective settings.llm values, when sent.
+	LLMSettings *LLMSettings `json:"llm_settings,omitempty"`
+	// Degraded marks a plan answered only by heuristics because the LLM
+	// is down.
+	Degraded bool `json:"degraded,omitempty"`
+	// Topic is the detected topic the plan answered first and TopicScore
+	// its recency-weighted score (1 for a single, newest player message).
+	Topic      string  `json:"topic,omitempty"`
+	TopicScore float64 `json:"topic_score,omitempty"`
+	// StaleChat marks a plan made against chat older than
+	// settings.max_message_age_ms.
+	StaleChat bool `json:"stale_chat,omitempty"`
+	// AlreadyAnswered marks a plan that skipped topic replies because the
+	// newest player message was answered by an earlier plan.
+	AlreadyAnswered bool `json:"already_answered,omitempty"`
+	// RateLimited counts the bots left out because they reached
+	// settings.max_bot_messages_per_minute; they are included in
+	// SuppressedReplies too.
+	RateLimited int `json:"rate_limited,omitempty"`
+	// DeadlineExceeded marks a plan cut short by settings.plan_deadline_ms
+	// or the request timeout; SkippedBots counts the bots left without a
+	// reply because of it.
+	DeadlineExceeded bool `json:"deadline_exceeded,omitempty"`
+	SkippedBots      int  `json:"skipped_bots,omitempty"`
+	// Decisions walks through the plan step by step; only filled when the
+	// request sets explain.
+	Decisions []PlanDecision `json:"decisions,omitempty"`
+}
+
+// PlanDecision is one planner decision in debug.decisions. Step says what
+// was decided; Roll and Threshold are set for random rolls and Score for
+// detected topics.
+type PlanDecision struct {
+	Step      string  `json:"step"`
+	BotID     string  `json:"bot_id,omitempty"`
+	Topic     string  `json:"topic,omitempty"`
+	Score     float64 `json:"score,omitempty"`
+	Roll      float64 `json:"roll,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Outcome   string  `json:"outcome"`
+}
+
+type LLMBackendUse struct {
+	BotID   string `json:"bot_id"`
+	Backend string `json:"backend"`
 }
 
 type PlanResponse struct {
-	RequestID string          `json:"request_id"`
-	Actions   []PlannedAction `json:"actions"`
-	Debug     PlanDebug       `json:"debug"`
+	// SchemaVersion is only sent to clients that asked for version 2 or
+	// later; see ForSchemaVersion.
+	SchemaVersion int             `json:"schema_version,omitempty"`
+	RequestID     string          `json:"request_id"`
+	Actions       []PlannedAction `json:"actions"`
+	Debug         PlanDebug       `json:"debug"`
+}
+
+type ActionCheckRequest struct {
+	TimeMS int64         `json:"time_ms"`
+	Tokens []string      `json:"tokens"`
+	Chat   []ChatMessage `json:"chat"`
+}
+
+type ActionCheckResult struct {
+	ActionToken string `json:"action_token"`
+	Decision    string `json:"decision"`
+	Reason      string `json:"reason"`
+}
+
+type ActionCheckResponse struct {
+	Results []ActionCheckResult `json:"results"`
 }
 
 type HealthResponse struct {
-	Status string `json:"status"`
+	Status    string `json:"status"`
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	UptimeS   int64  `json:"uptime_s,omitempty"`
+	LLMState  string `json:"llm_state,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status     string              `json:"status"`
+	Components ReadinessComponents `json:"components"`
+}
+
+type ReadinessComponents struct {
+	LLM     LLMReadiness     `json:"llm"`
+	Elastic ElasticReadiness `json:"elastic"`
+	Planner PlannerReadiness `json:"planner"`
+}
+
+type LLMReadiness struct {
+	Enabled       bool                `json:"enabled"`
+	Available     bool                `json:"available"`
+	State         string              `json:"state"`
+	LastSuccessMS int64               `json:"last_success_ms,omitempty"`
+	Server        *LLMServerReadiness `json:"server,omitempty"`
+}
+
+type LLMServerReadiness struct {
+	State      string `json:"state"`
+	Reason     string `json:"reason,omitempty"`
+	SlotsTotal int    `json:"slots_total"`
+	SlotsBusy  int    `json:"slots_busy"`
+	LatencyMS  int64  `json:"latency_ms"`
+	CheckedMS  int64  `json:"checked_ms"`
+}
+
+type ElasticReadiness struct {
+	Enabled     bool   `json:"enabled"`
+	QueueDepth  int    `json:"queue_depth"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorMS int64  `json:"last_error_ms,omitempty"`
+	Dropped     int64  `json:"dropped,omitempty"`
+	Spilled     int64  `json:"spilled,omitempty"`
+	Replayed    int64  `json:"replayed,omitempty"`
+}
+
+type PlannerReadiness struct {
+	RegisteredServers int `json:"registered_servers"`
+	RegisteredBots    int `json:"registered_bots"`
 }
 
 type BotRegisterRequest struct {
 	ServerID string       `json:"server_id"`
 	Bots     []BotProfile `json:"bots"`
+	// DefaultSettings, when sent, replaces the settings this server's plan
+	// requests fall back to for fields they leave unset.
+	DefaultSettings *PlanSettings `json:"default_settings,omitempty"`
 }
 
 type BotRegisterResponse struct {
 	Registered int `json:"registered"`
 }
+
+type ValidationViolation struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Error      string                `json:"error"`
+	Violations []ValidationViolation `json:"violations"`
+}
+
+type ValidationFailedResponse struct {
+	Error   string                `json:"error"`
+	Details []ValidationViolation `json:"details"`
+}
+
+type InternalErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+type UnsupportedSchemaVersionResponse struct {
+	Error            string `json:"error"`
+	SchemaVersion    int    `json:"schema_version"`
+	MaxSchemaVersion int    `json:"max_schema_version"`
+}
+
+// MetaResponse lets integrators discover the reason taxonomy, the active
+// topics and the supported schema versions.
+type MetaResponse struct {
+	SchemaVersion    int          `json:"schema_version"`
+	MaxSchemaVersion int          `json:"max_schema_version"`
+	Reasons          []ReasonInfo `json:"reasons"`
+	ReasonPrefixes   []string     `json:"reason_prefixes"`
+	ReasonSuffixes   []string     `json:"reason_suffixes"`
+	Topics           []string     `json:"topics"`
+}
+
+type RateLimitedResponse struct {
+	Error        string `json:"error"`
+	RetryAfterMS int64  `json:"retry_after_ms"`
+}
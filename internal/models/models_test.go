@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const kebabPlanRequest = `{
+	"request-id": "req-kebab",
+	"server": {"server-id": "srv-1", "mode": "LOBBY", "online-players": 12},
+	"tick": 7,
+	"time-ms": 1712345000000,
+	"bots": [{
+		"bot-id": "bot-1",
+		"name": "Kuba",
+		"online": true,
+		"cooldown-ms": 2000,
+		"persona": {"language": "pl", "tone": "casual", "style-tags": ["short"], "avoid-topics": ["politics"], "knowledge-level": "beginner"},
+		"llm-overrides": {"top-p": 0.8, "max-tokens": 32}
+	}],
+	"chat": [{"ts-ms": 1712344999000, "sender": "RealPlayer123", "sender-type": "PLAYER", "message": "siema"}],
+	"settings": {
+		"max-actions": 2,
+		"min-delay-ms": 800,
+		"max-delay-ms": 4500,
+		"global-silence-chance": 0.2,
+		"reply-chance": 0.7,
+		"selection-strategy": "least_recent",
+		"topic-cooldown-ms": 5000,
+		"topic-cooldowns": {"small-talk": 1000},
+		"llm": {"top-p": 0.5, "max-tokens": 48, "chat-history-limit": 3}
+	},
+	"required-bot-ids": ["bot-1"],
+	"required-bypass-cooldown": true,
+	"dry-run": true
+}`
+
+const snakePlanRequest = `{
+	"request_id": "req-kebab",
+	"server": {"server_id": "srv-1", "mode": "LOBBY", "online_players": 12},
+	"tick": 7,
+	"time_ms": 1712345000000,
+	"bots": [{
+		"bot_id": "bot-1",
+		"name": "Kuba",
+		"online": true,
+		"cooldown_ms": 2000,
+		"persona": {"language": "pl", "tone": "casual", "style_tags": ["short"], "avoid_topics": ["politics"], "knowledge_level": "beginner"},
+		"llm_overrides": {"top_p": 0.8, "max_tokens": 32}
+	}],
+	"chat": [{"ts_ms": 1712344999000, "sender": "RealPlayer123", "sender_type": "PLAYER", "message": "siema"}],
+	"settings": {
+		"max_actions": 2,
+		"min_delay_ms": 800,
+		"max_delay_ms": 4500,
+		"global_silence_chance": 0.2,
+		"reply_chance": 0.7,
+		"selection_strategy": "least_recent",
+		"topic_cooldown_ms": 5000,
+		"topic_cooldowns": {"small-talk": 1000},
+		"llm": {"top_p": 0.5, "max_tokens": 48, "chat_history_limit": 3}
+	},
+	"required_bot_ids": ["bot-1"],
+	"required_bypass_cooldown": true,
+	"dry_run": true
+}`
+
+func TestPlanRequestKebabCaseMatchesSnakeCase(t *testing.T) {
+	var kebab, snake PlanRequest
+	if err := json.Unmarshal([]byte(kebabPlanRequest), &kebab); err != nil {
+		t.Fatalf("decode kebab-case: %v", err)
+	}
+	if err := json.Unmarshal([]byte(snakePlanRequest), &snake); err != nil {
+		t.Fatalf("decode snake_case: %v", err)
+	}
+	if !reflect.DeepEqual(kebab, snake) {
+		t.Fatalf("kebab-case decoded to %+v, snake_case to %+v", kebab, snake)
+	}
+	if kebab.Server.ServerID != "srv-1" || kebab.Bots[0].Persona.KnowledgeLevel != "beginner" || *kebab.Settings.LLM.TopP != 0.5 {
+		t.Fatalf("kebab-case fields not decoded: %+v", kebab)
+	}
+	if _, ok := kebab.Settings.TopicCooldowns["small-talk"]; !ok {
+		t.Fatalf("topic_cooldowns keys must be kept as sent: %v", kebab.Settings.TopicCooldowns)
+	}
+
+	data, err := json.Marshal(kebab)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, key := range []string{"request_id", "server_id", "online_players", "ts_ms", "sender_type", "style_tags", "top_p", "dry_run"} {
+		if !strings.Contains(string(data), `"`+key+`"`) {
+			t.Fatalf("marshalled request lacks snake_case key %s: %s", key, data)
+		}
+	}
+}
+
+func TestDualCaseDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  any
+		body    string
+		wantErr bool
+		check   func(t *testing.T, v any)
+	}{
+		{
+			name:   "snake_case wins over kebab-case",
+			target: &ServerContext{},
+			body:   `{"server_id":"snake","server-id":"kebab"}`,
+			check: func(t *testing.T, v any) {
+				if got := v.(*ServerContext).ServerID; got != "snake" {
+					t.Fatalf("server_id = %q, want snake", got)
+				}
+			},
+		},
+		{name: "unknown top-level field", target: &PlanRequest{}, body: `{"server":{"server_id":"s"},"extra":1}`, wantErr: true},
+		{name: "unknown kebab-case field", target: &PlanRequest{}, body: `{"server":{"server_id":"s"},"no-such-field":1}`, wantErr: true},
+		{name: "unknown field in settings", target: &PlanRequest{}, body: `{"settings":{"max-actions":1,"bogus":true}}`, wantErr: true},
+		{name: "unknown field in llm settings", target: &PlanSettings{}, body: `{"llm":{"top-k":40}}`, wantErr: true},
+		{name: "unknown field in bot", target: &BotProfile{}, body: `{"bot-id":"b","mood":"happy"}`, wantErr: true},
+		{name: "unknown field in chat", target: &ChatMessage{}, body: `{"sender":"p","color":"red"}`, wantErr: true},
+		{name: "wrong type", target: &ServerContext{}, body: `{"online-players":"many"}`, wantErr: true},
+		{
+			name:   "null leaves the value alone",
+			target: &ServerContext{ServerID: "kept"},
+			body:   `null`,
+			check: func(t *testing.T, v any) {
+				if got := v.(*ServerContext).ServerID; got != "kept" {
+					t.Fatalf("server_id = %q, want kept", got)
+				}
+			},
+		},
+		{
+			name:   "async request keeps callback url",
+			target: &AsyncPlanRequest{},
+			body:   `{"request-id":"r1","server":{"server-id":"s"},"callback-url":"http://example.test/cb"}`,
+			check: func(t *testing.T, v any) {
+				req := v.(*AsyncPlanRequest)
+				if req.CallbackURL != "http://example.test/cb" || req.RequestID != "r1" || req.Server.ServerID != "s" {
+					t.Fatalf("unexpected async request: %+v", req)
+				}
+			},
+		},
+		{name: "async request rejects unknown field", target: &AsyncPlanRequest{}, body: `{"callback_url":"http://example.test/cb","extra":1}`, wantErr: true},
+		{
+			name:   "engagement request",
+			target: &EngagementRequest{},
+			body:   `{"server":{"server-id":"s"},"target-player":"RealPlayer123","example-prompt":"hi"}`,
+			check: func(t *testing.T, v any) {
+				if req := v.(*EngagementRequest); req.TargetPlayer != "RealPlayer123" || req.ExamplePrompt != "hi" {
+					t.Fatalf("unexpected engagement request: %+v", req)
+				}
+			},
+		},
+		{
+			name:   "bot register request",
+			target: &BotRegisterRequest{},
+			body:   `{"server-id":"s","bots":[{"bot-id":"b"}]}`,
+			check: func(t *testing.T, v any) {
+				if req := v.(*BotRegisterRequest); req.ServerID != "s" || req.Bots[0].BotID != "b" {
+					t.Fatalf("unexpected register request: %+v", req)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := json.Unmarshal([]byte(tt.body), tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", tt.target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, tt.target)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import "strings"
+
+// Reason explains why the planner emitted an action. Plugins switch on it,
+// so every value the planner sends is one of the constants below, possibly
+// with the ReasonDryRunPrefix and, in schema version 1, ReasonWhisperSuffix.
+type Reason string
+
+const (
+	// ReasonGreeting answers a player greeting.
+	ReasonGreeting Reason = "greeting"
+	// ReasonAvoidRealPVP deflects a PvP invitation without accepting it.
+	ReasonAvoidRealPVP Reason = "avoid_real_pvp"
+	// ReasonReactToEvent reacts to players talking about an event.
+	ReasonReactToEvent Reason = "react_to_event"
+	// ReasonHelpfulHint answers a player asking for help.
+	ReasonHelpfulHint Reason = "helpful_hint"
+	// ReasonServerInfo answers a question about the server mode or player
+	// count.
+	ReasonServerInfo Reason = "server_info"
+	// ReasonSmallTalk fills a quiet tick without player topics.
+	ReasonSmallTalk Reason = "small_talk"
+	// ReasonCustomTopic uses a template of a topic from TOPIC_KEYWORDS_PATH.
+	ReasonCustomTopic Reason = "custom_topic"
+	// ReasonLLM is a reply generated by the LLM.
+	ReasonLLM Reason = "llm"
+	// ReasonAvoidTopicFiltered replaces an LLM reply that touched one of
+	// the bot's avoid_topics or a forbidden subject with a template.
+	ReasonAvoidTopicFiltered Reason = "avoid_topic_filtered"
+	// ReasonDirectMention answers a player who named the bot.
+	ReasonDirectMention Reason = "direct_mention"
+	// ReasonBanter opens a two-bot exchange.
+	ReasonBanter Reason = "banter"
+	// ReasonBanterReply answers the ReasonBanter line of another bot.
+	ReasonBanterReply Reason = "banter_reply"
+	// ReasonSystemEventReact hypes a SYSTEM event announcement.
+	ReasonSystemEventReact Reason = "system_event_react"
+	// ReasonEngagement opens a conversation with an idle player.
+	ReasonEngagement Reason = "engagement"
+)
+
+const (
+	// ReasonWhisperSuffix marks whispered actions in schema version 1.
+	ReasonWhisperSuffix = "_whisper"
+	// ReasonDryRunPrefix marks the actions of a dry run.
+	ReasonDryRunPrefix = "dry_run_"
+)
+
+// ReasonInfo documents one reason for /v1/meta.
+type ReasonInfo struct {
+	Reason      Reason `json:"reason"`
+	Description string `json:"description"`
+}
+
+// Reasons is the complete reason taxonomy, in documentation order.
+var Reasons = []ReasonInfo{
+	{ReasonGreeting, "template reply to a player greeting"},
+	{ReasonAvoidRealPVP, "template that deflects a PvP invitation"},
+	{ReasonReactToEvent, "template reaction to players talking about an event"},
+	{ReasonHelpfulHint, "template answer to a player asking for help"},
+	{ReasonServerInfo, "template answer with the server mode and online player count"},
+	{ReasonSmallTalk, "template small talk on a tick without player topics"},
+	{ReasonCustomTopic, "template of a custom topic from the topic keywords file"},
+	{ReasonLLM, "reply generated by the LLM"},
+	{ReasonAvoidTopicFiltered, "template replacing an LLM reply about an avoided or forbidden subject"},
+	{ReasonDirectMention, "reply from a bot named in the recent player messages"},
+	{ReasonBanter, "first line of a two-bot exchange"},
+	{ReasonBanterReply, "second line of a two-bot exchange"},
+	{ReasonSystemEventReact, "reaction to a SYSTEM event announcement"},
+	{ReasonEngagement, "conversation opener for an idle player (/v1/engagement)"},
+}
+
+// Base strips the dry run prefix and the whisper suffix.
+func (r Reason) Base() Reason {
+	base := strings.TrimPrefix(string(r), ReasonDryRunPrefix)
+	return Reason(strings.TrimSuffix(base, ReasonWhisperSuffix))
+}
+
+// Known reports whether r, ignoring its prefix and suffix, belongs to the
+// taxonomy.
+func (r Reason) Known() bool {
+	base := r.Base()
+	for _, info := range Reasons {
+		if info.Reason == base {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestReasonKnown(t *testing.T) {
+	tests := []struct {
+		reason Reason
+		want   bool
+	}{
+		{reason: ReasonGreeting, want: true},
+		{reason: "helpful_hint_whisper", want: true},
+		{reason: "dry_run_llm_whisper", want: true},
+		{reason: "dry_run_banter_reply", want: true},
+		{reason: "greating", want: false},
+		{reason: "quiet_hours", want: false},
+		{reason: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.reason.Known(); got != tt.want {
+			t.Fatalf("%q.Known() = %t, want %t", tt.reason, got, tt.want)
+		}
+	}
+	seen := make(map[Reason]bool)
+	for _, info := range Reasons {
+		if seen[info.Reason] || info.Description == "" {
+			t.Fatalf("duplicate or undocumented reason %q", info.Reason)
+		}
+		seen[info.Reason] = true
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import "strings"
+
+// Wire format versions a request may ask for with schema_version. The
+// planner works on the current model; responses are converted to the
+// version the client speaks so older plugins keep working.
+const (
+	// SchemaVersion1 is the format of requests without schema_version.
+	SchemaVersion1 = 1
+	// SchemaVersion2 echoes schema_version in responses and drops the
+	// _whisper reason suffix, since visibility and target_player already
+	// mark whispered actions.
+	SchemaVersion2 = 2
+
+	MaxSchemaVersion = SchemaVersion2
+)
+
+const whisperVisibility = "WHISPER"
+
+// EffectiveSchemaVersion treats an omitted schema_version as version 1.
+func EffectiveSchemaVersion(version int) int {
+	if version == 0 {
+		return SchemaVersion1
+	}
+	return version
+}
+
+// SchemaVersionSupported reports whether version, possibly omitted, is one
+// this build can answer.
+func SchemaVersionSupported(version int) bool {
+	version = EffectiveSchemaVersion(version)
+	return version >= SchemaVersion1 && version <= MaxSchemaVersion
+}
+
+// ForSchemaVersion converts a planner response to the wire format of
+// version; resp itself is not modified.
+func (resp PlanResponse) ForSchemaVersion(version int) PlanResponse {
+	if EffectiveSchemaVersion(version) == SchemaVersion1 {
+		resp.SchemaVersion = 0
+		return resp
+	}
+	resp.SchemaVersion = SchemaVersion2
+	actions := make([]PlannedAction, len(resp.Actions))
+	for i, action := range resp.Actions {
+		if action.Visibility == whisperVisibility {
+			action.Reason = Reason(strings.TrimSuffix(string(action.Reason), ReasonWhisperSuffix))
+		}
+		actions[i] = action
+	}
+	if resp.Actions != nil {
+		resp.Actions = actions
+	}
+	return resp
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func whisperResponse() PlanResponse {
+	return PlanResponse{
+		RequestID: "req-1",
+		Actions: []PlannedAction{
+			{BotID: "bot-1", Message: "wejdz na spawn", Visibility: "WHISPER", Reason: "helpful_hint_whisper", TargetPlayer: "RealPlayer123"},
+			{BotID: "bot-2", Message: "siema", Visibility: "PUBLIC", Reason: "greeting"},
+		},
+		Debug: PlanDebug{ChosenStrategy: "reply"},
+	}
+}
+
+func TestSchemaVersionSupported(t *testing.T) {
+	tests := []struct {
+		version int
+		want    bool
+	}{
+		{version: 0, want: true},
+		{version: SchemaVersion1, want: true},
+		{version: SchemaVersion2, want: true},
+		{version: MaxSchemaVersion + 1, want: false},
+		{version: -1, want: false},
+	}
+	for _, tt := range tests {
+		if got := SchemaVersionSupported(tt.version); got != tt.want {
+			t.Fatalf("SchemaVersionSupported(%d) = %t, want %t", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestPlanResponseForSchemaVersionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		wantReasons []Reason
+		wantVersion bool
+	}{
+		{name: "omitted is v1", version: 0, wantReasons: []Reason{"helpful_hint_whisper", "greeting"}},
+		{name: "v1", version: SchemaVersion1, wantReasons: []Reason{"helpful_hint_whisper", "greeting"}},
+		{name: "v2", version: SchemaVersion2, wantReasons: []Reason{"helpful_hint", "greeting"}, wantVersion: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internal := whisperResponse()
+			wire := internal.ForSchemaVersion(tt.version)
+			if !reflect.DeepEqual(internal, whisperResponse()) {
+				t.Fatalf("ForSchemaVersion modified its receiver: %+v", internal)
+			}
+
+			data, err := json.Marshal(wire)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if got := strings.Contains(string(data), `"schema_version"`); got != tt.wantVersion {
+				t.Fatalf("schema_version present = %t in %s", got, data)
+			}
+			var decoded PlanResponse
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, wire) {
+				t.Fatalf("round trip changed the response: %+v != %+v", decoded, wire)
+			}
+			for i, want := range tt.wantReasons {
+				if decoded.Actions[i].Reason != want {
+					t.Fatalf("action %d reason = %q, want %q", i, decoded.Actions[i].Reason, want)
+				}
+			}
+			if decoded.Actions[0].TargetPlayer != "RealPlayer123" || decoded.Actions[0].Visibility != "WHISPER" {
+				t.Fatalf("whisper target lost: %+v", decoded.Actions[0])
+			}
+		})
+	}
+}
+
+func TestPlanRequestSchemaVersionRoundTrip(t *testing.T) {
+	for _, body := range []string{
+		`{"server":{"server_id":"s"},"time_ms":1}`,
+		`{"server":{"server_id":"s"},"time_ms":1,"schema_version":2}`,
+	} {
+		var req PlanRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("decode %s: %v", body, err)
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var again PlanRequest
+		if err := json.Unmarshal(data, &again); err != nil || !reflect.DeepEqual(req, again) {
+			t.Fatalf("round trip of %s gave %+v (err %v)", body, again, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var chatSenderTypes = []string{"PLAYER", "BOT", "SYSTEM"}
+
+// Validate checks the embedded plan request and the callback URL, which has to
+// be an absolute http(s) URL when set.
+func (r AsyncPlanRequest) Validate() []ValidationViolation {
+	violations := r.PlanRequest.Validate()
+	if r.CallbackURL == "" {
+		return violations
+	}
+	parsed, err := url.Parse(r.CallbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		violations = append(violations, ValidationViolation{Path: "/callback_url", Rule: "format", Message: "must be an absolute http or https URL"})
+	}
+	return violations
+}
+
+// Validate reports fields the planner cannot work with. Zero values that the
+// planner normalizes (max_actions, delays, chances) are accepted.
+func (r PlanRequest) Validate() []ValidationViolation {
+	var violations []ValidationViolation
+	add := func(path, rule, format string, args ...interface{}) {
+		violations = append(violations, ValidationViolation{Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.TimeMS <= 0 {
+		add("/time_ms", "minimum", "must be > 0")
+	}
+	if r.Tick < 0 {
+		add("/tick", "minimum", "must be >= 0")
+	}
+	for i, bot := range r.Bots {
+		if strings.TrimSpace(bot.BotID) == "" {
+			add(fmt.Sprintf("/bots/%d/bot_id", i), "required", "missing bot_id")
+		}
+		if bot.CooldownMS < 0 {
+			add(fmt.Sprintf("/bots/%d/cooldown_ms", i), "minimum", "must be >= 0")
+		}
+		for j, quirk := range bot.Persona.Quirks {
+			if !isQuirk(quirk) {
+				add(fmt.Sprintf("/bots/%d/persona/quirks/%d", i, j), "enum", "must be one of %s", strings.Join(Quirks, ", "))
+			}
+		}
+	}
+	for i, message := range r.Chat {
+		if !isChatSenderType(message.SenderType) {
+			add(fmt.Sprintf("/chat/%d/sender_type", i), "enum", "must be one of %s", strings.Join(chatSenderTypes, ", "))
+		}
+	}
+	for i, botID := range r.RequiredBotIDs {
+		if strings.TrimSpace(botID) == "" {
+			add(fmt.Sprintf("/required_bot_ids/%d", i), "required", "empty bot id")
+		}
+	}
+
+	violations = append(violations, r.Settings.validate("/settings")...)
+	return violations
+}
+
+// Validate checks the default settings; bots without an ID are skipped at
+// registration rather than rejected.
+func (r BotRegisterRequest) Validate() []ValidationViolation {
+	if r.DefaultSettings == nil {
+		return nil
+	}
+	return r.DefaultSettings.validate("/default_settings")
+}
+
+// validate reports settings the planner cannot work with, with paths under
+// prefix.
+func (settings PlanSettings) validate(prefix string) []ValidationViolation {
+	var violations []ValidationViolation
+	add := func(path, rule, format string, args ...interface{}) {
+		violations = append(violations, ValidationViolation{Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	if settings.MaxActions < 0 {
+		add(prefix+"/max_actions", "minimum", "must be >= 0")
+	}
+	if settings.MinDelayMS < 0 {
+		add(prefix+"/min_delay_ms", "minimum", "must be >= 0")
+	}
+	if settings.MaxDelayMS < 0 {
+		add(prefix+"/max_delay_ms", "minimum", "must be >= 0")
+	}
+	if settings.MaxDelayMS > 0 && settings.MinDelayMS > settings.MaxDelayMS {
+		add(prefix+"/min_delay_ms", "order", "must be <= max_delay_ms")
+	}
+	chances := []struct {
+		field string
+		value float64
+	}{
+		{field: "global_silence_chance", value: settings.GlobalSilenceChance},
+		{field: "reply_chance", value: settings.ReplyChance},
+		{field: "banter_chance", value: settings.BanterChance},
+		{field: "uninterested_weight", value: settings.UninterestedWeight},
+	}
+	for _, chance := range chances {
+		if chance.value < 0 || chance.value > 1 {
+			add(prefix+"/"+chance.field, "range", "must be between 0 and 1")
+		}
+	}
+	if settings.CatchphraseChance != nil && (*settings.CatchphraseChance < 0 || *settings.CatchphraseChance > 1) {
+		add(prefix+"/catchphrase_chance", "range", "must be between 0 and 1")
+	}
+	if settings.SelectionStrategy != "" && !isSelectionStrategy(settings.SelectionStrategy) {
+		add(prefix+"/selection_strategy", "enum", "must be one of %s", strings.Join(SelectionStrategies, ", "))
+	}
+	if settings.TopicCooldownMS != nil && *settings.TopicCooldownMS < 0 {
+		add(prefix+"/topic_cooldown_ms", "minimum", "must be >= 0")
+	}
+	if settings.MaxMessageAgeMS < 0 {
+		add(prefix+"/max_message_age_ms", "minimum", "must be >= 0")
+	}
+	if settings.PlanDeadlineMS < 0 {
+		add(prefix+"/plan_deadline_ms", "minimum", "must be >= 0")
+	}
+	if settings.MaxBotMessagesPerMinute < 0 {
+		add(prefix+"/max_bot_messages_per_minute", "minimum", "must be >= 0")
+	}
+	topics := make([]string, 0, len(settings.TopicCooldowns))
+	for topic := range settings.TopicCooldowns {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		if settings.TopicCooldowns[topic] < 0 {
+			add(prefix+"/topic_cooldowns/"+topic, "minimum", "must be >= 0")
+		}
+	}
+	return violations
+}
+
+func isSelectionStrategy(strategy string) bool {
+	for _, allowed := range SelectionStrategies {
+		if strategy == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func isQuirk(quirk string) bool {
+	for _, allowed := range Quirks {
+		if quirk == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func isChatSenderType(senderType string) bool {
+	for _, allowed := range chatSenderTypes {
+		if strings.EqualFold(senderType, allowed) {
+			return true
+		}
+	}
+	return false
+}
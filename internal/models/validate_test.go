@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func validPlanRequest() PlanRequest {
+	return PlanRequest{
+		Server: ServerContext{ServerID: "srv-1"},
+		TimeMS: 1712345000000,
+		Bots:   []BotProfile{{BotID: "bot-1"}},
+		Chat:   []ChatMessage{{Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"}},
+	}
+}
+
+func TestPlanRequestValidate(t *testing.T) {
+	negative := int64(-1)
+	tests := []struct {
+		name     string
+		mutate   func(r *PlanRequest)
+		wantPath string
+		wantRule string
+	}{
+		{name: "valid", mutate: func(r *PlanRequest) {}},
+		{name: "zero settings are normalized later", mutate: func(r *PlanRequest) { r.Settings = PlanSettings{} }},
+		{name: "lowercase sender type", mutate: func(r *PlanRequest) { r.Chat[0].SenderType = "player" }},
+		{name: "unset max delay", mutate: func(r *PlanRequest) { r.Settings.MinDelayMS = 5000 }},
+		{name: "missing time", mutate: func(r *PlanRequest) { r.TimeMS = 0 }, wantPath: "/time_ms", wantRule: "minimum"},
+		{name: "negative tick", mutate: func(r *PlanRequest) { r.Tick = -1 }, wantPath: "/tick", wantRule: "minimum"},
+		{name: "missing bot id", mutate: func(r *PlanRequest) { r.Bots = append(r.Bots, BotProfile{Name: "Kuba"}) }, wantPath: "/bots/1/bot_id", wantRule: "required"},
+		{name: "negative bot cooldown", mutate: func(r *PlanRequest) { r.Bots[0].CooldownMS = -5 }, wantPath: "/bots/0/cooldown_ms", wantRule: "minimum"},
+		{name: "known quirk", mutate: func(r *PlanRequest) { r.Bots[0].Persona.Quirks = []string{QuirkLowercaseOnly} }},
+		{name: "unknown quirk", mutate: func(r *PlanRequest) { r.Bots[0].Persona.Quirks = []string{"all_caps"} }, wantPath: "/bots/0/persona/quirks/0", wantRule: "enum"},
+		{name: "unknown sender type", mutate: func(r *PlanRequest) { r.Chat[0].SenderType = "NPC" }, wantPath: "/chat/0/sender_type", wantRule: "enum"},
+		{name: "empty required bot", mutate: func(r *PlanRequest) { r.RequiredBotIDs = []string{" "} }, wantPath: "/required_bot_ids/0", wantRule: "required"},
+		{name: "negative max actions", mutate: func(r *PlanRequest) { r.Settings.MaxActions = -1 }, wantPath: "/settings/max_actions", wantRule: "minimum"},
+		{name: "negative min delay", mutate: func(r *PlanRequest) { r.Settings.MinDelayMS = -1 }, wantPath: "/settings/min_delay_ms", wantRule: "minimum"},
+		{name: "negative max delay", mutate: func(r *PlanRequest) { r.Settings.MaxDelayMS = -1 }, wantPath: "/settings/max_delay_ms", wantRule: "minimum"},
+		{name: "min delay above max", mutate: func(r *PlanRequest) { r.Settings.MinDelayMS, r.Settings.MaxDelayMS = 3000, 1000 }, wantPath: "/settings/min_delay_ms", wantRule: "order"},
+		{name: "silence chance above one", mutate: func(r *PlanRequest) { r.Settings.GlobalSilenceChance = 1.5 }, wantPath: "/settings/global_silence_chance", wantRule: "range"},
+		{name: "negative reply chance", mutate: func(r *PlanRequest) { r.Settings.ReplyChance = -0.1 }, wantPath: "/settings/reply_chance", wantRule: "range"},
+		{name: "banter chance above one", mutate: func(r *PlanRequest) { r.Settings.BanterChance = 2 }, wantPath: "/settings/banter_chance", wantRule: "range"},
+		{name: "known selection strategy", mutate: func(r *PlanRequest) { r.Settings.SelectionStrategy = SelectionWeighted }},
+		{name: "unknown selection strategy", mutate: func(r *PlanRequest) { r.Settings.SelectionStrategy = "round_robin" }, wantPath: "/settings/selection_strategy", wantRule: "enum"},
+		{name: "negative topic cooldown", mutate: func(r *PlanRequest) { r.Settings.TopicCooldownMS = &negative }, wantPath: "/settings/topic_cooldown_ms", wantRule: "minimum"},
+		{name: "catchphrase chance above one", mutate: func(r *PlanRequest) { chance := 1.5; r.Settings.CatchphraseChance = &chance }, wantPath: "/settings/catchphrase_chance", wantRule: "range"},
+		{name: "negative bot rate limit", mutate: func(r *PlanRequest) { r.Settings.MaxBotMessagesPerMinute = -1 }, wantPath: "/settings/max_bot_messages_per_minute", wantRule: "minimum"},
+		{name: "negative per-topic cooldown", mutate: func(r *PlanRequest) { r.Settings.TopicCooldowns = map[string]int64{"greeting": -1} }, wantPath: "/settings/topic_cooldowns/greeting", wantRule: "minimum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validPlanRequest()
+			tt.mutate(&req)
+			violations := req.Validate()
+			if tt.wantPath == "" {
+				if len(violations) != 0 {
+					t.Fatalf("expected no violations, got %+v", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || violations[0].Path != tt.wantPath || violations[0].Rule != tt.wantRule {
+				t.Fatalf("expected %s %s, got %+v", tt.wantPath, tt.wantRule, violations)
+			}
+		})
+	}
+}
+
+func TestBotRegisterRequestValidate(t *testing.T) {
+	req := BotRegisterRequest{ServerID: "srv-1", Bots: []BotProfile{{BotID: "bot-1"}}}
+	if violations := req.Validate(); len(violations) != 0 {
+		t.Fatalf("expected no violations, got %+v", violations)
+	}
+	req.DefaultSettings = &PlanSettings{ReplyChance: 2}
+	violations := req.Validate()
+	if len(violations) != 1 || violations[0].Path != "/default_settings/reply_chance" || violations[0].Rule != "range" {
+		t.Fatalf("expected /default_settings/reply_chance range, got %+v", violations)
+	}
+}
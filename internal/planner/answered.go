@@ -0,0 +1,66 @@
+package planner
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"aichatplayers/internal/models"
+)
+
+// answeredMessage identifies the newest player message of a chat window a
+// topic reply was planned for. The hash tells apart messages sent in the
+// same millisecond.
+type answeredMessage struct {
+	timestampMS int64
+	hash        uint64
+}
+
+// newestPlayerMessage returns the newest PLAYER message of chat.
+func newestPlayerMessage(chat []models.ChatMessage) (answeredMessage, bool) {
+	for i := len(chat) - 1; i >= 0; i-- {
+		message := chat[i]
+		if !strings.EqualFold(message.SenderType, "PLAYER") {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(message.Sender))
+		h.Write([]byte{0})
+		h.Write([]byte(message.Message))
+		return answeredMessage{timestampMS: message.TimestampMS, hash: h.Sum64()}, true
+	}
+	return answeredMessage{}, false
+}
+
+// alreadyAnswered reports whether the server's bots already replied to the
+// newest player message in chat: the plugin resends overlapping chat
+// windows, so the same greeting shows up on several ticks.
+func (p *Planner) alreadyAnswered(serverID string, chat []models.ChatMessage) bool {
+	newest, ok := newestPlayerMessage(chat)
+	if !ok {
+		return false
+	}
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	last, ok := p.answered[serverID]
+	return ok && (newest.timestampMS < last.timestampMS || newest == last)
+}
+
+func (p *Planner) rememberAnswered(serverID string, chat []models.ChatMessage) {
+	newest, ok := newestPlayerMessage(chat)
+	if !ok {
+		return
+	}
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if last, ok := p.answered[serverID]; !ok || newest.timestampMS >= last.timestampMS {
+		p.answered[serverID] = newest
+	}
+}
@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func answeredTestRequest(id string, timeMS int64, chat ...models.ChatMessage) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: id,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    timeMS,
+		Bots:      []models.BotProfile{{BotID: "bot-1"}, {BotID: "bot-2"}},
+		Chat:      chat,
+		Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlanSkipsAlreadyAnsweredMessage(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	greeting := models.ChatMessage{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"}
+
+	first := planner.Plan(context.Background(), answeredTestRequest("tick-1", 1712345000000, greeting))
+	if len(first.Actions) != 1 || first.Actions[0].Reason != models.ReasonGreeting || first.Debug.AlreadyAnswered {
+		t.Fatalf("expected a greeting on the first tick, got %+v (debug %+v)", first.Actions, first.Debug)
+	}
+
+	second := planner.Plan(context.Background(), answeredTestRequest("tick-2", 1712345001000, greeting))
+	for _, action := range second.Actions {
+		if action.Reason != models.ReasonSmallTalk {
+			t.Fatalf("expected no topic reply to the answered greeting, got %+v", second.Actions)
+		}
+	}
+	if !second.Debug.AlreadyAnswered || second.Debug.Topic != "" {
+		t.Fatalf("debug = %+v, want already_answered without a topic", second.Debug)
+	}
+
+	question := models.ChatMessage{TimestampMS: 1712345001500, Sender: "Steve", SenderType: "PLAYER", Message: "jak zrobic portal?"}
+	third := planner.Plan(context.Background(), answeredTestRequest("tick-3", 1712345002000, greeting, question))
+	if len(third.Actions) != 1 || third.Actions[0].Reason != models.ReasonHelpfulHint || third.Debug.AlreadyAnswered {
+		t.Fatalf("expected a reply to the newer message, got %+v (debug %+v)", third.Actions, third.Debug)
+	}
+}
+
+func TestAlreadyAnsweredComparesMessages(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	answered := []models.ChatMessage{{TimestampMS: 1000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}}
+	planner.rememberAnswered("srv-1", answered)
+
+	tests := []struct {
+		name string
+		chat []models.ChatMessage
+		want bool
+	}{
+		{name: "same message", chat: answered, want: true},
+		{name: "older message", chat: []models.ChatMessage{{TimestampMS: 900, Sender: "Alex", SenderType: "PLAYER", Message: "hej"}}, want: true},
+		{name: "same millisecond, other message", chat: []models.ChatMessage{{TimestampMS: 1000, Sender: "Alex", SenderType: "PLAYER", Message: "hej"}}},
+		{name: "newer message", chat: []models.ChatMessage{{TimestampMS: 1100, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}}},
+		{name: "only bots", chat: []models.ChatMessage{{TimestampMS: 900, Sender: "Kuba", SenderType: "BOT", Message: "siema"}}},
+	}
+	for _, tt := range tests {
+		if got := planner.alreadyAnswered("srv-1", tt.chat); got != tt.want {
+			t.Fatalf("%s: alreadyAnswered = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+	if planner.alreadyAnswered("srv-2", answered) {
+		t.Fatal("answered messages must be tracked per server")
+	}
+}
@@ -0,0 +1,140 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/models"
+)
+
+const (
+	banterMinGapMS     = 3000
+	banterGapJitterMS  = 2000
+	banterActionsCount = 2
+)
+
+func shouldBanter(bots []models.BotProfile, required *requiredTracker, settings models.PlanSettings, rng *rand.Rand) bool {
+	if settings.BanterChance <= 0 || settings.MaxActions < banterActionsCount || len(bots) < banterActionsCount || required.prioritized() {
+		return false
+	}
+	return rng.Float64() < settings.BanterChance
+}
+
+// banterPlan lets one bot open a short exchange and a different bot answer it
+// a few seconds later.
+func (p *Planner) banterPlan(ctx context.Context, req models.PlanRequest, bots []models.BotProfile, routing *llmRouting, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, bool, bool) {
+	pair := p.newBotSelector(req.Server.ServerID, settings, req.TimeMS).pick(bots, banterActionsCount, rng)
+	first, second := pair[0], pair[1]
+	pairIndex := p.pickBanterPair(req.Server.ServerID, first, rng)
+	if pairIndex < 0 {
+		return nil, false, false
+	}
+	logging.Ctx(ctx).Debugf("planner_plan_banter request_id=%s transaction_id=%s opener=%s responder=%s", req.RequestID, req.RequestID, first.BotID, second.BotID)
+
+	opener := templatesFor(first.Persona.Language).Banter
+	start := p.clock.Now()
+	call, callAttempted, callUsed := p.generateBanterLine(ctx, req, first, req.Chat, llm.Request{BanterOpener: true}, opener[pairIndex].Call, routing)
+	callSource, callGenerationMS := routing.observe(p.clock.Now().Sub(start), callAttempted, callUsed)
+	if call == "" {
+		return nil, callAttempted, false
+	}
+	call = p.styleMessage(settings, first, call, routing, rng)
+	callDelay := randomDelay(settings, first.CooldownMS, rng)
+	actions := []models.PlannedAction{{
+		BotID:        first.BotID,
+		SendAfterMS:  callDelay,
+		Message:      call,
+		Visibility:   visibilityPublic,
+		Reason:       models.ReasonBanter,
+		Source:       callSource,
+		GenerationMS: callGenerationMS,
+	}}
+	p.recordAction(req, first.BotID, "small_talk", call)
+
+	if requestExpired(ctx, req.RequestID) {
+		return actions, callAttempted, callUsed
+	}
+	chat := make([]models.ChatMessage, 0, len(req.Chat)+1)
+	chat = append(chat, req.Chat...)
+	chat = append(chat, models.ChatMessage{TimestampMS: req.TimeMS + callDelay, Sender: first.Name, SenderType: "BOT", Message: call})
+	responder := templatesFor(second.Persona.Language).Banter
+	start = p.clock.Now()
+	reply, replyAttempted, replyUsed := p.generateBanterLine(ctx, req, second, chat, llm.Request{BanterReplyTo: first.Name}, responder[pairIndex%len(responder)].Response, routing)
+	replySource, replyGenerationMS := routing.observe(p.clock.Now().Sub(start), replyAttempted, replyUsed)
+	if reply == "" {
+		return actions, callAttempted || replyAttempted, callUsed
+	}
+	reply = p.styleMessage(settings, second, reply, routing, rng)
+	replyDelay := randomDelay(settings, second.CooldownMS, rng)
+	if minDelay := callDelay + banterMinGapMS + rng.Int63n(banterGapJitterMS); replyDelay < minDelay {
+		replyDelay = minDelay
+	}
+	actions = append(actions, models.PlannedAction{
+		BotID:        second.BotID,
+		SendAfterMS:  replyDelay,
+		Message:      reply,
+		Visibility:   visibilityPublic,
+		Reason:       models.ReasonBanterReply,
+		Source:       replySource,
+		GenerationMS: replyGenerationMS,
+	})
+	p.recordAction(req, second.BotID, "small_talk", reply)
+	logging.Ctx(ctx).Infof("planner_plan_banter_action request_id=%s transaction_id=%s opener=%s responder=%s", req.RequestID, req.RequestID, first.BotID, second.BotID)
+	return actions, callAttempted || replyAttempted, callUsed || replyUsed
+}
+
+func (p *Planner) pickBanterPair(serverID string, bot models.BotProfile, rng *rand.Rand) int {
+	pairs := templatesFor(bot.Persona.Language).Banter
+	if len(pairs) == 0 {
+		return -1
+	}
+	for attempt := 0; attempt < maxTemplateRepicks; attempt++ {
+		index := rng.Intn(len(pairs))
+		if !p.sentRecently(serverID, bot.BotID, pairs[index].Call) {
+			return index
+		}
+	}
+	return -1
+}
+
+// generateBanterLine asks the LLM for one turn of the exchange and falls back
+// to the paired template; both are rejected if the bot said them recently.
+func (p *Planner) generateBanterLine(ctx context.Context, req models.PlanRequest, bot models.BotProfile, chat []models.ChatMessage, turn llm.Request, fallback string, routing *llmRouting) (string, bool, bool) {
+	if req.DryRun {
+		if turn.BanterOpener {
+			return dryRunMessage(string(models.ReasonBanter)), false, false
+		}
+		return dryRunMessage(string(models.ReasonBanterReply)), false, false
+	}
+	attempted := false
+	if p.llm != nil && p.llm.Enabled() && !routing.reserve("", false) {
+		attempted = true
+		var cancel context.CancelFunc
+		if p.tuning.Load().llmTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.tuning.Load().llmTimeout)
+			defer cancel()
+		}
+		turn.Server = req.Server
+		turn.Bot = bot
+		turn.RecentChat = recentChat(chat, p.historyLimit(req.Settings))
+		turn.Sampling = req.Settings.LLM
+		message, err := p.generateLLM(ctx, turn)
+		if err != nil {
+			logging.Ctx(ctx).Warnf("planner_llm_error request_id=%s transaction_id=%s bot_id=%s topic=banter error=%v", req.RequestID, req.RequestID, bot.BotID, err)
+		} else if p.sentRecently(req.Server.ServerID, bot.BotID, message) {
+			logging.Ctx(ctx).Infof("planner_llm_repeat request_id=%s transaction_id=%s bot_id=%s topic=banter", req.RequestID, req.RequestID, bot.BotID)
+		} else if p.profaneOutput(ctx, req.RequestID, bot, message) {
+			return "", true, false
+		} else if message != "" && !p.avoidedOutput(ctx, req.RequestID, bot, message) {
+			return message, true, true
+		}
+		metrics.HeuristicFallbacks.Inc()
+	}
+	if p.sentRecently(req.Server.ServerID, bot.BotID, fallback) {
+		return "", attempted, false
+	}
+	return fallback, attempted, false
+}
@@ -0,0 +1,92 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func banterTestRequest(requestID string, bots []models.BotProfile, maxActions int) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      bots,
+		Settings:  models.PlanSettings{MaxActions: maxActions, BanterChance: 1},
+	}
+}
+
+func banterTestBots() []models.BotProfile {
+	return []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}}
+}
+
+func TestPlannerBanterExchange(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		planner := NewPlanner(noopLLM{}, Config{})
+		resp := planner.Plan(context.Background(), banterTestRequest(fmt.Sprintf("req-banter-%d", i), banterTestBots(), 3))
+		if len(resp.Actions) != 2 {
+			t.Fatalf("run %d: expected a two-action exchange, got %+v", i, resp.Actions)
+		}
+		call, reply := resp.Actions[0], resp.Actions[1]
+		if call.BotID == reply.BotID {
+			t.Fatalf("run %d: same bot on both turns: %+v", i, resp.Actions)
+		}
+		if call.Reason != models.ReasonBanter || reply.Reason != models.ReasonBanterReply || resp.Debug.ChosenStrategy != string(models.ReasonBanter) {
+			t.Fatalf("run %d: reasons %s/%s strategy %s", i, call.Reason, reply.Reason, resp.Debug.ChosenStrategy)
+		}
+		if reply.SendAfterMS < call.SendAfterMS+banterMinGapMS {
+			t.Fatalf("run %d: reply at %d ms is too close to call at %d ms", i, reply.SendAfterMS, call.SendAfterMS)
+		}
+		paired := false
+		for _, pair := range templatesFor("").Banter {
+			if pair.Call == call.Message && pair.Response == reply.Message {
+				paired = true
+			}
+		}
+		if !paired {
+			t.Fatalf("run %d: %q / %q is not a template pair", i, call.Message, reply.Message)
+		}
+	}
+}
+
+func TestPlannerBanterReplySeesOpener(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{ChatHistoryLimit: 6})
+	resp := planner.Plan(context.Background(), banterTestRequest("req-banter-llm", banterTestBots(), 2))
+	if len(resp.Actions) != 2 || resp.Debug.ChosenStrategy != "llm" {
+		t.Fatalf("expected llm exchange, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+	if len(generator.requests) != 2 {
+		t.Fatalf("expected 2 llm requests, got %d", len(generator.requests))
+	}
+	opener, reply := generator.requests[0], generator.requests[1]
+	if !opener.BanterOpener || reply.BanterReplyTo != opener.Bot.Name {
+		t.Fatalf("unexpected banter turns: opener=%+v reply=%+v", opener, reply)
+	}
+	last := reply.RecentChat[len(reply.RecentChat)-1]
+	if last.Sender != opener.Bot.Name || last.Message != resp.Actions[0].Message || last.SenderType != "BOT" {
+		t.Fatalf("reply chat should end with the opener's line, got %+v", reply.RecentChat)
+	}
+}
+
+func TestPlannerBanterFallsBackToSmallTalk(t *testing.T) {
+	tests := []struct {
+		name       string
+		bots       []models.BotProfile
+		maxActions int
+	}{
+		{name: "single bot", bots: banterTestBots()[:1], maxActions: 2},
+		{name: "max actions below pair", bots: banterTestBots(), maxActions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			resp := planner.Plan(context.Background(), banterTestRequest("req-banter-small", tt.bots, tt.maxActions))
+			if len(resp.Actions) != 1 || resp.Debug.ChosenStrategy != "small_talk" {
+				t.Fatalf("expected single small talk line, got %+v (debug %+v)", resp.Actions, resp.Debug)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+const chaosFullMessage = "siema wszystkim, co tam dzisiaj"
+
+func TestPlannerUnderFaultScenarios(t *testing.T) {
+	tests := []struct {
+		file   string
+		expect string
+	}{
+		{file: "latency_spike.json", expect: "fallback"},
+		{file: "connection_reset.json", expect: "fallback"},
+		{file: "malformed_body.json", expect: "fallback"},
+		{file: "stuck_request.json", expect: "fallback"},
+		{file: "partial_stream.json", expect: "partial"},
+		{file: "mixed.json", expect: "any"},
+	}
+
+	const (
+		softTimeout = 50 * time.Millisecond
+		maxLatency  = 400 * time.Millisecond
+		runs        = 25
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			scenario, err := llm.LoadFaultScenario(filepath.Join("testdata", "chaos", tt.file))
+			if err != nil {
+				t.Fatalf("LoadFaultScenario() error: %v", err)
+			}
+			cfg := config.LLMConfig{Timeout: 100 * time.Millisecond, MaxResponseChars: 80}
+			generator := llm.NewFaultInjector(fakeLLM{enabled: true, message: chaosFullMessage}, scenario, cfg)
+			planner := NewPlanner(generator, Config{LLMTimeout: softTimeout})
+
+			for i := 0; i < runs; i++ {
+				req := models.PlanRequest{
+					RequestID: fmt.Sprintf("chaos-%d", i),
+					Server:    models.ServerContext{ServerID: "srv-chaos"},
+					TimeMS:    1712345000000 + int64(i)*60000,
+					Bots:      []models.BotProfile{{BotID: fmt.Sprintf("bot-%d", i), Name: "Kuba"}},
+					Chat: []models.ChatMessage{
+						{TimestampMS: 1712345000000 + int64(i)*60000 - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+					},
+					Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+				}
+
+				start := time.Now()
+				resp := planner.Plan(context.Background(), req)
+				if elapsed := time.Since(start); elapsed > maxLatency {
+					t.Fatalf("run %d: plan took %s, want <= %s", i, elapsed, maxLatency)
+				}
+				if len(resp.Actions) != 1 {
+					t.Fatalf("run %d: expected 1 action, got %+v", i, resp.Actions)
+				}
+				action := resp.Actions[0]
+				if strings.TrimSpace(action.Message) == "" {
+					t.Fatalf("run %d: empty message", i)
+				}
+
+				switch tt.expect {
+				case "fallback":
+					if action.Reason == "llm" || resp.Debug.ChosenStrategy != "heuristics_fallback" {
+						t.Fatalf("run %d: expected heuristic fallback, got reason=%s strategy=%s", i, action.Reason, resp.Debug.ChosenStrategy)
+					}
+				case "partial":
+					if action.Reason != "llm" || !strings.HasPrefix(chaosFullMessage, action.Message) {
+						t.Fatalf("run %d: expected truncated llm message, got reason=%s message=%q", i, action.Reason, action.Message)
+					}
+				default:
+					if action.Reason == "llm" && resp.Debug.ChosenStrategy != "llm" {
+						t.Fatalf("run %d: llm reason with strategy %s", i, resp.Debug.ChosenStrategy)
+					}
+					if action.Reason != "llm" && resp.Debug.ChosenStrategy != "heuristics_fallback" {
+						t.Fatalf("run %d: heuristic reason with strategy %s", i, resp.Debug.ChosenStrategy)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFaultInjectorIsDeterministicPerSeed(t *testing.T) {
+	scenario, err := llm.LoadFaultScenario(filepath.Join("testdata", "chaos", "mixed.json"))
+	if err != nil {
+		t.Fatalf("LoadFaultScenario() error: %v", err)
+	}
+	scenario.Faults = scenario.Faults[2:4]
+
+	outcomes := func() string {
+		generator := llm.NewFaultInjector(fakeLLM{enabled: true, message: chaosFullMessage}, scenario, config.LLMConfig{})
+		var sb strings.Builder
+		for i := 0; i < 20; i++ {
+			_, err := generator.Generate(context.Background(), llm.Request{})
+			sb.WriteString(fmt.Sprint(err != nil))
+		}
+		return sb.String()
+	}
+	if first, second := outcomes(), outcomes(); first != second {
+		t.Fatalf("expected identical outcomes for a fixed seed:\n%s\n%s", first, second)
+	}
+}
+
+func TestPlannerSkipsStuckLLMOnceBreakerOpens(t *testing.T) {
+	scenario, err := llm.LoadFaultScenario(filepath.Join("testdata", "chaos", "stuck_request.json"))
+	if err != nil {
+		t.Fatalf("LoadFaultScenario() error: %v", err)
+	}
+	const (
+		softTimeout = 50 * time.Millisecond
+		failures    = 3
+	)
+	cfg := config.LLMConfig{Timeout: 100 * time.Millisecond, BreakerFailures: failures, BreakerCooldown: time.Minute}
+	generator := llm.WithCircuitBreaker(llm.NewFaultInjector(fakeLLM{enabled: true, message: chaosFullMessage}, scenario, cfg), cfg)
+	planner := NewPlanner(generator, Config{LLMTimeout: softTimeout})
+
+	for i := 0; i < 10; i++ {
+		req := models.PlanRequest{
+			RequestID: fmt.Sprintf("breaker-%d", i),
+			Server:    models.ServerContext{ServerID: "srv-chaos"},
+			TimeMS:    1712345000000 + int64(i)*60000,
+			Bots:      []models.BotProfile{{BotID: fmt.Sprintf("bot-%d", i), Name: "Kuba"}},
+			Chat: []models.ChatMessage{
+				{TimestampMS: 1712345000000 + int64(i)*60000 - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+			},
+			Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+		}
+		start := time.Now()
+		resp := planner.Plan(context.Background(), req)
+		elapsed := time.Since(start)
+		if len(resp.Actions) != 1 || resp.Debug.ChosenStrategy != "heuristics_fallback" {
+			t.Fatalf("run %d: expected heuristic fallback, got %+v (debug %+v)", i, resp.Actions, resp.Debug)
+		}
+		if i >= failures && elapsed >= softTimeout {
+			t.Fatalf("run %d: open breaker should skip the soft timeout, took %s", i, elapsed)
+		}
+	}
+	if state := planner.LLMState(); state != llm.BreakerOpen {
+		t.Fatalf("LLMState() = %s, want %s", state, llm.BreakerOpen)
+	}
+}
@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"strings"
+	"unicode"
+
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+const defaultChatMessageMaxChars = 256
+
+// maxPlayerNameChars is the Minecraft username limit; longer senders are
+// cut before a template or prompt names them.
+const maxPlayerNameChars = 16
+
+// systemSenders are account names plugins use for console and broadcast
+// lines that arrive with sender_type PLAYER.
+var systemSenders = []string{"server", "console", "system", "broadcast"}
+
+// promptMarkers could make a player line look like a prompt section or the
+// silence token once it is embedded in the LLM prompt.
+var promptMarkers = []string{"===", "__silence__"}
+
+// sanitizeChat strips control characters and prompt markers from incoming
+// messages, caps their length at maxChars runes and drops the ones left
+// empty. It returns the cleaned copy and how many messages were truncated.
+func sanitizeChat(chat []models.ChatMessage, maxChars int) ([]models.ChatMessage, int) {
+	cleaned := make([]models.ChatMessage, 0, len(chat))
+	truncated := 0
+	for _, message := range chat {
+		text := sanitizeChatText(message.Message)
+		if runes := []rune(text); len(runes) > maxChars {
+			text = strings.TrimSpace(string(runes[:maxChars]))
+			truncated++
+		}
+		if text == "" {
+			continue
+		}
+		message.Message = text
+		cleaned = append(cleaned, message)
+	}
+	return cleaned, truncated
+}
+
+func sanitizeChatText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	for changed := true; changed; {
+		changed = false
+		for _, marker := range promptMarkers {
+			if index := indexFold(text, marker); index >= 0 {
+				text = text[:index] + " " + text[index+len(marker):]
+				changed = true
+			}
+		}
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// indexFold is strings.Index ignoring ASCII case; markers are ASCII, so byte
+// offsets into text stay valid.
+func indexFold(text, marker string) int {
+	for i := 0; i+len(marker) <= len(text); i++ {
+		if strings.EqualFold(text[i:i+len(marker)], marker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// playerName cleans a chat sender for use in a reply: it drops color codes
+// (§c, &l) and characters Minecraft names cannot hold and caps the length.
+// It returns "" for system accounts and senders with nothing left.
+func playerName(sender string) string {
+	runes := []rune(sender)
+	var sb strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case (r == '§' || r == '&') && i+1 < len(runes) && util.IsFormattingCode(runes[i+1]):
+			i++
+		case r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+		}
+	}
+	name := sb.String()
+	if len(name) > maxPlayerNameChars {
+		name = name[:maxPlayerNameChars]
+	}
+	for _, system := range systemSenders {
+		if strings.EqualFold(name, system) {
+			return ""
+		}
+	}
+	return name
+}
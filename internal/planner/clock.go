@@ -0,0 +1,15 @@
+package planner
+
+import "time"
+
+// Clock is the planner's time source for decisions that have no request
+// time_ms to go by: quiet hours, state snapshots, action checks without a
+// timestamp and generation timings. Cooldowns between requests keep using
+// time_ms.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,115 @@
+package planner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+var testClockStart = time.Date(2024, 4, 5, 19, 23, 20, 0, time.UTC)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTopicCooldownExpiryMatchesWithFakeClock(t *testing.T) {
+	steps := []time.Duration{0, 5 * time.Second, 10 * time.Second, 16 * time.Second, 20 * time.Second}
+	run := func(planner *Planner, clock *fakeClock) []int {
+		start := testClockStart.UnixMilli()
+		var actions []int
+		for i, offset := range steps {
+			if clock != nil {
+				clock.Set(testClockStart.Add(offset))
+			}
+			resp := planner.Plan(context.Background(), stateTestRequest("cooldown-"+offset.String(), start+offset.Milliseconds()))
+			actions = append(actions, len(resp.Actions))
+			if i == 0 && len(resp.Actions) != 1 {
+				t.Fatalf("expected the first greeting to be planned, got %+v", resp)
+			}
+		}
+		return actions
+	}
+
+	clock := newFakeClock(testClockStart)
+	withFake := run(NewPlanner(noopLLM{}, Config{Clock: clock}), clock)
+	withSystem := run(NewPlanner(noopLLM{}, Config{}), nil)
+	want := []int{1, 0, 0, 1, 0}
+	for i := range want {
+		if withFake[i] != want[i] || withSystem[i] != want[i] {
+			t.Fatalf("step %s: fake clock=%v system clock=%v, want %v", steps[i], withFake, withSystem, want)
+		}
+	}
+}
+
+func TestCheckActionsWithoutTimeUsesClock(t *testing.T) {
+	clock := newFakeClock(time.UnixMilli(1712345000000))
+	planner := NewPlanner(noopLLM{}, Config{Clock: clock})
+	_, token := planWithToken(t, planner)
+
+	clock.Advance(2 * time.Second)
+	if result := planner.CheckActions(models.ActionCheckRequest{Tokens: []string{token}}).Results[0]; result.Decision != DecisionKeep {
+		t.Fatalf("expected keep 2s after planning, got %+v", result)
+	}
+	clock.Advance(time.Duration(pendingActionTTLMS) * time.Millisecond)
+	if result := planner.CheckActions(models.ActionCheckRequest{Tokens: []string{token}}).Results[0]; result.Decision != DecisionDrop || result.Reason != "expired" {
+		t.Fatalf("expected expired after the TTL, got %+v", result)
+	}
+}
+
+// advancingLLM moves the fake clock forward by step on every call, so the
+// generation time comes out exact.
+type advancingLLM struct {
+	clock *fakeClock
+	step  time.Duration
+}
+
+func (advancingLLM) Enabled() bool { return true }
+
+func (a advancingLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	a.clock.Advance(a.step)
+	return "siema, co tam?", nil
+}
+
+func (advancingLLM) Close() error { return nil }
+
+func TestGenerationTimeUsesClock(t *testing.T) {
+	for _, message := range []string{"siema", "nudzi mi sie"} {
+		t.Run(message, func(t *testing.T) {
+			clock := newFakeClock(testClockStart)
+			planner := NewPlanner(advancingLLM{clock: clock, step: 1500 * time.Millisecond}, Config{Clock: clock})
+			req := stateTestRequest("generation-clock", testClockStart.UnixMilli())
+			req.Chat[0].Message = message
+			resp := planner.Plan(context.Background(), req)
+			if len(resp.Actions) != 1 || resp.Debug.GenerationMS != 1500 {
+				t.Fatalf("expected one action generated in 1500ms by the fake clock, got %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func cooldownTestRequest(requestID string, timeMS int64, settings models.PlanSettings) models.PlanRequest {
+	settings.MaxActions = 1
+	settings.ReplyChance = 1
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    timeMS,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: timeMS - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings: settings,
+	}
+}
+
+func int64Ptr(value int64) *int64 { return &value }
+
+func boolPtr(value bool) *bool { return &value }
+
+func TestPlannerTopicCooldownSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		settings     models.PlanSettings
+		gapMS        int64
+		wantSuppress bool
+	}{
+		{name: "default cooldown", gapMS: 12000, wantSuppress: true},
+		{name: "default cooldown elapsed", gapMS: 16000, wantSuppress: false},
+		{name: "shorter global", settings: models.PlanSettings{TopicCooldownMS: int64Ptr(5000)}, gapMS: 12000, wantSuppress: false},
+		{name: "topic override wins over global", settings: models.PlanSettings{TopicCooldownMS: int64Ptr(5000), TopicCooldowns: map[string]int64{"greeting": 20000}}, gapMS: 12000, wantSuppress: true},
+		{name: "shorter topic override wins over global", settings: models.PlanSettings{TopicCooldownMS: int64Ptr(60000), TopicCooldowns: map[string]int64{"greeting": 10000}}, gapMS: 12000, wantSuppress: false},
+		{name: "other topic override ignored", settings: models.PlanSettings{TopicCooldowns: map[string]int64{"event": 1000}}, gapMS: 12000, wantSuppress: true},
+		{name: "zero global disables cooldown", settings: models.PlanSettings{TopicCooldownMS: int64Ptr(0)}, gapMS: 0, wantSuppress: false},
+		{name: "zero topic override disables cooldown", settings: models.PlanSettings{TopicCooldownMS: int64Ptr(60000), TopicCooldowns: map[string]int64{"greeting": 0}}, gapMS: 0, wantSuppress: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			start := int64(1712345000000)
+			first := planner.Plan(context.Background(), cooldownTestRequest("req-cooldown-1", start, tt.settings))
+			if len(first.Actions) != 1 {
+				t.Fatalf("expected first greeting, got %+v", first.Actions)
+			}
+			second := planner.Plan(context.Background(), cooldownTestRequest("req-cooldown-2", start+tt.gapMS, tt.settings))
+			suppressed := len(second.Actions) == 0 && second.Debug.SuppressedReplies == 1
+			if suppressed != tt.wantSuppress {
+				t.Fatalf("suppressed = %t, want %t (actions %+v, debug %+v)", suppressed, tt.wantSuppress, second.Actions, second.Debug)
+			}
+		})
+	}
+}
+
+func TestPlanSettingsAcceptsKebabCaseCooldowns(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "snake", body: `{"topic_cooldown_ms":5000,"topic_cooldowns":{"greeting":10000}}`},
+		{name: "kebab", body: `{"topic-cooldown-ms":5000,"topic-cooldowns":{"greeting":10000}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settings models.PlanSettings
+			if err := json.Unmarshal([]byte(tt.body), &settings); err != nil {
+				t.Fatalf("Unmarshal() error: %v", err)
+			}
+			if got := topicCooldown(settings, TopicGreeting, defaultTopicKeywords); got != 10000 {
+				t.Fatalf("greeting cooldown = %d, want 10000", got)
+			}
+			if got := topicCooldown(settings, TopicHelp, defaultTopicKeywords); got != 5000 {
+				t.Fatalf("help cooldown = %d, want 5000", got)
+			}
+		})
+	}
+}
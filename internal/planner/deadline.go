@@ -0,0 +1,54 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"aichatplayers/internal/models"
+)
+
+const deadlineExceededReason = "deadline_exceeded"
+
+// planDeadline bounds the reply generation of one plan. Its context is a
+// child of the request context, so an HTTP request timeout that fires first
+// counts as the deadline too.
+type planDeadline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	hit     bool
+	skipped map[string]bool
+}
+
+// newPlanDeadline uses settings.plan_deadline_ms, falling back to
+// PLAN_DEADLINE_MS; with neither set only the request context applies.
+func (p *Planner) newPlanDeadline(ctx context.Context, settings models.PlanSettings) *planDeadline {
+	deadlineMS := settings.PlanDeadlineMS
+	if deadlineMS <= 0 {
+		deadlineMS = p.tuning.Load().planDeadlineMS
+	}
+	d := &planDeadline{ctx: ctx, cancel: func() {}, skipped: make(map[string]bool)}
+	if deadlineMS > 0 {
+		d.ctx, d.cancel = context.WithTimeout(ctx, time.Duration(deadlineMS)*time.Millisecond)
+	}
+	return d
+}
+
+// exceeded reports whether the deadline has passed and remembers that it
+// cut the plan short.
+func (d *planDeadline) exceeded() bool {
+	if errors.Is(d.ctx.Err(), context.DeadlineExceeded) {
+		d.hit = true
+	}
+	return d.hit
+}
+
+// skip records a bot left without a reply because of the deadline.
+func (d *planDeadline) skip(botID string) {
+	d.skipped[botID] = true
+}
+
+func (d *planDeadline) fill(debug *models.PlanDebug) {
+	debug.DeadlineExceeded = d.hit
+	debug.SkippedBots = len(d.skipped)
+}
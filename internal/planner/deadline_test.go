@@ -0,0 +1,83 @@
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/models"
+)
+
+func deadlineTestRequest(bots int) models.PlanRequest {
+	req := models.PlanRequest{
+		RequestID: "req-deadline",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: bots, ReplyChance: 1},
+	}
+	for _, id := range []string{"bot-1", "bot-2", "bot-3", "bot-4", "bot-5"}[:bots] {
+		req.Bots = append(req.Bots, models.BotProfile{BotID: id})
+		req.RequiredBotIDs = append(req.RequiredBotIDs, id)
+	}
+	return req
+}
+
+func TestPlanDeadlineReturnsPartialResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		deadlineMS  int64
+		wantActions int
+		wantSkipped int
+	}{
+		{name: "request setting", cfg: Config{LLMTimeout: time.Minute}, deadlineMS: 100, wantActions: planWorkers, wantSkipped: 1},
+		{name: "config default", cfg: Config{LLMTimeout: time.Minute, PlanDeadline: 100 * time.Millisecond}, wantActions: planWorkers, wantSkipped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(latentLLM{delay: time.Minute}, tt.cfg)
+			req := deadlineTestRequest(planWorkers + 1)
+			req.Settings.PlanDeadlineMS = tt.deadlineMS
+
+			start := time.Now()
+			resp := planner.Plan(context.Background(), req)
+			if elapsed := time.Since(start); elapsed >= time.Second {
+				t.Fatalf("plan blocked for %s past its deadline", elapsed)
+			}
+			if len(resp.Actions) != tt.wantActions {
+				t.Fatalf("expected %d partial actions, got %+v", tt.wantActions, resp.Actions)
+			}
+			if !resp.Debug.DeadlineExceeded || resp.Debug.SkippedBots != tt.wantSkipped {
+				t.Fatalf("debug = %+v, want deadline_exceeded with %d skipped bots", resp.Debug, tt.wantSkipped)
+			}
+			if len(resp.Debug.RequiredFailures) != 1 || resp.Debug.RequiredFailures[0].Reason != deadlineExceededReason {
+				t.Fatalf("required failures = %+v, want one %s", resp.Debug.RequiredFailures, deadlineExceededReason)
+			}
+		})
+	}
+}
+
+func TestPlanDeadlineFollowsRequestContext(t *testing.T) {
+	planner := NewPlanner(latentLLM{delay: time.Minute}, Config{LLMTimeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	resp := planner.Plan(ctx, deadlineTestRequest(planWorkers+1))
+	if !resp.Debug.DeadlineExceeded || resp.Debug.SkippedBots != 1 {
+		t.Fatalf("debug = %+v, want the request timeout reported as deadline_exceeded", resp.Debug)
+	}
+}
+
+func TestPlanWithinDeadlineIsNotMarked(t *testing.T) {
+	planner := NewPlanner(latentLLM{delay: 10 * time.Millisecond}, Config{LLMTimeout: time.Minute})
+	req := deadlineTestRequest(2)
+	req.Settings.PlanDeadlineMS = 5000
+
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 2 || resp.Debug.DeadlineExceeded || resp.Debug.SkippedBots != 0 {
+		t.Fatalf("expected a complete plan without deadline markers, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+}
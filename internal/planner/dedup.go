@@ -0,0 +1,140 @@
+package planner
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+const (
+	planDedupSize = 512
+	planDedupTTL  = time.Minute
+)
+
+// planDedup remembers recent plan responses by server and request seed
+// (request_id, tick, time_ms, chat and bots; see SeedForRequest), so a
+// plugin retrying after a network timeout gets the plan it already has
+// instead of a second one with the same lines. A plugin that reuses an ID
+// for a later tick or new chat still gets a new plan. A retry that arrives
+// while the first plan is still running waits for it.
+type planDedup struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key      string
+	done     chan struct{}
+	response models.PlanResponse
+	expires  time.Time
+	// failed marks a plan that panicked; its waiters plan again.
+	failed bool
+}
+
+func newPlanDedup(now func() time.Time) *planDedup {
+	return &planDedup{
+		ttl:     planDedupTTL,
+		size:    planDedupSize,
+		now:     now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// do returns the stored response for key or runs plan and stores its
+// result. The bool reports a deduplicated answer; the error is set when ctx
+// ended while waiting for the first plan. A plan that panics leaves no entry
+// behind, so the next call with key plans again.
+func (d *planDedup) do(ctx context.Context, key string, plan func() models.PlanResponse) (models.PlanResponse, bool, error) {
+	d.mu.Lock()
+	if element, ok := d.entries[key]; ok {
+		entry := element.Value.(*dedupEntry)
+		if entry.expires.IsZero() || d.now().Before(entry.expires) {
+			d.order.MoveToFront(element)
+			d.mu.Unlock()
+			select {
+			case <-entry.done:
+				if entry.failed {
+					return d.do(ctx, key, plan)
+				}
+				return entry.response, true, nil
+			case <-ctx.Done():
+				return models.PlanResponse{}, true, ctx.Err()
+			}
+		}
+		d.order.Remove(element)
+		delete(d.entries, key)
+	}
+	entry := &dedupEntry{key: key, done: make(chan struct{})}
+	d.entries[key] = d.order.PushFront(entry)
+	d.evictLocked()
+	d.mu.Unlock()
+
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		d.mu.Lock()
+		if element, ok := d.entries[key]; ok && element.Value == entry {
+			d.order.Remove(element)
+			delete(d.entries, key)
+		}
+		entry.failed = true
+		d.mu.Unlock()
+		close(entry.done)
+	}()
+	response := plan()
+	d.mu.Lock()
+	entry.response = response
+	entry.expires = d.now().Add(d.ttl)
+	d.mu.Unlock()
+	completed = true
+	close(entry.done)
+	return response, false, nil
+}
+
+// evictLocked drops the least recently used finished entries over the size
+// limit; plans still running are kept so their waiters get the answer.
+func (d *planDedup) evictLocked() {
+	for element := d.order.Back(); element != nil && d.order.Len() > d.size; {
+		previous := element.Prev()
+		if entry := element.Value.(*dedupEntry); !entry.expires.IsZero() {
+			d.order.Remove(element)
+			delete(d.entries, entry.key)
+		}
+		element = previous
+	}
+}
+
+// dedupPlan answers a repeated request_id from the dedup cache. Dry runs
+// and requests without an ID are always planned.
+func (p *Planner) dedupPlan(ctx context.Context, req models.PlanRequest, plan func() models.PlanResponse) models.PlanResponse {
+	if req.RequestID == "" || req.DryRun {
+		return plan()
+	}
+	serverID := req.Server.ServerID
+	if serverID == "" {
+		serverID = "default"
+	}
+	key := fmt.Sprintf("%s\x00%d", serverID, SeedForRequest(req))
+	response, deduplicated, err := p.dedup.do(ctx, key, plan)
+	if err != nil {
+		logging.Ctx(ctx).Infof("plan_request_deduplicated request_id=%s transaction_id=%s server_id=%s error=%v", req.RequestID, req.RequestID, serverID, err)
+		return models.PlanResponse{RequestID: req.RequestID, Debug: models.PlanDebug{ChosenStrategy: "deduplicated" + cancelledSuffix}}
+	}
+	if deduplicated {
+		logging.Ctx(ctx).Infof("plan_request_deduplicated request_id=%s transaction_id=%s server_id=%s actions=%d", req.RequestID, req.RequestID, serverID, len(response.Actions))
+	}
+	return response
+}
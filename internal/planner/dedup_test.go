@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+// forgetPlans clears the dedup cache, so a test can plan the same request
+// (and seed) twice.
+func forgetPlans(p *Planner) {
+	p.dedup = newPlanDedup(p.clock.Now)
+}
+
+type countingLLM struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (c *countingLLM) Enabled() bool { return true }
+
+func (c *countingLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	c.calls.Add(1)
+	time.Sleep(c.delay)
+	return "siema, co tam?", nil
+}
+
+func (c *countingLLM) Close() error { return nil }
+
+func dedupTestRequest(requestID string) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+		Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlanDeduplicatesRepeatedRequestID(t *testing.T) {
+	generator := &countingLLM{}
+	planner := NewPlanner(generator, Config{})
+	first := planner.Plan(context.Background(), dedupTestRequest("retry-1"))
+	second := planner.Plan(context.Background(), dedupTestRequest("retry-1"))
+	if len(first.Actions) != 1 || !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical responses, got %+v and %+v", first, second)
+	}
+	if calls := generator.calls.Load(); calls != 1 {
+		t.Fatalf("llm calls = %d, want 1", calls)
+	}
+
+	later := dedupTestRequest("retry-1")
+	later.TimeMS += 60000
+	if resp := planner.Plan(context.Background(), later); generator.calls.Load() != 2 || reflect.DeepEqual(resp, first) {
+		t.Fatalf("an ID reused on a later tick should be planned again, got %+v", resp)
+	}
+	anonymous := dedupTestRequest("")
+	anonymous.Settings.TopicCooldowns = map[string]int64{string(TopicGreeting): 0}
+	planner.Plan(context.Background(), anonymous)
+	planner.Plan(context.Background(), anonymous)
+	if calls := generator.calls.Load(); calls != 4 {
+		t.Fatalf("llm calls = %d, want 4: requests without an ID must not be deduplicated", calls)
+	}
+}
+
+func TestPlanDeduplicatesConcurrentRetries(t *testing.T) {
+	generator := &countingLLM{delay: 20 * time.Millisecond}
+	planner := NewPlanner(generator, Config{})
+	responses := make([]models.PlanResponse, 8)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = planner.Plan(context.Background(), dedupTestRequest("retry-concurrent"))
+		}(i)
+	}
+	wg.Wait()
+	if calls := generator.calls.Load(); calls != 1 {
+		t.Fatalf("llm calls = %d, want 1", calls)
+	}
+	for i := range responses {
+		if !reflect.DeepEqual(responses[i], responses[0]) {
+			t.Fatalf("response %d differs: %+v vs %+v", i, responses[i], responses[0])
+		}
+	}
+}
+
+func TestPlanDedupExpiresAndEvicts(t *testing.T) {
+	now := time.Unix(1712345000, 0)
+	dedup := newPlanDedup(func() time.Time { return now })
+	dedup.size = 2
+	plans := 0
+	plan := func() models.PlanResponse {
+		plans++
+		return models.PlanResponse{}
+	}
+	dedup.do(context.Background(), "a", plan)
+	if _, deduplicated, _ := dedup.do(context.Background(), "a", plan); !deduplicated || plans != 1 {
+		t.Fatalf("expected a cache hit, plans = %d", plans)
+	}
+	now = now.Add(planDedupTTL)
+	if _, deduplicated, _ := dedup.do(context.Background(), "a", plan); deduplicated || plans != 2 {
+		t.Fatalf("expected the entry to expire, plans = %d", plans)
+	}
+	dedup.do(context.Background(), "b", plan)
+	dedup.do(context.Background(), "c", plan)
+	if _, deduplicated, _ := dedup.do(context.Background(), "a", plan); deduplicated {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+}
+
+func TestPlanDedupForgetsPanickedPlan(t *testing.T) {
+	dedup := newPlanDedup(time.Now)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the plan panic to propagate")
+			}
+		}()
+		dedup.do(context.Background(), "srv-1", func() models.PlanResponse { panic("boom") })
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	want := models.PlanResponse{RequestID: "retry"}
+	got, deduplicated, err := dedup.do(ctx, "srv-1", func() models.PlanResponse { return want })
+	if err != nil || deduplicated || got.RequestID != want.RequestID {
+		t.Fatalf("expected a fresh plan after the panic, got %+v deduplicated=%t err=%v", got, deduplicated, err)
+	}
+}
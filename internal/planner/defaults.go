@@ -0,0 +1,110 @@
+package planner
+
+import "aichatplayers/internal/models"
+
+// RegisterDefaultSettings stores the settings a server's plan requests fall
+// back to for every field they leave unset.
+func (p *Planner) RegisterDefaultSettings(serverID string, settings models.PlanSettings) {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.serverSettings[serverID] = settings
+}
+
+// serverDefaults fills the unset fields of settings from the server's
+// registered defaults; normalizeSettings then supplies the built-in ones.
+func (p *Planner) serverDefaults(serverID string, settings models.PlanSettings) models.PlanSettings {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defaults, ok := p.serverSettings[serverID]
+	p.mu.Unlock()
+	if !ok {
+		return settings
+	}
+	return mergeSettings(settings, defaults)
+}
+
+// mergeSettings returns settings with every zero field taken from defaults.
+// A request cannot switch a registered allow_whispers back off or zero a
+// registered chance; it has to register different defaults instead.
+func mergeSettings(settings, defaults models.PlanSettings) models.PlanSettings {
+	if settings.MaxActions == 0 {
+		settings.MaxActions = defaults.MaxActions
+	}
+	if settings.MinDelayMS == 0 {
+		settings.MinDelayMS = defaults.MinDelayMS
+	}
+	if settings.MaxDelayMS == 0 {
+		settings.MaxDelayMS = defaults.MaxDelayMS
+	}
+	if settings.GlobalSilenceChance == 0 {
+		settings.GlobalSilenceChance = defaults.GlobalSilenceChance
+	}
+	if settings.ReplyChance == 0 {
+		settings.ReplyChance = defaults.ReplyChance
+	}
+	if !settings.AllowWhispers {
+		settings.AllowWhispers = defaults.AllowWhispers
+	}
+	if settings.BanterChance == 0 {
+		settings.BanterChance = defaults.BanterChance
+	}
+	if settings.CatchphraseChance == nil {
+		settings.CatchphraseChance = defaults.CatchphraseChance
+	}
+	if settings.UninterestedWeight == 0 {
+		settings.UninterestedWeight = defaults.UninterestedWeight
+	}
+	if settings.SelectionStrategy == "" {
+		settings.SelectionStrategy = defaults.SelectionStrategy
+	}
+	if settings.TopicCooldownMS == nil {
+		settings.TopicCooldownMS = defaults.TopicCooldownMS
+	}
+	if len(defaults.TopicCooldowns) > 0 {
+		cooldowns := make(map[string]int64, len(defaults.TopicCooldowns)+len(settings.TopicCooldowns))
+		for topic, cooldown := range defaults.TopicCooldowns {
+			cooldowns[topic] = cooldown
+		}
+		for topic, cooldown := range settings.TopicCooldowns {
+			cooldowns[topic] = cooldown
+		}
+		settings.TopicCooldowns = cooldowns
+	}
+	if settings.Quiet == nil {
+		settings.Quiet = defaults.Quiet
+	}
+	if settings.MaxMessageAgeMS == 0 {
+		settings.MaxMessageAgeMS = defaults.MaxMessageAgeMS
+	}
+	if settings.PlanDeadlineMS == 0 {
+		settings.PlanDeadlineMS = defaults.PlanDeadlineMS
+	}
+	if settings.MaxBotMessagesPerMinute == 0 {
+		settings.MaxBotMessagesPerMinute = defaults.MaxBotMessagesPerMinute
+	}
+	switch {
+	case settings.LLM == nil:
+		settings.LLM = defaults.LLM
+	case defaults.LLM != nil:
+		llm := *settings.LLM
+		if llm.Temperature == nil {
+			llm.Temperature = defaults.LLM.Temperature
+		}
+		if llm.TopP == nil {
+			llm.TopP = defaults.LLM.TopP
+		}
+		if llm.MaxTokens == 0 {
+			llm.MaxTokens = defaults.LLM.MaxTokens
+		}
+		if llm.ChatHistoryLimit == 0 {
+			llm.ChatHistoryLimit = defaults.LLM.ChatHistoryLimit
+		}
+		settings.LLM = &llm
+	}
+	return settings
+}
@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestPlanSettingsPrecedence(t *testing.T) {
+	registered := models.PlanSettings{MaxActions: 1, MinDelayMS: 5000, MaxDelayMS: 6000, ReplyChance: 1}
+	tests := []struct {
+		name        string
+		registered  *models.PlanSettings
+		settings    models.PlanSettings
+		wantActions int
+		wantMinMS   int64
+	}{
+		{name: "built-in defaults", settings: models.PlanSettings{ReplyChance: 1}, wantActions: 2, wantMinMS: 800},
+		{name: "registered defaults", registered: &registered, wantActions: 1, wantMinMS: 5000},
+		{name: "request wins field by field", registered: &registered, settings: models.PlanSettings{MaxActions: 3}, wantActions: 3, wantMinMS: 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			if tt.registered != nil {
+				planner.RegisterDefaultSettings("srv-1", *tt.registered)
+			}
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "req-defaults",
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1"}, {BotID: "bot-2"}, {BotID: "bot-3"}},
+				Chat: []models.ChatMessage{
+					{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+				},
+				Settings: tt.settings,
+			})
+			if len(resp.Actions) != tt.wantActions {
+				t.Fatalf("expected %d actions, got %+v", tt.wantActions, resp.Actions)
+			}
+			for _, action := range resp.Actions {
+				if action.SendAfterMS < tt.wantMinMS {
+					t.Fatalf("send_after_ms = %d, want >= %d", action.SendAfterMS, tt.wantMinMS)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeSettingsKeepsRequestValues(t *testing.T) {
+	quiet := false
+	temperature := 0.2
+	defaults := models.PlanSettings{
+		ReplyChance:    0.5,
+		AllowWhispers:  true,
+		TopicCooldowns: map[string]int64{"greeting": 1000, "help": 2000},
+		Quiet:          &quiet,
+		LLM:            &models.LLMSettings{Temperature: &temperature, MaxTokens: 40},
+	}
+	got := mergeSettings(models.PlanSettings{
+		ReplyChance:    0.9,
+		TopicCooldowns: map[string]int64{"help": 0},
+		LLM:            &models.LLMSettings{MaxTokens: 80},
+	}, defaults)
+
+	if got.ReplyChance != 0.9 || !got.AllowWhispers || got.Quiet == nil || *got.Quiet {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+	if got.TopicCooldowns["greeting"] != 1000 || got.TopicCooldowns["help"] != 0 {
+		t.Fatalf("topic cooldowns = %v", got.TopicCooldowns)
+	}
+	if got.LLM.MaxTokens != 80 || got.LLM.Temperature == nil || *got.LLM.Temperature != temperature {
+		t.Fatalf("llm settings = %+v", got.LLM)
+	}
+	if defaults.LLM.MaxTokens != 40 || len(defaults.TopicCooldowns) != 2 || defaults.TopicCooldowns["help"] != 2000 {
+		t.Fatalf("merge modified the registered defaults: %+v", defaults)
+	}
+}
@@ -0,0 +1,40 @@
+package planner
+
+import (
+	"context"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+// llmUnavailableState is the LLM state after its client failed to start.
+const llmUnavailableState = "unavailable"
+
+// MarkLLMUnavailable records that the LLM client could not be started, so
+// heuristic plans are reported as degraded rather than intended.
+func (p *Planner) MarkLLMUnavailable() {
+	p.llmUnavailable.Store(true)
+}
+
+// markDegraded flags a response whose actions all came from heuristics
+// because the LLM is down: its client failed to start, its breaker is open
+// or every LLM attempt of this plan failed. A disabled LLM is intended and
+// never degrades a plan.
+func (p *Planner) markDegraded(ctx context.Context, response *models.PlanResponse) {
+	if len(response.Actions) == 0 {
+		return
+	}
+	for _, action := range response.Actions {
+		if action.Source == SourceLLM {
+			return
+		}
+	}
+	state := p.LLMState()
+	allFailed := response.Debug.LLMAttempts > 0 && response.Debug.LLMFailures == response.Debug.LLMAttempts
+	if !allFailed && state != llmUnavailableState && state != llm.BreakerOpen {
+		return
+	}
+	response.Debug.Degraded = true
+	logging.Ctx(ctx).Warnf("planner_plan_degraded request_id=%s transaction_id=%s llm_state=%s llm_attempts=%d llm_failures=%d actions=%d", response.RequestID, response.RequestID, state, response.Debug.LLMAttempts, response.Debug.LLMFailures, len(response.Actions))
+}
@@ -0,0 +1,33 @@
+package planner
+
+import (
+	"fmt"
+	"math/rand"
+
+	"aichatplayers/internal/models"
+)
+
+// dryRunReply returns a placeholder for the reply the heuristics would
+// give, together with their reason; the LLM is never asked in a dry run.
+func (p *Planner) dryRunReply(topic Topic, bot models.BotProfile, server models.ServerContext, chat []models.ChatMessage, rng *rand.Rand) (string, models.Reason) {
+	_, reason := generateResponse(topic, bot, server, topicSender(chat, topic, p.topicKeywords()), chat, p.topicKeywords(), rng)
+	if reason == "" {
+		return "", ""
+	}
+	label := string(topic)
+	if topic == "" {
+		label = "small_talk"
+	}
+	return dryRunMessage(label), reason
+}
+
+func dryRunMessage(label string) string {
+	return fmt.Sprintf("[dry-run %s reply]", label)
+}
+
+func markDryRun(response *models.PlanResponse) {
+	response.Debug.DryRun = true
+	for i := range response.Actions {
+		response.Actions[i].Reason = models.ReasonDryRunPrefix + response.Actions[i].Reason
+	}
+}
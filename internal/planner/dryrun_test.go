@@ -0,0 +1,43 @@
+package planner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestDryRunSkipsLLMAndState(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{})
+	req := models.PlanRequest{
+		RequestID: "dry",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+		Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+		DryRun:    true,
+	}
+
+	first := planner.Plan(context.Background(), req)
+	if !first.Debug.DryRun || len(first.Actions) != 1 {
+		t.Fatalf("expected one dry-run action, got %+v", first)
+	}
+	action := first.Actions[0]
+	if action.Message != "[dry-run greeting reply]" || action.Reason != "dry_run_greeting" || action.ActionToken != "" {
+		t.Fatalf("unexpected dry-run action %+v", action)
+	}
+	if len(generator.requests) != 0 {
+		t.Fatalf("dry run must not call the LLM, got %d calls", len(generator.requests))
+	}
+
+	if second := planner.Plan(context.Background(), req); len(second.Actions) != 1 || second.Actions[0] != action {
+		t.Fatalf("dry run should not start cooldowns, got %+v", second)
+	}
+	req.DryRun = false
+	if live := planner.Plan(context.Background(), req); len(live.Actions) != 1 || live.Debug.DryRun || strings.HasPrefix(string(live.Actions[0].Reason), models.ReasonDryRunPrefix) {
+		t.Fatalf("expected a normal plan after dry runs, got %+v", live)
+	}
+}
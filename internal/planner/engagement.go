@@ -0,0 +1,168 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+const defaultEngagementCooldown = 10 * time.Minute
+
+func (p *Planner) Engage(ctx context.Context, req models.EngagementRequest) models.PlanResponse {
+	ctx = llm.WithBackendTrace(ctx)
+	metrics.PlanRequests.Inc()
+	logging.Ctx(ctx).Infof("planner_engage_start request_id=%s transaction_id=%s server_id=%s target_player=%s time_ms=%d bots=%d", req.RequestID, req.RequestID, req.Server.ServerID, req.TargetPlayer, req.TimeMS, len(req.Bots))
+	var truncated int
+	req.Chat, truncated = sanitizeChat(req.Chat, p.tuning.Load().chatMaxChars)
+	rng := newRequestRand(requestSeed(req.RequestID, req.Tick, req.TimeMS, req.Chat, req.Bots, "engage"))
+	settings := normalizeSettings(req.Settings)
+	req.Settings.LLM = settings.LLM
+	bots := p.enrichBots(req.Server.ServerID, req.Bots)
+	available, cooldownSkipped := filterAvailableBots(bots, settings)
+
+	silence := func(reason string) models.PlanResponse {
+		logging.Ctx(ctx).Infof("planner_engage_silence request_id=%s transaction_id=%s reason=%s", req.RequestID, req.RequestID, reason)
+		metrics.SilenceDecisions.Inc(reason)
+		return models.PlanResponse{
+			RequestID: req.RequestID,
+			Debug:     models.PlanDebug{ChosenStrategy: reason, CooldownSkipped: cooldownSkipped, TruncatedMessages: truncated},
+		}
+	}
+
+	if p.quiet(req.Settings) {
+		return silence(quietHoursReason)
+	}
+	target := strings.TrimSpace(req.TargetPlayer)
+	if target == "" {
+		return silence("no_target")
+	}
+	eligible := make([]models.BotProfile, 0, len(available))
+	for _, bot := range available {
+		if !strings.EqualFold(bot.Name, target) && !strings.EqualFold(bot.BotID, target) {
+			eligible = append(eligible, bot)
+		}
+	}
+	if len(eligible) == 0 {
+		return silence("no_available_bots")
+	}
+	if p.engagedRecently(req.Server.ServerID, target, req.TimeMS) {
+		return silence("engagement_cooldown")
+	}
+
+	bot := p.newBotSelector(req.Server.ServerID, settings, req.TimeMS).pick(eligible, 1, rng)[0]
+	var stats generationStats
+	start := p.clock.Now()
+	message, llmAttempted, llmUsed := p.generateEngagement(ctx, req, bot, target, rng)
+	source, generationMS := stats.observe(p.clock.Now().Sub(start), llmAttempted, llmUsed)
+	if message == "" {
+		return silence("no_message")
+	}
+	message = p.styleMessage(settings, bot, message, &stats, rng)
+	actions := []models.PlannedAction{{
+		BotID:        bot.BotID,
+		SendAfterMS:  randomDelay(settings, bot.CooldownMS, rng),
+		Message:      message,
+		Visibility:   visibilityPublic,
+		Reason:       models.ReasonEngagement,
+		Source:       source,
+		GenerationMS: generationMS,
+	}}
+	p.rememberEngagement(req.Server.ServerID, target, req.TimeMS)
+	p.rememberAction(req.Server.ServerID, bot.BotID, req.TimeMS)
+	p.rememberMessage(req.Server.ServerID, bot.BotID, message)
+	metrics.ActionsEmitted.Add(len(actions))
+	p.trackPendingActions(models.PlanRequest{TimeMS: req.TimeMS, Chat: req.Chat}, actions)
+
+	strategy := strategyLabel(string(models.ReasonEngagement), llmAttempted, llmUsed)
+	if ctx.Err() != nil {
+		strategy += cancelledSuffix
+	}
+	logging.Ctx(ctx).Infof("planner_engage_result request_id=%s transaction_id=%s bot_id=%s target_player=%s strategy=%s", req.RequestID, req.RequestID, bot.BotID, target, strategy)
+	response := models.PlanResponse{
+		RequestID: req.RequestID,
+		Actions:   actions,
+		Debug: models.PlanDebug{
+			ChosenStrategy:    strategy,
+			CooldownSkipped:   cooldownSkipped,
+			TruncatedMessages: truncated,
+			LLMSettings:       p.effectiveLLMSettings(settings.LLM),
+		},
+	}
+	stats.fill(&response.Debug)
+	response.Debug.LLMBackends = llm.BackendsUsed(ctx)
+	p.markDegraded(ctx, &response)
+	return response
+}
+
+func (p *Planner) generateEngagement(ctx context.Context, req models.EngagementRequest, bot models.BotProfile, target string, rng *rand.Rand) (string, bool, bool) {
+	if p.llm != nil && p.llm.Enabled() {
+		var cancel context.CancelFunc
+		if p.tuning.Load().llmTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.tuning.Load().llmTimeout)
+			defer cancel()
+		}
+		message, err := p.generateLLM(ctx, llm.Request{
+			Server:       req.Server,
+			Bot:          bot,
+			RecentChat:   recentChat(req.Chat, p.historyLimit(req.Settings)),
+			EngageTarget: target,
+			EngageHint:   req.ExamplePrompt,
+			Sampling:     req.Settings.LLM,
+		})
+		if err != nil {
+			logging.Ctx(ctx).Warnf("planner_llm_error request_id=%s transaction_id=%s bot_id=%s topic=engagement error=%v", req.RequestID, req.RequestID, bot.BotID, err)
+		} else if p.sentRecently(req.Server.ServerID, bot.BotID, message) {
+			logging.Ctx(ctx).Infof("planner_llm_repeat request_id=%s transaction_id=%s bot_id=%s topic=engagement", req.RequestID, req.RequestID, bot.BotID)
+		} else if p.profaneOutput(ctx, req.RequestID, bot, message) {
+			return "", true, false
+		} else if message != "" && !p.avoidedOutput(ctx, req.RequestID, bot, message) {
+			return message, true, true
+		}
+		metrics.HeuristicFallbacks.Inc()
+		return p.engagementTemplate(req.Server.ServerID, bot, target, rng), true, false
+	}
+	return p.engagementTemplate(req.Server.ServerID, bot, target, rng), false, false
+}
+
+func (p *Planner) engagementTemplate(serverID string, bot models.BotProfile, target string, rng *rand.Rand) string {
+	templates := templatesFor(bot.Persona.Language)
+	for attempt := 0; attempt < maxTemplateRepicks; attempt++ {
+		message := fmt.Sprintf(pickTemplate(templates.Engagement, rng), target)
+		if !p.sentRecently(serverID, bot.BotID, message) {
+			return message
+		}
+	}
+	return ""
+}
+
+func (p *Planner) engagedRecently(serverID, target string, nowMS int64) bool {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	last, ok := p.engaged[serverID][util.NormalizeText(target)]
+	return ok && nowMS-last < p.tuning.Load().engageCooldownMS
+}
+
+func (p *Planner) rememberEngagement(serverID, target string, nowMS int64) {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.engaged[serverID] == nil {
+		p.engaged[serverID] = make(map[string]int64)
+	}
+	p.engaged[serverID][util.NormalizeText(target)] = nowMS
+}
@@ -0,0 +1,102 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+func engagementTestRequest(requestID string, timeMS int64, target string) models.EngagementRequest {
+	return models.EngagementRequest{
+		RequestID:     requestID,
+		Server:        models.ServerContext{ServerID: "srv-1"},
+		TimeMS:        timeMS,
+		Bots:          []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}},
+		TargetPlayer:  target,
+		ExamplePrompt: "zapytaj co buduje",
+	}
+}
+
+func TestEngageUsesLLMWithTargetPrompt(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{})
+	resp := planner.Engage(context.Background(), engagementTestRequest("eng-1", 1712345000000, "RealPlayer123"))
+
+	if len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonEngagement {
+		t.Fatalf("expected one engagement action, got %+v", resp.Actions)
+	}
+	if resp.Debug.ChosenStrategy != "llm" {
+		t.Fatalf("strategy = %s, want llm", resp.Debug.ChosenStrategy)
+	}
+	if len(generator.requests) != 1 || generator.requests[0].EngageTarget != "RealPlayer123" || generator.requests[0].EngageHint != "zapytaj co buduje" {
+		t.Fatalf("unexpected llm requests %+v", generator.requests)
+	}
+}
+
+func TestEngageFallsBackToTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator LLMGenerator
+		strategy  string
+	}{
+		{name: "llm error", generator: fakeLLM{enabled: true, err: errors.New("boom")}, strategy: "engagement_fallback"},
+		{name: "llm disabled", generator: noopLLM{}, strategy: "engagement"},
+		{name: "llm under pressure", generator: pressuredLLM{fakeLLM: fakeLLM{enabled: true, err: errors.New("boom")}, stats: llm.Stats{Queued: 10}}, strategy: "engagement_fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(tt.generator, Config{PressureQueueDepth: 1})
+			resp := planner.Engage(context.Background(), engagementTestRequest("eng-2", 1712345000000, "Steve"))
+			if len(resp.Actions) != 1 || !strings.Contains(strings.ToLower(resp.Actions[0].Message), "steve") {
+				t.Fatalf("expected a template addressing Steve, got %+v", resp.Actions)
+			}
+			if resp.Debug.ChosenStrategy != tt.strategy {
+				t.Fatalf("strategy = %s, want %s", resp.Debug.ChosenStrategy, tt.strategy)
+			}
+		})
+	}
+}
+
+func TestEngageRespectsPerTargetCooldown(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{EngagementCooldown: time.Minute})
+	start := int64(1712345000000)
+
+	steps := []struct {
+		timeMS      int64
+		target      string
+		wantActions int
+	}{
+		{timeMS: start, target: "Steve", wantActions: 1},
+		{timeMS: start + 30000, target: "steve", wantActions: 0},
+		{timeMS: start + 30000, target: "Alex", wantActions: 1},
+		{timeMS: start + 61000, target: "Steve", wantActions: 1},
+	}
+	for i, step := range steps {
+		resp := planner.Engage(context.Background(), engagementTestRequest("eng-cooldown", step.timeMS, step.target))
+		if len(resp.Actions) != step.wantActions {
+			t.Fatalf("step %d: expected %d actions, got %+v (debug %+v)", i, step.wantActions, resp.Actions, resp.Debug)
+		}
+		if step.wantActions == 0 && resp.Debug.ChosenStrategy != "engagement_cooldown" {
+			t.Fatalf("step %d: strategy = %s, want engagement_cooldown", i, resp.Debug.ChosenStrategy)
+		}
+	}
+}
+
+func TestEngageSkipsTargetBotAndMissingTarget(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	if resp := planner.Engage(context.Background(), engagementTestRequest("eng-3", 1712345000000, "")); len(resp.Actions) != 0 || resp.Debug.ChosenStrategy != "no_target" {
+		t.Fatalf("expected no_target silence, got %+v", resp)
+	}
+	for i := 0; i < 10; i++ {
+		planner := NewPlanner(noopLLM{}, Config{})
+		resp := planner.Engage(context.Background(), engagementTestRequest("eng-self", 1712345000000+int64(i)*int64(time.Hour/time.Millisecond), "Kuba"))
+		if len(resp.Actions) != 1 || resp.Actions[0].BotID != "bot-2" {
+			t.Fatalf("run %d: expected bot-2 to engage Kuba, got %+v", i, resp.Actions)
+		}
+	}
+}
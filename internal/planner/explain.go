@@ -0,0 +1,140 @@
+package planner
+
+import (
+	"sync"
+
+	"aichatplayers/internal/models"
+)
+
+// Decision steps reported in debug.decisions.
+const (
+	decisionChat      = "chat"
+	decisionTopic     = "topic"
+	decisionBot       = "bot_filter"
+	decisionSilence   = "global_silence"
+	decisionReply     = "reply_chance"
+	decisionSelection = "selection"
+	decisionCandidate = "candidate"
+)
+
+// planExplainer collects debug.decisions for requests that set explain. A
+// nil explainer records nothing, so the planner calls it unconditionally.
+type planExplainer struct {
+	mu        sync.Mutex
+	decisions []models.PlanDecision
+}
+
+func newPlanExplainer(enabled bool) *planExplainer {
+	if !enabled {
+		return nil
+	}
+	return &planExplainer{}
+}
+
+func (e *planExplainer) add(decision models.PlanDecision) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.decisions = append(e.decisions, decision)
+}
+
+// roll records a random roll against the chance it was compared with.
+func (e *planExplainer) roll(step string, roll, threshold float64, outcome string) {
+	e.add(models.PlanDecision{Step: step, Roll: roll, Threshold: threshold, Outcome: outcome})
+}
+
+// candidate records what happened to one bot's reply to one topic.
+func (e *planExplainer) candidate(botID string, topic Topic, outcome string) {
+	e.add(models.PlanDecision{Step: decisionCandidate, BotID: botID, Topic: string(topic), Outcome: outcome})
+}
+
+// topics records the detected topics in order with their scores.
+func (e *planExplainer) topics(messages []models.ChatMessage, keywords *topicKeywords, topics []Topic) {
+	if e == nil {
+		return
+	}
+	scores, _ := scoreTopics(messages, keywords)
+	for _, topic := range topics {
+		e.add(models.PlanDecision{Step: decisionTopic, Topic: string(topic), Score: scores[topic], Outcome: "detected"})
+	}
+}
+
+// botFilters records why each requested bot is or is not available, from
+// the bot lists left after each filter of plan.
+func (e *planExplainer) botFilters(bots, online, notSelf, available []models.BotProfile) {
+	if e == nil {
+		return
+	}
+	kept := func(list []models.BotProfile, botID string) bool {
+		for _, bot := range list {
+			if bot.BotID == botID {
+				return true
+			}
+		}
+		return false
+	}
+	for _, bot := range bots {
+		outcome := "available"
+		switch {
+		case !bot.IsOnline():
+			outcome = "offline"
+		case !kept(online, bot.BotID):
+			outcome = "cooldown"
+		case !kept(notSelf, bot.BotID):
+			outcome = "self_reply"
+		case !kept(available, bot.BotID):
+			outcome = "rate_limited"
+		}
+		e.add(models.PlanDecision{Step: decisionBot, BotID: bot.BotID, Outcome: outcome})
+	}
+}
+
+// selection records which available bots got an action slot.
+func (e *planExplainer) selection(bots, selected []models.BotProfile) {
+	if e == nil {
+		return
+	}
+	chosen := make(map[string]bool, len(selected))
+	for _, bot := range selected {
+		chosen[bot.BotID] = true
+	}
+	for _, bot := range bots {
+		outcome := "not_selected"
+		if chosen[bot.BotID] {
+			outcome = "selected"
+		}
+		e.add(models.PlanDecision{Step: decisionSelection, BotID: bot.BotID, Outcome: outcome})
+	}
+}
+
+// generation records the outcome of a finished generation job.
+func (e *planExplainer) generation(job *generationJob) {
+	if e == nil {
+		return
+	}
+	outcome := "heuristic"
+	switch {
+	case job.rejected != "":
+		outcome = job.rejected
+	case job.message == "" && shouldAvoidTopic(job.topic, job.bot.Persona.AvoidTopics):
+		outcome = "avoid_topic"
+	case job.message == "":
+		outcome = "empty"
+	case job.used:
+		outcome = "llm_ok"
+	case job.attempted:
+		outcome = "llm_error"
+	}
+	e.candidate(job.bot.BotID, job.topic, outcome)
+}
+
+func (e *planExplainer) fill(debug *models.PlanDebug) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	debug.Decisions = append([]models.PlanDecision{}, e.decisions...)
+}
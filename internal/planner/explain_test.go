@@ -0,0 +1,88 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func explainTestRequest() models.PlanRequest {
+	offline := false
+	return models.PlanRequest{
+		RequestID: "req-explain",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots: []models.BotProfile{
+			{BotID: "bot-1", Name: "Kuba"},
+			{BotID: "bot-2", Name: "Ola", Online: &offline},
+			{BotID: "bot-3", Name: "Zosia", CooldownMS: 60000},
+			{BotID: "bot-4", Name: "Maja"},
+			{BotID: "bot-5", Name: "Olek"},
+		},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344998000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+			{TimestampMS: 1712344999000, Sender: "Maja", SenderType: "BOT", Message: "dobre pytanie"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 0.9, MinDelayMS: 800, MaxDelayMS: 2000},
+		Explain:  true,
+	}
+}
+
+// explainSnapshot is debug.decisions for explainTestRequest: one bot per
+// filter outcome, the help topic, the reply roll, the pick and the reply.
+const explainSnapshot = `[` +
+	`{"step":"bot_filter","bot_id":"bot-1","outcome":"available"},` +
+	`{"step":"bot_filter","bot_id":"bot-2","outcome":"offline"},` +
+	`{"step":"bot_filter","bot_id":"bot-3","outcome":"cooldown"},` +
+	`{"step":"bot_filter","bot_id":"bot-4","outcome":"self_reply"},` +
+	`{"step":"bot_filter","bot_id":"bot-5","outcome":"available"},` +
+	`{"step":"topic","topic":"help","score":0.5,"outcome":"detected"},` +
+	`{"step":"reply_chance","roll":0.05684032993522642,"threshold":0.9,"outcome":"reply"},` +
+	`{"step":"selection","bot_id":"bot-1","outcome":"not_selected"},` +
+	`{"step":"selection","bot_id":"bot-5","outcome":"selected"},` +
+	`{"step":"candidate","bot_id":"bot-5","topic":"help","outcome":"heuristic"}` +
+	`]`
+
+func TestPlanExplainDecisions(t *testing.T) {
+	resp := NewPlanner(nil, Config{}).Plan(context.Background(), explainTestRequest())
+	data, err := json.Marshal(resp.Debug.Decisions)
+	if err != nil {
+		t.Fatalf("marshal decisions: %v", err)
+	}
+	if string(data) != explainSnapshot {
+		t.Fatalf("decisions changed:\n got %s\nwant %s", data, explainSnapshot)
+	}
+}
+
+func TestPlanExplainReportsRepeatedReply(t *testing.T) {
+	planner := NewPlanner(fakeLLM{enabled: true, message: "przez obsydian"}, Config{})
+	planner.rememberMessage("srv-1", "bot-5", "przez obsydian")
+	resp := planner.Plan(context.Background(), explainTestRequest())
+	last := resp.Debug.Decisions[len(resp.Debug.Decisions)-1]
+	want := models.PlanDecision{Step: "candidate", BotID: "bot-5", Topic: "help", Outcome: "suppressed_by_memory"}
+	if last != want {
+		t.Fatalf("expected %+v, got %+v", want, last)
+	}
+}
+
+func TestPlanWithoutExplainHasNoDecisions(t *testing.T) {
+	req := explainTestRequest()
+	req.Explain = false
+	resp := NewPlanner(nil, Config{}).Plan(context.Background(), req)
+	if resp.Debug.Decisions != nil {
+		t.Fatalf("expected no decisions without explain, got %+v", resp.Debug.Decisions)
+	}
+	data, err := json.Marshal(resp.Debug)
+	if err != nil {
+		t.Fatalf("marshal debug: %v", err)
+	}
+	var debug map[string]any
+	if err := json.Unmarshal(data, &debug); err != nil {
+		t.Fatalf("unmarshal debug: %v", err)
+	}
+	if _, ok := debug["decisions"]; ok {
+		t.Fatalf("debug carries decisions without explain: %s", data)
+	}
+}
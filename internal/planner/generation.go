@@ -0,0 +1,49 @@
+package planner
+
+import (
+	"time"
+
+	"aichatplayers/internal/models"
+)
+
+const (
+	SourceLLM       = "llm"
+	SourceHeuristic = "heuristic"
+)
+
+// generationStats sums up message generation for one plan: how often the LLM
+// was tried, how often it gave nothing usable, how long generation took and
+// how many messages the style pass cut to the length cap.
+type generationStats struct {
+	attempts   int
+	failures   int
+	generation time.Duration
+	truncated  int
+}
+
+// observe records one generation that took elapsed and returns the action's
+// source and generation time.
+func (s *generationStats) observe(elapsed time.Duration, attempted, used bool) (string, int64) {
+	s.generation += elapsed
+	if attempted {
+		s.attempts++
+		if !used {
+			s.failures++
+		}
+	}
+	if used {
+		return SourceLLM, elapsed.Milliseconds()
+	}
+	return SourceHeuristic, elapsed.Milliseconds()
+}
+
+func (s *generationStats) fill(debug *models.PlanDebug) {
+	debug.LLMAttempts = s.attempts
+	debug.LLMFailures = s.failures
+	debug.GenerationMS = s.generation.Milliseconds()
+	debug.TruncatedMessages += s.truncated
+}
+
+func (s *generationStats) countTruncated() {
+	s.truncated++
+}
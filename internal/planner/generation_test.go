@@ -0,0 +1,67 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+type slowLLM struct {
+	delay   time.Duration
+	message string
+	err     error
+}
+
+func (s slowLLM) Enabled() bool { return true }
+
+func (s slowLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	time.Sleep(s.delay)
+	return s.message, s.err
+}
+
+func (s slowLLM) Close() error { return nil }
+
+func TestPlanReportsGenerationStats(t *testing.T) {
+	tests := []struct {
+		name         string
+		generator    LLMGenerator
+		wantSource   string
+		wantAttempts int
+		wantFailures int
+		wantTiming   bool
+	}{
+		{name: "llm", generator: slowLLM{delay: 20 * time.Millisecond, message: "siema siema"}, wantSource: SourceLLM, wantAttempts: 1, wantTiming: true},
+		{name: "llm failure", generator: slowLLM{delay: 20 * time.Millisecond, err: errors.New("boom")}, wantSource: SourceHeuristic, wantAttempts: 1, wantFailures: 1, wantTiming: true},
+		{name: "heuristics only", generator: noopLLM{}, wantSource: SourceHeuristic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(tt.generator, Config{})
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "generation-" + tt.name,
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+				Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+				Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+			})
+			if len(resp.Actions) != 1 {
+				t.Fatalf("expected one action, got %+v", resp)
+			}
+			action := resp.Actions[0]
+			if action.Source != tt.wantSource {
+				t.Fatalf("source = %q, want %q", action.Source, tt.wantSource)
+			}
+			if resp.Debug.LLMAttempts != tt.wantAttempts || resp.Debug.LLMFailures != tt.wantFailures {
+				t.Fatalf("llm_attempts=%d llm_failures=%d, want %d/%d", resp.Debug.LLMAttempts, resp.Debug.LLMFailures, tt.wantAttempts, tt.wantFailures)
+			}
+			if tt.wantTiming && (action.GenerationMS <= 0 || resp.Debug.GenerationMS < action.GenerationMS) {
+				t.Fatalf("expected generation timing, got action=%d total=%d", action.GenerationMS, resp.Debug.GenerationMS)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestPlayerName(t *testing.T) {
+	tests := []struct {
+		sender string
+		want   string
+	}{
+		{sender: "RealPlayer123", want: "RealPlayer123"},
+		{sender: "§cRed_Player&l", want: "Red_Player"},
+		{sender: "&6Gold Name!", want: "GoldName"},
+		{sender: "VeryLongPlayerName12345", want: "VeryLongPlayerNa"},
+		{sender: "[Server]", want: ""},
+		{sender: "CONSOLE", want: ""},
+		{sender: "  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := playerName(tt.sender); got != tt.want {
+			t.Fatalf("playerName(%q) = %q, want %q", tt.sender, got, tt.want)
+		}
+	}
+}
+
+func TestGreetingAddressesSender(t *testing.T) {
+	bot := models.BotProfile{BotID: "bot-1"}
+	for seed := int64(0); seed < 10; seed++ {
+		message, reason := generateResponse(TopicGreeting, bot, models.ServerContext{}, "RealPlayer123", nil, defaultTopicKeywords, rand.New(rand.NewSource(seed)))
+		if reason != models.ReasonGreeting || !strings.Contains(message, "RealPlayer123") {
+			t.Fatalf("generateResponse = %q, %q; want a greeting naming the player", message, reason)
+		}
+	}
+	message, _ := generateResponse(TopicGreeting, bot, models.ServerContext{}, "", nil, defaultTopicKeywords, rand.New(rand.NewSource(1)))
+	if strings.Contains(message, "%") {
+		t.Fatalf("unnamed greeting kept a placeholder: %q", message)
+	}
+}
+
+func greetingRequest(sender string) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-greet-" + sender,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: sender, SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlanGreetsTheGreeter(t *testing.T) {
+	tests := []struct {
+		sender  string
+		want    string
+		wantNot string
+	}{
+		{sender: "§aRealPlayer123", want: "RealPlayer123", wantNot: "§"},
+		{sender: "Server", wantNot: "Server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sender, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			resp := planner.Plan(context.Background(), greetingRequest(tt.sender))
+			if len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonGreeting {
+				t.Fatalf("expected one greeting, got %+v", resp.Actions)
+			}
+			message := resp.Actions[0].Message
+			if !strings.Contains(message, tt.want) || strings.Contains(message, tt.wantNot) {
+				t.Fatalf("greeting %q: want %q, not %q", message, tt.want, tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestLLMRequestNamesTheGreeter(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{})
+	planner.Plan(context.Background(), greetingRequest("&bRealPlayer123"))
+	if len(generator.requests) != 1 || generator.requests[0].ReplyTo != "RealPlayer123" {
+		t.Fatalf("expected the LLM to be told to reply to RealPlayer123, got %+v", generator.requests)
+	}
+}
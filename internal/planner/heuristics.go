@@ -1,9 +1,13 @@
 package planner
 
 import (
+	"fmt"
 	"math/rand"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"aichatplayers/internal/models"
 	"aichatplayers/internal/util"
@@ -11,86 +15,172 @@ import (
 
 const maxRecentPlayerMessages = 3
 
-func detectTopics(messages []models.ChatMessage) []Topic {
-	if len(messages) == 0 {
-		return nil
-	}
+var eventCountdownPattern = regexp.MustCompile(`\b(?:za|in)\s+(\d+)\s*(sek|s\b|sec|min|m\b|godz|h\b|hour)`)
 
-	recent := make([]models.ChatMessage, 0, maxRecentPlayerMessages)
-	for i := len(messages) - 1; i >= 0 && len(recent) < maxRecentPlayerMessages; i-- {
-		if strings.EqualFold(messages[i].SenderType, "PLAYER") {
-			recent = append(recent, messages[i])
-		}
+// Topic detection weighs each message by recency: the newest message counts
+// fully and every older one by topicRecencyDecay less, so a fresh topic
+// outranks repeated stale ones. BOT messages count at botTopicWeight and only
+// reinforce topics players raised, so bots don't amplify their own chatter.
+const (
+	topicRecencyDecay = 0.5
+	botTopicWeight    = 0.25
+)
+
+// detectTopics returns the topics of the most recent player messages, best
+// score first, together with the winning topic's score.
+func detectTopics(messages []models.ChatMessage, keywords *topicKeywords) ([]Topic, float64) {
+	scores, fromPlayer := scoreTopics(messages, keywords)
+	ordered := make([]Topic, 0, len(fromPlayer))
+	for topic := range fromPlayer {
+		ordered = append(ordered, topic)
 	}
-	if len(recent) == 0 {
-		return nil
+	if len(ordered) == 0 {
+		return nil, 0
 	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if scores[ordered[i]] != scores[ordered[j]] {
+			return scores[ordered[i]] > scores[ordered[j]]
+		}
+		return ordered[i] < ordered[j]
+	})
+	return ordered, scores[ordered[0]]
+}
 
-	topicCounts := make(map[Topic]int)
-	for _, message := range recent {
-		text := util.NormalizeText(message.Message)
-		switch {
-		case util.ContainsAny(text, toxicKeywords):
-			topicCounts[TopicToxic]++
-		case util.ContainsAny(text, eventKeywords):
-			topicCounts[TopicEvent]++
-		case util.ContainsAny(text, pvpKeywords):
-			topicCounts[TopicPVPInvite]++
-		case util.ContainsAny(text, helpKeywords):
-			topicCounts[TopicHelp]++
-		case util.ContainsAny(text, greetingKeywords):
-			topicCounts[TopicGreeting]++
+// scoreTopics returns the recency-weighted score of every topic in the
+// recent messages and which of them a player brought up.
+func scoreTopics(messages []models.ChatMessage, keywords *topicKeywords) (map[Topic]float64, map[Topic]bool) {
+	scores := make(map[Topic]float64)
+	fromPlayer := make(map[Topic]bool)
+	weight := 1.0
+	players := 0
+	for i := len(messages) - 1; i >= 0 && players < maxRecentPlayerMessages; i-- {
+		player := strings.EqualFold(messages[i].SenderType, "PLAYER")
+		bot := strings.EqualFold(messages[i].SenderType, "BOT")
+		if !player && !bot {
+			continue
+		}
+		if topic, ok := keywords.detect(util.NormalizeText(messages[i].Message)); ok {
+			if player {
+				scores[topic] += weight
+				fromPlayer[topic] = true
+			} else {
+				scores[topic] += weight * botTopicWeight
+			}
 		}
+		if player {
+			players++
+		}
+		weight *= topicRecencyDecay
 	}
+	return scores, fromPlayer
+}
 
-	if len(topicCounts) == 0 {
-		return nil
+// topicSender returns the cleaned name of the player whose latest message
+// raised topic, or "" for small talk and unnamed senders.
+func topicSender(chat []models.ChatMessage, topic Topic, keywords *topicKeywords) string {
+	if topic == "" {
+		return ""
 	}
-
-	ordered := make([]Topic, 0, len(topicCounts))
-	for topic := range topicCounts {
-		ordered = append(ordered, topic)
+	for i := len(chat) - 1; i >= 0; i-- {
+		if !strings.EqualFold(chat[i].SenderType, "PLAYER") {
+			continue
+		}
+		if keywords.matches(util.NormalizeText(chat[i].Message), topic) {
+			return playerName(chat[i].Sender)
+		}
 	}
-
-	sort.SliceStable(ordered, func(i, j int) bool {
-		return topicCounts[ordered[i]] > topicCounts[ordered[j]]
-	})
-	return ordered
+	return ""
 }
 
-func generateResponse(topic Topic, bot models.BotProfile, rng *rand.Rand) (string, string) {
+// generateResponse picks a template reply; sender is the cleaned name of the
+// player who raised the topic (see topicSender), "" when unknown.
+func generateResponse(topic Topic, bot models.BotProfile, server models.ServerContext, sender string, chat []models.ChatMessage, keywords *topicKeywords, rng *rand.Rand) (string, models.Reason) {
 	if shouldAvoidTopic(topic, bot.Persona.AvoidTopics) {
 		return "", ""
 	}
 	tone := strings.ToLower(bot.Persona.Tone)
 	styleTags := strings.Join(bot.Persona.StyleTags, ",")
 	knowledge := strings.ToLower(bot.Persona.KnowledgeLevel)
+	templates := templatesFor(bot.Persona.Language)
 
 	switch topic {
 	case TopicGreeting:
-		return prefixNewbie(knowledge, rng, pickTemplate(greetingTemplates, rng)) + emojiSuffix(tone, rng), "greeting"
+		var greeting string
+		if sender != "" {
+			greeting = fmt.Sprintf(pickTemplate(templates.GreetingNamed, rng), sender)
+		} else {
+			greeting = pickTemplate(templates.Greeting, rng)
+		}
+		return prefixNewbie(knowledge, templates, rng, greeting) + emojiSuffix(tone, rng), models.ReasonGreeting
 	case TopicPVPInvite:
-		return pickTemplate(pvpNeutralTemplates, rng) + emojiSuffix(tone, rng), "avoid_real_pvp"
+		return pickTemplate(templates.PVPNeutral, rng) + emojiSuffix(tone, rng), models.ReasonAvoidRealPVP
 	case TopicEvent:
-		return pickTemplate(eventTemplates, rng), "react_to_event"
+		if startsIn, ok := eventCountdown(chat, keywords); ok {
+			return fmt.Sprintf(pickTemplate(templates.EventCountdown, rng), util.FormatRelativeTime(bot.Persona.Language, startsIn)), models.ReasonReactToEvent
+		}
+		return pickTemplate(templates.Event, rng), models.ReasonReactToEvent
 	case TopicHelp:
-		return prefixNewbie(knowledge, rng, pickTemplate(helpTemplates, rng)), "helpful_hint"
+		return prefixNewbie(knowledge, templates, rng, pickTemplate(templates.Help, rng)), models.ReasonHelpfulHint
+	case TopicServerInfo:
+		if server.OnlinePlayers <= 0 {
+			return "", ""
+		}
+		if mode := strings.TrimSpace(server.Mode); mode != "" {
+			return fmt.Sprintf(pickTemplate(templates.ServerInfoMode, rng), mode, server.OnlinePlayers), models.ReasonServerInfo
+		}
+		return fmt.Sprintf(pickTemplate(templates.ServerInfo, rng), server.OnlinePlayers), models.ReasonServerInfo
 	case "":
-		message := pickTemplate(smallTalkTemplates, rng)
+		message := pickTemplate(templates.SmallTalk, rng)
 		if strings.Contains(styleTags, "short") {
 			message = shorten(message)
 		}
-		return prefixNewbie(knowledge, rng, message) + emojiSuffix(tone, rng), "small_talk"
+		return prefixNewbie(knowledge, templates, rng, message) + emojiSuffix(tone, rng), models.ReasonSmallTalk
 	default:
-		return "", ""
+		templates := keywords.customTemplates(topic, bot.Persona.Language)
+		if len(templates) == 0 {
+			return "", ""
+		}
+		return pickTemplate(templates, rng) + emojiSuffix(tone, rng), models.ReasonCustomTopic
+	}
+}
+
+func eventCountdown(messages []models.ChatMessage, keywords *topicKeywords) (time.Duration, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		text := util.NormalizeText(messages[i].Message)
+		if !keywords.matches(text, TopicEvent) {
+			continue
+		}
+		match := eventCountdownPattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		amount, err := strconv.Atoi(match[1])
+		if err != nil || amount <= 0 {
+			continue
+		}
+		unit := time.Minute
+		switch {
+		case strings.HasPrefix(match[2], "s"):
+			unit = time.Second
+		case strings.HasPrefix(match[2], "g"), strings.HasPrefix(match[2], "h"):
+			unit = time.Hour
+		}
+		return time.Duration(amount) * unit, true
 	}
+	return 0, false
 }
 
 func shouldAvoidTopic(topic Topic, avoid []string) bool {
+	return matchesTopic(topic, avoid)
+}
+
+// matchesTopic reports whether one of the persona labels (avoid_topics or
+// interests) names the topic.
+func matchesTopic(topic Topic, labels []string) bool {
 	if topic == "" {
 		return false
 	}
-	for _, item := range avoid {
+	for _, item := range labels {
 		normalized := strings.ToLower(item)
 		if strings.EqualFold(item, string(topic)) {
 			return true
@@ -105,11 +195,11 @@ func shouldAvoidTopic(topic Topic, avoid []string) bool {
 	return false
 }
 
-func prefixNewbie(level string, rng *rand.Rand, message string) string {
+func prefixNewbie(level string, templates templateSet, rng *rand.Rand, message string) string {
 	if level != "newbie" || message == "" {
 		return message
 	}
-	prefix := pickTemplate(newbieAddOns, rng)
+	prefix := pickTemplate(templates.NewbieAddOns, rng)
 	if strings.HasPrefix(message, prefix) {
 		return message
 	}
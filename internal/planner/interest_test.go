@@ -0,0 +1,49 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func interestTestRequest(interests []string) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-interest-3",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots: []models.BotProfile{
+			{BotID: "bot-1", Persona: models.Persona{Interests: []string{"building"}}},
+			{BotID: "bot-2", Persona: models.Persona{Interests: interests}},
+		},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "kto pvp?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlanPrefersBotsInterestedInTopic(t *testing.T) {
+	baseline := NewPlanner(nil, Config{}).Plan(context.Background(), interestTestRequest(nil))
+	if len(baseline.Actions) != 1 || baseline.Actions[0].BotID != "bot-1" {
+		t.Fatalf("expected the seed to pick bot-1 without interests, got %+v", baseline.Actions)
+	}
+
+	resp := NewPlanner(nil, Config{}).Plan(context.Background(), interestTestRequest([]string{"pvp"}))
+	if len(resp.Actions) != 1 || resp.Actions[0].BotID != "bot-2" {
+		t.Fatalf("expected the pvp fan bot-2 to be selected, got %+v", resp.Actions)
+	}
+}
+
+func TestSelectorKeepsUninterestedBotsSelectable(t *testing.T) {
+	bots := interestTestRequest([]string{"pvp"}).Bots
+	selector := botSelector{}.forTopic(TopicPVPInvite, models.PlanSettings{UninterestedWeight: 0.25})
+	picks := make(map[string]int)
+	for seed := int64(0); seed < 400; seed++ {
+		picks[selector.pick(bots, 1, rand.New(rand.NewSource(seed)))[0].BotID]++
+	}
+	if picks["bot-1"] == 0 || picks["bot-2"] <= 2*picks["bot-1"] {
+		t.Fatalf("picks = %v, want the uninterested bot-1 picked less often but not excluded", picks)
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 	"aichatplayers/internal/llm"
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
 	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
 )
 
 type LLMGenerator interface {
@@ -25,41 +27,143 @@ func (noopLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
 
 func (noopLLM) Close() error { return nil }
 
-func (p *Planner) generateMessage(req models.PlanRequest, topic Topic, bot models.BotProfile, rng *rand.Rand) (string, string, bool, bool) {
+// Reasons generateMessage gives for dropping a generated message; the
+// explain mode reports them as candidate outcomes.
+const (
+	rejectedByMemory     = "suppressed_by_memory"
+	rejectedByProfanity  = "profanity_blocked"
+	rejectedByAvoidTopic = "avoid_topic_filtered"
+)
+
+// generateMessage asks the LLM for a reply on topic and falls back to the
+// heuristics; turn carries extra prompt context such as a system announcement
+// and mustReply keeps the LLM for required and mentioned bots under pressure.
+// rejected names the check that dropped an LLM reply, or says the bot had
+// nothing left to say that it had not said recently.
+func (p *Planner) generateMessage(ctx context.Context, req models.PlanRequest, topic Topic, bot models.BotProfile, mustReply bool, turn llm.Request, routing *llmRouting, rng *rand.Rand) (message string, reason models.Reason, attempted, used bool, rejected string) {
 	if shouldAvoidTopic(topic, bot.Persona.AvoidTopics) {
-		return "", "", false, false
+		return "", "", false, false, ""
+	}
+	if req.DryRun {
+		message, reason := p.dryRunReply(topic, bot, req.Server, req.Chat, rng)
+		return message, reason, false, false, ""
+	}
+	useLLM := p.llm != nil && p.llm.Enabled()
+	if useLLM && routing.reserve(topic, mustReply) {
+		logging.Ctx(ctx).Infof("planner_llm_reserved request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+		useLLM = false
 	}
-	if p.llm != nil && p.llm.Enabled() {
-		ctx := context.Background()
+	if useLLM {
 		var cancel context.CancelFunc
-		if p.llmTimeout > 0 {
-			ctx, cancel = context.WithTimeout(ctx, p.llmTimeout)
+		if p.tuning.Load().llmTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.tuning.Load().llmTimeout)
 			defer cancel()
 		}
-		llmReq := llm.Request{
-			Server:     req.Server,
-			Bot:        bot,
-			Topic:      string(topic),
-			RecentChat: recentChat(req.Chat, p.chatLimit),
-		}
-		message, err := p.llm.Generate(ctx, llmReq)
+		turn.Server = req.Server
+		turn.Bot = bot
+		turn.Topic = string(topic)
+		turn.TopicHint = p.topicKeywords().hint(topic)
+		turn.ReplyTo = topicSender(req.Chat, topic, p.topicKeywords())
+		turn.RecentChat = recentChat(req.Chat, p.historyLimit(req.Settings))
+		turn.Sampling = req.Settings.LLM
+		message, err := p.generateLLM(ctx, turn)
 		if err != nil {
-			logging.Warnf("planner_llm_error request_id=%s transaction_id=%s bot_id=%s topic=%s error=%v", req.RequestID, req.RequestID, bot.BotID, topic, err)
+			logging.Ctx(ctx).Warnf("planner_llm_error request_id=%s transaction_id=%s bot_id=%s topic=%s error=%v", req.RequestID, req.RequestID, bot.BotID, topic, err)
+		} else if p.sentRecently(req.Server.ServerID, bot.BotID, message) {
+			logging.Ctx(ctx).Infof("planner_llm_repeat request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+			rejected = rejectedByMemory
+		} else if p.profaneOutput(ctx, req.RequestID, bot, message) {
+			metrics.SilenceDecisions.Inc(profanityBlockedReason)
+			return "", "", true, false, rejectedByProfanity
+		} else if p.avoidedOutput(ctx, req.RequestID, bot, message) {
+			rejected = rejectedByAvoidTopic
 		} else if message != "" {
-			logging.Debugf("[LLM-SERVER REPONSE] planner_llm_response request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
-			return message, "llm", true, true
+			logging.Ctx(ctx).Debugf("[LLM-SERVER REPONSE] planner_llm_response request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+			return message, models.ReasonLLM, true, true, ""
+		}
+		message, reason, repeated := p.heuristicMessage(ctx, req, topic, bot, rng)
+		if rejected == rejectedByAvoidTopic {
+			reason = models.ReasonAvoidTopicFiltered
+			if message == "" {
+				metrics.SilenceDecisions.Inc(string(models.ReasonAvoidTopicFiltered))
+			}
+		} else if repeated {
+			rejected = rejectedByMemory
 		}
-		message, reason := generateResponse(topic, bot, rng)
 		if message != "" {
-			logging.Debugf("[HEURISTIC RESPONSE] planner_heuristic_response request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
+			metrics.HeuristicFallbacks.Inc()
+			logging.Ctx(ctx).Debugf("[HEURISTIC RESPONSE] planner_heuristic_response request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
 		}
-		return message, reason, true, false
+		return message, reason, true, false, rejected
 	}
-	message, reason := generateResponse(topic, bot, rng)
+	message, reason, repeated := p.heuristicMessage(ctx, req, topic, bot, rng)
 	if message != "" {
-		logging.Debugf("[HEURISTIC RESPONSE] planner_heuristic_response request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
+		logging.Ctx(ctx).Debugf("[HEURISTIC RESPONSE] planner_heuristic_response request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
+	}
+	if repeated {
+		rejected = rejectedByMemory
+	}
+	return message, reason, false, false, rejected
+}
+
+// avoidedOutput reports whether an LLM reply touches a forbidden subject or
+// one of the bot's avoid_topics.
+func (p *Planner) avoidedOutput(ctx context.Context, requestID string, bot models.BotProfile, message string) bool {
+	label, ok := p.topicKeywords().avoidedTopic(util.NormalizeText(message), bot.Persona.AvoidTopics)
+	if ok {
+		logging.Ctx(ctx).Infof("planner_llm_avoid_topic request_id=%s transaction_id=%s bot_id=%s avoid_topic=%s", requestID, requestID, bot.BotID, label)
+	}
+	return ok
+}
+
+const (
+	maxSettingsMaxTokens = 4096
+	maxChatHistoryLimit  = 100
+)
+
+// normalizeLLMSettings clamps settings.llm into the ranges the LLM accepts;
+// zero max_tokens and chat_history_limit keep the configured values.
+func normalizeLLMSettings(settings models.LLMSettings) *models.LLMSettings {
+	if settings.Temperature != nil {
+		temperature := min(max(*settings.Temperature, 0), llm.MaxTemperature)
+		settings.Temperature = &temperature
+	}
+	if settings.TopP != nil {
+		topP := min(max(*settings.TopP, 0), 1)
+		settings.TopP = &topP
+	}
+	settings.MaxTokens = min(max(settings.MaxTokens, 0), maxSettingsMaxTokens)
+	settings.ChatHistoryLimit = min(max(settings.ChatHistoryLimit, 0), maxChatHistoryLimit)
+	return &settings
+}
+
+// effectiveLLMSettings fills the unset settings.llm fields with the
+// configured values; nil when the request sent none.
+func (p *Planner) effectiveLLMSettings(settings *models.LLMSettings) *models.LLMSettings {
+	if settings == nil {
+		return nil
+	}
+	effective := p.tuning.Load().llmDefaults
+	if settings.Temperature != nil {
+		effective.Temperature = settings.Temperature
+	}
+	if settings.TopP != nil {
+		effective.TopP = settings.TopP
+	}
+	if settings.MaxTokens > 0 {
+		effective.MaxTokens = settings.MaxTokens
+	}
+	if settings.ChatHistoryLimit > 0 {
+		effective.ChatHistoryLimit = settings.ChatHistoryLimit
+	}
+	return &effective
+}
+
+func (p *Planner) historyLimit(settings models.PlanSettings) int {
+	if settings.LLM != nil && settings.LLM.ChatHistoryLimit > 0 {
+		return settings.LLM.ChatHistoryLimit
 	}
-	return message, reason, false, false
+	return p.tuning.Load().chatLimit
 }
 
 func recentChat(messages []models.ChatMessage, limit int) []models.ChatMessage {
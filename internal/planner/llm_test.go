@@ -0,0 +1,80 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestPlanSettingsLLM(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    string
+		wantHistory int
+		wantEcho    string
+	}{
+		{name: "absent", settings: `{"max_actions":1,"reply_chance":1}`, wantHistory: 2},
+		{
+			name:        "snake case",
+			settings:    `{"max_actions":1,"reply_chance":1,"llm":{"temperature":1.1,"max_tokens":48,"chat_history_limit":1}}`,
+			wantHistory: 1,
+			wantEcho:    `{"temperature":1.1,"top_p":0.9,"max_tokens":48,"chat_history_limit":1}`,
+		},
+		{
+			name:        "kebab case",
+			settings:    `{"max_actions":1,"reply_chance":1,"llm":{"top-p":0.5,"max-tokens":48,"chat-history-limit":3}}`,
+			wantHistory: 3,
+			wantEcho:    `{"temperature":0.6,"top_p":0.5,"max_tokens":48,"chat_history_limit":3}`,
+		},
+		{
+			name:        "clamped",
+			settings:    `{"max_actions":1,"reply_chance":1,"llm":{"temperature":9,"top_p":-1,"max_tokens":99999,"chat_history_limit":-4}}`,
+			wantHistory: 2,
+			wantEcho:    `{"temperature":2,"top_p":0,"max_tokens":4096,"chat_history_limit":2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := &capturingLLM{}
+			planner := NewPlanner(generator, Config{ChatHistoryLimit: 2, LLMTemperature: 0.6, LLMTopP: 0.9, LLMMaxTokens: 128})
+			req := models.PlanRequest{
+				RequestID: "req-llm-settings",
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba", Online: boolPtr(true)}},
+				Chat: []models.ChatMessage{
+					{TimestampMS: 1712344990000, Sender: "Steve", SenderType: "PLAYER", Message: "gg"},
+					{TimestampMS: 1712344995000, Sender: "Alex", SenderType: "PLAYER", Message: "nice"},
+					{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"},
+				},
+			}
+			if err := json.Unmarshal([]byte(tt.settings), &req.Settings); err != nil {
+				t.Fatalf("unmarshal settings: %v", err)
+			}
+
+			resp := planner.Plan(context.Background(), req)
+			if len(generator.requests) != 1 {
+				t.Fatalf("llm requests = %d, want 1 (actions %+v)", len(generator.requests), resp.Actions)
+			}
+			turn := generator.requests[0]
+			if len(turn.RecentChat) != tt.wantHistory {
+				t.Fatalf("recent chat = %d messages, want %d", len(turn.RecentChat), tt.wantHistory)
+			}
+			if tt.wantEcho == "" {
+				if turn.Sampling != nil || resp.Debug.LLMSettings != nil {
+					t.Fatalf("expected no llm settings, got turn %+v debug %+v", turn.Sampling, resp.Debug.LLMSettings)
+				}
+				return
+			}
+			if turn.Sampling == nil {
+				t.Fatal("expected settings.llm to reach the LLM request")
+			}
+			echo, _ := json.Marshal(resp.Debug.LLMSettings)
+			if string(echo) != tt.wantEcho {
+				t.Fatalf("debug.llm_settings = %s, want %s", echo, tt.wantEcho)
+			}
+		})
+	}
+}
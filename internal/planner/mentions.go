@@ -0,0 +1,61 @@
+package planner
+
+import (
+	"strings"
+	"unicode"
+
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+// detectMentions returns the bots whose names appear as whole words in the
+// most recent player messages, newest mention first.
+func detectMentions(messages []models.ChatMessage, bots []models.BotProfile) []models.BotProfile {
+	var mentioned []models.BotProfile
+	seen := make(map[string]bool)
+	checked := 0
+	for i := len(messages) - 1; i >= 0 && checked < maxRecentPlayerMessages; i-- {
+		if !strings.EqualFold(messages[i].SenderType, "PLAYER") {
+			continue
+		}
+		checked++
+		text := util.NormalizeText(messages[i].Message)
+		for _, bot := range bots {
+			if bot.BotID == "" || seen[bot.BotID] {
+				continue
+			}
+			if containsWord(text, util.NormalizeText(strings.TrimSpace(bot.Name))) {
+				seen[bot.BotID] = true
+				mentioned = append(mentioned, bot)
+			}
+		}
+	}
+	return mentioned
+}
+
+func containsWord(text, word string) bool {
+	if len(word) < 2 {
+		return false
+	}
+	for offset := 0; offset < len(text); {
+		index := strings.Index(text[offset:], word)
+		if index < 0 {
+			return false
+		}
+		start := offset + index
+		end := start + len(word)
+		if isWordBoundary(text, start-1) && isWordBoundary(text, end) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+func isWordBoundary(text string, index int) bool {
+	if index < 0 || index >= len(text) {
+		return true
+	}
+	r := rune(text[index])
+	return r < 0x80 && !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
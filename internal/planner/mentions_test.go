@@ -0,0 +1,109 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func mentionTestRequest(requestID, message string, bots []models.BotProfile) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      bots,
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: message},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 0.01},
+	}
+}
+
+func mentionTestBots() []models.BotProfile {
+	return []models.BotProfile{
+		{BotID: "bot-1", Name: "Kuba", Online: boolPtr(true)},
+		{BotID: "bot-2", Name: "Łukasz", Online: boolPtr(true)},
+		{BotID: "bot-3", Name: "Ola", Online: boolPtr(true)},
+		{BotID: "bot-4", Name: "Zosia", Online: boolPtr(false)},
+	}
+}
+
+func TestMentionedBotIsChosen(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "Kuba, pomozesz mi?", want: "bot-1"},
+		{message: "lukasz jak zrobic portal?", want: "bot-2"},
+		{message: "hej OLA co tam", want: "bot-3"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			planner := NewPlanner(noopLLM{}, Config{})
+			resp := planner.Plan(context.Background(), mentionTestRequest(fmt.Sprintf("req-%d", i), tt.message, mentionTestBots()))
+			if len(resp.Actions) != 1 || resp.Actions[0].BotID != tt.want {
+				t.Fatalf("%q run %d: expected %s to answer despite low reply chance, got %+v (debug %+v)", tt.message, i, tt.want, resp.Actions, resp.Debug)
+			}
+			if resp.Actions[0].Reason != models.ReasonDirectMention {
+				t.Fatalf("%q run %d: reason = %s, want %s", tt.message, i, resp.Actions[0].Reason, models.ReasonDirectMention)
+			}
+		}
+	}
+}
+
+func TestMentionRespectsSilenceRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		avoid       []string
+		wantActions int
+	}{
+		{name: "toxic chat", message: "Kuba ty idiota", wantActions: 0},
+		{name: "avoided topic", message: "Kuba kto pvp?", avoid: []string{"pvp"}, wantActions: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bots := mentionTestBots()[:1]
+			bots[0].Persona.AvoidTopics = tt.avoid
+			planner := NewPlanner(noopLLM{}, Config{})
+			resp := planner.Plan(context.Background(), mentionTestRequest("req-silence", tt.message, bots))
+			if len(resp.Actions) != tt.wantActions {
+				t.Fatalf("expected %d actions, got %+v", tt.wantActions, resp.Actions)
+			}
+		})
+	}
+}
+
+func TestMentionOfOfflineBotIsIgnored(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	req := mentionTestRequest("req-offline", "Zosia jestes?", mentionTestBots())
+	req.Settings.ReplyChance = 1
+	resp := planner.Plan(context.Background(), req)
+	for _, action := range resp.Actions {
+		if action.BotID == "bot-4" || action.Reason == models.ReasonDirectMention {
+			t.Fatalf("offline bot mention should not produce a mention reply, got %+v", resp.Actions)
+		}
+	}
+}
+
+func TestContainsWordRequiresBoundaries(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		want bool
+	}{
+		{text: "kuba, chodz", word: "kuba", want: true},
+		{text: "@kuba!", word: "kuba", want: true},
+		{text: "kubatura", word: "kuba", want: false},
+		{text: "skola", word: "ola", want: false},
+		{text: "ola", word: "ola", want: true},
+		{text: "hej o", word: "o", want: false},
+	}
+	for _, tt := range tests {
+		if got := containsWord(tt.text, tt.word); got != tt.want {
+			t.Fatalf("containsWord(%q, %q) = %t, want %t", tt.text, tt.word, got, tt.want)
+		}
+	}
+}
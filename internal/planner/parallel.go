@@ -0,0 +1,76 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+// planWorkers bounds the goroutines generating messages for one plan. The
+// LLM client's own concurrency limit still applies on top of it.
+const planWorkers = 4
+
+// generationJob is one bot's reply to one topic. Each job carries its own
+// rng, drawn from the plan's rng before the round starts, so the output
+// does not depend on which worker finishes first.
+type generationJob struct {
+	topic Topic
+	bot   models.BotProfile
+	rng   *rand.Rand
+	// mustReply marks a required or mentioned bot.
+	mustReply bool
+
+	message      string
+	reason       models.Reason
+	source       string
+	generationMS int64
+	attempted    bool
+	used         bool
+	// rejected is generateMessage's reason for dropping a reply.
+	rejected string
+	// skipped marks a job that never started because the plan deadline
+	// passed or the request ended while it waited for a worker.
+	skipped bool
+}
+
+// generateAll fills in the jobs using up to planWorkers goroutines. It
+// returns once every job has finished; the caller reads the results in job
+// order.
+func (p *Planner) generateAll(ctx context.Context, req models.PlanRequest, jobs []*generationJob, routing *llmRouting, deadline *planDeadline) {
+	run := func(job *generationJob) {
+		if deadline.ctx.Err() != nil {
+			job.skipped = true
+			return
+		}
+		start := p.clock.Now()
+		job.message, job.reason, job.attempted, job.used, job.rejected = p.generateMessage(ctx, req, job.topic, job.bot, job.mustReply, llm.Request{}, routing, job.rng)
+		job.source, job.generationMS = routing.observe(p.clock.Now().Sub(start), job.attempted, job.used)
+	}
+	if len(jobs) == 1 {
+		run(jobs[0])
+		return
+	}
+	workers := planWorkers
+	if len(jobs) < workers {
+		workers = len(jobs)
+	}
+	queue := make(chan *generationJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				run(job)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
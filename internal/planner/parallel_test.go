@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+type latentLLM struct {
+	delay time.Duration
+}
+
+func (latentLLM) Enabled() bool { return true }
+
+func (s latentLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	select {
+	case <-time.After(s.delay):
+		return "portal robisz z obsydianu, " + req.Bot.Name, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (latentLLM) Close() error { return nil }
+
+func parallelTestRequest() models.PlanRequest {
+	return models.PlanRequest{
+		RequestID:      "req-parallel",
+		Server:         models.ServerContext{ServerID: "srv-1"},
+		TimeMS:         1712345000000,
+		Bots:           []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}, {BotID: "bot-3", Name: "Zenek"}},
+		RequiredBotIDs: []string{"bot-1", "bot-2", "bot-3"},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 3, ReplyChance: 1},
+	}
+}
+
+func TestPlanGeneratesBotsInParallel(t *testing.T) {
+	planner := NewPlanner(latentLLM{delay: 300 * time.Millisecond}, Config{LLMTimeout: 5 * time.Second})
+
+	start := time.Now()
+	resp := planner.Plan(context.Background(), parallelTestRequest())
+	elapsed := time.Since(start)
+
+	if elapsed >= 600*time.Millisecond {
+		t.Fatalf("plan took %s, want well under 3x300ms", elapsed)
+	}
+	if len(resp.Actions) != 3 {
+		t.Fatalf("expected three actions, got %+v", resp.Actions)
+	}
+	for i, want := range []string{"bot-1", "bot-2", "bot-3"} {
+		if resp.Actions[i].BotID != want || resp.Actions[i].Reason != models.ReasonLLM {
+			t.Fatalf("action %d = %+v, want an llm reply from %s", i, resp.Actions[i], want)
+		}
+	}
+}
+
+func TestPlanSoftTimeoutCancelsStragglers(t *testing.T) {
+	planner := NewPlanner(latentLLM{delay: time.Minute}, Config{LLMTimeout: 100 * time.Millisecond})
+
+	start := time.Now()
+	resp := planner.Plan(context.Background(), parallelTestRequest())
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("plan took %s, want the soft timeout to cancel stragglers", elapsed)
+	}
+	if len(resp.Actions) != 3 {
+		t.Fatalf("expected heuristic fallbacks for all bots, got %+v", resp.Actions)
+	}
+	for _, action := range resp.Actions {
+		if action.Source != SourceHeuristic {
+			t.Fatalf("expected heuristic fallbacks, got %+v", resp.Actions)
+		}
+	}
+}
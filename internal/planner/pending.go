@@ -0,0 +1,132 @@
+package planner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+const (
+	pendingActionTTLMS int64 = 30000
+	maxPendingActions        = 4096
+)
+
+const (
+	DecisionKeep = "keep"
+	DecisionDrop = "drop"
+)
+
+type pendingAction struct {
+	botID     string
+	createdMS int64
+	// lastChatMS is the newest chat timestamp the plan was based on; anything
+	// newer in the check request happened after the action was planned.
+	lastChatMS int64
+}
+
+func (p *Planner) trackPendingActions(req models.PlanRequest, actions []models.PlannedAction) {
+	if len(actions) == 0 {
+		return
+	}
+	lastChatMS := int64(0)
+	if last := latestChatMessage(req.Chat); last != nil {
+		lastChatMS = last.TimestampMS
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prunePendingLocked(req.TimeMS)
+	for i := range actions {
+		token := newActionToken()
+		if token == "" {
+			continue
+		}
+		actions[i].ActionToken = token
+		p.pending[token] = pendingAction{
+			botID:      actions[i].BotID,
+			createdMS:  req.TimeMS,
+			lastChatMS: lastChatMS,
+		}
+	}
+}
+
+func (p *Planner) CheckActions(req models.ActionCheckRequest) models.ActionCheckResponse {
+	nowMS := req.TimeMS
+	if nowMS <= 0 {
+		nowMS = p.clock.Now().UnixMilli()
+	}
+	p.mu.Lock()
+	snapshots := make([]*pendingAction, len(req.Tokens))
+	for i, token := range req.Tokens {
+		if pending, ok := p.pending[token]; ok {
+			snapshots[i] = &pending
+		}
+	}
+	p.prunePendingLocked(nowMS)
+	p.mu.Unlock()
+
+	results := make([]models.ActionCheckResult, 0, len(req.Tokens))
+	for i, token := range req.Tokens {
+		decision, reason := evaluatePendingAction(snapshots[i], req.Chat, nowMS, p.topicKeywords())
+		logging.Debugf("planner_action_check token=%s decision=%s reason=%s", token, decision, reason)
+		results = append(results, models.ActionCheckResult{
+			ActionToken: token,
+			Decision:    decision,
+			Reason:      reason,
+		})
+	}
+	return models.ActionCheckResponse{Results: results}
+}
+
+func evaluatePendingAction(pending *pendingAction, chat []models.ChatMessage, nowMS int64, keywords *topicKeywords) (string, string) {
+	if pending == nil {
+		return DecisionDrop, "unknown_token"
+	}
+	if nowMS-pending.createdMS > pendingActionTTLMS {
+		return DecisionDrop, "expired"
+	}
+	superseded := false
+	for _, message := range chat {
+		if message.TimestampMS <= pending.lastChatMS {
+			continue
+		}
+		if !strings.EqualFold(message.SenderType, "PLAYER") {
+			continue
+		}
+		if keywords.matches(util.NormalizeText(message.Message), TopicToxic) {
+			return DecisionDrop, "toxic"
+		}
+		superseded = true
+	}
+	if superseded {
+		return DecisionDrop, "superseded"
+	}
+	return DecisionKeep, "unchanged"
+}
+
+func (p *Planner) prunePendingLocked(nowMS int64) {
+	for token, pending := range p.pending {
+		if nowMS-pending.createdMS > pendingActionTTLMS {
+			delete(p.pending, token)
+		}
+	}
+	for token := range p.pending {
+		if len(p.pending) < maxPendingActions {
+			break
+		}
+		delete(p.pending, token)
+	}
+}
+
+func newActionToken() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		logging.Warnf("planner_action_token_failed error=%v", err)
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
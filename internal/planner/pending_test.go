@@ -0,0 +1,114 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func planWithToken(t *testing.T, planner *Planner) (models.PlanRequest, string) {
+	t.Helper()
+	req := models.PlanRequest{
+		RequestID: "req-pending",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{
+				TimestampMS: 1712344999000,
+				Sender:      "RealPlayer123",
+				SenderType:  "PLAYER",
+				Message:     "gdzie jest lobby?",
+			},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected 1 action, got %d", len(resp.Actions))
+	}
+	token := resp.Actions[0].ActionToken
+	if token == "" {
+		t.Fatal("expected action token in plan response")
+	}
+	return req, token
+}
+
+func TestCheckActions(t *testing.T) {
+	tests := []struct {
+		name       string
+		offsetMS   int64
+		newChat    []models.ChatMessage
+		wantResult string
+		wantReason string
+	}{
+		{
+			name:       "keep when chat unchanged",
+			offsetMS:   2000,
+			wantResult: DecisionKeep,
+			wantReason: "unchanged",
+		},
+		{
+			name:     "keep when only bots spoke",
+			offsetMS: 2000,
+			newChat: []models.ChatMessage{
+				{TimestampMS: 1712345001000, Sender: "Maja", SenderType: "BOT", Message: "hej"},
+			},
+			wantResult: DecisionKeep,
+			wantReason: "unchanged",
+		},
+		{
+			name:     "drop when superseded",
+			offsetMS: 2000,
+			newChat: []models.ChatMessage{
+				{TimestampMS: 1712345001000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "dobra juz wiem"},
+			},
+			wantResult: DecisionDrop,
+			wantReason: "superseded",
+		},
+		{
+			name:     "drop when toxic",
+			offsetMS: 2000,
+			newChat: []models.ChatMessage{
+				{TimestampMS: 1712345001000, Sender: "Troll", SenderType: "PLAYER", Message: "ty idiota"},
+			},
+			wantResult: DecisionDrop,
+			wantReason: "toxic",
+		},
+		{
+			name:       "drop when expired",
+			offsetMS:   pendingActionTTLMS + 1,
+			wantResult: DecisionDrop,
+			wantReason: "expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			req, token := planWithToken(t, planner)
+			chat := append(append([]models.ChatMessage{}, req.Chat...), tt.newChat...)
+			resp := planner.CheckActions(models.ActionCheckRequest{
+				TimeMS: req.TimeMS + tt.offsetMS,
+				Tokens: []string{token},
+				Chat:   chat,
+			})
+			if len(resp.Results) != 1 {
+				t.Fatalf("expected 1 result, got %d", len(resp.Results))
+			}
+			got := resp.Results[0]
+			if got.ActionToken != token || got.Decision != tt.wantResult || got.Reason != tt.wantReason {
+				t.Fatalf("got %+v, want decision=%s reason=%s", got, tt.wantResult, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCheckActionsUnknownToken(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	resp := planner.CheckActions(models.ActionCheckRequest{TimeMS: 1, Tokens: []string{"missing"}})
+	if len(resp.Results) != 1 || resp.Results[0].Decision != DecisionDrop || resp.Results[0].Reason != "unknown_token" {
+		t.Fatalf("unexpected result: %+v", resp.Results)
+	}
+}
@@ -1,48 +1,221 @@
 package planner
 
 import (
-	"fmt"
+	"context"
 	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/llm"
 	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
 	"aichatplayers/internal/models"
-	"aichatplayers/internal/util"
 )
 
 type BotMemory struct {
-	LastSentByTopic map[Topic]int64
+	LastSentByTopic map[Topic]int64 `json:"last_sent_by_topic"`
+	RecentMessages  []string        `json:"recent_messages,omitempty"`
+	// LastActionMS is the time of the bot's latest planned action on the
+	// server, used by the least_recent and weighted selection strategies.
+	LastActionMS int64 `json:"last_action_ms,omitempty"`
+	// RecentActionsMS are the bot's action times in the last minute, for
+	// settings.max_bot_messages_per_minute.
+	RecentActionsMS []int64 `json:"recent_actions_ms,omitempty"`
 }
 
 type Planner struct {
-	mu         sync.Mutex
-	memory     map[string]map[string]BotMemory
-	registry   map[string]map[string]models.BotProfile
-	llm        LLMGenerator
-	llmTimeout time.Duration
-	chatLimit  int
+	mu       sync.Mutex
+	memory   map[string]map[string]BotMemory
+	registry map[string]map[string]models.BotProfile
+	// serverSettings holds the default_settings registered per server.
+	serverSettings map[string]models.PlanSettings
+	pending        map[string]pendingAction
+	engaged        map[string]map[string]int64
+	// systemEvents holds the time of the last system event reaction per server.
+	systemEvents map[string]int64
+	// answered holds the newest player message each server's bots replied
+	// to with a topic reply.
+	answered map[string]answeredMessage
+	llm      LLMGenerator
+	// tuning is swapped whole by Reconfigure.
+	tuning atomic.Pointer[plannerTuning]
+
+	statePath string
+	stateStop chan struct{}
+	stateDone chan struct{}
+	closeOnce sync.Once
+
+	lastLLMSuccessMS atomic.Int64
+	llmUnavailable   atomic.Bool
+
+	topicsPath string
+	topics     atomic.Pointer[topicKeywords]
+
+	clock Clock
+	// profanity holds the extra words from the profanity blocklist file.
+	profanity []string
+	dedup     *planDedup
 }
 
 const topicCooldownMS int64 = 15000
 
+const quietHoursReason = "quiet_hours"
+
+// cancelledSuffix marks strategies of plans cut short by the request context.
+const cancelledSuffix = "_cancelled"
+
 type Config struct {
-	LLMTimeout       time.Duration
-	ChatHistoryLimit int
+	LLMTimeout         time.Duration
+	ChatHistoryLimit   int
+	LLMTemperature     float64
+	LLMTopP            float64
+	LLMMaxTokens       int
+	PressureQueueDepth int
+	PressureP95Latency time.Duration
+	EngagementCooldown time.Duration
+	RecentMessageLimit int
+	StatePath          string
+	StateInterval      time.Duration
+	TopicKeywordsPath  string
+	QuietHours         *config.QuietHours
+	// ProfanityBlocklistPath adds words to the outgoing profanity filter.
+	ProfanityBlocklistPath string
+	// ChatMessageMaxChars caps incoming chat messages before they reach a
+	// prompt; 0 uses the default.
+	ChatMessageMaxChars int
+	// ChatClockSkew is added to settings.max_message_age_ms before chat
+	// counts as stale; 0 uses the default.
+	ChatClockSkew time.Duration
+	// PlanDeadline is the default for settings.plan_deadline_ms; 0 leaves
+	// plans without a deadline.
+	PlanDeadline time.Duration
+	// MaxMessageChars caps messages after the persona style is applied;
+	// 0 leaves them uncapped.
+	MaxMessageChars int
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// plannerTuning holds the settings a config reload may change while plans
+// are running.
+type plannerTuning struct {
+	llmTimeout time.Duration
+	chatLimit  int
+	// llmDefaults are the configured values behind debug.llm_settings.
+	llmDefaults        models.LLMSettings
+	pressureQueueDepth int
+	pressureP95        time.Duration
+	engageCooldownMS   int64
+	recentMessageLimit int
+	chatMaxChars       int
+	chatClockSkewMS    int64
+	planDeadlineMS     int64
+	maxMessageChars    int
+	quietHours         *config.QuietHours
 }
 
 func NewPlanner(generator LLMGenerator, cfg Config) *Planner {
 	if generator == nil {
 		generator = noopLLM{}
 	}
-	return &Planner{
-		memory:     make(map[string]map[string]BotMemory),
-		registry:   make(map[string]map[string]models.BotProfile),
-		llm:        generator,
+	clock := cfg.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	p := &Planner{
+		memory:         make(map[string]map[string]BotMemory),
+		registry:       make(map[string]map[string]models.BotProfile),
+		serverSettings: make(map[string]models.PlanSettings),
+		pending:        make(map[string]pendingAction),
+		engaged:        make(map[string]map[string]int64),
+		systemEvents:   make(map[string]int64),
+		answered:       make(map[string]answeredMessage),
+		llm:            generator,
+		statePath:      cfg.StatePath,
+		topicsPath:     cfg.TopicKeywordsPath,
+		clock:          clock,
+	}
+	p.dedup = newPlanDedup(clock.Now)
+	p.Reconfigure(cfg)
+	p.topics.Store(defaultTopicKeywords)
+	if p.topicsPath != "" {
+		if _, err := p.ReloadTopics(); err != nil {
+			logging.Warnf("planner_topics_load_failed path=%s error=%v fallback=defaults", p.topicsPath, err)
+		}
+	}
+	if cfg.ProfanityBlocklistPath != "" {
+		words, err := loadProfanityBlocklist(cfg.ProfanityBlocklistPath)
+		if err != nil {
+			logging.Warnf("planner_profanity_load_failed path=%s error=%v fallback=defaults", cfg.ProfanityBlocklistPath, err)
+		} else {
+			p.profanity = words
+			logging.Infof("planner_profanity_loaded path=%s words=%d", cfg.ProfanityBlocklistPath, len(words))
+		}
+	}
+	if p.statePath != "" {
+		p.loadState(p.clock.Now().UnixMilli())
+		interval := cfg.StateInterval
+		if interval <= 0 {
+			interval = defaultStateInterval
+		}
+		p.stateStop = make(chan struct{})
+		p.stateDone = make(chan struct{})
+		go p.runStateSaver(interval)
+	}
+	return p
+}
+
+// Reconfigure applies the reloadable settings of cfg (LLM timeout, sampling
+// defaults, history and message limits, chat clock skew, pressure
+// thresholds, engagement cooldown, quiet hours) to plans started from now on.
+func (p *Planner) Reconfigure(cfg Config) {
+	engageCooldown := cfg.EngagementCooldown
+	if engageCooldown <= 0 {
+		engageCooldown = defaultEngagementCooldown
+	}
+	recentLimit := cfg.RecentMessageLimit
+	if recentLimit <= 0 {
+		recentLimit = defaultRecentMessageLimit
+	}
+	chatMaxChars := cfg.ChatMessageMaxChars
+	if chatMaxChars <= 0 {
+		chatMaxChars = defaultChatMessageMaxChars
+	}
+	chatClockSkew := cfg.ChatClockSkew
+	if chatClockSkew <= 0 {
+		chatClockSkew = defaultChatClockSkew
+	}
+	p.tuning.Store(&plannerTuning{
 		llmTimeout: cfg.LLMTimeout,
 		chatLimit:  cfg.ChatHistoryLimit,
+		llmDefaults: models.LLMSettings{
+			Temperature:      &cfg.LLMTemperature,
+			TopP:             &cfg.LLMTopP,
+			MaxTokens:        cfg.LLMMaxTokens,
+			ChatHistoryLimit: cfg.ChatHistoryLimit,
+		},
+		pressureQueueDepth: cfg.PressureQueueDepth,
+		pressureP95:        cfg.PressureP95Latency,
+		engageCooldownMS:   engageCooldown.Milliseconds(),
+		recentMessageLimit: recentLimit,
+		chatMaxChars:       chatMaxChars,
+		chatClockSkewMS:    chatClockSkew.Milliseconds(),
+		planDeadlineMS:     cfg.PlanDeadline.Milliseconds(),
+		maxMessageChars:    cfg.MaxMessageChars,
+		quietHours:         cfg.QuietHours,
+	})
+}
+
+// quiet reports whether the bots should stay silent: settings.quiet overrides
+// the configured quiet hours either way.
+func (p *Planner) quiet(settings models.PlanSettings) bool {
+	if settings.Quiet != nil {
+		return *settings.Quiet
 	}
+	return p.tuning.Load().quietHours.Active(p.clock.Now())
 }
 
 func (p *Planner) RegisterBots(serverID string, bots []models.BotProfile) int {
@@ -67,55 +240,228 @@ func (p *Planner) RegisterBots(serverID string, bots []models.BotProfile) int {
 	return count
 }
 
-func (p *Planner) Plan(req models.PlanRequest) models.PlanResponse {
-	logging.Infof("planner_plan_start request_id=%s transaction_id=%s server_id=%s tick=%d time_ms=%d bots=%d chat_messages=%d", req.RequestID, req.RequestID, req.Server.ServerID, req.Tick, req.TimeMS, len(req.Bots), len(req.Chat))
-	rng := util.NewSeededRand(req.RequestID, fmt.Sprint(req.Tick), fmt.Sprint(req.TimeMS))
-	availableBots := filterAvailableBots(req.Bots)
-	availableBots = filterSelfReplyBots(req, availableBots)
-	if len(availableBots) == 0 {
-		logging.Infof("planner_plan_no_available_bots request_id=%s transaction_id=%s", req.RequestID, req.RequestID)
-		return models.PlanResponse{RequestID: req.RequestID}
-	}
+// Plan answers a plan request; a retry of a request planned in the last
+// minute gets the same response.
+func (p *Planner) Plan(ctx context.Context, req models.PlanRequest) models.PlanResponse {
+	return p.dedupPlan(ctx, req, func() models.PlanResponse {
+		ctx := llm.WithBackendTrace(ctx)
+		response := p.plan(ctx, req)
+		response.Debug.LLMBackends = llm.BackendsUsed(ctx)
+		if req.DryRun {
+			markDryRun(&response)
+		} else {
+			p.markDegraded(ctx, &response)
+		}
+		return response
+	})
+}
 
-	topics := detectTopics(req.Chat)
+func (p *Planner) plan(ctx context.Context, req models.PlanRequest) models.PlanResponse {
+	metrics.PlanRequests.Inc()
+	logging.Ctx(ctx).Infof("planner_plan_start request_id=%s transaction_id=%s server_id=%s tick=%d time_ms=%d bots=%d chat_messages=%d schema_version=%d", req.RequestID, req.RequestID, req.Server.ServerID, req.Tick, req.TimeMS, len(req.Bots), len(req.Chat), req.SchemaVersion)
+	req.Settings = p.serverDefaults(req.Server.ServerID, req.Settings)
+	if p.quiet(req.Settings) {
+		logging.Ctx(ctx).Infof("planner_plan_quiet_hours request_id=%s transaction_id=%s quiet_hours=%s", req.RequestID, req.RequestID, p.tuning.Load().quietHours)
+		metrics.SilenceDecisions.Inc(quietHoursReason)
+		return models.PlanResponse{
+			RequestID: req.RequestID,
+			Actions:   []models.PlannedAction{},
+			Debug:     models.PlanDebug{ChosenStrategy: quietHoursReason},
+		}
+	}
+	var truncated int
+	req.Chat, truncated = sanitizeChat(req.Chat, p.tuning.Load().chatMaxChars)
+	if truncated > 0 {
+		logging.Ctx(ctx).Infof("planner_plan_chat_truncated request_id=%s transaction_id=%s messages=%d max_chars=%d", req.RequestID, req.RequestID, truncated, p.tuning.Load().chatMaxChars)
+	}
+	rng := newRequestRand(SeedForRequest(req))
+	req.Bots = p.enrichBots(req.Server.ServerID, req.Bots)
 	settings := normalizeSettings(req.Settings)
-	logging.Debugf("planner_plan_context request_id=%s transaction_id=%s topics=%v available_bots=%v settings=%+v", req.RequestID, req.RequestID, topics, botIDs(availableBots), settings)
-
-	actions, strategy, suppressed := p.buildPlan(req, topics, availableBots, settings, rng)
-	logging.Infof("planner_plan_result request_id=%s transaction_id=%s strategy=%s actions=%d suppressed=%d", req.RequestID, req.RequestID, strategy, len(actions), suppressed)
+	req.Settings.LLM = settings.LLM
+	explain := newPlanExplainer(req.Explain)
+	onlineBots, cooldownSkipped := filterAvailableBots(req.Bots, settings)
+	notSelfBots := filterSelfReplyBots(ctx, req, onlineBots)
+	availableBots, rateLimited := p.filterRateLimited(ctx, req, notSelfBots, settings)
+	explain.botFilters(req.Bots, onlineBots, notSelfBots, availableBots)
+	required, warnings := newRequiredTracker(ctx, req, availableBots)
+	required.markUnavailable(rateLimited, "rate_limited")
+	stale := staleChat(req.Chat, req.TimeMS, settings.MaxMessageAgeMS, p.tuning.Load().chatClockSkewMS)
+	answered := !stale && p.alreadyAnswered(req.Server.ServerID, req.Chat)
+	if stale {
+		logging.Ctx(ctx).Infof("planner_plan_stale_chat request_id=%s transaction_id=%s max_message_age_ms=%d", req.RequestID, req.RequestID, settings.MaxMessageAgeMS)
+		explain.add(models.PlanDecision{Step: decisionChat, Outcome: "stale"})
+	} else if answered {
+		logging.Ctx(ctx).Infof("planner_plan_already_answered request_id=%s transaction_id=%s", req.RequestID, req.RequestID)
+		explain.add(models.PlanDecision{Step: decisionChat, Outcome: "already_answered"})
+	} else if mentioned := detectMentions(req.Chat, availableBots); len(mentioned) > 0 {
+		logging.Ctx(ctx).Infof("planner_plan_mentions request_id=%s transaction_id=%s bots=%v", req.RequestID, req.RequestID, botIDs(mentioned))
+		required.addMentions(mentioned)
+	}
+	if len(availableBots) == 0 {
+		logging.Ctx(ctx).Infof("planner_plan_no_available_bots request_id=%s transaction_id=%s cooldown_skipped=%d", req.RequestID, req.RequestID, cooldownSkipped)
+		metrics.SilenceDecisions.Inc("no_available_bots")
+		response := models.PlanResponse{
+			RequestID: req.RequestID,
+			Debug: models.PlanDebug{
+				CooldownSkipped:   cooldownSkipped,
+				RequiredFailures:  required.results(),
+				Warnings:          warnings,
+				TruncatedMessages: truncated,
+				StaleChat:         stale,
+				AlreadyAnswered:   answered,
+				SuppressedReplies: len(rateLimited),
+				RateLimited:       len(rateLimited),
+			},
+		}
+		explain.fill(&response.Debug)
+		return response
+	}
+
+	var topics []Topic
+	var topicScore float64
+	if !stale && !answered {
+		topics, topicScore = detectTopics(req.Chat, p.topicKeywords())
+		explain.topics(req.Chat, p.topicKeywords(), topics)
+		// Without a player count there is nothing to answer, and an old
+		// count would mislead the player.
+		if req.Server.OnlinePlayers <= 0 && containsTopic(topics, TopicServerInfo) {
+			explain.add(models.PlanDecision{Step: decisionTopic, Topic: string(TopicServerInfo), Outcome: "dropped_no_player_count"})
+			topics = withoutTopic(topics, TopicServerInfo)
+			if len(topics) == 0 {
+				topicScore = 0
+			}
+		}
+	}
+	logging.Ctx(ctx).Debugf("planner_plan_context request_id=%s transaction_id=%s topics=%v topic_score=%.3f available_bots=%v settings=%+v", req.RequestID, req.RequestID, topics, topicScore, botIDs(availableBots), settings)
+
+	routing := p.newLLMRouting(ctx, req)
+	deadline := p.newPlanDeadline(ctx, settings)
+	defer deadline.cancel()
+	var actions []models.PlannedAction
+	var strategy string
+	var suppressed int
+	if stale {
+		actions, strategy, suppressed = p.stalePlan(ctx, req, availableBots, required, routing, settings, rng)
+	} else {
+		actions, strategy, suppressed = p.buildPlan(ctx, req, topics, availableBots, required, routing, deadline, explain, settings, rng)
+	}
+	if ctx.Err() != nil {
+		strategy += cancelledSuffix
+	}
+	logging.Ctx(ctx).Infof("planner_plan_result request_id=%s transaction_id=%s strategy=%s actions=%d suppressed=%d", req.RequestID, req.RequestID, strategy, len(actions), suppressed)
+	if !req.DryRun {
+		metrics.ActionsEmitted.Add(len(actions))
+		p.trackPendingActions(req, actions)
+		if len(actions) > 0 && (len(topics) > 0 || len(required.mentioned) > 0) {
+			p.rememberAnswered(req.Server.ServerID, req.Chat)
+		}
+	}
 
-	return models.PlanResponse{
+	response := models.PlanResponse{
 		RequestID: req.RequestID,
 		Actions:   actions,
 		Debug: models.PlanDebug{
 			ChosenStrategy:    strategy,
-			SuppressedReplies: suppressed,
+			SuppressedReplies: suppressed + len(rateLimited),
+			RateLimited:       len(rateLimited),
+			CooldownSkipped:   cooldownSkipped,
+			RequiredFailures:  required.results(),
+			Warnings:          warnings,
+			LLMRouting:        routing.label(),
+			TruncatedMessages: truncated,
+			LLMSettings:       p.effectiveLLMSettings(settings.LLM),
+			TopicScore:        topicScore,
+			StaleChat:         stale,
+			AlreadyAnswered:   answered,
 		},
 	}
+	if len(topics) > 0 {
+		response.Debug.Topic = string(topics[0])
+	}
+	routing.fill(&response.Debug)
+	deadline.fill(&response.Debug)
+	explain.fill(&response.Debug)
+	return response
 }
 
-func filterAvailableBots(bots []models.BotProfile) []models.BotProfile {
-	onlineSpecified := false
-	for _, bot := range bots {
-		if bot.Online {
-			onlineSpecified = true
-			break
+func (p *Planner) enrichBots(serverID string, bots []models.BotProfile) []models.BotProfile {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	registered := p.registry[serverID]
+	if len(registered) == 0 {
+		return bots
+	}
+	enriched := make([]models.BotProfile, len(bots))
+	for i, bot := range bots {
+		enriched[i] = bot
+		profile, ok := registered[bot.BotID]
+		if !ok || bot.BotID == "" {
+			continue
 		}
+		enriched[i] = mergeBotProfile(bot, profile)
+	}
+	return enriched
+}
+
+func mergeBotProfile(requested, registered models.BotProfile) models.BotProfile {
+	merged := requested
+	if merged.Name == "" {
+		merged.Name = registered.Name
+	}
+	persona := &merged.Persona
+	if persona.Language == "" {
+		persona.Language = registered.Persona.Language
+	}
+	if persona.Tone == "" {
+		persona.Tone = registered.Persona.Tone
+	}
+	if len(persona.StyleTags) == 0 {
+		persona.StyleTags = registered.Persona.StyleTags
+	}
+	if len(persona.AvoidTopics) == 0 {
+		persona.AvoidTopics = registered.Persona.AvoidTopics
+	}
+	if len(persona.Interests) == 0 {
+		persona.Interests = registered.Persona.Interests
+	}
+	if len(persona.Catchphrases) == 0 {
+		persona.Catchphrases = registered.Persona.Catchphrases
+	}
+	if len(persona.Quirks) == 0 {
+		persona.Quirks = registered.Persona.Quirks
 	}
+	if persona.KnowledgeLevel == "" {
+		persona.KnowledgeLevel = registered.Persona.KnowledgeLevel
+	}
+	if merged.LLMOverrides == nil {
+		merged.LLMOverrides = registered.LLMOverrides
+	}
+	return merged
+}
+
+// filterAvailableBots keeps bots whose cooldown ends before the latest
+// possible send time; randomDelay then holds their action until it has passed.
+func filterAvailableBots(bots []models.BotProfile, settings models.PlanSettings) ([]models.BotProfile, int) {
 	available := make([]models.BotProfile, 0, len(bots))
+	cooldownSkipped := 0
 	for _, bot := range bots {
-		if onlineSpecified && !bot.Online {
+		if !bot.IsOnline() {
 			continue
 		}
-		if bot.CooldownMS > 0 {
+		if bot.CooldownMS >= settings.MaxDelayMS {
+			cooldownSkipped++
 			continue
 		}
 		available = append(available, bot)
 	}
-	return available
+	return available, cooldownSkipped
 }
 
-func filterSelfReplyBots(req models.PlanRequest, bots []models.BotProfile) []models.BotProfile {
+func filterSelfReplyBots(ctx context.Context, req models.PlanRequest, bots []models.BotProfile) []models.BotProfile {
 	last := latestChatMessage(req.Chat)
 	if last == nil {
 		return bots
@@ -126,7 +472,7 @@ func filterSelfReplyBots(req models.PlanRequest, bots []models.BotProfile) []mod
 	filtered := make([]models.BotProfile, 0, len(bots))
 	for _, bot := range bots {
 		if isSameSender(bot, *last) {
-			logging.Debugf("planner_plan_skip_self_reply request_id=%s transaction_id=%s bot_id=%s sender=%s", req.RequestID, req.RequestID, bot.BotID, last.Sender)
+			logging.Ctx(ctx).Debugf("planner_plan_skip_self_reply request_id=%s transaction_id=%s bot_id=%s sender=%s", req.RequestID, req.RequestID, bot.BotID, last.Sender)
 			continue
 		}
 		filtered = append(filtered, bot)
@@ -176,29 +522,70 @@ func normalizeSettings(settings models.PlanSettings) models.PlanSettings {
 	if settings.GlobalSilenceChance > 1 {
 		settings.GlobalSilenceChance = 1
 	}
+	if settings.BanterChance < 0 {
+		settings.BanterChance = 0
+	}
+	if settings.BanterChance > 1 {
+		settings.BanterChance = 1
+	}
+	if settings.UninterestedWeight <= 0 {
+		settings.UninterestedWeight = defaultUninterestedWeight
+	}
+	if settings.LLM != nil {
+		settings.LLM = normalizeLLMSettings(*settings.LLM)
+	}
 	return settings
 }
 
-func (p *Planner) buildPlan(req models.PlanRequest, topics []Topic, bots []models.BotProfile, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, string, int) {
+func (p *Planner) buildPlan(ctx context.Context, req models.PlanRequest, topics []Topic, bots []models.BotProfile, required *requiredTracker, routing *llmRouting, deadline *planDeadline, explain *planExplainer, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, string, int) {
 	strategy := "heuristics"
+	if !containsTopic(topics, TopicToxic) {
+		if announcement, ok := recentSystemEvent(req, p.topicKeywords()); ok && !p.systemEventOnCooldown(req.Server.ServerID, req.TimeMS) {
+			actions, llmAttempted, llmUsed := p.systemEventPlan(ctx, req, announcement, bots, required, routing, settings, rng)
+			if len(actions) > 0 {
+				return actions, strategyLabel("system_event", llmAttempted, llmUsed), 0
+			}
+		}
+	}
 	if len(topics) == 0 {
-		if rng.Float64() < settings.GlobalSilenceChance {
-			logging.Infof("planner_plan_silence request_id=%s transaction_id=%s reason=global_silence", req.RequestID, req.RequestID)
-			return nil, "silence", 1
+		if !required.prioritized() {
+			roll := rng.Float64()
+			if roll < settings.GlobalSilenceChance {
+				explain.roll(decisionSilence, roll, settings.GlobalSilenceChance, "silence")
+				logging.Ctx(ctx).Infof("planner_plan_silence request_id=%s transaction_id=%s reason=global_silence", req.RequestID, req.RequestID)
+				metrics.SilenceDecisions.Inc("global_silence")
+				return nil, "silence", 1
+			}
+			explain.roll(decisionSilence, roll, settings.GlobalSilenceChance, "speak")
 		}
-		logging.Debugf("planner_plan_small_talk request_id=%s transaction_id=%s", req.RequestID, req.RequestID)
-		actions, llmAttempted, llmUsed := p.smallTalkPlan(req, bots, settings, rng)
+		if shouldBanter(bots, required, settings, rng) {
+			actions, llmAttempted, llmUsed := p.banterPlan(ctx, req, bots, routing, settings, rng)
+			if len(actions) > 0 {
+				return actions, strategyLabel(string(models.ReasonBanter), llmAttempted, llmUsed), 0
+			}
+		}
+		logging.Ctx(ctx).Debugf("planner_plan_small_talk request_id=%s transaction_id=%s", req.RequestID, req.RequestID)
+		actions, llmAttempted, llmUsed := p.smallTalkPlan(ctx, req, bots, required, routing, settings, rng)
 		return actions, strategyLabel("small_talk", llmAttempted, llmUsed), 0
 	}
 
 	if containsTopic(topics, TopicToxic) {
-		logging.Infof("planner_plan_toxic_silence request_id=%s transaction_id=%s topic=%s", req.RequestID, req.RequestID, TopicToxic)
+		logging.Ctx(ctx).Infof("planner_plan_toxic_silence request_id=%s transaction_id=%s topic=%s", req.RequestID, req.RequestID, TopicToxic)
+		metrics.SilenceDecisions.Inc("toxic")
+		explain.add(models.PlanDecision{Step: decisionTopic, Topic: string(TopicToxic), Outcome: "toxic_silence"})
+		required.failAll("toxic_silence")
 		return nil, "toxic_silence", len(bots)
 	}
 
-	if rng.Float64() > settings.ReplyChance {
-		logging.Infof("planner_plan_reply_suppressed request_id=%s transaction_id=%s reply_chance=%.2f", req.RequestID, req.RequestID, settings.ReplyChance)
-		return nil, "reply_suppressed", 1
+	if !required.prioritized() {
+		roll := rng.Float64()
+		if roll > settings.ReplyChance {
+			explain.roll(decisionReply, roll, settings.ReplyChance, "suppressed")
+			logging.Ctx(ctx).Infof("planner_plan_reply_suppressed request_id=%s transaction_id=%s reply_chance=%.2f", req.RequestID, req.RequestID, settings.ReplyChance)
+			metrics.SilenceDecisions.Inc("reply_suppressed")
+			return nil, "reply_suppressed", 1
+		}
+		explain.roll(decisionReply, roll, settings.ReplyChance, "reply")
 	}
 
 	actions := make([]models.PlannedAction, 0, settings.MaxActions)
@@ -206,51 +593,160 @@ func (p *Planner) buildPlan(req models.PlanRequest, topics []Topic, bots []model
 	llmAttempted := false
 	llmUsed := false
 
-	selectedBots := pickBots(bots, settings.MaxActions, rng)
-	logging.Debugf("planner_plan_selected_bots request_id=%s transaction_id=%s bots=%v topics=%v", req.RequestID, req.RequestID, botIDs(selectedBots), topics)
+	selector := p.newBotSelector(req.Server.ServerID, settings, req.TimeMS).forTopic(topics[0], settings)
+	selectedBots := required.selectBots(bots, settings.MaxActions, selector, rng)
+	explain.selection(bots, selectedBots)
+	logging.Ctx(ctx).Debugf("planner_plan_selected_bots request_id=%s transaction_id=%s bots=%v topics=%v", req.RequestID, req.RequestID, botIDs(selectedBots), topics)
+	pending := make([]generationJob, 0, len(topics)*len(selectedBots))
 	for _, topic := range topics {
 		for _, bot := range selectedBots {
-			if len(actions) >= settings.MaxActions {
-				break
+			pending = append(pending, generationJob{topic: topic, bot: bot})
+		}
+	}
+	// The LLM soft timeout bounds the whole generation phase rather than
+	// each call, so stragglers are cancelled and fall back to heuristics.
+	generateCtx := deadline.ctx
+	if timeout := p.tuning.Load().llmTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		generateCtx, cancel = context.WithTimeout(generateCtx, timeout)
+		defer cancel()
+	}
+	replied := make(map[string]bool)
+	for len(pending) > 0 && len(actions) < settings.MaxActions {
+		// Each round asks every bot at most once; a bot whose reply came
+		// back empty gets its next topic in the following round.
+		round := make([]*generationJob, 0, settings.MaxActions-len(actions))
+		busy := make(map[string]bool)
+		var deferred []generationJob
+		for len(pending) > 0 && len(round) < settings.MaxActions-len(actions) {
+			job := pending[0]
+			pending = pending[1:]
+			topic, bot := job.topic, job.bot
+			// A bot speaks at most once per plan; its other topics are
+			// dropped once it has replied to one.
+			if replied[bot.BotID] {
+				logging.Ctx(ctx).Debugf("planner_plan_bot_already_replied request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+				explain.candidate(bot.BotID, topic, "already_replied")
+				suppressed++
+				continue
 			}
-			if p.shouldSuppress(req.Server.ServerID, bot.BotID, topic, req.TimeMS) {
-				logging.Debugf("planner_plan_suppress request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+			if busy[bot.BotID] {
+				deferred = append(deferred, job)
+				continue
+			}
+			bypassCooldown := required.bypassCooldown && required.isRequired(bot.BotID)
+			if !bypassCooldown && p.shouldSuppress(req.Server.ServerID, bot.BotID, topic, req.TimeMS, topicCooldown(settings, topic, p.topicKeywords())) {
+				logging.Ctx(ctx).Debugf("planner_plan_suppress request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+				required.fail(bot.BotID, "topic_cooldown")
+				explain.candidate(bot.BotID, topic, "topic_cooldown")
 				suppressed++
 				continue
 			}
-			message, reason, attempted, used := p.generateMessage(req, topic, bot, rng)
-			if attempted {
+			if deadline.exceeded() {
+				deadline.skip(bot.BotID)
+				required.fail(bot.BotID, deadlineExceededReason)
+				explain.candidate(bot.BotID, topic, deadlineExceededReason)
+				continue
+			}
+			if requestExpired(ctx, req.RequestID) {
+				required.fail(bot.BotID, "cancelled")
+				explain.candidate(bot.BotID, topic, "cancelled")
+				continue
+			}
+			job.rng = rand.New(rand.NewSource(rng.Int63()))
+			job.mustReply = required.mustReply(bot.BotID)
+			busy[bot.BotID] = true
+			round = append(round, &job)
+		}
+		pending = append(deferred, pending...)
+		if len(round) == 0 {
+			continue
+		}
+		p.generateAll(generateCtx, req, round, routing, deadline)
+		// Replies cut off by the deadline fell back to heuristics; note the
+		// hit so the debug output explains them.
+		deadline.exceeded()
+		for _, job := range round {
+			topic, bot, message, reason := job.topic, job.bot, job.message, job.reason
+			if job.skipped {
+				if deadline.exceeded() {
+					deadline.skip(bot.BotID)
+					required.fail(bot.BotID, deadlineExceededReason)
+					explain.candidate(bot.BotID, topic, deadlineExceededReason)
+				} else {
+					required.fail(bot.BotID, "cancelled")
+					explain.candidate(bot.BotID, topic, "cancelled")
+				}
+				continue
+			}
+			explain.generation(job)
+			if job.attempted {
 				llmAttempted = true
 			}
-			if used {
+			if job.used {
 				llmUsed = true
 			}
 			if message == "" {
-				logging.Debugf("planner_plan_no_message request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+				logging.Ctx(ctx).Debugf("planner_plan_no_message request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+				required.fail(bot.BotID, "no_message")
 				continue
 			}
-			actions = append(actions, models.PlannedAction{
-				BotID:       bot.BotID,
-				SendAfterMS: randomDelay(settings, rng),
-				Message:     message,
-				Visibility:  "PUBLIC",
-				Reason:      reason,
-			})
-			p.remember(req.Server.ServerID, bot.BotID, topic, req.TimeMS)
-			logging.Infof("planner_plan_action request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
+			if required.isMentioned(bot.BotID) {
+				reason = models.ReasonDirectMention
+			}
+			message = p.styleMessage(settings, bot, message, routing, job.rng)
+			action := models.PlannedAction{
+				BotID:        bot.BotID,
+				SendAfterMS:  randomDelay(settings, bot.CooldownMS, rng),
+				Message:      message,
+				Visibility:   visibilityPublic,
+				Reason:       reason,
+				Source:       job.source,
+				GenerationMS: job.generationMS,
+			}
+			if target := whisperTarget(req.Chat, settings, topic); target != "" {
+				action.Visibility = visibilityWhisper
+				action.TargetPlayer = target
+				action.Reason += models.ReasonWhisperSuffix
+			}
+			actions = append(actions, action)
+			replied[bot.BotID] = true
+			p.recordAction(req, bot.BotID, topic, message)
+			required.succeed(bot.BotID)
+			logging.Ctx(ctx).Infof("planner_plan_action request_id=%s transaction_id=%s bot_id=%s topic=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, topic, reason)
 		}
 	}
+	if deadline.hit {
+		logging.Ctx(ctx).Infof("planner_plan_deadline_exceeded request_id=%s transaction_id=%s actions=%d skipped_bots=%d", req.RequestID, req.RequestID, len(actions), len(deadline.skipped))
+	}
+	for _, job := range pending {
+		required.fail(job.bot.BotID, "max_actions")
+		explain.candidate(job.bot.BotID, job.topic, "max_actions")
+	}
 	return actions, strategyLabel(strategy, llmAttempted, llmUsed), suppressed
 }
 
-func (p *Planner) smallTalkPlan(req models.PlanRequest, bots []models.BotProfile, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, bool, bool) {
-	selected := pickBots(bots, 1, rng)
-	logging.Debugf("planner_plan_small_talk_bots request_id=%s transaction_id=%s bots=%v", req.RequestID, req.RequestID, botIDs(selected))
+func (p *Planner) smallTalkPlan(ctx context.Context, req models.PlanRequest, bots []models.BotProfile, required *requiredTracker, routing *llmRouting, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, bool, bool) {
+	limit := 1
+	if required.prioritized() {
+		limit = len(required.bots()) + len(required.mentioned)
+		if limit > settings.MaxActions {
+			limit = settings.MaxActions
+		}
+	}
+	selected := required.selectBots(bots, limit, p.newBotSelector(req.Server.ServerID, settings, req.TimeMS), rng)
+	logging.Ctx(ctx).Debugf("planner_plan_small_talk_bots request_id=%s transaction_id=%s bots=%v", req.RequestID, req.RequestID, botIDs(selected))
 	actions := make([]models.PlannedAction, 0, 1)
 	llmAttempted := false
 	llmUsed := false
 	for _, bot := range selected {
-		message, reason, attempted, used := p.generateMessage(req, "", bot, rng)
+		if requestExpired(ctx, req.RequestID) {
+			required.fail(bot.BotID, "cancelled")
+			continue
+		}
+		start := p.clock.Now()
+		message, reason, attempted, used, _ := p.generateMessage(ctx, req, "", bot, required.mustReply(bot.BotID), llm.Request{}, routing, rng)
+		source, generationMS := routing.observe(p.clock.Now().Sub(start), attempted, used)
 		if attempted {
 			llmAttempted = true
 		}
@@ -258,26 +754,64 @@ func (p *Planner) smallTalkPlan(req models.PlanRequest, bots []models.BotProfile
 			llmUsed = true
 		}
 		if message == "" {
-			logging.Debugf("planner_plan_small_talk_no_message request_id=%s transaction_id=%s bot_id=%s", req.RequestID, req.RequestID, bot.BotID)
+			logging.Ctx(ctx).Debugf("planner_plan_small_talk_no_message request_id=%s transaction_id=%s bot_id=%s", req.RequestID, req.RequestID, bot.BotID)
+			required.fail(bot.BotID, "no_message")
 			continue
 		}
+		if required.isMentioned(bot.BotID) {
+			reason = models.ReasonDirectMention
+		}
+		message = p.styleMessage(settings, bot, message, routing, rng)
 		actions = append(actions, models.PlannedAction{
-			BotID:       bot.BotID,
-			SendAfterMS: randomDelay(settings, rng),
-			Message:     message,
-			Visibility:  "PUBLIC",
-			Reason:      reason,
+			BotID:        bot.BotID,
+			SendAfterMS:  randomDelay(settings, bot.CooldownMS, rng),
+			Message:      message,
+			Visibility:   visibilityPublic,
+			Reason:       reason,
+			Source:       source,
+			GenerationMS: generationMS,
 		})
-		p.remember(req.Server.ServerID, bot.BotID, "small_talk", req.TimeMS)
-		logging.Infof("planner_plan_small_talk_action request_id=%s transaction_id=%s bot_id=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, reason)
+		p.recordAction(req, bot.BotID, "small_talk", message)
+		required.succeed(bot.BotID)
+		logging.Ctx(ctx).Infof("planner_plan_small_talk_action request_id=%s transaction_id=%s bot_id=%s reason=%s", req.RequestID, req.RequestID, bot.BotID, reason)
 	}
 	return actions, llmAttempted, llmUsed
 }
 
-func (p *Planner) shouldSuppress(serverID, botID string, topic Topic, nowMS int64) bool {
+// requestExpired reports whether the caller gave up on the request (deadline
+// or disconnect); the plan then keeps the actions built so far instead of
+// waiting for more LLM calls.
+func requestExpired(ctx context.Context, requestID string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	logging.Ctx(ctx).Infof("planner_plan_request_expired request_id=%s transaction_id=%s error=%v", requestID, requestID, ctx.Err())
+	return true
+}
+
+// topicCooldown resolves the cooldown for a topic: a per-topic request
+// override wins over the request-wide value, then the topics file, then the
+// default.
+func topicCooldown(settings models.PlanSettings, topic Topic, keywords *topicKeywords) int64 {
+	if cooldown, ok := settings.TopicCooldowns[string(topic)]; ok && cooldown >= 0 {
+		return cooldown
+	}
+	if settings.TopicCooldownMS != nil && *settings.TopicCooldownMS >= 0 {
+		return *settings.TopicCooldownMS
+	}
+	if cooldown, ok := keywords.cooldown(topic); ok {
+		return cooldown
+	}
+	return topicCooldownMS
+}
+
+func (p *Planner) shouldSuppress(serverID, botID string, topic Topic, nowMS, cooldownMS int64) bool {
 	if botID == "" {
 		return true
 	}
+	if cooldownMS <= 0 {
+		return false
+	}
 	if serverID == "" {
 		serverID = "default"
 	}
@@ -293,12 +827,22 @@ func (p *Planner) shouldSuppress(serverID, botID string, topic Topic, nowMS int6
 		return false
 	}
 	lastSent, ok := last.LastSentByTopic[topic]
-	if ok && nowMS-lastSent < topicCooldownMS {
+	if ok && nowMS-lastSent < cooldownMS {
 		return true
 	}
 	return false
 }
 
+// recordAction updates cooldowns and the repetition memory for an emitted
+// action; dry runs leave the planner state untouched.
+func (p *Planner) recordAction(req models.PlanRequest, botID string, topic Topic, message string) {
+	if req.DryRun {
+		return
+	}
+	p.remember(req.Server.ServerID, botID, topic, req.TimeMS)
+	p.rememberMessage(req.Server.ServerID, botID, message)
+}
+
 func (p *Planner) remember(serverID, botID string, topic Topic, nowMS int64) {
 	if serverID == "" {
 		serverID = "default"
@@ -314,6 +858,8 @@ func (p *Planner) remember(serverID, botID string, topic Topic, nowMS int64) {
 		last.LastSentByTopic = make(map[Topic]int64)
 	}
 	last.LastSentByTopic[topic] = nowMS
+	last.LastActionMS = nowMS
+	last.RecentActionsMS = append(pruneActions(last.RecentActionsMS, nowMS), nowMS)
 	p.memory[serverID][botID] = last
 }
 
@@ -326,6 +872,16 @@ func containsTopic(topics []Topic, target Topic) bool {
 	return false
 }
 
+func withoutTopic(topics []Topic, target Topic) []Topic {
+	kept := make([]Topic, 0, len(topics))
+	for _, topic := range topics {
+		if topic != target {
+			kept = append(kept, topic)
+		}
+	}
+	return kept
+}
+
 func pickBots(bots []models.BotProfile, max int, rng *rand.Rand) []models.BotProfile {
 	if len(bots) <= max {
 		return bots
@@ -348,10 +904,14 @@ func botIDs(bots []models.BotProfile) []string {
 	return ids
 }
 
-func randomDelay(settings models.PlanSettings, rng *rand.Rand) int64 {
-	span := settings.MaxDelayMS - settings.MinDelayMS
+func randomDelay(settings models.PlanSettings, cooldownMS int64, rng *rand.Rand) int64 {
+	minDelay := settings.MinDelayMS
+	if cooldownMS > minDelay {
+		minDelay = cooldownMS
+	}
+	span := settings.MaxDelayMS - minDelay
 	if span <= 0 {
-		return settings.MinDelayMS
+		return minDelay
 	}
-	return settings.MinDelayMS + rng.Int63n(span+1)
+	return minDelay + rng.Int63n(span+1)
 }
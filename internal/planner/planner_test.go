@@ -2,8 +2,13 @@ package planner
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"aichatplayers/internal/llm"
 	"aichatplayers/internal/models"
@@ -38,7 +43,7 @@ func TestPlannerFallbacksToHeuristics(t *testing.T) {
 			{
 				BotID:  "bot-1",
 				Name:   "Kuba",
-				Online: true,
+				Online: boolPtr(true),
 				Persona: models.Persona{
 					Language:       "pl",
 					Tone:           "casual",
@@ -64,7 +69,7 @@ func TestPlannerFallbacksToHeuristics(t *testing.T) {
 		},
 	}
 
-	resp := planner.Plan(req)
+	resp := planner.Plan(context.Background(), req)
 	if len(resp.Actions) != 1 {
 		t.Fatalf("expected 1 action, got %d", len(resp.Actions))
 	}
@@ -89,9 +94,8 @@ func TestPlannerTreatsBotsOnlineWhenFlagOmitted(t *testing.T) {
 		TimeMS: 1712345000000,
 		Bots: []models.BotProfile{
 			{
-				BotID:  "bot-1",
-				Name:   "Kuba",
-				Online: false,
+				BotID: "bot-1",
+				Name:  "Kuba",
 				Persona: models.Persona{
 					Language:       "pl",
 					Tone:           "casual",
@@ -117,7 +121,7 @@ func TestPlannerTreatsBotsOnlineWhenFlagOmitted(t *testing.T) {
 		},
 	}
 
-	resp := planner.Plan(req)
+	resp := planner.Plan(context.Background(), req)
 	if len(resp.Actions) != 1 {
 		t.Fatalf("expected 1 action, got %d", len(resp.Actions))
 	}
@@ -125,3 +129,214 @@ func TestPlannerTreatsBotsOnlineWhenFlagOmitted(t *testing.T) {
 		t.Fatalf("expected heuristics strategy, got %s", resp.Debug.ChosenStrategy)
 	}
 }
+
+type capturingLLM struct {
+	requests []llm.Request
+}
+
+func (c *capturingLLM) Enabled() bool { return true }
+
+func (c *capturingLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	c.requests = append(c.requests, req)
+	return "siema", nil
+}
+
+func (c *capturingLLM) Close() error { return nil }
+
+func TestPlannerEnrichesBotsFromRegistry(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{})
+	planner.RegisterBots("srv-1", []models.BotProfile{
+		{
+			BotID: "bot_01",
+			Name:  "Kuba",
+			Persona: models.Persona{
+				Language:       "pl",
+				Tone:           "casual",
+				StyleTags:      []string{"short", "memes_light"},
+				KnowledgeLevel: "average_player",
+			},
+		},
+		{
+			BotID: "bot_02",
+			Name:  "Maja",
+		},
+	})
+
+	var bot models.BotProfile
+	if err := json.Unmarshal([]byte(`{"bot_id":"bot_01","online":true}`), &bot); err != nil {
+		t.Fatalf("unmarshal bot: %v", err)
+	}
+	req := models.PlanRequest{
+		RequestID: "req-3",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{bot},
+		Chat: []models.ChatMessage{
+			{
+				TimestampMS: 1712344999000,
+				Sender:      "RealPlayer123",
+				SenderType:  "PLAYER",
+				Message:     "siema",
+			},
+		},
+		Settings: models.PlanSettings{MaxActions: 2, ReplyChance: 1},
+	}
+
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 1 || resp.Actions[0].BotID != "bot_01" {
+		t.Fatalf("expected a single action from bot_01, got %+v", resp.Actions)
+	}
+	if len(generator.requests) != 1 {
+		t.Fatalf("expected 1 llm request, got %d", len(generator.requests))
+	}
+	persona := generator.requests[0].Bot.Persona
+	if persona.Tone != "casual" {
+		t.Fatalf("expected registered tone, got %q", persona.Tone)
+	}
+	if strings.Join(persona.StyleTags, ",") != "short,memes_light" {
+		t.Fatalf("expected registered style tags, got %v", persona.StyleTags)
+	}
+	if generator.requests[0].Bot.Name != "Kuba" {
+		t.Fatalf("expected registered name, got %q", generator.requests[0].Bot.Name)
+	}
+}
+
+func TestMergeBotProfilePrefersRequestFields(t *testing.T) {
+	merged := mergeBotProfile(
+		models.BotProfile{BotID: "bot_01", Persona: models.Persona{Tone: "friendly"}},
+		models.BotProfile{BotID: "bot_01", Name: "Kuba", Persona: models.Persona{Tone: "casual", Language: "pl"}},
+	)
+	if merged.Persona.Tone != "friendly" {
+		t.Fatalf("expected request tone to win, got %q", merged.Persona.Tone)
+	}
+	if merged.Persona.Language != "pl" || merged.Name != "Kuba" {
+		t.Fatalf("expected registry fields to fill gaps, got %+v", merged)
+	}
+}
+
+func TestEventCountdownRendersPolishPlural(t *testing.T) {
+	chat := []models.ChatMessage{
+		{Sender: "Admin", SenderType: "SYSTEM", Message: "Event start za 3 minuty!"},
+	}
+	startsIn, ok := eventCountdown(chat, defaultTopicKeywords)
+	if !ok || startsIn != 3*time.Minute {
+		t.Fatalf("eventCountdown() = %s, %t", startsIn, ok)
+	}
+	message, reason := generateResponse(TopicEvent, models.BotProfile{BotID: "bot-1"}, models.ServerContext{}, "", chat, defaultTopicKeywords, rand.New(rand.NewSource(1)))
+	if reason != "react_to_event" || !strings.Contains(message, "za 3 minuty") {
+		t.Fatalf("expected countdown in event message, got %q (%s)", message, reason)
+	}
+}
+
+func TestPlannerUsesEnglishTemplatesForEnglishPersona(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	resp := planner.Plan(context.Background(), models.PlanRequest{
+		RequestID: "req-en",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots: []models.BotProfile{
+			{BotID: "bot-1", Name: "Jake", Persona: models.Persona{Language: "en"}},
+		},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "hej wszyscy"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	})
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected 1 action, got %d", len(resp.Actions))
+	}
+	found := false
+	for _, template := range templateSets["en"].GreetingNamed {
+		if resp.Actions[0].Message == fmt.Sprintf(template, "RealPlayer123") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected English greeting template, got %q", resp.Actions[0].Message)
+	}
+}
+
+func TestPlannerCooldownAwareAvailability(t *testing.T) {
+	tests := []struct {
+		name        string
+		cooldownMS  int64
+		wantActions int
+		wantSkipped int
+	}{
+		{name: "no cooldown", cooldownMS: 0, wantActions: 1},
+		{name: "cooldown shorter than max delay", cooldownMS: 3000, wantActions: 1},
+		{name: "cooldown longer than max delay", cooldownMS: 6000, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(noopLLM{}, Config{})
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "req-cooldown",
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba", CooldownMS: tt.cooldownMS}},
+				Chat: []models.ChatMessage{
+					{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+				},
+				Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1, MinDelayMS: 200, MaxDelayMS: 4500},
+			})
+			if len(resp.Actions) != tt.wantActions {
+				t.Fatalf("expected %d actions, got %+v", tt.wantActions, resp.Actions)
+			}
+			if resp.Debug.CooldownSkipped != tt.wantSkipped {
+				t.Fatalf("cooldown_skipped = %d, want %d", resp.Debug.CooldownSkipped, tt.wantSkipped)
+			}
+			for _, action := range resp.Actions {
+				if action.SendAfterMS < tt.cooldownMS || action.SendAfterMS > 4500 {
+					t.Fatalf("send_after_ms = %d, want within [%d, 4500]", action.SendAfterMS, tt.cooldownMS)
+				}
+			}
+		})
+	}
+}
+
+func TestPlannerReconfigure(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{LLMTemperature: 0.7, RecentMessageLimit: 10})
+	if tuning := planner.tuning.Load(); tuning.recentMessageLimit != 10 || *tuning.llmDefaults.Temperature != 0.7 {
+		t.Fatalf("initial tuning = %+v", tuning)
+	}
+
+	planner.Reconfigure(Config{LLMTemperature: 1.2, EngagementCooldown: 5 * time.Second})
+	tuning := planner.tuning.Load()
+	if *tuning.llmDefaults.Temperature != 1.2 || tuning.engageCooldownMS != 5000 {
+		t.Fatalf("reconfigured tuning = %+v", tuning)
+	}
+	if tuning.recentMessageLimit != defaultRecentMessageLimit || tuning.chatMaxChars != defaultChatMessageMaxChars {
+		t.Fatalf("expected defaults for unset limits, got %+v", tuning)
+	}
+}
+
+func TestFilterAvailableBotsOnlineFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		bots string
+		want []string
+	}{
+		{name: "omitted flag is online", bots: `[{"bot_id":"a"},{"bot_id":"b"}]`, want: []string{"a", "b"}},
+		{name: "explicit false is offline", bots: `[{"bot_id":"a"},{"bot_id":"b","online":false},{"bot_id":"c","online":true}]`, want: []string{"a", "c"}},
+		{name: "all offline", bots: `[{"bot_id":"a","online":false},{"bot_id":"b","online":false}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bots []models.BotProfile
+			if err := json.Unmarshal([]byte(tt.bots), &bots); err != nil {
+				t.Fatalf("decode bots: %v", err)
+			}
+			available, _ := filterAvailableBots(bots, models.PlanSettings{MaxDelayMS: 1000})
+			var got []string
+			for _, bot := range available {
+				got = append(got, bot.BotID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("available = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
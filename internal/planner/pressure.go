@@ -0,0 +1,92 @@
+package planner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+const llmReservedRouting = "llm_reserved"
+
+// llmRouting is shared by the generation workers of one plan; mu guards
+// reserved and the embedded stats.
+type llmRouting struct {
+	mu        sync.Mutex
+	pressured bool
+	reserved  int
+	generationStats
+}
+
+func (p *Planner) newLLMRouting(ctx context.Context, req models.PlanRequest) *llmRouting {
+	routing := &llmRouting{}
+	provider, ok := p.llm.(llm.StatsProvider)
+	if !ok || !p.llm.Enabled() {
+		return routing
+	}
+	stats := provider.Stats()
+	routing.pressured = stats.BreakerOpen ||
+		(p.tuning.Load().pressureQueueDepth > 0 && stats.Queued >= p.tuning.Load().pressureQueueDepth) ||
+		(p.tuning.Load().pressureP95 > 0 && stats.P95Latency >= p.tuning.Load().pressureP95)
+	if routing.pressured {
+		logging.Ctx(ctx).Infof("planner_llm_pressure request_id=%s transaction_id=%s queued=%d in_flight=%d p95_ms=%d breaker_open=%t", req.RequestID, req.RequestID, stats.Queued, stats.InFlight, stats.P95Latency.Milliseconds(), stats.BreakerOpen)
+	}
+	return routing
+}
+
+// reserve reports whether a low-value topic should skip the LLM so that
+// capacity stays available for help, event and engagement replies. Bots
+// that must reply, because they are required or were mentioned, always keep
+// the LLM.
+func (r *llmRouting) reserve(topic Topic, mustReply bool) bool {
+	if r == nil || !r.pressured || mustReply {
+		return false
+	}
+	if topic != "" && topic != TopicGreeting {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved++
+	return true
+}
+
+// LLMState reports "unavailable" when the client failed to start,
+// "disabled", the circuit breaker state ("closed", "open", "half_open") or
+// "enabled" when the generator has no breaker.
+func (p *Planner) LLMState() string {
+	if p.llmUnavailable.Load() {
+		return llmUnavailableState
+	}
+	if p.llm == nil || !p.llm.Enabled() {
+		return "disabled"
+	}
+	if provider, ok := p.llm.(llm.StatsProvider); ok {
+		if state := provider.Stats().BreakerState; state != "" {
+			return state
+		}
+	}
+	return "enabled"
+}
+
+func (r *llmRouting) observe(elapsed time.Duration, attempted, used bool) (string, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generationStats.observe(elapsed, attempted, used)
+}
+
+func (r *llmRouting) countTruncated() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generationStats.countTruncated()
+}
+
+func (r *llmRouting) label() string {
+	if r == nil || r.reserved == 0 {
+		return ""
+	}
+	return llmReservedRouting
+}
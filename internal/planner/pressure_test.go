@@ -0,0 +1,100 @@
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+type pressuredLLM struct {
+	fakeLLM
+	stats llm.Stats
+}
+
+func (p pressuredLLM) Stats() llm.Stats { return p.stats }
+
+func TestPlannerReservesLLMUnderPressure(t *testing.T) {
+	const llmMessage = "siema z llm"
+	calm := llm.Stats{}
+	queued := llm.Stats{Queued: 3}
+	slow := llm.Stats{P95Latency: 2 * time.Second}
+	breaker := llm.Stats{BreakerOpen: true}
+
+	tests := []struct {
+		name        string
+		stats       llm.Stats
+		message     string
+		wantLLM     bool
+		wantRouting string
+	}{
+		{name: "calm greeting", stats: calm, message: "siema", wantLLM: true},
+		{name: "calm small talk", stats: calm, message: "nudzi mi sie", wantLLM: true},
+		{name: "queued greeting", stats: queued, message: "siema", wantRouting: "llm_reserved"},
+		{name: "queued small talk", stats: queued, message: "nudzi mi sie", wantRouting: "llm_reserved"},
+		{name: "queued help", stats: queued, message: "jak zrobic portal?", wantLLM: true},
+		{name: "slow pvp", stats: slow, message: "kto pvp?", wantLLM: true},
+		{name: "slow greeting", stats: slow, message: "siema", wantRouting: "llm_reserved"},
+		{name: "breaker small talk", stats: breaker, message: "nudzi mi sie", wantRouting: "llm_reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := pressuredLLM{fakeLLM: fakeLLM{enabled: true, message: llmMessage}, stats: tt.stats}
+			planner := NewPlanner(generator, Config{PressureQueueDepth: 2, PressureP95Latency: time.Second})
+			req := models.PlanRequest{
+				RequestID: "req-pressure",
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+				Chat: []models.ChatMessage{
+					{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: tt.message},
+				},
+				Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+			}
+
+			resp := planner.Plan(context.Background(), req)
+			if len(resp.Actions) != 1 {
+				t.Fatalf("expected 1 action, got %+v (debug %+v)", resp.Actions, resp.Debug)
+			}
+			gotLLM := resp.Actions[0].Reason == "llm"
+			if gotLLM != tt.wantLLM {
+				t.Fatalf("llm used = %t, want %t (reason=%s)", gotLLM, tt.wantLLM, resp.Actions[0].Reason)
+			}
+			if resp.Debug.LLMRouting != tt.wantRouting {
+				t.Fatalf("llm_routing = %q, want %q", resp.Debug.LLMRouting, tt.wantRouting)
+			}
+			if !tt.wantLLM && resp.Debug.ChosenStrategy == "heuristics_fallback" {
+				t.Fatalf("reserved topics should not count as llm fallback, got %s", resp.Debug.ChosenStrategy)
+			}
+		})
+	}
+}
+
+func TestPlannerKeepsLLMForMentionsUnderPressure(t *testing.T) {
+	generator := pressuredLLM{fakeLLM: fakeLLM{enabled: true, message: "siema z llm"}, stats: llm.Stats{Queued: 3}}
+	planner := NewPlanner(generator, Config{PressureQueueDepth: 2})
+	req := models.PlanRequest{
+		RequestID: "req-pressure-mention",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema kuba"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected 1 action, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+	if resp.Actions[0].Source != SourceLLM || resp.Actions[0].Message != "siema z llm" {
+		t.Fatalf("mentioned bot should keep the llm under pressure, got %+v", resp.Actions[0])
+	}
+	if resp.Debug.LLMRouting != "" {
+		t.Fatalf("llm_routing = %q, want none", resp.Debug.LLMRouting)
+	}
+}
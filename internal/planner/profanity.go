@@ -0,0 +1,86 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"strings"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+// profanityBlockedReason silences a bot whose LLM reply contained profanity;
+// falling back to a template would still answer a message that provoked it.
+const profanityBlockedReason = "llm_output_profanity_blocked"
+
+// defaultProfanity is checked on top of the toxic topic keywords; like them it
+// is written without diacritics and matched on whole words.
+var defaultProfanity = []string{
+	"kurwa", "kurwy", "kurwo", "kurwie", "kurwe", "kurwami", "skurwysyn", "skurwiel",
+	"chuj", "chuja", "chuju", "chujem", "chujowy", "chujowa", "chujowe", "huj",
+	"jebac", "jebany", "jebana", "jebane", "jebie", "jebnij", "zajebie",
+	"pierdol", "pierdole", "pierdolic", "pierdolony", "spierdalaj", "wypierdalaj",
+	"pizda", "pizdy", "pizde", "cipa",
+	"fuck", "fucking", "shit", "bitch", "cunt",
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// loadProfanityBlocklist reads one word or phrase per line; empty lines and
+// lines starting with # are skipped.
+func loadProfanityBlocklist(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return mergeKeywords(nil, words), scanner.Err()
+}
+
+// profaneOutput reports whether an LLM reply contains profanity, also when it
+// is spelled with digits or symbols ("kurw4", "p1zd@") or stretched letters.
+func (p *Planner) profaneOutput(ctx context.Context, requestID string, bot models.BotProfile, message string) bool {
+	keywords := mergeKeywords(defaultProfanity, p.profanity)
+	for _, rule := range p.topicKeywords().rules {
+		if rule.topic == TopicToxic {
+			keywords = mergeKeywords(keywords, rule.keywords)
+		}
+	}
+	normalized := util.NormalizeText(message)
+	decoded := collapseRepeats(leetReplacer.Replace(normalized))
+	collapsed := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		collapsed[i] = collapseRepeats(keyword)
+	}
+	if !util.ContainsKeyword(normalized, keywords) && !util.ContainsKeyword(decoded, collapsed) {
+		return false
+	}
+	logging.Ctx(ctx).Warnf("planner_llm_output_profanity_blocked request_id=%s transaction_id=%s bot_id=%s", requestID, requestID, bot.BotID)
+	return true
+}
+
+// collapseRepeats squeezes runs of the same letter, so "kuuurwaaa" and
+// "kurwa" compare equal.
+func collapseRepeats(input string) string {
+	var sb strings.Builder
+	var previous rune
+	for i, r := range input {
+		if i > 0 && r == previous {
+			continue
+		}
+		sb.WriteRune(r)
+		previous = r
+	}
+	return sb.String()
+}
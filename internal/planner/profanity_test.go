@@ -0,0 +1,83 @@
+package planner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestProfaneOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# serwerowe\nfrajer\n\nspadaj stad\n"), 0o600); err != nil {
+		t.Fatalf("write blocklist: %v", err)
+	}
+	planner := NewPlanner(nil, Config{ProfanityBlocklistPath: path})
+	bot := models.BotProfile{BotID: "bot-1"}
+
+	tests := []struct {
+		name    string
+		message string
+		want    bool
+	}{
+		{name: "plain", message: "co ty kurwa robisz", want: true},
+		{name: "diacritics and case", message: "PIERDOLĘ to", want: true},
+		{name: "inflection from list", message: "Pierdole to", want: true},
+		{name: "toxic topic keyword", message: "ty idioto", want: true},
+		{name: "leet digits", message: "kurw4 znowu lag", want: true},
+		{name: "leet mixed", message: "p1zd@ z tym", want: true},
+		{name: "leet three", message: "j3bac to", want: true},
+		{name: "leet symbols", message: "ale $hit", want: true},
+		{name: "stretched letters", message: "kuuurwaaa", want: true},
+		{name: "blocklist word", message: "ty frajerze", want: false},
+		{name: "blocklist exact", message: "ty frajer", want: true},
+		{name: "blocklist phrase", message: "spadaj, stad!", want: true},
+		{name: "clean", message: "siema, kto na event?", want: false},
+		{name: "word boundary", message: "huje ruje i chujnia", want: false},
+		{name: "embedded in longer word", message: "shitake to grzyb", want: false},
+		{name: "plain numbers", message: "gram 1v1 o 17", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planner.profaneOutput(context.Background(), "req-1", bot, tt.message); got != tt.want {
+				t.Fatalf("profaneOutput(%q) = %v, want %v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfaneLLMOutputSilencesBot(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		blocked bool
+	}{
+		{name: "blocked", message: "siema, j3b4ny lag", blocked: true},
+		{name: "clean", message: "siema, co budujecie?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(fakeLLM{enabled: true, message: tt.message}, Config{})
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "profanity-" + tt.name,
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+				Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+				Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+			})
+			if tt.blocked {
+				if len(resp.Actions) != 0 {
+					t.Fatalf("expected profane reply to be silenced, got %+v", resp.Actions)
+				}
+				return
+			}
+			if len(resp.Actions) != 1 || resp.Actions[0].Message != tt.message {
+				t.Fatalf("expected clean LLM reply, got %+v", resp.Actions)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/config"
+	"aichatplayers/internal/models"
+)
+
+func TestPlanStaysSilentDuringQuietHours(t *testing.T) {
+	quietHours, err := config.ParseQuietHours("23:00-06:00", "UTC")
+	if err != nil {
+		t.Fatalf("ParseQuietHours() error: %v", err)
+	}
+	clock := newFakeClock(testClockStart)
+	planner := NewPlanner(noopLLM{}, Config{QuietHours: quietHours, Clock: clock})
+	quiet, loud := true, false
+
+	tests := []struct {
+		name      string
+		clock     time.Time
+		override  *bool
+		wantQuiet bool
+	}{
+		{name: "after midnight", clock: time.Date(2024, 4, 5, 2, 30, 0, 0, time.UTC), wantQuiet: true},
+		{name: "before midnight", clock: time.Date(2024, 4, 5, 23, 15, 0, 0, time.UTC), wantQuiet: true},
+		{name: "daytime", clock: time.Date(2024, 4, 5, 14, 0, 0, 0, time.UTC)},
+		{name: "request lifts quiet hours", clock: time.Date(2024, 4, 5, 2, 30, 0, 0, time.UTC), override: &loud},
+		{name: "request forces quiet", clock: time.Date(2024, 4, 5, 14, 0, 0, 0, time.UTC), override: &quiet, wantQuiet: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Set(tt.clock)
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "quiet-" + tt.name,
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    tt.clock.UnixMilli(),
+				Bots:      []models.BotProfile{{BotID: "bot-" + tt.name, Name: "Kuba"}},
+				Chat:      []models.ChatMessage{{TimestampMS: tt.clock.UnixMilli() - 1000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+				Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1, Quiet: tt.override},
+			})
+			if tt.wantQuiet {
+				if resp.Debug.ChosenStrategy != quietHoursReason || resp.Actions == nil || len(resp.Actions) != 0 {
+					t.Fatalf("expected quiet_hours with empty actions, got %+v", resp)
+				}
+				return
+			}
+			if resp.Debug.ChosenStrategy == quietHoursReason || len(resp.Actions) != 1 {
+				t.Fatalf("expected a normal plan, got %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package planner
+
+import (
+	"context"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+// botRateWindowMS is the rolling window of settings.max_bot_messages_per_minute.
+const botRateWindowMS int64 = 60000
+
+// filterRateLimited drops the bots that already sent
+// settings.max_bot_messages_per_minute messages on the server in the last
+// minute and returns their IDs.
+func (p *Planner) filterRateLimited(ctx context.Context, req models.PlanRequest, bots []models.BotProfile, settings models.PlanSettings) ([]models.BotProfile, []string) {
+	if settings.MaxBotMessagesPerMinute <= 0 {
+		return bots, nil
+	}
+	serverID := req.Server.ServerID
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	allowed := make([]models.BotProfile, 0, len(bots))
+	var limited []string
+	for _, bot := range bots {
+		if recentActions(p.memory[serverID][bot.BotID].RecentActionsMS, req.TimeMS) >= settings.MaxBotMessagesPerMinute {
+			logging.Ctx(ctx).Debugf("planner_plan_bot_rate_limited request_id=%s transaction_id=%s bot_id=%s limit=%d", req.RequestID, req.RequestID, bot.BotID, settings.MaxBotMessagesPerMinute)
+			limited = append(limited, bot.BotID)
+			continue
+		}
+		allowed = append(allowed, bot)
+	}
+	return allowed, limited
+}
+
+// recentActions counts the action times inside the window ending at nowMS.
+func recentActions(actionsMS []int64, nowMS int64) int {
+	count := 0
+	for _, actionMS := range actionsMS {
+		if nowMS-actionMS < botRateWindowMS {
+			count++
+		}
+	}
+	return count
+}
+
+// pruneActions keeps the action times still inside the window ending at
+// nowMS.
+func pruneActions(actionsMS []int64, nowMS int64) []int64 {
+	kept := actionsMS[:0]
+	for _, actionMS := range actionsMS {
+		if nowMS-actionMS < botRateWindowMS {
+			kept = append(kept, actionMS)
+		}
+	}
+	return kept
+}
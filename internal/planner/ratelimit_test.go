@@ -0,0 +1,74 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestPlanGivesEachBotOneAction(t *testing.T) {
+	planner := NewPlanner(nil, Config{})
+	req := models.PlanRequest{
+		RequestID: "req-one-action",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344998000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 2, ReplyChance: 1},
+	}
+
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected one action for the only bot, got %+v", resp.Actions)
+	}
+	if resp.Debug.SuppressedReplies != 1 || resp.Debug.RateLimited != 0 {
+		t.Fatalf("debug = %+v, want the second topic suppressed without rate limiting", resp.Debug)
+	}
+}
+
+func TestPlanRateLimitsBotsAcrossPlans(t *testing.T) {
+	planner := NewPlanner(nil, Config{})
+	req := models.PlanRequest{
+		RequestID:      "req-rate-1",
+		Server:         models.ServerContext{ServerID: "srv-1"},
+		TimeMS:         1712345000000,
+		Bots:           []models.BotProfile{{BotID: "bot-1"}},
+		RequiredBotIDs: []string{"bot-1"},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1, MaxBotMessagesPerMinute: 1, TopicCooldownMS: new(int64)},
+	}
+	if resp := planner.Plan(context.Background(), req); len(resp.Actions) != 1 {
+		t.Fatalf("expected the first plan to reply, got %+v", resp.Actions)
+	}
+
+	req.RequestID = "req-rate-2"
+	req.TimeMS += 30000
+	req.Chat = []models.ChatMessage{
+		{TimestampMS: req.TimeMS - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "gdzie jest spawn?"},
+	}
+	resp := planner.Plan(context.Background(), req)
+	if len(resp.Actions) != 0 {
+		t.Fatalf("expected the bot to be rate limited, got %+v", resp.Actions)
+	}
+	if resp.Debug.RateLimited != 1 || resp.Debug.SuppressedReplies != 1 {
+		t.Fatalf("debug = %+v, want one rate limited bot counted as suppressed", resp.Debug)
+	}
+	if len(resp.Debug.RequiredFailures) != 1 || resp.Debug.RequiredFailures[0].Reason != "rate_limited" {
+		t.Fatalf("required failures = %+v, want rate_limited", resp.Debug.RequiredFailures)
+	}
+
+	req.RequestID = "req-rate-3"
+	req.TimeMS += 31000
+	req.Chat = []models.ChatMessage{
+		{TimestampMS: req.TimeMS - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "gdzie jest sklep?"},
+	}
+	if resp := planner.Plan(context.Background(), req); len(resp.Actions) != 1 || resp.Debug.RateLimited != 0 {
+		t.Fatalf("expected the bot to reply once the window passed, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+}
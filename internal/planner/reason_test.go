@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+// TestPlannerReasonsAreKnown walks the planner code paths that emit actions
+// and checks every reason against the models.Reason taxonomy.
+func TestPlannerReasonsAreKnown(t *testing.T) {
+	bots := []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}}
+	chat := func(message string) []models.ChatMessage {
+		return []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: message}}
+	}
+	settings := models.PlanSettings{MaxActions: 2, ReplyChance: 1, AllowWhispers: true}
+	tests := []struct {
+		name      string
+		generator LLMGenerator
+		req       models.PlanRequest
+	}{
+		{name: "greeting", req: models.PlanRequest{Chat: chat("siema")}},
+		{name: "pvp", req: models.PlanRequest{Chat: chat("kto na pvp 1v1?")}},
+		{name: "event", req: models.PlanRequest{Chat: chat("event za 5 minut")}},
+		{name: "help whispered", req: models.PlanRequest{Chat: chat("jak zrobic portal?")}},
+		{name: "small talk", req: models.PlanRequest{}},
+		{name: "mention", req: models.PlanRequest{Chat: chat("Kuba siema")}},
+		{name: "llm", generator: fakeLLM{enabled: true, message: "siema, co budujecie?"}, req: models.PlanRequest{Chat: chat("siema")}},
+		{name: "avoid filtered", generator: fakeLLM{enabled: true, message: "wyslij przelew na blika"}, req: models.PlanRequest{Chat: chat("siema")}},
+		{name: "dry run", req: models.PlanRequest{Chat: chat("jak zrobic portal?"), DryRun: true}},
+		{name: "banter", req: models.PlanRequest{Settings: models.PlanSettings{MaxActions: 2, BanterChance: 1}}},
+		{name: "system event", req: systemEventRequest("event", 1712345000000, 1712344998000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.RequestID = "reasons-" + tt.name
+			req.Server = models.ServerContext{ServerID: "srv-1"}
+			if req.TimeMS == 0 {
+				req.TimeMS = 1712345000000
+			}
+			if req.Bots == nil {
+				req.Bots = bots
+			}
+			if req.Settings.MaxActions == 0 {
+				req.Settings = settings
+			}
+			resp := NewPlanner(tt.generator, Config{}).Plan(context.Background(), req)
+			if len(resp.Actions) == 0 {
+				t.Fatalf("expected actions, got %+v", resp)
+			}
+			for _, action := range resp.Actions {
+				if !action.Reason.Known() {
+					t.Fatalf("reason %q is not in the taxonomy", action.Reason)
+				}
+			}
+		})
+	}
+
+	resp := NewPlanner(nil, Config{}).Engage(context.Background(), models.EngagementRequest{RequestID: "reasons-engage", TimeMS: 1712345000000, Bots: bots, TargetPlayer: "Steve"})
+	if len(resp.Actions) != 1 || !resp.Actions[0].Reason.Known() {
+		t.Fatalf("engagement reason not in the taxonomy: %+v", resp)
+	}
+}
@@ -0,0 +1,75 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+const (
+	defaultRecentMessageLimit = 5
+	maxTemplateRepicks        = 5
+)
+
+func normalizeMessage(message string) string {
+	return strings.Join(strings.Fields(strings.ToLower(message)), " ")
+}
+
+func (p *Planner) sentRecently(serverID, botID, message string) bool {
+	if serverID == "" {
+		serverID = "default"
+	}
+	normalized := normalizeMessage(message)
+	if normalized == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, recent := range p.memory[serverID][botID].RecentMessages {
+		if recent == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Planner) rememberMessage(serverID, botID, message string) {
+	if serverID == "" {
+		serverID = "default"
+	}
+	normalized := normalizeMessage(message)
+	if normalized == "" || p.tuning.Load().recentMessageLimit <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.memory[serverID] == nil {
+		p.memory[serverID] = make(map[string]BotMemory)
+	}
+	last := p.memory[serverID][botID]
+	last.RecentMessages = append(last.RecentMessages, normalized)
+	if overflow := len(last.RecentMessages) - p.tuning.Load().recentMessageLimit; overflow > 0 {
+		last.RecentMessages = append([]string(nil), last.RecentMessages[overflow:]...)
+	}
+	p.memory[serverID][botID] = last
+}
+
+// heuristicMessage re-picks templates until it finds one the bot has not sent
+// recently; it gives up with an empty message rather than repeat itself and
+// then reports repeated.
+func (p *Planner) heuristicMessage(ctx context.Context, req models.PlanRequest, topic Topic, bot models.BotProfile, rng *rand.Rand) (string, models.Reason, bool) {
+	for attempt := 0; attempt < maxTemplateRepicks; attempt++ {
+		message, reason := generateResponse(topic, bot, req.Server, topicSender(req.Chat, topic, p.topicKeywords()), req.Chat, p.topicKeywords(), rng)
+		if message == "" || !p.sentRecently(req.Server.ServerID, bot.BotID, message) {
+			return message, reason, false
+		}
+		logging.Ctx(ctx).Debugf("planner_repeat_repick request_id=%s transaction_id=%s bot_id=%s topic=%s attempt=%d", req.RequestID, req.RequestID, bot.BotID, topic, attempt+1)
+	}
+	logging.Ctx(ctx).Infof("planner_repeat_exhausted request_id=%s transaction_id=%s bot_id=%s topic=%s", req.RequestID, req.RequestID, bot.BotID, topic)
+	return "", "", true
+}
@@ -0,0 +1,72 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func repetitionTestRequest() models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-repeat",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "nudzi mi sie"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlannerDoesNotRepeatHeuristicMessage(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	first := planner.Plan(context.Background(), repetitionTestRequest())
+	forgetPlans(planner)
+	second := planner.Plan(context.Background(), repetitionTestRequest())
+	if len(first.Actions) != 1 || len(second.Actions) != 1 {
+		t.Fatalf("expected one action per call, got %+v and %+v", first.Actions, second.Actions)
+	}
+	if normalizeMessage(first.Actions[0].Message) == normalizeMessage(second.Actions[0].Message) {
+		t.Fatalf("same seed repeated message %q", second.Actions[0].Message)
+	}
+}
+
+func TestPlannerFallsBackWhenLLMRepeats(t *testing.T) {
+	planner := NewPlanner(fakeLLM{enabled: true, message: "Siema  wszystkim"}, Config{})
+	first := planner.Plan(context.Background(), repetitionTestRequest())
+	if len(first.Actions) != 1 || first.Actions[0].Reason != "llm" {
+		t.Fatalf("expected first reply from llm, got %+v", first.Actions)
+	}
+	forgetPlans(planner)
+	second := planner.Plan(context.Background(), repetitionTestRequest())
+	for _, action := range second.Actions {
+		if action.Reason == "llm" || normalizeMessage(action.Message) == "siema wszystkim" {
+			t.Fatalf("llm repeat should fall back, got %+v", second.Actions)
+		}
+	}
+	if second.Debug.ChosenStrategy != "small_talk_fallback" {
+		t.Fatalf("strategy = %s, want small_talk_fallback", second.Debug.ChosenStrategy)
+	}
+}
+
+func TestRecentMessagesAreBounded(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{RecentMessageLimit: 2})
+	for _, message := range []string{"a", "B", "  c "} {
+		planner.rememberMessage("srv-1", "bot-1", message)
+	}
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{message: "a", want: false},
+		{message: "b", want: true},
+		{message: "C", want: true},
+	}
+	for _, tt := range tests {
+		if got := planner.sentRecently("srv-1", "bot-1", tt.message); got != tt.want {
+			t.Fatalf("sentRecently(%q) = %t, want %t", tt.message, got, tt.want)
+		}
+	}
+}
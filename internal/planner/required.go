@@ -0,0 +1,181 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+)
+
+type requiredTracker struct {
+	bypassCooldown bool
+	order          []string
+	available      map[string]models.BotProfile
+	responded      map[string]bool
+	failures       map[string]string
+	mentioned      []models.BotProfile
+}
+
+func newRequiredTracker(ctx context.Context, req models.PlanRequest, available []models.BotProfile) (*requiredTracker, []string) {
+	tracker := &requiredTracker{
+		bypassCooldown: req.RequiredBypassCooldown,
+		available:      make(map[string]models.BotProfile),
+		responded:      make(map[string]bool),
+		failures:       make(map[string]string),
+	}
+	if len(req.RequiredBotIDs) == 0 {
+		return tracker, nil
+	}
+	listed := make(map[string]bool, len(req.Bots))
+	for _, bot := range req.Bots {
+		listed[bot.BotID] = true
+	}
+	availableByID := make(map[string]models.BotProfile, len(available))
+	for _, bot := range available {
+		availableByID[bot.BotID] = bot
+	}
+
+	var warnings []string
+	seen := make(map[string]bool, len(req.RequiredBotIDs))
+	for _, botID := range req.RequiredBotIDs {
+		if botID == "" || seen[botID] {
+			continue
+		}
+		seen[botID] = true
+		if !listed[botID] {
+			logging.Ctx(ctx).Warnf("planner_required_bot_unknown request_id=%s transaction_id=%s bot_id=%s", req.RequestID, req.RequestID, botID)
+			warnings = append(warnings, fmt.Sprintf("unknown required bot_id: %s", botID))
+			continue
+		}
+		tracker.order = append(tracker.order, botID)
+		bot, ok := availableByID[botID]
+		if !ok {
+			tracker.failures[botID] = "unavailable"
+			continue
+		}
+		tracker.available[botID] = bot
+	}
+	return tracker, warnings
+}
+
+// markUnavailable replaces the generic "unavailable" failure of the given
+// required bots with a specific reason.
+func (t *requiredTracker) markUnavailable(botIDs []string, reason string) {
+	for _, botID := range botIDs {
+		if t.failures[botID] == "unavailable" {
+			t.failures[botID] = reason
+		}
+	}
+}
+
+func (t *requiredTracker) isRequired(botID string) bool {
+	_, ok := t.available[botID]
+	return ok
+}
+
+func (t *requiredTracker) active() bool {
+	return len(t.available) > 0
+}
+
+// addMentions records directly mentioned bots; they are selected right after
+// the required bots and skip the reply chance roll.
+func (t *requiredTracker) addMentions(bots []models.BotProfile) {
+	for _, bot := range bots {
+		if !t.isRequired(bot.BotID) {
+			t.mentioned = append(t.mentioned, bot)
+		}
+	}
+}
+
+func (t *requiredTracker) isMentioned(botID string) bool {
+	for _, bot := range t.mentioned {
+		if bot.BotID == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// mustReply reports whether botID is required or was mentioned by name.
+func (t *requiredTracker) mustReply(botID string) bool {
+	return t.isRequired(botID) || t.isMentioned(botID)
+}
+
+func (t *requiredTracker) prioritized() bool {
+	return t.active() || len(t.mentioned) > 0
+}
+
+func (t *requiredTracker) bots() []models.BotProfile {
+	bots := make([]models.BotProfile, 0, len(t.available))
+	for _, botID := range t.order {
+		if bot, ok := t.available[botID]; ok {
+			bots = append(bots, bot)
+		}
+	}
+	return bots
+}
+
+func (t *requiredTracker) succeed(botID string) {
+	if !t.isRequired(botID) {
+		return
+	}
+	t.responded[botID] = true
+	delete(t.failures, botID)
+}
+
+func (t *requiredTracker) fail(botID, reason string) {
+	if !t.isRequired(botID) || t.responded[botID] {
+		return
+	}
+	t.failures[botID] = reason
+}
+
+// failAll records reason for every required bot that has not responded and has
+// no more specific failure yet.
+func (t *requiredTracker) failAll(reason string) {
+	for botID := range t.available {
+		if _, ok := t.failures[botID]; ok {
+			continue
+		}
+		t.fail(botID, reason)
+	}
+}
+
+func (t *requiredTracker) results() []models.RequiredBotFailure {
+	var failures []models.RequiredBotFailure
+	for _, botID := range t.order {
+		if t.responded[botID] {
+			continue
+		}
+		reason, ok := t.failures[botID]
+		if !ok {
+			reason = "not_selected"
+		}
+		failures = append(failures, models.RequiredBotFailure{BotID: botID, Reason: reason})
+	}
+	return failures
+}
+
+// selectBots puts available required bots first, then mentioned bots, and
+// fills the remaining slots up to max with the selector's pick of the other bots.
+func (t *requiredTracker) selectBots(bots []models.BotProfile, max int, selector botSelector, rng *rand.Rand) []models.BotProfile {
+	priority := append(t.bots(), t.mentioned...)
+	if len(priority) == 0 {
+		return selector.pick(bots, max, rng)
+	}
+	if len(priority) >= max {
+		for _, bot := range priority[max:] {
+			t.fail(bot.BotID, "max_actions")
+		}
+		return priority[:max]
+	}
+	others := make([]models.BotProfile, 0, len(bots))
+	for _, bot := range bots {
+		if !t.isRequired(bot.BotID) && !t.isMentioned(bot.BotID) {
+			others = append(others, bot)
+		}
+	}
+	return append(priority, selector.pick(others, max-len(priority), rng)...)
+}
@@ -0,0 +1,84 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func requiredTestRequest(requestID string, timeMS int64, required ...string) models.PlanRequest {
+	bots := make([]models.BotProfile, 0, 5)
+	for i := 1; i <= 5; i++ {
+		bots = append(bots, models.BotProfile{BotID: fmt.Sprintf("bot-%d", i), Name: fmt.Sprintf("Bot%d", i)})
+	}
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    timeMS,
+		Bots:      bots,
+		Chat: []models.ChatMessage{
+			{TimestampMS: timeMS - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings:       models.PlanSettings{MaxActions: 1, ReplyChance: 0.01},
+		RequiredBotIDs: required,
+	}
+}
+
+func TestRequiredBotWinsOverRandomSelection(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		planner := NewPlanner(noopLLM{}, Config{})
+		resp := planner.Plan(context.Background(), requiredTestRequest(fmt.Sprintf("req-%d", i), 1712345000000, "bot-4"))
+		if len(resp.Actions) != 1 || resp.Actions[0].BotID != "bot-4" {
+			t.Fatalf("run %d: expected bot-4 to answer despite low reply chance, got %+v (debug %+v)", i, resp.Actions, resp.Debug)
+		}
+		if len(resp.Debug.RequiredFailures) != 0 {
+			t.Fatalf("run %d: unexpected failures %+v", i, resp.Debug.RequiredFailures)
+		}
+	}
+}
+
+func TestRequiredBotCooldownBypassFlag(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	first := planner.Plan(context.Background(), requiredTestRequest("req-a", 1712345000000, "bot-2"))
+	if len(first.Actions) != 1 {
+		t.Fatalf("expected first plan to answer, got %+v", first.Actions)
+	}
+
+	second := planner.Plan(context.Background(), requiredTestRequest("req-b", 1712345001000, "bot-2"))
+	if len(second.Actions) != 0 {
+		t.Fatalf("expected topic cooldown to suppress bot-2, got %+v", second.Actions)
+	}
+	if len(second.Debug.RequiredFailures) != 1 || second.Debug.RequiredFailures[0].Reason != "topic_cooldown" {
+		t.Fatalf("expected topic_cooldown failure, got %+v", second.Debug.RequiredFailures)
+	}
+
+	bypass := requiredTestRequest("req-c", 1712345002000, "bot-2")
+	bypass.RequiredBypassCooldown = true
+	third := planner.Plan(context.Background(), bypass)
+	if len(third.Actions) != 1 || third.Actions[0].BotID != "bot-2" {
+		t.Fatalf("expected bypass flag to let bot-2 answer, got %+v", third.Actions)
+	}
+}
+
+func TestRequiredBotFailureReporting(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	req := requiredTestRequest("req-fail", 1712345000000, "bot-1", "bot-3", "ghost")
+	req.Bots[2].CooldownMS = 5000
+	req.Bots[0].Persona.AvoidTopics = []string{"greeting"}
+
+	resp := planner.Plan(context.Background(), req)
+	want := map[string]string{"bot-1": "no_message", "bot-3": "unavailable"}
+	if len(resp.Debug.RequiredFailures) != len(want) {
+		t.Fatalf("expected %d failures, got %+v", len(want), resp.Debug.RequiredFailures)
+	}
+	for _, failure := range resp.Debug.RequiredFailures {
+		if want[failure.BotID] != failure.Reason {
+			t.Fatalf("unexpected failure %+v", failure)
+		}
+	}
+	if len(resp.Debug.Warnings) != 1 || resp.Debug.Warnings[0] != "unknown required bot_id: ghost" {
+		t.Fatalf("expected unknown bot warning, got %+v", resp.Debug.Warnings)
+	}
+}
@@ -0,0 +1,46 @@
+package planner
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+
+	"aichatplayers/internal/models"
+)
+
+// SeedForRequest derives the random seed of a plan from request_id, tick,
+// time_ms, the chat senders and messages and the bot IDs. Identical
+// requests always get the same seed, which retries and the dedup cache rely
+// on; a plugin reusing a request_id with different chat or bots gets a
+// different bot selection and template picks.
+func SeedForRequest(req models.PlanRequest) int64 {
+	return requestSeed(req.RequestID, req.Tick, req.TimeMS, req.Chat, req.Bots)
+}
+
+// requestSeed hashes every field with a terminator, so ("ab", "c") and
+// ("a", "bc") seed differently. salt separates endpoints sharing a request.
+func requestSeed(requestID string, tick, timeMS int64, chat []models.ChatMessage, bots []models.BotProfile, salt ...string) int64 {
+	hasher := fnv.New64a()
+	write := func(value string) {
+		_, _ = hasher.Write([]byte(value))
+		_, _ = hasher.Write([]byte{0})
+	}
+	write(requestID)
+	write(strconv.FormatInt(tick, 10))
+	write(strconv.FormatInt(timeMS, 10))
+	for _, message := range chat {
+		write(message.Sender)
+		write(message.Message)
+	}
+	for _, bot := range bots {
+		write(bot.BotID)
+	}
+	for _, value := range salt {
+		write(value)
+	}
+	return int64(hasher.Sum64())
+}
+
+func newRequestRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
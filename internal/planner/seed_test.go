@@ -0,0 +1,85 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func seedTestRequest() models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-seed",
+		Tick:      42,
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1"}, {BotID: "bot-2"}},
+		Chat:      []models.ChatMessage{{Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+	}
+}
+
+func TestSeedForRequest(t *testing.T) {
+	base := SeedForRequest(seedTestRequest())
+	if again := SeedForRequest(seedTestRequest()); again != base {
+		t.Fatalf("identical requests seeded %d and %d", base, again)
+	}
+	tests := []struct {
+		name   string
+		mutate func(req *models.PlanRequest)
+	}{
+		{name: "request id", mutate: func(req *models.PlanRequest) { req.RequestID = "req-other" }},
+		{name: "tick", mutate: func(req *models.PlanRequest) { req.Tick++ }},
+		{name: "time", mutate: func(req *models.PlanRequest) { req.TimeMS++ }},
+		{name: "message", mutate: func(req *models.PlanRequest) { req.Chat[0].Message = "czesc" }},
+		{name: "sender", mutate: func(req *models.PlanRequest) { req.Chat[0].Sender = "Alex" }},
+		{name: "new message", mutate: func(req *models.PlanRequest) {
+			req.Chat = append(req.Chat, models.ChatMessage{Sender: "Alex", Message: "siema"})
+		}},
+		{name: "field boundary", mutate: func(req *models.PlanRequest) { req.Chat[0].Sender, req.Chat[0].Message = "Stevesi", "ema" }},
+		{name: "bots", mutate: func(req *models.PlanRequest) { req.Bots = req.Bots[:1] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := seedTestRequest()
+			tt.mutate(&req)
+			if SeedForRequest(req) == base {
+				t.Fatalf("changing the %s kept seed %d", tt.name, base)
+			}
+		})
+	}
+}
+
+func TestPlanIsDeterministicForIdenticalRequests(t *testing.T) {
+	bots := make([]models.BotProfile, 6)
+	for i := range bots {
+		bots[i] = models.BotProfile{BotID: fmt.Sprintf("bot-%d", i), Name: fmt.Sprintf("Bot%d", i)}
+	}
+	req := func(message string) models.PlanRequest {
+		return models.PlanRequest{
+			RequestID: "req-reused",
+			Server:    models.ServerContext{ServerID: "srv-1"},
+			TimeMS:    1712345000000,
+			Bots:      bots,
+			Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: message}},
+			Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+		}
+	}
+	first := NewPlanner(nil, Config{}).Plan(context.Background(), req("siema"))
+	second := NewPlanner(nil, Config{}).Plan(context.Background(), req("siema"))
+	if len(first.Actions) != 1 || len(second.Actions) != 1 {
+		t.Fatalf("expected one action each, got %+v and %+v", first.Actions, second.Actions)
+	}
+	a, b := first.Actions[0], second.Actions[0]
+	if a.BotID != b.BotID || a.Message != b.Message || a.SendAfterMS != b.SendAfterMS {
+		t.Fatalf("identical requests planned differently: %+v vs %+v", a, b)
+	}
+
+	differs := false
+	for i := 0; i < 10 && !differs; i++ {
+		other := NewPlanner(nil, Config{}).Plan(context.Background(), req(fmt.Sprintf("siema %d", i)))
+		differs = len(other.Actions) == 1 && (other.Actions[0].BotID != a.BotID || other.Actions[0].SendAfterMS != a.SendAfterMS)
+	}
+	if !differs {
+		t.Fatal("a reused request_id with new chat always planned the same bot and delay")
+	}
+}
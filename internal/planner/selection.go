@@ -0,0 +1,193 @@
+package planner
+
+import (
+	"math/rand"
+	"sort"
+
+	"aichatplayers/internal/models"
+)
+
+const (
+	// selectionWeightFloorMS keeps bots that just spoke selectable under the
+	// weighted strategy; selectionWeightCapMS stops long-silent (or never
+	// seen) bots from drowning out everyone else.
+	selectionWeightFloorMS int64 = 5000
+	selectionWeightCapMS   int64 = 10 * 60 * 1000
+
+	// defaultUninterestedWeight is settings.uninterested_weight when unset.
+	defaultUninterestedWeight = 0.3
+	// interestWeightScale turns interest factors into integer weights for
+	// the random strategy.
+	interestWeightScale = 1000
+)
+
+// botSelector picks bots according to settings.selection_strategy, using a
+// snapshot of each bot's last action on the server.
+type botSelector struct {
+	strategy     string
+	nowMS        int64
+	lastActionMS map[string]int64
+	// topic, when set, favours bots whose persona interests match it; the
+	// others keep uninterestedWeight of their usual chance.
+	topic              Topic
+	uninterestedWeight float64
+}
+
+func (p *Planner) newBotSelector(serverID string, settings models.PlanSettings, nowMS int64) botSelector {
+	selector := botSelector{strategy: settings.SelectionStrategy, nowMS: nowMS}
+	if selector.strategy == "" || selector.strategy == models.SelectionRandom {
+		return selector
+	}
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	selector.lastActionMS = make(map[string]int64, len(p.memory[serverID]))
+	for botID, memory := range p.memory[serverID] {
+		selector.lastActionMS[botID] = memory.LastActionMS
+	}
+	return selector
+}
+
+// rememberAction records an action that does not go through remember, which
+// tracks topic cooldowns as well.
+func (p *Planner) rememberAction(serverID, botID string, nowMS int64) {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.memory[serverID] == nil {
+		p.memory[serverID] = make(map[string]BotMemory)
+	}
+	last := p.memory[serverID][botID]
+	last.LastActionMS = nowMS
+	p.memory[serverID][botID] = last
+}
+
+// forTopic returns a selector that favours bots interested in topic.
+func (s botSelector) forTopic(topic Topic, settings models.PlanSettings) botSelector {
+	s.topic = topic
+	s.uninterestedWeight = settings.UninterestedWeight
+	return s
+}
+
+func (s botSelector) pick(bots []models.BotProfile, max int, rng *rand.Rand) []models.BotProfile {
+	interest := s.interest(bots)
+	switch s.strategy {
+	case models.SelectionLeastRecent:
+		return s.leastRecent(bots, max, interest, rng)
+	case models.SelectionWeighted:
+		return draw(bots, max, rng, func(bot models.BotProfile) int64 {
+			return scaleWeight(s.weight(bot.BotID), interest[bot.BotID])
+		})
+	default:
+		if interest == nil || len(bots) <= max {
+			return pickBots(bots, max, rng)
+		}
+		return draw(bots, max, rng, func(bot models.BotProfile) int64 {
+			return scaleWeight(interestWeightScale, interest[bot.BotID])
+		})
+	}
+}
+
+// interest returns the selection factor of each bot for the selector's
+// topic: 1 for bots with a matching persona interest, uninterestedWeight for
+// the rest. It is nil when no bot is interested, so selection stays as is.
+func (s botSelector) interest(bots []models.BotProfile) map[string]float64 {
+	if s.topic == "" {
+		return nil
+	}
+	factors := make(map[string]float64, len(bots))
+	interested := false
+	for _, bot := range bots {
+		factors[bot.BotID] = s.uninterestedWeight
+		if matchesTopic(s.topic, bot.Persona.Interests) {
+			factors[bot.BotID] = 1
+			interested = true
+		}
+	}
+	if !interested {
+		return nil
+	}
+	return factors
+}
+
+// scaleWeight applies an interest factor to a selection weight; a missing
+// factor leaves it unchanged and no bot drops below a weight of 1.
+func scaleWeight(weight int64, factor float64) int64 {
+	if factor <= 0 {
+		return weight
+	}
+	scaled := int64(float64(weight) * factor)
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// leastRecent orders bots by how long they have been quiet, scaled by their
+// interest in the topic; bots that never acted come first and ties are
+// shuffled.
+func (s botSelector) leastRecent(bots []models.BotProfile, max int, interest map[string]float64, rng *rand.Rand) []models.BotProfile {
+	ordered := make([]models.BotProfile, 0, len(bots))
+	for _, index := range rng.Perm(len(bots)) {
+		ordered = append(ordered, bots[index])
+	}
+	quiet := func(bot models.BotProfile) float64 {
+		factor, ok := interest[bot.BotID]
+		if !ok {
+			factor = 1
+		}
+		return float64(s.nowMS-s.lastActionMS[bot.BotID]) * factor
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return quiet(ordered[i]) > quiet(ordered[j])
+	})
+	if max < len(ordered) {
+		ordered = ordered[:max]
+	}
+	return ordered
+}
+
+// draw picks bots without replacement with a chance proportional to their
+// weight. The weighted strategy weighs bots by how long they have been quiet.
+func draw(bots []models.BotProfile, max int, rng *rand.Rand, weight func(models.BotProfile) int64) []models.BotProfile {
+	remaining := append([]models.BotProfile(nil), bots...)
+	selected := make([]models.BotProfile, 0, max)
+	for len(selected) < max && len(remaining) > 0 {
+		var total int64
+		weights := make([]int64, len(remaining))
+		for i, bot := range remaining {
+			weights[i] = weight(bot)
+			total += weights[i]
+		}
+		target := rng.Int63n(total)
+		index := 0
+		for target >= weights[index] {
+			target -= weights[index]
+			index++
+		}
+		selected = append(selected, remaining[index])
+		remaining = append(remaining[:index], remaining[index+1:]...)
+	}
+	return selected
+}
+
+func (s botSelector) weight(botID string) int64 {
+	last := s.lastActionMS[botID]
+	if last <= 0 {
+		return selectionWeightCapMS
+	}
+	quiet := s.nowMS - last
+	if quiet < selectionWeightFloorMS {
+		return selectionWeightFloorMS
+	}
+	if quiet > selectionWeightCapMS {
+		return selectionWeightCapMS
+	}
+	return quiet
+}
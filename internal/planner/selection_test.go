@@ -0,0 +1,63 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestLeastRecentSkipsBotThatJustSpoke(t *testing.T) {
+	planner := NewPlanner(noopLLM{}, Config{})
+	bots := []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}}
+	last := ""
+	for i := 0; i < 6; i++ {
+		timeMS := 1712345000000 + int64(i)*60000
+		resp := planner.Plan(context.Background(), models.PlanRequest{
+			RequestID: fmt.Sprintf("least-recent-%d", i),
+			Server:    models.ServerContext{ServerID: "srv-1"},
+			TimeMS:    timeMS,
+			Bots:      bots,
+			Chat:      []models.ChatMessage{{TimestampMS: timeMS - 1000, Sender: "Steve", SenderType: "PLAYER", Message: fmt.Sprintf("jak zrobic portal %d?", i)}},
+			Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1, SelectionStrategy: models.SelectionLeastRecent},
+		})
+		if len(resp.Actions) != 1 {
+			t.Fatalf("run %d: expected one action, got %+v", i, resp)
+		}
+		if resp.Actions[0].BotID == last {
+			t.Fatalf("run %d: %s spoke twice in a row under least_recent", i, last)
+		}
+		last = resp.Actions[0].BotID
+	}
+}
+
+func TestBotSelectorStrategies(t *testing.T) {
+	bots := []models.BotProfile{{BotID: "recent"}, {BotID: "quiet"}, {BotID: "new"}}
+	selector := botSelector{
+		nowMS:        1712345000000,
+		lastActionMS: map[string]int64{"recent": 1712345000000 - 1000, "quiet": 1712345000000 - 300000},
+	}
+
+	selector.strategy = models.SelectionLeastRecent
+	got := selector.pick(bots, 2, rand.New(rand.NewSource(1)))
+	if len(got) != 2 || got[0].BotID != "new" || got[1].BotID != "quiet" {
+		t.Fatalf("least_recent pick = %v, want [new quiet]", botIDs(got))
+	}
+
+	selector.strategy = models.SelectionWeighted
+	counts := make(map[string]int)
+	for seed := int64(0); seed < 500; seed++ {
+		picked := selector.pick(bots, 1, rand.New(rand.NewSource(seed)))
+		counts[picked[0].BotID]++
+	}
+	if counts["recent"] == 0 || counts["recent"] >= counts["quiet"] || counts["quiet"] >= counts["new"] {
+		t.Fatalf("weighted pick should favour quiet bots but keep recent ones possible, got %v", counts)
+	}
+	first := selector.pick(bots, 3, rand.New(rand.NewSource(7)))
+	second := selector.pick(bots, 3, rand.New(rand.NewSource(7)))
+	if fmt.Sprint(botIDs(first)) != fmt.Sprint(botIDs(second)) {
+		t.Fatalf("weighted pick is not deterministic for a seed: %v vs %v", botIDs(first), botIDs(second))
+	}
+}
@@ -0,0 +1,91 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+func TestDetectServerInfoQuestions(t *testing.T) {
+	for _, message := range []string{"ilu nas gra?", "co to za tryb?", "Jaki tryb jest na serwerze", "ile osób online?"} {
+		topic, ok := defaultTopicKeywords.detect(util.NormalizeText(message))
+		if !ok || topic != TopicServerInfo {
+			t.Fatalf("detect(%q) = %q, %t; want %s", message, topic, ok, TopicServerInfo)
+		}
+	}
+}
+
+func TestServerInfoResponseInterpolatesServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		server   models.ServerContext
+		want     []string
+	}{
+		{name: "count only", server: models.ServerContext{OnlinePlayers: 42}, want: []string{"42"}},
+		{name: "mode and count", server: models.ServerContext{Mode: "BOXPVP", OnlinePlayers: 42}, want: []string{"BOXPVP", "42"}},
+		{name: "english", language: "en", server: models.ServerContext{Mode: "skyblock", OnlinePlayers: 7}, want: []string{"skyblock", "7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := models.BotProfile{BotID: "bot-1", Persona: models.Persona{Language: tt.language}}
+			for seed := int64(0); seed < 10; seed++ {
+				message, reason := generateResponse(TopicServerInfo, bot, tt.server, "", nil, defaultTopicKeywords, rand.New(rand.NewSource(seed)))
+				if reason != models.ReasonServerInfo || strings.Contains(message, "%") {
+					t.Fatalf("generateResponse = %q, %q", message, reason)
+				}
+				for _, want := range tt.want {
+					if !strings.Contains(message, want) {
+						t.Fatalf("message %q does not mention %q", message, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func serverInfoRequest(onlinePlayers int) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-server-info",
+		Server:    models.ServerContext{ServerID: "srv-1", Mode: "BOXPVP", OnlinePlayers: onlinePlayers},
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "ilu nas gra?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1, GlobalSilenceChance: 1},
+	}
+}
+
+func TestPlanAnswersServerInfo(t *testing.T) {
+	resp := NewPlanner(noopLLM{}, Config{}).Plan(context.Background(), serverInfoRequest(42))
+	if len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonServerInfo || !strings.Contains(resp.Actions[0].Message, "42") {
+		t.Fatalf("expected a server_info answer citing 42 players, got %+v", resp.Actions)
+	}
+	if resp.Debug.Topic != string(TopicServerInfo) {
+		t.Fatalf("topic = %q, want %s", resp.Debug.Topic, TopicServerInfo)
+	}
+}
+
+func TestPlanSkipsServerInfoWithoutPlayerCount(t *testing.T) {
+	resp := NewPlanner(noopLLM{}, Config{}).Plan(context.Background(), serverInfoRequest(0))
+	if len(resp.Actions) != 0 || resp.Debug.Topic != "" {
+		t.Fatalf("expected silence without a player count, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+}
+
+func TestServerInfoLLMHint(t *testing.T) {
+	generator := &capturingLLM{}
+	NewPlanner(generator, Config{}).Plan(context.Background(), serverInfoRequest(42))
+	if len(generator.requests) != 1 {
+		t.Fatalf("expected one LLM request, got %d", len(generator.requests))
+	}
+	hint := generator.requests[0].TopicHint
+	if !strings.Contains(hint, "online_players") || !strings.Contains(hint, "mode") {
+		t.Fatalf("topic hint = %q, want it to limit the reply to mode and online_players", hint)
+	}
+}
@@ -0,0 +1,45 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/metrics"
+	"aichatplayers/internal/models"
+)
+
+const (
+	staleChatStrategy    = "stale_chat"
+	defaultChatClockSkew = 2 * time.Second
+	// staleSmallTalkChance is how often a plan on stale chat still gets a
+	// small-talk line, so a server nobody writes on does not go fully quiet.
+	staleSmallTalkChance = 0.25
+)
+
+// staleChat reports whether the newest chat message is older than
+// maxAgeMS before timeMS, allowing skewMS for clock drift between the
+// plugin and the Minecraft server. Without a limit or chat nothing is stale.
+func staleChat(chat []models.ChatMessage, timeMS, maxAgeMS, skewMS int64) bool {
+	if maxAgeMS <= 0 {
+		return false
+	}
+	newest := latestChatMessage(chat)
+	if newest == nil {
+		return false
+	}
+	return newest.TimestampMS < timeMS-maxAgeMS-skewMS
+}
+
+// stalePlan answers a repeated chat snapshot: no topic replies, only an
+// occasional small-talk line unless required bots must speak.
+func (p *Planner) stalePlan(ctx context.Context, req models.PlanRequest, bots []models.BotProfile, required *requiredTracker, routing *llmRouting, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, string, int) {
+	if !required.prioritized() && rng.Float64() >= staleSmallTalkChance {
+		logging.Ctx(ctx).Infof("planner_plan_silence request_id=%s transaction_id=%s reason=%s", req.RequestID, req.RequestID, staleChatStrategy)
+		metrics.SilenceDecisions.Inc(staleChatStrategy)
+		return nil, staleChatStrategy, 1
+	}
+	actions, llmAttempted, llmUsed := p.smallTalkPlan(ctx, req, bots, required, routing, settings, rng)
+	return actions, strategyLabel("small_talk", llmAttempted, llmUsed), 0
+}
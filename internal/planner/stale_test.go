@@ -0,0 +1,86 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/models"
+)
+
+func staleChatRequest(requestID string, messageAgeMS int64) models.PlanRequest {
+	const nowMS = int64(1712345000000)
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    nowMS,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat:      []models.ChatMessage{{TimestampMS: nowMS - messageAgeMS, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+		Settings:  models.PlanSettings{ReplyChance: 1, MaxActions: 1, MaxMessageAgeMS: 30000},
+	}
+}
+
+func TestStaleChatDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		ageMS     int64
+		maxAgeMS  int64
+		wantStale bool
+	}{
+		{name: "fresh", ageMS: 1000, maxAgeMS: 30000},
+		{name: "within skew allowance", ageMS: 31000, maxAgeMS: 30000},
+		{name: "stale", ageMS: 33000, maxAgeMS: 30000, wantStale: true},
+		{name: "check disabled", ageMS: 600000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := []models.ChatMessage{{TimestampMS: 1000000 - tt.ageMS}}
+			if got := staleChat(chat, 1000000, tt.maxAgeMS, 2000); got != tt.wantStale {
+				t.Fatalf("staleChat = %t, want %t", got, tt.wantStale)
+			}
+		})
+	}
+	if staleChat(nil, 1000000, 30000, 0) {
+		t.Fatal("empty chat should not be stale")
+	}
+}
+
+func TestPlanOnStaleChatSkipsTopicReplies(t *testing.T) {
+	fresh := NewPlanner(nil, Config{}).Plan(context.Background(), staleChatRequest("fresh", 1000))
+	if len(fresh.Actions) != 1 || fresh.Actions[0].Reason != models.ReasonGreeting || fresh.Debug.StaleChat {
+		t.Fatalf("expected a greeting on fresh chat, got %+v", fresh)
+	}
+
+	smallTalk := 0
+	for i := 0; i < 40; i++ {
+		resp := NewPlanner(nil, Config{}).Plan(context.Background(), staleChatRequest(fmt.Sprintf("stale-%d", i), 60000))
+		if !resp.Debug.StaleChat {
+			t.Fatalf("run %d: expected stale_chat, got %+v", i, resp.Debug)
+		}
+		for _, action := range resp.Actions {
+			if action.Reason != models.ReasonSmallTalk {
+				t.Fatalf("run %d: stale chat answered a topic: %+v", i, action)
+			}
+		}
+		if len(resp.Actions) > 0 {
+			smallTalk++
+		} else if resp.Debug.ChosenStrategy != staleChatStrategy {
+			t.Fatalf("run %d: strategy = %q", i, resp.Debug.ChosenStrategy)
+		}
+	}
+	if smallTalk == 0 || smallTalk > 25 {
+		t.Fatalf("small talk on %d of 40 stale plans, want a reduced share", smallTalk)
+	}
+}
+
+func TestPlanChatClockSkewIsConfigurable(t *testing.T) {
+	req := staleChatRequest("skew", 40000)
+	if resp := NewPlanner(nil, Config{}).Plan(context.Background(), req); !resp.Debug.StaleChat {
+		t.Fatalf("expected stale chat with the default skew, got %+v", resp.Debug)
+	}
+	resp := NewPlanner(nil, Config{ChatClockSkew: 15 * time.Second}).Plan(context.Background(), req)
+	if resp.Debug.StaleChat || len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonGreeting {
+		t.Fatalf("expected the skew allowance to keep the chat fresh, got %+v", resp)
+	}
+}
@@ -0,0 +1,130 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"aichatplayers/internal/logging"
+)
+
+const (
+	stateVersion         = 1
+	stateMaxAge          = 5 * time.Minute
+	defaultStateInterval = 30 * time.Second
+)
+
+type stateSnapshot struct {
+	Version   int                             `json:"version"`
+	SavedAtMS int64                           `json:"saved_at_ms"`
+	Memory    map[string]map[string]BotMemory `json:"memory"`
+}
+
+func (p *Planner) loadState(nowMS int64) {
+	data, err := os.ReadFile(p.statePath)
+	if err != nil {
+		logging.Warnf("planner_state_load_skipped path=%s error=%v", p.statePath, err)
+		return
+	}
+	var snapshot stateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		logging.Warnf("planner_state_load_skipped path=%s error=%v", p.statePath, err)
+		return
+	}
+	if snapshot.Version != stateVersion {
+		logging.Warnf("planner_state_load_skipped path=%s error=unsupported version %d", p.statePath, snapshot.Version)
+		return
+	}
+
+	cutoff := nowMS - stateMaxAge.Milliseconds()
+	bots := 0
+	for serverID, memories := range snapshot.Memory {
+		for botID, memory := range memories {
+			for topic, sentMS := range memory.LastSentByTopic {
+				if sentMS < cutoff {
+					delete(memory.LastSentByTopic, topic)
+				}
+			}
+			if len(memory.LastSentByTopic) == 0 {
+				continue
+			}
+			if p.memory[serverID] == nil {
+				p.memory[serverID] = make(map[string]BotMemory)
+			}
+			p.memory[serverID][botID] = memory
+			bots++
+		}
+	}
+	logging.Infof("planner_state_loaded path=%s servers=%d bots=%d saved_at_ms=%d", p.statePath, len(p.memory), bots, snapshot.SavedAtMS)
+}
+
+// SaveState writes the memory snapshot to a temporary file and renames it over
+// the configured path so readers never see a partial file.
+func (p *Planner) SaveState() error {
+	if p.statePath == "" {
+		return nil
+	}
+	p.mu.Lock()
+	data, err := json.Marshal(stateSnapshot{Version: stateVersion, SavedAtMS: p.clock.Now().UnixMilli(), Memory: p.memory})
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encode planner state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.statePath), filepath.Base(p.statePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create planner state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write planner state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write planner state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.statePath); err != nil {
+		return fmt.Errorf("replace planner state: %w", err)
+	}
+	return nil
+}
+
+func (p *Planner) runStateSaver(interval time.Duration) {
+	defer close(p.stateDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.SaveState(); err != nil {
+				logging.Warnf("planner_state_save_failed path=%s error=%v", p.statePath, err)
+			}
+		case <-p.stateStop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic snapshots and writes a final one.
+func (p *Planner) Close(ctx context.Context) error {
+	if p.statePath == "" {
+		return nil
+	}
+	p.closeOnce.Do(func() {
+		if p.stateStop != nil {
+			close(p.stateStop)
+			select {
+			case <-p.stateDone:
+			case <-ctx.Done():
+			}
+		}
+	})
+	if err := p.SaveState(); err != nil {
+		return err
+	}
+	logging.Infof("planner_state_saved path=%s", p.statePath)
+	return nil
+}
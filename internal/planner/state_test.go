@@ -0,0 +1,111 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/models"
+)
+
+func stateTestPlanner(t *testing.T, path string) *Planner {
+	t.Helper()
+	planner := NewPlanner(noopLLM{}, Config{StatePath: path, StateInterval: time.Hour, Clock: newFakeClock(testClockStart)})
+	t.Cleanup(func() { planner.Close(context.Background()) })
+	return planner
+}
+
+func stateTestRequest(requestID string, timeMS int64) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    timeMS,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: timeMS - 1000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+	}
+}
+
+func TestPlannerStateSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "planner_state.json")
+	now := testClockStart.UnixMilli()
+
+	first := stateTestPlanner(t, path)
+	if resp := first.Plan(context.Background(), stateTestRequest("req-before", now)); len(resp.Actions) != 1 {
+		t.Fatalf("expected greeting before restart, got %+v", resp.Actions)
+	}
+	if err := first.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	restarted := stateTestPlanner(t, path)
+	resp := restarted.Plan(context.Background(), stateTestRequest("req-after", now+2000))
+	if len(resp.Actions) != 0 || resp.Debug.SuppressedReplies != 1 {
+		t.Fatalf("expected topic cooldown to survive restart, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+}
+
+func TestPlannerStatePrunesStaleEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "planner_state.json")
+	now := testClockStart.UnixMilli()
+	snapshot := stateSnapshot{
+		Version: stateVersion,
+		Memory: map[string]map[string]BotMemory{
+			"srv-1": {
+				"bot-1": {LastSentByTopic: map[Topic]int64{TopicGreeting: now - stateMaxAge.Milliseconds() - 1000}},
+				"bot-2": {LastSentByTopic: map[Topic]int64{TopicGreeting: now, TopicHelp: now - time.Hour.Milliseconds()}},
+			},
+		},
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	planner := stateTestPlanner(t, path)
+	if _, ok := planner.memory["srv-1"]["bot-1"]; ok {
+		t.Fatalf("stale bot should be pruned, got %+v", planner.memory["srv-1"])
+	}
+	topics := planner.memory["srv-1"]["bot-2"].LastSentByTopic
+	if _, ok := topics[TopicGreeting]; !ok || len(topics) != 1 {
+		t.Fatalf("expected only the fresh greeting to remain, got %+v", topics)
+	}
+}
+
+func TestPlannerStateIgnoresBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "missing", path: filepath.Join(dir, "missing.json")},
+		{name: "corrupt", path: corrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := stateTestPlanner(t, tt.path)
+			if resp := planner.Plan(context.Background(), stateTestRequest("req-broken", testClockStart.UnixMilli())); len(resp.Actions) != 1 {
+				t.Fatalf("expected planner to work without state, got %+v", resp.Actions)
+			}
+			if err := planner.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+			if _, err := os.Stat(tt.path); err != nil {
+				t.Fatalf("expected snapshot to be written on close: %v", err)
+			}
+		})
+	}
+}
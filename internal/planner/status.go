@@ -0,0 +1,48 @@
+package planner
+
+import (
+	"context"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+func (p *Planner) generateLLM(ctx context.Context, req llm.Request) (string, error) {
+	message, err := p.llm.Generate(ctx, req)
+	if err == nil && message != "" {
+		p.lastLLMSuccessMS.Store(p.clock.Now().UnixMilli())
+	}
+	return message, err
+}
+
+func (p *Planner) LLMStatus() models.LLMReadiness {
+	state := p.LLMState()
+	readiness := models.LLMReadiness{
+		Enabled:       state != "disabled",
+		Available:     state != "disabled" && state != llm.BreakerOpen && state != llmUnavailableState,
+		State:         state,
+		LastSuccessMS: p.lastLLMSuccessMS.Load(),
+	}
+	if server := llm.Status(); server.State != "" {
+		readiness.Server = &models.LLMServerReadiness{
+			State:      server.State,
+			Reason:     server.Reason,
+			SlotsTotal: server.SlotsTotal,
+			SlotsBusy:  server.SlotsBusy,
+			LatencyMS:  server.Latency.Milliseconds(),
+			CheckedMS:  server.CheckedAt.UnixMilli(),
+		}
+	}
+	return readiness
+}
+
+func (p *Planner) RegistryStatus() models.PlannerReadiness {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := models.PlannerReadiness{RegisteredServers: len(p.registry)}
+	for _, bots := range p.registry {
+		status.RegisteredBots += len(bots)
+	}
+	return status
+}
@@ -0,0 +1,33 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLLMStatusTracksLastSuccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		generator   LLMGenerator
+		wantEnabled bool
+		wantSuccess bool
+	}{
+		{name: "disabled", generator: noopLLM{}},
+		{name: "failing", generator: fakeLLM{enabled: true, err: errors.New("boom")}, wantEnabled: true},
+		{name: "working", generator: fakeLLM{enabled: true, message: "siema z llm"}, wantEnabled: true, wantSuccess: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(tt.generator, Config{})
+			planner.Plan(context.Background(), repetitionTestRequest())
+			status := planner.LLMStatus()
+			if status.Enabled != tt.wantEnabled || status.Available != tt.wantEnabled {
+				t.Fatalf("unexpected status %+v", status)
+			}
+			if (status.LastSuccessMS > 0) != tt.wantSuccess {
+				t.Fatalf("last_success_ms = %d, want success %t", status.LastSuccessMS, tt.wantSuccess)
+			}
+		})
+	}
+}
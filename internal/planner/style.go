@@ -0,0 +1,130 @@
+package planner
+
+import (
+	"math/rand"
+	"strings"
+	"unicode"
+
+	"aichatplayers/internal/models"
+)
+
+const (
+	// defaultCatchphraseChance is settings.catchphrase_chance when unset.
+	defaultCatchphraseChance = 0.2
+	// typoChance is how often a bot with the occasional_typos quirk makes
+	// one typo in a message.
+	typoChance = 0.3
+	// minTypoWordRunes keeps typos out of short words, where a swapped
+	// letter makes the word unreadable.
+	minTypoWordRunes = 4
+)
+
+// styleApply gives a generated message the bot's persona style: it may add
+// one of the catchphrases with catchphraseChance, then applies the quirks and
+// finally caps the message at maxChars runes (0 = no cap), reporting whether
+// it had to cut. A catchphrase that would not fit is left out rather than
+// truncating the reply. The same rng state always gives the same result.
+func styleApply(message string, persona models.Persona, catchphraseChance float64, maxChars int, rng *rand.Rand) (string, bool) {
+	if message == "" {
+		return message, false
+	}
+	if len(persona.Catchphrases) > 0 && rng.Float64() < catchphraseChance {
+		phrase := strings.TrimSpace(persona.Catchphrases[rng.Intn(len(persona.Catchphrases))])
+		withPhrase := message + " " + phrase
+		if rng.Intn(2) == 0 {
+			withPhrase = phrase + " " + message
+		}
+		if phrase != "" && (maxChars <= 0 || len([]rune(withPhrase)) <= maxChars) {
+			message = withPhrase
+		}
+	}
+	if hasQuirk(persona, models.QuirkOccasionalTypos) && rng.Float64() < typoChance {
+		message = addTypo(message, rng)
+	}
+	if hasQuirk(persona, models.QuirkLowercaseOnly) {
+		message = strings.ToLower(message)
+	}
+	if hasQuirk(persona, models.QuirkNoPunctuation) {
+		message = stripPunctuation(message)
+	}
+	if maxChars > 0 {
+		if runes := []rune(message); len(runes) > maxChars {
+			return strings.TrimSpace(string(runes[:maxChars])), true
+		}
+	}
+	return message, false
+}
+
+func hasQuirk(persona models.Persona, quirk string) bool {
+	for _, item := range persona.Quirks {
+		if item == quirk {
+			return true
+		}
+	}
+	return false
+}
+
+// addTypo swaps two neighbouring letters inside one longer word; the message
+// keeps its length.
+func addTypo(message string, rng *rand.Rand) string {
+	runes := []rune(message)
+	var candidates []int
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && unicode.IsLetter(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minTypoWordRunes {
+			// Keep the first and last letter so the word stays readable.
+			for j := start + 1; j < i-2; j++ {
+				candidates = append(candidates, j)
+			}
+		}
+		start = -1
+	}
+	if len(candidates) == 0 {
+		return message
+	}
+	i := candidates[rng.Intn(len(candidates))]
+	runes[i], runes[i+1] = runes[i+1], runes[i]
+	return string(runes)
+}
+
+// stripPunctuation drops punctuation and squeezes the spaces it leaves.
+func stripPunctuation(message string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, message)
+	return strings.Join(strings.Fields(stripped), " ")
+}
+
+// catchphraseChance resolves settings.catchphrase_chance; only an absent
+// value takes the default, so a request can send 0 to turn catchphrases off.
+func catchphraseChance(settings models.PlanSettings) float64 {
+	if settings.CatchphraseChance == nil {
+		return defaultCatchphraseChance
+	}
+	return *settings.CatchphraseChance
+}
+
+// truncationCounter counts generated messages cut to the length cap.
+type truncationCounter interface {
+	countTruncated()
+}
+
+// styleMessage applies the bot's persona style to a generated message before
+// the action is built and recorded, so the repetition memory holds what the
+// bot actually says.
+func (p *Planner) styleMessage(settings models.PlanSettings, bot models.BotProfile, message string, counter truncationCounter, rng *rand.Rand) string {
+	styled, truncated := styleApply(message, bot.Persona, catchphraseChance(settings), p.tuning.Load().maxMessageChars, rng)
+	if truncated {
+		counter.countTruncated()
+	}
+	return styled
+}
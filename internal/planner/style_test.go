@@ -0,0 +1,144 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestStyleApplyQuirks(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		quirks   []string
+		maxChars int
+		want     string
+		wantCut  bool
+	}{
+		{name: "no quirks", message: "Siema, co tam?", want: "Siema, co tam?"},
+		{name: "lowercase only", message: "Siema, CO tam?", quirks: []string{models.QuirkLowercaseOnly}, want: "siema, co tam?"},
+		{name: "no punctuation", message: "Siema, co tam?! Gramy...", quirks: []string{models.QuirkNoPunctuation}, want: "Siema co tam Gramy"},
+		{name: "lowercase without punctuation", message: "Siema, CO tam?", quirks: []string{models.QuirkNoPunctuation, models.QuirkLowercaseOnly}, want: "siema co tam"},
+		{name: "capped after quirks", message: "Siema wszystkim na serwerze", quirks: []string{models.QuirkLowercaseOnly}, maxChars: 14, want: "siema wszystki", wantCut: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persona := models.Persona{Quirks: tt.quirks}
+			got, cut := styleApply(tt.message, persona, 0, tt.maxChars, rand.New(rand.NewSource(1)))
+			if got != tt.want || cut != tt.wantCut {
+				t.Fatalf("styleApply(%q) = %q, %t, want %q, %t", tt.message, got, cut, tt.want, tt.wantCut)
+			}
+		})
+	}
+}
+
+func TestStyleApplyOccasionalTypos(t *testing.T) {
+	const message = "ktos idzie na spawn?"
+	persona := models.Persona{Quirks: []string{models.QuirkOccasionalTypos}}
+	typos := 0
+	for seed := int64(0); seed < 200; seed++ {
+		got, _ := styleApply(message, persona, 0, 0, rand.New(rand.NewSource(seed)))
+		if again, _ := styleApply(message, persona, 0, 0, rand.New(rand.NewSource(seed))); got != again {
+			t.Fatalf("seed %d gave different results", seed)
+		}
+		if len(got) != len(message) {
+			t.Fatalf("typo changed the length: %q", got)
+		}
+		if got != message {
+			typos++
+		}
+	}
+	if typos == 0 || typos == 200 {
+		t.Fatalf("expected typos in some messages only, got %d of 200", typos)
+	}
+}
+
+func TestStyleApplyCatchphrases(t *testing.T) {
+	persona := models.Persona{Catchphrases: []string{"xD"}}
+	tests := []struct {
+		name     string
+		chance   float64
+		maxChars int
+		want     []string
+	}{
+		{name: "never", chance: 0, want: []string{"gramy?"}},
+		{name: "always", chance: 1, want: []string{"gramy? xD", "xD gramy?"}},
+		{name: "does not fit", chance: 1, maxChars: 8, want: []string{"gramy?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				got, _ := styleApply("gramy?", persona, tt.chance, tt.maxChars, rand.New(rand.NewSource(seed)))
+				found := false
+				for _, want := range tt.want {
+					found = found || got == want
+				}
+				if !found {
+					t.Fatalf("seed %d: got %q, want one of %q", seed, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func float64Ptr(value float64) *float64 { return &value }
+
+func styleTestRequest(chance *float64) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: "req-style",
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    1712345000000,
+		Bots: []models.BotProfile{{BotID: "bot-1", Persona: models.Persona{
+			Catchphrases: []string{"XD"},
+			Quirks:       []string{models.QuirkLowercaseOnly, models.QuirkNoPunctuation},
+		}}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1, CatchphraseChance: chance},
+	}
+}
+
+func TestPlanAppliesPersonaStyle(t *testing.T) {
+	resp := NewPlanner(nil, Config{MaxMessageChars: 80}).Plan(context.Background(), styleTestRequest(float64Ptr(1)))
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected one action, got %+v", resp.Actions)
+	}
+	message := resp.Actions[0].Message
+	if message != strings.ToLower(message) || strings.ContainsAny(message, ",.!?") || !strings.Contains(message, "xd") {
+		t.Fatalf("message %q does not carry the persona style", message)
+	}
+}
+
+func TestPlanCatchphraseChanceZeroAddsNoCatchphrase(t *testing.T) {
+	for seed := 0; seed < 20; seed++ {
+		req := styleTestRequest(float64Ptr(0))
+		req.RequestID = fmt.Sprintf("req-style-off-%d", seed)
+		resp := NewPlanner(nil, Config{MaxMessageChars: 80}).Plan(context.Background(), req)
+		if len(resp.Actions) != 1 {
+			t.Fatalf("expected one action, got %+v", resp.Actions)
+		}
+		if message := resp.Actions[0].Message; strings.Contains(message, "xd") {
+			t.Fatalf("catchphrase_chance 0 still added a catchphrase: %q", message)
+		}
+	}
+}
+
+func TestPlanRecordsStyledMessage(t *testing.T) {
+	planner := NewPlanner(nil, Config{MaxMessageChars: 5})
+	resp := planner.Plan(context.Background(), styleTestRequest(float64Ptr(0)))
+	if len(resp.Actions) != 1 {
+		t.Fatalf("expected one action, got %+v", resp.Actions)
+	}
+	message := resp.Actions[0].Message
+	if len([]rune(message)) > 5 || resp.Debug.TruncatedMessages != 1 {
+		t.Fatalf("message %q with debug %+v, want it cut to 5 runes and counted", message, resp.Debug)
+	}
+	if !planner.sentRecently("srv-1", "bot-1", message) {
+		t.Fatalf("repetition memory %v does not hold the styled message %q", planner.memory["srv-1"]["bot-1"].RecentMessages, message)
+	}
+}
@@ -0,0 +1,109 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/models"
+	"aichatplayers/internal/util"
+)
+
+const (
+	// systemEventWindowMS is how old a SYSTEM announcement may be to still get
+	// a reaction; systemEventCooldownMS keeps the same announcement, repeated
+	// by the server or resent with the chat history, from starting a new wave.
+	systemEventWindowMS   int64 = 30000
+	systemEventCooldownMS int64 = 180000
+
+	systemEventGapMS    int64 = 1500
+	systemEventJitterMS int64 = 1500
+)
+
+// recentSystemEvent returns the latest SYSTEM message if it is recent and
+// matches the event keywords.
+func recentSystemEvent(req models.PlanRequest, keywords *topicKeywords) (models.ChatMessage, bool) {
+	for i := len(req.Chat) - 1; i >= 0; i-- {
+		message := req.Chat[i]
+		if !strings.EqualFold(message.SenderType, "SYSTEM") {
+			continue
+		}
+		if req.TimeMS-message.TimestampMS > systemEventWindowMS {
+			return models.ChatMessage{}, false
+		}
+		return message, keywords.matches(util.NormalizeText(message.Message), TopicEvent)
+	}
+	return models.ChatMessage{}, false
+}
+
+func (p *Planner) systemEventOnCooldown(serverID string, nowMS int64) bool {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	last, ok := p.systemEvents[serverID]
+	return ok && nowMS-last < systemEventCooldownMS
+}
+
+func (p *Planner) rememberSystemEvent(serverID string, nowMS int64) {
+	if serverID == "" {
+		serverID = "default"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.systemEvents[serverID] = nowMS
+}
+
+// systemEventPlan lets up to MaxActions bots hype a server announcement, a
+// few seconds apart, regardless of the reply chance.
+func (p *Planner) systemEventPlan(ctx context.Context, req models.PlanRequest, announcement models.ChatMessage, bots []models.BotProfile, required *requiredTracker, routing *llmRouting, settings models.PlanSettings, rng *rand.Rand) ([]models.PlannedAction, bool, bool) {
+	selected := required.selectBots(bots, settings.MaxActions, p.newBotSelector(req.Server.ServerID, settings, req.TimeMS), rng)
+	logging.Ctx(ctx).Infof("planner_plan_system_event request_id=%s transaction_id=%s bots=%v announcement=%q", req.RequestID, req.RequestID, botIDs(selected), announcement.Message)
+	actions := make([]models.PlannedAction, 0, len(selected))
+	llmAttempted := false
+	llmUsed := false
+	previousDelay := int64(0)
+	for _, bot := range selected {
+		if requestExpired(ctx, req.RequestID) {
+			required.fail(bot.BotID, "cancelled")
+			continue
+		}
+		start := p.clock.Now()
+		message, _, attempted, used, _ := p.generateMessage(ctx, req, TopicEvent, bot, false, llm.Request{SystemEvent: announcement.Message}, routing, rng)
+		source, generationMS := routing.observe(p.clock.Now().Sub(start), attempted, used)
+		llmAttempted = llmAttempted || attempted
+		llmUsed = llmUsed || used
+		if message == "" {
+			required.fail(bot.BotID, "no_message")
+			continue
+		}
+		message = p.styleMessage(settings, bot, message, routing, rng)
+		delay := randomDelay(settings, bot.CooldownMS, rng)
+		if len(actions) > 0 {
+			if minDelay := previousDelay + systemEventGapMS + rng.Int63n(systemEventJitterMS); delay < minDelay {
+				delay = minDelay
+			}
+		}
+		previousDelay = delay
+		actions = append(actions, models.PlannedAction{
+			BotID:        bot.BotID,
+			SendAfterMS:  delay,
+			Message:      message,
+			Visibility:   visibilityPublic,
+			Reason:       models.ReasonSystemEventReact,
+			Source:       source,
+			GenerationMS: generationMS,
+		})
+		p.recordAction(req, bot.BotID, TopicEvent, message)
+		required.succeed(bot.BotID)
+	}
+	if len(actions) > 0 && !req.DryRun {
+		p.rememberSystemEvent(req.Server.ServerID, req.TimeMS)
+	}
+	return actions, llmAttempted, llmUsed
+}
@@ -0,0 +1,73 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func systemEventRequest(requestID string, timeMS, announcedMS int64) models.PlanRequest {
+	return models.PlanRequest{
+		RequestID: requestID,
+		Server:    models.ServerContext{ServerID: "srv-1"},
+		TimeMS:    timeMS,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}, {BotID: "bot-3", Name: "Zenek"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: announcedMS, Sender: "Server", SenderType: "SYSTEM", Message: "Event start za 5 minut!"},
+			{TimestampMS: announcedMS + 500, Sender: "Steve", SenderType: "PLAYER", Message: "siema"},
+		},
+		Settings: models.PlanSettings{MaxActions: 3, ReplyChance: 0.01},
+	}
+}
+
+func TestSystemEventSchedulesStaggeredReactions(t *testing.T) {
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{})
+	const nowMS = int64(1712345000000)
+
+	resp := planner.Plan(context.Background(), systemEventRequest("event-1", nowMS, nowMS-2000))
+	if resp.Debug.ChosenStrategy != "llm" || len(resp.Actions) != 3 {
+		t.Fatalf("expected three reactions, got %+v", resp)
+	}
+	seen := make(map[string]bool)
+	for i, action := range resp.Actions {
+		if action.Reason != models.ReasonSystemEventReact || seen[action.BotID] {
+			t.Fatalf("unexpected action %d: %+v", i, action)
+		}
+		seen[action.BotID] = true
+		if i > 0 && action.SendAfterMS < resp.Actions[i-1].SendAfterMS+systemEventGapMS {
+			t.Fatalf("reactions are not staggered: %+v", resp.Actions)
+		}
+	}
+	for _, req := range generator.requests {
+		if req.SystemEvent != "Event start za 5 minut!" {
+			t.Fatalf("expected announcement in llm request, got %q", req.SystemEvent)
+		}
+	}
+
+	repeated := planner.Plan(context.Background(), systemEventRequest("event-2", nowMS+60000, nowMS+59000))
+	for _, action := range repeated.Actions {
+		if action.Reason == models.ReasonSystemEventReact {
+			t.Fatalf("repeated announcement should be on cooldown, got %+v", repeated.Actions)
+		}
+	}
+}
+
+func TestSystemEventIgnoresStaleOrUnrelatedAnnouncements(t *testing.T) {
+	const nowMS = int64(1712345000000)
+	stale := systemEventRequest("stale", nowMS, nowMS-systemEventWindowMS-1)
+	unrelated := systemEventRequest("unrelated", nowMS, nowMS-1000)
+	unrelated.Chat[0].Message = "Restart serwera o 3:00"
+
+	for _, req := range []models.PlanRequest{stale, unrelated} {
+		t.Run(req.RequestID, func(t *testing.T) {
+			resp := NewPlanner(noopLLM{}, Config{}).Plan(context.Background(), req)
+			for _, action := range resp.Actions {
+				if action.Reason == models.ReasonSystemEventReact {
+					t.Fatalf("unexpected system event reaction %+v", resp.Actions)
+				}
+			}
+		})
+	}
+}
@@ -1,40 +1,180 @@
 package planner
 
-var greetingTemplates = []string{
-	"siema!",
-	"hejka!",
-	"elo, co tam?",
-	"siemanko wszystkim!",
-}
-
-var pvpNeutralTemplates = []string{
-	"ja jeszcze eq ogarniam, zaraz zobaczę",
-	"chyba event zaraz, to ogarnę po nim",
-	"jeszcze chwilę, dopiero wbijam",
-}
+import "strings"
 
-var eventTemplates = []string{
-	"event zaraz startuje, warto się zebrać 😄",
-	"o, event! lecę zobaczyć co tam",
-	"event? to chyba tam warto być",
+type templateSet struct {
+	Greeting []string
+	// GreetingNamed takes the greeting player's name.
+	GreetingNamed  []string
+	PVPNeutral     []string
+	Event          []string
+	EventCountdown []string
+	Help           []string
+	// ServerInfo takes the online player count; ServerInfoMode takes the
+	// mode and then the count.
+	ServerInfo     []string
+	ServerInfoMode []string
+	SmallTalk      []string
+	Engagement     []string
+	Banter         []banterPair
+	NewbieAddOns   []string
 }
 
-var helpTemplates = []string{
-	"też się dopiero uczę, ale lobby jest przy spawnie",
-	"jak coś to pytaj, może ktoś podpowie",
-	"nie jestem pewien, ale spróbuj w /help",
+// banterPair is a call/response exchange; pairs share indexes across
+// languages so bots with different personas still answer each other.
+type banterPair struct {
+	Call     string
+	Response string
 }
 
-var smallTalkTemplates = []string{
-	"ktoś coś robi?",
-	"co teraz gracie?",
-	"spokojnie dziś na serwerze 😅",
-}
+const defaultTemplateLanguage = "pl"
 
-var newbieAddOns = []string{
-	"ja dopiero wbijam",
-	"jestem nowa tutaj",
-	"nie ogarniam jeszcze wszystkiego",
+var templateSets = map[string]templateSet{
+	"pl": {
+		Greeting: []string{
+			"siema!",
+			"hejka!",
+			"elo, co tam?",
+			"siemanko wszystkim!",
+		},
+		GreetingNamed: []string{
+			"siema %s!",
+			"hejka %s!",
+			"elo %s, co tam?",
+			"siemanko %s!",
+		},
+		PVPNeutral: []string{
+			"ja jeszcze eq ogarniam, zaraz zobaczę",
+			"chyba event zaraz, to ogarnę po nim",
+			"jeszcze chwilę, dopiero wbijam",
+		},
+		Event: []string{
+			"event zaraz startuje, warto się zebrać 😄",
+			"o, event! lecę zobaczyć co tam",
+			"event? to chyba tam warto być",
+		},
+		EventCountdown: []string{
+			"event %s, warto się zebrać 😄",
+			"o, event %s! lecę się przygotować",
+			"event %s? to ogarniam eq",
+		},
+		Help: []string{
+			"też się dopiero uczę, ale lobby jest przy spawnie",
+			"jak coś to pytaj, może ktoś podpowie",
+			"nie jestem pewien, ale spróbuj w /help",
+		},
+		ServerInfo: []string{
+			"jest nas teraz %d",
+			"na serwerze jest %d osób",
+			"teraz %d online, całkiem sporo",
+		},
+		ServerInfoMode: []string{
+			"to %s, jest nas teraz %d",
+			"gramy %s, online %d osób",
+			"tryb %s, teraz %d graczy",
+		},
+		SmallTalk: []string{
+			"ktoś coś robi?",
+			"co teraz gracie?",
+			"spokojnie dziś na serwerze 😅",
+		},
+		Engagement: []string{
+			"siema %s, co budujesz?",
+			"%s, jak leci?",
+			"hej %s, idziesz potem na jakiś event?",
+			"%s, ogarniasz już serwer?",
+		},
+		Banter: []banterPair{
+			{Call: "ktoś w ogóle dziś gra?", Response: "ja jestem, tylko eq ogarniam"},
+			{Call: "ale cisza na serwerze", Response: "no, wszyscy chyba na evencie"},
+			{Call: "co budujecie ostatnio?", Response: "ja domek przy spawnie, powoli idzie"},
+			{Call: "ktoś ma zbędne żelazo?", Response: "mam trochę, zaraz podrzucę"},
+		},
+		NewbieAddOns: []string{
+			"ja dopiero wbijam",
+			"jestem nowa tutaj",
+			"nie ogarniam jeszcze wszystkiego",
+		},
+	},
+	"en": {
+		Greeting: []string{
+			"hey!",
+			"hi all!",
+			"yo, what's up?",
+			"hello everyone!",
+		},
+		GreetingNamed: []string{
+			"hey %s!",
+			"hi %s!",
+			"yo %s, what's up?",
+			"hello %s!",
+		},
+		PVPNeutral: []string{
+			"still sorting my gear, give me a sec",
+			"think an event is coming, maybe after that",
+			"one sec, just joined",
+		},
+		Event: []string{
+			"event starting soon, let's go 😄",
+			"oh, an event! gonna check it out",
+			"event? sounds worth joining",
+		},
+		EventCountdown: []string{
+			"event %s, let's gather up 😄",
+			"oh, event %s! getting ready",
+			"event %s? time to grab my gear",
+		},
+		Help: []string{
+			"still learning too, but the lobby is near spawn",
+			"just ask, someone will know",
+			"not sure, but try /help",
+		},
+		ServerInfo: []string{
+			"there are %d of us right now",
+			"%d players online at the moment",
+			"%d online now, pretty busy",
+		},
+		ServerInfoMode: []string{
+			"it's %s, %d of us online right now",
+			"we're playing %s, %d players online",
+			"%s mode, %d players at the moment",
+		},
+		SmallTalk: []string{
+			"anyone doing anything?",
+			"what are you all playing?",
+			"quiet on the server today 😅",
+		},
+		Engagement: []string{
+			"hey %s, what are you building?",
+			"%s, how's it going?",
+			"hi %s, joining any event later?",
+			"%s, finding your way around the server?",
+		},
+		Banter: []banterPair{
+			{Call: "anyone actually playing today?", Response: "i'm here, just sorting my gear"},
+			{Call: "so quiet on the server", Response: "yeah, everyone's probably at the event"},
+			{Call: "what are you building lately?", Response: "a small house near spawn, slow going"},
+			{Call: "anyone got spare iron?", Response: "i have some, will drop it in a sec"},
+		},
+		NewbieAddOns: []string{
+			"just joined",
+			"i'm new here",
+			"still figuring things out",
+		},
+	},
 }
 
 var friendlyEmojis = []string{"😄", "😊", "✨", "😅"}
+
+func templatesFor(language string) templateSet {
+	code := strings.ToLower(strings.TrimSpace(language))
+	if set, ok := templateSets[code]; ok {
+		return set
+	}
+	if base, _, found := strings.Cut(code, "-"); found {
+		if set, ok := templateSets[base]; ok {
+			return set
+		}
+	}
+	return templateSets[defaultTemplateLanguage]
+}
@@ -0,0 +1,96 @@
+package planner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aichatplayers/internal/llm"
+	"aichatplayers/internal/models"
+)
+
+type blockingLLM struct{}
+
+func (blockingLLM) Enabled() bool { return true }
+
+func (blockingLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (blockingLLM) Close() error { return nil }
+
+func TestPlanStopsWhenRequestContextExpires(t *testing.T) {
+	planner := NewPlanner(blockingLLM{}, Config{LLMTimeout: time.Minute})
+	req := models.PlanRequest{
+		RequestID:      "req-timeout",
+		Server:         models.ServerContext{ServerID: "srv-1"},
+		TimeMS:         1712345000000,
+		Bots:           []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}, {BotID: "bot-2", Name: "Ola"}},
+		RequiredBotIDs: []string{"bot-1", "bot-2"},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 2, ReplyChance: 1},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp := planner.Plan(ctx, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("plan blocked for %s after the request context expired", elapsed)
+	}
+	if len(resp.Actions) != 2 {
+		t.Fatalf("expected heuristic actions from both bots generated in parallel, got %+v", resp.Actions)
+	}
+	for _, action := range resp.Actions {
+		if action.Reason == "llm" {
+			t.Fatalf("expected heuristic fallbacks after the deadline, got %+v", resp.Actions)
+		}
+	}
+}
+
+type cancellingLLM struct {
+	cancel context.CancelFunc
+	calls  atomic.Int32
+}
+
+func (c *cancellingLLM) Enabled() bool { return true }
+
+func (c *cancellingLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	c.calls.Add(1)
+	c.cancel()
+	return "mam portal przy spawnie", nil
+}
+
+func (c *cancellingLLM) Close() error { return nil }
+
+func TestPlanCancelStopsFurtherGenerateCalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	generator := &cancellingLLM{cancel: cancel}
+	planner := NewPlanner(generator, Config{})
+	req := models.PlanRequest{
+		RequestID:      "req-cancel",
+		Server:         models.ServerContext{ServerID: "srv-1"},
+		TimeMS:         1712345000000,
+		Bots:           []models.BotProfile{{BotID: "bot-1"}},
+		RequiredBotIDs: []string{"bot-1"},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344999000, Sender: "RealPlayer123", SenderType: "PLAYER", Message: "siema, jak zrobic portal?"},
+		},
+		Settings: models.PlanSettings{MaxActions: 2, ReplyChance: 1},
+	}
+
+	// One bot with two topics needs two generation rounds; the cancel in
+	// the first round must stop the second.
+	resp := planner.Plan(ctx, req)
+	if calls := generator.calls.Load(); calls != 1 {
+		t.Fatalf("Generate called %d times after cancel, want 1", calls)
+	}
+	if len(resp.Actions) != 1 || resp.Debug.ChosenStrategy != "llm_cancelled" {
+		t.Fatalf("expected one partial llm action, got %+v (debug %+v)", resp.Actions, resp.Debug)
+	}
+}
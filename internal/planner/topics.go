@@ -1,11 +1,16 @@
 package planner
 
-var (
-	greetingKeywords = []string{"siema", "hej", "czesc", "elo", "yo", "witam"}
-	pvpKeywords      = []string{"kto pvp", "pvp", "klepac", "1v1", "duel", "pojedynek"}
-	eventKeywords    = []string{"event", "start", "drop", "turniej", "boss"}
-	helpKeywords     = []string{"jak zrobic", "jak wejsc", "jak dostac", "jak to", "gdzie", "co robic", "pomoc", "help"}
-	toxicKeywords    = []string{"kurwa", "chuj", "chujowy", "jebac", "idiota"}
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"aichatplayers/internal/logging"
+	"aichatplayers/internal/util"
 )
 
 type Topic string
@@ -16,4 +21,356 @@ const (
 	TopicEvent     Topic = "event"
 	TopicHelp      Topic = "help"
 	TopicToxic     Topic = "toxic"
+	// TopicServerInfo is a question about the server mode or player count.
+	TopicServerInfo Topic = "server_info"
 )
+
+const (
+	topicsModeMerge   = "merge"
+	topicsModeReplace = "replace"
+)
+
+// forbiddenSubjects are filtered from LLM output for every bot, on top of the
+// bot's own avoid_topics.
+var forbiddenSubjects = []string{"payments", "admin_powers", "cheating"}
+
+// builtinTopics is the detection order: the first topic with a matching
+// keyword wins for a message. Custom topics are checked afterwards.
+var builtinTopics = []Topic{TopicToxic, TopicEvent, TopicPVPInvite, TopicServerInfo, TopicHelp, TopicGreeting}
+
+// Single words match whole words only (see util.ContainsKeyword), so the
+// defaults list common inflections explicitly.
+//
+//go:embed topics.json
+var defaultTopicsJSON []byte
+
+var defaultTopicKeywords = mustParseTopicKeywords(defaultTopicsJSON)
+
+type topicsFile struct {
+	Mode   string                     `json:"mode"`
+	Topics map[string]TopicDefinition `json:"topics"`
+	// AvoidTopics maps avoid_topics labels (as sent in bot personas) to the
+	// keywords that mark an LLM reply as being about that subject.
+	AvoidTopics map[string][]string `json:"avoid_topics,omitempty"`
+}
+
+// TopicDefinition is one entry of the topics file; Name comes from its key.
+type TopicDefinition struct {
+	Name     Topic    `json:"-"`
+	Keywords []string `json:"keywords"`
+	// Templates (by language) are required for custom topics and rejected
+	// for built-in ones, whose replies come from templateSets.
+	Templates map[string][]string `json:"templates,omitempty"`
+	// LLMHint is appended to the LLM task when the topic triggered a reply.
+	LLMHint string `json:"llm_hint,omitempty"`
+	// CooldownMS overrides the default topic cooldown; per-request
+	// settings.topic_cooldowns still win.
+	CooldownMS *int64 `json:"cooldown_ms,omitempty"`
+}
+
+type topicRule struct {
+	topic    Topic
+	keywords []string
+}
+
+type topicKeywords struct {
+	rules       []topicRule
+	definitions map[Topic]TopicDefinition
+	avoid       map[string][]string
+}
+
+func (p *Planner) topicKeywords() *topicKeywords {
+	return p.topics.Load()
+}
+
+// ReloadTopics re-reads the topic keywords file and returns the keyword count
+// per topic. On error the current keywords stay in place; without a
+// configured file the embedded defaults are (re)applied.
+func (p *Planner) ReloadTopics() (map[string]int, error) {
+	keywords := defaultTopicKeywords
+	if p.topicsPath != "" {
+		loaded, err := loadTopicKeywords(p.topicsPath)
+		if err != nil {
+			return nil, err
+		}
+		keywords = loaded
+	}
+	p.topics.Store(keywords)
+	counts := keywords.counts()
+	logging.Infof("planner_topics_loaded path=%s topics=%d custom=%d", p.topicsPath, len(counts), keywords.customCount())
+	return counts, nil
+}
+
+func mustParseTopicKeywords(data []byte) *topicKeywords {
+	keywords, err := parseTopicKeywords(data, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded topics.json: %v", err))
+	}
+	return keywords
+}
+
+// loadTopicKeywords reads a topics file and applies it on top of the
+// embedded defaults.
+func loadTopicKeywords(path string) (*topicKeywords, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTopicKeywords(data, defaultTopicKeywords)
+}
+
+func parseTopicKeywords(data []byte, base *topicKeywords) (*topicKeywords, error) {
+	var file topicsFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode topics: %w", err)
+	}
+	mode := strings.ToLower(strings.TrimSpace(file.Mode))
+	if mode == "" {
+		mode = topicsModeMerge
+	}
+	if mode != topicsModeMerge && mode != topicsModeReplace {
+		return nil, fmt.Errorf("mode must be %q or %q, got %q", topicsModeMerge, topicsModeReplace, file.Mode)
+	}
+
+	keywords := make(map[Topic][]string)
+	if mode == topicsModeMerge && base != nil {
+		for _, rule := range base.rules {
+			keywords[rule.topic] = append([]string(nil), rule.keywords...)
+		}
+	}
+	definitions := make(map[Topic]TopicDefinition)
+	if mode == topicsModeMerge && base != nil {
+		for topic, definition := range base.definitions {
+			definitions[topic] = definition
+		}
+	}
+	var custom []Topic
+	for name, definition := range file.Topics {
+		topic := Topic(strings.TrimSpace(name))
+		if topic == "" {
+			return nil, fmt.Errorf("topic name must not be empty")
+		}
+		builtin := isBuiltinTopic(topic)
+		if builtin && len(definition.Templates) > 0 {
+			return nil, fmt.Errorf("topic %q: templates are only supported for custom topics", topic)
+		}
+		if definition.CooldownMS != nil && *definition.CooldownMS < 0 {
+			return nil, fmt.Errorf("topic %q: cooldown_ms must be >= 0", topic)
+		}
+		if !builtin {
+			if len(nonEmpty(definition.Keywords)) == 0 {
+				return nil, fmt.Errorf("custom topic %q needs at least one keyword", topic)
+			}
+			if !hasTemplates(definition.Templates) {
+				return nil, fmt.Errorf("topic %q is not a known topic and has no templates", topic)
+			}
+			definition.Templates = normalizeTemplates(definition.Templates)
+		}
+		definition.Name = topic
+		definition.LLMHint = strings.Join(strings.Fields(definition.LLMHint), " ")
+		keywords[topic] = mergeKeywords(keywords[topic], definition.Keywords)
+		definition.Keywords = keywords[topic]
+		definitions[topic] = definition
+	}
+	for topic := range definitions {
+		if !isBuiltinTopic(topic) {
+			custom = append(custom, topic)
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i] < custom[j] })
+
+	avoid := make(map[string][]string)
+	if mode == topicsModeMerge && base != nil {
+		for label, keywords := range base.avoid {
+			avoid[label] = keywords
+		}
+	}
+	for label, labelKeywords := range file.AvoidTopics {
+		label = avoidLabel(label)
+		if label == "" {
+			return nil, fmt.Errorf("avoid_topics label must not be empty")
+		}
+		if len(nonEmpty(labelKeywords)) == 0 {
+			return nil, fmt.Errorf("avoid_topics %q needs at least one keyword", label)
+		}
+		avoid[label] = mergeKeywords(avoid[label], labelKeywords)
+	}
+
+	result := &topicKeywords{definitions: definitions, avoid: avoid}
+	for _, topic := range append(append([]Topic(nil), builtinTopics...), custom...) {
+		if len(keywords[topic]) > 0 {
+			result.rules = append(result.rules, topicRule{topic: topic, keywords: keywords[topic]})
+		}
+	}
+	return result, nil
+}
+
+// detect returns the first topic whose keywords appear in the normalized text.
+func (k *topicKeywords) detect(text string) (Topic, bool) {
+	for _, rule := range k.rules {
+		if util.ContainsKeyword(text, rule.keywords) {
+			return rule.topic, true
+		}
+	}
+	return "", false
+}
+
+func (k *topicKeywords) matches(text string, topic Topic) bool {
+	for _, rule := range k.rules {
+		if rule.topic == topic {
+			return util.ContainsKeyword(text, rule.keywords)
+		}
+	}
+	return false
+}
+
+// customTemplates picks the templates for the bot's language, falling back
+// to the base language, the default language and then any language.
+func (k *topicKeywords) customTemplates(topic Topic, language string) []string {
+	byLanguage := k.definitions[topic].Templates
+	if len(byLanguage) == 0 {
+		return nil
+	}
+	code := strings.ToLower(strings.TrimSpace(language))
+	base, _, _ := strings.Cut(code, "-")
+	for _, candidate := range []string{code, base, defaultTemplateLanguage} {
+		if templates := byLanguage[candidate]; len(templates) > 0 {
+			return templates
+		}
+	}
+	languages := make([]string, 0, len(byLanguage))
+	for language := range byLanguage {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return byLanguage[languages[0]]
+}
+
+// hint returns the extra LLM task line configured for the topic, if any.
+func (k *topicKeywords) hint(topic Topic) string {
+	return k.definitions[topic].LLMHint
+}
+
+// cooldown returns the topic's configured cooldown.
+func (k *topicKeywords) cooldown(topic Topic) (int64, bool) {
+	if cooldown := k.definitions[topic].CooldownMS; cooldown != nil {
+		return *cooldown, true
+	}
+	return 0, false
+}
+
+func (k *topicKeywords) customCount() int {
+	count := 0
+	for topic := range k.definitions {
+		if !isBuiltinTopic(topic) {
+			count++
+		}
+	}
+	return count
+}
+
+// avoidedTopic returns the first forbidden subject or avoid_topics label the
+// normalized text is about. Labels without configured keywords fall back to a
+// detection topic of the same name and then to the label itself.
+func (k *topicKeywords) avoidedTopic(text string, labels []string) (string, bool) {
+	for _, label := range append(append([]string(nil), forbiddenSubjects...), labels...) {
+		label = avoidLabel(label)
+		if label == "" {
+			continue
+		}
+		keywords := k.avoid[label]
+		if len(keywords) == 0 {
+			for _, rule := range k.rules {
+				if string(rule.topic) == label {
+					keywords = rule.keywords
+					break
+				}
+			}
+		}
+		if len(keywords) == 0 {
+			keywords = mergeKeywords(nil, []string{strings.ReplaceAll(label, "_", " ")})
+		}
+		if util.ContainsKeyword(text, keywords) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// Topics lists the active topics in detection order: built-in topics
+// first, then custom topics by name.
+func (p *Planner) Topics() []string {
+	rules := p.topicKeywords().rules
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, string(rule.topic))
+	}
+	return names
+}
+
+// counts reports the number of keywords per topic, for reload summaries.
+func (k *topicKeywords) counts() map[string]int {
+	counts := make(map[string]int, len(k.rules))
+	for _, rule := range k.rules {
+		counts[string(rule.topic)] = len(rule.keywords)
+	}
+	return counts
+}
+
+func avoidLabel(label string) string {
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+func isBuiltinTopic(topic Topic) bool {
+	for _, builtin := range builtinTopics {
+		if topic == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeKeywords(existing, added []string) []string {
+	seen := make(map[string]bool, len(existing)+len(added))
+	merged := make([]string, 0, len(existing)+len(added))
+	for _, keyword := range append(append([]string(nil), existing...), added...) {
+		keyword = strings.Join(util.Words(util.NormalizeText(keyword)), " ")
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		merged = append(merged, keyword)
+	}
+	return merged
+}
+
+func nonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func hasTemplates(byLanguage map[string][]string) bool {
+	for _, templates := range byLanguage {
+		if len(nonEmpty(templates)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeTemplates(byLanguage map[string][]string) map[string][]string {
+	normalized := make(map[string][]string, len(byLanguage))
+	for language, templates := range byLanguage {
+		if templates = nonEmpty(templates); len(templates) > 0 {
+			normalized[strings.ToLower(strings.TrimSpace(language))] = templates
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,310 @@
+package planner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestDetectTopicsRespectsWordBoundaries(t *testing.T) {
+	tests := []struct {
+		message string
+		want    Topic
+	}{
+		{message: "pvpowy ranking juz jest?", want: ""},
+		{message: "ale zjebane te drzwi", want: ""},
+		{message: "idiotyczny ten parkour", want: ""},
+		{message: "restart serwera o 12?", want: ""},
+		{message: "kto pvp?", want: TopicPVPInvite},
+		{message: "PVP na spawnie", want: TopicPVPInvite},
+		{message: "jebane lagi", want: TopicToxic},
+		{message: "ty idioto", want: TopicToxic},
+		{message: "Siemanko!", want: TopicGreeting},
+		{message: "event startuje za 5 min", want: TopicEvent},
+		{message: "jak zrobić portal?", want: TopicHelp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			topics, _ := detectTopics([]models.ChatMessage{{SenderType: "PLAYER", Sender: "Steve", Message: tt.message}}, defaultTopicKeywords)
+			if tt.want == "" {
+				if len(topics) != 0 {
+					t.Fatalf("detectTopics(%q) = %v, want none", tt.message, topics)
+				}
+				return
+			}
+			if len(topics) != 1 || topics[0] != tt.want {
+				t.Fatalf("detectTopics(%q) = %v, want [%s]", tt.message, topics, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTopicsWeighsRecency(t *testing.T) {
+	player := func(message string) models.ChatMessage {
+		return models.ChatMessage{SenderType: "PLAYER", Sender: "Steve", Message: message}
+	}
+	bot := func(message string) models.ChatMessage {
+		return models.ChatMessage{SenderType: "BOT", Sender: "Kuba", Message: message}
+	}
+	tests := []struct {
+		name      string
+		chat      []models.ChatMessage
+		want      []Topic
+		wantScore float64
+	}{
+		{name: "fresh pvp beats stale greetings", chat: []models.ChatMessage{player("siema"), player("czesc"), player("kto pvp?")}, want: []Topic{TopicPVPInvite, TopicGreeting}, wantScore: 1},
+		{name: "repeated fresh greetings win", chat: []models.ChatMessage{player("kto pvp?"), player("siema"), player("czesc")}, want: []Topic{TopicGreeting, TopicPVPInvite}, wantScore: 1.5},
+		{name: "bot messages push player messages back", chat: []models.ChatMessage{player("siema"), bot("siema"), bot("hej")}, want: []Topic{TopicGreeting}, wantScore: 0.25 + 0.125 + 0.25},
+		{name: "bot topics alone are ignored", chat: []models.ChatMessage{player("fajny dom"), bot("kto pvp?")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topics, score := detectTopics(tt.chat, defaultTopicKeywords)
+			if len(topics) != len(tt.want) {
+				t.Fatalf("topics = %v, want %v", topics, tt.want)
+			}
+			for i := range topics {
+				if topics[i] != tt.want[i] {
+					t.Fatalf("topics = %v, want %v", topics, tt.want)
+				}
+			}
+			if score != tt.wantScore {
+				t.Fatalf("score = %v, want %v", score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestPlanReportsTopicScore(t *testing.T) {
+	planner := NewPlanner(nil, Config{})
+	resp := planner.Plan(context.Background(), models.PlanRequest{
+		RequestID: "topic-score",
+		TimeMS:    1712345000000,
+		Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+		Chat: []models.ChatMessage{
+			{TimestampMS: 1712344990000, SenderType: "PLAYER", Sender: "Steve", Message: "siema"},
+			{TimestampMS: 1712344999000, SenderType: "PLAYER", Sender: "Alex", Message: "kto pvp?"},
+		},
+		Settings: models.PlanSettings{ReplyChance: 1, MaxActions: 1},
+	})
+	if resp.Debug.Topic != string(TopicPVPInvite) || resp.Debug.TopicScore != 1 {
+		t.Fatalf("debug topic = %q score %v, want pvp_invite 1", resp.Debug.Topic, resp.Debug.TopicScore)
+	}
+	if len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonAvoidRealPVP {
+		t.Fatalf("expected a reply to the fresh pvp invite, got %+v", resp.Actions)
+	}
+}
+
+func TestParseTopicKeywords(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+		check   func(t *testing.T, keywords *topicKeywords)
+	}{
+		{
+			name: "merge keeps defaults",
+			file: `{"topics":{"event":{"keywords":["KoTH"]}}}`,
+			check: func(t *testing.T, keywords *topicKeywords) {
+				if !keywords.matches("koth za 5 min", TopicEvent) || !keywords.matches("event!", TopicEvent) {
+					t.Fatal("expected merged and default event keywords")
+				}
+			},
+		},
+		{
+			name: "replace drops defaults",
+			file: `{"mode":"replace","topics":{"greeting":{"keywords":["hello"]}}}`,
+			check: func(t *testing.T, keywords *topicKeywords) {
+				if keywords.matches("siema", TopicGreeting) || !keywords.matches("hello", TopicGreeting) {
+					t.Fatal("expected only file greetings")
+				}
+				if _, ok := keywords.detect("kurwa"); ok {
+					t.Fatal("expected toxic defaults to be replaced")
+				}
+			},
+		},
+		{
+			name: "custom topic",
+			file: `{"topics":{"dungeon":{"keywords":["loch"],"templates":{"pl":["lecimy do lochu?"]}}}}`,
+			check: func(t *testing.T, keywords *topicKeywords) {
+				if topic, ok := keywords.detect("kto idzie do loch"); !ok || topic != "dungeon" {
+					t.Fatalf("detect() = %q, %t", topic, ok)
+				}
+				if topic, _ := keywords.detect("siema, loch?"); topic != TopicGreeting {
+					t.Fatalf("built-in topics should win, got %q", topic)
+				}
+				if templates := keywords.customTemplates("dungeon", "en"); len(templates) != 1 {
+					t.Fatalf("expected fallback to pl templates, got %v", templates)
+				}
+			},
+		},
+		{
+			name: "hint and cooldown",
+			file: `{"topics":{"parkour":{"keywords":["parkour"],"templates":{"pl":["parkour!"]},"llm_hint":"  mention the  parkour map ","cooldown_ms":60000},"help":{"keywords":["pomocy"],"cooldown_ms":0}}}`,
+			check: func(t *testing.T, keywords *topicKeywords) {
+				if hint := keywords.hint("parkour"); hint != "mention the parkour map" {
+					t.Fatalf("hint() = %q", hint)
+				}
+				if cooldown, ok := keywords.cooldown("parkour"); !ok || cooldown != 60000 {
+					t.Fatalf("cooldown(parkour) = %d, %t", cooldown, ok)
+				}
+				if cooldown, ok := keywords.cooldown(TopicHelp); !ok || cooldown != 0 {
+					t.Fatalf("cooldown(help) = %d, %t", cooldown, ok)
+				}
+				if _, ok := keywords.cooldown(TopicGreeting); ok {
+					t.Fatal("greeting should use the default cooldown")
+				}
+			},
+		},
+		{
+			name: "avoid topics",
+			file: `{"avoid_topics":{"Redstone":["redstone","komparator"],"payments":["doladowanie"]}}`,
+			check: func(t *testing.T, keywords *topicKeywords) {
+				if label, ok := keywords.avoidedTopic("daj komparator", []string{"redstone"}); !ok || label != "redstone" {
+					t.Fatalf("avoidedTopic() = %q, %t", label, ok)
+				}
+				if _, ok := keywords.avoidedTopic("daj komparator", nil); ok {
+					t.Fatal("redstone should only be filtered for bots avoiding it")
+				}
+				if label, _ := keywords.avoidedTopic("kup doladowanie albo blik", nil); label != "payments" {
+					t.Fatalf("expected merged payments keywords, got %q", label)
+				}
+			},
+		},
+		{name: "avoid topic without keywords", file: `{"avoid_topics":{"redstone":[]}}`, wantErr: "needs at least one keyword"},
+		{name: "negative cooldown", file: `{"topics":{"help":{"keywords":["pomocy"],"cooldown_ms":-1}}}`, wantErr: "cooldown_ms must be >= 0"},
+		{name: "unknown topic without templates", file: `{"topics":{"dungeon":{"keywords":["loch"]}}}`, wantErr: "not a known topic"},
+		{name: "custom topic without keywords", file: `{"topics":{"dungeon":{"templates":{"pl":["x"]}}}}`, wantErr: "at least one keyword"},
+		{name: "templates on built-in topic", file: `{"topics":{"help":{"keywords":["pomocy"],"templates":{"pl":["x"]}}}}`, wantErr: "only supported for custom topics"},
+		{name: "unknown mode", file: `{"mode":"append","topics":{}}`, wantErr: "mode must be"},
+		{name: "unknown field", file: `{"topic":{}}`, wantErr: "decode topics"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keywords, err := parseTopicKeywords([]byte(tt.file), defaultTopicKeywords)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTopicKeywords() error: %v", err)
+			}
+			tt.check(t, keywords)
+		})
+	}
+}
+
+func TestPlannerReloadsTopicKeywords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topics.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	write(`{"topics":{"dungeon":{"keywords":["loch"],"templates":{"pl":["lecimy do lochu?"]}}}}`)
+	planner := NewPlanner(noopLLM{}, Config{TopicKeywordsPath: path})
+
+	plan := func(requestID, message string) models.PlanResponse {
+		return planner.Plan(context.Background(), models.PlanRequest{
+			RequestID: requestID,
+			Server:    models.ServerContext{ServerID: "srv-1"},
+			TimeMS:    1712345000000,
+			Bots:      []models.BotProfile{{BotID: "bot-" + requestID}},
+			Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: message}},
+			Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+		})
+	}
+	resp := plan("a", "kto na loch?")
+	if len(resp.Actions) != 1 || resp.Actions[0].Reason != models.ReasonCustomTopic || resp.Actions[0].Message != "lecimy do lochu?" {
+		t.Fatalf("expected custom topic reply, got %+v", resp.Actions)
+	}
+
+	write(`{"topics":{"dungeon":{"keywords":["dungeon"],"templates":{"pl":["dungeon? jasne"]}}}}`)
+	counts, err := planner.ReloadTopics()
+	if err != nil || counts["dungeon"] != 1 {
+		t.Fatalf("ReloadTopics() = %v, %v", counts, err)
+	}
+	if resp := plan("b", "kto na loch?"); len(resp.Actions) != 0 && resp.Actions[0].Reason == models.ReasonCustomTopic {
+		t.Fatalf("old keyword should no longer match, got %+v", resp.Actions)
+	}
+
+	write(`{"topics":{"dungeon":{"keywords":["loch"]}}}`)
+	if _, err := planner.ReloadTopics(); err == nil {
+		t.Fatal("expected invalid file to be rejected")
+	}
+	if resp := plan("c", "idziemy na dungeon"); len(resp.Actions) != 1 || resp.Actions[0].Message != "dungeon? jasne" {
+		t.Fatalf("failed reload should keep previous keywords, got %+v", resp.Actions)
+	}
+}
+
+func TestCustomTopicHintAndCooldown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topics.json")
+	content := `{"topics":{"parkour":{"keywords":["parkour"],"templates":{"pl":["parkour!"]},"llm_hint":"Mention the parkour map at spawn.","cooldown_ms":60000}}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	generator := &capturingLLM{}
+	planner := NewPlanner(generator, Config{TopicKeywordsPath: path})
+
+	plan := func(timeMS int64, cooldownMS *int64) models.PlanResponse {
+		return planner.Plan(context.Background(), models.PlanRequest{
+			RequestID: "parkour",
+			Server:    models.ServerContext{ServerID: "srv-1"},
+			TimeMS:    timeMS,
+			Bots:      []models.BotProfile{{BotID: "bot-1"}},
+			Chat:      []models.ChatMessage{{TimestampMS: timeMS - 1000, Sender: "Steve", SenderType: "PLAYER", Message: "kto na parkour?"}},
+			Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1, TopicCooldownMS: cooldownMS},
+		})
+	}
+	if resp := plan(1712345000000, nil); len(resp.Actions) != 1 {
+		t.Fatalf("expected a reply, got %+v", resp)
+	}
+	if len(generator.requests) != 1 || generator.requests[0].TopicHint != "Mention the parkour map at spawn." {
+		t.Fatalf("expected topic hint in llm request, got %+v", generator.requests)
+	}
+	if resp := plan(1712345030000, nil); len(resp.Actions) != 0 || resp.Debug.SuppressedReplies != 1 {
+		t.Fatalf("expected the 60s topic cooldown to suppress the reply, got %+v", resp)
+	}
+	if resp := plan(1712345040000, int64Ptr(1000)); len(resp.Actions) != 1 {
+		t.Fatalf("expected topic_cooldown_ms to win over the topics file, got %+v", resp)
+	}
+}
+
+func TestLLMOutputRespectsAvoidTopics(t *testing.T) {
+	tests := []struct {
+		name       string
+		message    string
+		avoid      []string
+		wantReason models.Reason
+	}{
+		{name: "forbidden payments", message: "wyslij przelew na blika to dam ci range", wantReason: models.ReasonAvoidTopicFiltered},
+		{name: "bot avoid label", message: "chodzcie na pvp na spawnie", avoid: []string{"pvp"}, wantReason: models.ReasonAvoidTopicFiltered},
+		{name: "clean reply", message: "siema, co budujecie?", avoid: []string{"pvp"}, wantReason: "llm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(fakeLLM{enabled: true, message: tt.message}, Config{})
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "avoid-" + tt.name,
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba", Persona: models.Persona{AvoidTopics: tt.avoid}}},
+				Chat:      []models.ChatMessage{{TimestampMS: 1712344999000, Sender: "Steve", SenderType: "PLAYER", Message: "siema"}},
+				Settings:  models.PlanSettings{MaxActions: 1, ReplyChance: 1},
+			})
+			if len(resp.Actions) != 1 || resp.Actions[0].Reason != tt.wantReason {
+				t.Fatalf("expected one action with reason %s, got %+v", tt.wantReason, resp.Actions)
+			}
+			if tt.wantReason == models.ReasonAvoidTopicFiltered && (resp.Actions[0].Message == tt.message || resp.Actions[0].Source != SourceHeuristic) {
+				t.Fatalf("filtered reply should fall back to a template, got %+v", resp.Actions[0])
+			}
+		})
+	}
+}
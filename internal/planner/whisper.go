@@ -0,0 +1,33 @@
+package planner
+
+import (
+	"strings"
+
+	"aichatplayers/internal/models"
+)
+
+const (
+	visibilityPublic  = "PUBLIC"
+	visibilityWhisper = "WHISPER"
+)
+
+// whisperTarget returns the player a help reply should be whispered to, or ""
+// when the reply stays public.
+func whisperTarget(messages []models.ChatMessage, settings models.PlanSettings, topic Topic) string {
+	if !settings.AllowWhispers || topic != TopicHelp {
+		return ""
+	}
+	var latest *models.ChatMessage
+	for i := range messages {
+		if !strings.EqualFold(messages[i].SenderType, "PLAYER") {
+			continue
+		}
+		if latest == nil || messages[i].TimestampMS >= latest.TimestampMS {
+			latest = &messages[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return strings.TrimSpace(latest.Sender)
+}
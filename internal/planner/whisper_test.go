@@ -0,0 +1,51 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"aichatplayers/internal/models"
+)
+
+func TestPlannerWhispersHelpReplies(t *testing.T) {
+	tests := []struct {
+		name           string
+		generator      LLMGenerator
+		message        string
+		sender         string
+		allowWhispers  bool
+		wantVisibility string
+		wantTarget     string
+		wantReason     models.Reason
+	}{
+		{name: "help whispered", generator: noopLLM{}, message: "jak zrobic portal?", sender: "RealPlayer123", allowWhispers: true, wantVisibility: "WHISPER", wantTarget: "RealPlayer123", wantReason: "helpful_hint_whisper"},
+		{name: "llm help whispered", generator: fakeLLM{enabled: true, message: "wejdz na spawn"}, message: "jak zrobic portal?", sender: "RealPlayer123", allowWhispers: true, wantVisibility: "WHISPER", wantTarget: "RealPlayer123", wantReason: "llm_whisper"},
+		{name: "flag absent", generator: noopLLM{}, message: "jak zrobic portal?", sender: "RealPlayer123", wantVisibility: "PUBLIC", wantReason: "helpful_hint"},
+		{name: "greeting stays public", generator: noopLLM{}, message: "siema", sender: "RealPlayer123", allowWhispers: true, wantVisibility: "PUBLIC", wantReason: "greeting"},
+		{name: "anonymous sender", generator: noopLLM{}, message: "jak zrobic portal?", sender: " ", allowWhispers: true, wantVisibility: "PUBLIC", wantReason: "helpful_hint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := NewPlanner(tt.generator, Config{})
+			resp := planner.Plan(context.Background(), models.PlanRequest{
+				RequestID: "req-whisper",
+				Server:    models.ServerContext{ServerID: "srv-1"},
+				TimeMS:    1712345000000,
+				Bots:      []models.BotProfile{{BotID: "bot-1", Name: "Kuba"}},
+				Chat: []models.ChatMessage{
+					{TimestampMS: 1712344998000, Sender: "OtherPlayer", SenderType: "PLAYER", Message: "ok"},
+					{TimestampMS: 1712344999000, Sender: tt.sender, SenderType: "PLAYER", Message: tt.message},
+				},
+				Settings: models.PlanSettings{MaxActions: 1, ReplyChance: 1, AllowWhispers: tt.allowWhispers},
+			})
+			if len(resp.Actions) != 1 {
+				t.Fatalf("expected 1 action, got %+v (debug %+v)", resp.Actions, resp.Debug)
+			}
+			action := resp.Actions[0]
+			if action.Visibility != tt.wantVisibility || action.TargetPlayer != tt.wantTarget || action.Reason != tt.wantReason {
+				t.Fatalf("got visibility=%s target=%q reason=%s, want %s %q %s", action.Visibility, action.TargetPlayer, action.Reason, tt.wantVisibility, tt.wantTarget, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const draftURI = "https://json-schema.org/draft/2020-12/schema"
+
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	// snakeCase objects also accept kebab-case spellings of their
+	// properties, like the request decoders do.
+	snakeCase bool
+}
+
+type Document struct {
+	Dialect string `json:"$schema"`
+	ID      string `json:"$id"`
+	Title   string `json:"title"`
+	*Schema
+}
+
+const (
+	maxTimestampMS = 1 << 53
+	maxBots        = 50
+	maxChat        = 100
+	// maxSchemaVersion leaves versions this build does not speak to the
+	// handlers, which answer unsupported_schema_version.
+	maxSchemaVersion = 1<<31 - 1
+)
+
+var (
+	serverSchema = object([]string{"server_id"}, map[string]*Schema{
+		"server_id":      str(1, 64),
+		"mode":           str(0, 32),
+		"online_players": integer(0, 100000),
+	})
+	personaSchema = object(nil, map[string]*Schema{
+		"language":        str(0, 16),
+		"tone":            str(0, 32),
+		"style_tags":      array(str(1, 32), 0, 16),
+		"avoid_topics":    array(str(1, 32), 0, 16),
+		"interests":       array(str(1, 32), 0, 16),
+		"catchphrases":    array(str(1, 48), 0, 16),
+		"quirks":          array(enum("lowercase_only", "no_punctuation", "occasional_typos"), 0, 3),
+		"knowledge_level": str(0, 32),
+	})
+	llmOverridesSchema = object(nil, map[string]*Schema{
+		"temperature": number(0, 2),
+		"top_p":       number(0, 1),
+		"max_tokens":  integer(0, 4096),
+		"model":       str(0, 128),
+	})
+	botSchema = object([]string{"bot_id"}, map[string]*Schema{
+		"bot_id":        str(1, 64),
+		"name":          str(0, 64),
+		"online":        boolean(),
+		"cooldown_ms":   integer(0, 86400000),
+		"persona":       personaSchema,
+		"llm_overrides": llmOverridesSchema,
+	})
+	chatSchema = object([]string{"sender", "sender_type", "message"}, map[string]*Schema{
+		"ts_ms":       integer(0, maxTimestampMS),
+		"sender":      str(1, 64),
+		"sender_type": enum("PLAYER", "BOT", "SYSTEM"),
+		"message":     str(0, 256),
+	})
+	topicCooldownsSchema = keyedObject(map[string]*Schema{
+		"greeting":   integer(0, 3600000),
+		"pvp_invite": integer(0, 3600000),
+		"event":      integer(0, 3600000),
+		"help":       integer(0, 3600000),
+	})
+	llmSettingsSchema = object(nil, map[string]*Schema{
+		"temperature":        number(0, 2),
+		"top_p":              number(0, 1),
+		"max_tokens":         integer(0, 4096),
+		"chat_history_limit": integer(0, maxChat),
+	})
+	settingsSchema = object(nil, map[string]*Schema{
+		"max_actions":                 integer(0, 10),
+		"min_delay_ms":                integer(0, 60000),
+		"max_delay_ms":                integer(0, 60000),
+		"global_silence_chance":       number(0, 1),
+		"reply_chance":                number(0, 1),
+		"allow_whispers":              boolean(),
+		"banter_chance":               number(0, 1),
+		"uninterested_weight":         number(0, 1),
+		"catchphrase_chance":          number(0, 1),
+		"selection_strategy":          enum("random", "least_recent", "weighted"),
+		"topic_cooldown_ms":           integer(0, 3600000),
+		"topic_cooldowns":             topicCooldownsSchema,
+		"quiet":                       boolean(),
+		"max_message_age_ms":          integer(0, 86400000),
+		"plan_deadline_ms":            integer(0, 600000),
+		"max_bot_messages_per_minute": integer(0, 60),
+		"llm":                         llmSettingsSchema,
+	})
+)
+
+var planProperties = map[string]*Schema{
+	"request_id":               str(0, 128),
+	"server":                   serverSchema,
+	"tick":                     integer(0, maxTimestampMS),
+	"time_ms":                  integer(0, maxTimestampMS),
+	"bots":                     array(botSchema, 0, maxBots),
+	"chat":                     array(chatSchema, 0, maxChat),
+	"settings":                 settingsSchema,
+	"required_bot_ids":         array(str(1, 64), 0, maxBots),
+	"required_bypass_cooldown": boolean(),
+	"dry_run":                  boolean(),
+	"explain":                  boolean(),
+	"schema_version":           integer(1, maxSchemaVersion),
+}
+
+var schemas = map[string]*Schema{
+	"plan":       object([]string{"server", "time_ms", "bots"}, planProperties),
+	"plan_async": object([]string{"server", "time_ms", "bots"}, withProperties(planProperties, map[string]*Schema{"callback_url": str(0, 2048)})),
+	"engagement": object([]string{"server", "time_ms", "bots"}, map[string]*Schema{
+		"request_id":     str(0, 128),
+		"server":         serverSchema,
+		"tick":           integer(0, maxTimestampMS),
+		"time_ms":        integer(0, maxTimestampMS),
+		"bots":           array(botSchema, 0, maxBots),
+		"chat":           array(chatSchema, 0, maxChat),
+		"settings":       settingsSchema,
+		"target_player":  str(0, 64),
+		"example_prompt": str(0, 512),
+		"schema_version": integer(1, maxSchemaVersion),
+	}),
+	"register": object([]string{"server_id", "bots"}, map[string]*Schema{
+		"server_id":        str(1, 64),
+		"bots":             array(botSchema, 1, 200),
+		"default_settings": settingsSchema,
+	}),
+}
+
+func Lookup(name string) (*Schema, bool) {
+	s, ok := schemas[name]
+	return s, ok
+}
+
+func Names() []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Export(name string) (Document, bool) {
+	s, ok := schemas[name]
+	if !ok {
+		return Document{}, false
+	}
+	return Document{
+		Dialect: draftURI,
+		ID:      "/v1/schemas/" + name,
+		Title:   fmt.Sprintf("%s request", name),
+		Schema:  s,
+	}, true
+}
+
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decode schema: %w", err)
+	}
+	return &s, nil
+}
+
+func withProperties(base, extra map[string]*Schema) map[string]*Schema {
+	merged := make(map[string]*Schema, len(base)+len(extra))
+	for name, s := range base {
+		merged[name] = s
+	}
+	for name, s := range extra {
+		merged[name] = s
+	}
+	return merged
+}
+
+func object(required []string, properties map[string]*Schema) *Schema {
+	closed := false
+	return &Schema{Type: "object", Properties: properties, Required: required, AdditionalProperties: &closed, snakeCase: true}
+}
+
+// keyedObject is a closed object whose keys are data, not field names.
+func keyedObject(properties map[string]*Schema) *Schema {
+	s := object(nil, properties)
+	s.snakeCase = false
+	return s
+}
+
+func array(items *Schema, minItems, maxItems int) *Schema {
+	s := &Schema{Type: "array", Items: items, MaxItems: &maxItems}
+	if minItems > 0 {
+		s.MinItems = &minItems
+	}
+	return s
+}
+
+func str(minLength, maxLength int) *Schema {
+	s := &Schema{Type: "string", MaxLength: &maxLength}
+	if minLength > 0 {
+		s.MinLength = &minLength
+	}
+	return s
+}
+
+func integer(minimum, maximum float64) *Schema {
+	return &Schema{Type: "integer", Minimum: &minimum, Maximum: &maximum}
+}
+
+func number(minimum, maximum float64) *Schema {
+	return &Schema{Type: "number", Minimum: &minimum, Maximum: &maximum}
+}
+
+func boolean() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+func enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
@@ -0,0 +1,214 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportedSchema(t *testing.T, name string) *Schema {
+	t.Helper()
+	document, ok := Export(name)
+	if !ok {
+		t.Fatalf("Export(%q) not found", name)
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	return s
+}
+
+func TestSamplePayloadsMatchExportedSchemas(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("..", "..", "DOCS", "examples", name+".json"))
+			if err != nil {
+				t.Fatalf("read sample: %v", err)
+			}
+			if violations := Validate(exportedSchema(t, name), payload); len(violations) != 0 {
+				t.Fatalf("sample %s violates schema: %+v", name, violations)
+			}
+		})
+	}
+}
+
+func TestExportedDocumentHeader(t *testing.T) {
+	document, _ := Export("plan")
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var header map[string]any
+	if err := json.Unmarshal(data, &header); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	if header["$schema"] != draftURI || header["$id"] != "/v1/schemas/plan" || header["type"] != "object" {
+		t.Fatalf("unexpected schema header: %v", header)
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	const validBot = `{"bot_id":"bot_01","name":"Kuba"}`
+	tests := []struct {
+		name     string
+		schema   string
+		payload  string
+		wantPath string
+		wantRule string
+	}{
+		{
+			name:     "missing required field",
+			schema:   "register",
+			payload:  `{"bots":[` + validBot + `]}`,
+			wantPath: "/server_id",
+			wantRule: "required",
+		},
+		{
+			name:     "nested required field",
+			schema:   "plan",
+			payload:  `{"server":{},"time_ms":1,"bots":[]}`,
+			wantPath: "/server/server_id",
+			wantRule: "required",
+		},
+		{
+			name:     "string too long",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"chat":[{"sender":"p","sender_type":"PLAYER","message":"` + strings.Repeat("a", 300) + `"}]}`,
+			wantPath: "/chat/0/message",
+			wantRule: "max_length",
+		},
+		{
+			name:     "empty string",
+			schema:   "register",
+			payload:  `{"server_id":"","bots":[` + validBot + `]}`,
+			wantPath: "/server_id",
+			wantRule: "min_length",
+		},
+		{
+			name:     "number out of range",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"settings":{"reply_chance":1.5}}`,
+			wantPath: "/settings/reply_chance",
+			wantRule: "maximum",
+		},
+		{
+			name:     "negative integer",
+			schema:   "engagement",
+			payload:  `{"server":{"server_id":"s"},"time_ms":-5,"bots":[]}`,
+			wantPath: "/time_ms",
+			wantRule: "minimum",
+		},
+		{
+			name:     "fractional integer",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1.5,"bots":[]}`,
+			wantPath: "/time_ms",
+			wantRule: "type",
+		},
+		{
+			name:     "array too long",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"required_bot_ids":["` + strings.Repeat(`b","`, 50) + `b"]}`,
+			wantPath: "/required_bot_ids",
+			wantRule: "max_items",
+		},
+		{
+			name:     "array too short",
+			schema:   "register",
+			payload:  `{"server_id":"s","bots":[]}`,
+			wantPath: "/bots",
+			wantRule: "min_items",
+		},
+		{
+			name:     "enum membership",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"chat":[{"sender":"p","sender_type":"ADMIN","message":"hej"}]}`,
+			wantPath: "/chat/0/sender_type",
+			wantRule: "enum",
+		},
+		{
+			name:     "unknown field",
+			schema:   "register",
+			payload:  `{"server_id":"s","bots":[{"bot_id":"b","a/b":1}]}`,
+			wantPath: "/bots/0/a~1b",
+			wantRule: "additional_properties",
+		},
+		{
+			name:     "wrong type",
+			schema:   "plan",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":{}}`,
+			wantPath: "/bots",
+			wantRule: "type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := Validate(exportedSchema(t, tt.schema), []byte(tt.payload))
+			for _, violation := range violations {
+				if violation.Path == tt.wantPath && violation.Rule == tt.wantRule {
+					return
+				}
+			}
+			t.Fatalf("expected %s violation at %s, got %+v", tt.wantRule, tt.wantPath, violations)
+		})
+	}
+}
+
+func TestValidateKebabCaseKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		wantPath string
+		wantRule string
+	}{
+		{
+			name:    "kebab-case request",
+			payload: `{"server":{"server-id":"s","online-players":3},"time-ms":1,"bots":[{"bot-id":"b","cooldown-ms":0,"persona":{"style-tags":["short"]}}],"settings":{"max-actions":1,"topic-cooldown-ms":5000,"llm":{"top-p":0.5}}}`,
+		},
+		{
+			name:     "kebab-case value out of range",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"settings":{"reply-chance":1.5}}`,
+			wantPath: "/settings/reply_chance",
+			wantRule: "maximum",
+		},
+		{
+			name:     "unknown kebab-case field",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"settings":{"no-such-field":1}}`,
+			wantPath: "/settings/no_such_field",
+			wantRule: "additional_properties",
+		},
+		{
+			name:     "topic keys are not renamed",
+			payload:  `{"server":{"server_id":"s"},"time_ms":1,"bots":[],"settings":{"topic_cooldowns":{"pvp-invite":1000}}}`,
+			wantPath: "/settings/topic_cooldowns/pvp-invite",
+			wantRule: "additional_properties",
+		},
+	}
+
+	s, _ := Lookup("plan")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := Validate(s, []byte(tt.payload))
+			if tt.wantPath == "" {
+				if len(violations) != 0 {
+					t.Fatalf("unexpected violations: %+v", violations)
+				}
+				return
+			}
+			for _, violation := range violations {
+				if violation.Path == tt.wantPath && violation.Rule == tt.wantRule {
+					return
+				}
+			}
+			t.Fatalf("expected %s violation at %s, got %+v", tt.wantRule, tt.wantPath, violations)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"aichatplayers/internal/models"
+)
+
+type Violation = models.ValidationViolation
+
+func Validate(s *Schema, data []byte) []Violation {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []Violation{{Path: "", Rule: "json", Message: err.Error()}}
+	}
+	var violations []Violation
+	validate(s, value, "", &violations)
+	return violations
+}
+
+func validate(s *Schema, value any, path string, violations *[]Violation) {
+	add := func(rule, format string, args ...any) {
+		*violations = append(*violations, Violation{Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		fields, ok := value.(map[string]any)
+		if !ok {
+			add("type", "expected object")
+			return
+		}
+		if s.snakeCase {
+			fields = snakeCaseFields(fields)
+		}
+		for _, name := range s.Required {
+			if _, ok := fields[name]; !ok {
+				*violations = append(*violations, Violation{Path: pointer(path, name), Rule: "required", Message: "field is required"})
+			}
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*violations = append(*violations, Violation{Path: pointer(path, name), Rule: "additional_properties", Message: "unknown field"})
+				}
+				continue
+			}
+			validate(child, fields[name], pointer(path, name), violations)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			add("type", "expected array")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			add("min_items", "expected at least %d items, got %d", *s.MinItems, len(items))
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			add("max_items", "expected at most %d items, got %d", *s.MaxItems, len(items))
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				validate(s.Items, item, pointer(path, strconv.Itoa(i)), violations)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			add("type", "expected string")
+			return
+		}
+		length := utf8.RuneCountInString(text)
+		if s.MinLength != nil && length < *s.MinLength {
+			add("min_length", "expected at least %d characters, got %d", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			add("max_length", "expected at most %d characters, got %d", *s.MaxLength, length)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, text) {
+			add("enum", "expected one of %s", strings.Join(s.Enum, ", "))
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			add("type", "expected %s", s.Type)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				add("type", "expected integer")
+				return
+			}
+		}
+		parsed, err := number.Float64()
+		if err != nil {
+			add("type", "expected %s", s.Type)
+			return
+		}
+		if s.Minimum != nil && parsed < *s.Minimum {
+			add("minimum", "expected >= %s", formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && parsed > *s.Maximum {
+			add("maximum", "expected <= %s", formatNumber(*s.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			add("type", "expected boolean")
+		}
+	}
+}
+
+// pointer appends a reference token using RFC 6901 escaping.
+func pointer(path, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return path + "/" + token
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// snakeCaseFields renames kebab-case keys the way the request decoders do;
+// a snake_case key wins over its kebab-case twin.
+func snakeCaseFields(fields map[string]any) map[string]any {
+	renamed := make(map[string]any, len(fields))
+	for name, value := range fields {
+		if !strings.Contains(name, "-") {
+			renamed[name] = value
+		}
+	}
+	for name, value := range fields {
+		snake := strings.ReplaceAll(name, "-", "_")
+		if _, ok := renamed[snake]; !ok {
+			renamed[snake] = value
+		}
+	}
+	return renamed
+}
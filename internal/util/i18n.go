@@ -0,0 +1,118 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PluralForms struct {
+	One  string
+	Few  string
+	Many string
+}
+
+var (
+	secondForms = map[string]PluralForms{
+		"pl": {One: "sekundę", Few: "sekundy", Many: "sekund"},
+		"en": {One: "second", Many: "seconds"},
+	}
+	minuteForms = map[string]PluralForms{
+		"pl": {One: "minutę", Few: "minuty", Many: "minut"},
+		"en": {One: "minute", Many: "minutes"},
+	}
+	hourForms = map[string]PluralForms{
+		"pl": {One: "godzinę", Few: "godziny", Many: "godzin"},
+		"en": {One: "hour", Many: "hours"},
+	}
+	dayForms = map[string]PluralForms{
+		"pl": {One: "dzień", Few: "dni", Many: "dni"},
+		"en": {One: "day", Many: "days"},
+	}
+)
+
+func Pluralize(lang string, n int, forms PluralForms) string {
+	if n < 0 {
+		n = -n
+	}
+	switch normalizeLang(lang) {
+	case "pl":
+		if n == 1 {
+			return forms.One
+		}
+		mod10 := n % 10
+		mod100 := n % 100
+		if mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14) {
+			return firstNonEmpty(forms.Few, forms.Many)
+		}
+		return forms.Many
+	default:
+		if n == 1 {
+			return forms.One
+		}
+		return forms.Many
+	}
+}
+
+func FormatCount(lang string, n int, forms PluralForms) string {
+	return fmt.Sprintf("%d %s", n, Pluralize(lang, n, forms))
+}
+
+func FormatRelativeTime(lang string, d time.Duration) string {
+	lang = normalizeLang(lang)
+	past := d < 0
+	if past {
+		d = -d
+	}
+
+	var amount int
+	var forms map[string]PluralForms
+	switch {
+	case d < time.Second:
+		if lang == "pl" {
+			return "teraz"
+		}
+		return "now"
+	case d < time.Minute:
+		amount, forms = wholeUnits(d, time.Second), secondForms
+	case d < time.Hour:
+		amount, forms = wholeUnits(d, time.Minute), minuteForms
+	case d < 24*time.Hour:
+		amount, forms = wholeUnits(d, time.Hour), hourForms
+	default:
+		amount, forms = wholeUnits(d, 24*time.Hour), dayForms
+	}
+
+	count := FormatCount(lang, amount, forms[lang])
+	switch {
+	case lang == "pl" && past:
+		return count + " temu"
+	case lang == "pl":
+		return "za " + count
+	case past:
+		return count + " ago"
+	default:
+		return "in " + count
+	}
+}
+
+func wholeUnits(d, unit time.Duration) int {
+	return int(d / unit)
+}
+
+func normalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if strings.HasPrefix(lang, "en") {
+		return "en"
+	}
+	return "pl"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
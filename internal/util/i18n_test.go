@@ -0,0 +1,97 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPluralizePolish(t *testing.T) {
+	forms := PluralForms{One: "minuta", Few: "minuty", Many: "minut"}
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "minut"},
+		{1, "minuta"},
+		{2, "minuty"},
+		{3, "minuty"},
+		{4, "minuty"},
+		{5, "minut"},
+		{11, "minut"},
+		{12, "minut"},
+		{13, "minut"},
+		{14, "minut"},
+		{15, "minut"},
+		{21, "minut"},
+		{22, "minuty"},
+		{23, "minuty"},
+		{24, "minuty"},
+		{25, "minut"},
+		{101, "minut"},
+		{102, "minuty"},
+		{111, "minut"},
+		{112, "minut"},
+		{122, "minuty"},
+		{1004, "minuty"},
+		{-2, "minuty"},
+	}
+	for _, tt := range tests {
+		if got := Pluralize("pl", tt.n, forms); got != tt.want {
+			t.Errorf("Pluralize(pl, %d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPluralizeEnglish(t *testing.T) {
+	forms := PluralForms{One: "player", Many: "players"}
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "players"},
+		{1, "player"},
+		{2, "players"},
+		{21, "players"},
+	}
+	for _, tt := range tests {
+		if got := Pluralize("en", tt.n, forms); got != tt.want {
+			t.Errorf("Pluralize(en, %d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	tests := []struct {
+		lang string
+		d    time.Duration
+		want string
+	}{
+		{"pl", 0, "teraz"},
+		{"pl", 500 * time.Millisecond, "teraz"},
+		{"pl", 1 * time.Second, "za 1 sekundę"},
+		{"pl", 3 * time.Second, "za 3 sekundy"},
+		{"pl", 45 * time.Second, "za 45 sekund"},
+		{"pl", 1 * time.Minute, "za 1 minutę"},
+		{"pl", 5 * time.Minute, "za 5 minut"},
+		{"pl", 22*time.Minute + 30*time.Second, "za 22 minuty"},
+		{"pl", 2 * time.Hour, "za 2 godziny"},
+		{"pl", 12 * time.Hour, "za 12 godzin"},
+		{"pl", 24 * time.Hour, "za 1 dzień"},
+		{"pl", 72 * time.Hour, "za 3 dni"},
+		{"pl", -10 * time.Minute, "10 minut temu"},
+		{"pl", -1 * time.Hour, "1 godzinę temu"},
+		{"", 4 * time.Minute, "za 4 minuty"},
+		{"en", 0, "now"},
+		{"en", 1 * time.Second, "in 1 second"},
+		{"en", 30 * time.Second, "in 30 seconds"},
+		{"en", 1 * time.Minute, "in 1 minute"},
+		{"en", 3 * time.Hour, "in 3 hours"},
+		{"en", 48 * time.Hour, "in 2 days"},
+		{"en", -5 * time.Minute, "5 minutes ago"},
+	}
+	for _, tt := range tests {
+		if got := FormatRelativeTime(tt.lang, tt.d); got != tt.want {
+			t.Errorf("FormatRelativeTime(%q, %s) = %q, want %q", tt.lang, tt.d, got, tt.want)
+		}
+	}
+}
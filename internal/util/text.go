@@ -1,6 +1,9 @@
 package util
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 func NormalizeText(input string) string {
 	lower := strings.ToLower(input)
@@ -16,10 +19,40 @@ func NormalizeText(input string) string {
 	return lower
 }
 
-func ContainsAny(input string, keywords []string) bool {
+// IsFormattingCode reports whether r, read after § or &, is a Minecraft
+// color or style code.
+func IsFormattingCode(r rune) bool {
+	return strings.ContainsRune("0123456789abcdefklmnorx", unicode.ToLower(r))
+}
+
+// Words splits normalized text into runs of letters and digits.
+func Words(input string) []string {
+	return strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ContainsKeyword reports whether input contains one of the keywords.
+// Single-word keywords must match a whole word, so "pvp" does not fire on
+// "pvpowy"; multi-word phrases such as "kto pvp" are matched as substrings
+// of the text with punctuation and repeated spaces collapsed.
+func ContainsKeyword(input string, keywords []string) bool {
+	words := Words(input)
+	var joined string
 	for _, keyword := range keywords {
-		if strings.Contains(input, keyword) {
-			return true
+		if strings.Contains(keyword, " ") {
+			if joined == "" {
+				joined = strings.Join(words, " ")
+			}
+			if strings.Contains(joined, keyword) {
+				return true
+			}
+			continue
+		}
+		for _, word := range words {
+			if word == keyword {
+				return true
+			}
 		}
 	}
 	return false
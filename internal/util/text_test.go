@@ -0,0 +1,37 @@
+package util
+
+import "testing"
+
+func TestContainsKeyword(t *testing.T) {
+	keywords := []string{"kto pvp", "pvp", "1v1", "jak zrobic"}
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "pvp?", want: true},
+		{input: "ktos na 1v1", want: true},
+		{input: "kto   pvp!!", want: true},
+		{input: "jak zrobic portal", want: true},
+		{input: "pvpowy ranking", want: false},
+		{input: "superpvp", want: false},
+		{input: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := ContainsKeyword(NormalizeText(tt.input), keywords); got != tt.want {
+			t.Fatalf("ContainsKeyword(%q) = %t, want %t", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsFormattingCode(t *testing.T) {
+	for _, r := range "09afAFklmnorxKX" {
+		if !IsFormattingCode(r) {
+			t.Fatalf("IsFormattingCode(%q) = false, want true", r)
+		}
+	}
+	for _, r := range "gzG_ §&" {
+		if IsFormattingCode(r) {
+			t.Fatalf("IsFormattingCode(%q) = true, want false", r)
+		}
+	}
+}
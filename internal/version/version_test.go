@@ -0,0 +1,26 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(version, commit, buildDate string) {
+		Version, Commit, BuildDate = version, commit, buildDate
+	}(Version, Commit, BuildDate)
+
+	Version, Commit, BuildDate = "v1.2.3", "abc1234", "2026-10-01T12:00:00Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.BuildDate != "2026-10-01T12:00:00Z" || info.GoVersion == "" {
+		t.Fatalf("Get() = %+v", info)
+	}
+	if got := info.String(); !strings.HasPrefix(got, "aichatplayers v1.2.3 (commit abc1234, built 2026-10-01T12:00:00Z, go") {
+		t.Fatalf("String() = %q", got)
+	}
+
+	Commit, BuildDate = "", ""
+	if info := Get(); info.Commit == "" || info.BuildDate == "" {
+		t.Fatalf("unset commit and build date not filled in: %+v", info)
+	}
+}